@@ -0,0 +1,146 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/adapter"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/service"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/handler"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"go-micro.dev/v4"
+)
+
+func main() {
+	configPath := flag.String("config_path", "./config/config.yml", "server configuration path")
+	flag.Parse()
+
+	serverConfig, err := config.BuildNewServerConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	credentialsConfig, err := shared.BuildNewCredentialsConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	persistenceConfig, err := config.BuildNewPersistenceConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	workerConfig, err := config.BuildNewWorkerConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	logger := log.NewDefaultLogger(serverConfig)
+
+	onlyofficeConfig, err := shared.NewOnlyofficeConfigSource(*configPath, logger.Warnf)
+	if err != nil {
+		logger.Fatalf("could not load the onlyoffice configuration: %s", err.Error())
+	}
+
+	userAdapter, err := adapter.NewMongoUserAdapter(persistenceConfig.Persistence.URL)
+	if err != nil {
+		logger.Fatalf("could not connect to the persistence backend: %s", err.Error())
+	}
+
+	// The read cache sits in front of mongo; writes through the adapter and
+	// the cache rpc handler both drop a user's entry, so admin-side changes
+	// never serve out their TTL.
+	var cacheInvalidator port.UserCacheInvalidator
+	if ttl := onlyofficeConfig.Config().Onlyoffice.Auth.CacheTTL; ttl > 0 {
+		cached := adapter.NewCachingUserAdapter(userAdapter, time.Duration(ttl)*time.Second)
+		userAdapter = cached
+		cacheInvalidator = cached
+	}
+
+	// aes-gcm binds stored tokens to their user id; rows written by the
+	// legacy encryptor keep decrypting through its fallback, so the switch
+	// needs no migration pass.
+	encryptor := crypto.NewAesGcmEncryptor()
+	if onlyofficeConfig.Config().Onlyoffice.Auth.Encryption == shared.EncryptionLegacy {
+		encryptor = crypto.NewLegacyEncryptor()
+	}
+
+	userService := service.NewUserAccessService(
+		userAdapter, encryptor,
+		[]byte(credentialsConfig.Credentials.ClientSecret), logger,
+	)
+
+	srv := micro.NewService(
+		micro.Name(fmt.Sprintf("%s:auth", serverConfig.Namespace)),
+		micro.Address(serverConfig.Address),
+	)
+	srv.Init()
+
+	// With several auth replicas the token refresh critical section is
+	// guarded by a redis lock on the worker's redis; without one the
+	// handler falls back to per-process deduplication.
+	var refreshLocker port.RefreshLocker
+	if len(workerConfig.RedisAddresses) > 0 {
+		// The breaker keeps an unreachable redis from adding a timeout to
+		// every login: after a few consecutive failures lock operations are
+		// skipped for a cool-down and the handler deduplicates locally.
+		refreshLocker = adapter.NewBreakerRefreshLocker(adapter.NewRedisRefreshLocker(
+			workerConfig.RedisAddresses, workerConfig.RedisUsername,
+			workerConfig.RedisPassword, workerConfig.RedisDatabase,
+		), logger.Warnf)
+	}
+
+	refreshMetrics := handler.NewRefreshMetrics()
+	selectHandler := handler.NewUserSelectHandler(userService, credentialsConfig, refreshMetrics, refreshLocker, logger)
+	insertHandler := handler.NewUserInsertHandler(userService, logger)
+	deleteHandler := handler.NewUserDeleteHandler(userService, logger)
+	cacheHandler := handler.NewUserCacheHandler(cacheInvalidator, logger)
+
+	for _, h := range []interface{}{&selectHandler, &insertHandler, &deleteHandler, &cacheHandler} {
+		if err := micro.RegisterHandler(srv.Server(), h); err != nil {
+			logger.Fatalf("could not register an rpc handler: %s", err.Error())
+		}
+	}
+
+	// The repl listener only carries operational endpoints; rpc traffic
+	// stays on the micro server. The repl config can move it to its own
+	// interface and decides whether pprof is mounted at all.
+	go func() {
+		repl := onlyofficeConfig.Config().Onlyoffice.Repl
+		address := serverConfig.ReplAddress
+		if repl.Address != "" {
+			address = repl.Address
+		}
+		if err := http.ListenAndServe(address, shared.NewReplMux(repl)); err != nil {
+			logger.Errorf("auth repl stopped: %s", err.Error())
+		}
+	}()
+
+	if err := srv.Run(); err != nil {
+		logger.Fatalf("auth stopped: %s", err.Error())
+	}
+}
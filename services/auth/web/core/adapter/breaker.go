@@ -0,0 +1,132 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// breakerFailureThreshold is how many consecutive backend failures
+	// open the breaker.
+	breakerFailureThreshold = 3
+	// breakerCooldown is how long lock operations are skipped once the
+	// breaker is open. Callers fall back to local deduplication, so the
+	// only cost of staying open is cross-replica refresh duplication.
+	breakerCooldown = 30 * time.Second
+	// breakerUnlockTimeout bounds the fire-and-forget unlock; the lock TTL
+	// releases it anyway when the backend stays unreachable.
+	breakerUnlockTimeout = time.Second
+)
+
+var breakerTrips = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "onlyoffice_auth_lock_breaker_trips_total",
+	Help: "Times the refresh lock circuit breaker opened.",
+})
+
+// ErrLockBreakerOpen is returned instead of touching the backend while the
+// breaker cools down.
+var ErrLockBreakerOpen = errors.New("refresh lock backend circuit breaker is open")
+
+// breakerRefreshLocker wraps a refresh locker with a circuit breaker: after
+// consecutive backend failures every lock operation is skipped for a
+// cool-down period, so an unreachable redis costs one timeout per window
+// instead of one per login. Correctness is unaffected — the lock only
+// deduplicates refreshes, the store stays the source of truth.
+type breakerRefreshLocker struct {
+	inner port.RefreshLocker
+	warn  func(format string, args ...interface{})
+	now   func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewBreakerRefreshLocker decorates a refresh locker with the circuit
+// breaker. The warn function receives one line per trip.
+func NewBreakerRefreshLocker(
+	inner port.RefreshLocker, warn func(format string, args ...interface{}),
+) port.RefreshLocker {
+	return &breakerRefreshLocker{
+		inner: inner,
+		warn:  warn,
+		now:   time.Now,
+	}
+}
+
+func (l *breakerRefreshLocker) TryLock(ctx context.Context, uid string, ttl time.Duration) (bool, error) {
+	if l.open() {
+		return false, ErrLockBreakerOpen
+	}
+
+	acquired, err := l.inner.TryLock(ctx, uid, ttl)
+	l.observe(err)
+	return acquired, err
+}
+
+// Unlock is fire-and-forget: the caller already left the critical section
+// and the lock TTL bounds how long a stuck key can last, so the backend
+// round trip runs off the request path with its own short timeout.
+func (l *breakerRefreshLocker) Unlock(ctx context.Context, uid string) error {
+	if l.open() {
+		return ErrLockBreakerOpen
+	}
+
+	go func() {
+		tctx, cancel := context.WithTimeout(context.Background(), breakerUnlockTimeout)
+		defer cancel()
+		l.observe(l.inner.Unlock(tctx, uid))
+	}()
+	return nil
+}
+
+func (l *breakerRefreshLocker) open() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.now().Before(l.openUntil)
+}
+
+func (l *breakerRefreshLocker) observe(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err == nil {
+		l.failures = 0
+		return
+	}
+
+	l.failures++
+	if l.failures >= breakerFailureThreshold {
+		l.failures = 0
+		l.openUntil = l.now().Add(breakerCooldown)
+		breakerTrips.Inc()
+		l.warn(
+			"refresh lock backend failed %d times, skipping lock operations for %s: %s",
+			breakerFailureThreshold, breakerCooldown, err.Error(),
+		)
+	}
+}
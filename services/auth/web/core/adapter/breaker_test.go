@@ -0,0 +1,122 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingLocker always reports the backend as unreachable and counts how
+// often it is actually called.
+type failingLocker struct {
+	calls int32
+}
+
+func (l *failingLocker) TryLock(ctx context.Context, uid string, ttl time.Duration) (bool, error) {
+	atomic.AddInt32(&l.calls, 1)
+	return false, errors.New("connection refused")
+}
+
+func (l *failingLocker) Unlock(ctx context.Context, uid string) error {
+	atomic.AddInt32(&l.calls, 1)
+	return errors.New("connection refused")
+}
+
+func newTestBreaker(inner *failingLocker, now *time.Time) *breakerRefreshLocker {
+	return &breakerRefreshLocker{
+		inner: inner,
+		warn:  func(format string, args ...interface{}) {},
+		now:   func() time.Time { return *now },
+	}
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	inner := &failingLocker{}
+	breaker := newTestBreaker(inner, &now)
+
+	for i := 0; i < breakerFailureThreshold; i++ {
+		if _, err := breaker.TryLock(context.Background(), "1000000000", time.Second); err == nil {
+			t.Fatal("expected the backend failure to surface")
+		}
+	}
+
+	// The breaker is open now: the backend must not be touched and the
+	// caller must get the open error immediately.
+	if _, err := breaker.TryLock(context.Background(), "1000000000", time.Second); !errors.Is(err, ErrLockBreakerOpen) {
+		t.Fatalf("expected ErrLockBreakerOpen, got %v", err)
+	}
+	if got := atomic.LoadInt32(&inner.calls); got != breakerFailureThreshold {
+		t.Errorf("expected %d backend calls, got %d", breakerFailureThreshold, got)
+	}
+}
+
+func TestBreakerClosesAfterCooldown(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	inner := &failingLocker{}
+	breaker := newTestBreaker(inner, &now)
+
+	for i := 0; i < breakerFailureThreshold; i++ {
+		breaker.TryLock(context.Background(), "1000000000", time.Second)
+	}
+	if _, err := breaker.TryLock(context.Background(), "1000000000", time.Second); !errors.Is(err, ErrLockBreakerOpen) {
+		t.Fatalf("expected the breaker to be open, got %v", err)
+	}
+
+	now = now.Add(breakerCooldown + time.Second)
+	if _, err := breaker.TryLock(context.Background(), "1000000000", time.Second); errors.Is(err, ErrLockBreakerOpen) {
+		t.Fatal("expected the breaker to close after the cooldown")
+	}
+	if got := atomic.LoadInt32(&inner.calls); got != breakerFailureThreshold+1 {
+		t.Errorf("expected the backend to be probed again, got %d calls", got)
+	}
+}
+
+func TestBreakerSuccessResetsFailureCount(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breaker := newTestBreaker(&failingLocker{}, &now)
+
+	breaker.observe(errors.New("connection refused"))
+	breaker.observe(errors.New("connection refused"))
+	breaker.observe(nil)
+	breaker.observe(errors.New("connection refused"))
+
+	if breaker.open() {
+		t.Error("a success between failures must keep the breaker closed")
+	}
+}
+
+// Unlock must not add the backend's latency to the request path even when
+// the breaker is still closed.
+func TestBreakerUnlockIsFireAndForget(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breaker := newTestBreaker(&failingLocker{}, &now)
+
+	started := time.Now()
+	if err := breaker.Unlock(context.Background(), "1000000000"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > 100*time.Millisecond {
+		t.Errorf("unlock blocked the caller for %s", elapsed)
+	}
+}
@@ -0,0 +1,133 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package adapter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/domain"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+)
+
+type cachedUser struct {
+	user    domain.UserAccess
+	fetched time.Time
+}
+
+// cachingUserAdapter decorates the persistence adapter with a short
+// per-user read cache, so hot users do not hit the backend on every RPC.
+// Every write through the adapter drops the user's entry, which is what
+// keeps UserInsertHandler, the refresh persist path and DeleteUser from
+// ever serving tokens the backend no longer holds; Invalidate covers
+// changes made behind the service's back, like a support fix in Mongo.
+type cachingUserAdapter struct {
+	inner port.UserAccessServiceAdapter
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedUser
+}
+
+// NewCachingUserAdapter wraps the adapter with a read cache holding each
+// user for ttl.
+func NewCachingUserAdapter(inner port.UserAccessServiceAdapter, ttl time.Duration) port.CachedUserAccessAdapter {
+	return &cachingUserAdapter{
+		inner:   inner,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]cachedUser),
+	}
+}
+
+func (a *cachingUserAdapter) SelectUser(ctx context.Context, uid string) (domain.UserAccess, error) {
+	a.mu.Lock()
+	entry, ok := a.entries[uid]
+	fresh := ok && a.now().Sub(entry.fetched) < a.ttl
+	a.mu.Unlock()
+	if fresh {
+		return entry.user, nil
+	}
+
+	user, err := a.inner.SelectUser(ctx, uid)
+	if err != nil {
+		return user, err
+	}
+
+	a.mu.Lock()
+	a.entries[uid] = cachedUser{user: user, fetched: a.now()}
+	a.mu.Unlock()
+	return user, nil
+}
+
+func (a *cachingUserAdapter) SelectUsers(ctx context.Context, uids []string) ([]domain.UserAccess, error) {
+	users := make([]domain.UserAccess, 0, len(uids))
+	var misses []string
+
+	a.mu.Lock()
+	for _, uid := range uids {
+		if entry, ok := a.entries[uid]; ok && a.now().Sub(entry.fetched) < a.ttl {
+			users = append(users, entry.user)
+		} else {
+			misses = append(misses, uid)
+		}
+	}
+	a.mu.Unlock()
+
+	if len(misses) == 0 {
+		return users, nil
+	}
+
+	fetched, err := a.inner.SelectUsers(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	for _, user := range fetched {
+		a.entries[user.ID] = cachedUser{user: user, fetched: a.now()}
+	}
+	a.mu.Unlock()
+	return append(users, fetched...), nil
+}
+
+func (a *cachingUserAdapter) InsertUser(ctx context.Context, user domain.UserAccess) error {
+	a.Invalidate(user.ID)
+	return a.inner.InsertUser(ctx, user)
+}
+
+func (a *cachingUserAdapter) UpsertUser(ctx context.Context, user domain.UserAccess) error {
+	a.Invalidate(user.ID)
+	return a.inner.UpsertUser(ctx, user)
+}
+
+func (a *cachingUserAdapter) DeleteUser(ctx context.Context, uid string) error {
+	a.Invalidate(uid)
+	return a.inner.DeleteUser(ctx, uid)
+}
+
+// Invalidate drops the user's cached record so the next select reads the
+// backend.
+func (a *cachingUserAdapter) Invalidate(uid string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.entries, uid)
+}
@@ -0,0 +1,193 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/domain"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+)
+
+// countingAdapter records backend hits so the tests can tell a cached
+// answer from a real select.
+type countingAdapter struct {
+	selects int
+	batches int
+	user    domain.UserAccess
+	users   []domain.UserAccess
+}
+
+func (a *countingAdapter) InsertUser(ctx context.Context, user domain.UserAccess) error {
+	return nil
+}
+
+func (a *countingAdapter) SelectUser(ctx context.Context, uid string) (domain.UserAccess, error) {
+	a.selects++
+	return a.user, nil
+}
+
+func (a *countingAdapter) SelectUsers(ctx context.Context, uids []string) ([]domain.UserAccess, error) {
+	a.batches++
+	requested := map[string]bool{}
+	for _, uid := range uids {
+		requested[uid] = true
+	}
+	var users []domain.UserAccess
+	for _, user := range a.users {
+		if requested[user.ID] {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (a *countingAdapter) UpsertUser(ctx context.Context, user domain.UserAccess) error {
+	return nil
+}
+
+func (a *countingAdapter) DeleteUser(ctx context.Context, uid string) error {
+	return nil
+}
+
+func TestCachingAdapterServesSelectsFromCache(t *testing.T) {
+	inner := &countingAdapter{user: domain.UserAccess{ID: "user-1", AccessToken: "token"}}
+	cached := NewCachingUserAdapter(inner, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		user, err := cached.SelectUser(context.Background(), "user-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.AccessToken != "token" {
+			t.Fatalf("expected the stored tokens, got %q", user.AccessToken)
+		}
+	}
+	if inner.selects != 1 {
+		t.Errorf("expected one backend select for three reads, got %d", inner.selects)
+	}
+}
+
+func TestCachingAdapterInvalidateForcesABackendRead(t *testing.T) {
+	inner := &countingAdapter{user: domain.UserAccess{ID: "user-1"}}
+	cached := NewCachingUserAdapter(inner, time.Minute)
+
+	if _, err := cached.SelectUser(context.Background(), "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	cached.Invalidate("user-1")
+	if _, err := cached.SelectUser(context.Background(), "user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if inner.selects != 2 {
+		t.Errorf("expected the select after invalidation to hit the backend, got %d selects", inner.selects)
+	}
+}
+
+func TestCachingAdapterWritesInvalidateAutomatically(t *testing.T) {
+	for name, write := range map[string]func(cached port.CachedUserAccessAdapter) error{
+		"upsert": func(cached port.CachedUserAccessAdapter) error {
+			return cached.UpsertUser(context.Background(), domain.UserAccess{ID: "user-1"})
+		},
+		"delete": func(cached port.CachedUserAccessAdapter) error {
+			return cached.DeleteUser(context.Background(), "user-1")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			inner := &countingAdapter{user: domain.UserAccess{ID: "user-1"}}
+			cached := NewCachingUserAdapter(inner, time.Minute)
+
+			if _, err := cached.SelectUser(context.Background(), "user-1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := write(cached); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := cached.SelectUser(context.Background(), "user-1"); err != nil {
+				t.Fatal(err)
+			}
+
+			if inner.selects != 2 {
+				t.Errorf("expected the write to drop the cached entry, got %d selects", inner.selects)
+			}
+		})
+	}
+}
+
+func TestCachingAdapterEntriesExpire(t *testing.T) {
+	inner := &countingAdapter{user: domain.UserAccess{ID: "user-1"}}
+	cached := NewCachingUserAdapter(inner, time.Minute).(*cachingUserAdapter)
+
+	now := time.Now()
+	cached.now = func() time.Time { return now }
+
+	if _, err := cached.SelectUser(context.Background(), "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute + time.Second)
+	if _, err := cached.SelectUser(context.Background(), "user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if inner.selects != 2 {
+		t.Errorf("expected the expired entry to be refetched, got %d selects", inner.selects)
+	}
+}
+
+// A batch select serves cached entries from memory and fetches only the
+// misses, in a single backend round trip.
+func TestCachingAdapterBatchesMisses(t *testing.T) {
+	inner := &countingAdapter{
+		user: domain.UserAccess{ID: "user-1"},
+		users: []domain.UserAccess{
+			{ID: "user-2", AccessToken: "token-2"},
+			{ID: "user-3", AccessToken: "token-3"},
+		},
+	}
+	cached := NewCachingUserAdapter(inner, time.Minute)
+
+	if _, err := cached.SelectUser(context.Background(), "user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := cached.SelectUsers(
+		context.Background(), []string{"user-1", "user-2", "user-3", "user-4"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 {
+		t.Fatalf("expected the three stored users, got %d", len(users))
+	}
+	if inner.batches != 1 || inner.selects != 1 {
+		t.Errorf("expected one backend batch for the misses, got %d batches and %d selects",
+			inner.batches, inner.selects)
+	}
+
+	// The fetched misses are now cached too.
+	if _, err := cached.SelectUsers(context.Background(), []string{"user-2", "user-3"}); err != nil {
+		t.Fatal(err)
+	}
+	if inner.batches != 1 {
+		t.Errorf("expected the second batch to be served from cache, got %d batches", inner.batches)
+	}
+}
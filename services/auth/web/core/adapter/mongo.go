@@ -0,0 +1,101 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/domain"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrUserNotFound aliases the port-level sentinel, kept here because the
+// adapter package is where callers historically imported it from.
+var ErrUserNotFound = port.ErrUserNotFound
+
+type mongoUserAdapter struct {
+	collection *mongo.Collection
+}
+
+// NewMongoUserAdapter connects to the mongo instance at url and returns an
+// adapter over the users collection.
+func NewMongoUserAdapter(url string) (port.UserAccessServiceAdapter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(url))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	return mongoUserAdapter{
+		collection: client.Database("gdrive").Collection("users"),
+	}, nil
+}
+
+func (a mongoUserAdapter) InsertUser(ctx context.Context, user domain.UserAccess) error {
+	_, err := a.collection.InsertOne(ctx, user)
+	return err
+}
+
+func (a mongoUserAdapter) SelectUser(ctx context.Context, uid string) (domain.UserAccess, error) {
+	var user domain.UserAccess
+	err := a.collection.FindOne(ctx, bson.M{"_id": uid}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return user, ErrUserNotFound
+	}
+
+	return user, err
+}
+
+func (a mongoUserAdapter) SelectUsers(ctx context.Context, uids []string) ([]domain.UserAccess, error) {
+	cursor, err := a.collection.Find(ctx, bson.M{"_id": bson.M{"$in": uids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []domain.UserAccess
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (a mongoUserAdapter) UpsertUser(ctx context.Context, user domain.UserAccess) error {
+	_, err := a.collection.ReplaceOne(
+		ctx, bson.M{"_id": user.ID}, user,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+func (a mongoUserAdapter) DeleteUser(ctx context.Context, uid string) error {
+	_, err := a.collection.DeleteOne(ctx, bson.M{"_id": uid})
+	return err
+}
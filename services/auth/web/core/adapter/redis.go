@@ -0,0 +1,61 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+	"github.com/go-redis/redis/v8"
+)
+
+// redisRefreshLocker implements port.RefreshLocker with a SETNX key per
+// user, reusing the redis the background worker already runs on. The TTL
+// is the only release guarantee a crashed replica gets, so callers keep
+// it short.
+type redisRefreshLocker struct {
+	client redis.UniversalClient
+}
+
+// NewRedisRefreshLocker builds a refresh locker over the given redis
+// nodes; a single address yields a plain client, several a cluster one.
+func NewRedisRefreshLocker(addresses []string, username, password string, database int) port.RefreshLocker {
+	return redisRefreshLocker{
+		client: redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs:    addresses,
+			Username: username,
+			Password: password,
+			DB:       database,
+		}),
+	}
+}
+
+func (l redisRefreshLocker) TryLock(ctx context.Context, uid string, ttl time.Duration) (bool, error) {
+	return l.client.SetNX(ctx, refreshLockKey(uid), 1, ttl).Result()
+}
+
+func (l redisRefreshLocker) Unlock(ctx context.Context, uid string) error {
+	return l.client.Del(ctx, refreshLockKey(uid)).Err()
+}
+
+func refreshLockKey(uid string) string {
+	return fmt.Sprintf("auth:refresh-lock:%s", uid)
+}
@@ -0,0 +1,134 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package crypto holds the token encryptors of the auth service. The
+// adapters' encryptor protects confidentiality only: a ciphertext moved
+// onto another user's record decrypts fine there. The aes-gcm encryptor
+// closes that gap by authenticating the user id along with the tokens.
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	acrypto "github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+)
+
+// Encryptor encrypts a secret bound to additional authenticated data. The
+// aad is not stored with the ciphertext; decryption must present the same
+// bytes, so a record swap in the store surfaces as a decryption failure.
+type Encryptor interface {
+	Encrypt(text string, key, aad []byte) (string, error)
+	Decrypt(text string, key, aad []byte) (string, error)
+}
+
+// legacyEncryptor adapts the adapters-package encryptor to the aad-aware
+// interface. The aad is accepted and ignored, which is exactly the property
+// the aes-gcm implementation exists to fix.
+type legacyEncryptor struct {
+	encryptor acrypto.Encryptor
+}
+
+func NewLegacyEncryptor() Encryptor {
+	return legacyEncryptor{encryptor: acrypto.NewAesEncryptor()}
+}
+
+func (e legacyEncryptor) Encrypt(text string, key, _ []byte) (string, error) {
+	return e.encryptor.Encrypt(text, key)
+}
+
+func (e legacyEncryptor) Decrypt(text string, key, _ []byte) (string, error) {
+	return e.encryptor.Decrypt(text, key)
+}
+
+// gcmPrefix marks ciphertexts written by the aes-gcm encryptor. The legacy
+// output is plain base64 and can never contain a colon, so the prefix is an
+// unambiguous format marker.
+const gcmPrefix = "gcm:"
+
+// aesGcmEncryptor seals tokens with AES-256-GCM. Rows written before the
+// rollout lack the format prefix and fall through to the legacy decryptor,
+// so the migration needs no offline re-encryption pass: records upgrade to
+// the new format the next time they are written.
+type aesGcmEncryptor struct {
+	legacy Encryptor
+}
+
+func NewAesGcmEncryptor() Encryptor {
+	return aesGcmEncryptor{legacy: NewLegacyEncryptor()}
+}
+
+func (e aesGcmEncryptor) Encrypt(text string, key, aad []byte) (string, error) {
+	aead, err := newAead(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(text), aad)
+	return gcmPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (e aesGcmEncryptor) Decrypt(text string, key, aad []byte) (string, error) {
+	if !strings.HasPrefix(text, gcmPrefix) {
+		// A row written before the gcm rollout. Tampered gcm ciphertexts
+		// never reach this branch: they carry the prefix and fail inside
+		// Open, where falling back would defeat the authentication.
+		return e.legacy.Decrypt(text, key, aad)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(text, gcmPrefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed gcm ciphertext: %w", err)
+	}
+
+	aead, err := newAead(key)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < aead.NonceSize() {
+		return "", fmt.Errorf("gcm ciphertext is shorter than a nonce")
+	}
+
+	opened, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], aad)
+	if err != nil {
+		return "", fmt.Errorf("could not open gcm ciphertext: %w", err)
+	}
+	return string(opened), nil
+}
+
+// newAead builds the AES-256-GCM cipher, stretching the configured secret
+// to the 32 bytes the key schedule needs.
+func newAead(key []byte) (cipher.AEAD, error) {
+	derived := sha256.Sum256(key)
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
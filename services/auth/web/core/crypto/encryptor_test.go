@@ -0,0 +1,124 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package crypto
+
+import (
+	"strings"
+	"testing"
+)
+
+var (
+	testKey = []byte("client-secret")
+	testAad = []byte("1000000000")
+)
+
+func TestAesGcmRoundTrip(t *testing.T) {
+	encryptor := NewAesGcmEncryptor()
+
+	sealed, err := encryptor.Encrypt("ya29.access-token", testKey, testAad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, gcmPrefix) {
+		t.Fatalf("expected a gcm-format ciphertext, got %q", sealed)
+	}
+	if strings.Contains(sealed, "access-token") {
+		t.Fatal("the ciphertext leaks the plaintext")
+	}
+
+	opened, err := encryptor.Decrypt(sealed, testKey, testAad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != "ya29.access-token" {
+		t.Fatalf("unexpected plaintext: %q", opened)
+	}
+}
+
+func TestAesGcmRejectsTampering(t *testing.T) {
+	encryptor := NewAesGcmEncryptor()
+
+	sealed, err := encryptor.Encrypt("ya29.access-token", testKey, testAad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip one character of the base64 payload past the prefix.
+	tampered := []byte(sealed)
+	index := len(gcmPrefix) + 3
+	if tampered[index] == 'A' {
+		tampered[index] = 'B'
+	} else {
+		tampered[index] = 'A'
+	}
+
+	if _, err := encryptor.Decrypt(string(tampered), testKey, testAad); err == nil {
+		t.Fatal("expected a tampered ciphertext to fail")
+	}
+}
+
+// A ciphertext moved onto another user's record presents that user's id as
+// aad and must not open; this is the row-swap scenario the aad exists for.
+func TestAesGcmRejectsTheWrongAad(t *testing.T) {
+	encryptor := NewAesGcmEncryptor()
+
+	sealed, err := encryptor.Encrypt("ya29.access-token", testKey, testAad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := encryptor.Decrypt(sealed, testKey, []byte("2000000000")); err == nil {
+		t.Fatal("expected a decrypt under another user's id to fail")
+	}
+}
+
+// Rows written before the gcm rollout decrypt through the legacy fallback
+// regardless of the aad, so the switch needs no migration pass.
+func TestAesGcmDecryptsLegacyCiphertexts(t *testing.T) {
+	legacy := NewLegacyEncryptor()
+	sealed, err := legacy.Encrypt("1//refresh-token", testKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := NewAesGcmEncryptor().Decrypt(sealed, testKey, testAad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != "1//refresh-token" {
+		t.Fatalf("unexpected plaintext: %q", opened)
+	}
+}
+
+func TestAesGcmNoncesDiffer(t *testing.T) {
+	encryptor := NewAesGcmEncryptor()
+
+	first, err := encryptor.Encrypt("ya29.access-token", testKey, testAad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := encryptor.Encrypt("ya29.access-token", testKey, testAad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Fatal("expected fresh nonces to produce distinct ciphertexts")
+	}
+}
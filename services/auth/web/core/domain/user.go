@@ -0,0 +1,42 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package domain
+
+import "errors"
+
+var ErrInvalidUser = errors.New("invalid user access entry")
+
+// UserAccess is a persisted Google OAuth credential set. Tokens are stored
+// encrypted at rest.
+type UserAccess struct {
+	ID           string `json:"id" bson:"_id"`
+	AccessToken  string `json:"access_token" bson:"access_token"`
+	RefreshToken string `json:"refresh_token" bson:"refresh_token"`
+	TokenType    string `json:"token_type" bson:"token_type"`
+	Scope        string `json:"scope" bson:"scope"`
+	Expiry       int64  `json:"expiry" bson:"expiry"`
+}
+
+func (u UserAccess) Validate() error {
+	if u.ID == "" || u.AccessToken == "" || u.RefreshToken == "" {
+		return ErrInvalidUser
+	}
+
+	return nil
+}
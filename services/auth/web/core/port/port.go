@@ -0,0 +1,82 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package port
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/domain"
+)
+
+// ErrUserNotFound is what adapters and services return for an id with no
+// stored record.
+var ErrUserNotFound = errors.New("user not found")
+
+// UserAccessServiceAdapter persists user access entries. SelectUsers
+// resolves a batch in one backend round trip and only returns the records
+// that exist; a requested id without a record is not an error.
+type UserAccessServiceAdapter interface {
+	InsertUser(ctx context.Context, user domain.UserAccess) error
+	SelectUser(ctx context.Context, uid string) (domain.UserAccess, error)
+	SelectUsers(ctx context.Context, uids []string) ([]domain.UserAccess, error)
+	UpsertUser(ctx context.Context, user domain.UserAccess) error
+	DeleteUser(ctx context.Context, uid string) error
+}
+
+// UserCacheInvalidator drops a user's cached record so the next select
+// reads the persistence backend.
+type UserCacheInvalidator interface {
+	Invalidate(uid string)
+}
+
+// CachedUserAccessAdapter is a persistence adapter with a read cache in
+// front; the invalidator side is what the cache rpc handler is wired to.
+type CachedUserAccessAdapter interface {
+	UserAccessServiceAdapter
+	UserCacheInvalidator
+}
+
+// RefreshLocker serializes the token refresh critical section for one user
+// across auth replicas. TryLock returns false when another replica already
+// holds the lock; an error means the lock backend itself is unavailable and
+// the caller should fall back to local-only deduplication.
+type RefreshLocker interface {
+	TryLock(ctx context.Context, uid string, ttl time.Duration) (bool, error)
+	Unlock(ctx context.Context, uid string) error
+}
+
+// UserBatchResult pairs one requested user id with its outcome in a batch
+// lookup. Err isolates a record that is missing or failed to decrypt, so
+// one bad entry never fails the rest of the batch.
+type UserBatchResult struct {
+	User domain.UserAccess
+	Err  error
+}
+
+// UserAccessService exposes user access operations to the rpc handlers.
+// GetUsers resolves a batch of ids in request order, one result per id.
+type UserAccessService interface {
+	CreateUser(ctx context.Context, user domain.UserAccess) error
+	GetUser(ctx context.Context, uid string) (domain.UserAccess, error)
+	GetUsers(ctx context.Context, uids []string) ([]UserBatchResult, error)
+	UpdateUser(ctx context.Context, user domain.UserAccess) error
+	RemoveUser(ctx context.Context, uid string) error
+}
@@ -0,0 +1,153 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package service
+
+import (
+	"context"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/domain"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+)
+
+// userAccessService encrypts tokens before they hit the persistence adapter
+// and decrypts them on the way out.
+type userAccessService struct {
+	adapter   port.UserAccessServiceAdapter
+	encryptor crypto.Encryptor
+	key       []byte
+	logger    log.Logger
+}
+
+func NewUserAccessService(
+	adapter port.UserAccessServiceAdapter, encryptor crypto.Encryptor,
+	key []byte, logger log.Logger,
+) port.UserAccessService {
+	return userAccessService{
+		adapter:   adapter,
+		encryptor: encryptor,
+		key:       key,
+		logger:    logger,
+	}
+}
+
+func (s userAccessService) encrypt(user domain.UserAccess) (domain.UserAccess, error) {
+	// The user id as aad binds both ciphertexts to their record: tokens
+	// swapped onto another user's row no longer decrypt.
+	atoken, err := s.encryptor.Encrypt(user.AccessToken, s.key, []byte(user.ID))
+	if err != nil {
+		return user, err
+	}
+
+	rtoken, err := s.encryptor.Encrypt(user.RefreshToken, s.key, []byte(user.ID))
+	if err != nil {
+		return user, err
+	}
+
+	user.AccessToken, user.RefreshToken = atoken, rtoken
+	return user, nil
+}
+
+func (s userAccessService) decrypt(user domain.UserAccess) (domain.UserAccess, error) {
+	atoken, err := s.encryptor.Decrypt(user.AccessToken, s.key, []byte(user.ID))
+	if err != nil {
+		return user, err
+	}
+
+	rtoken, err := s.encryptor.Decrypt(user.RefreshToken, s.key, []byte(user.ID))
+	if err != nil {
+		return user, err
+	}
+
+	user.AccessToken, user.RefreshToken = atoken, rtoken
+	return user, nil
+}
+
+func (s userAccessService) CreateUser(ctx context.Context, user domain.UserAccess) error {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+
+	user, err := s.encrypt(user)
+	if err != nil {
+		return err
+	}
+
+	return s.adapter.UpsertUser(ctx, user)
+}
+
+func (s userAccessService) GetUser(ctx context.Context, uid string) (domain.UserAccess, error) {
+	user, err := s.adapter.SelectUser(ctx, uid)
+	if err != nil {
+		return user, err
+	}
+
+	return s.decrypt(user)
+}
+
+// GetUsers resolves a batch of ids with one adapter round trip, returning
+// one result per requested id in request order. A missing record or a
+// record that fails to decrypt only marks its own entry.
+func (s userAccessService) GetUsers(ctx context.Context, uids []string) ([]port.UserBatchResult, error) {
+	users, err := s.adapter.SelectUsers(ctx, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]domain.UserAccess, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	results := make([]port.UserBatchResult, 0, len(uids))
+	for _, uid := range uids {
+		user, ok := byID[uid]
+		if !ok {
+			results = append(results, port.UserBatchResult{Err: port.ErrUserNotFound})
+			continue
+		}
+
+		decrypted, err := s.decrypt(user)
+		if err != nil {
+			s.logger.Errorf("could not decrypt tokens of user %s: %s", uid, err.Error())
+			results = append(results, port.UserBatchResult{Err: err})
+			continue
+		}
+		results = append(results, port.UserBatchResult{User: decrypted})
+	}
+	return results, nil
+}
+
+func (s userAccessService) UpdateUser(ctx context.Context, user domain.UserAccess) error {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+
+	user, err := s.encrypt(user)
+	if err != nil {
+		return err
+	}
+
+	return s.adapter.UpsertUser(ctx, user)
+}
+
+func (s userAccessService) RemoveUser(ctx context.Context, uid string) error {
+	return s.adapter.DeleteUser(ctx, uid)
+}
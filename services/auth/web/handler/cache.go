@@ -0,0 +1,53 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"context"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+)
+
+// UserCacheHandler drops a user's cached credentials on demand — after an
+// admin rotates their access in Google Workspace or support fixes their
+// record directly in the backend, the next GetUser must read the store
+// rather than serve the cached tokens out their TTL.
+type UserCacheHandler struct {
+	invalidator port.UserCacheInvalidator
+	logger      log.Logger
+}
+
+// NewUserCacheHandler builds the cache rpc handler. A nil invalidator —
+// the auth service running without its read cache — makes Invalidate a
+// no-op, so callers need not know how the service is configured.
+func NewUserCacheHandler(invalidator port.UserCacheInvalidator, logger log.Logger) UserCacheHandler {
+	return UserCacheHandler{
+		invalidator: invalidator,
+		logger:      logger,
+	}
+}
+
+func (h UserCacheHandler) Invalidate(ctx context.Context, uid *string, res *interface{}) error {
+	if h.invalidator != nil {
+		h.invalidator.Invalidate(*uid)
+	}
+	h.logger.Debugf("invalidated cached credentials for user %s", *uid)
+	return nil
+}
@@ -0,0 +1,159 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/oauth2"
+)
+
+// Refresh outcome classes. Network failures and revoked grants need very
+// different operator responses, so they are counted apart.
+const (
+	refreshOutcomeSuccess      = "success"
+	refreshOutcomeNetwork      = "network"
+	refreshOutcomeInvalidGrant = "invalid_grant"
+	refreshOutcomeOther        = "other"
+)
+
+// RefreshMetrics aggregates token refresh telemetry: attempt and outcome
+// counters, refresh latency and a gauge of users whose tokens expire within
+// the next hour, tracked incrementally from the expiries this service
+// serves and persists.
+type RefreshMetrics struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	expiries map[string]int64
+
+	attempts prometheus.Counter
+	outcomes *prometheus.CounterVec
+	latency  prometheus.Histogram
+}
+
+func NewRefreshMetrics() *RefreshMetrics {
+	m := &RefreshMetrics{
+		now:      time.Now,
+		expiries: map[string]int64{},
+		attempts: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "onlyoffice_auth_token_refresh_attempts_total",
+			Help: "Google OAuth token refresh attempts.",
+		}),
+		outcomes: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "onlyoffice_auth_token_refresh_outcomes_total",
+			Help: "Google OAuth token refresh outcomes by error class.",
+		}, []string{"outcome"}),
+		latency: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "onlyoffice_auth_token_refresh_seconds",
+			Help:    "Google OAuth token refresh latency.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "onlyoffice_auth_tokens_expiring_soon",
+		Help: "Known users whose access tokens expire within the next hour.",
+	}, func() float64 {
+		return float64(m.ExpiringWithin(time.Hour))
+	})
+
+	return m
+}
+
+// ObserveRefresh counts one refresh attempt with its outcome and latency.
+func (m *RefreshMetrics) ObserveRefresh(err error, elapsed time.Duration) {
+	if m.attempts != nil {
+		m.attempts.Inc()
+	}
+	if m.outcomes != nil {
+		m.outcomes.WithLabelValues(classifyRefreshError(err)).Inc()
+	}
+	if m.latency != nil {
+		m.latency.Observe(elapsed.Seconds())
+	}
+}
+
+// TrackExpiry records the last known token expiry for a user.
+func (m *RefreshMetrics) TrackExpiry(uid string, expiry int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expiries[uid] = expiry
+	// Long-expired entries are users this replica has not served in a
+	// while; dropping them bounds the map.
+	edge := m.now().Add(-24 * time.Hour).Unix()
+	for id, exp := range m.expiries {
+		if exp < edge {
+			delete(m.expiries, id)
+		}
+	}
+}
+
+// ExpiringWithin counts tracked users whose tokens run out within d.
+func (m *RefreshMetrics) ExpiringWithin(d time.Duration) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now().Unix()
+	edge := m.now().Add(d).Unix()
+	count := 0
+	for _, expiry := range m.expiries {
+		if expiry >= now && expiry <= edge {
+			count++
+		}
+	}
+	return count
+}
+
+// classifyRefreshError buckets a refresh failure into an outcome class.
+func classifyRefreshError(err error) string {
+	if err == nil {
+		return refreshOutcomeSuccess
+	}
+
+	var rerr *oauth2.RetrieveError
+	if errors.As(err, &rerr) {
+		if strings.Contains(string(rerr.Body), "invalid_grant") {
+			return refreshOutcomeInvalidGrant
+		}
+		return refreshOutcomeOther
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return refreshOutcomeNetwork
+	}
+
+	return refreshOutcomeOther
+}
+
+// hashUID gives a stable non-reversible identifier for correlating log
+// lines about one user without writing the raw id.
+func hashUID(uid string) string {
+	sum := sha256.Sum256([]byte(uid))
+	return hex.EncodeToString(sum[:8])
+}
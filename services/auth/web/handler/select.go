@@ -0,0 +1,286 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/domain"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/sync/singleflight"
+)
+
+// refreshLockTTL bounds how long a crashed replica can hold the
+// distributed refresh lock. A Google refresh round trip takes well under
+// ten seconds.
+const refreshLockTTL = 10 * time.Second
+
+// UserSelectHandler resolves stored user credentials, refreshing the access
+// token through Google when it is about to expire.
+type UserSelectHandler struct {
+	service     port.UserAccessService
+	credentials *shared.CredentialsConfig
+	metrics     *RefreshMetrics
+	// locker serializes refreshes of the same user across replicas; nil
+	// when no lock backend is configured.
+	locker port.RefreshLocker
+	logger log.Logger
+	// group deduplicates concurrent refreshes of the same user within this
+	// process, so a burst of parallel requests costs one Google round trip.
+	group *singleflight.Group
+	// tokenSource builds the refresh source for a stored token; tests swap
+	// it for a scripted one.
+	tokenSource func(ctx context.Context, token *oauth2.Token) oauth2.TokenSource
+}
+
+func NewUserSelectHandler(
+	service port.UserAccessService, credentials *shared.CredentialsConfig,
+	metrics *RefreshMetrics, locker port.RefreshLocker, logger log.Logger,
+) UserSelectHandler {
+	config := &oauth2.Config{
+		ClientID:     credentials.Credentials.ClientID,
+		ClientSecret: credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}
+	return UserSelectHandler{
+		service:     service,
+		credentials: credentials,
+		metrics:     metrics,
+		locker:      locker,
+		logger:      logger,
+		group:       &singleflight.Group{},
+		tokenSource: config.TokenSource,
+	}
+}
+
+// GetUser returns the user's decrypted credentials, transparently
+// refreshing the google access token when less than a minute of validity
+// remains.
+func (h UserSelectHandler) GetUser(ctx context.Context, uid *string, res *response.UserResponse) error {
+	user, err := h.service.GetUser(ctx, *uid)
+	if err != nil {
+		h.logger.Errorf("could not get user %s: %s", *uid, err.Error())
+		return err
+	}
+
+	h.metrics.TrackExpiry(user.ID, user.Expiry)
+
+	if time.Until(time.Unix(user.Expiry, 0)) > time.Minute {
+		*res = response.UserResponse{
+			ID:           user.ID,
+			AccessToken:  user.AccessToken,
+			RefreshToken: user.RefreshToken,
+			TokenType:    user.TokenType,
+			Scope:        user.Scope,
+			Expiry:       user.Expiry,
+		}
+		return nil
+	}
+
+	result, err, _ := h.group.Do(user.ID, func() (interface{}, error) {
+		// Another flight may have refreshed and persisted while this call
+		// waited on the group; a quick re-read avoids a redundant refresh.
+		if current, err := h.service.GetUser(ctx, *uid); err == nil &&
+			time.Until(time.Unix(current.Expiry, 0)) > time.Minute {
+			return current, nil
+		}
+
+		// The group only deduplicates within this process; with several
+		// replicas the same user would still be refreshed concurrently and
+		// Google may invalidate the older refresh tokens. A distributed
+		// lock keeps the critical section to one replica; when the backend
+		// is unavailable the local group is the best we can do.
+		if h.locker != nil {
+			switch acquired, err := h.locker.TryLock(ctx, user.ID, refreshLockTTL); {
+			case err != nil:
+				h.logger.Warnf(
+					"refresh lock backend unavailable, deduplicating locally: uid_hash=%s error=%s",
+					hashUID(*uid), err.Error(),
+				)
+			case !acquired:
+				// Another replica is refreshing right now; once it persists,
+				// the stored credentials are fresh and this call serves them.
+				if current, ok := h.waitForPeerRefresh(ctx, *uid); ok {
+					return current, nil
+				}
+				// The peer never persisted within the lock TTL; refresh here
+				// rather than fail the caller.
+			default:
+				defer func() {
+					if err := h.locker.Unlock(context.Background(), user.ID); err != nil {
+						h.logger.Warnf(
+							"could not release refresh lock: uid_hash=%s error=%s",
+							hashUID(*uid), err.Error(),
+						)
+					}
+				}()
+			}
+		}
+
+		start := time.Now()
+		token, err := h.tokenSource(ctx, &oauth2.Token{
+			AccessToken:  user.AccessToken,
+			RefreshToken: user.RefreshToken,
+			TokenType:    user.TokenType,
+			Expiry:       time.Unix(user.Expiry, 0),
+		}).Token()
+		h.metrics.ObserveRefresh(err, time.Since(start))
+		if err != nil {
+			h.logger.Warnf(
+				"token refresh failed: outcome=%s uid_hash=%s error=%s",
+				classifyRefreshError(err), hashUID(*uid), err.Error(),
+			)
+			return nil, err
+		}
+
+		refreshed := domain.UserAccess{
+			ID:           user.ID,
+			AccessToken:  token.AccessToken,
+			RefreshToken: token.RefreshToken,
+			TokenType:    token.TokenType,
+			Scope:        user.Scope,
+			Expiry:       token.Expiry.Unix(),
+		}
+		if refreshed.RefreshToken == "" {
+			refreshed.RefreshToken = user.RefreshToken
+		}
+
+		h.logger.Debugf(
+			"refreshed access token %s for user %s",
+			shared.Redact(refreshed.AccessToken), *uid,
+		)
+
+		// The token in hand is valid no matter what the persistence layer
+		// does, so the write happens off the request path and the caller
+		// is never failed over it.
+		go h.persistRefreshed(refreshed)
+		return refreshed, nil
+	})
+	if err != nil {
+		return err
+	}
+	refreshed := result.(domain.UserAccess)
+
+	*res = response.UserResponse{
+		ID:           refreshed.ID,
+		AccessToken:  refreshed.AccessToken,
+		RefreshToken: refreshed.RefreshToken,
+		TokenType:    refreshed.TokenType,
+		Scope:        refreshed.Scope,
+		Expiry:       refreshed.Expiry,
+	}
+	return nil
+}
+
+// GetUsers resolves several users in one call, batching the cache lookups
+// and the backend read behind one round trip. Results keep the request
+// order with a per-entry status: a missing record or one that fails to
+// decrypt only marks its own entry, never the batch. Near-expiry tokens go
+// through the single-user path so the refresh keeps its singleflight and
+// cross-replica lock.
+func (h UserSelectHandler) GetUsers(ctx context.Context, uids *[]string, res *response.UsersResponse) error {
+	results, err := h.service.GetUsers(ctx, *uids)
+	if err != nil {
+		h.logger.Errorf("could not get users: %s", err.Error())
+		return err
+	}
+
+	res.Users = make([]response.UserBatchEntry, 0, len(results))
+	for i, result := range results {
+		uid := (*uids)[i]
+		entry := response.UserBatchEntry{ID: uid}
+
+		switch {
+		case errors.Is(result.Err, port.ErrUserNotFound):
+			entry.Status = response.UserBatchNotFound
+		case result.Err != nil:
+			entry.Status = response.UserBatchError
+		case time.Until(time.Unix(result.User.Expiry, 0)) > time.Minute:
+			h.metrics.TrackExpiry(result.User.ID, result.User.Expiry)
+			entry.Status = response.UserBatchOK
+			entry.User = &response.UserResponse{
+				ID:           result.User.ID,
+				AccessToken:  result.User.AccessToken,
+				RefreshToken: result.User.RefreshToken,
+				TokenType:    result.User.TokenType,
+				Scope:        result.User.Scope,
+				Expiry:       result.User.Expiry,
+			}
+		default:
+			var refreshed response.UserResponse
+			if err := h.GetUser(ctx, &uid, &refreshed); err != nil {
+				entry.Status = response.UserBatchError
+			} else {
+				entry.Status = response.UserBatchOK
+				entry.User = &refreshed
+			}
+		}
+		res.Users = append(res.Users, entry)
+	}
+	return nil
+}
+
+// waitForPeerRefresh polls the store while another replica holds the
+// refresh lock, returning the credentials once the peer has persisted a
+// fresh set. The deadline stays under the lock TTL so a crashed peer only
+// costs the caller a short wait before this replica refreshes itself.
+func (h UserSelectHandler) waitForPeerRefresh(ctx context.Context, uid string) (domain.UserAccess, bool) {
+	deadline := time.Now().Add(refreshLockTTL - 2*time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return domain.UserAccess{}, false
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if current, err := h.service.GetUser(ctx, uid); err == nil &&
+			time.Until(time.Unix(current.Expiry, 0)) > time.Minute {
+			return current, true
+		}
+	}
+	return domain.UserAccess{}, false
+}
+
+// persistRefreshed stores refreshed credentials with a bounded retry. The
+// caller already holds a valid token, so a persistence failure only costs
+// an extra refresh on a later request and is never surfaced to them.
+func (h UserSelectHandler) persistRefreshed(refreshed domain.UserAccess) {
+	for attempt := 1; attempt <= 3; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := h.service.UpdateUser(ctx, refreshed)
+		cancel()
+		if err == nil {
+			h.metrics.TrackExpiry(refreshed.ID, refreshed.Expiry)
+			return
+		}
+
+		h.logger.Warnf(
+			"could not persist refreshed tokens (attempt %d/3): uid_hash=%s error=%s",
+			attempt, hashUID(refreshed.ID), err.Error(),
+		)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
@@ -0,0 +1,478 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/domain"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+)
+
+type fakeUserService struct {
+	mu        sync.Mutex
+	user      domain.UserAccess
+	updateErr error
+	updated   *domain.UserAccess
+	// batch scripts per-id outcomes for GetUsers; ids outside the map fall
+	// back to the single stored user.
+	batch map[string]port.UserBatchResult
+}
+
+func (s *fakeUserService) CreateUser(ctx context.Context, user domain.UserAccess) error {
+	return nil
+}
+
+func (s *fakeUserService) GetUser(ctx context.Context, uid string) (domain.UserAccess, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.user, nil
+}
+
+func (s *fakeUserService) GetUsers(ctx context.Context, uids []string) ([]port.UserBatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]port.UserBatchResult, 0, len(uids))
+	for _, uid := range uids {
+		if result, ok := s.batch[uid]; ok {
+			results = append(results, result)
+			continue
+		}
+		results = append(results, port.UserBatchResult{User: s.user})
+	}
+	return results, nil
+}
+
+func (s *fakeUserService) UpdateUser(ctx context.Context, user domain.UserAccess) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.updateErr != nil {
+		return s.updateErr
+	}
+	s.user = user
+	s.updated = &user
+	return nil
+}
+
+func (s *fakeUserService) RemoveUser(ctx context.Context, uid string) error {
+	return nil
+}
+
+func (s *fakeUserService) lastUpdate() *domain.UserAccess {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updated
+}
+
+// testLogger discards everything; handler tests assert on behavior, not on
+// log output.
+type testLogger struct{}
+
+func (testLogger) Debugf(format string, args ...interface{}) {}
+func (testLogger) Infof(format string, args ...interface{})  {}
+func (testLogger) Warnf(format string, args ...interface{})  {}
+func (testLogger) Errorf(format string, args ...interface{}) {}
+func (testLogger) Fatalf(format string, args ...interface{}) {}
+
+// scriptedTokenSource answers every Token call with a fixed result.
+type scriptedTokenSource struct {
+	token *oauth2.Token
+	err   error
+}
+
+func (s scriptedTokenSource) Token() (*oauth2.Token, error) {
+	return s.token, s.err
+}
+
+func newTestRefreshMetrics() *RefreshMetrics {
+	return &RefreshMetrics{
+		now:      time.Now,
+		expiries: map[string]int64{},
+	}
+}
+
+func newTestSelectHandler(service *fakeUserService, source oauth2.TokenSource) UserSelectHandler {
+	return UserSelectHandler{
+		service:     service,
+		credentials: &shared.CredentialsConfig{},
+		metrics:     newTestRefreshMetrics(),
+		logger:      testLogger{},
+		group:       &singleflight.Group{},
+		tokenSource: func(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+			return source
+		},
+	}
+}
+
+func TestGetUserRefreshFailure(t *testing.T) {
+	service := &fakeUserService{user: domain.UserAccess{
+		ID:           "someone",
+		AccessToken:  "stale",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Unix(),
+	}}
+	handler := newTestSelectHandler(service, scriptedTokenSource{
+		err: errors.New("refresh rejected"),
+	})
+
+	uid := "someone"
+	var res response.UserResponse
+	if err := handler.GetUser(context.Background(), &uid, &res); err == nil {
+		t.Fatal("expected a failed refresh to surface an error")
+	}
+	if service.lastUpdate() != nil {
+		t.Fatal("a failed refresh must not persist anything")
+	}
+}
+
+func TestGetUserRefreshSuccess(t *testing.T) {
+	service := &fakeUserService{user: domain.UserAccess{
+		ID:           "someone",
+		AccessToken:  "stale",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Unix(),
+	}}
+	handler := newTestSelectHandler(service, scriptedTokenSource{
+		token: &oauth2.Token{
+			AccessToken: "fresh",
+			TokenType:   "Bearer",
+			Expiry:      time.Now().Add(time.Hour),
+		},
+	})
+
+	uid := "someone"
+	var res response.UserResponse
+	if err := handler.GetUser(context.Background(), &uid, &res); err != nil {
+		t.Fatalf("expected the refresh to succeed: %s", err.Error())
+	}
+	if res.AccessToken != "fresh" {
+		t.Errorf("expected the refreshed token, got %q", res.AccessToken)
+	}
+	// Google omits the refresh token on refresh responses; the stored one
+	// must survive.
+	if res.RefreshToken != "refresh" {
+		t.Errorf("expected the stored refresh token to survive, got %q", res.RefreshToken)
+	}
+
+	// Persistence happens off the request path.
+	deadline := time.Now().Add(2 * time.Second)
+	for service.lastUpdate() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if updated := service.lastUpdate(); updated == nil || updated.AccessToken != "fresh" {
+		t.Error("expected the refreshed credentials to be persisted")
+	}
+}
+
+// A persistence outage must not fail callers holding a freshly refreshed
+// token; they get the credentials and the write is retried in background.
+func TestGetUserPersistFailureStillReturnsToken(t *testing.T) {
+	service := &fakeUserService{
+		user: domain.UserAccess{
+			ID:           "someone",
+			AccessToken:  "stale",
+			RefreshToken: "refresh",
+			Expiry:       time.Now().Unix(),
+		},
+		updateErr: errors.New("persistence down"),
+	}
+	handler := newTestSelectHandler(service, scriptedTokenSource{
+		token: &oauth2.Token{
+			AccessToken: "fresh",
+			TokenType:   "Bearer",
+			Expiry:      time.Now().Add(time.Hour),
+		},
+	})
+
+	uid := "someone"
+	var res response.UserResponse
+	if err := handler.GetUser(context.Background(), &uid, &res); err != nil {
+		t.Fatalf("expected usable credentials despite the persist failure: %s", err.Error())
+	}
+	if res.AccessToken != "fresh" || res.RefreshToken != "refresh" {
+		t.Fatalf("expected the refreshed credentials, got %+v", res)
+	}
+}
+
+// countingTokenSource counts refreshes and simulates Google latency so
+// concurrent callers actually overlap.
+type countingTokenSource struct {
+	calls *int32
+	token *oauth2.Token
+}
+
+func (s countingTokenSource) Token() (*oauth2.Token, error) {
+	atomic.AddInt32(s.calls, 1)
+	time.Sleep(50 * time.Millisecond)
+	return s.token, nil
+}
+
+func TestGetUserConcurrentRefreshesAreDeduplicated(t *testing.T) {
+	service := &fakeUserService{user: domain.UserAccess{
+		ID:           "someone",
+		AccessToken:  "stale",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Unix(),
+	}}
+	var calls int32
+	handler := newTestSelectHandler(service, countingTokenSource{
+		calls: &calls,
+		token: &oauth2.Token{
+			AccessToken: "fresh",
+			TokenType:   "Bearer",
+			Expiry:      time.Now().Add(time.Hour),
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			uid := "someone"
+			var res response.UserResponse
+			if err := handler.GetUser(context.Background(), &uid, &res); err != nil {
+				t.Errorf("unexpected refresh error: %s", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected concurrent callers to share one refresh, got %d", got)
+	}
+}
+
+// fakeRefreshLocker mimics a shared redis lock: one holder per key,
+// optionally failing outright to exercise the local fallback.
+type fakeRefreshLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+	err  error
+}
+
+func (l *fakeRefreshLocker) TryLock(ctx context.Context, uid string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return false, l.err
+	}
+	if l.held == nil {
+		l.held = map[string]bool{}
+	}
+	if l.held[uid] {
+		return false, nil
+	}
+	l.held[uid] = true
+	return true, nil
+}
+
+func (l *fakeRefreshLocker) Unlock(ctx context.Context, uid string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.held, uid)
+	return nil
+}
+
+// Two handler instances stand in for two auth replicas: sharing the lock
+// and the store, they must cost the user a single Google refresh.
+func TestGetUserDistributedLockDeduplicatesAcrossReplicas(t *testing.T) {
+	service := &fakeUserService{user: domain.UserAccess{
+		ID:           "someone",
+		AccessToken:  "stale",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Unix(),
+	}}
+	locker := &fakeRefreshLocker{}
+	var calls int32
+	source := countingTokenSource{
+		calls: &calls,
+		token: &oauth2.Token{
+			AccessToken: "fresh",
+			TokenType:   "Bearer",
+			Expiry:      time.Now().Add(time.Hour),
+		},
+	}
+
+	replicas := make([]UserSelectHandler, 2)
+	for i := range replicas {
+		replicas[i] = newTestSelectHandler(service, source)
+		replicas[i].locker = locker
+	}
+
+	var wg sync.WaitGroup
+	for i := range replicas {
+		wg.Add(1)
+		go func(replica UserSelectHandler) {
+			defer wg.Done()
+			uid := "someone"
+			var res response.UserResponse
+			if err := replica.GetUser(context.Background(), &uid, &res); err != nil {
+				t.Errorf("unexpected refresh error: %s", err.Error())
+			} else if res.AccessToken != "fresh" {
+				t.Errorf("expected fresh credentials on both replicas, got %q", res.AccessToken)
+			}
+		}(replicas[i])
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected the replicas to share one refresh, got %d", got)
+	}
+}
+
+// A dead lock backend must degrade to per-process deduplication, not to a
+// failed request.
+func TestGetUserLockBackendFailureFallsBackLocally(t *testing.T) {
+	service := &fakeUserService{user: domain.UserAccess{
+		ID:           "someone",
+		AccessToken:  "stale",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Unix(),
+	}}
+	handler := newTestSelectHandler(service, scriptedTokenSource{
+		token: &oauth2.Token{
+			AccessToken: "fresh",
+			TokenType:   "Bearer",
+			Expiry:      time.Now().Add(time.Hour),
+		},
+	})
+	handler.locker = &fakeRefreshLocker{err: errors.New("connection refused")}
+
+	uid := "someone"
+	var res response.UserResponse
+	if err := handler.GetUser(context.Background(), &uid, &res); err != nil {
+		t.Fatalf("expected the refresh to succeed without the lock backend: %s", err.Error())
+	}
+	if res.AccessToken != "fresh" {
+		t.Errorf("expected the refreshed token, got %q", res.AccessToken)
+	}
+}
+
+// A mixed batch answers every id in request order with its own status: a
+// missing record and one whose tokens do not decrypt mark their entries
+// without failing the rest, and a near-expiry record comes back refreshed.
+func TestGetUsersMixedBatch(t *testing.T) {
+	service := &fakeUserService{
+		user: domain.UserAccess{
+			ID:           "stale",
+			AccessToken:  "stale-token",
+			RefreshToken: "refresh",
+			Expiry:       time.Now().Unix(),
+		},
+		batch: map[string]port.UserBatchResult{
+			"fresh": {User: domain.UserAccess{
+				ID:          "fresh",
+				AccessToken: "fresh-token",
+				Expiry:      time.Now().Add(time.Hour).Unix(),
+			}},
+			"missing": {Err: port.ErrUserNotFound},
+			"broken":  {Err: errors.New("cipher: message authentication failed")},
+		},
+	}
+	handler := newTestSelectHandler(service, scriptedTokenSource{
+		token: &oauth2.Token{
+			AccessToken: "refreshed-token",
+			TokenType:   "Bearer",
+			Expiry:      time.Now().Add(time.Hour),
+		},
+	})
+
+	uids := []string{"fresh", "missing", "broken", "stale"}
+	var res response.UsersResponse
+	if err := handler.GetUsers(context.Background(), &uids, &res); err != nil {
+		t.Fatalf("expected the batch to succeed: %s", err.Error())
+	}
+
+	if len(res.Users) != len(uids) {
+		t.Fatalf("expected one entry per requested id, got %d", len(res.Users))
+	}
+	for i, uid := range uids {
+		if res.Users[i].ID != uid {
+			t.Fatalf("expected entry %d to answer %q, got %q", i, uid, res.Users[i].ID)
+		}
+	}
+
+	if res.Users[0].Status != response.UserBatchOK || res.Users[0].User.AccessToken != "fresh-token" {
+		t.Errorf("expected the fresh user's tokens, got %+v", res.Users[0])
+	}
+	if res.Users[1].Status != response.UserBatchNotFound || res.Users[1].User != nil {
+		t.Errorf("expected a bare not_found entry, got %+v", res.Users[1])
+	}
+	if res.Users[2].Status != response.UserBatchError || res.Users[2].User != nil {
+		t.Errorf("expected the broken record to mark only its entry, got %+v", res.Users[2])
+	}
+	if res.Users[3].Status != response.UserBatchOK || res.Users[3].User.AccessToken != "refreshed-token" {
+		t.Errorf("expected the stale user to come back refreshed, got %+v", res.Users[3])
+	}
+}
+
+func TestClassifyRefreshError(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"success", nil, refreshOutcomeSuccess},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("refused")}, refreshOutcomeNetwork},
+		{
+			"invalid grant",
+			&oauth2.RetrieveError{Body: []byte(`{"error":"invalid_grant"}`)},
+			refreshOutcomeInvalidGrant,
+		},
+		{
+			"other oauth error",
+			&oauth2.RetrieveError{Body: []byte(`{"error":"server_error"}`)},
+			refreshOutcomeOther,
+		},
+		{"other", errors.New("boom"), refreshOutcomeOther},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifyRefreshError(tc.err); got != tc.want {
+				t.Errorf("classifyRefreshError(%v) = %q, want %q", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpiringWithin(t *testing.T) {
+	now := time.Unix(10000, 0)
+	metrics := &RefreshMetrics{
+		now: func() time.Time { return now },
+		expiries: map[string]int64{
+			"soon":    now.Add(30 * time.Minute).Unix(),
+			"later":   now.Add(3 * time.Hour).Unix(),
+			"expired": now.Add(-time.Minute).Unix(),
+		},
+	}
+
+	if got := metrics.ExpiringWithin(time.Hour); got != 1 {
+		t.Fatalf("expected 1 token expiring within the hour, got %d", got)
+	}
+}
@@ -0,0 +1,91 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/builder/web/handler"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"go-micro.dev/v4"
+)
+
+func main() {
+	configPath := flag.String("config_path", "./config/config.yml", "server configuration path")
+	flag.Parse()
+
+	serverConfig, err := config.BuildNewServerConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	credentialsConfig, err := shared.BuildNewCredentialsConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	logger := log.NewDefaultLogger(serverConfig)
+
+	onlyofficeConfig, err := shared.NewOnlyofficeConfigSource(*configPath, logger.Warnf)
+	if err != nil {
+		logger.Fatalf("could not load the onlyoffice configuration: %s", err.Error())
+	}
+	if err := onlyofficeConfig.Watch(context.Background()); err != nil {
+		logger.Warnf("could not start the configuration watcher: %s", err.Error())
+	}
+
+	// A builder configured with a gateway URL the gateway itself does not
+	// answer on produces download URLs the Document Server cannot reach.
+	// Surface the mismatch at startup instead.
+	pctx, pcancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := shared.CheckGatewayURL(pctx, onlyofficeConfig.Config().Onlyoffice.Builder.GatewayURL); err != nil {
+		if onlyofficeConfig.Config().Onlyoffice.Builder.StrictGatewayCheck {
+			logger.Fatalf("gateway url check failed: %s", err.Error())
+		}
+		logger.Errorf("gateway url check failed: %s", err.Error())
+	}
+	pcancel()
+
+	service := micro.NewService(
+		micro.Name(fmt.Sprintf("%s:builder", serverConfig.Namespace)),
+		micro.Address(serverConfig.Address),
+	)
+	service.Init()
+
+	configHandler := handler.NewConfigHandler(
+		service.Client(), crypto.NewOnlyofficeJwtManager(),
+		files.NewOnlyofficeFileUtility(), serverConfig,
+		credentialsConfig, onlyofficeConfig, logger,
+	)
+
+	if err := micro.RegisterHandler(service.Server(), &configHandler); err != nil {
+		logger.Fatalf("could not register a config handler: %s", err.Error())
+	}
+
+	if err := service.Run(); err != nil {
+		logger.Fatalf("builder stopped: %s", err.Error())
+	}
+}
@@ -0,0 +1,315 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"github.com/golang-jwt/jwt/v4"
+	"go-micro.dev/v4/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	goauth "google.golang.org/api/oauth2/v2"
+	"google.golang.org/api/option"
+)
+
+// ConfigHandler builds Document Server editor configs for gateway editor
+// sessions.
+type ConfigHandler struct {
+	client      client.Client
+	jwtManager  crypto.JwtManager
+	fileUtility files.OnlyofficeFileUtility
+	server      *config.ServerConfig
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	logger      log.Logger
+	// clock stamps the watermark date baked into signed configs; injected
+	// so tests can pin it.
+	clock shared.Clock
+}
+
+func NewConfigHandler(
+	client client.Client, jwtManager crypto.JwtManager,
+	fileUtility files.OnlyofficeFileUtility,
+	server *config.ServerConfig, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource, logger log.Logger,
+) ConfigHandler {
+	return ConfigHandler{
+		client:      client,
+		jwtManager:  jwtManager,
+		fileUtility: fileUtility,
+		server:      server,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		logger:      logger,
+		clock:       shared.SystemClock{},
+	}
+}
+
+// BuildConfig resolves the user's credentials and the file's Drive metadata
+// into a signed Document Server editor config.
+func (h ConfigHandler) BuildConfig(ctx context.Context, payload request.ConfigRequest, res *response.BuildConfigResponse) error {
+	h.logger.Debugf("building an editor config for file %s", payload.FileID)
+
+	if !shared.ValidCoEditingMode(payload.CoEditing) {
+		return fmt.Errorf("unknown co-editing mode %q", payload.CoEditing)
+	}
+
+	var ures response.UserResponse
+	if err := h.client.Call(ctx, h.client.NewRequest(
+		fmt.Sprintf("%s:auth", h.server.Namespace), "UserSelectHandler.GetUser",
+		payload.UID,
+	), &ures); err != nil {
+		return err
+	}
+
+	gclient := (&oauth2.Config{
+		ClientID:     h.credentials.Credentials.ClientID,
+		ClientSecret: h.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(ctx, &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(gclient))
+	if err != nil {
+		return err
+	}
+
+	usvc, err := goauth.NewService(ctx, option.WithHTTPClient(gclient))
+	if err != nil {
+		return err
+	}
+
+	file, err := srv.Files.Get(payload.FileID).Do()
+	if err != nil {
+		return err
+	}
+
+	uinfo, err := usvc.Userinfo.Get().Do()
+	if err != nil {
+		return err
+	}
+
+	// A pinned revision turns the whole config view-only; a pruned or
+	// invalid id fails here, before any token is signed.
+	var revision *drive.Revision
+	if payload.RevisionID != "" {
+		revision, err = srv.Revisions.Get(file.Id, payload.RevisionID).Do()
+		if err != nil {
+			return fmt.Errorf("could not get revision %s of %s: %w", payload.RevisionID, file.Id, err)
+		}
+	}
+
+	user, err := editorUser(uinfo)
+	if err != nil {
+		return err
+	}
+
+	ext, mismatch := shared.EffectiveExtension(file.FileExtension, file.MimeType)
+	if mismatch {
+		h.logger.Warnf(
+			"file %s reports extension .%s but is Google-native (%s); treating it as .%s",
+			file.Id, file.FileExtension, file.MimeType, ext,
+		)
+	}
+
+	fileType, err := h.fileUtility.GetFileType(ext)
+	if err != nil {
+		return err
+	}
+
+	// The Document Server has no rfc822 format; when email preview is
+	// enabled the gateway's download proxy serves the extracted body HTML,
+	// so the config declares html. With the preview off the file stays
+	// closed rather than handed to the editor raw.
+	declaredExt := ext
+	if h.fileUtility.IsExtensionEmail(ext) {
+		if !h.onlyoffice.Config().Onlyoffice.Builder.EmailPreview {
+			return fmt.Errorf("email preview is disabled for .%s files", ext)
+		}
+		declaredExt = "html"
+	}
+
+	downloadToken, err := h.jwtManager.Sign(h.credentials.Credentials.ClientSecret, shared.DownloadToken{
+		UID:        payload.UID,
+		FileID:     file.Id,
+		RevisionID: payload.RevisionID,
+	})
+	if err != nil {
+		return err
+	}
+
+	editable := h.fileUtility.IsExtensionEditable(ext) ||
+		(payload.ForceEdit && h.fileUtility.IsExtensionLossEditable(ext))
+	// With template editing enabled the OOXML template formats open as
+	// editable sessions. The registry maps them onto themselves, which is
+	// what tells them apart from the legacy templates (dot, xlt, pot, ...)
+	// that must convert first.
+	if !editable && h.onlyoffice.Config().Onlyoffice.Builder.TemplateEdit {
+		if target, ok := h.fileUtility.TemplateOutputExtension(ext); ok && strings.EqualFold(target, ext) {
+			editable = true
+		}
+	}
+	// The force-edit bit matters to the key exactly when it is what makes
+	// the session editable; on natively editable formats it changes
+	// nothing and must not split the co-editing session.
+	lossyEdit := payload.ForceEdit && !h.fileUtility.IsExtensionEditable(ext) &&
+		h.fileUtility.IsExtensionLossEditable(ext)
+
+	// Embedded configs are iframed by third parties and stay view-only no
+	// matter what the user could do in Drive.
+	permissions := mapPermissions(
+		file, editable, h.onlyoffice.Config().Onlyoffice.Builder.Permissions,
+	)
+	if payload.Embedded {
+		permissions = response.Permissions{}
+	}
+	// Older revisions are strictly read-only: even commenting would write
+	// to the live file, not the pinned content.
+	if revision != nil {
+		permissions = response.Permissions{}
+	}
+
+	// Accounts report locales the Document Server's lang parameter cannot
+	// digest — region-only strings, deprecated codes, plain junk — and a
+	// rejected lang costs the whole UI its translation, not just the odd
+	// region. Normalize to a value the editor accepts, English as the last
+	// resort.
+	lang := shared.DSLanguage(uinfo.Locale)
+
+	// Embedded viewers are iframed by third parties and must not link into
+	// the user's Drive.
+	var goback response.Goback
+	if !payload.Embedded {
+		goback = gobackCustomization(
+			file, lang, h.onlyoffice.Config().Onlyoffice.Builder,
+		)
+	}
+
+	// Routed instances keep classified documents on their own Document
+	// Server; the decision rides on the file's private Drive properties,
+	// which the metadata fetch above already carries.
+	properties := map[string]string{}
+	for _, property := range file.Properties {
+		properties[property.Key] = property.Value
+	}
+	dsURL, dsSecret := h.onlyoffice.Config().Onlyoffice.Builder.RouteDocumentServer(properties)
+	if dsURL != h.onlyoffice.Config().Onlyoffice.Builder.DocumentServerURL {
+		h.logger.Debugf("file %s is routed to document server %s", file.Id, dsURL)
+	}
+
+	title := file.Title
+	keyMaterial := documentKeyMaterial(file, lossyEdit)
+	callbackURL := fmt.Sprintf(
+		"%s/callback?id=%s",
+		h.onlyoffice.Config().Onlyoffice.Builder.CallbackURL, file.Id,
+	)
+	if revision != nil {
+		title = revisionTitle(file.Title, revision.ModifiedDate)
+		keyMaterial = file.Id + ":" + revision.Id
+		// Without a callback URL the Document Server never tries to save
+		// the pinned content over the live file.
+		callbackURL = ""
+	}
+
+	keySource := md5.Sum([]byte(keyMaterial))
+	config := response.BuildConfigResponse{
+		Document: response.Document{
+			FileType: declaredExt,
+			Key:      hex.EncodeToString(keySource[:]),
+			Title:    title,
+			URL: fmt.Sprintf(
+				"%s/api/download?token=%s",
+				h.onlyoffice.Config().Onlyoffice.Builder.GatewayURL, downloadToken,
+			),
+			Permissions: permissions,
+		},
+		DocumentType: fileType,
+		EditorConfig: response.EditorConfig{
+			User:        user,
+			CallbackURL: callbackURL,
+			CoEditing: coEditing(
+				payload.CoEditing, h.onlyoffice.Config().Onlyoffice.Builder.CoEditing,
+			),
+			Customization: response.Customization{Goback: goback},
+			Lang:          lang,
+		},
+		ServerURL: dsURL,
+	}
+
+	config.Document.Options = documentOptions(
+		h.onlyoffice.Config().Onlyoffice.Builder.Watermark, permissions,
+		user.Name, uinfo.Email, h.clock.Now(),
+	)
+
+	if isMobileUserAgent(payload.UserAgent) {
+		config.Type = "mobile"
+	}
+	switch payload.ForceType {
+	case "mobile":
+		config.Type = "mobile"
+	case "desktop":
+		config.Type = ""
+	}
+	if payload.Embedded {
+		config.Type = "embedded"
+		config.EditorConfig.Mode = "view"
+	}
+	if revision != nil {
+		config.EditorConfig.Mode = "view"
+	}
+
+	// Some families the desktop editor edits are view-only in the mobile
+	// one; an edit-mode config there renders a broken toolbar, so downgrade
+	// to view before the config is signed.
+	applyMobileCapabilities(&config, ext, h.onlyoffice.Config().Onlyoffice.Builder)
+
+	if secret := dsSecret; secret != "" {
+		var claims jwt.MapClaims
+		if err := json.Unmarshal(config.ToJSON(), &claims); err != nil {
+			return err
+		}
+
+		token, err := h.jwtManager.Sign(secret, claims)
+		if err != nil {
+			return err
+		}
+
+		config.Token = token
+	}
+
+	*res = config
+	return nil
+}
@@ -0,0 +1,39 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+)
+
+// coEditing resolves the editorConfig.coEditing block: a per-request mode
+// wins over the deployment default, and no preference at all leaves the
+// block out so the Document Server applies its own default (fast).
+func coEditing(requested string, cfg shared.OnlyofficeCoEditingConfig) *response.CoEditing {
+	mode := requested
+	if mode == "" {
+		mode = cfg.Mode
+	}
+	if mode == "" {
+		return nil
+	}
+
+	return &response.CoEditing{Mode: mode, Change: cfg.Change}
+}
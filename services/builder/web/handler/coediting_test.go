@@ -0,0 +1,82 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+)
+
+func TestCoEditingConfigJSON(t *testing.T) {
+	tests := map[string]struct {
+		requested string
+		cfg       shared.OnlyofficeCoEditingConfig
+		expected  string
+	}{
+		"strict mode from the deployment config": {
+			cfg:      shared.OnlyofficeCoEditingConfig{Mode: shared.CoEditingStrict},
+			expected: `"coEditing":{"mode":"strict","change":false}`,
+		},
+		"fast mode with user switching allowed": {
+			cfg:      shared.OnlyofficeCoEditingConfig{Mode: shared.CoEditingFast, Change: true},
+			expected: `"coEditing":{"mode":"fast","change":true}`,
+		},
+		"request override wins over the config": {
+			requested: shared.CoEditingStrict,
+			cfg:       shared.OnlyofficeCoEditingConfig{Mode: shared.CoEditingFast},
+			expected:  `"coEditing":{"mode":"strict","change":false}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := response.EditorConfig{
+				CoEditing: coEditing(test.requested, test.cfg),
+			}
+
+			buf, err := json.Marshal(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(buf), test.expected) {
+				t.Errorf("expected %s in %s", test.expected, buf)
+			}
+		})
+	}
+}
+
+// No preference at all must leave the block out entirely, so the Document
+// Server applies its own default instead of an explicit setting.
+func TestCoEditingUnsetIsOmitted(t *testing.T) {
+	config := response.EditorConfig{
+		CoEditing: coEditing("", shared.OnlyofficeCoEditingConfig{}),
+	}
+
+	buf, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(buf), "coEditing") {
+		t.Errorf("expected no coEditing block, got %s", buf)
+	}
+}
@@ -0,0 +1,95 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	drive "google.golang.org/api/drive/v2"
+)
+
+// gobackTexts localizes the editor's "open file location" button per
+// editor language; unknown languages fall back to English.
+var gobackTexts = map[string]string{
+	"en": "Open file location",
+	"de": "Dateispeicherort öffnen",
+	"es": "Abrir ubicación del archivo",
+	"fr": "Ouvrir l'emplacement du fichier",
+	"it": "Apri il percorso del file",
+	"ja": "ファイルの場所を開く",
+	"pt": "Abrir local do arquivo",
+	"ru": "Открыть расположение файла",
+	"zh": "打开文件位置",
+}
+
+// gobackFolderURL resolves the Drive URL behind the editor's back button.
+// Files in a folder (shared-drive folders included — their folder ids use
+// the same URL form) link to that folder; files without an accessible
+// parent land in "shared with me" when they came from someone else and in
+// "my drive" otherwise.
+func gobackFolderURL(file *drive.File) string {
+	for _, parent := range file.Parents {
+		if parent.IsRoot {
+			return "https://drive.google.com/drive/my-drive"
+		}
+	}
+
+	if len(file.Parents) > 0 {
+		return fmt.Sprintf("https://drive.google.com/drive/folders/%s", file.Parents[0].Id)
+	}
+
+	if file.Shared {
+		return "https://drive.google.com/drive/shared-with-me"
+	}
+	return "https://drive.google.com/drive/my-drive"
+}
+
+// gobackCustomization builds the goback block for a file, honoring the
+// deployment configuration: disabled yields an empty block, a custom URL
+// template replaces the {folder_id} placeholder with the parent folder id
+// (empty for parentless files).
+func gobackCustomization(
+	file *drive.File, lang string, config shared.OnlyofficeBuilderConfig,
+) response.Goback {
+	if config.DisableGoback {
+		return response.Goback{}
+	}
+
+	url := gobackFolderURL(file)
+	if config.GobackURL != "" {
+		folderID := ""
+		if len(file.Parents) > 0 && !file.Parents[0].IsRoot {
+			folderID = file.Parents[0].Id
+		}
+		url = strings.ReplaceAll(config.GobackURL, "{folder_id}", folderID)
+	}
+
+	text, ok := gobackTexts[lang]
+	if !ok {
+		text = gobackTexts["en"]
+	}
+
+	return response.Goback{
+		Text: text,
+		URL:  url,
+	}
+}
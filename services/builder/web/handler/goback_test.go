@@ -0,0 +1,101 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestGobackFolderURL(t *testing.T) {
+	for name, tc := range map[string]struct {
+		file     *drive.File
+		expected string
+	}{
+		"file in a folder": {
+			file: &drive.File{Parents: []*drive.ParentReference{
+				{Id: "folder-id-0123456789"},
+			}},
+			expected: "https://drive.google.com/drive/folders/folder-id-0123456789",
+		},
+		"file in the drive root": {
+			file: &drive.File{Parents: []*drive.ParentReference{
+				{Id: "root-id", IsRoot: true},
+			}},
+			expected: "https://drive.google.com/drive/my-drive",
+		},
+		"shared file without an accessible parent": {
+			file:     &drive.File{Shared: true},
+			expected: "https://drive.google.com/drive/shared-with-me",
+		},
+		"own file without a parent": {
+			file:     &drive.File{},
+			expected: "https://drive.google.com/drive/my-drive",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := gobackFolderURL(tc.file); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestGobackCustomization(t *testing.T) {
+	file := &drive.File{Parents: []*drive.ParentReference{
+		{Id: "folder-id-0123456789"},
+	}}
+
+	t.Run("default configuration", func(t *testing.T) {
+		goback := gobackCustomization(file, "de", shared.OnlyofficeBuilderConfig{})
+		if goback.URL != "https://drive.google.com/drive/folders/folder-id-0123456789" {
+			t.Errorf("unexpected goback url: %q", goback.URL)
+		}
+		if goback.Text != gobackTexts["de"] {
+			t.Errorf("expected a localized text, got %q", goback.Text)
+		}
+	})
+
+	t.Run("unknown language falls back to english", func(t *testing.T) {
+		goback := gobackCustomization(file, "tlh", shared.OnlyofficeBuilderConfig{})
+		if goback.Text != gobackTexts["en"] {
+			t.Errorf("expected the english text, got %q", goback.Text)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		goback := gobackCustomization(file, "en", shared.OnlyofficeBuilderConfig{
+			DisableGoback: true,
+		})
+		if goback.URL != "" || goback.Text != "" {
+			t.Errorf("expected an empty goback block, got %+v", goback)
+		}
+	})
+
+	t.Run("custom url template", func(t *testing.T) {
+		goback := gobackCustomization(file, "en", shared.OnlyofficeBuilderConfig{
+			GobackURL: "https://intranet.example.com/files/{folder_id}",
+		})
+		if goback.URL != "https://intranet.example.com/files/folder-id-0123456789" {
+			t.Errorf("unexpected goback url: %q", goback.URL)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	drive "google.golang.org/api/drive/v2"
+)
+
+// documentKeyMaterial derives the editor key source for a live file. The
+// Document Server treats everyone under one key as co-editors of one
+// document, so a lossy force-edit session gets its own key: a view session
+// and a force-edit session of the same file must never share editing
+// state. The callback worker's conflict detection recomputes both
+// variants, so the salt has to stay in sync with it.
+func documentKeyMaterial(file *drive.File, lossyEdit bool) string {
+	material := file.Id + file.ModifiedDate
+	if lossyEdit {
+		material += ":force-edit"
+	}
+	return material
+}
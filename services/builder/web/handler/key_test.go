@@ -0,0 +1,43 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"testing"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+// Opening the same file first view-only and then force-edit must produce
+// distinct keys; sharing one would join the viewer into the lossy editing
+// session's state.
+func TestDocumentKeyMaterialSplitsForceEditSessions(t *testing.T) {
+	file := &drive.File{Id: "file-id-0123456789", ModifiedDate: "2023-10-01T10:00:00.000Z"}
+
+	view := documentKeyMaterial(file, false)
+	forced := documentKeyMaterial(file, true)
+	if view == forced {
+		t.Error("expected a force-edit session to derive its own key material")
+	}
+
+	// The split is deterministic: reopening a force-edit session rejoins it.
+	if again := documentKeyMaterial(file, true); again != forced {
+		t.Errorf("expected a stable force-edit key, got %q and %q", forced, again)
+	}
+}
@@ -0,0 +1,66 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+)
+
+// formExtensions are the form formats whose editing UI differs from plain
+// word documents; for mobile capabilities they are a family of their own
+// even though their documentType is word.
+var formExtensions = map[string]bool{
+	"docxf": true,
+	"oform": true,
+}
+
+// mobileFamily resolves the format family the mobile capability table is
+// consulted with: forms are split out of word, everything else keeps its
+// documentType.
+func mobileFamily(ext, fileType string) string {
+	if formExtensions[ext] {
+		return "form"
+	}
+	return fileType
+}
+
+// applyMobileCapabilities downgrades a mobile editor config to view when the
+// mobile editor cannot edit the file's format family. Desktop and embedded
+// configs pass through untouched, as does anything already view-only. The
+// downgrade is flagged on the config so the editor page can tell the user
+// why the toolbar they saw on desktop is gone.
+func applyMobileCapabilities(
+	config *response.BuildConfigResponse, ext string, builder shared.OnlyofficeBuilderConfig,
+) {
+	if config.Type != "mobile" {
+		return
+	}
+	if !config.Document.Permissions.Edit && !config.Document.Permissions.Review {
+		return
+	}
+	if builder.MobileEditableFamily(mobileFamily(ext, config.DocumentType)) {
+		return
+	}
+
+	config.Document.Permissions.Edit = false
+	config.Document.Permissions.Review = false
+	config.EditorConfig.Mode = "view"
+	config.MobileViewOnly = true
+}
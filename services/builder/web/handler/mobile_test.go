@@ -0,0 +1,126 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+)
+
+func TestMobileCapabilityMatrix(t *testing.T) {
+	tests := []struct {
+		name       string
+		ext        string
+		fileType   string
+		editorType string
+		wantEdit   bool
+		wantNotice bool
+	}{
+		{
+			name: "desktop word stays editable",
+			ext:  "docx", fileType: "word", editorType: "",
+			wantEdit: true,
+		},
+		{
+			name: "mobile word stays editable",
+			ext:  "docx", fileType: "word", editorType: "mobile",
+			wantEdit: true,
+		},
+		{
+			name: "mobile cell stays editable",
+			ext:  "xlsx", fileType: "cell", editorType: "mobile",
+			wantEdit: true,
+		},
+		{
+			name: "mobile slide stays editable",
+			ext:  "pptx", fileType: "slide", editorType: "mobile",
+			wantEdit: true,
+		},
+		{
+			name: "desktop form stays editable",
+			ext:  "docxf", fileType: "word", editorType: "",
+			wantEdit: true,
+		},
+		{
+			name: "mobile form downgrades to view",
+			ext:  "docxf", fileType: "word", editorType: "mobile",
+			wantEdit: false, wantNotice: true,
+		},
+		{
+			name: "embedded form is not the mobile editor",
+			ext:  "docxf", fileType: "word", editorType: "embedded",
+			wantEdit: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := response.BuildConfigResponse{
+				DocumentType: test.fileType,
+				Type:         test.editorType,
+			}
+			config.Document.Permissions.Edit = true
+			config.Document.Permissions.Review = true
+
+			applyMobileCapabilities(&config, test.ext, shared.OnlyofficeBuilderConfig{})
+
+			if config.Document.Permissions.Edit != test.wantEdit {
+				t.Errorf("expected edit=%v, got %v", test.wantEdit, config.Document.Permissions.Edit)
+			}
+			if config.Document.Permissions.Review != test.wantEdit {
+				t.Errorf("expected review=%v, got %v", test.wantEdit, config.Document.Permissions.Review)
+			}
+			if config.MobileViewOnly != test.wantNotice {
+				t.Errorf("expected notice=%v, got %v", test.wantNotice, config.MobileViewOnly)
+			}
+			if test.wantNotice && config.EditorConfig.Mode != "view" {
+				t.Errorf("expected a downgraded config to open in view mode, got %q", config.EditorConfig.Mode)
+			}
+		})
+	}
+}
+
+func TestMobileCapabilityOverrides(t *testing.T) {
+	builder := shared.OnlyofficeBuilderConfig{
+		MobileEditable: map[string]bool{"form": true, "word": false},
+	}
+
+	form := response.BuildConfigResponse{DocumentType: "word", Type: "mobile"}
+	form.Document.Permissions.Edit = true
+	applyMobileCapabilities(&form, "docxf", builder)
+	if !form.Document.Permissions.Edit || form.MobileViewOnly {
+		t.Error("expected the override to keep forms editable on mobile")
+	}
+
+	word := response.BuildConfigResponse{DocumentType: "word", Type: "mobile"}
+	word.Document.Permissions.Edit = true
+	applyMobileCapabilities(&word, "docx", builder)
+	if word.Document.Permissions.Edit || !word.MobileViewOnly {
+		t.Error("expected the override to downgrade word documents on mobile")
+	}
+
+	// A view-only config has nothing to downgrade and must not claim it did.
+	viewer := response.BuildConfigResponse{DocumentType: "word", Type: "mobile"}
+	applyMobileCapabilities(&viewer, "docx", builder)
+	if viewer.MobileViewOnly {
+		t.Error("expected an already view-only config to carry no notice")
+	}
+}
@@ -0,0 +1,82 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	drive "google.golang.org/api/drive/v2"
+)
+
+// mapPermissions translates the file's Drive capabilities into editor
+// permissions and overlays the deployment's permission policy. The editable
+// flag tells whether the format itself allows editing (and, for
+// loss-editable formats, whether the user forced it).
+//
+// Commenters get a view-mode editor with commenting enabled, viewers get
+// neither; Drive capabilities always win over format capabilities. When the
+// owner set copyRequiresWriterPermission, readers additionally lose copy,
+// download and print regardless of their other capabilities.
+//
+// Policy precedence per permission: a configured deny always wins; a
+// configured allow wins over the derived default but is still clamped to
+// what Drive permits (copy/download/print stay off for download-restricted
+// readers, edit stays off for non-writers and view-only formats); anything
+// else keeps the derived value.
+func mapPermissions(file *drive.File, editable bool, policy shared.OnlyofficePermissionsConfig) response.Permissions {
+	// Files without a capabilities block predate the field; Drive only
+	// returns them for the owner.
+	capabilities := file.Capabilities
+	canEdit, canComment := true, true
+	canCopy, canDownload := true, true
+	if capabilities != nil {
+		canEdit = capabilities.CanEdit
+		canComment = capabilities.CanComment
+		canCopy = capabilities.CanCopy
+		canDownload = capabilities.CanDownload
+	}
+
+	if file.CopyRequiresWriterPermission && !canEdit {
+		canCopy, canDownload = false, false
+	}
+
+	edit := editable && canEdit
+	return response.Permissions{
+		Comment:  mergePermission(canComment, true, policy.Comment),
+		Copy:     mergePermission(canCopy, canCopy, policy.Copy),
+		Download: mergePermission(canDownload, canDownload, policy.Download),
+		Edit:     mergePermission(edit, edit, policy.Edit),
+		Print:    mergePermission(canDownload, canDownload, policy.Print),
+		Review:   mergePermission(edit, true, policy.Review),
+	}
+}
+
+// mergePermission folds one configured policy into a derived permission.
+// The ceiling is the most Drive allows; an explicit allow can reach it but
+// never exceed it.
+func mergePermission(derived, ceiling bool, policy string) bool {
+	switch policy {
+	case shared.PermissionDeny:
+		return false
+	case shared.PermissionAllow:
+		return ceiling
+	default:
+		return derived
+	}
+}
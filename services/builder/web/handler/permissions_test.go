@@ -0,0 +1,273 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestMapPermissions(t *testing.T) {
+	owner := &drive.FileCapabilities{
+		CanEdit: true, CanComment: true, CanCopy: true, CanDownload: true,
+	}
+	editor := &drive.FileCapabilities{
+		CanEdit: true, CanComment: true, CanCopy: true, CanDownload: true,
+	}
+	commenter := &drive.FileCapabilities{
+		CanEdit: false, CanComment: true, CanCopy: true, CanDownload: true,
+	}
+	viewer := &drive.FileCapabilities{
+		CanEdit: false, CanComment: false, CanCopy: true, CanDownload: true,
+	}
+	restrictedViewer := &drive.FileCapabilities{
+		CanEdit: false, CanComment: false, CanCopy: false, CanDownload: false,
+	}
+
+	tests := []struct {
+		name         string
+		capabilities *drive.FileCapabilities
+		editable     bool
+		expected     response.Permissions
+	}{
+		{
+			name:         "owner of an editable format",
+			capabilities: owner,
+			editable:     true,
+			expected: response.Permissions{
+				Comment: true, Copy: true, Download: true,
+				Edit: true, Print: true, Review: true,
+			},
+		},
+		{
+			name:         "editor of a view-only format",
+			capabilities: editor,
+			editable:     false,
+			expected: response.Permissions{
+				Comment: true, Copy: true, Download: true,
+				Edit: false, Print: true, Review: false,
+			},
+		},
+		{
+			name:         "commenter gets view mode with comments",
+			capabilities: commenter,
+			editable:     true,
+			expected: response.Permissions{
+				Comment: true, Copy: true, Download: true,
+				Edit: false, Print: true, Review: false,
+			},
+		},
+		{
+			name:         "viewer gets neither edit nor comment",
+			capabilities: viewer,
+			editable:     true,
+			expected: response.Permissions{
+				Comment: false, Copy: true, Download: true,
+				Edit: false, Print: true, Review: false,
+			},
+		},
+		{
+			name:         "viewer without download",
+			capabilities: restrictedViewer,
+			editable:     true,
+			expected: response.Permissions{
+				Comment: false, Copy: false, Download: false,
+				Edit: false, Print: false, Review: false,
+			},
+		},
+		{
+			name:         "missing capabilities default to owner",
+			capabilities: nil,
+			editable:     true,
+			expected: response.Permissions{
+				Comment: true, Copy: true, Download: true,
+				Edit: true, Print: true, Review: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &drive.File{Capabilities: tt.capabilities}
+			if got := mapPermissions(file, tt.editable, shared.OnlyofficePermissionsConfig{}); got != tt.expected {
+				t.Fatalf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// The deployment policy matrix: every policy against an editor and against
+// a restricted viewer, proving deny always wins, allow stops at what Drive
+// permits and drive/empty keep the derived behavior.
+func TestMapPermissionsPolicyMatrix(t *testing.T) {
+	editorFile := &drive.File{Capabilities: &drive.FileCapabilities{
+		CanEdit: true, CanComment: true, CanCopy: true, CanDownload: true,
+	}}
+	restrictedFile := &drive.File{
+		CopyRequiresWriterPermission: true,
+		Capabilities: &drive.FileCapabilities{
+			CanEdit: false, CanComment: false, CanCopy: true, CanDownload: true,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		file     *drive.File
+		policy   shared.OnlyofficePermissionsConfig
+		expected response.Permissions
+	}{
+		{
+			name:   "deny printing for an editor",
+			file:   editorFile,
+			policy: shared.OnlyofficePermissionsConfig{Print: shared.PermissionDeny},
+			expected: response.Permissions{
+				Comment: true, Copy: true, Download: true,
+				Edit: true, Print: false, Review: true,
+			},
+		},
+		{
+			name:   "deny download keeps the rest derived",
+			file:   editorFile,
+			policy: shared.OnlyofficePermissionsConfig{Download: shared.PermissionDeny},
+			expected: response.Permissions{
+				Comment: true, Copy: true, Download: false,
+				Edit: true, Print: true, Review: true,
+			},
+		},
+		{
+			name: "allow review and comment for a restricted viewer",
+			file: restrictedFile,
+			policy: shared.OnlyofficePermissionsConfig{
+				Comment: shared.PermissionAllow,
+				Review:  shared.PermissionAllow,
+			},
+			expected: response.Permissions{
+				Comment: true, Copy: false, Download: false,
+				Edit: false, Print: false, Review: true,
+			},
+		},
+		{
+			name: "allow cannot restore drive-restricted download",
+			file: restrictedFile,
+			policy: shared.OnlyofficePermissionsConfig{
+				Copy:     shared.PermissionAllow,
+				Download: shared.PermissionAllow,
+				Print:    shared.PermissionAllow,
+			},
+			expected: response.Permissions{
+				Comment: false, Copy: false, Download: false,
+				Edit: false, Print: false, Review: false,
+			},
+		},
+		{
+			name:   "allow cannot make a non-writer an editor",
+			file:   restrictedFile,
+			policy: shared.OnlyofficePermissionsConfig{Edit: shared.PermissionAllow},
+			expected: response.Permissions{
+				Comment: false, Copy: false, Download: false,
+				Edit: false, Print: false, Review: false,
+			},
+		},
+		{
+			name: "explicit drive matches the default",
+			file: editorFile,
+			policy: shared.OnlyofficePermissionsConfig{
+				Comment:  shared.PermissionDrive,
+				Copy:     shared.PermissionDrive,
+				Download: shared.PermissionDrive,
+				Edit:     shared.PermissionDrive,
+				Print:    shared.PermissionDrive,
+				Review:   shared.PermissionDrive,
+			},
+			expected: response.Permissions{
+				Comment: true, Copy: true, Download: true,
+				Edit: true, Print: true, Review: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapPermissions(tt.file, true, tt.policy); got != tt.expected {
+				t.Fatalf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMapPermissionsDownloadRestricted(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     *drive.File
+		expected response.Permissions
+	}{
+		{
+			name: "restricted file keeps writer permissions",
+			file: &drive.File{
+				CopyRequiresWriterPermission: true,
+				Capabilities: &drive.FileCapabilities{
+					CanEdit: true, CanComment: true, CanCopy: true, CanDownload: true,
+				},
+			},
+			expected: response.Permissions{
+				Comment: true, Copy: true, Download: true,
+				Edit: true, Print: true, Review: true,
+			},
+		},
+		{
+			name: "restricted file strips viewer copy and download",
+			file: &drive.File{
+				CopyRequiresWriterPermission: true,
+				Capabilities: &drive.FileCapabilities{
+					// Drive still reports these true because the viewer's own
+					// token can technically fetch the content.
+					CanEdit: false, CanComment: false, CanCopy: true, CanDownload: true,
+				},
+			},
+			expected: response.Permissions{
+				Comment: false, Copy: false, Download: false,
+				Edit: false, Print: false, Review: false,
+			},
+		},
+		{
+			name: "restricted file strips commenter copy and download",
+			file: &drive.File{
+				CopyRequiresWriterPermission: true,
+				Capabilities: &drive.FileCapabilities{
+					CanEdit: false, CanComment: true, CanCopy: true, CanDownload: true,
+				},
+			},
+			expected: response.Permissions{
+				Comment: true, Copy: false, Download: false,
+				Edit: false, Print: false, Review: false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapPermissions(tt.file, true, shared.OnlyofficePermissionsConfig{}); got != tt.expected {
+				t.Fatalf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"fmt"
+	"time"
+)
+
+// revisionTitle suffixes the editor title with the revision date so the user
+// can tell a pinned revision apart from the live file. Drive reports the
+// modification date in RFC 3339; if it ever does not, the raw value is still
+// better than no marker at all.
+func revisionTitle(title, modifiedDate string) string {
+	stamp := modifiedDate
+	if parsed, err := time.Parse(time.RFC3339, modifiedDate); err == nil {
+		stamp = parsed.UTC().Format("2006-01-02 15:04")
+	}
+
+	return fmt.Sprintf("%s (rev of %s)", title, stamp)
+}
@@ -0,0 +1,53 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import "testing"
+
+func TestRevisionTitle(t *testing.T) {
+	tests := map[string]struct {
+		title        string
+		modifiedDate string
+		expected     string
+	}{
+		"rfc3339 date is formatted": {
+			title:        "report.docx",
+			modifiedDate: "2023-04-12T09:30:00.000Z",
+			expected:     "report.docx (rev of 2023-04-12 09:30)",
+		},
+		"date is normalized to utc": {
+			title:        "report.docx",
+			modifiedDate: "2023-04-12T11:30:00.000+02:00",
+			expected:     "report.docx (rev of 2023-04-12 09:30)",
+		},
+		"unparsable date is kept verbatim": {
+			title:        "report.docx",
+			modifiedDate: "yesterday",
+			expected:     "report.docx (rev of yesterday)",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := revisionTitle(test.title, test.modifiedDate); got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
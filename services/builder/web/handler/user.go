@@ -0,0 +1,59 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	goauth "google.golang.org/api/oauth2/v2"
+)
+
+var ErrNoUserinfoID = errors.New("userinfo carries no account id")
+
+// editorUser maps Google account info onto the editor user block. Some
+// Workspace configurations return Userinfo without a display name, which
+// would label the user "" in co-editing presence, so the name falls back to
+// the email local-part and then to an anonymous label derived from the id.
+// An empty id is rejected outright: a config keyed to an empty user would
+// make every such account share one editing identity.
+func editorUser(uinfo *goauth.Userinfo) (response.User, error) {
+	if uinfo.Id == "" {
+		return response.User{}, ErrNoUserinfoID
+	}
+
+	name := uinfo.Name
+	if name == "" {
+		name = strings.SplitN(uinfo.Email, "@", 2)[0]
+	}
+	if name == "" {
+		suffix := uinfo.Id
+		if len(suffix) > 4 {
+			suffix = suffix[len(suffix)-4:]
+		}
+		name = fmt.Sprintf("Anonymous (%s)", suffix)
+	}
+
+	return response.User{
+		ID:   uinfo.Id,
+		Name: name,
+	}, nil
+}
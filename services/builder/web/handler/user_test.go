@@ -0,0 +1,69 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	goauth "google.golang.org/api/oauth2/v2"
+)
+
+func TestEditorUserNameFallbacks(t *testing.T) {
+	for name, tc := range map[string]struct {
+		uinfo    *goauth.Userinfo
+		expected string
+	}{
+		"display name wins": {
+			uinfo:    &goauth.Userinfo{Id: "100200300400", Name: "Jane Doe", Email: "jane@example.com"},
+			expected: "Jane Doe",
+		},
+		"email local-part when the name is empty": {
+			uinfo:    &goauth.Userinfo{Id: "100200300400", Email: "jane.doe@example.com"},
+			expected: "jane.doe",
+		},
+		"anonymous label when name and email are empty": {
+			uinfo:    &goauth.Userinfo{Id: "100200300400"},
+			expected: "Anonymous (0400)",
+		},
+		"short ids are not truncated": {
+			uinfo:    &goauth.Userinfo{Id: "42"},
+			expected: "Anonymous (42)",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			user, err := editorUser(tc.uinfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Name != tc.expected {
+				t.Errorf("expected name %q, got %q", tc.expected, user.Name)
+			}
+			if user.ID != tc.uinfo.Id {
+				t.Errorf("expected id %q, got %q", tc.uinfo.Id, user.ID)
+			}
+		})
+	}
+}
+
+func TestEditorUserRejectsEmptyID(t *testing.T) {
+	if _, err := editorUser(&goauth.Userinfo{Name: "Jane Doe"}); !errors.Is(err, ErrNoUserinfoID) {
+		t.Errorf("expected ErrNoUserinfoID, got %v", err)
+	}
+}
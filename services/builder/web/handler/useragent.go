@@ -0,0 +1,41 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import "strings"
+
+// mobileUAMarkers are the user agent fragments that select the mobile
+// editor. Android tablets do not always send "Mobile", so the platform
+// names are listed explicitly.
+var mobileUAMarkers = []string{
+	"mobile", "android", "iphone", "ipad", "ipod", "opera mini",
+	"windows phone",
+}
+
+// isMobileUserAgent reports whether a user agent string belongs to a phone
+// or tablet browser that should get the mobile editor.
+func isMobileUserAgent(ua string) bool {
+	ua = strings.ToLower(ua)
+	for _, marker := range mobileUAMarkers {
+		if strings.Contains(ua, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import "testing"
+
+func TestIsMobileUserAgent(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		ua     string
+		mobile bool
+	}{
+		{
+			name:   "iphone safari",
+			ua:     "Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.5 Mobile/15E148 Safari/604.1",
+			mobile: true,
+		},
+		{
+			name:   "android chrome",
+			ua:     "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Mobile Safari/537.36",
+			mobile: true,
+		},
+		{
+			name:   "android tablet without mobile token",
+			ua:     "Mozilla/5.0 (Linux; Android 12; SM-X906C) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/113.0.0.0 Safari/537.36",
+			mobile: true,
+		},
+		{
+			name:   "ipad safari",
+			ua:     "Mozilla/5.0 (iPad; CPU OS 16_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.5 Safari/604.1",
+			mobile: true,
+		},
+		{
+			name:   "windows phone",
+			ua:     "Mozilla/5.0 (Windows Phone 10.0; Android 6.0.1) AppleWebKit/537.36 (KHTML, like Gecko) Edge/15.15254",
+			mobile: true,
+		},
+		{
+			name:   "opera mini",
+			ua:     "Opera/9.80 (J2ME/MIDP; Opera Mini/9.80; U; en) Presto/2.12.423 Version/12.16",
+			mobile: true,
+		},
+		{
+			name:   "windows desktop chrome",
+			ua:     "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36",
+			mobile: false,
+		},
+		{
+			name:   "macos safari",
+			ua:     "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.5 Safari/605.1.15",
+			mobile: false,
+		},
+		{
+			name:   "empty",
+			ua:     "",
+			mobile: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isMobileUserAgent(tc.ua); got != tc.mobile {
+				t.Errorf("isMobileUserAgent(%q) = %t, want %t", tc.ua, got, tc.mobile)
+			}
+		})
+	}
+}
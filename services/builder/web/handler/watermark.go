@@ -0,0 +1,108 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+)
+
+// The watermark structures mirror the Document Server watermark_on_draw
+// format: a rotated rectangle with one paragraph of runs drawn over every
+// page.
+type watermarkRun struct {
+	Text       string `json:"text"`
+	Fill       []int  `json:"fill"`
+	FontSize   int    `json:"font-size"`
+	FontFamily string `json:"font-family,omitempty"`
+}
+
+type watermarkParagraph struct {
+	Align int            `json:"align"`
+	Runs  []watermarkRun `json:"runs"`
+}
+
+type watermarkBlock struct {
+	Transparent float64              `json:"transparent"`
+	Type        string               `json:"type"`
+	Width       int                  `json:"width"`
+	Height      int                  `json:"height"`
+	Rotate      int                  `json:"rotate"`
+	Fill        string               `json:"fill"`
+	Align       int                  `json:"align"`
+	Paragraphs  []watermarkParagraph `json:"paragraphs"`
+}
+
+// documentOptions returns the document options block for a session,
+// carrying the watermark only when it is enabled and the session is
+// view-only; editors would bake the watermark into the saved document.
+func documentOptions(
+	config shared.OnlyofficeWatermarkConfig, permissions response.Permissions,
+	name, email string, now time.Time,
+) *response.DocumentOptions {
+	if !config.Enabled || permissions.Edit {
+		return nil
+	}
+	return &response.DocumentOptions{
+		WatermarkOnDraw: watermarkOnDraw(config, name, email, now),
+	}
+}
+
+// renderWatermarkText substitutes the template placeholders with the
+// viewing user's details and the open time.
+func renderWatermarkText(template, name, email string, now time.Time) string {
+	replacer := strings.NewReplacer(
+		"{user_name}", name,
+		"{user_email}", email,
+		"{date}", now.Format("2006-01-02 15:04"),
+	)
+	return replacer.Replace(template)
+}
+
+// watermarkOnDraw renders the per-request watermark JSON for a view-only
+// session.
+func watermarkOnDraw(
+	config shared.OnlyofficeWatermarkConfig, name, email string, now time.Time,
+) json.RawMessage {
+	block := watermarkBlock{
+		Transparent: config.Transparent,
+		Type:        "rect",
+		Width:       100,
+		Height:      100,
+		Rotate:      config.Rotate,
+		Fill:        "none",
+		Align:       4,
+		Paragraphs: []watermarkParagraph{{
+			Align: 2,
+			Runs: []watermarkRun{{
+				Text:       renderWatermarkText(config.Template, name, email, now),
+				Fill:       []int{120, 120, 120},
+				FontSize:   config.FontSize,
+				FontFamily: config.FontFamily,
+			}},
+		}},
+	}
+
+	buf, _ := json.Marshal(block)
+	return buf
+}
@@ -0,0 +1,96 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+)
+
+func TestRenderWatermarkText(t *testing.T) {
+	opened := time.Date(2023, 9, 14, 10, 30, 0, 0, time.UTC)
+	got := renderWatermarkText(
+		"{user_name} <{user_email}> {date}",
+		"Some One", "someone@example.com", opened,
+	)
+	if got != "Some One <someone@example.com> 2023-09-14 10:30" {
+		t.Errorf("unexpected watermark text: %q", got)
+	}
+}
+
+func TestDocumentOptionsGating(t *testing.T) {
+	enabled := shared.OnlyofficeWatermarkConfig{Enabled: true, Template: "{user_email}"}
+
+	if documentOptions(
+		enabled, response.Permissions{Edit: true},
+		"Some One", "someone@example.com", time.Now(),
+	) != nil {
+		t.Error("editable sessions must not carry a watermark")
+	}
+
+	if documentOptions(
+		shared.OnlyofficeWatermarkConfig{}, response.Permissions{},
+		"Some One", "someone@example.com", time.Now(),
+	) != nil {
+		t.Error("a disabled watermark must not be rendered")
+	}
+
+	options := documentOptions(
+		enabled, response.Permissions{},
+		"Some One", "someone@example.com", time.Now(),
+	)
+	if options == nil || len(options.WatermarkOnDraw) == 0 {
+		t.Fatal("view-only sessions must carry the watermark")
+	}
+}
+
+func TestWatermarkOnDraw(t *testing.T) {
+	config := shared.OnlyofficeWatermarkConfig{
+		Enabled:     true,
+		Template:    "{user_email}",
+		FontFamily:  "Arial",
+		FontSize:    24,
+		Rotate:      -45,
+		Transparent: 0.3,
+	}
+
+	raw := watermarkOnDraw(config, "Some One", "someone@example.com", time.Now())
+
+	var block watermarkBlock
+	if err := json.Unmarshal(raw, &block); err != nil {
+		t.Fatalf("the watermark block must be valid json: %s", err.Error())
+	}
+	if block.Rotate != -45 || block.Transparent != 0.3 {
+		t.Errorf("unexpected geometry: %+v", block)
+	}
+	if len(block.Paragraphs) != 1 || len(block.Paragraphs[0].Runs) != 1 {
+		t.Fatalf("expected one paragraph with one run, got %+v", block.Paragraphs)
+	}
+	run := block.Paragraphs[0].Runs[0]
+	if run.Text != "someone@example.com" {
+		t.Errorf("expected the substituted template, got %q", run.Text)
+	}
+	if run.FontSize != 24 || run.FontFamily != "Arial" {
+		t.Errorf("unexpected font settings: %+v", run)
+	}
+}
@@ -0,0 +1,151 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/callback/web/controller"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/callback/web/worker"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/quota"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/timings"
+	sworker "github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/worker"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	aworker "github.com/ONLYOFFICE/onlyoffice-integration-adapters/worker"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go-micro.dev/v4"
+)
+
+func main() {
+	configPath := flag.String("config_path", "./config/config.yml", "server configuration path")
+	flag.Parse()
+
+	serverConfig, err := config.BuildNewServerConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	credentialsConfig, err := shared.BuildNewCredentialsConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	workerConfig, err := config.BuildNewWorkerConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	logger := log.NewDefaultLogger(serverConfig)
+
+	onlyofficeConfig, err := shared.NewOnlyofficeConfigSource(*configPath, logger.Warnf)
+	if err != nil {
+		logger.Fatalf("could not load the onlyoffice configuration: %s", err.Error())
+	}
+	if err := onlyofficeConfig.Watch(context.Background()); err != nil {
+		logger.Warnf("could not start the configuration watcher: %s", err.Error())
+	}
+
+	service := micro.NewService(
+		micro.Name(fmt.Sprintf("%s:callback", serverConfig.Namespace)),
+	)
+	service.Init()
+
+	monitor := quota.NewMonitor(
+		onlyofficeConfig.Config().Onlyoffice.Quota.LimitPerMinute,
+		onlyofficeConfig.Config().Onlyoffice.Quota.Threshold,
+	)
+	jwtManager := crypto.NewOnlyofficeJwtManager()
+	flags := shared.NewConfigFlags(onlyofficeConfig)
+	// Save durations per size bucket, summarized in the log every few
+	// minutes for capacity planning.
+	timingsRecorder := timings.NewRecorder()
+	timingsRecorder.StartSummaries(context.Background(), 5*time.Minute, logger.Infof)
+	callbackWorker := worker.NewCallbackWorker(
+		service.Client(), jwtManager, files.NewOnlyofficeFileUtility(),
+		serverConfig, credentialsConfig, onlyofficeConfig, flags, monitor,
+		timingsRecorder, logger,
+	)
+
+	// Every upload attempt — queued, synchronous or detached — reports its
+	// outcome here, so repeated failures for one document key can
+	// short-circuit the Document Server's retry storm at the callback.
+	uploadRetries := controller.NewUploadRetries(onlyofficeConfig)
+	trackedUpload := uploadRetries.Track(callbackWorker.UploadFile)
+
+	// Single-node installs can run without Redis by selecting the
+	// in-process queue; durability is traded off for not having to deploy
+	// a broker.
+	var enqueuer aworker.BackgroundEnqueuer
+	if workerConfig.Type == "memory" {
+		memoryWorker := sworker.NewMemoryWorker(workerConfig.MaxConcurrency, 3, logger.Warnf)
+		memoryWorker.Register(controller.UploadPattern, trackedUpload)
+		memoryWorker.RegisterDropped(controller.UploadPattern, callbackWorker.NotifyUploadFailed)
+		go memoryWorker.Run()
+		enqueuer = memoryWorker
+	} else {
+		// The queued worker owns its retry policy and exposes no drop hook,
+		// so failure notifications are only dispatched on the in-process
+		// queue for now.
+		backgroundWorker := aworker.NewBackgroundWorker(workerConfig, logger)
+		backgroundWorker.Register(controller.UploadPattern, trackedUpload)
+		go backgroundWorker.Run()
+		enqueuer = backgroundWorker
+	}
+
+	callbackController := controller.NewCallbackController(
+		enqueuer, trackedUpload, uploadRetries, jwtManager, credentialsConfig,
+		onlyofficeConfig, flags, logger,
+	)
+
+	mux := chi.NewRouter()
+	mux.Use(chimiddleware.RequestID)
+	mux.Use(shared.TraceMiddleware(onlyofficeConfig, serverConfig.Debug, logger))
+	mux.Post("/callback", callbackController.BuildPostHandleCallback())
+	mux.Get("/callback/retry", callbackController.BuildGetRetryUpload())
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Get("/version", buildinfo.Handler())
+	// Internal endpoints are only reachable with a signed request; see
+	// shared.InternalAuthMiddleware.
+	mux.Route("/internal", func(r chi.Router) {
+		r.Use(shared.InternalAuthMiddleware(onlyofficeConfig))
+		r.Post("/reload", func(rw http.ResponseWriter, _ *http.Request) {
+			if err := onlyofficeConfig.Reload(); err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			rw.WriteHeader(http.StatusOK)
+		})
+	})
+
+	logger.Infof("starting callback on %s", serverConfig.Address)
+	if err := http.ListenAndServe(serverConfig.Address, mux); err != nil {
+		logger.Fatalf("callback stopped: %s", err.Error())
+	}
+}
@@ -0,0 +1,441 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/callback/web/worker"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	aworker "github.com/ONLYOFFICE/onlyoffice-integration-adapters/worker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// UploadPattern is the background job queue the callback controller feeds.
+const UploadPattern = "gdrive-callback-upload"
+
+// uploadDeadlineMargin is how much of the callback's context budget must
+// remain for the handler to keep waiting on a synchronous upload. Below
+// it, the Document Server is about to give up on the request and retry.
+const uploadDeadlineMargin = 2 * time.Second
+
+// uploadDetachedBudget bounds an upload that keeps running after its
+// callback has already been answered, so a hung Drive call cannot pin a
+// goroutine forever.
+const uploadDetachedBudget = 5 * time.Minute
+
+// errUploadStillRunning reports that a synchronous upload outlived the
+// callback's deadline budget but has not failed; it keeps running detached
+// while the callback answers success.
+var errUploadStillRunning = errors.New("upload still running near the callback deadline")
+
+// unsignedCallbacks counts callbacks that arrived without any token while a
+// secret is configured — the classic "JWT enabled here, disabled on the
+// Document Server" misconfiguration. Package level so handler tests can
+// construct controllers without tripping duplicate metric registration.
+var unsignedCallbacks = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "onlyoffice_callback_unsigned_total",
+	Help: "Callbacks without a token while a Document Server secret is configured.",
+})
+
+// CallbackController terminates Document Server save callbacks and defers
+// the Drive upload to the background worker.
+type CallbackController struct {
+	enqueuer aworker.BackgroundEnqueuer
+	// uploader performs the upload synchronously for users rolled out of
+	// the queued-upload flag.
+	uploader func(ctx context.Context, payload []byte) error
+	// dedup absorbs Document Server retries of a save that is already
+	// being written, so a slow upload never lands in Drive twice.
+	dedup *uploadDedup
+	// retries short-circuits callbacks whose key keeps failing to upload,
+	// so a Drive outage does not turn every Document Server retry into a
+	// full download and upload.
+	retries     *UploadRetries
+	jwtManager  crypto.JwtManager
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	flags       shared.Flags
+	logger      log.Logger
+}
+
+func NewCallbackController(
+	enqueuer aworker.BackgroundEnqueuer,
+	uploader func(ctx context.Context, payload []byte) error,
+	retries *UploadRetries,
+	jwtManager crypto.JwtManager, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource, flags shared.Flags,
+	logger log.Logger,
+) CallbackController {
+	return CallbackController{
+		enqueuer:    enqueuer,
+		uploader:    uploader,
+		dedup:       newUploadDedup(uploadDedupTTL),
+		retries:     retries,
+		jwtManager:  jwtManager,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		flags:       flags,
+		logger:      logger,
+	}
+}
+
+func (c CallbackController) respond(rw http.ResponseWriter, errorCode int) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(map[string]int{"error": errorCode})
+}
+
+// BuildPostHandleCallback validates the callback body and, on a
+// save-requested status, enqueues a Drive upload job.
+func (c CallbackController) BuildPostHandleCallback() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		fileID := r.URL.Query().Get("id")
+		if fileID == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			c.respond(rw, 1)
+			return
+		}
+
+		var body request.CallbackRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			c.respond(rw, 1)
+			return
+		}
+
+		body.FileID = fileID
+		if err := body.Validate(); err != nil {
+			c.logger.Errorf("invalid callback body for %s: %s", fileID, err.Error())
+			rw.WriteHeader(http.StatusBadRequest)
+			c.respond(rw, 1)
+			return
+		}
+
+		builder := c.onlyoffice.Config().Onlyoffice.Builder
+		if builder.DocumentServerSecret != "" {
+			token := body.Token
+			// Installs can be configured to send the token in the
+			// Authorization header instead of the body.
+			if token == "" {
+				token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			}
+
+			// A decodable body with no token at all is not a forgery; it is
+			// the Document Server running with JWT disabled while this side
+			// expects signatures. Say so instead of a generic decode error.
+			if token == "" {
+				unsignedCallbacks.Inc()
+				c.logger.Errorf(
+					"callback for %s carries no token: Document Server JWT is not configured; see settings",
+					fileID,
+				)
+				rw.WriteHeader(http.StatusForbidden)
+				c.respond(rw, 1)
+				return
+			}
+
+			var verified request.CallbackRequest
+			// Issuer/audience claims and clock-skew leeway come from the
+			// builder config; unset options keep plain secret verification.
+			if err := shared.VerifyDocumentServerToken(builder, token, &verified); err != nil {
+				c.logger.Errorf("could not verify callback token for %s: %s", fileID, err.Error())
+				rw.WriteHeader(http.StatusForbidden)
+				c.respond(rw, 1)
+				return
+			}
+
+			// The signature covers the token's own claims, not the posted
+			// body; with the body left authoritative, any token signed with
+			// the secret — a replayed callback, an editor config token —
+			// would authorize arbitrary content. Once the token verifies,
+			// its claims are the payload every decision below reads, and a
+			// token that does not itself describe a valid callback is
+			// refused like a bad signature.
+			verified.FileID = fileID
+			if err := verified.Validate(); err != nil {
+				c.logger.Errorf("callback token claims for %s do not form a valid callback: %s", fileID, err.Error())
+				rw.WriteHeader(http.StatusForbidden)
+				c.respond(rw, 1)
+				return
+			}
+			body = verified
+		}
+
+		// Status 1 reports editing activity; it keeps the gateway's
+		// per-user session registry warm so seats expire on real
+		// inactivity, not on the editor open alone.
+		if body.Status == 1 && len(body.Users) > 0 {
+			go c.refreshSessions(body)
+		}
+
+		// A save transition means the content behind the document key is
+		// being replaced; editor configs the gateway cached for the key
+		// must not survive it.
+		if body.Status == 2 || body.Status == 6 {
+			go c.invalidateConfigs(body)
+		}
+
+		if body.Status == 2 {
+			if len(body.Users) < 1 {
+				c.logger.Errorf("callback for %s carries no users", fileID)
+				rw.WriteHeader(http.StatusBadRequest)
+				c.respond(rw, 1)
+				return
+			}
+
+			// A key that kept failing to upload gets its remaining retries
+			// answered here, before any revision download: each one would
+			// otherwise run the full pipeline against a Drive that is down.
+			if c.retries.Exceeded(body.Key) {
+				suppressedCallbacks.Inc()
+				c.logger.Warnf("suppressing callback for %s: save %s exhausted its upload attempts", fileID, body.Key)
+				rw.WriteHeader(http.StatusTooManyRequests)
+				c.respond(rw, 1)
+				return
+			}
+
+			// A repeat of a save that is already in flight is the Document
+			// Server retrying a callback it gave up on, not a new revision;
+			// answering success is what stops the retry loop.
+			if !c.dedup.Begin(body.Key, body.URL) {
+				c.logger.Debugf("callback for %s repeats in-flight save %s; answering success", fileID, body.Key)
+				c.respond(rw, 0)
+				return
+			}
+
+			payload, _ := json.Marshal(worker.UploadRequest{
+				UID:        body.Users[0],
+				Users:      body.Users,
+				FileID:     body.FileID,
+				URL:        body.URL,
+				Key:        body.Key,
+				ChangesURL: body.Changesurl,
+				History:    body.History,
+			})
+
+			// Queued uploads are the default; the flag exists so the
+			// behavior can be rolled back per domain or percentage without
+			// a redeploy.
+			if c.flags.Enabled(shared.FlagQueuedUploads, body.Users[0], true) {
+				if err := c.enqueuer.Enqueue(UploadPattern, payload); err != nil {
+					c.logger.Errorf("could not enqueue an upload job for %s: %s", fileID, err.Error())
+					c.dedup.Forget(body.Key, body.URL)
+					rw.WriteHeader(http.StatusInternalServerError)
+					c.respond(rw, 1)
+					return
+				}
+			} else if err := c.uploadWithinDeadline(r, payload, body.Key, body.URL); err != nil {
+				if errors.Is(err, errUploadStillRunning) {
+					// The upload keeps going detached; the dedup entry
+					// absorbs the retry this answer would otherwise cause.
+					c.logger.Warnf("upload for %s outlived the callback budget; answering optimistically", fileID)
+				} else {
+					c.logger.Errorf("could not upload %s synchronously: %s", fileID, err.Error())
+					c.dedup.Forget(body.Key, body.URL)
+					rw.WriteHeader(http.StatusInternalServerError)
+					c.respond(rw, 1)
+					return
+				}
+			}
+		}
+
+		c.respond(rw, 0)
+	}
+}
+
+// uploadWithinDeadline runs the synchronous upload detached from the
+// callback's deadline. When the request carries no deadline the upload
+// simply runs to completion. Otherwise the handler waits until the margin
+// before the Document Server gives up; an upload still moving at that
+// point returns errUploadStillRunning so the callback can answer success
+// instead of letting a retry write the same content twice. An upload that
+// fails after the optimistic answer releases its dedup entry, so the
+// retry the Document Server sends anyway gets a real second attempt.
+func (c CallbackController) uploadWithinDeadline(
+	r *http.Request, payload []byte, key, documentURL string,
+) error {
+	deadline, ok := r.Context().Deadline()
+	if !ok {
+		return c.uploader(r.Context(), payload)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), uploadDetachedBudget)
+	done := make(chan error, 1)
+	go func() {
+		defer cancel()
+		err := c.uploader(ctx, payload)
+		if err != nil {
+			c.dedup.Forget(key, documentURL)
+		}
+		done <- err
+	}()
+
+	budget := time.Until(deadline) - uploadDeadlineMargin
+	if budget < 0 {
+		budget = 0
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(budget):
+		// The handler stops listening here; a late failure is only visible
+		// through this log line and the released dedup entry.
+		go func() {
+			if err := <-done; err != nil {
+				c.logger.Errorf("detached upload for save %s failed after an optimistic answer: %s", key, err.Error())
+			}
+		}()
+		return errUploadStillRunning
+	}
+}
+
+// BuildGetRetryUpload re-enqueues a dropped upload job from a signed retry
+// token. The endpoint answers 404 while notifications are disabled, so an
+// install that never issues retry links does not advertise it.
+func (c CallbackController) BuildGetRetryUpload() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if !c.onlyoffice.Config().Onlyoffice.Callback.Notifications {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var token shared.UploadRetryToken
+		if err := c.jwtManager.Verify(
+			c.credentials.Credentials.ClientSecret,
+			r.URL.Query().Get("token"), &token,
+		); err != nil {
+			c.logger.Errorf("could not verify a retry token: %s", err.Error())
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// The Document Server only keeps saved revisions for a limited
+		// window; a dead URL means the revision is gone and re-enqueueing
+		// could never succeed.
+		if !c.documentURLAlive(r, token.URL) {
+			rw.WriteHeader(http.StatusGone)
+			fmt.Fprintln(rw, "The saved revision is no longer available.")
+			return
+		}
+
+		payload, _ := json.Marshal(worker.UploadRequest{
+			UID:        token.UID,
+			FileID:     token.FileID,
+			URL:        token.URL,
+			Key:        token.Key,
+			ChangesURL: token.ChangesURL,
+		})
+		if err := c.enqueuer.Enqueue(UploadPattern, payload); err != nil {
+			c.logger.Errorf("could not re-enqueue an upload for %s: %s", token.FileID, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Fprintln(rw, "The upload has been queued again. Check the file in Google Drive in a few minutes.")
+	}
+}
+
+func (c CallbackController) documentURLAlive(r *http.Request, documentURL string) bool {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodHead, documentURL, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < http.StatusBadRequest
+}
+
+// invalidateConfigs tells the gateway to drop the editor configs it cached
+// for the document key a save transition just made stale. Failures only
+// cost a rebuilt config on the next reload, so they are logged and
+// swallowed.
+func (c CallbackController) invalidateConfigs(body request.CallbackRequest) {
+	config := c.onlyoffice.Config().Onlyoffice
+	if config.Internal.Secret == "" {
+		return
+	}
+
+	client := shared.NewInternalClient(config.Internal.Secret, nil)
+	req, err := http.NewRequest(
+		http.MethodPost,
+		fmt.Sprintf("%s/internal/configs/invalidate", strings.TrimSuffix(config.Builder.GatewayURL, "/")),
+		bytes.NewReader(request.ConfigInvalidateRequest{
+			FileID: body.FileID,
+			Key:    body.Key,
+		}.ToJSON()),
+	)
+	if err != nil {
+		return
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		c.logger.Debugf("could not invalidate cached configs for %s: %s", body.Key, err.Error())
+		return
+	}
+	resp.Body.Close()
+}
+
+// refreshSessions forwards Document Server activity to the gateway session
+// registry over the signed internal endpoint. Failures only cost seat
+// accounting accuracy, so they are logged and swallowed.
+func (c CallbackController) refreshSessions(body request.CallbackRequest) {
+	config := c.onlyoffice.Config().Onlyoffice
+	if config.Internal.Secret == "" {
+		return
+	}
+
+	client := shared.NewInternalClient(config.Internal.Secret, nil)
+	for _, uid := range body.Users {
+		req, err := http.NewRequest(
+			http.MethodPost,
+			fmt.Sprintf("%s/internal/sessions/refresh", strings.TrimSuffix(config.Builder.GatewayURL, "/")),
+			bytes.NewReader(request.SessionRefreshRequest{
+				UID:    uid,
+				FileID: body.FileID,
+				Key:    body.Key,
+			}.ToJSON()),
+		)
+		if err != nil {
+			return
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			c.logger.Debugf("could not refresh session for %s: %s", uid, err.Error())
+			continue
+		}
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,646 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/callback/web/worker"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	aworker "github.com/ONLYOFFICE/onlyoffice-integration-adapters/worker"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// fakeEnqueuer embeds the interface so only Enqueue needs an implementation.
+type fakeEnqueuer struct {
+	aworker.BackgroundEnqueuer
+	patterns []string
+	payloads [][]byte
+	err      error
+}
+
+func (f *fakeEnqueuer) Enqueue(pattern string, payload []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.patterns = append(f.patterns, pattern)
+	f.payloads = append(f.payloads, payload)
+	return nil
+}
+
+// retryJwtManager accepts exactly one token string and fills the verified
+// body with the prepared claims.
+type retryJwtManager struct {
+	valid string
+	token shared.UploadRetryToken
+}
+
+func (f retryJwtManager) Sign(secret string, payload jwt.Claims) (string, error) {
+	return f.valid, nil
+}
+
+func (f retryJwtManager) Verify(secret, token string, body interface{}) error {
+	if token != f.valid {
+		return errors.New("invalid token")
+	}
+	if target, ok := body.(*shared.UploadRetryToken); ok {
+		*target = f.token
+	}
+	return nil
+}
+
+func newRetryTestController(
+	t *testing.T, notifications bool,
+	manager retryJwtManager, enqueuer *fakeEnqueuer,
+) CallbackController {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := fmt.Sprintf(`onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+  callback:
+    notifications: %t
+`, notifications)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := &shared.CredentialsConfig{}
+	credentials.Credentials.ClientSecret = "client-secret"
+	return NewCallbackController(
+		enqueuer, nil, NewUploadRetries(source), manager, credentials, source,
+		shared.NewConfigFlags(source), testLogger{},
+	)
+}
+
+// newCallbackTestController builds a controller whose config carries a
+// Document Server secret, so the callback handler expects signed requests.
+func newCallbackTestController(t *testing.T, secret string, enqueuer *fakeEnqueuer) CallbackController {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := fmt.Sprintf(`onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    document_server_secret: "%s"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+`, secret)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewCallbackController(
+		enqueuer, nil, NewUploadRetries(source), retryJwtManager{},
+		&shared.CredentialsConfig{}, source,
+		shared.NewConfigFlags(source), testLogger{},
+	)
+}
+
+// testLogger discards everything; handler tests assert on behavior, not on
+// log output.
+type testLogger struct{}
+
+func (testLogger) Debugf(format string, args ...interface{}) {}
+func (testLogger) Infof(format string, args ...interface{})  {}
+func (testLogger) Warnf(format string, args ...interface{})  {}
+func (testLogger) Errorf(format string, args ...interface{}) {}
+func (testLogger) Fatalf(format string, args ...interface{}) {}
+
+func TestCallbackRejectsUnsignedWhenSecretConfigured(t *testing.T) {
+	enqueuer := &fakeEnqueuer{}
+	controller := newCallbackTestController(t, "ds-secret", enqueuer)
+
+	rec := httptest.NewRecorder()
+	controller.BuildPostHandleCallback()(rec, httptest.NewRequest(
+		"POST", "/callback?id=file-id-0123456789",
+		strings.NewReader(`{"key":"revision-key","status":2,"users":["1000000000"],"url":"https://ds.example.com/cache/file.docx"}`),
+	))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an unsigned callback, got %d", rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"error":1}` {
+		t.Errorf("expected the Document Server error contract, got %s", body)
+	}
+	if len(enqueuer.payloads) != 0 {
+		t.Error("an unsigned callback must not enqueue anything")
+	}
+}
+
+func TestCallbackAcceptsSignedToken(t *testing.T) {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"key":    "revision-key",
+		"status": 2,
+		"users":  []string{"1000000000"},
+		"url":    "https://ds.example.com/cache/file.docx",
+	}).SignedString([]byte("ds-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		bodyToken bool
+		header    string
+	}{
+		"token in the body":                 {bodyToken: true},
+		"token in the authorization header": {header: "Bearer " + signed},
+	} {
+		t.Run(name, func(t *testing.T) {
+			body := `{"key":"revision-key","status":2,"users":["1000000000"],"url":"https://ds.example.com/cache/file.docx"}`
+			if tc.bodyToken {
+				body = fmt.Sprintf(
+					`{"key":"revision-key","status":2,"users":["1000000000"],"url":"https://ds.example.com/cache/file.docx","token":"%s"}`,
+					signed,
+				)
+			}
+
+			enqueuer := &fakeEnqueuer{}
+			controller := newCallbackTestController(t, "ds-secret", enqueuer)
+			req := httptest.NewRequest("POST", "/callback?id=file-id-0123456789", strings.NewReader(body))
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			controller.BuildPostHandleCallback()(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200 for a signed callback, got %d", rec.Code)
+			}
+			if len(enqueuer.payloads) != 1 {
+				t.Fatalf("expected one upload job, got %d", len(enqueuer.payloads))
+			}
+		})
+	}
+}
+
+// The signature covers only the token's claims, so a body posted next to a
+// valid token must not be able to smuggle its own save: the upload job is
+// built from the verified claims and the unsigned body is discarded.
+func TestCallbackBuildsTheJobFromVerifiedClaimsOnly(t *testing.T) {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"key":    "revision-key",
+		"status": 2,
+		"users":  []string{"1000000000"},
+		"url":    "https://ds.example.com/cache/file.docx",
+	}).SignedString([]byte("ds-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enqueuer := &fakeEnqueuer{}
+	controller := newCallbackTestController(t, "ds-secret", enqueuer)
+	rec := httptest.NewRecorder()
+	controller.BuildPostHandleCallback()(rec, httptest.NewRequest(
+		"POST", "/callback?id=file-id-0123456789",
+		strings.NewReader(fmt.Sprintf(
+			`{"key":"revision-key","status":2,"users":["2000000000"],"url":"https://attacker.example.com/payload.docx","token":"%s"}`,
+			signed,
+		)),
+	))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a signed callback, got %d", rec.Code)
+	}
+	if len(enqueuer.payloads) != 1 {
+		t.Fatalf("expected one upload job, got %d", len(enqueuer.payloads))
+	}
+	var job worker.UploadRequest
+	if err := json.Unmarshal(enqueuer.payloads[0], &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.URL != "https://ds.example.com/cache/file.docx" {
+		t.Errorf("expected the job to carry the signed url, got %q", job.URL)
+	}
+	if job.UID != "1000000000" {
+		t.Errorf("expected the job to carry the signed user, got %q", job.UID)
+	}
+}
+
+// A token signed with the secret but not describing a save — an editor
+// config token leaked from the same deployment — must not let the body
+// next to it stand in for the missing claims.
+func TestCallbackRejectsTokenWithoutSaveClaims(t *testing.T) {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"document": map[string]interface{}{"key": "revision-key"},
+	}).SignedString([]byte("ds-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enqueuer := &fakeEnqueuer{}
+	controller := newCallbackTestController(t, "ds-secret", enqueuer)
+	rec := httptest.NewRecorder()
+	controller.BuildPostHandleCallback()(rec, httptest.NewRequest(
+		"POST", "/callback?id=file-id-0123456789",
+		strings.NewReader(fmt.Sprintf(
+			`{"key":"revision-key","status":2,"users":["1000000000"],"url":"https://ds.example.com/cache/file.docx","token":"%s"}`,
+			signed,
+		)),
+	))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a token without callback claims, got %d", rec.Code)
+	}
+	if len(enqueuer.payloads) != 0 {
+		t.Error("a token without callback claims must not enqueue anything")
+	}
+}
+
+func TestRetryUploadHiddenWhileDisabled(t *testing.T) {
+	controller := newRetryTestController(t, false, retryJwtManager{valid: "token"}, &fakeEnqueuer{})
+	rec := httptest.NewRecorder()
+	controller.BuildGetRetryUpload()(rec, httptest.NewRequest("GET", "/callback/retry?token=token", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 while notifications are disabled, got %d", rec.Code)
+	}
+}
+
+func TestRetryUploadRejectsInvalidToken(t *testing.T) {
+	enqueuer := &fakeEnqueuer{}
+	controller := newRetryTestController(t, true, retryJwtManager{valid: "token"}, enqueuer)
+	rec := httptest.NewRecorder()
+	controller.BuildGetRetryUpload()(rec, httptest.NewRequest("GET", "/callback/retry?token=forged", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a forged token, got %d", rec.Code)
+	}
+	if len(enqueuer.payloads) != 0 {
+		t.Error("a forged token must not enqueue anything")
+	}
+}
+
+func TestRetryUploadReenqueuesLiveRevision(t *testing.T) {
+	ds := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer ds.Close()
+
+	enqueuer := &fakeEnqueuer{}
+	controller := newRetryTestController(t, true, retryJwtManager{
+		valid: "token",
+		token: shared.UploadRetryToken{
+			UID:    "1000000000",
+			FileID: "file-id-0123456789",
+			URL:    ds.URL + "/cache/file.docx",
+			Key:    "revision-key",
+		},
+	}, enqueuer)
+
+	rec := httptest.NewRecorder()
+	controller.BuildGetRetryUpload()(rec, httptest.NewRequest(
+		"GET", "/callback/retry?token="+url.QueryEscape("token"), nil,
+	))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	if len(enqueuer.payloads) != 1 || enqueuer.patterns[0] != UploadPattern {
+		t.Fatalf("expected one upload job, got %v", enqueuer.patterns)
+	}
+	var job worker.UploadRequest
+	if err := json.Unmarshal(enqueuer.payloads[0], &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.UID != "1000000000" || job.FileID != "file-id-0123456789" || job.Key != "revision-key" {
+		t.Errorf("re-enqueued job does not match the token: %+v", job)
+	}
+}
+
+// newSyncUploadTestController rolls the queued-upload flag off, so status-2
+// callbacks run the provided uploader synchronously.
+func newSyncUploadTestController(
+	t *testing.T, uploader func(ctx context.Context, payload []byte) error,
+	enqueuer *fakeEnqueuer,
+) CallbackController {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+  features:
+    queued_uploads:
+      default: false
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retries := NewUploadRetries(source)
+	return NewCallbackController(
+		enqueuer, retries.Track(uploader), retries, retryJwtManager{},
+		&shared.CredentialsConfig{}, source,
+		shared.NewConfigFlags(source), testLogger{},
+	)
+}
+
+func saveCallbackRequest(t *testing.T, ctx context.Context) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(
+		"POST", "/callback?id=file-id-0123456789",
+		strings.NewReader(`{"key":"revision-key","status":2,"users":["1000000000"],"url":"https://ds.example.com/cache/file.docx"}`),
+	)
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	return req
+}
+
+// A save whose upload outlives the callback deadline is answered with
+// success while the upload keeps running; the Document Server's retry of
+// the "failed" callback is absorbed, and Drive sees exactly one write.
+func TestSlowUploadSpanningTwoCallbacksWritesOnce(t *testing.T) {
+	var writes int32
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	controller := newSyncUploadTestController(t, func(ctx context.Context, payload []byte) error {
+		atomic.AddInt32(&writes, 1)
+		<-release
+		close(finished)
+		return nil
+	}, &fakeEnqueuer{})
+	handler := controller.BuildPostHandleCallback()
+
+	// The first attempt carries a deadline the upload cannot meet.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	first := httptest.NewRecorder()
+	handler(first, saveCallbackRequest(t, ctx))
+	if first.Code != http.StatusOK || strings.TrimSpace(first.Body.String()) != `{"error":0}` {
+		t.Fatalf("expected an optimistic success, got %d %s", first.Code, first.Body.String())
+	}
+
+	// The Document Server retries the same save while the upload is still
+	// in flight.
+	second := httptest.NewRecorder()
+	handler(second, saveCallbackRequest(t, nil))
+	if second.Code != http.StatusOK || strings.TrimSpace(second.Body.String()) != `{"error":0}` {
+		t.Fatalf("expected the retry to be absorbed, got %d %s", second.Code, second.Body.String())
+	}
+
+	close(release)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("the detached upload never finished")
+	}
+	if got := atomic.LoadInt32(&writes); got != 1 {
+		t.Errorf("expected a single Drive write across both callbacks, got %d", got)
+	}
+}
+
+// A genuinely failed upload must release its dedup entry, so the retry the
+// error answer provokes gets a real second attempt instead of a cached
+// success.
+func TestFailedUploadAllowsTheRetryThrough(t *testing.T) {
+	var attempts int32
+	controller := newSyncUploadTestController(t, func(ctx context.Context, payload []byte) error {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return errors.New("stored token revoked")
+		}
+		return nil
+	}, &fakeEnqueuer{})
+	handler := controller.BuildPostHandleCallback()
+
+	first := httptest.NewRecorder()
+	handler(first, saveCallbackRequest(t, nil))
+	if first.Code != http.StatusInternalServerError {
+		t.Fatalf("expected the failure to surface, got %d", first.Code)
+	}
+
+	second := httptest.NewRecorder()
+	handler(second, saveCallbackRequest(t, nil))
+	if second.Code != http.StatusOK {
+		t.Fatalf("expected the retry to succeed, got %d", second.Code)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Errorf("expected the retry to reach the uploader, got %d attempts", got)
+	}
+}
+
+// The queued path dedupes too: a retried callback must not enqueue the
+// same revision twice.
+func TestDuplicateCallbackEnqueuesOnce(t *testing.T) {
+	enqueuer := &fakeEnqueuer{}
+	controller := newCallbackTestController(t, "", enqueuer)
+	handler := controller.BuildPostHandleCallback()
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, saveCallbackRequest(t, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("attempt %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	if len(enqueuer.payloads) != 1 {
+		t.Errorf("expected one queued job for two identical callbacks, got %d", len(enqueuer.payloads))
+	}
+}
+
+// newRetryCapTestController wires a controller, its retry tracker and a
+// scripted uploader around a config whose retry cap is small enough to
+// exercise. The tracker is returned so tests can pin its clock.
+func newRetryCapTestController(
+	t *testing.T, queued bool,
+	uploader func(ctx context.Context, payload []byte) error,
+	enqueuer *fakeEnqueuer,
+) (CallbackController, *UploadRetries) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := fmt.Sprintf(`onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+  callback:
+    max_retries: 2
+    retry_window: 60
+  features:
+    queued_uploads:
+      default: %t
+`, queued)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retries := NewUploadRetries(source)
+	var tracked func(ctx context.Context, payload []byte) error
+	if uploader != nil {
+		tracked = retries.Track(uploader)
+	}
+	return NewCallbackController(
+		enqueuer, tracked, retries, retryJwtManager{},
+		&shared.CredentialsConfig{}, source,
+		shared.NewConfigFlags(source), testLogger{},
+	), retries
+}
+
+// After the configured number of failed uploads, further callbacks for the
+// key are short-circuited; a success or the window expiring resets the cap.
+func TestRepeatedFailuresShortCircuitCallbacks(t *testing.T) {
+	var attempts int32
+	var failing int32 = 1
+	controller, retries := newRetryCapTestController(t, false, func(ctx context.Context, payload []byte) error {
+		atomic.AddInt32(&attempts, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("drive is down")
+		}
+		return nil
+	}, &fakeEnqueuer{})
+	handler := controller.BuildPostHandleCallback()
+
+	// Two real attempts are allowed and both reach Drive.
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, saveCallbackRequest(t, nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("attempt %d: expected the failure to surface, got %d", i+1, rec.Code)
+		}
+	}
+
+	// The third callback is answered without an upload attempt.
+	rec := httptest.NewRecorder()
+	handler(rec, saveCallbackRequest(t, nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the callback to be short-circuited, got %d", rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"error":1}` {
+		t.Errorf("expected the Document Server error contract, got %s", body)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Fatalf("expected the suppressed callback to skip the uploader, got %d attempts", got)
+	}
+
+	// Once the window expires the key gets fresh attempts.
+	retries.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	rec = httptest.NewRecorder()
+	handler(rec, saveCallbackRequest(t, nil))
+	if rec.Code != http.StatusInternalServerError || atomic.LoadInt32(&attempts) != 3 {
+		t.Fatalf("expected the expired window to let the callback through, got %d", rec.Code)
+	}
+
+	// One success clears the key entirely.
+	atomic.StoreInt32(&failing, 0)
+	rec = httptest.NewRecorder()
+	handler(rec, saveCallbackRequest(t, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the upload to succeed, got %d", rec.Code)
+	}
+	if retries.Exceeded("revision-key") {
+		t.Error("expected a successful upload to reset the retry budget")
+	}
+}
+
+// The queued path is capped too: once the background attempts for a key
+// exhaust the budget, further callbacks stop re-enqueueing it.
+func TestQueuedUploadFailuresCapReenqueues(t *testing.T) {
+	enqueuer := &fakeEnqueuer{}
+	controller, retries := newRetryCapTestController(t, true, nil, enqueuer)
+	handler := controller.BuildPostHandleCallback()
+	failed := retries.Track(func(ctx context.Context, payload []byte) error {
+		return errors.New("drive is down")
+	})
+
+	// Each save carries its own revision URL, so the dedup lets it through;
+	// the queued job then fails in the background.
+	for i := 0; i < 2; i++ {
+		body := fmt.Sprintf(
+			`{"key":"revision-key","status":2,"users":["1000000000"],"url":"https://ds.example.com/cache/file-%d.docx"}`,
+			i,
+		)
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(
+			"POST", "/callback?id=file-id-0123456789", strings.NewReader(body),
+		))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("attempt %d: expected the job to be enqueued, got %d", i+1, rec.Code)
+		}
+		if err := failed(context.Background(), enqueuer.payloads[i]); err == nil {
+			t.Fatal("expected the background attempt to fail")
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(
+		"POST", "/callback?id=file-id-0123456789",
+		strings.NewReader(`{"key":"revision-key","status":2,"users":["1000000000"],"url":"https://ds.example.com/cache/file-2.docx"}`),
+	))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the callback to be short-circuited, got %d", rec.Code)
+	}
+	if len(enqueuer.payloads) != 2 {
+		t.Errorf("expected no further jobs for the exhausted key, got %d", len(enqueuer.payloads))
+	}
+}
+
+func TestRetryUploadRejectsDeadRevision(t *testing.T) {
+	ds := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	defer ds.Close()
+
+	enqueuer := &fakeEnqueuer{}
+	controller := newRetryTestController(t, true, retryJwtManager{
+		valid: "token",
+		token: shared.UploadRetryToken{URL: ds.URL + "/cache/file.docx"},
+	}, enqueuer)
+
+	rec := httptest.NewRecorder()
+	controller.BuildGetRetryUpload()(rec, httptest.NewRequest("GET", "/callback/retry?token=token", nil))
+	if rec.Code != http.StatusGone {
+		t.Fatalf("expected 410 for a dead revision url, got %d", rec.Code)
+	}
+	if len(enqueuer.payloads) != 0 {
+		t.Error("a dead revision must not enqueue anything")
+	}
+}
@@ -0,0 +1,90 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"crypto/md5"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// uploadDedupTTL is how long a save stays remembered. The Document Server
+// retries a callback it considers failed within minutes, and the revision
+// URL it hands out changes with every new save, so a short window is
+// enough to absorb retries without ever suppressing a genuinely new save.
+const uploadDedupTTL = 10 * time.Minute
+
+// uploadDedup remembers which saved revisions are already on their way to
+// Drive. The Document Server retries callbacks on its own schedule; when
+// an upload is slow but succeeding, the retry carries the same document
+// key and revision URL, and writing it again would duplicate content in
+// Drive. Entries expire on their own — there is no completion callback,
+// because a finished upload must keep absorbing retries too.
+type uploadDedup struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]time.Time
+}
+
+func newUploadDedup(ttl time.Duration) *uploadDedup {
+	return &uploadDedup{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]time.Time{},
+	}
+}
+
+// uploadDedupKey identifies one saved revision. The document key alone is
+// not enough: force-save flows can report several revisions under one key,
+// each with its own download URL, so the URL hash disambiguates them.
+func uploadDedupKey(key, documentURL string) string {
+	return fmt.Sprintf("%s:%x", key, md5.Sum([]byte(documentURL)))
+}
+
+// Begin reports whether this callback is the first to carry the revision.
+// Repeats within the ttl are Document Server retries of a save that is
+// already in flight or already done.
+func (d *uploadDedup) Begin(key, documentURL string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := uploadDedupKey(key, documentURL)
+	now := d.now()
+	for stored, expires := range d.entries {
+		if now.After(expires) {
+			delete(d.entries, stored)
+		}
+	}
+
+	if expires, ok := d.entries[id]; ok && now.Before(expires) {
+		return false
+	}
+	d.entries[id] = now.Add(d.ttl)
+	return true
+}
+
+// Forget releases a revision after its upload failed, so the Document
+// Server's retry of the same save is processed instead of swallowed.
+func (d *uploadDedup) Forget(key, documentURL string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.entries, uploadDedupKey(key, documentURL))
+}
@@ -0,0 +1,143 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/callback/web/worker"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// suppressedCallbacks counts save callbacks short-circuited because their
+// document key kept failing to upload. Package level so handler tests can
+// construct controllers without tripping duplicate metric registration.
+var suppressedCallbacks = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "onlyoffice_callback_suppressed_total",
+	Help: "Save callbacks short-circuited after repeated failed uploads for one document key.",
+})
+
+// UploadRetries tracks failed upload attempts per document key. When Drive
+// is down, the Document Server retries status-2 callbacks aggressively for
+// every open document, and each retry costs a full revision download and a
+// doomed upload; once a key exhausts its attempts within the window, the
+// remaining retries are answered without touching Drive at all. A
+// successful upload or the window expiring opens the gate again. The limit
+// and window come from the callback configuration and follow reloads.
+type UploadRetries struct {
+	onlyoffice *shared.OnlyofficeConfigSource
+	mu         sync.Mutex
+	now        func() time.Time
+	entries    map[string]retryAttempts
+}
+
+type retryAttempts struct {
+	count int
+	since time.Time
+}
+
+func NewUploadRetries(onlyoffice *shared.OnlyofficeConfigSource) *UploadRetries {
+	return &UploadRetries{
+		onlyoffice: onlyoffice,
+		now:        time.Now,
+		entries:    map[string]retryAttempts{},
+	}
+}
+
+func (r *UploadRetries) limits() (int, time.Duration) {
+	callback := r.onlyoffice.Config().Onlyoffice.Callback
+	return callback.MaxRetries, time.Duration(callback.RetryWindow) * time.Second
+}
+
+// Exceeded reports whether the key has exhausted its upload attempts within
+// the window. A non-positive limit disables the cap entirely.
+func (r *UploadRetries) Exceeded(key string) bool {
+	limit, window := r.limits()
+	if limit <= 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(window)
+	return r.entries[key].count >= limit
+}
+
+// Failed records one failed upload attempt for the key. The window is
+// anchored at the first failure, so a key cannot stay suppressed forever on
+// sparse failures alone.
+func (r *UploadRetries) Failed(key string) {
+	if key == "" {
+		return
+	}
+	_, window := r.limits()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(window)
+	entry := r.entries[key]
+	if entry.count == 0 {
+		entry.since = r.now()
+	}
+	entry.count++
+	r.entries[key] = entry
+}
+
+// Succeeded resets the key after an upload landed in Drive.
+func (r *UploadRetries) Succeeded(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, key)
+}
+
+func (r *UploadRetries) prune(window time.Duration) {
+	now := r.now()
+	for key, entry := range r.entries {
+		if now.Sub(entry.since) > window {
+			delete(r.entries, key)
+		}
+	}
+}
+
+// Track wraps an upload function so every outcome feeds the tracker,
+// regardless of whether the attempt ran synchronously, detached or on the
+// background queue.
+func (r *UploadRetries) Track(
+	uploader func(ctx context.Context, payload []byte) error,
+) func(ctx context.Context, payload []byte) error {
+	return func(ctx context.Context, payload []byte) error {
+		var job worker.UploadRequest
+		if err := json.Unmarshal(payload, &job); err != nil {
+			return err
+		}
+
+		err := uploader(ctx, payload)
+		if err != nil {
+			r.Failed(job.Key)
+		} else {
+			r.Succeeded(job.Key)
+		}
+		return err
+	}
+}
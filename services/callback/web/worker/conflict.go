@@ -0,0 +1,58 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package worker
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+// conflictingKey reports whether the editor key the Document Server saved
+// under no longer matches the file's current revision. The builder derives
+// the key from the file id and modification date — salted for lossy
+// force-edit sessions, which save through this same path — so a mismatch
+// with both variants means Drive content changed while the editor was
+// open. Jobs without a key predate the field and never conflict.
+func conflictingKey(key string, file *drive.File) bool {
+	if key == "" {
+		return false
+	}
+
+	material := file.Id + file.ModifiedDate
+	for _, variant := range []string{material, material + ":force-edit"} {
+		keySource := md5.Sum([]byte(variant))
+		if key == hex.EncodeToString(keySource[:]) {
+			return false
+		}
+	}
+	return true
+}
+
+// conflictCopyTitle names the sidecar copy while keeping the extension so
+// Drive and the integration still recognize the format.
+func conflictCopyTitle(title string) string {
+	if dot := strings.LastIndex(title, "."); dot > 0 {
+		return fmt.Sprintf("%s (conflict copy)%s", title[:dot], title[dot:])
+	}
+	return fmt.Sprintf("%s (conflict copy)", title)
+}
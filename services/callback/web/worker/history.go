@@ -0,0 +1,127 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+// HistoryRecord is the sidecar document persisted per saved revision so a
+// future history endpoint can restore previous versions with their
+// changes.
+type HistoryRecord struct {
+	FileID     string          `json:"file_id"`
+	Key        string          `json:"key"`
+	ChangesURL string          `json:"changes_url"`
+	History    json.RawMessage `json:"history,omitempty"`
+	SavedAt    int64           `json:"saved_at"`
+}
+
+// historyDrive is the subset of Drive calls the history store needs. It is
+// an interface so tests can run against a fake client.
+type historyDrive interface {
+	Insert(file *drive.File, content io.Reader) (*drive.File, error)
+	List(query string) ([]*drive.File, error)
+	Delete(id string) error
+}
+
+// driveHistoryClient adapts *drive.Service to historyDrive.
+type driveHistoryClient struct {
+	srv *drive.Service
+}
+
+func (c driveHistoryClient) Insert(file *drive.File, content io.Reader) (*drive.File, error) {
+	return c.srv.Files.Insert(file).Media(content).Do()
+}
+
+func (c driveHistoryClient) List(query string) ([]*drive.File, error) {
+	list, err := c.srv.Files.List().Q(query).Do()
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (c driveHistoryClient) Delete(id string) error {
+	return c.srv.Files.Delete(id).Do()
+}
+
+// historyTitle names a record file so listings can be filtered per source
+// file and ordered by save time.
+func historyTitle(fileID string, savedAt int64) string {
+	return fmt.Sprintf("%s-%d.history.json", fileID, savedAt)
+}
+
+// historyQuery lists the record files belonging to a source file inside the
+// application data folder.
+func historyQuery(fileID string) string {
+	return fmt.Sprintf(
+		"'appDataFolder' in parents and title contains '%s-' and trashed = false",
+		fileID,
+	)
+}
+
+// saveHistoryRecord writes the record into the user's appDataFolder and
+// prunes the oldest records beyond the retention count.
+func saveHistoryRecord(client historyDrive, record HistoryRecord, retention int) error {
+	if record.SavedAt == 0 {
+		record.SavedAt = time.Now().Unix()
+	}
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Insert(&drive.File{
+		Title:    historyTitle(record.FileID, record.SavedAt),
+		MimeType: "application/json",
+		Parents:  []*drive.ParentReference{{Id: "appDataFolder"}},
+	}, bytes.NewReader(content)); err != nil {
+		return err
+	}
+
+	if retention < 1 {
+		return nil
+	}
+
+	records, err := client.List(historyQuery(record.FileID))
+	if err != nil {
+		return err
+	}
+
+	// Titles embed the save timestamp, so a lexicographic sort on equal-length
+	// ids orders records oldest first.
+	sort.Slice(records, func(i, j int) bool { return records[i].Title < records[j].Title })
+	for len(records) > retention {
+		if err := client.Delete(records[0].Id); err != nil {
+			return err
+		}
+		records = records[1:]
+	}
+
+	return nil
+}
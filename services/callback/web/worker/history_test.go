@@ -0,0 +1,138 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+// fakeHistoryDrive records inserted sidecar files in memory.
+type fakeHistoryDrive struct {
+	files  map[string]*drive.File
+	bodies map[string][]byte
+	nextID int
+}
+
+func newFakeHistoryDrive() *fakeHistoryDrive {
+	return &fakeHistoryDrive{
+		files:  make(map[string]*drive.File),
+		bodies: make(map[string][]byte),
+	}
+}
+
+func (f *fakeHistoryDrive) Insert(file *drive.File, content io.Reader) (*drive.File, error) {
+	body, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	f.nextID++
+	file.Id = fmt.Sprintf("sidecar-%d", f.nextID)
+	f.files[file.Id] = file
+	f.bodies[file.Id] = body
+	return file, nil
+}
+
+func (f *fakeHistoryDrive) List(query string) ([]*drive.File, error) {
+	var items []*drive.File
+	for _, file := range f.files {
+		// The fake only understands the "title contains" fragment the store
+		// uses to scope records to one source file.
+		start := strings.Index(query, "title contains '")
+		fragment := query[start+len("title contains '"):]
+		fragment = fragment[:strings.Index(fragment, "'")]
+		if strings.Contains(file.Title, fragment) {
+			items = append(items, file)
+		}
+	}
+	return items, nil
+}
+
+func (f *fakeHistoryDrive) Delete(id string) error {
+	if _, ok := f.files[id]; !ok {
+		return fmt.Errorf("file %s does not exist", id)
+	}
+	delete(f.files, id)
+	delete(f.bodies, id)
+	return nil
+}
+
+func TestSaveHistoryRecord(t *testing.T) {
+	client := newFakeHistoryDrive()
+
+	err := saveHistoryRecord(client, HistoryRecord{
+		FileID:     "source",
+		Key:        "rev-key",
+		ChangesURL: "https://ds/changes.zip",
+		History:    json.RawMessage(`{"serverVersion":"7.3"}`),
+		SavedAt:    100,
+	}, 10)
+	if err != nil {
+		t.Fatalf("save failed: %s", err.Error())
+	}
+
+	if len(client.files) != 1 {
+		t.Fatalf("expected one sidecar file, got %d", len(client.files))
+	}
+	for id, file := range client.files {
+		if file.Title != "source-100.history.json" {
+			t.Errorf("unexpected sidecar title %q", file.Title)
+		}
+		if file.Parents[0].Id != "appDataFolder" {
+			t.Errorf("sidecar must live in appDataFolder, got %q", file.Parents[0].Id)
+		}
+
+		var record HistoryRecord
+		if err := json.Unmarshal(client.bodies[id], &record); err != nil {
+			t.Fatalf("sidecar body is not valid json: %s", err.Error())
+		}
+		if record.ChangesURL != "https://ds/changes.zip" || record.Key != "rev-key" {
+			t.Errorf("sidecar lost callback data: %+v", record)
+		}
+	}
+}
+
+func TestSaveHistoryRecordRetention(t *testing.T) {
+	client := newFakeHistoryDrive()
+
+	for i := int64(1); i <= 4; i++ {
+		err := saveHistoryRecord(client, HistoryRecord{
+			FileID:     "source",
+			ChangesURL: "https://ds/changes.zip",
+			SavedAt:    100 + i,
+		}, 2)
+		if err != nil {
+			t.Fatalf("save %d failed: %s", i, err.Error())
+		}
+	}
+
+	if len(client.files) != 2 {
+		t.Fatalf("expected retention to keep 2 records, got %d", len(client.files))
+	}
+	for _, file := range client.files {
+		if file.Title != "source-103.history.json" && file.Title != "source-104.history.json" {
+			t.Errorf("expected only the newest records to survive, found %q", file.Title)
+		}
+	}
+}
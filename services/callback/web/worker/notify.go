@@ -0,0 +1,166 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/option"
+)
+
+// retryTokenTTL bounds how long a dropped upload can be retried. The
+// Document Server only keeps saved revision URLs alive for a limited window,
+// so an older link would re-enqueue a job that cannot succeed.
+const retryTokenTTL = 24 * time.Hour
+
+// notifyDrive is the single Drive call the failure notification needs,
+// behind an interface so tests can run against a fake client.
+type notifyDrive interface {
+	InsertComment(fileID, content string) error
+}
+
+// driveNotifyClient adapts *drive.Service to notifyDrive.
+type driveNotifyClient struct {
+	srv *drive.Service
+}
+
+func (c driveNotifyClient) InsertComment(fileID, content string) error {
+	_, err := c.srv.Comments.Insert(fileID, &drive.Comment{Content: content}).Do()
+	return err
+}
+
+// buildRetryURL signs the dropped job into a retry link against the
+// callback service's public URL.
+func buildRetryURL(
+	jwtManager crypto.JwtManager, secret, callbackURL string,
+	job UploadRequest, now time.Time,
+) (string, error) {
+	token, err := jwtManager.Sign(secret, shared.UploadRetryToken{
+		UID:        job.UID,
+		FileID:     job.FileID,
+		URL:        job.URL,
+		Key:        job.Key,
+		ChangesURL: job.ChangesURL,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(retryTokenTTL)),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"%s/callback/retry?token=%s",
+		strings.TrimSuffix(callbackURL, "/"), url.QueryEscape(token),
+	), nil
+}
+
+// uploadFailureComment is the user-facing notice posted on the file. It
+// names the failure and carries the retry link; Drive shows the comment to
+// everyone the file is shared with, so it stays free of internals.
+func uploadFailureComment(retryURL string) string {
+	return fmt.Sprintf(
+		"ONLYOFFICE could not save the latest edits of this document back to "+
+			"Google Drive. The saved revision is kept by the editing server for a "+
+			"limited time; open %s to retry the upload.",
+		retryURL,
+	)
+}
+
+// NotifyUploadFailed posts the failure comment for a finally dropped upload
+// job. The signature matches the in-process worker's drop hook. Everything
+// here is best effort: notification failures are logged, never retried.
+func (w CallbackWorker) NotifyUploadFailed(payload []byte, cause error) {
+	if !w.onlyoffice.Config().Onlyoffice.Callback.Notifications {
+		return
+	}
+
+	var job UploadRequest
+	if err := json.Unmarshal(payload, &job); err != nil {
+		w.logger.Warnf("could not decode a dropped upload job: %s", err.Error())
+		return
+	}
+
+	w.logger.Warnf(
+		"upload of file %s for user %s was dropped: %s",
+		job.FileID, job.UID, cause.Error(),
+	)
+
+	tctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var ures response.UserResponse
+	if err := w.client.Call(tctx, w.client.NewRequest(
+		fmt.Sprintf("%s:auth", w.server.Namespace), "UserSelectHandler.GetUser",
+		job.UID,
+	), &ures); err != nil {
+		w.logger.Warnf("could not get user %s for a failure notice: %s", job.UID, err.Error())
+		return
+	}
+
+	gclient := (&oauth2.Config{
+		ClientID:     w.credentials.Credentials.ClientID,
+		ClientSecret: w.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(tctx, &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	srv, err := drive.NewService(tctx, option.WithHTTPClient(gclient))
+	if err != nil {
+		w.logger.Warnf("could not initialize drive service for a failure notice: %s", err.Error())
+		return
+	}
+
+	if err := w.notifyUploadFailure(driveNotifyClient{srv: srv}, job); err != nil {
+		w.logger.Warnf("could not post a failure notice on %s: %s", job.FileID, err.Error())
+	}
+}
+
+func (w CallbackWorker) notifyUploadFailure(client notifyDrive, job UploadRequest) error {
+	retryURL, err := buildRetryURL(
+		w.jwtManager, w.credentials.Credentials.ClientSecret,
+		w.onlyoffice.Config().Onlyoffice.Builder.CallbackURL, job, time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	w.monitor.Observe("comments.insert")
+	if err := client.InsertComment(job.FileID, uploadFailureComment(retryURL)); err != nil {
+		w.monitor.ObserveError("comments.insert", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/quota"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// The monitor registers prometheus collectors, so the test binary builds it
+// once.
+var notifyTestMonitor = quota.NewMonitor(1000, 0.9)
+
+type fakeJwtManager struct {
+	token  string
+	err    error
+	claims jwt.Claims
+}
+
+func (f *fakeJwtManager) Sign(secret string, payload jwt.Claims) (string, error) {
+	f.claims = payload
+	if f.err != nil {
+		return "", f.err
+	}
+	return f.token, nil
+}
+
+func (f *fakeJwtManager) Verify(secret, token string, body interface{}) error {
+	return nil
+}
+
+type fakeNotifyDrive struct {
+	comments []string
+	err      error
+}
+
+func (f *fakeNotifyDrive) InsertComment(fileID, content string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.comments = append(f.comments, content)
+	return nil
+}
+
+func newNotifyTestWorker(t *testing.T, notifications bool, manager *fakeJwtManager) CallbackWorker {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := fmt.Sprintf(`onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+  callback:
+    notifications: %t
+`, notifications)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := &shared.CredentialsConfig{}
+	credentials.Credentials.ClientSecret = "client-secret"
+	return CallbackWorker{
+		jwtManager:  manager,
+		credentials: credentials,
+		onlyoffice:  source,
+		monitor:     notifyTestMonitor,
+	}
+}
+
+// Notifications are off unless the install opts in; the hook must return
+// before touching the auth service or Drive, which the nil client would
+// otherwise panic on.
+func TestNotifyUploadFailedDisabledByDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if source.Config().Onlyoffice.Callback.Notifications {
+		t.Fatal("notifications must be disabled by default")
+	}
+
+	w := CallbackWorker{onlyoffice: source}
+	payload, _ := json.Marshal(UploadRequest{UID: "1000000000", FileID: "file-id-0123456789"})
+	w.NotifyUploadFailed(payload, errors.New("upload failed"))
+}
+
+func TestNotifyUploadFailurePostsRetryComment(t *testing.T) {
+	manager := &fakeJwtManager{token: "retry-token"}
+	w := newNotifyTestWorker(t, true, manager)
+
+	client := &fakeNotifyDrive{}
+	job := UploadRequest{
+		UID:    "1000000000",
+		FileID: "file-id-0123456789",
+		URL:    "https://ds.example.com/cache/file.docx",
+	}
+	if err := w.notifyUploadFailure(client, job); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.comments) != 1 {
+		t.Fatalf("expected one comment, got %d", len(client.comments))
+	}
+	if !strings.Contains(client.comments[0], "https://callback.example.com/callback/retry?token=retry-token") {
+		t.Errorf("expected the retry link in the comment, got %q", client.comments[0])
+	}
+
+	token, ok := manager.claims.(shared.UploadRetryToken)
+	if !ok {
+		t.Fatalf("expected a retry token, got %T", manager.claims)
+	}
+	if token.UID != job.UID || token.FileID != job.FileID || token.URL != job.URL {
+		t.Errorf("retry token does not carry the job: %+v", token)
+	}
+	if token.ExpiresAt == nil || token.IssuedAt == nil ||
+		token.ExpiresAt.Sub(token.IssuedAt.Time) != retryTokenTTL {
+		t.Errorf("expected a %s expiry window, got %v %v", retryTokenTTL, token.IssuedAt, token.ExpiresAt)
+	}
+}
+
+func TestBuildRetryURLEscapesToken(t *testing.T) {
+	manager := &fakeJwtManager{token: "a/b+c"}
+	url, err := buildRetryURL(
+		manager, "client-secret", "https://callback.example.com/",
+		UploadRequest{UID: "1000000000", FileID: "file-id-0123456789"}, time.Now(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://callback.example.com/callback/retry?token=a%2Fb%2Bc" {
+		t.Errorf("unexpected retry url: %s", url)
+	}
+}
@@ -0,0 +1,131 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/option"
+)
+
+// maxUploaderAttempts caps how many callback users are probed before the
+// upload is given up; a busy co-editing session can list many users, and
+// each probe costs an auth lookup and a Drive call.
+const maxUploaderAttempts = 3
+
+// uploaderProbe reports whether one candidate's stored credentials can
+// write the file. It is injected so tests can fail specific candidates.
+type uploaderProbe func(ctx context.Context, uid, fileID string) (bool, error)
+
+// selectUploader picks the first callback user whose credentials can still
+// write the file. The Document Server lists everyone who edited, so when
+// the first user's token was revoked mid-session another editor can often
+// finish the upload. A single candidate is returned untouched: the probe
+// would only add a Drive round trip before the same failure surfaces on
+// the upload itself.
+func selectUploader(
+	ctx context.Context, candidates []string, fileID string,
+	probe uploaderProbe, logger log.Logger,
+) (string, error) {
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+
+	attempts := candidates
+	if len(attempts) > maxUploaderAttempts {
+		attempts = attempts[:maxUploaderAttempts]
+	}
+
+	var lastErr error
+	for _, uid := range attempts {
+		writable, err := probe(ctx, uid, fileID)
+		if err != nil {
+			logger.Debugf("upload candidate %s failed the probe for %s: %s", uid, fileID, err.Error())
+			lastErr = err
+			continue
+		}
+		if writable {
+			return uid, nil
+		}
+		lastErr = fmt.Errorf("user %s cannot write file %s", uid, fileID)
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no upload candidates for file %s", fileID)
+	}
+	return "", lastErr
+}
+
+// fetchUsers resolves every candidate's credentials in one auth round
+// trip. Candidates whose records are missing or broken are simply absent
+// from the map; only a failure of the batch call itself is an error.
+func (w CallbackWorker) fetchUsers(ctx context.Context, uids []string) (map[string]response.UserResponse, error) {
+	var users response.UsersResponse
+	if err := w.client.Call(ctx, w.client.NewRequest(
+		fmt.Sprintf("%s:auth", w.server.Namespace), "UserSelectHandler.GetUsers",
+		uids,
+	), &users); err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[string]response.UserResponse, len(users.Users))
+	for _, entry := range users.Users {
+		if entry.Status == response.UserBatchOK && entry.User != nil {
+			resolved[entry.ID] = *entry.User
+		}
+	}
+	return resolved, nil
+}
+
+// probeUploader checks the file's capabilities for one candidate with a
+// metadata-only Drive call, using credentials the batch lookup already
+// resolved.
+func (w CallbackWorker) probeUploader(ctx context.Context, ures response.UserResponse, fileID string) (bool, error) {
+	gclient := (&oauth2.Config{
+		ClientID:     w.credentials.Credentials.ClientID,
+		ClientSecret: w.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(ctx, &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(gclient))
+	if err != nil {
+		return false, err
+	}
+
+	w.monitor.Observe("files.get")
+	file, err := srv.Files.Get(fileID).Fields("capabilities").Do()
+	if err != nil {
+		w.monitor.ObserveError("files.get", err)
+		return false, err
+	}
+
+	// Files without a capabilities block predate the field; Drive only
+	// returns them for the owner.
+	return file.Capabilities == nil || file.Capabilities.CanEdit, nil
+}
@@ -0,0 +1,114 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// testLogger swallows worker logging in tests.
+type testLogger struct{}
+
+func (testLogger) Debugf(format string, args ...interface{}) {}
+func (testLogger) Infof(format string, args ...interface{})  {}
+func (testLogger) Warnf(format string, args ...interface{})  {}
+func (testLogger) Errorf(format string, args ...interface{}) {}
+func (testLogger) Fatalf(format string, args ...interface{}) {}
+
+func TestSelectUploaderFallsBackToNextCandidate(t *testing.T) {
+	probed := []string{}
+	probe := func(ctx context.Context, uid, fileID string) (bool, error) {
+		probed = append(probed, uid)
+		if uid == "user-1" {
+			return false, errors.New("token has been revoked")
+		}
+		return true, nil
+	}
+
+	uid, err := selectUploader(
+		context.Background(), []string{"user-1", "user-2", "user-3"},
+		"file-id", probe, testLogger{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid != "user-2" {
+		t.Errorf("expected user-2 to be selected, got %s", uid)
+	}
+	if len(probed) != 2 {
+		t.Errorf("expected 2 probes, got %d", len(probed))
+	}
+}
+
+func TestSelectUploaderSkipsReadOnlyCandidates(t *testing.T) {
+	probe := func(ctx context.Context, uid, fileID string) (bool, error) {
+		return uid == "user-2", nil
+	}
+
+	uid, err := selectUploader(
+		context.Background(), []string{"user-1", "user-2"},
+		"file-id", probe, testLogger{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid != "user-2" {
+		t.Errorf("expected user-2 to be selected, got %s", uid)
+	}
+}
+
+// A single candidate keeps the previous behavior: no probe, the upload
+// itself surfaces any credential problem.
+func TestSelectUploaderSingleCandidateSkipsProbe(t *testing.T) {
+	probe := func(ctx context.Context, uid, fileID string) (bool, error) {
+		t.Fatal("the probe must not run for a single candidate")
+		return false, nil
+	}
+
+	uid, err := selectUploader(
+		context.Background(), []string{"user-1"}, "file-id", probe, testLogger{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid != "user-1" {
+		t.Errorf("expected user-1 to be selected, got %s", uid)
+	}
+}
+
+func TestSelectUploaderCapsAttempts(t *testing.T) {
+	probes := 0
+	probe := func(ctx context.Context, uid, fileID string) (bool, error) {
+		probes++
+		return false, errors.New("token has been revoked")
+	}
+
+	if _, err := selectUploader(
+		context.Background(),
+		[]string{"user-1", "user-2", "user-3", "user-4", "user-5"},
+		"file-id", probe, testLogger{},
+	); err == nil {
+		t.Error("expected an error when every candidate fails")
+	}
+	if probes != maxUploaderAttempts {
+		t.Errorf("expected %d probes, got %d", maxUploaderAttempts, probes)
+	}
+}
@@ -0,0 +1,252 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/quota"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/timings"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"go-micro.dev/v4/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/option"
+)
+
+// UploadRequest is the payload of a deferred Drive upload job enqueued by
+// the callback controller when the Document Server reports a saved
+// document.
+type UploadRequest struct {
+	UID string `json:"uid"`
+	// Users lists every callback user beyond UID, in the Document Server's
+	// order, as fallback identities when UID's token no longer works.
+	Users      []string        `json:"users,omitempty"`
+	FileID     string          `json:"file_id"`
+	URL        string          `json:"url"`
+	Key        string          `json:"key,omitempty"`
+	ChangesURL string          `json:"changes_url,omitempty"`
+	History    json.RawMessage `json:"history,omitempty"`
+}
+
+// CallbackWorker downloads saved document revisions from the Document
+// Server and writes them back to Drive.
+type CallbackWorker struct {
+	client      client.Client
+	jwtManager  crypto.JwtManager
+	fileUtility files.OnlyofficeFileUtility
+	server      *config.ServerConfig
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	flags       shared.Flags
+	monitor     *quota.Monitor
+	timings     *timings.Recorder
+	logger      log.Logger
+}
+
+func NewCallbackWorker(
+	client client.Client, jwtManager crypto.JwtManager,
+	fileUtility files.OnlyofficeFileUtility,
+	server *config.ServerConfig, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource, flags shared.Flags,
+	monitor *quota.Monitor, recorder *timings.Recorder, logger log.Logger,
+) CallbackWorker {
+	return CallbackWorker{
+		client:      client,
+		jwtManager:  jwtManager,
+		fileUtility: fileUtility,
+		server:      server,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		flags:       flags,
+		monitor:     monitor,
+		timings:     recorder,
+		logger:      logger,
+	}
+}
+
+// UploadFile downloads the saved revision and updates the Drive file's
+// content.
+func (w CallbackWorker) UploadFile(ctx context.Context, payload []byte) error {
+	var job UploadRequest
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	w.logger.Debugf(
+		"uploading file %s for user %s from %s",
+		job.FileID, job.UID, shared.RedactURL(job.URL),
+	)
+
+	tctx, cancel := context.WithTimeout(
+		ctx, time.Duration(w.onlyoffice.Config().Onlyoffice.Callback.UploadTimeout)*time.Second,
+	)
+	defer cancel()
+
+	candidates := job.Users
+	if len(candidates) == 0 {
+		candidates = []string{job.UID}
+	}
+	// One batch lookup resolves every candidate's credentials; the probes
+	// and the upload itself reuse them instead of costing one auth RPC each.
+	users, err := w.fetchUsers(tctx, candidates)
+	if err != nil {
+		return err
+	}
+	probe := func(ctx context.Context, uid, fileID string) (bool, error) {
+		ures, ok := users[uid]
+		if !ok {
+			return false, fmt.Errorf("no stored credentials for user %s", uid)
+		}
+		return w.probeUploader(ctx, ures, fileID)
+	}
+	uid, err := selectUploader(tctx, candidates, job.FileID, probe, w.logger)
+	if err != nil {
+		return err
+	}
+	if uid != job.UID {
+		w.logger.Infof("uploading file %s as fallback user %s", job.FileID, uid)
+	}
+	job.UID = uid
+
+	ures, ok := users[job.UID]
+	if !ok {
+		return fmt.Errorf("no stored credentials for user %s", job.UID)
+	}
+
+	// The save is timed from fetching the revision to the Drive write
+	// landing, against the bytes actually streamed, for the
+	// capacity-planning histograms.
+	saveStart := time.Now()
+
+	req, err := http.NewRequestWithContext(tctx, http.MethodGet, job.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", buildinfo.UserAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// A Document Server error page streamed into Files.Update would replace
+	// the document's content with HTML; anything but 200 fails the job so
+	// the retry machinery gets to handle it.
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf(
+			"document server answered %d for revision %s",
+			resp.StatusCode, shared.RedactURL(job.URL),
+		)
+	}
+	counted := timings.NewCountingReader(resp.Body)
+
+	gclient := (&oauth2.Config{
+		ClientID:     w.credentials.Credentials.ClientID,
+		ClientSecret: w.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(tctx, &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	srv, err := drive.NewService(tctx, option.WithHTTPClient(gclient))
+	if err != nil {
+		return err
+	}
+
+	// Worker uploads are background traffic and yield to interactive calls
+	// when the quota is nearly exhausted.
+	w.monitor.Throttle(tctx, false)
+	w.monitor.Observe("files.get")
+	file, err := srv.Files.Get(job.FileID).Do()
+	if err != nil {
+		w.monitor.ObserveError("files.get", err)
+		return err
+	}
+
+	// A stale editor key means the file changed in Drive while the editor
+	// was open; with conflict copies rolled out the revision is stored next
+	// to the file instead of silently overwriting the newer content.
+	if w.flags.Enabled(shared.FlagConflictCopies, job.UID, false) && conflictingKey(job.Key, file) {
+		w.logger.Warnf("file %s changed during editing, storing a conflict copy", job.FileID)
+		w.monitor.Observe("files.insert")
+		if _, err := srv.Files.Insert(&drive.File{
+			Title: w.fileUtility.TruncateTitle(
+				conflictCopyTitle(file.Title),
+				w.onlyoffice.Config().Onlyoffice.Limits.MaxTitleLength,
+			),
+			Parents: file.Parents,
+		}).Media(counted).Do(); err != nil {
+			w.monitor.ObserveError("files.insert", err)
+			return err
+		}
+		w.recordSaveTiming(file, counted.Bytes(), time.Since(saveStart))
+		return nil
+	}
+
+	w.monitor.Observe("files.update")
+	if _, err := srv.Files.Update(job.FileID, file).Media(counted).Do(); err != nil {
+		w.monitor.ObserveError("files.update", err)
+		return err
+	}
+	w.recordSaveTiming(file, counted.Bytes(), time.Since(saveStart))
+
+	// History storage is best effort: a failed sidecar write must not fail
+	// the upload the user cares about.
+	history := w.onlyoffice.Config().Onlyoffice.History
+	if history.Enabled && job.ChangesURL != "" {
+		w.monitor.Observe("files.insert")
+		if err := saveHistoryRecord(driveHistoryClient{srv: srv}, HistoryRecord{
+			FileID:     job.FileID,
+			Key:        job.Key,
+			ChangesURL: job.ChangesURL,
+			History:    job.History,
+		}, history.Retention); err != nil {
+			w.logger.Warnf("could not store history for %s: %s", job.FileID, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// recordSaveTiming feeds the save histogram once the revision is back in
+// Drive; files whose extension maps onto no document family are skipped.
+func (w CallbackWorker) recordSaveTiming(file *drive.File, bytes int64, elapsed time.Duration) {
+	if w.timings == nil {
+		return
+	}
+	ext, _ := shared.EffectiveExtension(file.FileExtension, file.MimeType)
+	family, err := w.fileUtility.GetFileType(ext)
+	if err != nil {
+		return
+	}
+	w.timings.Observe(timings.OperationSave, family, bytes, elapsed)
+}
@@ -0,0 +1,147 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package doctor runs a full document round trip against a live deployment
+// so operators can verify an install before opening traffic: create a temp
+// Drive file, build an editor config, download through the gateway, simulate
+// a Document Server save callback and verify Drive received the update.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// RoundTrip holds one closure per smoke-test step. The closures carry their
+// own clients, so the runner stays independent of the wiring and tests can
+// fake any step.
+type RoundTrip struct {
+	// CreateFile seeds the temp document and returns its Drive id.
+	CreateFile func(ctx context.Context) (string, error)
+	// BuildConfig asks the builder service for an editor config.
+	BuildConfig func(ctx context.Context, fileID string) error
+	// DownloadFile fetches the content through the gateway download
+	// endpoint with a freshly signed token.
+	DownloadFile func(ctx context.Context, fileID string) error
+	// SimulateCallback posts a signed save callback to the callback
+	// service, the way the Document Server reports a closed editor.
+	SimulateCallback func(ctx context.Context, fileID string) error
+	// VerifyUpdate checks that Drive received the uploaded revision.
+	VerifyUpdate func(ctx context.Context, fileID string) error
+	// Cleanup removes the temp document. It runs whenever a file was
+	// created, even after an earlier step failed.
+	Cleanup func(ctx context.Context, fileID string) error
+
+	now func() time.Time
+}
+
+// Result is the outcome of one step.
+type Result struct {
+	Name     string
+	Duration time.Duration
+	Skipped  bool
+	Err      error
+}
+
+// Report collects step results in execution order.
+type Report struct {
+	Results []Result
+}
+
+// Passed reports whether every executed step succeeded.
+func (r Report) Passed() bool {
+	for _, result := range r.Results {
+		if result.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Write renders the step-by-step pass/fail report with timings.
+func (r Report) Write(w io.Writer) {
+	for _, result := range r.Results {
+		switch {
+		case result.Skipped:
+			fmt.Fprintf(w, "SKIP %-20s\n", result.Name)
+		case result.Err != nil:
+			fmt.Fprintf(w, "FAIL %-20s %8s  %s\n", result.Name, result.Duration.Round(time.Millisecond), result.Err.Error())
+		default:
+			fmt.Fprintf(w, "PASS %-20s %8s\n", result.Name, result.Duration.Round(time.Millisecond))
+		}
+	}
+	if r.Passed() {
+		fmt.Fprintln(w, "doctor: all checks passed")
+	} else {
+		fmt.Fprintln(w, "doctor: FAILED")
+	}
+}
+
+// Run executes the round trip. A failing step skips the remaining
+// verification steps, but cleanup still runs when a temp file exists.
+func (t RoundTrip) Run(ctx context.Context) Report {
+	if t.now == nil {
+		t.now = time.Now
+	}
+
+	var report Report
+	var fileID string
+
+	run := func(name string, failed bool, step func(ctx context.Context) error) bool {
+		if failed {
+			report.Results = append(report.Results, Result{Name: name, Skipped: true})
+			return true
+		}
+		started := t.now()
+		err := step(ctx)
+		report.Results = append(report.Results, Result{
+			Name:     name,
+			Duration: t.now().Sub(started),
+			Err:      err,
+		})
+		return err != nil
+	}
+
+	failed := run("create file", false, func(ctx context.Context) error {
+		id, err := t.CreateFile(ctx)
+		fileID = id
+		return err
+	})
+	failed = run("build config", failed, func(ctx context.Context) error {
+		return t.BuildConfig(ctx, fileID)
+	}) || failed
+	failed = run("download file", failed, func(ctx context.Context) error {
+		return t.DownloadFile(ctx, fileID)
+	}) || failed
+	failed = run("simulate callback", failed, func(ctx context.Context) error {
+		return t.SimulateCallback(ctx, fileID)
+	}) || failed
+	run("verify update", failed, func(ctx context.Context) error {
+		return t.VerifyUpdate(ctx, fileID)
+	})
+
+	if fileID != "" {
+		run("cleanup", false, func(ctx context.Context) error {
+			return t.Cleanup(ctx, fileID)
+		})
+	}
+
+	return report
+}
@@ -0,0 +1,148 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package doctor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTrip records which steps ran and fails the one named in failAt.
+type fakeTrip struct {
+	failAt string
+	ran    []string
+}
+
+func (f *fakeTrip) step(name string) func(ctx context.Context, fileID string) error {
+	return func(ctx context.Context, fileID string) error {
+		f.ran = append(f.ran, name)
+		if f.failAt == name {
+			return errors.New(name + " failed")
+		}
+		return nil
+	}
+}
+
+func (f *fakeTrip) roundTrip() RoundTrip {
+	return RoundTrip{
+		CreateFile: func(ctx context.Context) (string, error) {
+			f.ran = append(f.ran, "create")
+			if f.failAt == "create" {
+				return "", errors.New("create failed")
+			}
+			return "file-id", nil
+		},
+		BuildConfig:      f.step("config"),
+		DownloadFile:     f.step("download"),
+		SimulateCallback: f.step("callback"),
+		VerifyUpdate:     f.step("verify"),
+		Cleanup:          f.step("cleanup"),
+	}
+}
+
+func TestRoundTripRunsAllStepsInOrder(t *testing.T) {
+	fake := &fakeTrip{}
+	report := fake.roundTrip().Run(context.Background())
+
+	if !report.Passed() {
+		t.Error("expected the report to pass")
+	}
+	if len(report.Results) != 6 {
+		t.Fatalf("expected 6 results, got %d", len(report.Results))
+	}
+
+	want := []string{"create", "config", "download", "callback", "verify", "cleanup"}
+	for i, name := range want {
+		if fake.ran[i] != name {
+			t.Errorf("step %d: expected %s, ran %s", i, name, fake.ran[i])
+		}
+	}
+}
+
+func TestRoundTripFailureSkipsLaterStepsButCleansUp(t *testing.T) {
+	fake := &fakeTrip{failAt: "download"}
+	report := fake.roundTrip().Run(context.Background())
+
+	if report.Passed() {
+		t.Error("expected the report to fail")
+	}
+	for _, name := range []string{"callback", "verify"} {
+		for _, ran := range fake.ran {
+			if ran == name {
+				t.Errorf("step %s should have been skipped", name)
+			}
+		}
+	}
+	if fake.ran[len(fake.ran)-1] != "cleanup" {
+		t.Error("cleanup should still run after a failed step")
+	}
+
+	skipped := 0
+	for _, result := range report.Results {
+		if result.Skipped {
+			skipped++
+		}
+	}
+	if skipped != 2 {
+		t.Errorf("expected 2 skipped results, got %d", skipped)
+	}
+}
+
+func TestRoundTripCreateFailureSkipsCleanup(t *testing.T) {
+	fake := &fakeTrip{failAt: "create"}
+	fake.roundTrip().Run(context.Background())
+
+	for _, ran := range fake.ran {
+		if ran == "cleanup" {
+			t.Error("cleanup must not run when no file was created")
+		}
+	}
+}
+
+func TestReportWriteRendersTimings(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fake := &fakeTrip{failAt: "callback"}
+	trip := fake.roundTrip()
+	// Every now() call advances the clock, so each executed step reports
+	// a deterministic one-second duration.
+	trip.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	var out strings.Builder
+	trip.Run(context.Background()).Write(&out)
+	rendered := out.String()
+
+	for _, line := range []string{
+		"PASS create file",
+		"FAIL simulate callback",
+		"SKIP verify update",
+		"PASS cleanup",
+		"1s",
+		"doctor: FAILED",
+	} {
+		if !strings.Contains(rendered, line) {
+			t.Errorf("report is missing %q:\n%s", line, rendered)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/controller"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"go-micro.dev/v4/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/option"
+)
+
+// liveRoundTrip carries the shared state between live steps: the Drive
+// client, the seeded file's metadata and the builder's editor config.
+type liveRoundTrip struct {
+	client      client.Client
+	jwtManager  crypto.JwtManager
+	server      *config.ServerConfig
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	sources     controller.SourceURLService
+
+	// uid identifies the test user in the auth service; the gateway
+	// download endpoint and the simulated callback resolve credentials
+	// through it, so the user must be registered even when refreshToken
+	// overrides the Drive client the doctor itself uses.
+	uid          string
+	refreshToken string
+
+	drive       *drive.Service
+	httpClient  *http.Client
+	seeded      *drive.File
+	config      response.BuildConfigResponse
+	callbackURL string
+}
+
+// NewRoundTrip wires the live smoke-test steps around the same clients the
+// gateway handlers use: the auth and builder services over RPC, the Drive
+// API with the stored (or provided) credentials and the deployment's own
+// HTTP endpoints.
+func NewRoundTrip(
+	client client.Client, jwtManager crypto.JwtManager,
+	server *config.ServerConfig, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource,
+	sources controller.SourceURLService, uid, refreshToken string,
+) *RoundTrip {
+	live := &liveRoundTrip{
+		client:       client,
+		jwtManager:   jwtManager,
+		server:       server,
+		credentials:  credentials,
+		onlyoffice:   onlyoffice,
+		sources:      sources,
+		uid:          uid,
+		refreshToken: refreshToken,
+	}
+
+	return &RoundTrip{
+		CreateFile:       live.createFile,
+		BuildConfig:      live.buildConfig,
+		DownloadFile:     live.downloadFile,
+		SimulateCallback: live.simulateCallback,
+		VerifyUpdate:     live.verifyUpdate,
+		Cleanup:          live.cleanup,
+	}
+}
+
+// ensureDrive initializes the Drive client once. A provided refresh token
+// wins over the stored credentials, so the doctor can run against a user
+// whose access token has already expired.
+func (t *liveRoundTrip) ensureDrive(ctx context.Context) error {
+	if t.drive != nil {
+		return nil
+	}
+
+	token := &oauth2.Token{RefreshToken: t.refreshToken}
+	if t.refreshToken == "" {
+		var ures response.UserResponse
+		if err := t.client.Call(ctx, t.client.NewRequest(
+			fmt.Sprintf("%s:auth", t.server.Namespace), "UserSelectHandler.GetUser",
+			t.uid,
+		), &ures); err != nil {
+			return fmt.Errorf("could not get user %s access info: %w", t.uid, err)
+		}
+		token = &oauth2.Token{
+			AccessToken:  ures.AccessToken,
+			RefreshToken: ures.RefreshToken,
+			TokenType:    ures.TokenType,
+		}
+	}
+
+	t.httpClient = (&oauth2.Config{
+		ClientID:     t.credentials.Credentials.ClientID,
+		ClientSecret: t.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(ctx, token)
+
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(t.httpClient))
+	if err != nil {
+		return fmt.Errorf("could not initialize drive service: %w", err)
+	}
+	t.drive = srv
+	return nil
+}
+
+func (t *liveRoundTrip) createFile(ctx context.Context) (string, error) {
+	if err := t.ensureDrive(ctx); err != nil {
+		return "", err
+	}
+
+	template, err := embeddable.OfficeTemplate("en-US", "docx")
+	if err != nil {
+		return "", err
+	}
+
+	file, err := t.drive.Files.Insert(&drive.File{
+		Title: fmt.Sprintf("onlyoffice-doctor-%s.docx", uuid.NewString()),
+	}).Media(template).Do()
+	if err != nil {
+		return "", fmt.Errorf("could not create a temp file: %w", err)
+	}
+
+	t.seeded = file
+	return file.Id, nil
+}
+
+func (t *liveRoundTrip) buildConfig(ctx context.Context, fileID string) error {
+	if err := t.client.Call(ctx, t.client.NewRequest(
+		fmt.Sprintf("%s:builder", t.server.Namespace), "ConfigHandler.BuildConfig",
+		request.ConfigRequest{
+			UID:       t.uid,
+			FileID:    fileID,
+			UserAgent: "onlyoffice-doctor",
+			ForceEdit: true,
+		},
+	), &t.config); err != nil {
+		return fmt.Errorf("could not build an editor config: %w", err)
+	}
+
+	if t.config.Document.Key == "" {
+		return fmt.Errorf("the builder returned a config without a document key")
+	}
+	t.callbackURL = t.config.EditorConfig.CallbackURL
+	return nil
+}
+
+func (t *liveRoundTrip) downloadFile(ctx context.Context, fileID string) error {
+	downloadURL, err := t.sources.SourceURL(t.uid, fileID)
+	if err != nil {
+		return err
+	}
+
+	body, err := t.get(ctx, downloadURL)
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return fmt.Errorf("the gateway returned an empty document body")
+	}
+	return nil
+}
+
+// simulateCallback posts a status-2 save callback the way the Document
+// Server reports a closed editor. The document URL points back at the
+// gateway download endpoint, so the callback worker round-trips the same
+// content into Drive as a new revision.
+func (t *liveRoundTrip) simulateCallback(ctx context.Context, fileID string) error {
+	if t.callbackURL == "" {
+		return fmt.Errorf("no callback url; the config step did not run")
+	}
+
+	documentURL, err := t.sources.SourceURL(t.uid, fileID)
+	if err != nil {
+		return err
+	}
+
+	body := request.CallbackRequest{
+		Key:    t.config.Document.Key,
+		Status: 2,
+		Users:  []string{t.uid},
+		URL:    documentURL,
+	}
+	if secret := t.onlyoffice.Config().Onlyoffice.Builder.DocumentServerSecret; secret != "" {
+		token, err := t.jwtManager.Sign(secret, jwt.MapClaims{
+			"key":    body.Key,
+			"status": body.Status,
+			"users":  body.Users,
+			"url":    body.URL,
+		})
+		if err != nil {
+			return err
+		}
+		body.Token = token
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, t.callbackURL, bytes.NewReader(body.ToJSON()),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the callback service answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// verifyUpdate polls Drive until the seeded file reports a newer revision
+// than the one created by the seed step; the upload runs on the callback
+// service's background queue, so the change is not immediate.
+func (t *liveRoundTrip) verifyUpdate(ctx context.Context, fileID string) error {
+	for {
+		file, err := t.drive.Files.Get(fileID).Do()
+		if err != nil {
+			return fmt.Errorf("could not get the temp file: %w", err)
+		}
+		if file.ModifiedDate > t.seeded.ModifiedDate ||
+			(file.Md5Checksum != "" && file.Md5Checksum != t.seeded.Md5Checksum) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("drive never received the update: %w", ctx.Err())
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (t *liveRoundTrip) cleanup(ctx context.Context, fileID string) error {
+	if t.drive == nil {
+		return nil
+	}
+	return t.drive.Files.Delete(fileID).Do()
+}
+
+func (t *liveRoundTrip) get(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s answered %d", rawURL, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,148 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package janitor finds converted copies the integration created but nobody
+// ever touched. A conversion that succeeds right before the user abandons
+// the flow leaves an orphaned OOXML copy in Drive; the conversion pipeline
+// tags its inserts so a sweep can tell its own garbage from user files.
+package janitor
+
+import (
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+const (
+	// TagKey and TagValue mark a file as created by the conversion
+	// pipeline. Both travel as private Drive properties, so only this
+	// application sees them.
+	TagKey   = "onlyoffice-gdrive"
+	TagValue = "conversion"
+	// CreatedKey records the insert time next to the tag; the sweep falls
+	// back to Drive's own created date when the property is missing.
+	CreatedKey = "onlyoffice-gdrive-created"
+)
+
+// TagQuery is the Drive list query matching files the pipeline tagged.
+const TagQuery = "properties has { key='" + TagKey + "' and value='" + TagValue + "' and visibility='PRIVATE' } and trashed = false"
+
+// ConversionProperties returns the private properties the conversion
+// pipeline stamps onto a created copy.
+func ConversionProperties(now time.Time) []*drive.Property {
+	return []*drive.Property{
+		{Key: TagKey, Value: TagValue, Visibility: "PRIVATE"},
+		{Key: CreatedKey, Value: now.UTC().Format(time.RFC3339), Visibility: "PRIVATE"},
+	}
+}
+
+// modifiedSlack is how much the modified date may trail the recorded
+// creation before the file counts as edited. The insert itself moves the
+// modified date by a few seconds; a real edit moves it much further.
+const modifiedSlack = time.Minute
+
+// Expired reports whether file is sweep-eligible: tagged as a conversion
+// copy, older than maxAge and neither opened nor edited since it was
+// created. The viewed and modified dates come straight from Drive, so
+// activity through any client counts. Anything ambiguous is kept; deleting
+// a user's file is the one mistake a janitor must not make.
+func Expired(file *drive.File, now time.Time, maxAge time.Duration) bool {
+	tagged := false
+	created := ""
+	for _, property := range file.Properties {
+		switch property.Key {
+		case TagKey:
+			tagged = property.Value == TagValue
+		case CreatedKey:
+			created = property.Value
+		}
+	}
+	if !tagged {
+		return false
+	}
+
+	if file.LastViewedByMeDate != "" {
+		return false
+	}
+
+	if created == "" {
+		created = file.CreatedDate
+	}
+	when, err := time.Parse(time.RFC3339, created)
+	if err != nil {
+		return false
+	}
+
+	if modified, err := time.Parse(time.RFC3339, file.ModifiedDate); err == nil &&
+		modified.Sub(when) > modifiedSlack {
+		return false
+	}
+
+	return now.Sub(when) > maxAge
+}
+
+// Sweep walks one user's tagged conversion copies and trashes (or, in
+// dry-run mode, just reports) the ones Expired considers abandoned. The
+// Drive calls are injected so the filtering logic tests without a client.
+type Sweep struct {
+	// List returns one page of tagged files.
+	List func(pageToken string) (*drive.FileList, error)
+	// Remove moves the file to the trash; trashing instead of deleting
+	// keeps a wrongly collected file recoverable for thirty days.
+	Remove func(id string) error
+	// MaxAge is how old an untouched copy must be before it is collected.
+	MaxAge time.Duration
+	// DryRun lists the candidates without removing anything.
+	DryRun bool
+
+	now func() time.Time
+}
+
+// Run returns the files that matched the sweep. A removal error stops the
+// sweep and returns the files handled so far alongside it.
+func (s Sweep) Run() ([]*drive.File, error) {
+	if s.now == nil {
+		s.now = time.Now
+	}
+
+	var matched []*drive.File
+	pageToken := ""
+	for {
+		list, err := s.List(pageToken)
+		if err != nil {
+			return matched, err
+		}
+
+		for _, file := range list.Items {
+			if !Expired(file, s.now(), s.MaxAge) {
+				continue
+			}
+			if !s.DryRun {
+				if err := s.Remove(file.Id); err != nil {
+					return matched, err
+				}
+			}
+			matched = append(matched, file)
+		}
+
+		pageToken = list.NextPageToken
+		if pageToken == "" {
+			return matched, nil
+		}
+	}
+}
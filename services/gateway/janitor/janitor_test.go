@@ -0,0 +1,197 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package janitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestConversionProperties(t *testing.T) {
+	created := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	properties := ConversionProperties(created)
+
+	if len(properties) != 2 {
+		t.Fatalf("expected a tag and a created property, got %d", len(properties))
+	}
+	for _, property := range properties {
+		if property.Visibility != "PRIVATE" {
+			t.Errorf("property %s must be private, got %q", property.Key, property.Visibility)
+		}
+	}
+	if properties[0].Key != TagKey || properties[0].Value != TagValue {
+		t.Errorf("unexpected tag property: %+v", properties[0])
+	}
+	if properties[1].Key != CreatedKey || properties[1].Value != "2023-06-01T12:00:00Z" {
+		t.Errorf("unexpected created property: %+v", properties[1])
+	}
+}
+
+// taggedFile builds a conversion copy created at the given time; overrides
+// mutate the defaults per case.
+func taggedFile(created time.Time, overrides func(*drive.File)) *drive.File {
+	file := &drive.File{
+		Id:           "converted-copy",
+		Title:        "report.docx",
+		CreatedDate:  created.Format(time.RFC3339),
+		ModifiedDate: created.Add(5 * time.Second).Format(time.RFC3339),
+		Properties:   ConversionProperties(created),
+	}
+	if overrides != nil {
+		overrides(file)
+	}
+	return file
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)
+	maxAge := 30 * 24 * time.Hour
+	old := now.Add(-45 * 24 * time.Hour)
+	young := now.Add(-2 * 24 * time.Hour)
+
+	for name, tc := range map[string]struct {
+		file    *drive.File
+		expired bool
+	}{
+		"old untouched copy": {
+			file:    taggedFile(old, nil),
+			expired: true,
+		},
+		"untagged user file": {
+			file: taggedFile(old, func(f *drive.File) {
+				f.Properties = nil
+			}),
+			expired: false,
+		},
+		"copy the user opened": {
+			file: taggedFile(old, func(f *drive.File) {
+				f.LastViewedByMeDate = old.Add(time.Hour).Format(time.RFC3339)
+			}),
+			expired: false,
+		},
+		"copy the user edited": {
+			file: taggedFile(old, func(f *drive.File) {
+				f.ModifiedDate = old.Add(2 * time.Hour).Format(time.RFC3339)
+			}),
+			expired: false,
+		},
+		"copy still within the age window": {
+			file:    taggedFile(young, nil),
+			expired: false,
+		},
+		"missing created property falls back to the drive date": {
+			file: taggedFile(old, func(f *drive.File) {
+				f.Properties = f.Properties[:1]
+			}),
+			expired: true,
+		},
+		"unparsable created date is kept": {
+			file: taggedFile(old, func(f *drive.File) {
+				f.Properties[1].Value = "yesterday"
+			}),
+			expired: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := Expired(tc.file, now, maxAge); got != tc.expired {
+				t.Errorf("expected expired=%t, got %t", tc.expired, got)
+			}
+		})
+	}
+}
+
+func TestSweepFiltersAndRemovesAcrossPages(t *testing.T) {
+	now := time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-45 * 24 * time.Hour)
+
+	pages := map[string]*drive.FileList{
+		"": {
+			Items: []*drive.File{
+				taggedFile(old, func(f *drive.File) { f.Id = "abandoned-1" }),
+				taggedFile(old, func(f *drive.File) {
+					f.Id = "opened"
+					f.LastViewedByMeDate = old.Add(time.Hour).Format(time.RFC3339)
+				}),
+			},
+			NextPageToken: "page-2",
+		},
+		"page-2": {
+			Items: []*drive.File{
+				taggedFile(old, func(f *drive.File) { f.Id = "abandoned-2" }),
+			},
+		},
+	}
+
+	var removed []string
+	sweep := Sweep{
+		List:   func(pageToken string) (*drive.FileList, error) { return pages[pageToken], nil },
+		Remove: func(id string) error { removed = append(removed, id); return nil },
+		MaxAge: 30 * 24 * time.Hour,
+		now:    func() time.Time { return now },
+	}
+
+	matched, err := sweep.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched) != 2 || matched[0].Id != "abandoned-1" || matched[1].Id != "abandoned-2" {
+		t.Fatalf("unexpected matches: %v", matched)
+	}
+	if len(removed) != 2 {
+		t.Errorf("expected both abandoned copies removed, got %v", removed)
+	}
+
+	// The same sweep in dry-run mode reports without touching anything.
+	removed = nil
+	sweep.DryRun = true
+	if matched, err := sweep.Run(); err != nil || len(matched) != 2 {
+		t.Fatalf("unexpected dry run outcome: %v, %v", matched, err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("expected a dry run to remove nothing, got %v", removed)
+	}
+}
+
+func TestSweepStopsOnARemovalError(t *testing.T) {
+	now := time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-45 * 24 * time.Hour)
+
+	sweep := Sweep{
+		List: func(pageToken string) (*drive.FileList, error) {
+			return &drive.FileList{Items: []*drive.File{
+				taggedFile(old, func(f *drive.File) { f.Id = "abandoned-1" }),
+				taggedFile(old, func(f *drive.File) { f.Id = "abandoned-2" }),
+			}}, nil
+		},
+		Remove: func(id string) error { return errors.New("rate limit exceeded") },
+		MaxAge: 30 * 24 * time.Hour,
+		now:    func() time.Time { return now },
+	}
+
+	matched, err := sweep.Run()
+	if err == nil {
+		t.Fatal("expected the removal error to surface")
+	}
+	if len(matched) != 0 {
+		t.Errorf("expected no file reported as handled, got %v", matched)
+	}
+}
@@ -0,0 +1,82 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"go-micro.dev/v4/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/option"
+)
+
+// NewSweep wires a live sweep over uid's Drive with the credentials stored
+// in the auth service, the same way the gateway handlers build their
+// clients.
+func NewSweep(
+	ctx context.Context, rpc client.Client, server *config.ServerConfig,
+	credentials *shared.CredentialsConfig, uid string,
+	maxAge time.Duration, dryRun bool,
+) (*Sweep, error) {
+	var ures response.UserResponse
+	if err := rpc.Call(ctx, rpc.NewRequest(
+		fmt.Sprintf("%s:auth", server.Namespace), "UserSelectHandler.GetUser",
+		uid,
+	), &ures); err != nil {
+		return nil, fmt.Errorf("could not get user %s access info: %w", uid, err)
+	}
+
+	gclient := (&oauth2.Config{
+		ClientID:     credentials.Credentials.ClientID,
+		ClientSecret: credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(ctx, &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(gclient))
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize drive service: %w", err)
+	}
+
+	return &Sweep{
+		List: func(pageToken string) (*drive.FileList, error) {
+			call := srv.Files.List().Q(TagQuery).MaxResults(100)
+			if pageToken != "" {
+				call = call.PageToken(pageToken)
+			}
+			return call.Context(ctx).Do()
+		},
+		Remove: func(id string) error {
+			_, err := srv.Files.Trash(id).Context(ctx).Do()
+			return err
+		},
+		MaxAge: maxAge,
+		DryRun: dryRun,
+	}, nil
+}
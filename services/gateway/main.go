@@ -0,0 +1,294 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/doctor"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/janitor"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/controller"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/cache"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/quota"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/settings"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/timings"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"go-micro.dev/v4"
+)
+
+func main() {
+	configPath := flag.String("config_path", "./config/config.yml", "server configuration path")
+	doctorUID := flag.String("doctor_uid", "", "doctor: id of a test user registered in the auth service")
+	doctorRefreshToken := flag.String("doctor_refresh_token", "", "doctor: refresh token overriding the stored credentials")
+	janitorUID := flag.String("janitor_uid", "", "janitor: id of the user whose conversion copies are swept")
+	janitorAgeDays := flag.Int("janitor_age_days", 30, "janitor: minimum age in days of an untouched copy")
+	janitorDelete := flag.Bool("janitor_delete", false, "janitor: trash the matched copies instead of only listing them")
+	flag.Parse()
+
+	serverConfig, err := config.BuildNewServerConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	credentialsConfig, err := shared.BuildNewCredentialsConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	logger := log.NewDefaultLogger(serverConfig)
+
+	onlyofficeConfig, err := shared.NewOnlyofficeConfigSource(*configPath, logger.Warnf)
+	if err != nil {
+		logger.Fatalf("could not load the onlyoffice configuration: %s", err.Error())
+	}
+	if err := onlyofficeConfig.Watch(context.Background()); err != nil {
+		logger.Warnf("could not start the configuration watcher: %s", err.Error())
+	}
+	// One info line with the effective configuration, secrets masked, so a
+	// support log always starts with what this install actually ran with.
+	shared.LogEffectiveConfig(logger, onlyofficeConfig.Config())
+	// In debug mode verify the embedded template folders up front; a missing
+	// file otherwise only surfaces as a 500 on the create endpoint.
+	if serverConfig.Debug {
+		if err := embeddable.VerifyOfficeTemplates(); err != nil {
+			logger.Errorf("template verification failed: %s", err.Error())
+		}
+	}
+	// Deployments sharing one Google client list every environment's
+	// redirect endpoint; the entry matching this gateway becomes the
+	// effective redirect URI before anything consents against it.
+	if err := credentialsConfig.ResolveRedirectURI(
+		onlyofficeConfig.Config().Onlyoffice.Builder.GatewayURL,
+	); err != nil {
+		logger.Fatalf("could not resolve the oauth redirect uri: %s", err.Error())
+	}
+	// A redirect URI that does not point back at this gateway only surfaces
+	// as a Google error page after the user consents; complain at startup
+	// instead.
+	if gatewayURL := onlyofficeConfig.Config().Onlyoffice.Builder.GatewayURL; !controller.RedirectURIMatches(
+		credentialsConfig.Credentials.RedirectURI, gatewayURL,
+	) {
+		logger.Warnf(
+			"the configured redirect uri %q does not match this gateway's %q; Google will reject the consent round trip",
+			credentialsConfig.Credentials.RedirectURI,
+			controller.ExpectedRedirectURI(gatewayURL),
+		)
+	}
+
+	jwtManager := crypto.NewOnlyofficeJwtManager()
+	fileUtility := files.NewOnlyofficeFileUtility()
+	monitor := quota.NewMonitor(
+		onlyofficeConfig.Config().Onlyoffice.Quota.LimitPerMinute,
+		onlyofficeConfig.Config().Onlyoffice.Quota.Threshold,
+	)
+
+	service := micro.NewService(
+		micro.Name(fmt.Sprintf("%s:gateway", serverConfig.Namespace)),
+	)
+	service.Init()
+
+	sourceService := controller.NewSourceURLService(
+		jwtManager, credentialsConfig, onlyofficeConfig,
+	)
+
+	// The doctor subcommand exercises a full document round trip against the
+	// live deployment and exits instead of serving traffic.
+	if flag.Arg(0) == "doctor" {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		report := doctor.NewRoundTrip(
+			service.Client(), jwtManager, serverConfig, credentialsConfig,
+			onlyofficeConfig, sourceService, *doctorUID, *doctorRefreshToken,
+		).Run(ctx)
+		report.Write(os.Stdout)
+		if !report.Passed() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The janitor subcommand lists (or, with -janitor_delete, trashes) the
+	// abandoned conversion copies the integration tagged in a user's Drive
+	// and exits instead of serving traffic.
+	if flag.Arg(0) == "janitor" {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		sweep, err := janitor.NewSweep(
+			ctx, service.Client(), serverConfig, credentialsConfig,
+			*janitorUID, time.Duration(*janitorAgeDays)*24*time.Hour,
+			!*janitorDelete,
+		)
+		if err != nil {
+			logger.Fatalf("could not wire the janitor sweep: %s", err.Error())
+		}
+
+		matched, err := sweep.Run()
+		for _, file := range matched {
+			fmt.Fprintf(os.Stdout, "%s  %s  created %s\n", file.Id, file.Title, file.CreatedDate)
+		}
+		verb := "listed"
+		if *janitorDelete {
+			verb = "trashed"
+		}
+		fmt.Fprintf(os.Stdout, "janitor: %d abandoned conversion copies %s\n", len(matched), verb)
+		if err != nil {
+			logger.Fatalf("janitor sweep failed: %s", err.Error())
+		}
+		return
+	}
+
+	sessionRegistry := sessions.NewRegistry(
+		time.Duration(onlyofficeConfig.Config().Onlyoffice.Sessions.TTL) * time.Second,
+	)
+	// Identity lookups (Userinfo, About) are small but frequent; one cache
+	// across all handlers keeps them off the per-user API quota.
+	identityCache := cache.NewIdentityCache(time.Minute)
+	// Oversized launch states are parked here between redirect hops instead
+	// of traveling in the URL.
+	stateStore := middleware.NewStateStore(2 * time.Minute)
+	// With server sessions enabled the auth cookie carries only an opaque
+	// handle and the token lives in this store; otherwise the codec passes
+	// the token through unchanged.
+	var sessionPayloads *sessions.PayloadStore
+	if onlyofficeConfig.Config().Onlyoffice.Gateway.ServerSessions {
+		sessionPayloads = sessions.NewPayloadStore()
+	}
+	sessionCodec := sessions.NewCookieCodec(sessionPayloads, logger.Warnf)
+	sessionMiddleware := middleware.NewSessionMiddleware(
+		jwtManager, service.Client(), fileUtility, serverConfig,
+		credentialsConfig, onlyofficeConfig, sessionRegistry, identityCache,
+		stateStore, sessionCodec, logger,
+	)
+	authController := controller.NewAuthController(
+		service.Client(), jwtManager, serverConfig, credentialsConfig,
+		onlyofficeConfig, sessionCodec, logger,
+	)
+	// The editor page checks this before rendering so a down Document
+	// Server surfaces as an error page instead of a blank frame.
+	dsHealth := shared.NewDSHealth(onlyofficeConfig, 30*time.Second)
+	// A reload within the TTL reuses the previous open's config; save
+	// callbacks invalidate by document key, so the window only ever serves
+	// configs whose inputs are still current.
+	configCache := cache.NewConfigCache(30 * time.Second)
+	editorController := controller.NewEditorController(
+		service.Client(), serverConfig, onlyofficeConfig, sessionRegistry,
+		configCache, dsHealth, logger,
+	)
+	conversionCache := cache.NewConversionCache(
+		time.Duration(onlyofficeConfig.Config().Onlyoffice.Conversion.CacheTTL) * time.Second,
+	)
+	// Conversion durations per size bucket, summarized in the log every few
+	// minutes for capacity planning.
+	timingsRecorder := timings.NewRecorder()
+	timingsRecorder.StartSummaries(context.Background(), 5*time.Minute, logger.Infof)
+	// Settings live in the user's appDataFolder; the short cache keeps a
+	// session to one Drive round trip.
+	settingsStore := settings.NewStore(5 * time.Minute)
+	// One-time page nonces shared by the create and convert POST endpoints;
+	// the TTL bounds how long a rendered page stays submittable.
+	nonceStore := cache.NewNonceStore(time.Hour)
+	convertController := controller.NewConvertController(
+		service.Client(), jwtManager, fileUtility, serverConfig,
+		credentialsConfig, onlyofficeConfig, sourceService, monitor,
+		conversionCache, stateStore, settingsStore, nonceStore,
+		sessionRegistry, timingsRecorder, logger,
+	)
+	createController := controller.NewCreateController(
+		service.Client(), fileUtility, serverConfig, credentialsConfig,
+		onlyofficeConfig, settingsStore, nonceStore, logger,
+	)
+	apiController := controller.NewAPIController(
+		service.Client(), jwtManager, fileUtility, serverConfig,
+		credentialsConfig, onlyofficeConfig, monitor, sessionRegistry, logger,
+	)
+	pickerController := controller.NewPickerController(
+		service.Client(), jwtManager, fileUtility, serverConfig,
+		credentialsConfig, onlyofficeConfig, monitor, sessionRegistry, logger,
+	)
+	sessionsController := controller.NewSessionsController(
+		sessionRegistry, jwtManager, onlyofficeConfig, logger,
+	)
+	settingsController := controller.NewSettingsController(
+		settingsStore, credentialsConfig, logger,
+	)
+	adminController := controller.NewAdminController(
+		service.Client(), serverConfig, identityCache, settingsStore, logger,
+	)
+	emailController := controller.NewEmailController(
+		fileUtility, credentialsConfig, onlyofficeConfig, monitor, logger,
+	)
+
+	server := web.NewServer(
+		authController, editorController, convertController,
+		createController, apiController, pickerController, sessionsController,
+		settingsController, adminController, emailController,
+		sessionMiddleware, onlyofficeConfig,
+		serverConfig, serverConfig.Debug, logger,
+	)
+
+	httpServer := &http.Server{
+		Addr:    serverConfig.Address,
+		Handler: server.NewHandler(),
+	}
+
+	// On SIGTERM the pod deregisters first, keeps serving through the drain
+	// delay for requests the load balancer already routed here, then stops
+	// the server gracefully; see shared.Lifecycle.
+	gatewayConfig := onlyofficeConfig.Config().Onlyoffice.Gateway
+	lifecycle := shared.NewLifecycle(
+		time.Duration(gatewayConfig.DrainDelay)*time.Second,
+		time.Duration(gatewayConfig.ShutdownTimeout)*time.Second,
+		service.Server().Deregister, httpServer.Shutdown, logger.Warnf,
+	)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	drained := make(chan struct{})
+	go func() {
+		<-stop
+		lifecycle.Shutdown(context.Background())
+		close(drained)
+	}()
+
+	logger.Infof("starting gateway on %s", serverConfig.Address)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Fatalf("gateway stopped: %s", err.Error())
+	}
+	// ListenAndServe returns as soon as the listener closes; the drain and
+	// cleanup steps may still be running.
+	<-drained
+	logger.Infof("gateway drained and stopped")
+}
@@ -0,0 +1,88 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/cache"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/settings"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"go-micro.dev/v4/client"
+)
+
+// AdminController serves the internal operational endpoints that act on a
+// single user, like dropping their cached data after an admin-side change.
+type AdminController struct {
+	client   client.Client
+	server   *config.ServerConfig
+	identity *cache.IdentityCache
+	settings *settings.Store
+	logger   log.Logger
+}
+
+func NewAdminController(
+	client client.Client, server *config.ServerConfig,
+	identity *cache.IdentityCache, settings *settings.Store,
+	logger log.Logger,
+) AdminController {
+	return AdminController{
+		client:   client,
+		server:   server,
+		identity: identity,
+		settings: settings,
+		logger:   logger,
+	}
+}
+
+// BuildInvalidateUser drops every cache holding the user's data: the auth
+// service's credential cache via rpc and the gateway's own identity and
+// settings caches. Support runs it after rotating a user's access in Google
+// Workspace or fixing their record in the backend, so the stale entries do
+// not serve out their TTL.
+func (c AdminController) BuildInvalidateUser() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		uid := r.URL.Query().Get("user")
+		if uid == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := c.client.Call(r.Context(), c.client.NewRequest(
+			fmt.Sprintf("%s:auth", c.server.Namespace), "UserCacheHandler.Invalidate",
+			uid,
+		), nil); err != nil {
+			c.logger.Errorf("could not invalidate auth cache for user %s: %s", uid, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if c.identity != nil {
+			c.identity.Invalidate(fmt.Sprintf("userinfo:%s", uid))
+		}
+		if c.settings != nil {
+			c.settings.Invalidate(uid)
+		}
+
+		c.logger.Infof("invalidated cached data for user %s", uid)
+		rw.WriteHeader(http.StatusOK)
+	}
+}
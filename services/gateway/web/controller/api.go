@@ -0,0 +1,434 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/email"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/quota"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"go-micro.dev/v4/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// APIController serves machine endpoints consumed by the Document Server
+// and the integration's own pages, most notably the signed file download
+// proxy.
+type APIController struct {
+	client      client.Client
+	jwtManager  crypto.JwtManager
+	fileUtility files.OnlyofficeFileUtility
+	server      *config.ServerConfig
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	monitor     *quota.Monitor
+	sessions    *sessions.Registry
+	logger      log.Logger
+	// capabilityFile resolves the fields-limited metadata behind the
+	// capabilities endpoint; a hook so the refusal paths are testable
+	// without Drive.
+	capabilityFile func(r *http.Request, ures response.UserResponse, fileID string) (*drive.File, error)
+	// restoreFile, applyRestore and restoreNotify back the revision restore
+	// endpoint; hooks so the guard and the write path are testable without
+	// Drive or a Document Server.
+	restoreFile   func(r *http.Request, ures response.UserResponse, fileID string) (*drive.File, error)
+	applyRestore  func(r *http.Request, ures response.UserResponse, file *drive.File, revisionID string) error
+	restoreNotify func(r *http.Request, uid, fileID string)
+}
+
+func NewAPIController(
+	client client.Client, jwtManager crypto.JwtManager,
+	fileUtility files.OnlyofficeFileUtility,
+	server *config.ServerConfig, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource, monitor *quota.Monitor,
+	registry *sessions.Registry, logger log.Logger,
+) APIController {
+	controller := APIController{
+		client:      client,
+		jwtManager:  jwtManager,
+		fileUtility: fileUtility,
+		server:      server,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		monitor:     monitor,
+		sessions:    registry,
+		logger:      logger,
+	}
+	controller.capabilityFile = controller.getCapabilityFile
+	controller.restoreFile = controller.getRestoreFile
+	controller.applyRestore = controller.performRestore
+	controller.restoreNotify = controller.notifyVersionChange
+	return controller
+}
+
+// downloadSessionAllowed reports whether the download token belongs to an
+// editing flow this gateway knows about: a live editor session or a source
+// URL it issued within the last few minutes. The check can be disabled via
+// config for debugging.
+func (c APIController) downloadSessionAllowed(token shared.DownloadToken) bool {
+	if c.onlyoffice.Config().Onlyoffice.Gateway.DisableDownloadSessionCheck {
+		return true
+	}
+	if _, active := c.sessions.Get(token.UID, token.FileID); active {
+		return true
+	}
+	return c.sessions.RecentlyIssued(token.UID, token.FileID)
+}
+
+// BuildPing answers the unauthenticated health probe other services use to
+// verify they agree on the gateway's public URL.
+func (c APIController) BuildPing() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(response.PingResponse{
+			Version: buildinfo.Version,
+			URLHash: shared.GatewayURLHash(c.onlyoffice.Config().Onlyoffice.Builder.GatewayURL),
+		}.ToJSON())
+	}
+}
+
+// attachmentLink maps an email attachment to the gateway URL serving its
+// content — only for formats the integration could open on its own; the
+// rest are listed without a link.
+func (c APIController) attachmentLink(fileID string, message *email.Message) func(int) string {
+	return func(index int) string {
+		attachment := message.Attachments[index]
+		ext := strings.TrimPrefix(path.Ext(attachment.Filename), ".")
+		if !c.fileUtility.IsExtensionSupported(ext) {
+			return ""
+		}
+		return fmt.Sprintf(
+			"%s/api/email/attachment?id=%s&index=%d",
+			c.onlyoffice.Config().Onlyoffice.Builder.GatewayURL,
+			url.QueryEscape(fileID), index,
+		)
+	}
+}
+
+// BuildDownloadFile proxies a Drive file's binary content to the caller
+// holding a valid download token.
+func (c APIController) BuildDownloadFile() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var token shared.DownloadToken
+		if err := c.jwtManager.Verify(
+			c.credentials.Credentials.ClientSecret,
+			r.URL.Query().Get("token"), &token,
+		); err != nil {
+			c.logger.Errorf("could not verify download token: %s", err.Error())
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// A valid signature alone is not enough: the token must belong to
+		// an editing flow this gateway is tracking, so a leaked download URL
+		// stops working the moment the editor session winds down.
+		if !c.downloadSessionAllowed(token) {
+			c.logger.Errorf("download token for %s presented outside an active session", token.FileID)
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// Tokens minted for anonymous viewers carry no user; their content
+		// comes through the key-only client instead.
+		if token.Public {
+			c.servePublicDownload(rw, r, token)
+			return
+		}
+
+		var ures response.UserResponse
+		if err := c.client.Call(r.Context(), c.client.NewRequest(
+			fmt.Sprintf("%s:auth", c.server.Namespace), "UserSelectHandler.GetUser",
+			token.UID,
+		), &ures); err != nil {
+			c.logger.Errorf("could not get user %s access info: %s", token.UID, err.Error())
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		gclient := (&oauth2.Config{
+			ClientID:     c.credentials.Credentials.ClientID,
+			ClientSecret: c.credentials.Credentials.ClientSecret,
+			Endpoint:     google.Endpoint,
+		}).Client(r.Context(), &oauth2.Token{
+			AccessToken:  ures.AccessToken,
+			RefreshToken: ures.RefreshToken,
+			TokenType:    ures.TokenType,
+		})
+
+		srv, err := drive.NewService(r.Context(), option.WithHTTPClient(gclient))
+		if err != nil {
+			c.logger.Errorf("could not initialize drive service: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		c.monitor.Observe("files.get")
+		file, err := srv.Files.Get(token.FileID).Do()
+		if err != nil {
+			c.monitor.ObserveError("files.get", err)
+			c.logger.Errorf("could not get file %s: %s", token.FileID, err.Error())
+			// Drive refusing us — a revoked token, a 403, an outage — is an
+			// upstream failure regardless of the status handed back here.
+			shared.ClassifyError(rw, shared.ErrorCategoryDependency)
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// A token minted for a shortcut must serve its target's content; the
+		// shortcut object itself has none.
+		file, err = shared.ResolveShortcut(file, func(targetID, resourceKey string) (*drive.File, error) {
+			call := srv.Files.Get(targetID)
+			if resourceKey != "" {
+				call.Header().Set(
+					shared.ResourceKeysHeader,
+					fmt.Sprintf("%s/%s", targetID, resourceKey),
+				)
+			}
+			c.monitor.Observe("files.get")
+			return call.Do()
+		})
+		if err != nil {
+			c.logger.Errorf("could not resolve shortcut %s: %s", token.FileID, err.Error())
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if file.MimeType == shared.GoogleFolderMime {
+			c.logger.Debugf("download token for %s points at a folder", token.FileID)
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// The owner disabled download for readers. The user's own token
+		// could still fetch the content, so honor the restriction here
+		// instead of handing the file to the Document Server.
+		if file.CopyRequiresWriterPermission &&
+			file.Capabilities != nil && !file.Capabilities.CanEdit {
+			c.logger.Debugf("user %s may not download restricted file %s", token.UID, token.FileID)
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		ext, mismatch := shared.EffectiveExtension(file.FileExtension, file.MimeType)
+		if mismatch {
+			c.logger.Warnf(
+				"file %s reports extension .%s but is Google-native (%s); treating it as .%s",
+				token.FileID, file.FileExtension, file.MimeType, ext,
+			)
+		}
+		fileType, _ := c.fileUtility.GetFileType(ext)
+		limits := c.onlyoffice.Config().Onlyoffice.Limits
+		if limits.ExceedsOpenSizeLimit(fileType, ext, file.FileSize) {
+			c.logger.Debugf("file %s exceeds the open size limit", token.FileID)
+			rw.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		sourceURL, contentType, err := downloadSource(file)
+		if token.RevisionID != "" {
+			// A pinned token serves that revision's content instead of the
+			// file's current state.
+			c.monitor.Observe("revisions.get")
+			revision, rerr := srv.Revisions.Get(token.FileID, token.RevisionID).Do()
+			if rerr != nil {
+				c.monitor.ObserveError("revisions.get", rerr)
+				c.logger.Errorf("could not get revision %s of %s: %s", token.RevisionID, token.FileID, rerr.Error())
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+			sourceURL, contentType, err = revisionSource(revision)
+		}
+		if err != nil {
+			c.logger.Debugf("file %s is not downloadable: %s", token.FileID, err.Error())
+			rw.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		// The permit brackets exactly the Drive content transfer and is
+		// released on any exit, including panics.
+		if err := c.onlyoffice.DownloadSemaphore().With(r.Context(), func() error {
+			resp, err := gclient.Get(sourceURL)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			// Drive reports export and permission failures as a JSON error
+			// body; surface them as structured errors instead of streaming
+			// them to the Document Server as document content.
+			if err := googleapi.CheckResponse(resp); err != nil {
+				return err
+			}
+
+			// Metadata can understate the size, so streaming is also cut
+			// off at the limit.
+			var reader io.Reader = resp.Body
+			if limit := limits.OpenSizeLimit(fileType, ext); limit > 0 {
+				reader = io.LimitReader(resp.Body, limit)
+			}
+
+			// The Document Server cannot display raw rfc822; the proxy
+			// serves the extracted body HTML instead, with the attachments
+			// listed and linked where the integration can open them.
+			if c.fileUtility.IsExtensionEmail(ext) &&
+				c.onlyoffice.Config().Onlyoffice.Builder.EmailPreview {
+				message, err := email.ParseEML(reader)
+				if err != nil {
+					return fmt.Errorf("could not parse email %s: %w", token.FileID, err)
+				}
+				// The body HTML is the sender's; rendering strips its active
+				// content and the sandbox policy keeps anything that slips
+				// through from running script or reading this origin's
+				// cookies when a browser opens the preview directly.
+				rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+				rw.Header().Set("Content-Security-Policy", "sandbox")
+				rw.Write([]byte(message.RenderHTML(c.attachmentLink(token.FileID, message))))
+				return nil
+			}
+
+			// A renamed file whose content contradicts its extension is the
+			// usual culprit behind confusing Document Server failures; the
+			// mismatch is logged here, where the bytes first pass through.
+			format, reader := c.fileUtility.SniffFormat(reader)
+			if !c.fileUtility.FormatMatchesExtension(ext, format) {
+				c.logger.Warnf(
+					"file %s is named .%s but its content looks like %s",
+					token.FileID, ext, format,
+				)
+			}
+
+			rw.Header().Set("Content-Type", contentType)
+			io.Copy(rw, reader)
+			return nil
+		}); err != nil {
+			// Google Docs over the export cap cannot be exported to OOXML at
+			// all; answer with a size error instead of a generic failure.
+			if shared.IsExportSizeLimitError(err) {
+				c.logger.Warnf("file %s exceeds the Drive export size cap", token.FileID)
+				rw.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			c.logger.Errorf("could not download file %s: %s", token.FileID, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// servePublicDownload proxies a link-shared file's content for a public
+// download token. The anonymous view path only covers regular files —
+// Drive's export links do not accept an API key — so the content always
+// comes from the files media endpoint, keyed rather than authorized.
+func (c APIController) servePublicDownload(rw http.ResponseWriter, r *http.Request, token shared.DownloadToken) {
+	key := c.credentials.Credentials.APIKey
+	if key == "" {
+		c.logger.Errorf("a public download token for %s arrived without a configured api key", token.FileID)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	srv, err := drive.NewService(r.Context(), option.WithAPIKey(key))
+	if err != nil {
+		c.logger.Errorf("could not initialize drive service: %s", err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// The sharing could have been revoked between the page render and the
+	// Document Server's fetch; the key-only lookup re-checks it.
+	c.monitor.Observe("files.get")
+	file, err := srv.Files.Get(token.FileID).Do()
+	if err != nil {
+		c.monitor.ObserveError("files.get", err)
+		c.logger.Errorf("could not get public file %s: %s", token.FileID, err.Error())
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if file.MimeType == shared.GoogleFolderMime || shared.IsGoogleNative(file.MimeType) {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if file.CopyRequiresWriterPermission {
+		c.logger.Debugf("public file %s is download-restricted for readers", token.FileID)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	ext := file.FileExtension
+	fileType, _ := c.fileUtility.GetFileType(ext)
+	limits := c.onlyoffice.Config().Onlyoffice.Limits
+	if limits.ExceedsOpenSizeLimit(fileType, ext, file.FileSize) {
+		c.logger.Debugf("public file %s exceeds the open size limit", token.FileID)
+		rw.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	sourceURL, contentType, err := contentSource(fmt.Sprintf(
+		"https://www.googleapis.com/drive/v2/files/%s?alt=media&key=%s",
+		url.QueryEscape(file.Id), url.QueryEscape(key),
+	), file.MimeType, nil)
+	if err != nil {
+		c.logger.Debugf("public file %s is not downloadable: %s", token.FileID, err.Error())
+		rw.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if err := c.onlyoffice.DownloadSemaphore().With(r.Context(), func() error {
+		resp, err := (&http.Client{}).Get(sourceURL)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if err := googleapi.CheckResponse(resp); err != nil {
+			return err
+		}
+
+		var reader io.Reader = resp.Body
+		if limit := limits.OpenSizeLimit(fileType, ext); limit > 0 {
+			reader = io.LimitReader(resp.Body, limit)
+		}
+
+		rw.Header().Set("Content-Type", contentType)
+		io.Copy(rw, reader)
+		return nil
+	}); err != nil {
+		c.logger.Errorf("could not download public file %s: %s", token.FileID, err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
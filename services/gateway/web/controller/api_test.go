@@ -0,0 +1,128 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// downloadTestRegistry is shared across the download tests because the
+// registry constructor registers prometheus metrics once per process.
+var downloadTestRegistry = sessions.NewRegistry(time.Hour)
+
+// downloadJwtManager accepts exactly one token string and fills the
+// verified body with the prepared download claims.
+type downloadJwtManager struct {
+	valid string
+	token shared.DownloadToken
+}
+
+func (f downloadJwtManager) Sign(secret string, payload jwt.Claims) (string, error) {
+	return f.valid, nil
+}
+
+func (f downloadJwtManager) Verify(secret, token string, body interface{}) error {
+	if token != f.valid {
+		return fmt.Errorf("invalid token")
+	}
+	if target, ok := body.(*shared.DownloadToken); ok {
+		*target = f.token
+	}
+	return nil
+}
+
+func newDownloadTestController(
+	t *testing.T, checkDisabled bool, manager downloadJwtManager,
+) APIController {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := fmt.Sprintf(`onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+  gateway:
+    disable_download_session_check: %t
+`, checkDisabled)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := &shared.CredentialsConfig{}
+	credentials.Credentials.ClientSecret = "client-secret"
+	return NewAPIController(
+		nil, manager, nil, &config.ServerConfig{}, credentials, source, nil,
+		downloadTestRegistry, editorTestLogger{},
+	)
+}
+
+func TestDownloadRejectedWithoutSession(t *testing.T) {
+	controller := newDownloadTestController(t, false, downloadJwtManager{
+		valid: "token",
+		token: shared.DownloadToken{UID: "1000000000", FileID: "file-id-0123456789"},
+	})
+
+	rec := httptest.NewRecorder()
+	controller.BuildDownloadFile()(rec, httptest.NewRequest("GET", "/api/download?token=token", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a token outside an editing flow, got %d", rec.Code)
+	}
+}
+
+func TestDownloadSessionAllowance(t *testing.T) {
+	controller := newDownloadTestController(t, false, downloadJwtManager{valid: "token"})
+
+	downloadTestRegistry.Refresh("session-user", sessions.Session{FileID: "open-file", Key: "k1"})
+	if !controller.downloadSessionAllowed(shared.DownloadToken{UID: "session-user", FileID: "open-file"}) {
+		t.Error("expected an active session to allow the download")
+	}
+
+	downloadTestRegistry.NoteIssued("convert-user", "converted-file")
+	if !controller.downloadSessionAllowed(shared.DownloadToken{UID: "convert-user", FileID: "converted-file"}) {
+		t.Error("expected a recorded issuance to allow the download")
+	}
+
+	if controller.downloadSessionAllowed(shared.DownloadToken{UID: "stranger", FileID: "open-file"}) {
+		t.Error("expected a token without session or issuance to be rejected")
+	}
+}
+
+func TestDownloadCheckEscapeHatch(t *testing.T) {
+	controller := newDownloadTestController(t, true, downloadJwtManager{valid: "token"})
+
+	if !controller.downloadSessionAllowed(shared.DownloadToken{UID: "anyone", FileID: "anything"}) {
+		t.Error("expected the disabled check to allow every valid token")
+	}
+}
@@ -0,0 +1,241 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"github.com/golang-jwt/jwt/v4"
+	"go-micro.dev/v4/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	goauth "google.golang.org/api/oauth2/v2"
+	"google.golang.org/api/option"
+)
+
+// AuthController drives the Google OAuth consent flow and persists the
+// resulting tokens via the auth service.
+type AuthController struct {
+	client      client.Client
+	jwtManager  crypto.JwtManager
+	server      *config.ServerConfig
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	codec       *sessions.CookieCodec
+	logger      log.Logger
+	// clock stamps issued session tokens; injected so tests can pin it.
+	clock shared.Clock
+
+	// warmupProbe performs the post-login Drive round trip; injected so
+	// tests can observe warm-up behavior without Google.
+	warmupProbe func(ctx context.Context, token *oauth2.Token) error
+}
+
+func NewAuthController(
+	client client.Client, jwtManager crypto.JwtManager,
+	server *config.ServerConfig, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource, codec *sessions.CookieCodec,
+	logger log.Logger,
+) AuthController {
+	controller := AuthController{
+		client:      client,
+		jwtManager:  jwtManager,
+		server:      server,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		codec:       codec,
+		logger:      logger,
+		clock:       shared.SystemClock{},
+	}
+	controller.warmupProbe = controller.probeDrive
+	return controller
+}
+
+func (c AuthController) oauthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.credentials.Credentials.ClientID,
+		ClientSecret: c.credentials.Credentials.ClientSecret,
+		RedirectURL:  c.credentials.Credentials.RedirectURI,
+		Endpoint:     google.Endpoint,
+		Scopes:       shared.RequestedScopes(),
+	}
+}
+
+// BuildGetAuth redirects the browser to the Google consent screen.
+func (c AuthController) BuildGetAuth() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		state := r.URL.Query().Get("state")
+		// Incremental authorization keeps previously granted scopes, so a
+		// re-consent for the appdata scope does not drop the drive ones.
+		options := []oauth2.AuthCodeOption{
+			oauth2.AccessTypeOffline,
+			oauth2.SetAuthURLParam("include_granted_scopes", "true"),
+		}
+		// An account switch passes the expected account along so the consent
+		// screen opens on it instead of the browser's default account.
+		if hint := r.URL.Query().Get("login_hint"); hint != "" {
+			options = append(options, oauth2.SetAuthURLParam("login_hint", hint))
+		}
+		if prompt := r.URL.Query().Get("prompt"); prompt != "" {
+			options = append(options, oauth2.SetAuthURLParam("prompt", prompt))
+		}
+		http.Redirect(
+			rw, r,
+			c.oauthConfig().AuthCodeURL(state, options...),
+			http.StatusSeeOther,
+		)
+	}
+}
+
+// BuildGetRedirect exchanges the consent code for tokens, persists them via
+// the auth service and issues the session cookie.
+func (c AuthController) BuildGetRedirect() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		code := r.URL.Query().Get("code")
+		if code == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token, err := c.oauthConfig().Exchange(r.Context(), code)
+		if err != nil {
+			c.logger.Errorf("could not exchange oauth code: %s", err.Error())
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		usvc, err := goauth.NewService(r.Context(), option.WithTokenSource(
+			c.oauthConfig().TokenSource(r.Context(), token),
+		))
+		if err != nil {
+			c.logger.Errorf("could not initialize userinfo service: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		uinfo, err := usvc.Userinfo.Get().Do()
+		if err != nil {
+			c.logger.Errorf("could not get userinfo: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// Restricted deployments turn away accounts from other domains
+		// before any token is stored; the freshly exchanged tokens go out
+		// of scope with this request.
+		allowed := c.onlyoffice.Config().Onlyoffice.Auth.AllowedDomains
+		if !shared.DomainAllowed(allowed, uinfo.Hd, uinfo.Email) {
+			c.logger.Warnf("user %s is outside the allowed domains", uinfo.Id)
+			main, subtext := embeddable.RestrictedDomainMessage(
+				uinfo.Locale, strings.Join(allowed, ", "),
+			)
+			rw.WriteHeader(http.StatusForbidden)
+			embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+				"errorMain":    main,
+				"errorSubtext": subtext,
+			})
+			return
+		}
+
+		if err := c.client.Call(r.Context(), c.client.NewRequest(
+			fmt.Sprintf("%s:auth", c.server.Namespace), "UserInsertHandler.InsertUser",
+			map[string]interface{}{
+				"id":            uinfo.Id,
+				"access_token":  token.AccessToken,
+				"refresh_token": token.RefreshToken,
+				"token_type":    token.TokenType,
+				// The grant is recorded in the same wire format Google
+				// reports refreshed scopes in, so the stored string stays
+				// comparable to the requested set when drift is checked.
+				"scope":  shared.RequestedScopeString(),
+				"expiry": token.Expiry.Unix(),
+			},
+		), nil); err != nil {
+			c.logger.Errorf("could not persist user %s: %s", uinfo.Id, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		auth := c.onlyoffice.Config().Onlyoffice.Auth
+		lifetime := auth.SessionDuration()
+		now := c.clock.Now()
+		claims := shared.SessionToken{
+			UID:   uinfo.Id,
+			Email: uinfo.Email,
+			RegisteredClaims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(lifetime)),
+			},
+		}
+		// The absolute expiry is anchored here at consent and survives every
+		// renewal, so a sliding session still has a hard end.
+		if auth.SessionMaxAge > 0 {
+			claims.AbsoluteExpiresAt = jwt.NewNumericDate(
+				now.Add(time.Duration(auth.SessionMaxAge) * time.Second),
+			)
+		}
+		session, err := c.jwtManager.Sign(c.credentials.Credentials.ClientSecret, claims)
+		if err != nil {
+			c.logger.Errorf("could not issue a session token: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// Connections to googleapis.com are pre-established in the
+		// background, so the first document open after consent is not the
+		// one paying for the handshakes.
+		c.warmup(token)
+
+		// The session is set twice: the plain cookie marks the active
+		// account, the keyed copy survives switches to other accounts so a
+		// later switch back needs no consent round trip. The codec decides
+		// whether the cookies carry the token or a server-side handle.
+		value := c.codec.Encode(session, lifetime)
+		for _, name := range []string{
+			shared.SessionCookie, shared.AccountSessionCookie(uinfo.Id),
+		} {
+			http.SetCookie(rw, &http.Cookie{
+				Name:     name,
+				Value:    value,
+				Path:     "/",
+				MaxAge:   int(lifetime / time.Second),
+				Secure:   true,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		if state := r.URL.Query().Get("state"); state != "" {
+			http.Redirect(rw, r, fmt.Sprintf("/api/convert?state=%s", state), http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(rw, r, "https://drive.google.com", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,74 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultBatchParallelism is used when the conversion parallelism is not
+// configured; the global download semaphore still bounds the Drive traffic
+// the workers generate.
+const defaultBatchParallelism = 2
+
+// batchConvertFn converts one file of a batch; injected so the pool can be
+// exercised with fakes.
+type batchConvertFn func(ctx context.Context, fileID string) error
+
+// runConversionBatch feeds the files through a bounded worker pool and
+// reports every status transition. A cancelled context stops the batch:
+// running conversions abort with their context and files still pending are
+// reported failed without ever starting. The call returns once every file
+// settled.
+func runConversionBatch(
+	ctx context.Context, fileIDs []string, parallelism int,
+	convert batchConvertFn, report func(fileID, status, reason string),
+) {
+	if parallelism < 1 {
+		parallelism = defaultBatchParallelism
+	}
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileID := range queue {
+				if ctx.Err() != nil {
+					report(fileID, ConvertFileStatusFailed, "The conversion was cancelled.")
+					continue
+				}
+				report(fileID, ConvertFileStatusRunning, "")
+				if err := convert(ctx, fileID); err != nil {
+					report(fileID, ConvertFileStatusFailed, err.Error())
+					continue
+				}
+				report(fileID, ConvertFileStatusDone, "")
+			}
+		}()
+	}
+
+	for _, fileID := range fileIDs {
+		queue <- fileID
+	}
+	close(queue)
+	wg.Wait()
+}
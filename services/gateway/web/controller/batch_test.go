@@ -0,0 +1,128 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchReporter collects status transitions thread-safely so assertions can
+// run after the pool drained.
+type batchReporter struct {
+	mu    sync.Mutex
+	final map[string]struct{ status, reason string }
+}
+
+func newBatchReporter() *batchReporter {
+	return &batchReporter{final: map[string]struct{ status, reason string }{}}
+}
+
+func (r *batchReporter) report(fileID, status, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.final[fileID] = struct{ status, reason string }{status, reason}
+}
+
+func TestRunConversionBatchMixedResults(t *testing.T) {
+	reporter := newBatchReporter()
+	runConversionBatch(
+		context.Background(), []string{"a", "b", "c", "d"}, 2,
+		func(_ context.Context, fileID string) error {
+			if fileID == "b" {
+				return fmt.Errorf("drive said no")
+			}
+			return nil
+		},
+		reporter.report,
+	)
+
+	for _, fileID := range []string{"a", "c", "d"} {
+		if got := reporter.final[fileID]; got.status != ConvertFileStatusDone {
+			t.Errorf("expected %s to be done, got %q (%q)", fileID, got.status, got.reason)
+		}
+	}
+	if got := reporter.final["b"]; got.status != ConvertFileStatusFailed || got.reason != "drive said no" {
+		t.Errorf("expected b to fail with the conversion error, got %q (%q)", got.status, got.reason)
+	}
+}
+
+func TestRunConversionBatchCancellationStopsPendingFiles(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reporter := newBatchReporter()
+	runConversionBatch(
+		ctx, []string{"a", "b", "c"}, 1,
+		func(_ context.Context, fileID string) error {
+			// The user navigates away while the first file converts.
+			cancel()
+			return nil
+		},
+		reporter.report,
+	)
+
+	if got := reporter.final["a"]; got.status != ConvertFileStatusDone {
+		t.Errorf("expected the running file to finish, got %q", got.status)
+	}
+	for _, fileID := range []string{"b", "c"} {
+		if got := reporter.final[fileID]; got.status != ConvertFileStatusFailed {
+			t.Errorf("expected pending file %s to be reported failed, got %q", fileID, got.status)
+		}
+	}
+}
+
+func TestBatchJobKeepsSelectionOrder(t *testing.T) {
+	jobs := NewConversionJobs(time.Minute)
+	id := jobs.StartBatch([]string{"first", "second", "third"})
+	cancelled := false
+	jobs.AttachCancel(id, func() { cancelled = true })
+
+	jobs.UpdateFile(id, "second", ConvertFileStatusDone, "")
+	jobs.UpdateFile(id, "third", ConvertFileStatusFailed, "drive said no")
+
+	status, ok := jobs.Get(id)
+	if !ok || len(status.Files) != 3 {
+		t.Fatalf("expected three tracked files, got %d (%t)", len(status.Files), ok)
+	}
+	for i, fileID := range []string{"first", "second", "third"} {
+		if status.Files[i].FileID != fileID {
+			t.Errorf("expected position %d to hold %s, got %s", i, fileID, status.Files[i].FileID)
+		}
+	}
+	// Two of three files settled.
+	if status.Percent != 66 {
+		t.Errorf("expected 66 percent, got %d", status.Percent)
+	}
+
+	jobs.FinishBatch(id)
+	status, _ = jobs.Get(id)
+	if status.Phase != ConvertPhaseDone || status.Percent != 100 || len(status.Files) != 3 {
+		t.Errorf("expected a done job keeping its report, got %q at %d with %d files",
+			status.Phase, status.Percent, len(status.Files))
+	}
+
+	jobs.Cancel(id)
+	if !cancelled {
+		t.Error("expected Cancel to invoke the attached cancel function")
+	}
+}
@@ -0,0 +1,148 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// Refusal codes answered by /api/capabilities. Each doubles as the suffix
+// of the message key a launcher localizes.
+const (
+	CapabilityReasonNoAccess    = "no_access"
+	CapabilityReasonUnsupported = "unsupported_extension"
+	CapabilityReasonTooLarge    = "too_large"
+)
+
+// getCapabilityFile resolves just the metadata fields the capability checks
+// read, through the session user's own credentials — access is part of what
+// the endpoint validates.
+func (c APIController) getCapabilityFile(
+	r *http.Request, ures response.UserResponse, fileID string,
+) (*drive.File, error) {
+	gclient := (&oauth2.Config{
+		ClientID:     c.credentials.Credentials.ClientID,
+		ClientSecret: c.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(r.Context(), &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	srv, err := drive.NewService(r.Context(), option.WithHTTPClient(gclient))
+	if err != nil {
+		return nil, err
+	}
+
+	c.monitor.Observe("files.get")
+	file, err := srv.Files.Get(fileID).
+		Fields("id", "fileExtension", "mimeType", "fileSize").
+		SupportsAllDrives(true).Do()
+	if err != nil {
+		c.monitor.ObserveError("files.get", err)
+	}
+	return file, err
+}
+
+// capabilityRefusal shapes an unsupported answer from refusal codes.
+func capabilityRefusal(codes ...string) response.CapabilitiesResponse {
+	var res response.CapabilitiesResponse
+	for _, code := range codes {
+		res.Reasons = append(res.Reasons, response.CapabilityReason{
+			Code:       code,
+			MessageKey: "capabilities." + code,
+		})
+	}
+	return res
+}
+
+// BuildCapabilities reports whether a Drive file would open in the editor
+// — and how — without rendering any page, so third-party launchers can
+// pre-validate a file id against the format tables, the size limits and
+// the user's own access before sending anyone here.
+func (c APIController) BuildCapabilities() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		fileID := r.URL.Query().Get("fileId")
+		if fileID == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+
+		file, err := c.capabilityFile(r, ures, fileID)
+		if err != nil {
+			// Drive answers 404 for anything this user may not see, so
+			// missing and forbidden files collapse into one refusal; the
+			// endpoint must not reveal whether an id exists.
+			var gerr *googleapi.Error
+			if errors.As(err, &gerr) &&
+				(gerr.Code == http.StatusNotFound || gerr.Code == http.StatusForbidden) {
+				rw.Write(capabilityRefusal(CapabilityReasonNoAccess).ToJSON())
+				return
+			}
+			c.logger.Errorf("could not resolve capabilities of file %s: %s", fileID, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		ext, _ := shared.EffectiveExtension(file.FileExtension, file.MimeType)
+		fileType, err := c.fileUtility.GetFileType(ext)
+		if err != nil {
+			rw.Write(capabilityRefusal(CapabilityReasonUnsupported).ToJSON())
+			return
+		}
+
+		limits := c.onlyoffice.Config().Onlyoffice.Limits
+		if limits.ExceedsOpenSizeLimit(fileType, ext, file.FileSize) {
+			rw.Write(capabilityRefusal(CapabilityReasonTooLarge).ToJSON())
+			return
+		}
+
+		// The mode mirrors the conversion page's own signals: editable
+		// formats open directly, loss-editable ones are steered through a
+		// converted copy, and the rest open read-only.
+		mode := "view"
+		switch {
+		case c.fileUtility.IsExtensionEditable(ext):
+			mode = "edit"
+		case c.fileUtility.IsExtensionLossEditable(ext):
+			mode = "convert-required"
+		}
+
+		rw.Write(response.CapabilitiesResponse{Supported: true, Mode: mode}.ToJSON())
+	}
+}
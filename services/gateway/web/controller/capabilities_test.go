@@ -0,0 +1,181 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/googleapi"
+)
+
+// newCapabilitiesTestController wires an API controller whose metadata
+// lookups answer from the given stub instead of Drive.
+func newCapabilitiesTestController(
+	t *testing.T, fetch func(fileID string) (*drive.File, error),
+) APIController {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return APIController{
+		fileUtility: files.NewOnlyofficeFileUtility(),
+		onlyoffice:  source,
+		logger:      editorTestLogger{},
+		capabilityFile: func(r *http.Request, ures response.UserResponse, fileID string) (*drive.File, error) {
+			return fetch(fileID)
+		},
+	}
+}
+
+func capabilitiesRequest(t *testing.T, fileID string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/api/capabilities?fileId="+fileID, nil)
+	return req.WithContext(context.WithValue(
+		req.Context(), middleware.ContextUser, response.UserResponse{ID: "1000000000"},
+	))
+}
+
+func capabilitiesAnswer(t *testing.T, rec *httptest.ResponseRecorder) response.CapabilitiesResponse {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a capabilities answer, got %d", rec.Code)
+	}
+	var res response.CapabilitiesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// Each supported format family maps to the mode the editor would actually
+// use, and each refusal carries its stable code and message key.
+func TestCapabilitiesModesAndRefusals(t *testing.T) {
+	for name, tc := range map[string]struct {
+		file      *drive.File
+		err       error
+		supported bool
+		mode      string
+		reason    string
+	}{
+		"editable format": {
+			file:      &drive.File{Id: "f", FileExtension: "docx", FileSize: 1024},
+			supported: true,
+			mode:      "edit",
+		},
+		"view-only format": {
+			file:      &drive.File{Id: "f", FileExtension: "pdf", FileSize: 1024},
+			supported: true,
+			mode:      "view",
+		},
+		"loss-editable format": {
+			file:      &drive.File{Id: "f", FileExtension: "odt", FileSize: 1024},
+			supported: true,
+			mode:      "convert-required",
+		},
+		"unsupported extension": {
+			file:   &drive.File{Id: "f", FileExtension: "exe", FileSize: 1024},
+			reason: CapabilityReasonUnsupported,
+		},
+		"over the size limit": {
+			file:   &drive.File{Id: "f", FileExtension: "docx", FileSize: 120000000},
+			reason: CapabilityReasonTooLarge,
+		},
+		"no access": {
+			err:    &googleapi.Error{Code: http.StatusNotFound},
+			reason: CapabilityReasonNoAccess,
+		},
+		"forbidden collapses into no access": {
+			err:    &googleapi.Error{Code: http.StatusForbidden},
+			reason: CapabilityReasonNoAccess,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			controller := newCapabilitiesTestController(t, func(string) (*drive.File, error) {
+				return tc.file, tc.err
+			})
+
+			rec := httptest.NewRecorder()
+			controller.BuildCapabilities()(rec, capabilitiesRequest(t, "file-id-0123456789"))
+			res := capabilitiesAnswer(t, rec)
+
+			if res.Supported != tc.supported {
+				t.Fatalf("expected supported=%t, got %+v", tc.supported, res)
+			}
+			if res.Mode != tc.mode {
+				t.Errorf("expected mode %q, got %q", tc.mode, res.Mode)
+			}
+			if tc.reason == "" {
+				if len(res.Reasons) != 0 {
+					t.Errorf("expected no refusal reasons, got %v", res.Reasons)
+				}
+				return
+			}
+			if len(res.Reasons) != 1 || res.Reasons[0].Code != tc.reason {
+				t.Fatalf("expected the %s refusal, got %v", tc.reason, res.Reasons)
+			}
+			if res.Reasons[0].MessageKey != "capabilities."+tc.reason {
+				t.Errorf("expected a matching message key, got %q", res.Reasons[0].MessageKey)
+			}
+		})
+	}
+}
+
+// A request without a file id is malformed, and a transient Drive error is
+// a server failure rather than a refusal the launcher should cache.
+func TestCapabilitiesRejectsBadRequests(t *testing.T) {
+	controller := newCapabilitiesTestController(t, func(string) (*drive.File, error) {
+		return nil, &googleapi.Error{Code: http.StatusInternalServerError}
+	})
+
+	rec := httptest.NewRecorder()
+	controller.BuildCapabilities()(rec, capabilitiesRequest(t, ""))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a file id, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	controller.BuildCapabilities()(rec, capabilitiesRequest(t, "file-id-0123456789"))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 for a transient Drive error, got %d", rec.Code)
+	}
+}
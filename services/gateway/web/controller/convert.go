@@ -0,0 +1,1079 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/janitor"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/cache"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/quota"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/settings"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/timings"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"github.com/golang-jwt/jwt/v4"
+	"go-micro.dev/v4/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/sync/singleflight"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/option"
+)
+
+// conversionJobTTL is how long a finished or stalled conversion job stays
+// pollable after its last update; conversionPipelineTimeout caps one
+// pipeline run, which outlives the POST that started it.
+const (
+	conversionJobTTL          = 5 * time.Minute
+	conversionPipelineTimeout = 10 * time.Minute
+)
+
+// ConvertController renders the conversion page and converts Drive files
+// into editable OOXML copies through the Document Server conversion API.
+type ConvertController struct {
+	client      client.Client
+	jwtManager  crypto.JwtManager
+	fileUtility files.OnlyofficeFileUtility
+	server      *config.ServerConfig
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	sources     SourceURLService
+	monitor     *quota.Monitor
+	conversions *cache.ConversionCache
+	states      *middleware.StateStore
+	settings    *settings.Store
+	// settingsClient builds the appDataFolder drive client for the session
+	// user; a hook so the preference paths are testable without Drive.
+	settingsClient func(r *http.Request, ures response.UserResponse) (settings.SettingsDrive, error)
+	// group collapses concurrent conversions of the same source file into
+	// one pipeline run within this process; the conversion cache then
+	// converges near-simultaneous requests across its TTL.
+	group *singleflight.Group
+	// jobs tracks running conversion pipelines for the progress endpoint.
+	jobs *ConversionJobs
+	// passwords caps retries of password-carrying conversions per file.
+	passwords *passwordGate
+	// nonces binds rendered convert pages to the POSTs they submit, so a
+	// captured body cannot be replayed against the user's Drive.
+	nonces *cache.NonceStore
+	// registry records issued source URLs so the download proxy accepts
+	// them without a tracked editor session.
+	registry *sessions.Registry
+	timings  *timings.Recorder
+	logger   log.Logger
+
+	// clock and ids feed the persisted pieces of the pipeline — janitor
+	// properties and conversion request keys — so tests can pin them.
+	clock shared.Clock
+	ids   shared.IDGenerator
+}
+
+func NewConvertController(
+	client client.Client, jwtManager crypto.JwtManager,
+	fileUtility files.OnlyofficeFileUtility,
+	server *config.ServerConfig, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource, sources SourceURLService,
+	monitor *quota.Monitor, conversions *cache.ConversionCache,
+	states *middleware.StateStore, settingsStore *settings.Store,
+	nonceStore *cache.NonceStore, registry *sessions.Registry,
+	recorder *timings.Recorder, logger log.Logger,
+) ConvertController {
+	controller := ConvertController{
+		client:      client,
+		jwtManager:  jwtManager,
+		fileUtility: fileUtility,
+		server:      server,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		sources:     sources,
+		monitor:     monitor,
+		conversions: conversions,
+		states:      states,
+		settings:    settingsStore,
+		group:       &singleflight.Group{},
+		jobs:        NewConversionJobs(conversionJobTTL),
+		passwords:   newPasswordGate(),
+		nonces:      nonceStore,
+		registry:    registry,
+		timings:     recorder,
+		logger:      logger,
+		clock:       shared.SystemClock{},
+		ids:         shared.UUIDGenerator{},
+	}
+	controller.settingsClient = controller.settingsDrive
+	return controller
+}
+
+// settingsDrive builds an appDataFolder-capable drive client for the
+// session user.
+func (c ConvertController) settingsDrive(r *http.Request, ures response.UserResponse) (settings.SettingsDrive, error) {
+	gclient := (&oauth2.Config{
+		ClientID:     c.credentials.Credentials.ClientID,
+		ClientSecret: c.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(r.Context(), &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	srv, err := drive.NewService(r.Context(), option.WithHTTPClient(gclient))
+	if err != nil {
+		return nil, err
+	}
+	return settings.NewDriveClient(srv), nil
+}
+
+// BuildConvertPage renders the conversion suggestion page for files the
+// editor cannot open directly.
+func (c ConvertController) BuildConvertPage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		file, ok := r.Context().Value(middleware.ContextFile).(*drive.File)
+		if !ok {
+			http.Redirect(rw, r, "https://drive.google.com", http.StatusSeeOther)
+			return
+		}
+
+		state, _ := r.Context().Value(middleware.ContextState).(request.DriveState)
+		ext, mismatch := shared.EffectiveExtension(file.FileExtension, file.MimeType)
+		if mismatch {
+			c.logger.Warnf(
+				"file %s reports extension .%s but is Google-native (%s); treating it as .%s",
+				file.Id, file.FileExtension, file.MimeType, ext,
+			)
+		}
+
+		if c.fileUtility.IsExtensionEditable(ext) || state.ForceEdit || c.templateEditable(ext) {
+			// The state builder parks oversized multi-select states behind
+			// a server-side handle instead of an overlong URL.
+			http.Redirect(rw, r, c.states.StateURL("/api/editor", state), http.StatusSeeOther)
+			return
+		}
+
+		// A remembered "don't ask again" choice skips the page: view and
+		// edit relaunch the editor directly, while create cannot run from a
+		// redirect and autostarts the conversion on the page instead.
+		canCopy := file.Capabilities == nil || file.Capabilities.CanCopy
+		preferred, skip := c.openAsPreference(r, ext)
+		if skip && (preferred == "edit" || preferred == "view") {
+			state.ForceEdit = preferred == "edit"
+			http.Redirect(rw, r, c.states.StateURL("/api/editor", state), http.StatusSeeOther)
+			return
+		}
+
+		// The embedded nonce is spent by the POST the page submits; starting
+		// a job hands the page a fresh one for any follow-up.
+		var nonce string
+		if ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse); ok {
+			nonce = c.nonces.Issue(ures.ID)
+		}
+
+		// A template's create action is ambiguous — a plain document or a
+		// template copy — so a remembered "create" never autostarts for
+		// template formats; the page waits for the explicit choice.
+		template := c.fileUtility.IsExtensionTemplate(ext)
+
+		embeddable.ConvertPage.Execute(rw, map[string]interface{}{
+			"file":      file.Title,
+			"extension": ext,
+			"lossEdit":  c.fileUtility.IsExtensionLossEditable(ext),
+			"viewOnly":  c.fileUtility.IsExtensionViewOnly(ext),
+			"canCopy":   canCopy,
+			"preferred": preferred,
+			"template":  template,
+			"autostart": skip && preferred == "create" && canCopy && !template,
+			"state":     string(state.ToJSON()),
+			"nonce":     nonce,
+		})
+	}
+}
+
+// templateEditable reports whether ext skips the conversion page entirely
+// and opens straight in the editor as a template: the OOXML template
+// formats, on deployments whose Document Server supports editing them. The
+// registry maps those formats onto themselves, which is what tells them
+// apart from the legacy templates that must convert first.
+func (c ConvertController) templateEditable(ext string) bool {
+	target, ok := c.fileUtility.TemplateOutputExtension(ext)
+	if !ok || !strings.EqualFold(target, ext) {
+		return false
+	}
+	return c.onlyoffice.Config().Onlyoffice.Builder.TemplateEdit
+}
+
+// openAsPreference reads the session user's remembered conversion-page
+// choice for ext. Preferences are a convenience, so any trouble reading
+// them just shows the page as usual.
+func (c ConvertController) openAsPreference(r *http.Request, ext string) (string, bool) {
+	ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+	if !ok {
+		return "", false
+	}
+
+	client, err := c.settingsClient(r, ures)
+	if err != nil {
+		c.logger.Debugf("could not initialize drive service for preferences: %s", err.Error())
+		return "", false
+	}
+	stored, err := c.settings.Get(client, ures.ID)
+	if err != nil {
+		c.logger.Debugf("could not read settings for user %s: %s", ures.ID, err.Error())
+		return "", false
+	}
+	return stored.OpenAsPreference(ext)
+}
+
+// rememberOpenAs persists the chosen action as the user's preference for
+// the extension the page reported. A failed write only logs; the action
+// the user asked for still runs.
+func (c ConvertController) rememberOpenAs(r *http.Request, ures response.UserResponse, state request.DriveState) {
+	client, err := c.settingsClient(r, ures)
+	if err != nil {
+		c.logger.Debugf("could not initialize drive service for preferences: %s", err.Error())
+		return
+	}
+	stored, err := c.settings.Get(client, ures.ID)
+	if err != nil {
+		c.logger.Debugf("could not read settings for user %s: %s", ures.ID, err.Error())
+		return
+	}
+	stored.RememberOpenAs(state.Extension, state.Action, state.DontAsk)
+	if err := c.settings.Save(client, ures.ID, stored); err != nil {
+		c.logger.Warnf("could not save the open-as preference for user %s: %s", ures.ID, err.Error())
+	}
+}
+
+// BuildListFolders serves one page of the destination folder browser on
+// the conversion page: the subfolders of the requested parent (the Drive
+// root when omitted), spanning shared drives and paginated with Drive's
+// page tokens.
+func (c ConvertController) BuildListFolders() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		parent := r.URL.Query().Get("parent")
+		if parent == "" {
+			parent = "root"
+		}
+		// The id is interpolated into a Drive query; ids never contain
+		// quotes, so anything else is a malformed request.
+		if strings.ContainsAny(parent, "'\\") {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		gclient := (&oauth2.Config{
+			ClientID:     c.credentials.Credentials.ClientID,
+			ClientSecret: c.credentials.Credentials.ClientSecret,
+			Endpoint:     google.Endpoint,
+		}).Client(r.Context(), &oauth2.Token{
+			AccessToken:  ures.AccessToken,
+			RefreshToken: ures.RefreshToken,
+			TokenType:    ures.TokenType,
+		})
+
+		srv, err := drive.NewService(r.Context(), option.WithHTTPClient(gclient))
+		if err != nil {
+			c.logger.Errorf("could not initialize drive service: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		c.monitor.Observe("files.list")
+		call := srv.Files.List().
+			Q(fmt.Sprintf(
+				"'%s' in parents and mimeType = '%s' and trashed = false",
+				parent, shared.GoogleFolderMime,
+			)).
+			SupportsAllDrives(true).
+			IncludeItemsFromAllDrives(true).
+			MaxResults(50)
+		if token := r.URL.Query().Get("page"); token != "" {
+			call = call.PageToken(token)
+		}
+
+		list, err := call.Do()
+		if err != nil {
+			c.monitor.ObserveError("files.list", err)
+			c.logger.Errorf("could not list folders under %s: %s", parent, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		res := response.FolderListResponse{
+			Current:       response.FolderItem{ID: parent, Title: "My Drive"},
+			NextPageToken: list.NextPageToken,
+		}
+		for _, item := range list.Items {
+			res.Folders = append(res.Folders, response.FolderItem{
+				ID:    item.Id,
+				Title: item.Title,
+			})
+		}
+
+		if parent != "root" {
+			c.monitor.Observe("files.get")
+			if folder, err := srv.Files.Get(parent).SupportsAllDrives(true).Do(); err == nil {
+				res.Current.Title = folder.Title
+				if len(folder.Parents) > 0 {
+					if folder.Parents[0].IsRoot {
+						res.ParentID = "root"
+					} else {
+						res.ParentID = folder.Parents[0].Id
+					}
+				}
+			} else {
+				c.monitor.ObserveError("files.get", err)
+			}
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(res.ToJSON())
+	}
+}
+
+// BuildConvertFile handles the /api/convert POST body, either forcing the
+// editor open or producing a converted OOXML copy next to the source file.
+func (c ConvertController) BuildConvertFile() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var body request.ConvertRequest
+		if err := request.DecodeStrict(r.Body, &body); err != nil {
+			c.logger.Debugf("malformed convert payload: %s", err.Error())
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusBadRequest)
+			if verrs, ok := err.(request.ValidationErrors); ok {
+				rw.Write(verrs.ToJSON())
+			}
+			return
+		}
+
+		state := body.DriveState
+		if err := state.Validate(); err != nil {
+			c.logger.Debugf("invalid convert payload: %s", err.Error())
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusBadRequest)
+			if verrs, ok := err.(request.ValidationErrors); ok {
+				rw.Write(verrs.ToJSON())
+			}
+			return
+		}
+
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// Every POST spends the one-time nonce its page embedded; a replayed
+		// body fails here and the user is asked to reload. Responses the page
+		// continues from carry a fresh one.
+		if !c.nonces.Consume(ures.ID, body.Nonce) {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusConflict)
+			rw.Write(request.ValidationErrors{{
+				Field:   "page",
+				Message: embeddable.StalePageMessage(userLocale(r)),
+			}}.ToJSON())
+			return
+		}
+		fresh := c.nonces.Issue(ures.ID)
+
+		// The page reports which extension it was shown for, so the chosen
+		// action can be remembered per format.
+		if state.Extension != "" {
+			c.rememberOpenAs(r, ures, state)
+		}
+
+		switch state.Action {
+		case "edit":
+			state.ForceEdit = true
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write(state.ToJSON())
+		case "view":
+			state.ForceEdit = false
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write(state.ToJSON())
+		case "create":
+			// A multi-select conversion fans out to the bounded worker pool
+			// instead of converting the files one after another. Passwords
+			// are a single-file affair; a batch never carries one.
+			if len(state.IDS) > 1 {
+				c.startBatchConversion(rw, r, ures, state, fresh)
+				return
+			}
+
+			// Password retries are capped per file, so the endpoint cannot
+			// be used to guess document passwords.
+			if body.Password != "" && !c.passwords.allow(state.IDS[0]) {
+				rw.Header().Set("Content-Type", "application/json")
+				rw.WriteHeader(http.StatusTooManyRequests)
+				rw.Write(response.ConvertProgressResponse{
+					Errors: []response.ConvertProgressError{{
+						Field:   "password",
+						Message: "Too many password attempts. Please try again later.",
+					}},
+					Nonce: fresh,
+				}.ToJSON())
+				return
+			}
+
+			// Conversions can take minutes, so the POST answers with a job id
+			// right away and the page follows /api/convert/progress; the
+			// pipeline runs on its own context, detached from this request.
+			jobID := c.jobs.Start()
+			jobCtx, cancel := context.WithTimeout(context.Background(), conversionPipelineTimeout)
+			c.jobs.AttachCancel(jobID, cancel)
+			go func() {
+				defer cancel()
+				newState, err := c.convertFile(r.WithContext(jobCtx), ures, state, body.Password,
+					func(phase string, percent int) {
+						c.jobs.Update(jobID, phase, percent)
+					})
+				if err != nil {
+					// A protected source is a failure the user can fix; the
+					// page prompts for the password and posts again. The
+					// password itself never reaches a log line.
+					if isPasswordError(err) {
+						message := "This file is password protected. Enter the password to convert it."
+						if body.Password != "" {
+							message = "The password is not correct. Please try again."
+						}
+						c.jobs.Fail(jobID, response.ConvertProgressResponse{
+							PasswordRequired: true,
+							Errors: []response.ConvertProgressError{{
+								Field:   "password",
+								Message: message,
+							}},
+						})
+						return
+					}
+					// Google Docs over the export cap cannot be converted at
+					// all; point the user at Google's own editor instead of
+					// showing a generic failure.
+					if shared.IsExportSizeLimitError(err) {
+						c.logger.Warnf("file %s exceeds the Drive export size cap", state.IDS[0])
+						c.jobs.Fail(jobID, response.ConvertProgressResponse{
+							Errors: []response.ConvertProgressError{{
+								Field:   "file",
+								Message: "This file is too large to convert.",
+							}},
+							Link:     fmt.Sprintf("https://docs.google.com/open?id=%s", state.IDS[0]),
+							LinkText: "Open in Google Docs",
+						})
+						return
+					}
+					c.logger.Errorf("could not convert file %s: %s", state.IDS[0], err.Error())
+					c.jobs.Fail(jobID, response.ConvertProgressResponse{
+						Errors: []response.ConvertProgressError{{
+							Field:   "file",
+							Message: "The conversion failed. Please try again.",
+						}},
+					})
+					return
+				}
+				c.jobs.Finish(jobID, *newState)
+			}()
+
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusAccepted)
+			rw.Write(response.ConvertJobResponse{Job: jobID, Nonce: fresh}.ToJSON())
+		}
+	}
+}
+
+// startBatchConversion runs a multi-select conversion through the bounded
+// worker pool and answers with the job the page polls. Every file goes
+// through the same single-file pipeline, so the conversion cache, the
+// singleflight dedup and the download semaphore all apply per file.
+func (c ConvertController) startBatchConversion(
+	rw http.ResponseWriter, r *http.Request,
+	ures response.UserResponse, state request.DriveState, nonce string,
+) {
+	jobID := c.jobs.StartBatch(state.IDS)
+	jobCtx, cancel := context.WithTimeout(context.Background(), conversionPipelineTimeout)
+	c.jobs.AttachCancel(jobID, cancel)
+
+	go func() {
+		defer cancel()
+		runConversionBatch(
+			jobCtx, state.IDS,
+			c.onlyoffice.Config().Onlyoffice.Conversion.Parallelism,
+			func(ctx context.Context, fileID string) error {
+				fileState := state
+				fileState.IDS = []string{fileID}
+				// Per-file phases would interleave across workers, so batch
+				// progress is tracked per file instead.
+				_, err := c.convertFile(r.WithContext(ctx), ures, fileState, "", func(string, int) {})
+				if shared.IsExportSizeLimitError(err) {
+					return fmt.Errorf("this file is too large to convert")
+				}
+				if isPasswordError(err) {
+					return fmt.Errorf("this file is password protected")
+				}
+				return err
+			},
+			func(fileID, status, reason string) {
+				c.jobs.UpdateFile(jobID, fileID, status, reason)
+			},
+		)
+		c.jobs.FinishBatch(jobID)
+	}()
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusAccepted)
+	rw.Write(response.ConvertJobResponse{Job: jobID, Nonce: nonce}.ToJSON())
+}
+
+// BuildConvertCancel aborts a running conversion job. The page reports
+// abandonment with a beacon when the user navigates away, so pending batch
+// files never run for a viewer who already left.
+func (c ConvertController) BuildConvertCancel() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		c.jobs.Cancel(r.URL.Query().Get("job"))
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// BuildConvertProgress reports where a conversion job started by the
+// create action stands: its phase and percent while running, the relaunch
+// state once done.
+func (c ConvertController) BuildConvertProgress() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		status, ok := c.jobs.Get(r.URL.Query().Get("job"))
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(status.ToJSON())
+	}
+}
+
+// convertFile asks the Document Server to convert the state file into its
+// OOXML counterpart and inserts the result next to the source file,
+// reporting each pipeline phase through progress as it goes.
+func (c ConvertController) convertFile(
+	r *http.Request, ures response.UserResponse, state request.DriveState,
+	password string, progress func(phase string, percent int),
+) (*request.DriveState, error) {
+	gclient := (&oauth2.Config{
+		ClientID:     c.credentials.Credentials.ClientID,
+		ClientSecret: c.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(r.Context(), &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	srv, err := drive.NewService(r.Context(), option.WithHTTPClient(gclient))
+	if err != nil {
+		return nil, err
+	}
+
+	c.monitor.Observe("files.get")
+	file, err := srv.Files.Get(state.IDS[0]).Do()
+	if err != nil {
+		c.monitor.ObserveError("files.get", err)
+		return nil, err
+	}
+
+	if file.MimeType == shared.GoogleFolderMime {
+		return nil, fmt.Errorf("%s is a folder and cannot be converted", file.Id)
+	}
+
+	// An unchanged source revision that was already converted is reopened
+	// through its existing copy instead of converting again.
+	revision := file.Md5Checksum
+	if revision == "" {
+		revision = file.ModifiedDate
+	}
+	// A template-preserving copy and a plain-document copy of the same
+	// source are different results; the flavor joins the cache key so a
+	// reopen never serves the other one.
+	if state.AsTemplate {
+		revision += ":template"
+	}
+	if targetID, ok := c.conversions.Get(file.Id, revision); ok {
+		c.monitor.Observe("files.get")
+		if _, err := srv.Files.Get(targetID).Do(); err == nil {
+			return &request.DriveState{
+				IDS:    []string{targetID},
+				Action: "edit",
+				UserID: state.UserID,
+			}, nil
+		} else {
+			c.monitor.ObserveError("files.get", err)
+			c.conversions.Invalidate(file.Id)
+		}
+	}
+
+	ext, mismatch := shared.EffectiveExtension(file.FileExtension, file.MimeType)
+	if mismatch {
+		c.logger.Warnf(
+			"file %s reports extension .%s but is Google-native (%s); converting it as .%s",
+			file.Id, file.FileExtension, file.MimeType, ext,
+		)
+	}
+	// The converted copy gets a new name, so it is sanitized for client
+	// filesystems; the source file keeps its title untouched.
+	title := c.fileUtility.SanitizeTitle(strings.TrimSuffix(file.Title, "."+ext))
+	// A renamed file — an .xls posing as .xlsx — would be declared to the
+	// Document Server with the wrong filetype and fail confusingly, so the
+	// declared extension follows the content when the two contradict.
+	if corrected := c.sniffedExtension(r, gclient, file, ext); corrected != ext {
+		c.logger.Warnf(
+			"file %s is named .%s but its content looks like .%s; converting it as .%s",
+			file.Id, ext, corrected, corrected,
+		)
+		ext = corrected
+	}
+	fileType, err := c.fileUtility.GetFileType(ext)
+	if err != nil {
+		return nil, err
+	}
+	outputExt, err := c.conversionOutput(ext, fileType, state.AsTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	// The download proxy serves an .eml's extracted body HTML rather than
+	// the raw message, so the Document Server is told it converts html.
+	declaredExt := ext
+	if c.fileUtility.IsExtensionEmail(ext) {
+		if !c.onlyoffice.Config().Onlyoffice.Builder.EmailPreview {
+			return nil, fmt.Errorf("email preview is disabled for .%s files", ext)
+		}
+		declaredExt = "html"
+	}
+
+	// Two users converting the same shared file at the same moment would
+	// otherwise run two conversions and create two copies; the second caller
+	// waits for the first result and is redirected to the same created file.
+	result, err := c.convertOnce(fmt.Sprintf("%s:%s", file.Id, outputExt), func() (conversionResult, error) {
+		// The whole pipeline run is timed against the bytes actually
+		// streamed, feeding the capacity-planning histograms.
+		pipelineStart := time.Now()
+
+		sourceURL, err := c.sources.SourceURL(ures.ID, file.Id)
+		if err != nil {
+			return conversionResult{}, err
+		}
+		// Conversions have no editor session; the recorded issuance is what
+		// lets the Document Server fetch the source through the download
+		// proxy.
+		if c.registry != nil {
+			c.registry.NoteIssued(ures.ID, file.Id)
+		}
+
+		creq, err := c.buildConvertAPIRequest(declaredExt, outputExt, file.Title, sourceURL, password)
+		if err != nil {
+			return conversionResult{}, err
+		}
+
+		// The copy inherits whatever format actually converted; a fallback
+		// retry below may move it off the primary output.
+		output := outputExt
+		degraded := false
+
+		progress(ConvertPhaseConverting, 0)
+		cresp, err := c.sendConvertRequest(r, creq, func(percent int) {
+			progress(ConvertPhaseConverting, percent)
+		})
+		if err != nil {
+			// Some conversions the Document Server refuses outright still
+			// succeed into another format — a .mht that will not become docx
+			// often survives as pdf. The strategy table decides, once.
+			alternate, ok := c.conversionFallback(ext, output, err)
+			if !ok {
+				return conversionResult{}, err
+			}
+			c.logger.Warnf(
+				"conversion of %s from .%s to .%s was rejected (%s); retrying once as .%s",
+				file.Id, ext, output, err.Error(), alternate,
+			)
+			output = alternate
+			degraded = true
+
+			creq, err = c.buildConvertAPIRequest(declaredExt, output, file.Title, sourceURL, password)
+			if err != nil {
+				return conversionResult{}, err
+			}
+			progress(ConvertPhaseConverting, 0)
+			cresp, err = c.sendConvertRequest(r, creq, func(percent int) {
+				progress(ConvertPhaseConverting, percent)
+			})
+			if err != nil {
+				return conversionResult{}, err
+			}
+		}
+
+		parents, notice := convertDestination(func(id string) (*drive.File, error) {
+			c.monitor.Observe("files.get")
+			folder, err := srv.Files.Get(id).SupportsAllDrives(true).Do()
+			if err != nil {
+				c.monitor.ObserveError("files.get", err)
+			}
+			return folder, err
+		}, state.DestinationID, file)
+		if notice != "" {
+			c.logger.Warnf(
+				"conversion destination %s rejected for file %s: %s",
+				state.DestinationID, file.Id, notice,
+			)
+		}
+
+		// The download permit brackets fetching the conversion result and
+		// re-uploading it; it is released on any exit, including panics.
+		var newFile *drive.File
+		var counted *timings.CountingReader
+		if err := c.onlyoffice.DownloadSemaphore().With(r.Context(), func() error {
+			progress(ConvertPhaseDownloading, 0)
+			body, err := c.downloadResult(r, cresp.FileURL)
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+			counted = timings.NewCountingReader(body)
+
+			progress(ConvertPhaseUploading, 0)
+
+			// Conversion inserts are not latency sensitive, so they take the
+			// backoff hit first when the quota is nearly exhausted.
+			c.monitor.Throttle(r.Context(), false)
+			c.monitor.Observe("files.insert")
+			// The tag lets the janitor sweep find abandoned copies later;
+			// see gateway/janitor.
+			newFile, err = srv.Files.Insert(&drive.File{
+				Title: c.fileUtility.TruncateTitle(
+					fmt.Sprintf("%s.%s", title, output),
+					c.onlyoffice.Config().Onlyoffice.Limits.MaxTitleLength,
+				),
+				Parents:    parents,
+				Properties: janitor.ConversionProperties(c.clock.Now()),
+			}).SupportsAllDrives(true).Media(counted).Do()
+			if err != nil {
+				c.monitor.ObserveError("files.insert", err)
+				return err
+			}
+			return nil
+		}); err != nil {
+			return conversionResult{}, err
+		}
+
+		if c.timings != nil {
+			c.timings.Observe(
+				timings.OperationConvert, fileType,
+				counted.Bytes(), time.Since(pipelineStart),
+			)
+		}
+
+		c.conversions.Put(file.Id, revision, newFile.Id)
+		if password != "" {
+			c.passwords.clear(file.Id)
+		}
+		if degraded && notice == "" {
+			notice = fmt.Sprintf(
+				"The file could not be converted to .%s; a .%s copy was created instead and some fidelity may be lost.",
+				outputExt, output,
+			)
+		}
+		return conversionResult{fileID: newFile.Id, notice: notice, degraded: degraded}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &request.DriveState{
+		IDS:      []string{result.fileID},
+		Action:   "edit",
+		UserID:   state.UserID,
+		Notice:   result.notice,
+		Degraded: result.degraded,
+	}, nil
+}
+
+// conversionOutput picks the created copy's format: the family's editable
+// OOXML extension, or its OOXML template extension when the page's explicit
+// "edit a template copy" choice rides in the state. Without the flag a
+// template source still becomes a plain document — the historical behavior,
+// kept as the "create a document from the template" choice.
+func (c ConvertController) conversionOutput(ext, fileType string, asTemplate bool) (string, error) {
+	output := map[string]string{"word": "docx", "cell": "xlsx", "slide": "pptx"}[fileType]
+	if !asTemplate {
+		return output, nil
+	}
+
+	templateExt, ok := c.fileUtility.TemplateOutputExtension(ext)
+	if !ok {
+		return "", fmt.Errorf(".%s is not a template format", ext)
+	}
+	return templateExt, nil
+}
+
+// buildConvertAPIRequest shapes one Document Server conversion request.
+// Async mode makes the server answer each post with its current percent
+// instead of blocking until the file is ready; the generated key ties the
+// polls to one conversion.
+func (c ConvertController) buildConvertAPIRequest(
+	ext, output, title, sourceURL, password string,
+) (request.ConvertAPIRequest, error) {
+	creq := request.ConvertAPIRequest{
+		Async:      true,
+		Filetype:   ext,
+		Key:        c.ids.NewID(),
+		Outputtype: output,
+		// The display title also travels to the Document Server, which
+		// rejects requests carrying Drive's longest titles.
+		Title: c.fileUtility.TruncateTitle(
+			title, c.onlyoffice.Config().Onlyoffice.Limits.MaxTitleLength,
+		),
+		URL:      sourceURL,
+		Password: password,
+	}
+
+	if secret := c.onlyoffice.Config().Onlyoffice.Builder.DocumentServerSecret; secret != "" {
+		claims := jwt.MapClaims{
+			"async": true, "filetype": ext, "key": creq.Key,
+			"outputtype": output, "title": creq.Title, "url": creq.URL,
+		}
+		if password != "" {
+			claims["password"] = password
+		}
+		token, err := c.jwtManager.Sign(secret, claims)
+		if err != nil {
+			return request.ConvertAPIRequest{}, err
+		}
+		creq.Token = token
+	}
+
+	return creq, nil
+}
+
+// sniffedExtension fetches the head of the file's content and corrects the
+// declared extension when the magic bytes contradict it. The sniff is best
+// effort: Google-native files have no fixed bytes to inspect, and any fetch
+// trouble keeps the declared extension.
+func (c ConvertController) sniffedExtension(
+	r *http.Request, gclient *http.Client, file *drive.File, ext string,
+) string {
+	if shared.IsGoogleNative(file.MimeType) {
+		return ext
+	}
+	sourceURL, _, err := downloadSource(file)
+	if err != nil {
+		return ext
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return ext
+	}
+	// Only the sniff window travels; Drive honors ranges on media downloads.
+	req.Header.Set("Range", "bytes=0-511")
+
+	resp, err := gclient.Do(req)
+	if err != nil {
+		return ext
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return ext
+	}
+
+	format, _ := c.fileUtility.SniffFormat(resp.Body)
+	if c.fileUtility.FormatMatchesExtension(ext, format) {
+		return ext
+	}
+	if corrected, ok := c.fileUtility.CorrectedExtension(ext, format); ok {
+		return corrected
+	}
+	return ext
+}
+
+// conversionAPIError carries the Document Server conversion error code, so
+// the pipeline can match a rejection against the configured fallback
+// triggers instead of parsing it back out of a message.
+type conversionAPIError struct {
+	code int
+}
+
+// conversionErrorPassword is the Document Server code for a source file it
+// cannot open without a password.
+const conversionErrorPassword = -5
+
+func (e *conversionAPIError) Error() string {
+	return fmt.Sprintf("conversion error %d", e.code)
+}
+
+// isPasswordError reports whether the Document Server refused the
+// conversion because the source file is password protected.
+func isPasswordError(err error) bool {
+	var aerr *conversionAPIError
+	return errors.As(err, &aerr) && aerr.code == conversionErrorPassword
+}
+
+// conversionFallback returns the configured alternate output format when
+// the error is a Document Server rejection whose code is one of the
+// fallback triggers. An alternate equal to the output that just failed is
+// ignored, so a misconfigured table never retries the same conversion.
+func (c ConvertController) conversionFallback(ext, output string, err error) (string, bool) {
+	var aerr *conversionAPIError
+	if !errors.As(err, &aerr) {
+		return "", false
+	}
+	alternate, ok := c.onlyoffice.Config().Onlyoffice.Conversion.FallbackOutput(ext, aerr.code)
+	if !ok || alternate == output {
+		return "", false
+	}
+	return alternate, true
+}
+
+// conversionResult is what one pipeline run produces: the created copy,
+// the destination notice of the caller that actually ran it, and whether
+// the copy came out of a fallback retry rather than the primary format.
+type conversionResult struct {
+	fileID   string
+	notice   string
+	degraded bool
+}
+
+// convertOnce runs convert through the singleflight group, so concurrent
+// calls for the same source file and target format share one pipeline run.
+// The group only deduplicates within this process; across instances the
+// conversion cache converges requests after the first one completes.
+func (c ConvertController) convertOnce(
+	key string, convert func() (conversionResult, error),
+) (conversionResult, error) {
+	result, err, _ := c.group.Do(key, func() (interface{}, error) {
+		res, err := convert()
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	})
+	if err != nil {
+		return conversionResult{}, err
+	}
+
+	return result.(conversionResult), nil
+}
+
+// sendConvertRequest drives one conversion through the Document Server in
+// async mode: the request is re-posted until the server reports the end of
+// the conversion, feeding its percent into progress after every poll.
+func (c ConvertController) sendConvertRequest(
+	r *http.Request, creq request.ConvertAPIRequest, progress func(percent int),
+) (*response.ConvertResponse, error) {
+	for {
+		cresp, err := c.postConvertRequest(r, creq)
+		if err != nil {
+			return nil, err
+		}
+
+		progress(cresp.Percent)
+		if cresp.EndConvert {
+			return cresp, nil
+		}
+
+		select {
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (c ConvertController) postConvertRequest(
+	r *http.Request, creq request.ConvertAPIRequest,
+) (*response.ConvertResponse, error) {
+	req, err := http.NewRequestWithContext(
+		r.Context(), http.MethodPost,
+		fmt.Sprintf("%s/ConvertService.ashx", c.onlyoffice.Config().Onlyoffice.Builder.DocumentServerURL),
+		bytes.NewReader(creq.ToJSON()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", buildinfo.UserAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var cresp response.ConvertResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cresp); err != nil {
+		return nil, err
+	}
+
+	if cresp.Error != 0 {
+		return nil, &conversionAPIError{code: cresp.Error}
+	}
+
+	return &cresp, nil
+}
+
+func (c ConvertController) downloadResult(r *http.Request, fileURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", buildinfo.UserAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// A Document Server error page inserted into Drive would pass for the
+	// converted copy; anything but 200 fails the pipeline instead.
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("document server answered %d for the conversion result", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
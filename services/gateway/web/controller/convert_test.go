@@ -0,0 +1,588 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/cache"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/settings"
+	"golang.org/x/sync/singleflight"
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestConvertOnceCollapsesConcurrentConversions(t *testing.T) {
+	controller := ConvertController{group: &singleflight.Group{}}
+
+	var inserts int32
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	convert := func() (conversionResult, error) {
+		atomic.AddInt32(&inserts, 1)
+		started <- struct{}{}
+		<-release
+		return conversionResult{fileID: "converted-copy"}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]conversionResult, 2)
+	errs := make([]error, 2)
+	for i := 0; i < 2; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = controller.convertOnce("file-1:docx", convert)
+		}()
+		if i == 0 {
+			// The first caller must be inside the pipeline before the second
+			// one starts, so the second one has something to wait on.
+			<-started
+		}
+	}
+
+	// Give the second caller time to join the in-flight run, then let the
+	// pipeline finish.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&inserts); got != 1 {
+		t.Errorf("expected exactly one pipeline run, got %d", got)
+	}
+	for i := 0; i < 2; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if results[i].fileID != "converted-copy" {
+			t.Errorf("caller %d expected the shared copy, got %q", i, results[i].fileID)
+		}
+	}
+}
+
+func newFallbackTestController(t *testing.T) ConvertController {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+  conversion:
+    fallbacks:
+      mht: pdf
+    fallback_error_codes: [-3]
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ConvertController{onlyoffice: source}
+}
+
+// A Document Server rejection with a configured code and a mapped source
+// extension must produce exactly the configured alternate.
+func TestConversionFallbackTriggers(t *testing.T) {
+	controller := newFallbackTestController(t)
+
+	alternate, ok := controller.conversionFallback("mht", "docx", &conversionAPIError{code: -3})
+	if !ok || alternate != "pdf" {
+		t.Fatalf("expected a pdf fallback, got %q, %t", alternate, ok)
+	}
+}
+
+// Everything outside the strategy table keeps the current behavior: the
+// rejection surfaces and nothing is retried.
+func TestConversionFallbackDefaultsToNoRetry(t *testing.T) {
+	controller := newFallbackTestController(t)
+
+	for name, tc := range map[string]struct {
+		ext    string
+		output string
+		err    error
+	}{
+		"unlisted error code":      {ext: "mht", output: "docx", err: &conversionAPIError{code: -2}},
+		"unmapped extension":       {ext: "xls", output: "xlsx", err: &conversionAPIError{code: -3}},
+		"not a rejection":          {ext: "mht", output: "docx", err: errors.New("connection refused")},
+		"alternate already failed": {ext: "mht", output: "pdf", err: &conversionAPIError{code: -3}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if alternate, ok := controller.conversionFallback(tc.ext, tc.output, tc.err); ok {
+				t.Errorf("expected no fallback, got %q", alternate)
+			}
+		})
+	}
+}
+
+// The force-edit decision made by the convert action must survive the full
+// chain the page follows afterwards: the state JSON answered to the POST
+// and the /api/editor redirect URL built from it.
+func TestForceEditSurvivesTheConvertRedirectChain(t *testing.T) {
+	controller := ConvertController{nonces: cache.NewNonceStore(time.Minute)}
+
+	body := fmt.Sprintf(
+		`{"ids":["file-id-0123456789"],"action":"edit","userId":"1000000000","nonce":"%s"}`,
+		controller.nonces.Issue("1000000000"),
+	)
+	req := httptest.NewRequest("POST", "/api/convert", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(
+		req.Context(), middleware.ContextUser, response.UserResponse{ID: "1000000000"},
+	))
+	rec := httptest.NewRecorder()
+	controller.BuildConvertFile()(rec, req)
+
+	if rec.Code != 200 {
+		t.Fatalf("expected the edit action to answer 200, got %d", rec.Code)
+	}
+
+	// The same body posted again replays a spent nonce and must be refused.
+	replay := httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/convert", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(
+		req.Context(), middleware.ContextUser, response.UserResponse{ID: "1000000000"},
+	))
+	controller.BuildConvertFile()(replay, req)
+	if replay.Code != http.StatusConflict {
+		t.Fatalf("expected the replayed body to answer 409, got %d", replay.Code)
+	}
+	var state request.DriveState
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if !state.ForceEdit {
+		t.Fatal("expected the edit action to set the force-edit flag")
+	}
+
+	// A nil store inlines the state, which is how short states like this
+	// one travel in production too.
+	redirect := (*middleware.StateStore)(nil).StateURL("/api/editor", state)
+	target, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var launched request.DriveState
+	if err := json.Unmarshal([]byte(target.Query().Get("state")), &launched); err != nil {
+		t.Fatal(err)
+	}
+	if !launched.ForceEdit {
+		t.Error("expected the flag to survive into the editor launch state")
+	}
+}
+
+// capturingLogger records every formatted line, so tests can assert what
+// never reaches the logs.
+type capturingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *capturingLogger) record(format string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) Debugf(format string, args ...interface{}) { l.record(format, args) }
+func (l *capturingLogger) Infof(format string, args ...interface{})  { l.record(format, args) }
+func (l *capturingLogger) Warnf(format string, args ...interface{})  { l.record(format, args) }
+func (l *capturingLogger) Errorf(format string, args ...interface{}) { l.record(format, args) }
+func (l *capturingLogger) Fatalf(format string, args ...interface{}) { l.record(format, args) }
+
+func (l *capturingLogger) dump() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.lines, "\n")
+}
+
+// A supplied document password must reach exactly one place — the outbound
+// conversion payload — and never a log line, even on the paths that do log
+// the request going wrong.
+func TestConvertPasswordTravelsOnlyToTheConverter(t *testing.T) {
+	var received []byte
+	ds := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		rw.Write(response.ConvertResponse{
+			EndConvert: true,
+			FileURL:    "http://converted",
+			Percent:    100,
+		}.ToJSON())
+	}))
+	defer ds.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := fmt.Sprintf(`onlyoffice:
+  builder:
+    document_server_url: "%s"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+`, ds.URL)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &capturingLogger{}
+	controller := ConvertController{
+		fileUtility: files.NewOnlyofficeFileUtility(),
+		onlyoffice:  source,
+		passwords:   newPasswordGate(),
+		logger:      logger,
+		ids:         shared.UUIDGenerator{},
+	}
+
+	creq, err := controller.buildConvertAPIRequest(
+		"docx", "pdf", "contract", "https://gateway.example.com/api/source", "hunter2",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creq.Password != "hunter2" {
+		t.Fatalf("expected the password on the conversion request, got %q", creq.Password)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if _, err := controller.sendConvertRequest(req, creq, func(int) {}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(received), `"password":"hunter2"`) {
+		t.Error("expected the outbound conversion payload to carry the password")
+	}
+
+	// An invalid payload is the convert endpoint's chattiest path; even
+	// there the logged validation errors must not echo the password.
+	rec := httptest.NewRecorder()
+	post := httptest.NewRequest("POST", "/api/convert", strings.NewReader(
+		`{"ids":["bad"],"action":"create","password":"hunter2"}`,
+	))
+	controller.BuildConvertFile()(rec, post)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the invalid payload to answer 400, got %d", rec.Code)
+	}
+
+	if logged := logger.dump(); strings.Contains(logged, "hunter2") {
+		t.Errorf("expected the password to stay out of the logs, got %q", logged)
+	}
+}
+
+// A Document Server answering the result fetch with an error page must fail
+// the download instead of handing the error HTML over as the converted
+// bytes.
+func TestDownloadResultRejectsErrorStatuses(t *testing.T) {
+	ds := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(rw, "<html>not found</html>")
+	}))
+	defer ds.Close()
+
+	controller := ConvertController{}
+	body, err := controller.downloadResult(
+		httptest.NewRequest("GET", "/", nil), ds.URL+"/cache/file.docx",
+	)
+	if err == nil {
+		body.Close()
+		t.Fatal("expected the error status to fail the download")
+	}
+	if !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected the error to carry the status, got %q", err.Error())
+	}
+}
+
+// fakePreferenceDrive keeps the settings document in memory, standing in
+// for the appDataFolder during preference tests.
+type fakePreferenceDrive struct {
+	fileID  string
+	content []byte
+}
+
+func (d *fakePreferenceDrive) List(query string) ([]*drive.File, error) {
+	if d.fileID == "" {
+		return nil, nil
+	}
+	return []*drive.File{{Id: d.fileID}}, nil
+}
+
+func (d *fakePreferenceDrive) Download(id string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(d.content)), nil
+}
+
+func (d *fakePreferenceDrive) Insert(file *drive.File, content io.Reader) (*drive.File, error) {
+	d.fileID = "settings-file"
+	d.content, _ = io.ReadAll(content)
+	return &drive.File{Id: d.fileID}, nil
+}
+
+func (d *fakePreferenceDrive) Update(id string, content io.Reader) (*drive.File, error) {
+	d.content, _ = io.ReadAll(content)
+	return &drive.File{Id: id}, nil
+}
+
+func newPreferenceTestController(fake *fakePreferenceDrive) ConvertController {
+	return ConvertController{
+		fileUtility: files.NewOnlyofficeFileUtility(),
+		settings:    settings.NewStore(time.Minute),
+		nonces:      cache.NewNonceStore(time.Minute),
+		settingsClient: func(*http.Request, response.UserResponse) (settings.SettingsDrive, error) {
+			return fake, nil
+		},
+	}
+}
+
+func convertPageRequest(state request.DriveState) *http.Request {
+	req := httptest.NewRequest("GET", "/api/convert", nil)
+	ctx := context.WithValue(req.Context(), middleware.ContextFile, &drive.File{
+		Id:            state.IDS[0],
+		Title:         "report.csv",
+		FileExtension: "csv",
+		MimeType:      "text/csv",
+	})
+	ctx = context.WithValue(ctx, middleware.ContextState, state)
+	ctx = context.WithValue(ctx, middleware.ContextUser, response.UserResponse{ID: state.UserID})
+	return req.WithContext(ctx)
+}
+
+// Choosing an action with "don't ask again" must persist the preference,
+// and the next page load for that format must skip straight to the editor
+// with the remembered mode.
+func TestOpenAsPreferenceSkipsTheConvertPage(t *testing.T) {
+	fake := &fakePreferenceDrive{}
+	controller := newPreferenceTestController(fake)
+	state := request.DriveState{IDS: []string{"file-id-0123456789"}, UserID: "1000000000"}
+
+	body := fmt.Sprintf(
+		`{"ids":["file-id-0123456789"],"action":"view","userId":"1000000000",`+
+			`"extension":"csv","dontAsk":true,"nonce":"%s"}`,
+		controller.nonces.Issue("1000000000"),
+	)
+	req := httptest.NewRequest("POST", "/api/convert", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(
+		req.Context(), middleware.ContextUser, response.UserResponse{ID: "1000000000"},
+	))
+	rec := httptest.NewRecorder()
+	controller.BuildConvertFile()(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected the view action to answer 200, got %d", rec.Code)
+	}
+
+	stored, err := controller.settings.Get(fake, "1000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action, skip := stored.OpenAsPreference("csv"); action != "view" || !skip {
+		t.Fatalf("expected a skipping view preference, got %q/%t", action, skip)
+	}
+
+	rec = httptest.NewRecorder()
+	controller.BuildConvertPage()(rec, convertPageRequest(state))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected the page to redirect, got %d", rec.Code)
+	}
+	target, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Path != "/api/editor" {
+		t.Fatalf("expected an editor redirect, got %s", target.Path)
+	}
+	var launched request.DriveState
+	if err := json.Unmarshal([]byte(target.Query().Get("state")), &launched); err != nil {
+		t.Fatal(err)
+	}
+	if launched.ForceEdit {
+		t.Error("expected a view preference to launch without force-edit")
+	}
+}
+
+// A remembered choice without "don't ask again" still shows the page, with
+// the stored action marked so the page can highlight it.
+func TestOpenAsPreferenceWithoutSkipShowsThePage(t *testing.T) {
+	fake := &fakePreferenceDrive{}
+	controller := newPreferenceTestController(fake)
+	state := request.DriveState{IDS: []string{"file-id-0123456789"}, UserID: "1000000000"}
+
+	remembered := settings.UserSettings{}
+	remembered.RememberOpenAs("csv", "view", false)
+	if err := controller.settings.Save(fake, "1000000000", remembered); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	controller.BuildConvertPage()(rec, convertPageRequest(state))
+	if rec.Code != 200 {
+		t.Fatalf("expected the page to render, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `data-preferred="view"`) {
+		t.Error("expected the page to carry the remembered choice")
+	}
+}
+
+// newTemplatePageController wires the pieces the template choice flow
+// touches: the preference store plus a config source carrying the
+// template_edit switch.
+func newTemplatePageController(t *testing.T, fake *fakePreferenceDrive, templateEdit bool) ConvertController {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := fmt.Sprintf(`onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+    template_edit: %t
+`, templateEdit)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controller := newPreferenceTestController(fake)
+	controller.onlyoffice = source
+	return controller
+}
+
+func templatePageRequest(state request.DriveState) *http.Request {
+	req := httptest.NewRequest("GET", "/api/convert", nil)
+	ctx := context.WithValue(req.Context(), middleware.ContextFile, &drive.File{
+		Id:            state.IDS[0],
+		Title:         "letterhead.dotx",
+		FileExtension: "dotx",
+		MimeType:      "application/vnd.openxmlformats-officedocument.wordprocessingml.template",
+	})
+	ctx = context.WithValue(ctx, middleware.ContextState, state)
+	ctx = context.WithValue(ctx, middleware.ContextUser, response.UserResponse{ID: state.UserID})
+	return req.WithContext(ctx)
+}
+
+// A template source must get the explicit choice: both create flavors on
+// the page and no autostart, even when a remembered "don't ask again"
+// create preference would normally skip straight into the conversion —
+// remembered choices cannot tell the two flavors apart.
+func TestTemplateConvertPageOffersTheChoice(t *testing.T) {
+	fake := &fakePreferenceDrive{}
+	controller := newTemplatePageController(t, fake, false)
+	state := request.DriveState{IDS: []string{"file-id-0123456789"}, UserID: "1000000000"}
+
+	remembered := settings.UserSettings{}
+	remembered.RememberOpenAs("dotx", "create", true)
+	if err := controller.settings.Save(fake, "1000000000", remembered); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	controller.BuildConvertPage()(rec, templatePageRequest(state))
+	if rec.Code != 200 {
+		t.Fatalf("expected the page to render, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "convert-create-template") {
+		t.Error("expected the template-copy choice on the page")
+	}
+	if !strings.Contains(body, "Create a document from the template") {
+		t.Error("expected the create flavor to name the template")
+	}
+	if strings.Contains(body, "data-autostart") {
+		t.Error("expected a remembered create not to autostart for a template")
+	}
+}
+
+// With template editing enabled on the Document Server, the OOXML template
+// formats skip the conversion page entirely and open in the editor.
+func TestTemplateEditSkipsTheConvertPage(t *testing.T) {
+	fake := &fakePreferenceDrive{}
+	controller := newTemplatePageController(t, fake, true)
+	state := request.DriveState{IDS: []string{"file-id-0123456789"}, UserID: "1000000000"}
+
+	rec := httptest.NewRecorder()
+	controller.BuildConvertPage()(rec, templatePageRequest(state))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected the page to redirect, got %d", rec.Code)
+	}
+	target, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Path != "/api/editor" {
+		t.Fatalf("expected an editor redirect, got %s", target.Path)
+	}
+}
+
+// The explicit choice picks the pipeline's target: the plain family output
+// without it, the OOXML template extension with it — and only template
+// sources may ask for one.
+func TestConversionOutputHonorsTheTemplateChoice(t *testing.T) {
+	controller := ConvertController{fileUtility: files.NewOnlyofficeFileUtility()}
+
+	for name, tc := range map[string]struct {
+		ext        string
+		fileType   string
+		asTemplate bool
+		want       string
+		wantErr    bool
+	}{
+		"document template kept":   {ext: "dotx", fileType: "word", asTemplate: true, want: "dotx"},
+		"document from a template": {ext: "dotx", fileType: "word", want: "docx"},
+		"legacy template kept":     {ext: "xlt", fileType: "cell", asTemplate: true, want: "xltx"},
+		"presentation template":    {ext: "potm", fileType: "slide", asTemplate: true, want: "potx"},
+		"not a template":           {ext: "csv", fileType: "cell", asTemplate: true, wantErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := controller.conversionOutput(tc.ext, tc.fileType, tc.asTemplate)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("conversionOutput(%q, %q, %t) = %q, want %q", tc.ext, tc.fileType, tc.asTemplate, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/cache"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/settings"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"go-micro.dev/v4/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	goauth "google.golang.org/api/oauth2/v2"
+	"google.golang.org/api/option"
+)
+
+// CreateController renders the new-document page and creates files from the
+// embedded office templates.
+type CreateController struct {
+	client      client.Client
+	fileUtility files.OnlyofficeFileUtility
+	server      *config.ServerConfig
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	settings    *settings.Store
+	// nonces binds rendered create pages to the POSTs they submit, so a
+	// captured body cannot be replayed against the user's Drive.
+	nonces *cache.NonceStore
+	logger log.Logger
+}
+
+func NewCreateController(
+	client client.Client, fileUtility files.OnlyofficeFileUtility,
+	server *config.ServerConfig, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource, settings *settings.Store,
+	nonceStore *cache.NonceStore, logger log.Logger,
+) CreateController {
+	return CreateController{
+		client:      client,
+		fileUtility: fileUtility,
+		server:      server,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		settings:    settings,
+		nonces:      nonceStore,
+		logger:      logger,
+	}
+}
+
+// BuildCreatePage renders the new-document form.
+func (c CreateController) BuildCreatePage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		state, _ := r.Context().Value(middleware.ContextState).(request.DriveState)
+		// The embedded nonce is spent by the POST the form submits; failed
+		// validations answer before it is consumed, so fixing a field and
+		// resubmitting keeps the same one.
+		var nonce string
+		if ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse); ok {
+			nonce = c.nonces.Issue(ures.ID)
+		}
+		embeddable.CreatePage.Execute(rw, map[string]interface{}{
+			"state": string(state.ToJSON()),
+			"nonce": nonce,
+		})
+	}
+}
+
+// BuildCreateFile validates the /api/create POST body and seeds a new Drive
+// file from the embedded template matching the requested type.
+func (c CreateController) BuildCreateFile() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var body request.CreateRequest
+		if err := request.DecodeStrict(r.Body, &body); err != nil {
+			c.logger.Debugf("malformed create payload: %s", err.Error())
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusBadRequest)
+			if verrs, ok := err.(request.ValidationErrors); ok {
+				rw.Write(verrs.ToJSON())
+			}
+			return
+		}
+
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		gclient := (&oauth2.Config{
+			ClientID:     c.credentials.Credentials.ClientID,
+			ClientSecret: c.credentials.Credentials.ClientSecret,
+			Endpoint:     google.Endpoint,
+		}).Client(r.Context(), &oauth2.Token{
+			AccessToken:  ures.AccessToken,
+			RefreshToken: ures.RefreshToken,
+			TokenType:    ures.TokenType,
+		})
+
+		srv, err := drive.NewService(r.Context(), option.WithHTTPClient(gclient))
+		if err != nil {
+			c.logger.Errorf("could not initialize drive service: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		requested := ""
+		if uinfo, ok := r.Context().Value(middleware.ContextUserinfo).(*goauth.Userinfo); ok {
+			requested = uinfo.Locale
+		}
+		// A stored locale preference wins over the account locale; settings
+		// are best effort and never fail a create.
+		if stored, err := c.settings.Get(settings.NewDriveClient(srv), ures.ID); err == nil &&
+			stored.Locale != "" {
+			requested = stored.Locale
+		}
+		// A create without a name gets the localized default for its type,
+		// e.g. "Новый документ.docx" for a Russian account.
+		if body.Name == "" {
+			body.Name = embeddable.DefaultFileName(requested, body.Type)
+		}
+
+		if err := body.Validate(); err != nil {
+			c.logger.Debugf("invalid create payload: %s", err.Error())
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusBadRequest)
+			if verrs, ok := err.(request.ValidationErrors); ok {
+				rw.Write(verrs.ToJSON())
+			}
+			return
+		}
+
+		// The destination may come from the folder browser rather than the
+		// Drive launch context, so its writability is re-checked right
+		// before the insert.
+		if verrs := createDestination(func(id string) (*drive.File, error) {
+			call := srv.Files.Get(id).SupportsAllDrives(true)
+			if body.FolderResourceKey != "" {
+				call.Header().Set(
+					"X-Goog-Drive-Resource-Keys",
+					fmt.Sprintf("%s/%s", id, body.FolderResourceKey),
+				)
+			}
+			return call.Do()
+		}, body.FolderID); verrs != nil {
+			c.logger.Debugf("unusable create destination %s: %s", body.FolderID, verrs.Error())
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write(verrs.ToJSON())
+			return
+		}
+
+		// The nonce is spent only once the body is actually going to create a
+		// file, so fixing a rejected field keeps the page usable; a replayed
+		// body fails here and the user is asked to reload.
+		if !c.nonces.Consume(ures.ID, body.Nonce) {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusConflict)
+			rw.Write(request.ValidationErrors{{
+				Field:   "page",
+				Message: embeddable.StalePageMessage(userLocale(r)),
+			}}.ToJSON())
+			return
+		}
+
+		// Only folders with a genuinely localized variant for this file type
+		// are considered, so a locale whose template is just a copy of the
+		// default does not shadow a closer match.
+		locale := embeddable.MatchTemplateVariant(requested, body.Type)
+
+		template, err := embeddable.OfficeTemplate(locale, body.Type)
+		if err != nil {
+			c.logger.Errorf("could not load the %s template: %s", body.Type, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		file := &drive.File{
+			Title: c.fileUtility.TruncateTitle(
+				fmt.Sprintf("%s.%s", c.fileUtility.SanitizeTitle(body.Name), body.Type),
+				c.onlyoffice.Config().Onlyoffice.Limits.MaxTitleLength,
+			),
+		}
+		if body.FolderID != "" {
+			file.Parents = []*drive.ParentReference{{Id: body.FolderID}}
+		}
+
+		insert := srv.Files.Insert(file).SupportsAllDrives(true).Media(template)
+		// Link-shared folders require their resource key on the insert
+		// call, otherwise Drive answers 404 for the parent reference.
+		if body.FolderID != "" && body.FolderResourceKey != "" {
+			insert.Header().Set(
+				"X-Goog-Drive-Resource-Keys",
+				fmt.Sprintf("%s/%s", body.FolderID, body.FolderResourceKey),
+			)
+		}
+
+		newFile, err := insert.Do()
+		if err != nil {
+			c.logger.Errorf("could not create a new file: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(request.DriveState{
+			IDS:    []string{newFile.Id},
+			Action: "edit",
+			UserID: ures.ID,
+		}.ToJSON())
+	}
+}
@@ -0,0 +1,81 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	drive "google.golang.org/api/drive/v2"
+)
+
+// convertDestination resolves the parents a converted copy is inserted
+// with. A chosen destination folder is re-checked right before the upload —
+// it may have been trashed or its sharing changed since the user picked it —
+// and an unusable one falls back to the source file's folder with a notice
+// explaining why. The get callback loads folder metadata; tests supply a
+// fake.
+func convertDestination(
+	get func(id string) (*drive.File, error),
+	destinationID string, source *drive.File,
+) ([]*drive.ParentReference, string) {
+	if destinationID == "" {
+		return source.Parents, ""
+	}
+
+	folder, err := get(destinationID)
+	if err != nil {
+		return source.Parents,
+			"The chosen folder is no longer accessible. The copy was saved next to the original."
+	}
+
+	if folder.MimeType != shared.GoogleFolderMime ||
+		(folder.Capabilities != nil && !folder.Capabilities.CanAddChildren) {
+		return source.Parents,
+			"The chosen folder cannot be written to. The copy was saved next to the original."
+	}
+
+	return []*drive.ParentReference{{Id: destinationID}}, ""
+}
+
+// createDestination validates the folder a new document is created in. A
+// create has no source file to fall back to the way a conversion does, so an
+// unusable folder is a validation error the form surfaces instead of a
+// silent save to the Drive root. Shared-drive folders pass as long as Drive
+// reports the user may add children to them.
+func createDestination(
+	get func(id string) (*drive.File, error), folderID string,
+) request.ValidationErrors {
+	if folderID == "" {
+		return nil
+	}
+
+	folder, err := get(folderID)
+	if err != nil {
+		return request.ValidationErrors{{Field: "folder_id", Message: "is not accessible"}}
+	}
+
+	if folder.MimeType != shared.GoogleFolderMime {
+		return request.ValidationErrors{{Field: "folder_id", Message: "is not a folder"}}
+	}
+	if folder.Capabilities != nil && !folder.Capabilities.CanAddChildren {
+		return request.ValidationErrors{{Field: "folder_id", Message: "cannot be written to"}}
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestConvertDestination(t *testing.T) {
+	source := &drive.File{
+		Id:      "source-file",
+		Parents: []*drive.ParentReference{{Id: "source-folder"}},
+	}
+	writable := &drive.File{
+		Id:           "destination-folder",
+		MimeType:     shared.GoogleFolderMime,
+		Capabilities: &drive.FileCapabilities{CanAddChildren: true},
+	}
+	readOnly := &drive.File{
+		Id:           "destination-folder",
+		MimeType:     shared.GoogleFolderMime,
+		Capabilities: &drive.FileCapabilities{CanAddChildren: false},
+	}
+
+	t.Run("no destination keeps the source folder", func(t *testing.T) {
+		parents, notice := convertDestination(func(id string) (*drive.File, error) {
+			t.Fatal("no lookup expected without a destination")
+			return nil, nil
+		}, "", source)
+		if len(parents) != 1 || parents[0].Id != "source-folder" || notice != "" {
+			t.Fatalf("unexpected resolution: %v %q", parents, notice)
+		}
+	})
+
+	t.Run("writable destination overrides the source folder", func(t *testing.T) {
+		parents, notice := convertDestination(func(id string) (*drive.File, error) {
+			return writable, nil
+		}, "destination-folder", source)
+		if len(parents) != 1 || parents[0].Id != "destination-folder" {
+			t.Fatalf("expected the chosen folder, got %v", parents)
+		}
+		if notice != "" {
+			t.Errorf("expected no notice, got %q", notice)
+		}
+	})
+
+	// The folder may lose its write capability between selection and
+	// upload; the copy still lands next to the original, with a notice.
+	t.Run("unwritable destination falls back with a notice", func(t *testing.T) {
+		parents, notice := convertDestination(func(id string) (*drive.File, error) {
+			return readOnly, nil
+		}, "destination-folder", source)
+		if len(parents) != 1 || parents[0].Id != "source-folder" {
+			t.Fatalf("expected the source folder fallback, got %v", parents)
+		}
+		if notice == "" {
+			t.Error("expected a notice explaining the fallback")
+		}
+	})
+
+	t.Run("vanished destination falls back with a notice", func(t *testing.T) {
+		parents, notice := convertDestination(func(id string) (*drive.File, error) {
+			return nil, errors.New("googleapi: Error 404: File not found")
+		}, "destination-folder", source)
+		if len(parents) != 1 || parents[0].Id != "source-folder" {
+			t.Fatalf("expected the source folder fallback, got %v", parents)
+		}
+		if notice == "" {
+			t.Error("expected a notice explaining the fallback")
+		}
+	})
+
+	t.Run("a plain file is not a destination", func(t *testing.T) {
+		parents, notice := convertDestination(func(id string) (*drive.File, error) {
+			return &drive.File{Id: "destination-folder", MimeType: "application/pdf"}, nil
+		}, "destination-folder", source)
+		if len(parents) != 1 || parents[0].Id != "source-folder" || notice == "" {
+			t.Fatalf("unexpected resolution: %v %q", parents, notice)
+		}
+	})
+}
+
+func TestCreateDestination(t *testing.T) {
+	for name, tc := range map[string]struct {
+		folderID string
+		folder   *drive.File
+		err      error
+		field    string
+	}{
+		"no folder needs no lookup": {},
+		"writable folder passes": {
+			folderID: "destination-folder",
+			folder: &drive.File{
+				Id:           "destination-folder",
+				MimeType:     shared.GoogleFolderMime,
+				Capabilities: &drive.FileCapabilities{CanAddChildren: true},
+			},
+		},
+		// Shared-drive folders report their writability through the same
+		// capability; nothing else about them is special here.
+		"shared drive folder passes": {
+			folderID: "shared-drive-folder",
+			folder: &drive.File{
+				Id:           "shared-drive-folder",
+				MimeType:     shared.GoogleFolderMime,
+				DriveId:      "0AExample",
+				Capabilities: &drive.FileCapabilities{CanAddChildren: true},
+			},
+		},
+		"inaccessible folder fails": {
+			folderID: "destination-folder",
+			err:      errors.New("googleapi: Error 404: File not found"),
+			field:    "folder_id",
+		},
+		"a plain file fails": {
+			folderID: "destination-folder",
+			folder:   &drive.File{Id: "destination-folder", MimeType: "application/pdf"},
+			field:    "folder_id",
+		},
+		"read-only folder fails": {
+			folderID: "destination-folder",
+			folder: &drive.File{
+				Id:           "destination-folder",
+				MimeType:     shared.GoogleFolderMime,
+				Capabilities: &drive.FileCapabilities{CanAddChildren: false},
+			},
+			field: "folder_id",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			verrs := createDestination(func(id string) (*drive.File, error) {
+				if tc.folderID == "" {
+					t.Fatal("no lookup expected without a folder")
+				}
+				return tc.folder, tc.err
+			}, tc.folderID)
+
+			if tc.field == "" {
+				if verrs != nil {
+					t.Fatalf("expected the destination to pass, got %s", verrs.Error())
+				}
+				return
+			}
+			if len(verrs) != 1 || verrs[0].Field != tc.field {
+				t.Fatalf("expected a %s validation error, got %v", tc.field, verrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// ExpectedRedirectURI returns the redirect endpoint the Google console must
+// be configured with for the given public gateway URL. Sub-path deployments
+// keep their prefix: "https://host/drive" expects ".../drive/oauth/redirect".
+func ExpectedRedirectURI(gatewayURL string) string {
+	return strings.TrimSuffix(gatewayURL, "/") + "/oauth/redirect"
+}
+
+// RedirectURIMatches reports whether the configured redirect URI points back
+// at the gateway's own redirect endpoint. Scheme and host compare
+// case-insensitively and a trailing slash on either side is forgiven; those
+// are the mismatches operators spend the longest not seeing.
+func RedirectURIMatches(redirectURI, gatewayURL string) bool {
+	configured, err := url.Parse(redirectURI)
+	if err != nil {
+		return false
+	}
+	expected, err := url.Parse(ExpectedRedirectURI(gatewayURL))
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(configured.Scheme, expected.Scheme) &&
+		strings.EqualFold(configured.Host, expected.Host) &&
+		strings.TrimSuffix(configured.Path, "/") == strings.TrimSuffix(expected.Path, "/")
+}
+
+// BuildGetDiagnostics reports the computed consent URL next to the
+// configured and expected redirect URIs, so a redirect mismatch can be
+// compared against the Google console without reading code. The route is
+// only registered in debug mode.
+func (c AuthController) BuildGetDiagnostics() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		gatewayURL := c.onlyoffice.Config().Onlyoffice.Builder.GatewayURL
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]interface{}{
+			"auth_code_url":           c.oauthConfig().AuthCodeURL("", oauth2.AccessTypeOffline),
+			"configured_redirect_uri": c.credentials.Credentials.RedirectURI,
+			"expected_redirect_uri":   ExpectedRedirectURI(gatewayURL),
+			"match": RedirectURIMatches(
+				c.credentials.Credentials.RedirectURI, gatewayURL,
+			),
+		})
+	}
+}
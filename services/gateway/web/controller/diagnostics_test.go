@@ -0,0 +1,103 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import "testing"
+
+func TestExpectedRedirectURI(t *testing.T) {
+	for name, tc := range map[string]struct {
+		gatewayURL string
+		expected   string
+	}{
+		"plain":          {gatewayURL: "https://gateway.example.com", expected: "https://gateway.example.com/oauth/redirect"},
+		"trailing slash": {gatewayURL: "https://gateway.example.com/", expected: "https://gateway.example.com/oauth/redirect"},
+		"sub-path":       {gatewayURL: "https://example.com/drive", expected: "https://example.com/drive/oauth/redirect"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := ExpectedRedirectURI(tc.gatewayURL); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRedirectURIMatches(t *testing.T) {
+	for name, tc := range map[string]struct {
+		redirectURI string
+		gatewayURL  string
+		matches     bool
+	}{
+		"exact match": {
+			redirectURI: "https://gateway.example.com/oauth/redirect",
+			gatewayURL:  "https://gateway.example.com",
+			matches:     true,
+		},
+		"trailing slash on the gateway url": {
+			redirectURI: "https://gateway.example.com/oauth/redirect",
+			gatewayURL:  "https://gateway.example.com/",
+			matches:     true,
+		},
+		"trailing slash on the redirect uri": {
+			redirectURI: "https://gateway.example.com/oauth/redirect/",
+			gatewayURL:  "https://gateway.example.com",
+			matches:     true,
+		},
+		"host case differs": {
+			redirectURI: "https://Gateway.Example.com/oauth/redirect",
+			gatewayURL:  "https://gateway.example.com",
+			matches:     true,
+		},
+		"sub-path deployment": {
+			redirectURI: "https://example.com/drive/oauth/redirect",
+			gatewayURL:  "https://example.com/drive",
+			matches:     true,
+		},
+		"different scheme": {
+			redirectURI: "http://gateway.example.com/oauth/redirect",
+			gatewayURL:  "https://gateway.example.com",
+			matches:     false,
+		},
+		"different host": {
+			redirectURI: "https://other.example.com/oauth/redirect",
+			gatewayURL:  "https://gateway.example.com",
+			matches:     false,
+		},
+		"missing sub-path": {
+			redirectURI: "https://example.com/oauth/redirect",
+			gatewayURL:  "https://example.com/drive",
+			matches:     false,
+		},
+		"wrong path": {
+			redirectURI: "https://gateway.example.com/oauth/callback",
+			gatewayURL:  "https://gateway.example.com",
+			matches:     false,
+		},
+		"unparsable redirect uri": {
+			redirectURI: "://not-a-url",
+			gatewayURL:  "https://gateway.example.com",
+			matches:     false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := RedirectURIMatches(tc.redirectURI, tc.gatewayURL); got != tc.matches {
+				t.Errorf("expected match=%t for %q against %q", tc.matches, tc.redirectURI, tc.gatewayURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	drive "google.golang.org/api/drive/v2"
+)
+
+var errNotDownloadable = errors.New("file has no downloadable content")
+
+// downloadSource resolves where a file's binary content comes from and the
+// content type to forward. Regular files download directly; Google-native
+// documents go through their OOXML export link. Native objects without an
+// export (forms, maps) and files Drive marks as not downloadable produce a
+// structured error instead of streaming Drive's JSON error body to the
+// Document Server as document content.
+func downloadSource(file *drive.File) (string, string, error) {
+	return contentSource(file.DownloadUrl, file.MimeType, file.ExportLinks)
+}
+
+// revisionSource is downloadSource for a pinned older revision, which carries
+// its own download and export links.
+func revisionSource(rev *drive.Revision) (string, string, error) {
+	return contentSource(rev.DownloadUrl, rev.MimeType, rev.ExportLinks)
+}
+
+func contentSource(url, mimeType string, exportLinks map[string]string) (string, string, error) {
+	contentType := mimeType
+
+	if url == "" && shared.IsGoogleNative(mimeType) {
+		exportMime, ok := shared.GoogleExportMime(mimeType)
+		if !ok {
+			return "", "", fmt.Errorf("%w: no export target for %s", errNotDownloadable, mimeType)
+		}
+		link, ok := exportLinks[exportMime]
+		if !ok {
+			return "", "", fmt.Errorf("%w: drive offers no %s export", errNotDownloadable, exportMime)
+		}
+		url, contentType = link, exportMime
+	}
+
+	if url == "" {
+		return "", "", errNotDownloadable
+	}
+
+	// Text content without an explicit charset makes the Document Server
+	// guess the encoding, and it guesses wrong on non-ASCII csv exports.
+	if strings.HasPrefix(contentType, "text/") && !strings.Contains(contentType, "charset") {
+		contentType += "; charset=UTF-8"
+	}
+
+	return url, contentType, nil
+}
@@ -0,0 +1,148 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestDownloadSource(t *testing.T) {
+	const sheetExport = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	for name, tc := range map[string]struct {
+		file        *drive.File
+		url         string
+		contentType string
+		err         bool
+	}{
+		"binary file downloads directly": {
+			file: &drive.File{
+				MimeType:    "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+				DownloadUrl: "https://drive.example.com/content",
+			},
+			url:         "https://drive.example.com/content",
+			contentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		},
+		"google sheet goes through its export link": {
+			file: &drive.File{
+				MimeType: "application/vnd.google-apps.spreadsheet",
+				ExportLinks: map[string]string{
+					sheetExport: "https://drive.example.com/export",
+				},
+			},
+			url:         "https://drive.example.com/export",
+			contentType: sheetExport,
+		},
+		"native type missing from the export map": {
+			file: &drive.File{MimeType: "application/vnd.google-apps.form"},
+			err:  true,
+		},
+		"native file without the expected export link": {
+			file: &drive.File{MimeType: "application/vnd.google-apps.spreadsheet"},
+			err:  true,
+		},
+		"file with no content at all": {
+			file: &drive.File{MimeType: "application/pdf"},
+			err:  true,
+		},
+		"text export gains a charset": {
+			file: &drive.File{
+				MimeType:    "text/csv",
+				DownloadUrl: "https://drive.example.com/content",
+			},
+			url:         "https://drive.example.com/content",
+			contentType: "text/csv; charset=UTF-8",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			url, contentType, err := downloadSource(tc.file)
+			if tc.err {
+				if !errors.Is(err, errNotDownloadable) {
+					t.Fatalf("expected errNotDownloadable, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if url != tc.url {
+				t.Errorf("expected url %q, got %q", tc.url, url)
+			}
+			if contentType != tc.contentType {
+				t.Errorf("expected content type %q, got %q", tc.contentType, contentType)
+			}
+		})
+	}
+}
+
+func TestRevisionSource(t *testing.T) {
+	const docExport = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+	for name, tc := range map[string]struct {
+		revision    *drive.Revision
+		url         string
+		contentType string
+		err         bool
+	}{
+		"binary revision downloads directly": {
+			revision: &drive.Revision{
+				MimeType:    "application/pdf",
+				DownloadUrl: "https://drive.example.com/revision",
+			},
+			url:         "https://drive.example.com/revision",
+			contentType: "application/pdf",
+		},
+		"google doc revision goes through its export link": {
+			revision: &drive.Revision{
+				MimeType: "application/vnd.google-apps.document",
+				ExportLinks: map[string]string{
+					docExport: "https://drive.example.com/revision-export",
+				},
+			},
+			url:         "https://drive.example.com/revision-export",
+			contentType: docExport,
+		},
+		"revision with no content at all": {
+			revision: &drive.Revision{MimeType: "application/pdf"},
+			err:      true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			url, contentType, err := revisionSource(tc.revision)
+			if tc.err {
+				if !errors.Is(err, errNotDownloadable) {
+					t.Fatalf("expected errNotDownloadable, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if url != tc.url {
+				t.Errorf("expected url %q, got %q", tc.url, url)
+			}
+			if contentType != tc.contentType {
+				t.Errorf("expected content type %q, got %q", tc.contentType, contentType)
+			}
+		})
+	}
+}
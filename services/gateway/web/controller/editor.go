@@ -0,0 +1,235 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/cache"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"go-micro.dev/v4/client"
+	drive "google.golang.org/api/drive/v2"
+	goauth "google.golang.org/api/oauth2/v2"
+)
+
+// DSHealthChecker is the slice of shared.DSHealth the editor needs: the
+// cached reachability verdict and the cache drop the browser beacon
+// triggers. An interface so tests can stub the probe.
+type DSHealthChecker interface {
+	Healthy(ctx context.Context) bool
+	Invalidate()
+}
+
+// EditorController asks the builder service for an editor config and
+// renders the editor page around it.
+type EditorController struct {
+	client     client.Client
+	server     *config.ServerConfig
+	onlyoffice *shared.OnlyofficeConfigSource
+	sessions   *sessions.Registry
+	configs    *cache.ConfigCache
+	health     DSHealthChecker
+	logger     log.Logger
+}
+
+func NewEditorController(
+	client client.Client, server *config.ServerConfig,
+	onlyoffice *shared.OnlyofficeConfigSource, sessions *sessions.Registry,
+	configCache *cache.ConfigCache, health DSHealthChecker, logger log.Logger,
+) EditorController {
+	return EditorController{
+		client:     client,
+		server:     server,
+		onlyoffice: onlyoffice,
+		sessions:   sessions,
+		configs:    configCache,
+		health:     health,
+		logger:     logger,
+	}
+}
+
+// userLocale is the launching user's locale when the session middleware
+// resolved a profile, empty otherwise.
+func userLocale(r *http.Request) string {
+	if uinfo, ok := r.Context().Value(middleware.ContextUserinfo).(*goauth.Userinfo); ok && uinfo != nil {
+		return uinfo.Locale
+	}
+	return ""
+}
+
+// BuildEditorPage resolves the launch state into a Document Server config
+// and renders the editor.
+func (c EditorController) BuildEditorPage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		state, ok := r.Context().Value(middleware.ContextState).(request.DriveState)
+		if !ok || len(state.IDS) < 1 {
+			http.Redirect(rw, r, "https://drive.google.com", http.StatusSeeOther)
+			return
+		}
+
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			http.Redirect(rw, r, "/oauth/install", http.StatusSeeOther)
+			return
+		}
+
+		if file, ok := r.Context().Value(middleware.ContextFile).(*drive.File); ok &&
+			file.CopyRequiresWriterPermission &&
+			file.Capabilities != nil && !file.Capabilities.CanEdit {
+			embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+				"errorMain":    "Viewing is restricted",
+				"errorSubtext": "The owner disabled downloading this file for readers, so it cannot be opened in the editor",
+			})
+			return
+		}
+
+		// A Document Server that failed its last reachability probe would
+		// leave the browser staring at a frame whose api.js never loads;
+		// the error page says what is actually wrong.
+		if !c.health.Healthy(r.Context()) {
+			c.logger.Warnf("document server is unreachable, not rendering the editor for %s", state.IDS[0])
+			// The page renders as a 200, so without the annotation a down
+			// Document Server would be invisible to the error counter.
+			shared.ClassifyError(rw, shared.ErrorCategoryDependency)
+			main, subtext := embeddable.DSUnavailableMessage(userLocale(r))
+			embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+				"errorMain":    main,
+				"errorSubtext": subtext,
+			})
+			return
+		}
+
+		// The state's stored user agent can come from another device, so the
+		// live request header wins when both are present.
+		userAgent := r.UserAgent()
+		if userAgent == "" {
+			userAgent = state.UserAgent
+		}
+
+		forceType := r.URL.Query().Get("type")
+		if forceType != "mobile" && forceType != "desktop" {
+			forceType = ""
+		}
+
+		launch := request.ConfigRequest{
+			UID:        ures.ID,
+			FileID:     state.IDS[0],
+			UserAgent:  userAgent,
+			ForceEdit:  state.ForceEdit,
+			ForceType:  forceType,
+			RevisionID: state.RevisionID,
+			CoEditing:  state.CoEditing,
+		}
+
+		// A quick reload of an unchanged document serves the config the
+		// previous open just built; anything that changed the inputs — a
+		// save, a new revision, another device — misses the cache.
+		config, cached := c.configs.Get(launch)
+		if !cached {
+			if err := c.client.Call(r.Context(), c.client.NewRequest(
+				fmt.Sprintf("%s:builder", c.server.Namespace), "ConfigHandler.BuildConfig",
+				launch,
+			), &config); err != nil {
+				c.logger.Errorf("could not build an editor config for %s: %s", state.IDS[0], err.Error())
+				if state.RevisionID != "" {
+					// A pinned revision usually fails because Drive pruned it, not
+					// because of a transient error, so say so.
+					embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+						"errorMain":    "Revision not available",
+						"errorSubtext": "This revision may have been removed by Google Drive. The current version of the file can still be opened",
+					})
+					return
+				}
+				embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+					"errorMain":    "Could not open the file",
+					"errorSubtext": "Please try again later",
+				})
+				return
+			}
+			c.configs.Put(launch, config)
+		}
+
+		c.sessions.Refresh(ures.ID, sessions.Session{
+			FileID: state.IDS[0],
+			Title:  config.Document.Title,
+			Key:    config.Document.Key,
+		})
+
+		// A routed file must load api.js from the instance its config
+		// points at, not the default one.
+		serverURL := config.ServerURL
+		if serverURL == "" {
+			serverURL = c.onlyoffice.Config().Onlyoffice.Builder.DocumentServerURL
+		}
+
+		// A mobile session the builder downgraded to view deserves a word of
+		// explanation where the desktop toolbar would have been.
+		var mobileNotice string
+		if config.MobileViewOnly {
+			mobileNotice = embeddable.MobileViewOnlyMessage(userLocale(r))
+		}
+
+		fallbackMain, fallbackSubtext := embeddable.DSUnavailableMessage(userLocale(r))
+		embeddable.EditorPage.Execute(rw, map[string]interface{}{
+			"apijs":           fmt.Sprintf("%s/web-apps/apps/api/documents/api.js", serverURL),
+			"config":          string(config.ToJSON()),
+			"fallbackMain":    fallbackMain,
+			"fallbackSubtext": fallbackSubtext,
+			"mobileNotice":    mobileNotice,
+		})
+	}
+}
+
+// BuildInvalidateConfigs drops the cached editor configs built around a
+// document key. The callback service posts here on save transitions, so a
+// reload after a save rebuilds its config instead of reusing one that
+// points at the overwritten content. The endpoint sits behind the internal
+// signing middleware.
+func (c EditorController) BuildInvalidateConfigs() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var body request.ConfigInvalidateRequest
+		if err := request.DecodeStrict(r.Body, &body); err != nil || body.Key == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		c.configs.InvalidateKey(body.Key)
+		rw.WriteHeader(http.StatusOK)
+	}
+}
+
+// BuildEditorBeacon receives the browser's report that api.js failed to
+// load. The cached probe result is dropped so the next editor open
+// re-checks the Document Server instead of trusting a stale "healthy".
+func (c EditorController) BuildEditorBeacon() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		c.logger.Warnf("a browser reported that the document server api.js failed to load")
+		c.health.Invalidate()
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,95 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+)
+
+type editorTestLogger struct{}
+
+func (editorTestLogger) Debugf(format string, args ...interface{}) {}
+func (editorTestLogger) Infof(format string, args ...interface{})  {}
+func (editorTestLogger) Warnf(format string, args ...interface{})  {}
+func (editorTestLogger) Errorf(format string, args ...interface{}) {}
+func (editorTestLogger) Fatalf(format string, args ...interface{}) {}
+
+// stubDSHealth pins the reachability verdict and counts cache drops.
+type stubDSHealth struct {
+	healthy     bool
+	invalidated int
+}
+
+func (s *stubDSHealth) Healthy(context.Context) bool { return s.healthy }
+func (s *stubDSHealth) Invalidate()                  { s.invalidated++ }
+
+// editorLaunchRequest carries the context values the session middleware
+// would have resolved for a normal editor open.
+func editorLaunchRequest() *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/editor", nil)
+	ctx := context.WithValue(r.Context(), middleware.ContextState, request.DriveState{
+		IDS: []string{"file-id"}, Action: "edit",
+	})
+	ctx = context.WithValue(ctx, middleware.ContextUser, response.UserResponse{ID: "user-id"})
+	return r.WithContext(ctx)
+}
+
+// A Document Server that fails its reachability probe must select the error
+// page branch instead of rendering an editor frame whose api.js can never
+// load.
+func TestEditorPageRendersErrorWhenDSUnreachable(t *testing.T) {
+	controller := EditorController{
+		health: &stubDSHealth{healthy: false},
+		logger: editorTestLogger{},
+	}
+
+	rec := httptest.NewRecorder()
+	controller.BuildEditorPage()(rec, editorLaunchRequest())
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Document server unavailable") {
+		t.Errorf("expected the unavailable error page, got: %s", body)
+	}
+	if strings.Contains(body, "DocsAPI") {
+		t.Error("the editor frame must not render while the document server is down")
+	}
+}
+
+func TestEditorBeaconInvalidatesHealthCache(t *testing.T) {
+	health := &stubDSHealth{healthy: true}
+	controller := EditorController{health: health, logger: editorTestLogger{}}
+
+	rec := httptest.NewRecorder()
+	controller.BuildEditorBeacon()(rec, httptest.NewRequest(http.MethodPost, "/api/editor/beacon", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 from the beacon, got %d", rec.Code)
+	}
+	if health.invalidated != 1 {
+		t.Errorf("expected the beacon to drop the cached verdict once, got %d", health.invalidated)
+	}
+}
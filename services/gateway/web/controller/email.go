@@ -0,0 +1,224 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/email"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/quota"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// EmailController lists the attachments of .eml files in Drive and serves
+// their content, so the links the email preview renders into the document
+// lead somewhere.
+type EmailController struct {
+	fileUtility files.OnlyofficeFileUtility
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	monitor     *quota.Monitor
+	logger      log.Logger
+}
+
+func NewEmailController(
+	fileUtility files.OnlyofficeFileUtility,
+	credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource,
+	monitor *quota.Monitor, logger log.Logger,
+) EmailController {
+	return EmailController{
+		fileUtility: fileUtility,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		monitor:     monitor,
+		logger:      logger,
+	}
+}
+
+// loadMessage resolves the id query parameter into a parsed email using
+// the session user's credentials, writing the error status itself when
+// anything along the way refuses.
+func (c EmailController) loadMessage(rw http.ResponseWriter, r *http.Request) (*drive.File, *email.Message, bool) {
+	if !c.onlyoffice.Config().Onlyoffice.Builder.EmailPreview {
+		rw.WriteHeader(http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	id := r.URL.Query().Get("id")
+	if !request.ValidDriveID(id) {
+		rw.WriteHeader(http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+	if !ok {
+		rw.WriteHeader(http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	gclient := (&oauth2.Config{
+		ClientID:     c.credentials.Credentials.ClientID,
+		ClientSecret: c.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(r.Context(), &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	srv, err := drive.NewService(r.Context(), option.WithHTTPClient(gclient))
+	if err != nil {
+		c.logger.Errorf("could not initialize drive service: %s", err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	c.monitor.Observe("files.get")
+	file, err := srv.Files.Get(id).Do()
+	if err != nil {
+		c.monitor.ObserveError("files.get", err)
+		c.logger.Errorf("could not get file %s: %s", id, err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	ext, _ := shared.EffectiveExtension(file.FileExtension, file.MimeType)
+	if !c.fileUtility.IsExtensionEmail(ext) {
+		rw.WriteHeader(http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	sourceURL, _, err := downloadSource(file)
+	if err != nil {
+		c.logger.Debugf("file %s is not downloadable: %s", id, err.Error())
+		rw.WriteHeader(http.StatusUnsupportedMediaType)
+		return nil, nil, false
+	}
+
+	var message *email.Message
+	if err := c.onlyoffice.DownloadSemaphore().With(r.Context(), func() error {
+		resp, err := gclient.Get(sourceURL)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if err := googleapi.CheckResponse(resp); err != nil {
+			return err
+		}
+
+		var reader io.Reader = resp.Body
+		limits := c.onlyoffice.Config().Onlyoffice.Limits
+		if limit := limits.OpenSizeLimit("word", ext); limit > 0 {
+			reader = io.LimitReader(resp.Body, limit)
+		}
+		message, err = email.ParseEML(reader)
+		return err
+	}); err != nil {
+		c.logger.Errorf("could not parse email %s: %s", id, err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	return file, message, true
+}
+
+// BuildEmailAttachments renders the attachment list of an email with open
+// links for the attachments the integration supports on its own.
+func (c EmailController) BuildEmailAttachments() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		file, message, ok := c.loadMessage(rw, r)
+		if !ok {
+			return
+		}
+
+		type entry struct {
+			Filename    string
+			ContentType string
+			Link        string
+		}
+		entries := make([]entry, 0, len(message.Attachments))
+		for i, attachment := range message.Attachments {
+			item := entry{
+				Filename:    attachment.Filename,
+				ContentType: attachment.ContentType,
+			}
+			ext := strings.TrimPrefix(path.Ext(attachment.Filename), ".")
+			if c.fileUtility.IsExtensionSupported(ext) {
+				item.Link = fmt.Sprintf(
+					"/api/email/attachment?id=%s&index=%d",
+					url.QueryEscape(file.Id), i,
+				)
+			}
+			entries = append(entries, item)
+		}
+
+		embeddable.EmailPage.Execute(rw, map[string]interface{}{
+			"file":        file.Title,
+			"subject":     message.Subject,
+			"attachments": entries,
+		})
+	}
+}
+
+// BuildEmailAttachment serves one attachment's content as a download, so
+// a supported attachment can leave its message without a mail client.
+func (c EmailController) BuildEmailAttachment() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		_, message, ok := c.loadMessage(rw, r)
+		if !ok {
+			return
+		}
+
+		index, err := strconv.Atoi(r.URL.Query().Get("index"))
+		if err != nil || index < 0 || index >= len(message.Attachments) {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		attachment := message.Attachments[index]
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		rw.Header().Set("Content-Type", contentType)
+		rw.Header().Set("Content-Disposition", fmt.Sprintf(
+			`attachment; filename="%s"`,
+			c.fileUtility.EscapeFilename(attachment.Filename),
+		))
+		rw.Write(attachment.Data)
+	}
+}
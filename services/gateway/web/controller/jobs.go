@@ -0,0 +1,279 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/google/uuid"
+)
+
+// Conversion job phases in pipeline order.
+const (
+	ConvertPhaseQueued      = "queued"
+	ConvertPhaseConverting  = "converting"
+	ConvertPhaseDownloading = "downloading"
+	ConvertPhaseUploading   = "uploading"
+	ConvertPhaseDone        = "done"
+	ConvertPhaseFailed      = "failed"
+)
+
+// Per-file statuses within a batch conversion job.
+const (
+	ConvertFileStatusPending = "pending"
+	ConvertFileStatusRunning = "running"
+	ConvertFileStatusDone    = "done"
+	ConvertFileStatusFailed  = "failed"
+)
+
+// convertPhaseOrder ranks phases so a stale update from an earlier pipeline
+// step can never rewind a job; done and failed are terminal.
+var convertPhaseOrder = map[string]int{
+	ConvertPhaseQueued:      0,
+	ConvertPhaseConverting:  1,
+	ConvertPhaseDownloading: 2,
+	ConvertPhaseUploading:   3,
+	ConvertPhaseDone:        4,
+	ConvertPhaseFailed:      4,
+}
+
+// ConversionJobs tracks in-flight conversion pipelines so the conversion
+// page can poll their progress. A job expires a TTL after its last update,
+// which covers pages closed before they collect a finished job. Like
+// cache.ConversionCache this is in-process state: the poll lands on the
+// instance that accepted the POST, matching the singleflight dedup.
+type ConversionJobs struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	newID   func() string
+	entries map[string]conversionJob
+}
+
+type conversionJob struct {
+	status  response.ConvertProgressResponse
+	cancel  context.CancelFunc
+	expires time.Time
+}
+
+func NewConversionJobs(ttl time.Duration) *ConversionJobs {
+	return &ConversionJobs{
+		ttl:     ttl,
+		now:     time.Now,
+		newID:   uuid.NewString,
+		entries: make(map[string]conversionJob),
+	}
+}
+
+// Start registers a queued job and returns its id. Expired entries are
+// swept here rather than on a timer; starts are frequent enough to keep
+// the map bounded.
+func (j *ConversionJobs) Start() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for id, entry := range j.entries {
+		if j.now().After(entry.expires) {
+			delete(j.entries, id)
+		}
+	}
+
+	id := j.newID()
+	j.entries[id] = conversionJob{
+		status:  response.ConvertProgressResponse{Phase: ConvertPhaseQueued},
+		expires: j.now().Add(j.ttl),
+	}
+	return id
+}
+
+// StartBatch registers a job tracking each selected file, in selection
+// order — the order the progress endpoint and the final report keep.
+func (j *ConversionJobs) StartBatch(fileIDs []string) string {
+	id := j.Start()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := j.entries[id]
+	entry.status.Files = make([]response.ConvertFileProgress, 0, len(fileIDs))
+	for _, fileID := range fileIDs {
+		entry.status.Files = append(entry.status.Files, response.ConvertFileProgress{
+			FileID: fileID,
+			Status: ConvertFileStatusPending,
+		})
+	}
+	j.entries[id] = entry
+	return id
+}
+
+// AttachCancel ties the pipeline's cancel function to the job so the page
+// can abort the whole run when the user navigates away.
+func (j *ConversionJobs) AttachCancel(id string, cancel context.CancelFunc) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.entries[id]
+	if !ok {
+		return
+	}
+	entry.cancel = cancel
+	j.entries[id] = entry
+}
+
+// Cancel aborts the job's pipeline when one is attached; the workers report
+// the remaining files as they observe the cancelled context.
+func (j *ConversionJobs) Cancel(id string) {
+	j.mu.Lock()
+	cancel := j.entries[id].cancel
+	j.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// UpdateFile records one batch file's status and recomputes the overall
+// percent from the settled share, so the page's progress bar keeps moving
+// as workers finish.
+func (j *ConversionJobs) UpdateFile(id, fileID, status, reason string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.entries[id]
+	if !ok {
+		return
+	}
+
+	settled := 0
+	for i, file := range entry.status.Files {
+		if file.FileID == fileID {
+			entry.status.Files[i].Status = status
+			entry.status.Files[i].Reason = reason
+		}
+		if s := entry.status.Files[i].Status; s == ConvertFileStatusDone || s == ConvertFileStatusFailed {
+			settled++
+		}
+	}
+	if total := len(entry.status.Files); total > 0 {
+		entry.status.Percent = settled * 100 / total
+	}
+	entry.expires = j.now().Add(j.ttl)
+	j.entries[id] = entry
+}
+
+// Update moves the job to a later phase or refreshes the percent within its
+// current one. Updates for an earlier phase, or a smaller percent of the
+// same phase, are dropped — a late Document Server poll must never move
+// the progress bar backwards.
+func (j *ConversionJobs) Update(id, phase string, percent int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.entries[id]
+	if !ok {
+		return
+	}
+	if convertPhaseOrder[phase] < convertPhaseOrder[entry.status.Phase] {
+		return
+	}
+	if phase == entry.status.Phase && percent < entry.status.Percent {
+		return
+	}
+
+	entry.status.Phase = phase
+	entry.status.Percent = percent
+	entry.expires = j.now().Add(j.ttl)
+	j.entries[id] = entry
+}
+
+// Finish records the editor state the page relaunches with once the copy
+// exists.
+func (j *ConversionJobs) Finish(id string, state request.DriveState) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.entries[id]
+	if !ok {
+		return
+	}
+
+	entry.status = response.ConvertProgressResponse{
+		Phase:   ConvertPhaseDone,
+		Percent: 100,
+		Result:  state.ToJSON(),
+	}
+	entry.expires = j.now().Add(j.ttl)
+	j.entries[id] = entry
+}
+
+// FinishBatch marks a batch job done once every file settled, keeping the
+// per-file report the page renders in selection order.
+func (j *ConversionJobs) FinishBatch(id string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.entries[id]
+	if !ok {
+		return
+	}
+
+	entry.status.Phase = ConvertPhaseDone
+	entry.status.Percent = 100
+	entry.expires = j.now().Add(j.ttl)
+	j.entries[id] = entry
+}
+
+// Fail records a terminal failure in the error shape the page renders,
+// keeping whatever the failure carries — e.g. the Google Docs link offered
+// for files over the export cap.
+func (j *ConversionJobs) Fail(id string, failure response.ConvertProgressResponse) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.entries[id]
+	if !ok {
+		return
+	}
+
+	failure.Phase = ConvertPhaseFailed
+	entry.status = failure
+	entry.expires = j.now().Add(j.ttl)
+	j.entries[id] = entry
+}
+
+// Get reports the job status; unknown and expired jobs read as gone.
+func (j *ConversionJobs) Get(id string) (response.ConvertProgressResponse, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.entries[id]
+	if !ok {
+		return response.ConvertProgressResponse{}, false
+	}
+	if j.now().After(entry.expires) {
+		delete(j.entries, id)
+		return response.ConvertProgressResponse{}, false
+	}
+
+	return entry.status, true
+}
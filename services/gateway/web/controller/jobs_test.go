@@ -0,0 +1,136 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+)
+
+func TestConversionJobsPhaseProgression(t *testing.T) {
+	jobs := NewConversionJobs(time.Minute)
+	id := jobs.Start()
+
+	status, ok := jobs.Get(id)
+	if !ok || status.Phase != ConvertPhaseQueued {
+		t.Fatalf("expected a queued job, got %q, %t", status.Phase, ok)
+	}
+
+	jobs.Update(id, ConvertPhaseConverting, 40)
+	jobs.Update(id, ConvertPhaseDownloading, 0)
+
+	// A late Document Server poll for an already finished conversion must
+	// not rewind the job to the converting phase.
+	jobs.Update(id, ConvertPhaseConverting, 80)
+	if status, _ := jobs.Get(id); status.Phase != ConvertPhaseDownloading {
+		t.Fatalf("expected the job to stay in downloading, got %q", status.Phase)
+	}
+
+	jobs.Update(id, ConvertPhaseUploading, 0)
+	jobs.Finish(id, request.DriveState{IDS: []string{"copy"}, Action: "edit"})
+
+	status, _ = jobs.Get(id)
+	if status.Phase != ConvertPhaseDone || status.Percent != 100 {
+		t.Fatalf("expected a done job at 100%%, got %q at %d", status.Phase, status.Percent)
+	}
+	if len(status.Result) == 0 {
+		t.Fatal("expected the finished job to carry the relaunch state")
+	}
+}
+
+func TestConversionJobsPercentNeverRewinds(t *testing.T) {
+	jobs := NewConversionJobs(time.Minute)
+	id := jobs.Start()
+
+	jobs.Update(id, ConvertPhaseConverting, 60)
+	jobs.Update(id, ConvertPhaseConverting, 20)
+
+	if status, _ := jobs.Get(id); status.Percent != 60 {
+		t.Fatalf("expected the percent to hold at 60, got %d", status.Percent)
+	}
+}
+
+func TestConversionJobsFailure(t *testing.T) {
+	jobs := NewConversionJobs(time.Minute)
+	id := jobs.Start()
+
+	jobs.Update(id, ConvertPhaseConverting, 30)
+	jobs.Fail(id, response.ConvertProgressResponse{
+		Errors: []response.ConvertProgressError{{Field: "file", Message: "boom"}},
+	})
+
+	status, _ := jobs.Get(id)
+	if status.Phase != ConvertPhaseFailed {
+		t.Fatalf("expected a failed job, got %q", status.Phase)
+	}
+	if len(status.Errors) != 1 || status.Errors[0].Message != "boom" {
+		t.Fatal("expected the failure payload to survive untouched")
+	}
+}
+
+func TestConversionJobsUnknownID(t *testing.T) {
+	jobs := NewConversionJobs(time.Minute)
+
+	if _, ok := jobs.Get("missing"); ok {
+		t.Fatal("expected a miss for an unknown job id")
+	}
+	// Updates on unknown ids must be no-ops, not phantom entries.
+	jobs.Update("missing", ConvertPhaseConverting, 10)
+	if _, ok := jobs.Get("missing"); ok {
+		t.Fatal("expected the unknown job to stay unknown after an update")
+	}
+}
+
+func TestConversionJobsExpiry(t *testing.T) {
+	now := time.Now()
+	jobs := NewConversionJobs(time.Minute)
+	jobs.now = func() time.Time { return now }
+	id := jobs.Start()
+
+	now = now.Add(30 * time.Second)
+	if _, ok := jobs.Get(id); !ok {
+		t.Fatal("expected a hit before the ttl elapses")
+	}
+
+	// An update renews the deadline: a job is abandoned a ttl after its
+	// last sign of life, not after its start.
+	jobs.Update(id, ConvertPhaseConverting, 10)
+	now = now.Add(45 * time.Second)
+	if _, ok := jobs.Get(id); !ok {
+		t.Fatal("expected the update to renew the job deadline")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := jobs.Get(id); ok {
+		t.Fatal("expected a miss after the ttl elapses")
+	}
+
+	// Start sweeps expired entries out of the map as a side effect.
+	expired := id
+	jobs.Start()
+	jobs.mu.Lock()
+	_, lingering := jobs.entries[expired]
+	jobs.mu.Unlock()
+	if lingering {
+		t.Fatal("expected the expired job to be swept on the next start")
+	}
+}
@@ -0,0 +1,83 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// maxPasswordAttempts caps how often a password-carrying conversion of the
+// same file may be retried before the window expires; the gate only holds
+// attempt counts, never the passwords themselves.
+const (
+	maxPasswordAttempts   = 3
+	passwordAttemptWindow = 10 * time.Minute
+)
+
+type passwordAttempts struct {
+	count int
+	last  time.Time
+}
+
+// passwordGate rate limits password retries per file, so the conversion
+// endpoint cannot be used to guess document passwords.
+type passwordGate struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	files map[string]passwordAttempts
+}
+
+func newPasswordGate() *passwordGate {
+	return &passwordGate{
+		now:   time.Now,
+		files: map[string]passwordAttempts{},
+	}
+}
+
+// allow records one password attempt for the file and reports whether it
+// may proceed. Attempts age out with the window, so a user who mistyped a
+// few times is not locked out forever.
+func (g *passwordGate) allow(fileID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	for id, entry := range g.files {
+		if now.Sub(entry.last) > passwordAttemptWindow {
+			delete(g.files, id)
+		}
+	}
+
+	entry := g.files[fileID]
+	if entry.count >= maxPasswordAttempts {
+		return false
+	}
+	entry.count++
+	entry.last = now
+	g.files[fileID] = entry
+	return true
+}
+
+// clear forgets the file's attempts after a successful conversion.
+func (g *passwordGate) clear(fileID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.files, fileID)
+}
@@ -0,0 +1,66 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPasswordGateCapsAttemptsPerFile(t *testing.T) {
+	gate := newPasswordGate()
+
+	for i := 0; i < maxPasswordAttempts; i++ {
+		if !gate.allow("file-1") {
+			t.Fatalf("expected attempt %d to pass", i+1)
+		}
+	}
+	if gate.allow("file-1") {
+		t.Fatal("expected the cap to hold after the allowed attempts")
+	}
+
+	// Other files have their own budget.
+	if !gate.allow("file-2") {
+		t.Fatal("expected another file to be unaffected")
+	}
+
+	// A successful conversion resets the file's budget.
+	gate.clear("file-1")
+	if !gate.allow("file-1") {
+		t.Fatal("expected a cleared file to accept attempts again")
+	}
+}
+
+func TestPasswordGateAttemptsExpire(t *testing.T) {
+	gate := newPasswordGate()
+	now := time.Now()
+	gate.now = func() time.Time { return now }
+
+	for i := 0; i < maxPasswordAttempts; i++ {
+		gate.allow("file-1")
+	}
+	if gate.allow("file-1") {
+		t.Fatal("expected the cap to hold inside the window")
+	}
+
+	now = now.Add(passwordAttemptWindow + time.Second)
+	if !gate.allow("file-1") {
+		t.Fatal("expected the attempts to age out with the window")
+	}
+}
@@ -0,0 +1,296 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/quota"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"github.com/golang-jwt/jwt/v4"
+	"go-micro.dev/v4/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/option"
+)
+
+// pickerPageSize bounds a single Files.List call when browsing Drive.
+const pickerPageSize = 30
+
+// pickerTokenDuration bounds how long a signed embed URL stays usable.
+const pickerTokenDuration = 10 * time.Minute
+
+// PickerController lets partner applications browse a user's Drive and
+// obtain a signed, expiring URL to an embedded view-only editor for the
+// picked file.
+type PickerController struct {
+	client      client.Client
+	jwtManager  crypto.JwtManager
+	fileUtility files.OnlyofficeFileUtility
+	server      *config.ServerConfig
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	monitor     *quota.Monitor
+	// registry records the embed's download issuance so the proxy serves
+	// the Document Server without an editor session.
+	registry *sessions.Registry
+	logger   log.Logger
+	// clock stamps issued picker tokens; injected so tests can pin it.
+	clock shared.Clock
+}
+
+func NewPickerController(
+	client client.Client, jwtManager crypto.JwtManager,
+	fileUtility files.OnlyofficeFileUtility,
+	server *config.ServerConfig, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource, monitor *quota.Monitor,
+	registry *sessions.Registry, logger log.Logger,
+) PickerController {
+	return PickerController{
+		client:      client,
+		jwtManager:  jwtManager,
+		fileUtility: fileUtility,
+		server:      server,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		monitor:     monitor,
+		registry:    registry,
+		logger:      logger,
+		clock:       shared.SystemClock{},
+	}
+}
+
+// pickerItem is a single row on the picker page.
+type pickerItem struct {
+	ID     string
+	Title  string
+	Folder bool
+}
+
+// buildPickerQuery returns the Files.List query listing the direct,
+// non-trashed children of a folder.
+func buildPickerQuery(folderID string) string {
+	return fmt.Sprintf("'%s' in parents and trashed = false", folderID)
+}
+
+// pickerItems filters a Drive listing down to folders and files the editor
+// can open.
+func pickerItems(list []*drive.File, fileUtility files.OnlyofficeFileUtility) []pickerItem {
+	items := make([]pickerItem, 0, len(list))
+	for _, file := range list {
+		switch {
+		case file.MimeType == shared.GoogleFolderMime:
+			items = append(items, pickerItem{ID: file.Id, Title: file.Title, Folder: true})
+		case fileUtility.IsExtensionSupported(file.FileExtension):
+			items = append(items, pickerItem{ID: file.Id, Title: file.Title})
+		}
+	}
+	return items
+}
+
+// allowOrigin answers the request's Origin header when it is one of the
+// configured partner origins.
+func (c PickerController) allowOrigin(rw http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	for _, allowed := range c.onlyoffice.Config().Onlyoffice.CORS.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), strings.TrimSuffix(origin, "/")) {
+			rw.Header().Set("Access-Control-Allow-Origin", origin)
+			rw.Header().Set("Vary", "Origin")
+			return
+		}
+	}
+}
+
+// BuildPickerPage renders a minimal paginated Drive browser with folders
+// and supported files.
+func (c PickerController) BuildPickerPage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			http.Redirect(rw, r, "/oauth/install", http.StatusSeeOther)
+			return
+		}
+
+		folder := r.URL.Query().Get("folder")
+		if folder == "" {
+			folder = "root"
+		}
+
+		srv, err := c.driveService(r, ures)
+		if err != nil {
+			c.logger.Errorf("could not initialize drive service: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		c.monitor.Observe("files.list")
+		list, err := srv.Files.List().Q(buildPickerQuery(folder)).
+			MaxResults(pickerPageSize).
+			PageToken(r.URL.Query().Get("pageToken")).Do()
+		if err != nil {
+			c.monitor.ObserveError("files.list", err)
+			c.logger.Errorf("could not list folder %s: %s", folder, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		nextPage := ""
+		if list.NextPageToken != "" {
+			nextPage = fmt.Sprintf(
+				"/api/picker?folder=%s&pageToken=%s",
+				url.QueryEscape(folder), url.QueryEscape(list.NextPageToken),
+			)
+		}
+
+		embeddable.PickerPage.Execute(rw, map[string]interface{}{
+			"folder":   folder,
+			"items":    pickerItems(list.Items, c.fileUtility),
+			"nextPage": nextPage,
+		})
+	}
+}
+
+// BuildPickerSelect signs an expiring embed URL for the chosen file and
+// returns it to the (possibly cross-origin) caller.
+func (c PickerController) BuildPickerSelect() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		c.allowOrigin(rw, r)
+
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var body struct {
+			ID string `json:"id"`
+		}
+		if err := request.DecodeStrict(r.Body, &body); err != nil || body.ID == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token, err := c.jwtManager.Sign(c.credentials.Credentials.ClientSecret, shared.PickerToken{
+			UID:    ures.ID,
+			FileID: body.ID,
+			RegisteredClaims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(c.clock.Now()),
+				ExpiresAt: jwt.NewNumericDate(c.clock.Now().Add(pickerTokenDuration)),
+			},
+		})
+		if err != nil {
+			c.logger.Errorf("could not issue a picker token: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]interface{}{
+			"url": fmt.Sprintf(
+				"%s/api/picker/embed?token=%s",
+				c.onlyoffice.Config().Onlyoffice.Builder.GatewayURL, url.QueryEscape(token),
+			),
+			"expires_in": int(pickerTokenDuration.Seconds()),
+		})
+	}
+}
+
+// BuildPickerEmbed verifies the signed embed token and renders the
+// view-only embedded editor for partner iframes.
+func (c PickerController) BuildPickerEmbed() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var token shared.PickerToken
+		if err := c.jwtManager.Verify(
+			c.credentials.Credentials.ClientSecret,
+			r.URL.Query().Get("token"), &token,
+		); err != nil {
+			c.logger.Debugf("could not verify picker token: %s", err.Error())
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if origins := c.onlyoffice.Config().Onlyoffice.CORS.AllowedOrigins; len(origins) > 0 {
+			rw.Header().Set(
+				"Content-Security-Policy",
+				fmt.Sprintf("frame-ancestors %s", strings.Join(origins, " ")),
+			)
+		}
+
+		var config response.BuildConfigResponse
+		if err := c.client.Call(r.Context(), c.client.NewRequest(
+			fmt.Sprintf("%s:builder", c.server.Namespace), "ConfigHandler.BuildConfig",
+			request.ConfigRequest{
+				UID:      token.UID,
+				FileID:   token.FileID,
+				Embedded: true,
+			},
+		), &config); err != nil {
+			c.logger.Errorf("could not build an embedded config for %s: %s", token.FileID, err.Error())
+			embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+				"errorMain":    "Could not open the file",
+				"errorSubtext": "Please try again later",
+			})
+			return
+		}
+
+		// Embedded views open no editor session, so the recorded issuance is
+		// what lets the Document Server fetch the content through the
+		// download proxy.
+		if c.registry != nil {
+			c.registry.NoteIssued(token.UID, token.FileID)
+		}
+
+		embeddable.EditorPage.Execute(rw, map[string]interface{}{
+			"apijs":  fmt.Sprintf("%s/web-apps/apps/api/documents/api.js", c.onlyoffice.Config().Onlyoffice.Builder.DocumentServerURL),
+			"config": string(config.ToJSON()),
+		})
+	}
+}
+
+func (c PickerController) driveService(r *http.Request, ures response.UserResponse) (*drive.Service, error) {
+	gclient := (&oauth2.Config{
+		ClientID:     c.credentials.Credentials.ClientID,
+		ClientSecret: c.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(r.Context(), &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	return drive.NewService(r.Context(), option.WithHTTPClient(gclient))
+}
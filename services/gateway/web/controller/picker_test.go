@@ -0,0 +1,85 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/golang-jwt/jwt/v4"
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestBuildPickerQuery(t *testing.T) {
+	got := buildPickerQuery("root")
+	want := "'root' in parents and trashed = false"
+	if got != want {
+		t.Fatalf("buildPickerQuery = %q, want %q", got, want)
+	}
+}
+
+func TestPickerItems(t *testing.T) {
+	items := pickerItems([]*drive.File{
+		{Id: "1", Title: "Reports", MimeType: shared.GoogleFolderMime},
+		{Id: "2", Title: "notes.docx", FileExtension: "docx"},
+		{Id: "3", Title: "archive.tar.gz", FileExtension: "gz"},
+		{Id: "4", Title: "photo.png", FileExtension: "png"},
+		{Id: "5", Title: "data.csv", FileExtension: "csv"},
+	}, files.NewOnlyofficeFileUtility())
+
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(items))
+	}
+	if !items[0].Folder || items[0].ID != "1" {
+		t.Errorf("expected the folder first, got %+v", items[0])
+	}
+	if items[1].ID != "2" || items[2].ID != "5" {
+		t.Errorf("expected only supported files, got %+v", items[1:])
+	}
+}
+
+// Expiry is the only thing bounding a picker token's use, so expired claims
+// must fail validation.
+func TestPickerTokenExpiry(t *testing.T) {
+	fresh := shared.PickerToken{
+		UID:    "user",
+		FileID: "file",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(pickerTokenDuration)),
+		},
+	}
+	if err := fresh.Valid(); err != nil {
+		t.Fatalf("expected a fresh token to validate, got %s", err.Error())
+	}
+
+	expired := shared.PickerToken{
+		UID:    "user",
+		FileID: "file",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour + pickerTokenDuration)),
+		},
+	}
+	if err := expired.Valid(); err == nil {
+		t.Fatal("expected an expired token to fail validation")
+	}
+}
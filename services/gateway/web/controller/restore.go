@@ -0,0 +1,229 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// getRestoreFile resolves the metadata the restore guards read — most
+// importantly whether the user can edit at all — through the session user's
+// own credentials.
+func (c APIController) getRestoreFile(
+	r *http.Request, ures response.UserResponse, fileID string,
+) (*drive.File, error) {
+	srv, err := c.restoreDriveService(r, ures)
+	if err != nil {
+		return nil, err
+	}
+
+	c.monitor.Observe("files.get")
+	file, err := srv.Files.Get(fileID).
+		Fields("id", "title", "mimeType", "capabilities/canEdit").
+		SupportsAllDrives(true).Do()
+	if err != nil {
+		c.monitor.ObserveError("files.get", err)
+	}
+	return file, err
+}
+
+// performRestore downloads the revision's content and uploads it as the
+// file's new head, so the restored version becomes a regular new revision
+// instead of rewriting history.
+func (c APIController) performRestore(
+	r *http.Request, ures response.UserResponse, file *drive.File, revisionID string,
+) error {
+	srv, err := c.restoreDriveService(r, ures)
+	if err != nil {
+		return err
+	}
+
+	c.monitor.Observe("revisions.get")
+	revision, err := srv.Revisions.Get(file.Id, revisionID).Do()
+	if err != nil {
+		c.monitor.ObserveError("revisions.get", err)
+		return err
+	}
+
+	url, contentType, err := revisionSource(revision)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.restoreOauthClient(r, ures).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("revision content answered %d", resp.StatusCode)
+	}
+
+	c.monitor.Observe("files.update")
+	if _, err := srv.Files.Update(file.Id, &drive.File{}).
+		Media(resp.Body, googleapi.ContentType(contentType)).
+		SupportsAllDrives(true).Do(); err != nil {
+		c.monitor.ObserveError("files.update", err)
+		return err
+	}
+	return nil
+}
+
+func (c APIController) restoreOauthClient(r *http.Request, ures response.UserResponse) *http.Client {
+	return (&oauth2.Config{
+		ClientID:     c.credentials.Credentials.ClientID,
+		ClientSecret: c.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(r.Context(), &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+}
+
+func (c APIController) restoreDriveService(r *http.Request, ures response.UserResponse) (*drive.Service, error) {
+	return drive.NewService(r.Context(), option.WithHTTPClient(c.restoreOauthClient(r, ures)))
+}
+
+// notifyVersionChange tells the Document Server session on the user's open
+// document that the version behind its key changed, so the editor reloads
+// instead of saving over the restored head. No live session is fine; the
+// next open picks up the new head anyway, and a failed command only costs
+// that reload.
+func (c APIController) notifyVersionChange(r *http.Request, uid, fileID string) {
+	session, ok := c.sessions.Get(uid, fileID)
+	if !ok || session.Key == "" {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"c":   "version",
+		"key": session.Key,
+	}
+	builder := c.onlyoffice.Config().Onlyoffice.Builder
+	if builder.DocumentServerSecret != "" {
+		token, err := c.jwtManager.Sign(builder.DocumentServerSecret, jwt.MapClaims(payload))
+		if err != nil {
+			c.logger.Warnf("could not sign a version command for %s: %s", session.Key, err.Error())
+			return
+		}
+		payload["token"] = token
+	}
+
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(
+		r.Context(), http.MethodPost,
+		fmt.Sprintf("%s/coauthoring/CommandService.ashx", builder.DocumentServerURL),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", buildinfo.UserAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.logger.Warnf("could not notify the document server about restoring %s: %s", fileID, err.Error())
+		return
+	}
+	resp.Body.Close()
+}
+
+// BuildRestoreRevision writes an older revision back as the file's new
+// head. The write goes through the user's own credentials, so Drive itself
+// enforces access; the canEdit guard just refuses earlier with a clearer
+// answer for users who can only view.
+func (c APIController) BuildRestoreRevision() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var body request.RestoreRequest
+		if err := request.DecodeStrict(r.Body, &body); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := body.Validate(); err != nil {
+			c.logger.Debugf("invalid restore payload: %s", err.Error())
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusBadRequest)
+			if verrs, ok := err.(request.ValidationErrors); ok {
+				rw.Write(verrs.ToJSON())
+			}
+			return
+		}
+
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		file, err := c.restoreFile(r, ures, body.FileID)
+		if err != nil {
+			var gerr *googleapi.Error
+			if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+			c.logger.Errorf("could not resolve file %s for restore: %s", body.FileID, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if file.Capabilities == nil || !file.Capabilities.CanEdit {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if err := c.applyRestore(r, ures, file, body.RevisionID); err != nil {
+			c.logger.Errorf("could not restore revision %s of %s: %s", body.RevisionID, body.FileID, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// The restore overwrites user content on their word alone, so the
+		// action lands in the log with everything needed to answer "who
+		// rolled this file back".
+		c.logger.Infof(
+			"audit: user %s restored file %s to revision %s",
+			ures.ID, body.FileID, body.RevisionID,
+		)
+
+		c.restoreNotify(r, ures.ID, body.FileID)
+		rw.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,126 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	drive "google.golang.org/api/drive/v2"
+)
+
+// restoreTestDrive fakes the Drive side of a restore: the metadata lookup,
+// the applied write and the Document Server notification.
+type restoreTestDrive struct {
+	canEdit  bool
+	restored []string
+	notified int
+}
+
+func (d *restoreTestDrive) controller() APIController {
+	return APIController{
+		logger: editorTestLogger{},
+		restoreFile: func(*http.Request, response.UserResponse, string) (*drive.File, error) {
+			return &drive.File{
+				Id:           "file-id-0123456789",
+				Title:        "document.docx",
+				Capabilities: &drive.FileCapabilities{CanEdit: d.canEdit},
+			}, nil
+		},
+		applyRestore: func(_ *http.Request, _ response.UserResponse, _ *drive.File, revisionID string) error {
+			d.restored = append(d.restored, revisionID)
+			return nil
+		},
+		restoreNotify: func(*http.Request, string, string) {
+			d.notified++
+		},
+	}
+}
+
+func restoreRequestBody(t *testing.T, body request.RestoreRequest) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/restore", bytes.NewReader(body.ToJSON()))
+	return req.WithContext(context.WithValue(
+		req.Context(), middleware.ContextUser, response.UserResponse{ID: "1000000000"},
+	))
+}
+
+func TestRestoreWritesTheRevisionBack(t *testing.T) {
+	fake := &restoreTestDrive{canEdit: true}
+	controller := fake.controller()
+
+	rec := httptest.NewRecorder()
+	controller.BuildRestoreRevision()(rec, restoreRequestBody(t, request.RestoreRequest{
+		FileID: "file-id-0123456789", RevisionID: "7", Confirm: true,
+	}))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the restore to succeed, got %d", rec.Code)
+	}
+	if len(fake.restored) != 1 || fake.restored[0] != "7" {
+		t.Errorf("expected revision 7 to be written back, got %v", fake.restored)
+	}
+	if fake.notified != 1 {
+		t.Errorf("expected one version-change notification, got %d", fake.notified)
+	}
+}
+
+// A user who can only view must be refused before anything is written, and
+// the guard must not depend on the client knowing it.
+func TestRestoreRejectsViewOnlyUsers(t *testing.T) {
+	fake := &restoreTestDrive{canEdit: false}
+	controller := fake.controller()
+
+	rec := httptest.NewRecorder()
+	controller.BuildRestoreRevision()(rec, restoreRequestBody(t, request.RestoreRequest{
+		FileID: "file-id-0123456789", RevisionID: "7", Confirm: true,
+	}))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a view-only user, got %d", rec.Code)
+	}
+	if len(fake.restored) != 0 {
+		t.Errorf("nothing may be written for a view-only user, got %v", fake.restored)
+	}
+}
+
+// Restoring is destructive; a payload without the explicit confirmation is
+// rejected before any Drive call.
+func TestRestoreRequiresConfirmation(t *testing.T) {
+	fake := &restoreTestDrive{canEdit: true}
+	controller := fake.controller()
+
+	rec := httptest.NewRecorder()
+	controller.BuildRestoreRevision()(rec, restoreRequestBody(t, request.RestoreRequest{
+		FileID: "file-id-0123456789", RevisionID: "7",
+	}))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without confirmation, got %d", rec.Code)
+	}
+	if len(fake.restored) != 0 {
+		t.Errorf("nothing may be written without confirmation, got %v", fake.restored)
+	}
+}
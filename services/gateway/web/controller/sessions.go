@@ -0,0 +1,157 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SessionsController manages the per-user open-document registry: it
+// force-closes sessions through the Document Server drop command and
+// terminates the callback service's activity refreshes.
+type SessionsController struct {
+	registry   *sessions.Registry
+	jwtManager crypto.JwtManager
+	onlyoffice *shared.OnlyofficeConfigSource
+	logger     log.Logger
+}
+
+func NewSessionsController(
+	registry *sessions.Registry, jwtManager crypto.JwtManager,
+	onlyoffice *shared.OnlyofficeConfigSource, logger log.Logger,
+) SessionsController {
+	return SessionsController{
+		registry:   registry,
+		jwtManager: jwtManager,
+		onlyoffice: onlyoffice,
+		logger:     logger,
+	}
+}
+
+// BuildDropSession force-closes one of the caller's open documents so a new
+// one fits under the seat limit. The seat is freed even when the Document
+// Server cannot be reached; the TTL would reclaim it anyway.
+func (c SessionsController) BuildDropSession() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		fileID := r.FormValue("file_id")
+		session, ok := c.registry.Get(ures.ID, fileID)
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := c.dropCommand(r, session.Key, ures.ID); err != nil {
+			c.logger.Warnf("could not drop session %s for user %s: %s", fileID, ures.ID, err.Error())
+		}
+		c.registry.Drop(ures.ID, fileID)
+
+		back := r.Referer()
+		if back == "" {
+			back = "https://drive.google.com"
+		}
+		http.Redirect(rw, r, back, http.StatusSeeOther)
+	}
+}
+
+// dropCommand asks the Document Server to disconnect the user from the
+// document identified by key.
+func (c SessionsController) dropCommand(r *http.Request, key, uid string) error {
+	payload := map[string]interface{}{
+		"c":     "drop",
+		"key":   key,
+		"users": []string{uid},
+	}
+	if secret := c.onlyoffice.Config().Onlyoffice.Builder.DocumentServerSecret; secret != "" {
+		token, err := c.jwtManager.Sign(secret, jwt.MapClaims(payload))
+		if err != nil {
+			return err
+		}
+		payload["token"] = token
+	}
+
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(
+		r.Context(), http.MethodPost,
+		fmt.Sprintf("%s/coauthoring/CommandService.ashx", c.onlyoffice.Config().Onlyoffice.Builder.DocumentServerURL),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", buildinfo.UserAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var cresp struct {
+		Error int `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&cresp); err != nil {
+		return err
+	}
+	if cresp.Error != 0 {
+		return fmt.Errorf("drop command error %d", cresp.Error)
+	}
+	return nil
+}
+
+// BuildRefreshSession records callback-reported editing activity. The
+// endpoint sits behind the internal signing middleware.
+func (c SessionsController) BuildRefreshSession() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var body request.SessionRefreshRequest
+		if err := request.DecodeStrict(r.Body, &body); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if body.UID == "" || body.FileID == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		c.registry.Refresh(body.UID, sessions.Session{
+			FileID: body.FileID,
+			Key:    body.Key,
+		})
+		rw.WriteHeader(http.StatusOK)
+	}
+}
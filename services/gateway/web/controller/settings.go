@@ -0,0 +1,179 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/settings"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/option"
+)
+
+// SettingsController reads and writes per-user integration settings stored
+// in the user's Drive appDataFolder.
+type SettingsController struct {
+	store       *settings.Store
+	credentials *shared.CredentialsConfig
+	logger      log.Logger
+}
+
+func NewSettingsController(
+	store *settings.Store, credentials *shared.CredentialsConfig, logger log.Logger,
+) SettingsController {
+	return SettingsController{
+		store:       store,
+		credentials: credentials,
+		logger:      logger,
+	}
+}
+
+// settingsDrive builds an appDataFolder-capable drive client for the
+// session user.
+func (c SettingsController) settingsDrive(r *http.Request, ures response.UserResponse) (settings.SettingsDrive, error) {
+	gclient := (&oauth2.Config{
+		ClientID:     c.credentials.Credentials.ClientID,
+		ClientSecret: c.credentials.Credentials.ClientSecret,
+		Endpoint:     google.Endpoint,
+	}).Client(r.Context(), &oauth2.Token{
+		AccessToken:  ures.AccessToken,
+		RefreshToken: ures.RefreshToken,
+		TokenType:    ures.TokenType,
+	})
+
+	srv, err := drive.NewService(r.Context(), option.WithHTTPClient(gclient))
+	if err != nil {
+		return nil, err
+	}
+	return settings.NewDriveClient(srv), nil
+}
+
+// BuildGetSettings serves the user's stored settings; users without a
+// settings document or without the appdata scope get the defaults.
+func (c SettingsController) BuildGetSettings() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		client, err := c.settingsDrive(r, ures)
+		if err != nil {
+			c.logger.Errorf("could not initialize drive service: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		stored, err := c.store.Get(client, ures.ID)
+		if err != nil {
+			c.logger.Errorf("could not read settings for user %s: %s", ures.ID, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(stored.ToJSON())
+	}
+}
+
+// BuildPostSettings validates and persists the posted settings.
+func (c SettingsController) BuildPostSettings() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ures, ok := r.Context().Value(middleware.ContextUser).(response.UserResponse)
+		if !ok {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var body settings.UserSettings
+		if err := request.DecodeStrict(io.LimitReader(r.Body, 1<<12), &body); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !validSettings(body) {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		client, err := c.settingsDrive(r, ures)
+		if err != nil {
+			c.logger.Errorf("could not initialize drive service: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := c.store.Save(client, ures.ID, body); err != nil {
+			c.logger.Errorf("could not save settings for user %s: %s", ures.ID, err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(body.ToJSON())
+	}
+}
+
+// validSettings accepts only known preference values; every field may also
+// be empty, meaning "no preference".
+func validSettings(body settings.UserSettings) bool {
+	if body.NewFileType != "" {
+		if _, ok := shared.CreateFileMapper[body.NewFileType]; !ok {
+			return false
+		}
+	}
+
+	switch body.ConversionAction {
+	case "", "convert", "view":
+	default:
+		return false
+	}
+
+	for ext, action := range body.OpenAs {
+		if !request.ValidExtension(ext) {
+			return false
+		}
+		switch action {
+		case "edit", "view", "create":
+		default:
+			return false
+		}
+	}
+	for ext := range body.OpenAsSkip {
+		if !request.ValidExtension(ext) {
+			return false
+		}
+	}
+
+	if body.Locale != "" && embeddable.MatchTemplateLocale(body.Locale) != body.Locale {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SourceURLService signs gateway download URLs the Document Server fetches
+// file content from. Both the api and convert controllers need these URLs,
+// so the signing lives behind its own interface instead of one controller
+// holding a reference to the other.
+type SourceURLService interface {
+	// SourceURL builds a signed, short-lived download URL for the given
+	// user's file.
+	SourceURL(uid, fileID string) (string, error)
+}
+
+type signedSourceURLService struct {
+	jwtManager  crypto.JwtManager
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	// clock stamps the token's validity window; injected so tests can
+	// assert on deterministic tokens.
+	clock shared.Clock
+}
+
+var _ SourceURLService = signedSourceURLService{}
+
+func NewSourceURLService(
+	jwtManager crypto.JwtManager, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource,
+) SourceURLService {
+	return signedSourceURLService{
+		jwtManager:  jwtManager,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		clock:       shared.SystemClock{},
+	}
+}
+
+// SourceURL signs a download token for the file and wraps it into the
+// gateway's download endpoint. The api controller's download proxy verifies
+// the token with the same secret.
+func (s signedSourceURLService) SourceURL(uid, fileID string) (string, error) {
+	token, err := s.jwtManager.Sign(s.credentials.Credentials.ClientSecret, shared.DownloadToken{
+		UID:    uid,
+		FileID: fileID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(s.clock.Now()),
+			ExpiresAt: jwt.NewNumericDate(s.clock.Now().Add(4 * time.Minute)),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"%s/api/download?token=%s",
+		s.onlyoffice.Config().Onlyoffice.Builder.GatewayURL, url.QueryEscape(token),
+	), nil
+}
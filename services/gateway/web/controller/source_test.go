@@ -0,0 +1,135 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type fakeJwtManager struct {
+	token  string
+	err    error
+	secret string
+	claims jwt.Claims
+}
+
+func (f *fakeJwtManager) Sign(secret string, payload jwt.Claims) (string, error) {
+	f.secret = secret
+	f.claims = payload
+	if f.err != nil {
+		return "", f.err
+	}
+	return f.token, nil
+}
+
+func (f *fakeJwtManager) Verify(secret, token string, body interface{}) error {
+	return nil
+}
+
+func newTestSourceService(t *testing.T, manager *fakeJwtManager) SourceURLService {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := &shared.CredentialsConfig{}
+	credentials.Credentials.ClientSecret = "client-secret"
+	return NewSourceURLService(manager, credentials, source)
+}
+
+func TestSourceURLSignsDownloadToken(t *testing.T) {
+	manager := &fakeJwtManager{token: "signed/token+value"}
+	sources := newTestSourceService(t, manager)
+
+	url, err := sources.SourceURL("1000000000", "file-id-0123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if url != "https://gateway.example.com/api/download?token=signed%2Ftoken%2Bvalue" {
+		t.Errorf("unexpected download url: %s", url)
+	}
+	if manager.secret != "client-secret" {
+		t.Errorf("expected the client secret to sign the token, got %q", manager.secret)
+	}
+
+	token, ok := manager.claims.(shared.DownloadToken)
+	if !ok {
+		t.Fatalf("expected a download token, got %T", manager.claims)
+	}
+	if token.UID != "1000000000" || token.FileID != "file-id-0123456789" {
+		t.Errorf("unexpected token subject: %s %s", token.UID, token.FileID)
+	}
+	if token.ExpiresAt == nil || token.IssuedAt == nil ||
+		token.ExpiresAt.Sub(token.IssuedAt.Time) != 4*time.Minute {
+		t.Errorf("expected a four minute expiry window, got %v %v", token.IssuedAt, token.ExpiresAt)
+	}
+}
+
+func TestSourceURLPropagatesSigningErrors(t *testing.T) {
+	manager := &fakeJwtManager{err: errors.New("no key material")}
+	sources := newTestSourceService(t, manager)
+
+	if _, err := sources.SourceURL("1000000000", "file-id-0123456789"); err == nil {
+		t.Error("expected the signing error to surface")
+	}
+}
+
+// With a pinned clock the signed claims are fully deterministic — the
+// property the injected clock exists for: two runs over the same file
+// produce byte-identical tokens instead of ones differing by issue time.
+func TestSourceURLIsDeterministicWithAPinnedClock(t *testing.T) {
+	instant := time.Unix(1700000000, 0)
+	manager := &fakeJwtManager{token: "signed-token"}
+	sources := newTestSourceService(t, manager).(signedSourceURLService)
+	sources.clock = shared.FixedClock{Instant: instant}
+
+	for i := 0; i < 2; i++ {
+		if _, err := sources.SourceURL("1000000000", "file-id-0123456789"); err != nil {
+			t.Fatal(err)
+		}
+		token, ok := manager.claims.(shared.DownloadToken)
+		if !ok {
+			t.Fatalf("expected a download token, got %T", manager.claims)
+		}
+		if !token.IssuedAt.Time.Equal(instant) ||
+			!token.ExpiresAt.Time.Equal(instant.Add(4*time.Minute)) {
+			t.Errorf("run %d: expected the pinned validity window, got %v %v", i, token.IssuedAt, token.ExpiresAt)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/oauth2"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/option"
+)
+
+const defaultWarmupTimeout = 5 * time.Second
+
+var warmupDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name: "onlyoffice_gateway_login_warmup_seconds",
+	Help: "Duration of the post-login Drive connection warm-up.",
+})
+
+// warmup pre-establishes the Drive connections a fresh session is about to
+// need: the oauth client, the TLS handshakes to googleapis.com and a
+// fields-limited About probe. It runs in the background and swallows every
+// failure, so login latency never depends on it.
+func (c AuthController) warmup(token *oauth2.Token) {
+	auth := c.onlyoffice.Config().Onlyoffice.Auth
+	if !auth.Warmup {
+		return
+	}
+
+	timeout := time.Duration(auth.WarmupTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultWarmupTimeout
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		started := time.Now()
+		if err := c.warmupProbe(ctx, token); err != nil {
+			c.logger.Debugf("login warm-up failed: %s", err.Error())
+		}
+		warmupDuration.Observe(time.Since(started).Seconds())
+	}()
+}
+
+// probeDrive is the default warm-up probe: build the Drive service the next
+// document open would build and make the cheapest authenticated call.
+func (c AuthController) probeDrive(ctx context.Context, token *oauth2.Token) error {
+	srv, err := drive.NewService(ctx, option.WithTokenSource(
+		c.oauthConfig().TokenSource(ctx, token),
+	))
+	if err != nil {
+		return err
+	}
+
+	_, err = srv.About.Get().Fields("user").Context(ctx).Do()
+	return err
+}
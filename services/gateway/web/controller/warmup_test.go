@@ -0,0 +1,111 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"golang.org/x/oauth2"
+)
+
+// warmupTestLogger records debug lines, the only output a failed warm-up may
+// produce.
+type warmupTestLogger struct {
+	debugged int
+}
+
+func (l *warmupTestLogger) Debugf(format string, args ...interface{}) { l.debugged++ }
+func (l *warmupTestLogger) Infof(format string, args ...interface{})  {}
+func (l *warmupTestLogger) Warnf(format string, args ...interface{})  {}
+func (l *warmupTestLogger) Errorf(format string, args ...interface{}) {}
+func (l *warmupTestLogger) Fatalf(format string, args ...interface{}) {}
+
+func newWarmupTestController(t *testing.T, enabled bool) (*AuthController, *warmupTestLogger) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := fmt.Sprintf(`onlyoffice:
+  auth:
+    warmup: %t
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+`, enabled)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &warmupTestLogger{}
+	return &AuthController{
+		credentials: &shared.CredentialsConfig{},
+		onlyoffice:  source,
+		logger:      logger,
+	}, logger
+}
+
+// A failing warm-up only produces a debug line: warmup returns immediately
+// and never surfaces the error to the login flow.
+func TestWarmupFailureDoesNotFailLogin(t *testing.T) {
+	controller, logger := newWarmupTestController(t, true)
+
+	probed := make(chan struct{})
+	controller.warmupProbe = func(ctx context.Context, token *oauth2.Token) error {
+		defer close(probed)
+		return errors.New("googleapis.com is unreachable")
+	}
+
+	controller.warmup(&oauth2.Token{RefreshToken: "refresh-token"})
+
+	select {
+	case <-probed:
+	case <-time.After(time.Second):
+		t.Fatal("the warm-up probe never ran")
+	}
+	// The goroutine logs after the probe returns; give it a moment.
+	deadline := time.Now().Add(time.Second)
+	for logger.debugged == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if logger.debugged == 0 {
+		t.Error("expected the warm-up failure to be logged")
+	}
+}
+
+func TestWarmupDisabledByDefault(t *testing.T) {
+	controller, _ := newWarmupTestController(t, false)
+	controller.warmupProbe = func(ctx context.Context, token *oauth2.Token) error {
+		t.Error("the probe must not run while warm-up is disabled")
+		return nil
+	}
+
+	controller.warmup(&oauth2.Token{RefreshToken: "refresh-token"})
+	time.Sleep(50 * time.Millisecond)
+}
@@ -0,0 +1,54 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import (
+	"embed"
+	"html/template"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Static holds the css/js assets served under /static.
+//
+//go:embed static
+var Static embed.FS
+
+// OfficeFiles holds the localized new-document templates seeded into Drive
+// by the create endpoint.
+//
+//go:embed files
+var OfficeFiles embed.FS
+
+var (
+	ConvertPage = template.Must(template.ParseFS(templates, "templates/convert.html"))
+	CreatePage  = template.Must(template.ParseFS(templates, "templates/create.html"))
+	EditorPage  = template.Must(template.ParseFS(templates, "templates/editor.html"))
+	EmailPage   = template.Must(template.ParseFS(templates, "templates/email.html"))
+	// The error page stamps the build version into a footer comment so
+	// support can read it off a screenshot.
+	ErrorPage = template.Must(template.New("error.html").Funcs(template.FuncMap{
+		"buildVersion": buildinfo.UserAgent,
+	}).ParseFS(templates, "templates/error.html"))
+	PickerPage   = template.Must(template.ParseFS(templates, "templates/picker.html"))
+	SessionsPage = template.Must(template.ParseFS(templates, "templates/sessions.html"))
+)
@@ -0,0 +1,179 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import (
+	"archive/zip"
+	"bytes"
+	"html/template"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+)
+
+// Every embedded locale folder must carry all new-document templates; a
+// missing file would otherwise only show up as a runtime 500.
+func TestVerifyOfficeTemplates(t *testing.T) {
+	if err := VerifyOfficeTemplates(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	locales := TemplateLocales()
+	if len(locales) == 0 {
+		t.Fatal("expected at least one template locale")
+	}
+	for _, locale := range locales {
+		if locale == "en-US" {
+			return
+		}
+	}
+	t.Fatal("en-US templates must always be embedded")
+}
+
+// The preloaded template map must cover every locale and every extension
+// the create mapper can request, otherwise a combination only fails at
+// runtime.
+func TestOfficeTemplatesPreloaded(t *testing.T) {
+	for _, locale := range TemplateLocales() {
+		for _, ext := range shared.CreateFileMapper {
+			reader, err := OfficeTemplate(locale, ext)
+			if err != nil {
+				t.Errorf("no preloaded template for %s/%s: %s", locale, ext, err.Error())
+				continue
+			}
+			if reader.Len() == 0 {
+				t.Errorf("preloaded template for %s/%s is empty", locale, ext)
+			}
+		}
+	}
+
+	if _, err := OfficeTemplate("en-US", "exe"); err == nil {
+		t.Error("expected an error for an unknown extension")
+	}
+}
+
+func TestMatchTemplateLocale(t *testing.T) {
+	for requested, want := range map[string]string{
+		"en-US": "en-US",
+		"pt-BR": "pt-BR",
+		"pt":    "pt-BR",
+		"pt-PT": "pt-BR",
+		"de":    "de-DE",
+		"fr-FR": "fr-FR",
+		"ja":    "ja-JP",
+		"nl-NL": "en-US",
+		"":      "en-US",
+	} {
+		if got := MatchTemplateLocale(requested); got != want {
+			t.Errorf("MatchTemplateLocale(%q) = %q, want %q", requested, got, want)
+		}
+	}
+}
+
+// Variant selection only considers folders whose template for the extension
+// is genuinely localized, so presentations come from the default folder
+// until a locale ships a real pptx variant.
+func TestMatchTemplateVariant(t *testing.T) {
+	for name, tc := range map[string]struct {
+		requested string
+		ext       string
+		want      string
+	}{
+		"exact variant":          {"de-DE", "xlsx", "de-DE"},
+		"language variant":       {"ru", "docx", "ru-RU"},
+		"copy-only variant":      {"de-DE", "pptx", "en-US"},
+		"unknown locale":         {"nl-NL", "xlsx", "en-US"},
+		"empty locale":           {"", "docx", "en-US"},
+		"default keeps its pptx": {"en-US", "pptx", "en-US"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := MatchTemplateVariant(tc.requested, tc.ext); got != tc.want {
+				t.Errorf("MatchTemplateVariant(%q, %q) = %q, want %q",
+					tc.requested, tc.ext, got, tc.want)
+			}
+		})
+	}
+}
+
+// The German spreadsheet template must carry comma-decimal number formats;
+// this is the observable difference between a real variant and a copy.
+func TestGermanSpreadsheetTemplateUsesCommaDecimals(t *testing.T) {
+	reader, err := OfficeTemplate("de-DE", "xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(reader, reader.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	styles, err := archive.Open("xl/styles.xml")
+	if err != nil {
+		t.Fatalf("de-DE spreadsheet has no styles part: %s", err.Error())
+	}
+	defer styles.Close()
+
+	content, err := io.ReadAll(styles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `formatCode="#.##0,00"`) {
+		t.Error("expected comma-decimal number formats in the de-DE spreadsheet")
+	}
+}
+
+// Every page must render without errors and carry the viewport meta so
+// phones get the responsive layout.
+func TestTemplatesRenderWithViewport(t *testing.T) {
+	for name, page := range map[string]struct {
+		tmpl *template.Template
+		data map[string]interface{}
+	}{
+		"convert": {ConvertPage, map[string]interface{}{
+			"file": "report.doc", "extension": "doc", "lossEdit": false,
+			"viewOnly": true, "canCopy": true, "state": "{}",
+		}},
+		"create": {CreatePage, map[string]interface{}{"state": "{}"}},
+		"editor": {EditorPage, map[string]interface{}{"apijs": "https://ds/api.js", "config": "{}"}},
+		"email": {EmailPage, map[string]interface{}{
+			"file": "message.eml", "subject": "Quarterly numbers", "attachments": nil,
+		}},
+		"error": {ErrorPage, map[string]interface{}{
+			"errorMain": "Something went wrong", "errorSubtext": "Please reload the page",
+		}},
+		"picker": {PickerPage, map[string]interface{}{
+			"folder": "root", "items": nil, "nextPage": "",
+		}},
+		"sessions": {SessionsPage, map[string]interface{}{
+			"limit": 3, "sessions": nil,
+		}},
+	} {
+		var buf bytes.Buffer
+		if err := page.tmpl.Execute(&buf, page.data); err != nil {
+			t.Errorf("%s failed to render: %s", name, err.Error())
+			continue
+		}
+		if !strings.Contains(buf.String(), `name="viewport"`) {
+			t.Errorf("%s is missing the viewport meta", name)
+		}
+	}
+}
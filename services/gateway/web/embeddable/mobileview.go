@@ -0,0 +1,44 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import "github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+
+// mobileViewOnlyTexts carries the notice shown over the editor when the
+// builder downgraded a mobile session to view because the mobile editor
+// cannot edit the file's format. The languages match the localized default
+// file names.
+var mobileViewOnlyTexts = map[string]string{
+	"en": "This file type cannot be edited on a mobile device, so it opened for viewing",
+	"de": "Dieser Dateityp kann auf einem Mobilgerät nicht bearbeitet werden und wurde daher zum Ansehen geöffnet",
+	"fr": "Ce type de fichier ne peut pas être modifié sur un appareil mobile, il a donc été ouvert en lecture",
+	"ja": "このファイル形式はモバイル端末では編集できないため、表示モードで開きました",
+	"pt": "Este tipo de arquivo não pode ser editado em um dispositivo móvel, então foi aberto para visualização",
+	"ru": "Этот тип файла нельзя редактировать на мобильном устройстве, поэтому он открыт для просмотра",
+}
+
+// MobileViewOnlyMessage returns the mobile view-only notice in the locale's
+// language when it is translated and English otherwise.
+func MobileViewOnlyMessage(locale string) string {
+	language := shared.BaseLanguage(locale)
+	if text, ok := mobileViewOnlyTexts[language]; ok {
+		return text
+	}
+	return mobileViewOnlyTexts["en"]
+}
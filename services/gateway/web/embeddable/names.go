@@ -0,0 +1,82 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import "github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+
+// Message ids for the default names of files created without an explicit
+// name, one per document type.
+const (
+	newDocumentName     = "newDocumentName"
+	newSpreadsheetName  = "newSpreadsheetName"
+	newPresentationName = "newPresentationName"
+)
+
+// newFileMessages maps a template extension to its default-name message id.
+var newFileMessages = map[string]string{
+	"docx": newDocumentName,
+	"xlsx": newSpreadsheetName,
+	"pptx": newPresentationName,
+}
+
+// newFileNames carries the localized default names per message id, keyed by
+// language. The languages match the embedded template locales.
+var newFileNames = map[string]map[string]string{
+	newDocumentName: {
+		"en": "New Document",
+		"de": "Neues Dokument",
+		"fr": "Nouveau document",
+		"ja": "新しいドキュメント",
+		"pt": "Novo documento",
+		"ru": "Новый документ",
+	},
+	newSpreadsheetName: {
+		"en": "New Spreadsheet",
+		"de": "Neue Tabellenkalkulation",
+		"fr": "Nouvelle feuille de calcul",
+		"ja": "新しいスプレッドシート",
+		"pt": "Nova planilha",
+		"ru": "Новая таблица",
+	},
+	newPresentationName: {
+		"en": "New Presentation",
+		"de": "Neue Präsentation",
+		"fr": "Nouvelle présentation",
+		"ja": "新しいプレゼンテーション",
+		"pt": "Nova apresentação",
+		"ru": "Новая презентация",
+	},
+}
+
+// DefaultFileName returns the localized base name (without extension) for a
+// new file of the given template extension: the locale's language when it is
+// translated, English otherwise.
+func DefaultFileName(locale, ext string) string {
+	names, ok := newFileNames[newFileMessages[ext]]
+	if !ok {
+		return ""
+	}
+
+	language := shared.BaseLanguage(locale)
+	if name, ok := names[language]; ok {
+		return name
+	}
+
+	return names["en"]
+}
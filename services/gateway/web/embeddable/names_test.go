@@ -0,0 +1,61 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultFileName(t *testing.T) {
+	for name, tc := range map[string]struct {
+		locale string
+		ext    string
+		want   string
+	}{
+		"russian document":           {"ru-RU", "docx", "Новый документ"},
+		"russian spreadsheet":        {"ru", "xlsx", "Новая таблица"},
+		"japanese document":          {"ja-JP", "docx", "新しいドキュメント"},
+		"japanese presentation":      {"ja", "pptx", "新しいプレゼンテーション"},
+		"untranslated falls back":    {"nl-NL", "docx", "New Document"},
+		"empty locale falls back":    {"", "xlsx", "New Spreadsheet"},
+		"unknown extension is empty": {"ru-RU", "bin", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := DefaultFileName(tc.locale, tc.ext); got != tc.want {
+				t.Errorf("DefaultFileName(%q, %q) = %q, want %q",
+					tc.locale, tc.ext, got, tc.want)
+			}
+		})
+	}
+}
+
+// Every language with a template folder must have a translated name for
+// every message id, so a locale never mixes a localized template with an
+// English default name.
+func TestDefaultFileNamesCoverTemplateLocales(t *testing.T) {
+	for _, locale := range TemplateLocales() {
+		language := strings.ToLower(strings.Split(locale, "-")[0])
+		for id, names := range newFileNames {
+			if _, ok := names[language]; !ok {
+				t.Errorf("locale %s has no %s translation", locale, id)
+			}
+		}
+	}
+}
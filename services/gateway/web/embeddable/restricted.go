@@ -0,0 +1,50 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import (
+	"fmt"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+)
+
+// restrictedDomainTexts carries the error page copy shown to users outside
+// a restricted deployment's allowed domains, keyed by language. The %s in
+// the subtext receives the organization's domain list. The languages match
+// the localized default file names.
+var restrictedDomainTexts = map[string]struct{ Main, Subtext string }{
+	"en": {"Access restricted", "This integration is restricted to %s"},
+	"de": {"Zugriff eingeschränkt", "Diese Integration ist auf %s beschränkt"},
+	"fr": {"Accès restreint", "Cette intégration est réservée à %s"},
+	"ja": {"アクセスが制限されています", "この連携は %s に限定されています"},
+	"pt": {"Acesso restrito", "Esta integração é restrita a %s"},
+	"ru": {"Доступ ограничен", "Эта интеграция доступна только для %s"},
+}
+
+// RestrictedDomainMessage returns the error page heading and subtext telling
+// a user the deployment is restricted to org, in the locale's language when
+// it is translated and English otherwise.
+func RestrictedDomainMessage(locale, org string) (string, string) {
+	language := shared.BaseLanguage(locale)
+	text, ok := restrictedDomainTexts[language]
+	if !ok {
+		text = restrictedDomainTexts["en"]
+	}
+	return text.Main, fmt.Sprintf(text.Subtext, org)
+}
@@ -0,0 +1,42 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import "testing"
+
+func TestRestrictedDomainMessage(t *testing.T) {
+	for name, tc := range map[string]struct {
+		locale      string
+		wantMain    string
+		wantSubtext string
+	}{
+		"english":                 {"en-US", "Access restricted", "This integration is restricted to example.org"},
+		"german":                  {"de", "Zugriff eingeschränkt", "Diese Integration ist auf example.org beschränkt"},
+		"untranslated falls back": {"nl-NL", "Access restricted", "This integration is restricted to example.org"},
+		"empty locale falls back": {"", "Access restricted", "This integration is restricted to example.org"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			main, subtext := RestrictedDomainMessage(tc.locale, "example.org")
+			if main != tc.wantMain || subtext != tc.wantSubtext {
+				t.Errorf("RestrictedDomainMessage(%q) = %q, %q, want %q, %q",
+					tc.locale, main, subtext, tc.wantMain, tc.wantSubtext)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import "github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+
+// stalePageTexts carries the message shown when a page submits a one-time
+// nonce that was already spent or has expired. The languages match the
+// localized default file names.
+var stalePageTexts = map[string]string{
+	"en": "This page has expired. Please reload it and try again",
+	"de": "Diese Seite ist abgelaufen. Bitte laden Sie sie neu und versuchen Sie es erneut",
+	"fr": "Cette page a expiré. Veuillez la recharger et réessayer",
+	"ja": "このページの有効期限が切れました。再読み込みしてからもう一度お試しください",
+	"pt": "Esta página expirou. Recarregue-a e tente novamente",
+	"ru": "Страница устарела. Обновите её и попробуйте ещё раз",
+}
+
+// StalePageMessage returns the stale-page notice in the locale's language
+// when it is translated and English otherwise.
+func StalePageMessage(locale string) string {
+	language := shared.BaseLanguage(locale)
+	if text, ok := stalePageTexts[language]; ok {
+		return text
+	}
+	return stalePageTexts["en"]
+}
@@ -0,0 +1,289 @@
+(function () {
+  var root = document.querySelector(".convert");
+  var errors = document.querySelector(".convert__errors");
+  var state = JSON.parse(root.dataset.state);
+
+  function showErrors(payload) {
+    if (!payload || !payload.errors) {
+      errors.textContent = "Something went wrong. Please try again.";
+      errors.hidden = false;
+      return;
+    }
+
+    errors.innerHTML = "";
+    payload.errors.forEach(function (err) {
+      var line = document.createElement("div");
+      line.textContent = err.field + ": " + err.message;
+      errors.appendChild(line);
+    });
+    if (payload.link) {
+      var link = document.createElement("a");
+      link.href = payload.link;
+      link.textContent = payload.link_text || payload.link;
+      errors.appendChild(link);
+    }
+    errors.hidden = false;
+  }
+
+  // Optional destination browser: folders are loaded lazily per level, a
+  // click navigates into the folder and marks it as the destination for
+  // the converted copy.
+  var destination = "";
+  var toggle = document.getElementById("convert-destination-toggle");
+  var picker = document.querySelector(".convert__picker");
+  var breadcrumbs = document.querySelector(".convert__breadcrumbs");
+  var folders = document.querySelector(".convert__folders");
+  var more = document.getElementById("convert-folders-more");
+  var chosen = document.querySelector(".convert__destination-chosen");
+  var trail = [];
+
+  function renderBreadcrumbs() {
+    breadcrumbs.innerHTML = "";
+    trail.forEach(function (crumb, index) {
+      var link = document.createElement("a");
+      link.href = "#";
+      link.textContent = crumb.title;
+      link.addEventListener("click", function (event) {
+        event.preventDefault();
+        trail = trail.slice(0, index + 1);
+        loadFolders(crumb.id, "");
+      });
+      breadcrumbs.appendChild(link);
+      if (index < trail.length - 1) {
+        breadcrumbs.appendChild(document.createTextNode(" / "));
+      }
+    });
+  }
+
+  function loadFolders(parent, page) {
+    fetch(
+      "/api/folders?parent=" +
+        encodeURIComponent(parent) +
+        (page ? "&page=" + encodeURIComponent(page) : "")
+    )
+      .then(function (res) {
+        return res.json();
+      })
+      .then(function (payload) {
+        if (!page) folders.innerHTML = "";
+        if (!trail.length || trail[trail.length - 1].id !== payload.current.id) {
+          trail.push({ id: payload.current.id, title: payload.current.title });
+        }
+        destination = payload.current.id === "root" ? "" : payload.current.id;
+        chosen.textContent = destination
+          ? "Copy will be saved to: " + payload.current.title
+          : "";
+        chosen.hidden = !destination;
+        renderBreadcrumbs();
+
+        (payload.folders || []).forEach(function (folder) {
+          var item = document.createElement("li");
+          var link = document.createElement("a");
+          link.href = "#";
+          link.textContent = folder.title;
+          link.addEventListener("click", function (event) {
+            event.preventDefault();
+            loadFolders(folder.id, "");
+          });
+          item.appendChild(link);
+          folders.appendChild(item);
+        });
+
+        more.hidden = !payload.next_page_token;
+        more.onclick = function () {
+          loadFolders(parent, payload.next_page_token);
+        };
+      })
+      .catch(function () {
+        showErrors(null);
+      });
+  }
+
+  if (toggle) {
+    toggle.addEventListener("click", function () {
+      picker.hidden = !picker.hidden;
+      if (!picker.hidden && !trail.length) loadFolders("root", "");
+    });
+  }
+
+  // Conversions take a while, so the create POST only returns a job id; the
+  // page then follows /api/convert/progress to fill the bar until the job
+  // reports done (with the editor state) or failed (with the error list).
+  var progress = document.querySelector(".convert__progress");
+  var progressLabel = document.querySelector(".convert__progress-label");
+  var progressBar = document.querySelector(".convert__progress-bar");
+  var filesList = document.querySelector(".convert__files");
+  var activeJob = null;
+
+  // Abandoning the page cancels the whole job, so batch files still
+  // pending never convert for a viewer who already left.
+  window.addEventListener("pagehide", function () {
+    if (!activeJob) return;
+    navigator.sendBeacon(
+      "/api/convert/cancel?job=" + encodeURIComponent(activeJob)
+    );
+  });
+
+  var phaseLabels = {
+    queued: "Preparing…",
+    converting: "Converting…",
+    downloading: "Fetching the converted file…",
+    uploading: "Saving the copy to Drive…",
+    done: "Done",
+  };
+
+  var fileStatusLabels = {
+    pending: "Waiting…",
+    running: "Converting…",
+    done: "Done",
+    failed: "Failed",
+  };
+
+  function renderProgress(payload) {
+    progressLabel.textContent = phaseLabels[payload.phase] || "Working…";
+    progressBar.style.width = (payload.percent || 0) + "%";
+    progress.hidden = false;
+
+    // Batch jobs report each selected file, in selection order.
+    if (filesList && payload.files) {
+      filesList.innerHTML = "";
+      payload.files.forEach(function (file) {
+        var item = document.createElement("li");
+        item.textContent =
+          file.file_id +
+          ": " +
+          (fileStatusLabels[file.status] || file.status) +
+          (file.reason ? " — " + file.reason : "");
+        filesList.appendChild(item);
+      });
+      filesList.hidden = false;
+    }
+  }
+
+  function pollProgress(job) {
+    fetch("/api/convert/progress?job=" + encodeURIComponent(job))
+      .then(function (res) {
+        if (!res.ok) throw new Error("gone");
+        return res.json();
+      })
+      .then(function (payload) {
+        if (payload.phase === "failed") {
+          activeJob = null;
+          progress.hidden = true;
+          showErrors(payload);
+          // A protected source is fixable: show the password prompt and
+          // let the user retry the conversion with it.
+          if (payload.password_required && passwordBlock) {
+            passwordBlock.hidden = false;
+            passwordInput.focus();
+          }
+          return;
+        }
+        renderProgress(payload);
+        if (payload.phase === "done") {
+          activeJob = null;
+          // Batch jobs end on the per-file report instead of relaunching
+          // one editor for many files.
+          if (payload.result) {
+            window.location.href =
+              "/api/editor?state=" +
+              encodeURIComponent(JSON.stringify(payload.result));
+          }
+          return;
+        }
+        setTimeout(pollProgress.bind(null, job), 1000);
+      })
+      .catch(function () {
+        progress.hidden = true;
+        showErrors(null);
+      });
+  }
+
+  // Whether the "edit a template copy" flavor of create is in effect; it
+  // sticks across a password retry, which re-posts the same choice.
+  var asTemplate = false;
+
+  function convert(action) {
+    errors.hidden = true;
+    var body = Object.assign({}, state, { action: action });
+    if (action === "create" && destination) body.destinationId = destination;
+    if (action === "create" && asTemplate) body.asTemplate = true;
+    // The extension and the checkbox travel with the choice, so the server
+    // can remember it per format and skip this page next time.
+    if (root.dataset.extension) {
+      body.extension = root.dataset.extension;
+      body.dontAsk = dontAsk ? dontAsk.checked : false;
+    }
+    // The password rides only in this request body; it is never kept in
+    // the page state or echoed anywhere.
+    if (action === "create" && passwordInput && passwordInput.value) {
+      body.password = passwordInput.value;
+    }
+    // The page nonce is spent by this POST; responses the page continues
+    // from carry a replacement.
+    body.nonce = root.dataset.nonce || "";
+    fetch("/api/convert", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(body),
+    })
+      .then(function (res) {
+        return res.json().then(function (payload) {
+          if (payload && payload.nonce) root.dataset.nonce = payload.nonce;
+          if (!res.ok) {
+            showErrors(payload);
+            return;
+          }
+          if (payload.job) {
+            activeJob = payload.job;
+            renderProgress({ phase: "queued", percent: 0 });
+            pollProgress(payload.job);
+            return;
+          }
+          window.location.href =
+            "/api/editor?state=" + encodeURIComponent(JSON.stringify(payload));
+        });
+      })
+      .catch(function () {
+        showErrors(null);
+      });
+  }
+
+  var dontAsk = document.getElementById("convert-dont-ask");
+
+  var passwordBlock = document.querySelector(".convert__password");
+  var passwordInput = document.getElementById("convert-password");
+  var passwordSubmit = document.getElementById("convert-password-submit");
+  if (passwordSubmit) {
+    passwordSubmit.addEventListener("click", convert.bind(null, "create"));
+  }
+
+  var create = document.getElementById("convert-create");
+  if (create)
+    create.addEventListener("click", function () {
+      asTemplate = false;
+      convert("create");
+    });
+  // Templates offer a second create flavor that keeps the template nature:
+  // the copy comes out as dotx/xltx/potx instead of the plain document.
+  var createTemplate = document.getElementById("convert-create-template");
+  if (createTemplate)
+    createTemplate.addEventListener("click", function () {
+      asTemplate = true;
+      convert("create");
+    });
+  var view = document.getElementById("convert-view");
+  view.addEventListener("click", convert.bind(null, "view"));
+
+  var edit = document.getElementById("convert-edit");
+  if (edit) edit.addEventListener("click", convert.bind(null, "edit"));
+
+  // The last remembered choice is highlighted; a remembered "edit a copy"
+  // with "don't ask again" starts the conversion right away, since a
+  // redirect cannot run the async pipeline.
+  var preferred = { create: create, edit: edit, view: view }[
+    root.dataset.preferred
+  ];
+  if (preferred) preferred.classList.add("convert__actions--preferred");
+  if (root.dataset.autostart === "create") convert("create");
+})();
@@ -0,0 +1,142 @@
+(function () {
+  var root = document.querySelector(".create");
+  var errors = document.querySelector(".create__errors");
+  var state = JSON.parse(root.dataset.state);
+
+  function showErrors(payload) {
+    if (!payload || !payload.errors) {
+      errors.textContent = "Something went wrong. Please try again.";
+      errors.hidden = false;
+      return;
+    }
+
+    errors.innerHTML = "";
+    payload.errors.forEach(function (err) {
+      var line = document.createElement("div");
+      line.textContent = err.field + ": " + err.message;
+      errors.appendChild(line);
+    });
+    errors.hidden = false;
+  }
+
+  // Launches from Drive's "New" menu already carry the destination folder;
+  // the browser is only offered when the page was reached without one, e.g.
+  // through a direct link, so new files do not silently land in root.
+  var destination = state.folderId || "";
+  var destinationBlock = document.querySelector(".create__destination");
+  var toggle = document.getElementById("create-destination-toggle");
+  var picker = document.querySelector(".create__picker");
+  var breadcrumbs = document.querySelector(".create__breadcrumbs");
+  var folders = document.querySelector(".create__folders");
+  var more = document.getElementById("create-folders-more");
+  var chosen = document.querySelector(".create__destination-chosen");
+  var trail = [];
+
+  if (state.folderId) destinationBlock.hidden = true;
+
+  function renderBreadcrumbs() {
+    breadcrumbs.innerHTML = "";
+    trail.forEach(function (crumb, index) {
+      var link = document.createElement("a");
+      link.href = "#";
+      link.textContent = crumb.title;
+      link.addEventListener("click", function (event) {
+        event.preventDefault();
+        trail = trail.slice(0, index + 1);
+        loadFolders(crumb.id, "");
+      });
+      breadcrumbs.appendChild(link);
+      if (index < trail.length - 1) {
+        breadcrumbs.appendChild(document.createTextNode(" / "));
+      }
+    });
+  }
+
+  function loadFolders(parent, page) {
+    fetch(
+      "/api/folders?parent=" +
+        encodeURIComponent(parent) +
+        (page ? "&page=" + encodeURIComponent(page) : "")
+    )
+      .then(function (res) {
+        return res.json();
+      })
+      .then(function (payload) {
+        if (!page) folders.innerHTML = "";
+        if (!trail.length || trail[trail.length - 1].id !== payload.current.id) {
+          trail.push({ id: payload.current.id, title: payload.current.title });
+        }
+        destination = payload.current.id === "root" ? "" : payload.current.id;
+        chosen.textContent = destination
+          ? "Document will be saved to: " + payload.current.title
+          : "";
+        chosen.hidden = !destination;
+        renderBreadcrumbs();
+
+        (payload.folders || []).forEach(function (folder) {
+          var item = document.createElement("li");
+          var link = document.createElement("a");
+          link.href = "#";
+          link.textContent = folder.title;
+          link.addEventListener("click", function (event) {
+            event.preventDefault();
+            loadFolders(folder.id, "");
+          });
+          item.appendChild(link);
+          folders.appendChild(item);
+        });
+
+        more.hidden = !payload.next_page_token;
+        more.onclick = function () {
+          loadFolders(parent, payload.next_page_token);
+        };
+      })
+      .catch(function () {
+        showErrors(null);
+      });
+  }
+
+  if (toggle) {
+    toggle.addEventListener("click", function () {
+      picker.hidden = !picker.hidden;
+      if (!picker.hidden && !trail.length) loadFolders("root", "");
+    });
+  }
+
+  document.getElementById("create-form").addEventListener("submit", function (e) {
+    e.preventDefault();
+    errors.hidden = true;
+
+    // The resource key belongs to the launch folder; a folder picked in the
+    // browser is one the user can already reach and needs no key.
+    var resourceKey =
+      destination === (state.folderId || "") ? state.folderResourceKey || "" : "";
+
+    fetch("/api/create", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({
+        name: document.getElementById("create-name").value,
+        type: document.getElementById("create-type").value,
+        folder_id: destination,
+        folder_resource_key: resourceKey,
+        // The page nonce is spent once a create actually runs; validation
+        // failures leave it usable for the corrected resubmit.
+        nonce: root.dataset.nonce || "",
+      }),
+    })
+      .then(function (res) {
+        return res.json().then(function (payload) {
+          if (!res.ok) {
+            showErrors(payload);
+            return;
+          }
+          window.location.href =
+            "/api/editor?state=" + encodeURIComponent(JSON.stringify(payload));
+        });
+      })
+      .catch(function () {
+        showErrors(null);
+      });
+  });
+})();
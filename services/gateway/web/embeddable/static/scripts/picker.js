@@ -0,0 +1,24 @@
+document.querySelectorAll(".picker__select").forEach(function (button) {
+  button.addEventListener("click", function () {
+    button.disabled = true;
+    fetch("/api/picker/select", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ id: button.dataset.id }),
+    })
+      .then(function (response) {
+        if (!response.ok) throw new Error("selection failed");
+        return response.json();
+      })
+      .then(function (payload) {
+        if (window.parent !== window) {
+          window.parent.postMessage({ onlyofficeUrl: payload.url }, "*");
+        } else {
+          window.location.href = payload.url;
+        }
+      })
+      .catch(function () {
+        button.disabled = false;
+      });
+  });
+});
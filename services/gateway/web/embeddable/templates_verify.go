@@ -0,0 +1,222 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// officeTemplateNames are the files every locale folder under files/ must
+// provide.
+var officeTemplateNames = []string{"new.docx", "new.xlsx", "new.pptx"}
+
+// officeTemplates caches the embedded template bytes, keyed
+// "<locale>/<name>". The create endpoint serves templates from this map so
+// no request ever holds an open handle into the embedded filesystem.
+var officeTemplates = loadOfficeTemplates()
+
+func loadOfficeTemplates() map[string][]byte {
+	templates := map[string][]byte{}
+	for _, locale := range TemplateLocales() {
+		for _, name := range officeTemplateNames {
+			content, err := OfficeFiles.ReadFile(fmt.Sprintf("files/%s/%s", locale, name))
+			if err != nil {
+				continue
+			}
+			templates[fmt.Sprintf("%s/%s", locale, name)] = content
+		}
+	}
+	return templates
+}
+
+// OfficeTemplate returns an in-memory reader over the new-document template
+// for the locale and extension.
+func OfficeTemplate(locale, ext string) (*bytes.Reader, error) {
+	content, ok := officeTemplates[fmt.Sprintf("%s/new.%s", locale, ext)]
+	if !ok {
+		return nil, fmt.Errorf("no embedded template for locale %s and extension %s", locale, ext)
+	}
+	return bytes.NewReader(content), nil
+}
+
+// TemplateLocales lists the locales that ship new-document templates,
+// sorted alphabetically.
+func TemplateLocales() []string {
+	entries, err := OfficeFiles.ReadDir("files")
+	if err != nil {
+		return nil
+	}
+
+	locales := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			locales = append(locales, entry.Name())
+		}
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// MatchTemplateLocale maps a requested locale to the closest embedded
+// template folder: exact match first, then same language (pt matches
+// pt-BR), then en-US.
+func MatchTemplateLocale(requested string) string {
+	locales := TemplateLocales()
+	for _, locale := range locales {
+		if strings.EqualFold(locale, requested) {
+			return locale
+		}
+	}
+
+	language := strings.ToLower(strings.Split(requested, "-")[0])
+	if language != "" {
+		for _, locale := range locales {
+			if strings.ToLower(strings.Split(locale, "-")[0]) == language {
+				return locale
+			}
+		}
+	}
+
+	return "en-US"
+}
+
+// templateMetadata describes which locale folders carry genuinely localized
+// template variants. Folders still ship all files, but a folder not listed
+// for an extension only holds a copy of the default template.
+type templateMetadata struct {
+	Default string              `json:"default"`
+	Locales map[string][]string `json:"locales"`
+}
+
+var officeTemplateMeta = loadTemplateMetadata()
+
+func loadTemplateMetadata() templateMetadata {
+	var meta templateMetadata
+	content, err := OfficeFiles.ReadFile("files/templates.json")
+	if err != nil {
+		return meta
+	}
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return templateMetadata{}
+	}
+	return meta
+}
+
+func (m templateMetadata) hasVariant(locale, ext string) bool {
+	for _, variant := range m.Locales[locale] {
+		if variant == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchTemplateVariant maps a requested locale to the template folder that
+// carries a real variant for the extension: exact locale first, then same
+// language, then the default. A German user's spreadsheet comes from de-DE,
+// but their presentation comes from the default folder because only that
+// variant is genuinely localized.
+func MatchTemplateVariant(requested, ext string) string {
+	if len(officeTemplateMeta.Locales) == 0 {
+		return MatchTemplateLocale(requested)
+	}
+
+	for _, locale := range TemplateLocales() {
+		if strings.EqualFold(locale, requested) && officeTemplateMeta.hasVariant(locale, ext) {
+			return locale
+		}
+	}
+
+	language := strings.ToLower(strings.Split(requested, "-")[0])
+	if language != "" {
+		for _, locale := range TemplateLocales() {
+			if strings.ToLower(strings.Split(locale, "-")[0]) == language &&
+				officeTemplateMeta.hasVariant(locale, ext) {
+				return locale
+			}
+		}
+	}
+
+	return officeTemplateMeta.Default
+}
+
+// VerifyOfficeTemplates checks that every embedded locale folder carries
+// all template files, returning one error that lists everything missing. A
+// typo in a folder otherwise only shows up as a runtime 500 on create.
+func VerifyOfficeTemplates() error {
+	var missing []string
+	for _, locale := range TemplateLocales() {
+		for _, name := range officeTemplateNames {
+			path := fmt.Sprintf("files/%s/%s", locale, name)
+			if _, ok := officeTemplates[fmt.Sprintf("%s/%s", locale, name)]; !ok {
+				missing = append(missing, path)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("embedded office templates are missing: %s", strings.Join(missing, ", "))
+	}
+
+	return verifyTemplateMetadata()
+}
+
+// verifyTemplateMetadata cross-checks templates.json against the embedded
+// folders: the metadata must parse, describe every folder, reference only
+// existing folders and known extensions, and name an embedded default.
+func verifyTemplateMetadata() error {
+	meta := officeTemplateMeta
+	if len(meta.Locales) == 0 {
+		return fmt.Errorf("files/templates.json is missing or empty")
+	}
+
+	locales := TemplateLocales()
+	embedded := map[string]bool{}
+	for _, locale := range locales {
+		embedded[locale] = true
+		if _, ok := meta.Locales[locale]; !ok {
+			return fmt.Errorf("template folder %s is not described in templates.json", locale)
+		}
+	}
+
+	if !embedded[meta.Default] {
+		return fmt.Errorf("templates.json default %q has no embedded folder", meta.Default)
+	}
+
+	known := map[string]bool{}
+	for _, name := range officeTemplateNames {
+		known[strings.TrimPrefix(name, "new.")] = true
+	}
+	for locale, variants := range meta.Locales {
+		if !embedded[locale] {
+			return fmt.Errorf("templates.json locale %s has no embedded folder", locale)
+		}
+		for _, ext := range variants {
+			if !known[ext] {
+				return fmt.Errorf("templates.json lists unknown extension %s for %s", ext, locale)
+			}
+		}
+	}
+
+	return nil
+}
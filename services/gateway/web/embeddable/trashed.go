@@ -0,0 +1,46 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import "github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+
+// trashedFileTexts carries the copy shown when a launched file sits in the
+// Drive trash. The link text labels a pointer to the Drive trash view where
+// the file can be restored. The languages match the localized default file
+// names.
+var trashedFileTexts = map[string]struct{ Main, Subtext, Link string }{
+	"en": {"This file is in the trash", "Restore it in Google Drive to keep editing it", "Open the Google Drive trash"},
+	"de": {"Diese Datei befindet sich im Papierkorb", "Stellen Sie sie in Google Drive wieder her, um sie weiter zu bearbeiten", "Google Drive-Papierkorb öffnen"},
+	"fr": {"Ce fichier est dans la corbeille", "Restaurez-le dans Google Drive pour continuer à le modifier", "Ouvrir la corbeille Google Drive"},
+	"ja": {"このファイルはゴミ箱にあります", "編集を続けるには Google ドライブで復元してください", "Google ドライブのゴミ箱を開く"},
+	"pt": {"Este arquivo está na lixeira", "Restaure-o no Google Drive para continuar editando", "Abrir a lixeira do Google Drive"},
+	"ru": {"Этот файл находится в корзине", "Восстановите его в Google Диске, чтобы продолжить редактирование", "Открыть корзину Google Диска"},
+}
+
+// TrashedFileMessage returns the heading, subtext and restore-link label for
+// the trashed-file error page, in the locale's language when it is
+// translated and English otherwise.
+func TrashedFileMessage(locale string) (string, string, string) {
+	language := shared.BaseLanguage(locale)
+	text, ok := trashedFileTexts[language]
+	if !ok {
+		text = trashedFileTexts["en"]
+	}
+	return text.Main, text.Subtext, text.Link
+}
@@ -0,0 +1,46 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package embeddable
+
+import "github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+
+// dsUnavailableTexts carries the copy shown when the Document Server cannot
+// be reached — on the error page when the gateway already knows, and in the
+// editor page's fallback when api.js fails to load in the browser. The
+// languages match the localized default file names.
+var dsUnavailableTexts = map[string]struct{ Main, Subtext string }{
+	"en": {"Document server unavailable", "The document editor cannot be reached right now. Please try again later"},
+	"de": {"Dokumentenserver nicht erreichbar", "Der Dokumenteneditor ist derzeit nicht erreichbar. Bitte versuchen Sie es später erneut"},
+	"fr": {"Serveur de documents indisponible", "L'éditeur de documents est momentanément inaccessible. Veuillez réessayer plus tard"},
+	"ja": {"ドキュメントサーバーに接続できません", "現在ドキュメントエディターに接続できません。後でもう一度お試しください"},
+	"pt": {"Servidor de documentos indisponível", "O editor de documentos não está acessível no momento. Tente novamente mais tarde"},
+	"ru": {"Сервер документов недоступен", "Редактор документов сейчас недоступен. Пожалуйста, попробуйте позже"},
+}
+
+// DSUnavailableMessage returns the heading and subtext for the Document
+// Server unavailable error, in the locale's language when it is translated
+// and English otherwise.
+func DSUnavailableMessage(locale string) (string, string) {
+	language := shared.BaseLanguage(locale)
+	text, ok := dsUnavailableTexts[language]
+	if !ok {
+		text = dsUnavailableTexts["en"]
+	}
+	return text.Main, text.Subtext
+}
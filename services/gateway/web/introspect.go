@@ -0,0 +1,51 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+)
+
+// buildEffectiveConfig answers the configuration the gateway is actually
+// running with — after environment overlays and hot reloads — with every
+// secret masked. Operators debugging an install ask exactly this question;
+// the route registers in debug mode only and still requires a session.
+func (s DriveHTTPService) buildEffectiveConfig() http.HandlerFunc {
+	return func(rw http.ResponseWriter, _ *http.Request) {
+		sanitized, err := shared.SanitizeConfig(s.onlyoffice.Config().Onlyoffice)
+		if err != nil {
+			s.logger.Errorf("could not sanitize the effective configuration: %s", err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]interface{}{
+			"server": map[string]interface{}{
+				"namespace": s.server.Namespace,
+				"address":   s.server.Address,
+				"debug":     s.server.Debug,
+			},
+			"onlyoffice": sanitized,
+		})
+	}
+}
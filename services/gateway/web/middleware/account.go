@@ -0,0 +1,175 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// switchSession makes uid the active account after a launch by a different
+// account than the cookie session's. The new token is set both as the active
+// cookie and as the account's keyed copy; the account's email travels from
+// the old keyed cookie, when the browser still holds one, so future consent
+// redirects can hint the right account.
+func (m SessionMiddleware) switchSession(rw http.ResponseWriter, r *http.Request, uid string) error {
+	auth := m.onlyoffice.Config().Onlyoffice.Auth
+	lifetime := auth.SessionDuration()
+	now := m.clock.Now()
+	claims := shared.SessionToken{
+		UID:   uid,
+		Email: m.accountEmail(r, uid),
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(lifetime)),
+		},
+	}
+	// A switch mints a brand-new session for the other account, so its
+	// absolute expiry anchors here, not at the old session's sign-in.
+	if auth.SessionMaxAge > 0 {
+		claims.AbsoluteExpiresAt = jwt.NewNumericDate(
+			now.Add(time.Duration(auth.SessionMaxAge) * time.Second),
+		)
+	}
+	session, err := m.jwtManager.Sign(m.credentials.Credentials.ClientSecret, claims)
+	if err != nil {
+		return err
+	}
+
+	m.writeSessionCookies(rw, uid, m.codec.Encode(session, lifetime), int(lifetime/time.Second))
+	return nil
+}
+
+// renewSession slides the session cookie forward when less than the
+// configured threshold of its lifetime remains; requests further from
+// expiry leave the cookie untouched. Renewal extends the exp claim by the
+// configured lifetime but never past the session's absolute expiry, so the
+// last window before re-consent just comes out shorter.
+func (m SessionMiddleware) renewSession(rw http.ResponseWriter, session shared.SessionToken) {
+	if session.ExpiresAt == nil {
+		return
+	}
+	auth := m.onlyoffice.Config().Onlyoffice.Auth
+	threshold := time.Duration(auth.SessionRenewalThreshold) * time.Second
+	now := m.clock.Now()
+	if threshold <= 0 || session.ExpiresAt.Time.Sub(now) >= threshold {
+		return
+	}
+
+	expires := now.Add(auth.SessionDuration())
+	if session.AbsoluteExpiresAt != nil && expires.After(session.AbsoluteExpiresAt.Time) {
+		expires = session.AbsoluteExpiresAt.Time
+	}
+	if !expires.After(now) {
+		return
+	}
+
+	session.IssuedAt = jwt.NewNumericDate(now)
+	session.ExpiresAt = jwt.NewNumericDate(expires)
+	token, err := m.jwtManager.Sign(m.credentials.Credentials.ClientSecret, session)
+	if err != nil {
+		// The old cookie is still valid; the renewal just retries on the
+		// next request.
+		m.logger.Warnf("could not renew the session of user %s: %s", session.UID, err.Error())
+		return
+	}
+
+	ttl := expires.Sub(now)
+	m.writeSessionCookies(rw, session.UID, m.codec.Encode(token, ttl), int(ttl/time.Second))
+}
+
+// writeSessionCookies sets the active session cookie and the account's
+// keyed copy to the same encoded value.
+func (m SessionMiddleware) writeSessionCookies(rw http.ResponseWriter, uid, value string, maxAge int) {
+	for _, name := range []string{
+		shared.SessionCookie, shared.AccountSessionCookie(uid),
+	} {
+		http.SetCookie(rw, &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     "/",
+			MaxAge:   maxAge,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// accountEmail recovers the email a previous session for uid recorded in its
+// keyed cookie. An empty string means this browser never signed in as uid.
+func (m SessionMiddleware) accountEmail(r *http.Request, uid string) string {
+	cookie, err := r.Cookie(shared.AccountSessionCookie(uid))
+	if err != nil {
+		return ""
+	}
+	token, ok := m.codec.Decode(cookie.Value)
+	if !ok {
+		return ""
+	}
+
+	var session shared.SessionToken
+	if err := m.jwtManager.Verify(m.credentials.Credentials.ClientSecret, token, &session); err != nil {
+		return ""
+	}
+	return session.Email
+}
+
+// accountProven reports whether the browser holds a valid keyed session
+// cookie for uid — proof it completed consent as that account before. The
+// launch state naming uid is unsigned, caller-controlled JSON, so a switch
+// is only honored with this proof; without it the account chooser decides.
+func (m SessionMiddleware) accountProven(r *http.Request, uid string) bool {
+	cookie, err := r.Cookie(shared.AccountSessionCookie(uid))
+	if err != nil {
+		return false
+	}
+	token, ok := m.codec.Decode(cookie.Value)
+	if !ok {
+		return false
+	}
+
+	var session shared.SessionToken
+	if err := m.jwtManager.Verify(m.credentials.Credentials.ClientSecret, token, &session); err != nil {
+		return false
+	}
+	return session.UID == uid
+}
+
+// accountSwitchURL builds the consent redirect for a state user without
+// stored tokens. prompt=select_account forces the account chooser even when
+// Google would silently reuse the browser's default account; the login_hint,
+// when an earlier session recorded the email, preselects the right one. The
+// raw launch state rides along so the document still opens after consent.
+func (m SessionMiddleware) accountSwitchURL(r *http.Request, state request.DriveState, raw string) string {
+	query := url.Values{}
+	query.Set("prompt", "select_account")
+	if email := m.accountEmail(r, state.UserID); email != "" {
+		query.Set("login_hint", email)
+	}
+	if raw != "" {
+		query.Set("state", raw)
+	}
+	return "/oauth/install?" + query.Encode()
+}
@@ -0,0 +1,435 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/cache"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/golang-jwt/jwt/v4"
+	goauth "google.golang.org/api/oauth2/v2"
+)
+
+// sessionJwtManager round-trips claims through base64 json so tests can mint
+// and inspect session cookies without real signing.
+type sessionJwtManager struct{}
+
+func (sessionJwtManager) Sign(secret string, payload jwt.Claims) (string, error) {
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func (sessionJwtManager) Verify(secret, token string, body interface{}) error {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, body)
+}
+
+type accountTestLogger struct{}
+
+func (accountTestLogger) Debugf(format string, args ...interface{}) {}
+func (accountTestLogger) Infof(format string, args ...interface{})  {}
+func (accountTestLogger) Warnf(format string, args ...interface{})  {}
+func (accountTestLogger) Errorf(format string, args ...interface{}) {}
+func (accountTestLogger) Fatalf(format string, args ...interface{}) {}
+
+// A single cache per binary keeps the prometheus registry happy; tests warm
+// it under distinct keys.
+var accountTestIdentity = cache.NewIdentityCache(time.Minute)
+
+// newAccountTestMiddleware wires a middleware whose auth lookups answer from
+// stored: present uids resolve, absent ones fail as the RPC would.
+func newAccountTestMiddleware(t *testing.T, stored ...string) SessionMiddleware {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+  gateway:
+    page_budget: 2
+    auth_timeout: 1
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := &shared.CredentialsConfig{}
+	credentials.Credentials.ClientSecret = "client-secret"
+
+	records := map[string]bool{}
+	for _, uid := range stored {
+		uid := uid
+		records[uid] = true
+		// The profile fan-out must answer from the cache: these tests have
+		// no Google behind them.
+		if _, err := accountTestIdentity.Get(
+			fmt.Sprintf("userinfo:%s", uid),
+			func() (interface{}, error) {
+				return &goauth.Userinfo{Id: uid}, nil
+			},
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	middleware := SessionMiddleware{
+		jwtManager:  sessionJwtManager{},
+		credentials: credentials,
+		onlyoffice:  source,
+		identity:    accountTestIdentity,
+		states:      NewStateStore(time.Minute),
+		logger:      accountTestLogger{},
+		clock:       shared.SystemClock{},
+	}
+	middleware.lookupUser = func(ctx context.Context, uid string) (response.UserResponse, error) {
+		if !records[uid] {
+			return response.UserResponse{}, errors.New("user not found")
+		}
+		return response.UserResponse{ID: uid, AccessToken: "access-" + uid}, nil
+	}
+	return middleware
+}
+
+func sessionCookie(t *testing.T, name, uid, email string) *http.Cookie {
+	t.Helper()
+	token, err := sessionJwtManager{}.Sign("client-secret", shared.SessionToken{
+		UID:   uid,
+		Email: email,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: name, Value: token}
+}
+
+func launchRequest(t *testing.T, userID string, cookies ...*http.Cookie) *http.Request {
+	t.Helper()
+	state := url.QueryEscape(fmt.Sprintf(`{"ids":[],"action":"open","userId":"%s"}`, userID))
+	req := httptest.NewRequest(http.MethodGet, "/api/editor?state="+state, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+// A launch by a second signed-in account whose refresh token is already
+// stored must switch the session to it without another consent round trip —
+// provided the browser proves the account with its keyed session cookie.
+func TestAccountSwitchWithStoredTokensSkipsConsent(t *testing.T) {
+	middleware := newAccountTestMiddleware(t, "user-a", "user-b")
+
+	var acting response.UserResponse
+	handler := middleware.Protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		acting = r.Context().Value(ContextUser).(response.UserResponse)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, launchRequest(
+		t, "user-b",
+		sessionCookie(t, shared.SessionCookie, "user-a", "a@example.com"),
+		sessionCookie(t, shared.AccountSessionCookie("user-b"), "user-b", "b@example.com"),
+	))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the request to pass through, got %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if acting.ID != "user-b" {
+		t.Fatalf("expected the request to run as the state user, got %q", acting.ID)
+	}
+
+	minted := map[string]string{}
+	for _, cookie := range rec.Result().Cookies() {
+		var session shared.SessionToken
+		if err := (sessionJwtManager{}).Verify("client-secret", cookie.Value, &session); err != nil {
+			t.Fatalf("could not verify minted cookie %s: %s", cookie.Name, err.Error())
+		}
+		minted[cookie.Name] = session.UID
+	}
+	if minted[shared.SessionCookie] != "user-b" {
+		t.Errorf("expected the active session to move to user-b, got %v", minted)
+	}
+	if minted[shared.AccountSessionCookie("user-b")] != "user-b" {
+		t.Errorf("expected a keyed session for user-b, got %v", minted)
+	}
+}
+
+// The state's userId is unsigned JSON anyone can put in a URL: naming
+// another connected account without that account's keyed cookie must never
+// mint a session for it, however real its stored tokens are.
+func TestAccountSwitchWithoutProofRedirectsToConsent(t *testing.T) {
+	middleware := newAccountTestMiddleware(t, "user-a", "user-b")
+
+	rec := httptest.NewRecorder()
+	middleware.Protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		t.Error("the handler must not run for an unproven account")
+	})).ServeHTTP(rec, launchRequest(
+		t, "user-b", sessionCookie(t, shared.SessionCookie, "user-a", "a@example.com"),
+	))
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected a consent redirect, got %d", rec.Code)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Path != "/oauth/install" {
+		t.Fatalf("expected the install path, got %q", location.Path)
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("expected no minted session for an unproven account, got %v", cookies)
+	}
+}
+
+// A keyed cookie minted for one account must not prove a different one: the
+// claims inside it, not its name, decide.
+func TestAccountSwitchRejectsMismatchedKeyedCookie(t *testing.T) {
+	middleware := newAccountTestMiddleware(t, "user-a", "user-b")
+
+	rec := httptest.NewRecorder()
+	middleware.Protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		t.Error("the handler must not run for a forged keyed cookie")
+	})).ServeHTTP(rec, launchRequest(
+		t, "user-b",
+		sessionCookie(t, shared.SessionCookie, "user-a", "a@example.com"),
+		sessionCookie(t, shared.AccountSessionCookie("user-b"), "user-a", "a@example.com"),
+	))
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected a consent redirect, got %d", rec.Code)
+	}
+}
+
+// Without stored tokens the switch falls back to consent, forcing the
+// account chooser and hinting the account a previous session recorded.
+func TestAccountSwitchWithoutTokensRedirectsToConsent(t *testing.T) {
+	middleware := newAccountTestMiddleware(t, "user-a")
+
+	rec := httptest.NewRecorder()
+	middleware.Protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		t.Error("the handler must not run for an unknown account")
+	})).ServeHTTP(rec, launchRequest(
+		t, "user-c",
+		sessionCookie(t, shared.SessionCookie, "user-a", "a@example.com"),
+		sessionCookie(t, shared.AccountSessionCookie("user-c"), "user-c", "c@example.com"),
+	))
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected a consent redirect, got %d", rec.Code)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Path != "/oauth/install" {
+		t.Fatalf("expected the install path, got %q", location.Path)
+	}
+	query := location.Query()
+	if query.Get("prompt") != "select_account" {
+		t.Errorf("expected the account chooser to be forced, got %q", query.Get("prompt"))
+	}
+	if query.Get("login_hint") != "c@example.com" {
+		t.Errorf("expected the keyed cookie's email as the hint, got %q", query.Get("login_hint"))
+	}
+	if query.Get("state") == "" {
+		t.Error("expected the launch state to survive the consent redirect")
+	}
+}
+
+// A launch by the cookie session's own account must not reissue cookies.
+func TestMatchingAccountLeavesTheSessionAlone(t *testing.T) {
+	middleware := newAccountTestMiddleware(t, "user-a")
+
+	rec := httptest.NewRecorder()
+	middleware.Protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(rec, launchRequest(
+			t, "user-a", sessionCookie(t, shared.SessionCookie, "user-a", "a@example.com"),
+		))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the request to pass through, got %d", rec.Code)
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("expected no reissued cookies, got %v", cookies)
+	}
+}
+
+// A stalled auth service must render the timeout page rather than bounce
+// the user to consent: their tokens are fine, the RPC was merely slow. The
+// helper's config trims the auth slice to a second so the stall is short.
+func TestAuthPhaseTimeoutRendersTheTimeoutPage(t *testing.T) {
+	middleware := newAccountTestMiddleware(t, "user-a")
+	middleware.lookupUser = func(ctx context.Context, uid string) (response.UserResponse, error) {
+		<-ctx.Done()
+		return response.UserResponse{}, ctx.Err()
+	}
+
+	rec := httptest.NewRecorder()
+	middleware.Protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		t.Error("the handler must not run when the auth phase times out")
+	})).ServeHTTP(rec, launchRequest(
+		t, "user-a", sessionCookie(t, shared.SessionCookie, "user-a", "a@example.com"),
+	))
+
+	if rec.Code == http.StatusSeeOther {
+		t.Fatalf("expected the timeout page, got a redirect to %q", rec.Header().Get("Location"))
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "Loading took too long") {
+		t.Errorf("expected the timeout page, got %q", body)
+	}
+}
+
+// expiringSessionCookie mints a session cookie with pinned expiry claims,
+// so the renewal tests can place it precisely around the threshold.
+func expiringSessionCookie(t *testing.T, uid string, expires, absolute time.Time) *http.Cookie {
+	t.Helper()
+	token, err := sessionJwtManager{}.Sign("client-secret", shared.SessionToken{
+		UID:               uid,
+		AbsoluteExpiresAt: jwt.NewNumericDate(absolute),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: shared.SessionCookie, Value: token}
+}
+
+// mintedSession verifies the re-issued active session cookie and returns
+// its claims; it fails the test when no active cookie was set.
+func mintedSession(t *testing.T, rec *httptest.ResponseRecorder) shared.SessionToken {
+	t.Helper()
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name != shared.SessionCookie {
+			continue
+		}
+		var session shared.SessionToken
+		if err := (sessionJwtManager{}).Verify("client-secret", cookie.Value, &session); err != nil {
+			t.Fatal(err)
+		}
+		return session
+	}
+	t.Fatal("expected a renewed active session cookie")
+	return shared.SessionToken{}
+}
+
+// A session is renewed only once less than the configured threshold of its
+// lifetime remains — not on every request — and the renewal grants a full
+// lifetime while keeping the original absolute expiry.
+func TestSessionRenewsOnlyNearExpiry(t *testing.T) {
+	middleware := newAccountTestMiddleware(t, "user-a")
+	now := time.Unix(1700000000, 0)
+	middleware.clock = shared.FixedClock{Instant: now}
+	absolute := now.Add(30 * 24 * time.Hour)
+	pass := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+	// Two days out is past the default one-day threshold: no new cookies.
+	rec := httptest.NewRecorder()
+	middleware.Protect(pass).ServeHTTP(rec, launchRequest(
+		t, "user-a", expiringSessionCookie(t, "user-a", now.Add(48*time.Hour), absolute),
+	))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the request to pass through, got %d", rec.Code)
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Fatalf("expected no renewal far from expiry, got %v", cookies)
+	}
+
+	// One hour out is within the threshold: the cookie slides forward by
+	// the full configured lifetime.
+	rec = httptest.NewRecorder()
+	middleware.Protect(pass).ServeHTTP(rec, launchRequest(
+		t, "user-a", expiringSessionCookie(t, "user-a", now.Add(time.Hour), absolute),
+	))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the request to pass through, got %d", rec.Code)
+	}
+	session := mintedSession(t, rec)
+	if !session.ExpiresAt.Time.Equal(now.Add(7 * 24 * time.Hour)) {
+		t.Errorf("expected a full lifetime from the renewal, got expiry %s", session.ExpiresAt.Time)
+	}
+	if !session.AbsoluteExpiresAt.Time.Equal(absolute) {
+		t.Errorf("expected the absolute expiry to survive renewal, got %s", session.AbsoluteExpiresAt.Time)
+	}
+}
+
+// Renewal never pushes a session past its absolute expiry, and once that
+// instant passes, a still-verifiable token is turned away to re-consent.
+func TestAbsoluteExpiryBoundsSlidingSessions(t *testing.T) {
+	middleware := newAccountTestMiddleware(t, "user-a")
+	now := time.Unix(1700000000, 0)
+	middleware.clock = shared.FixedClock{Instant: now}
+	pass := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+	// The absolute expiry is closer than a full lifetime: the renewed
+	// cookie is clipped to it.
+	rec := httptest.NewRecorder()
+	middleware.Protect(pass).ServeHTTP(rec, launchRequest(
+		t, "user-a", expiringSessionCookie(t, "user-a", now.Add(time.Hour), now.Add(3*time.Hour)),
+	))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the request to pass through, got %d", rec.Code)
+	}
+	if session := mintedSession(t, rec); !session.ExpiresAt.Time.Equal(now.Add(3 * time.Hour)) {
+		t.Errorf("expected the renewal to clip at the absolute expiry, got %s", session.ExpiresAt.Time)
+	}
+
+	// Past the absolute expiry the token still verifies, but the session
+	// is over: the request bounces to consent.
+	middleware.clock = shared.FixedClock{Instant: now.Add(4 * time.Hour)}
+	rec = httptest.NewRecorder()
+	middleware.Protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		t.Error("the handler must not run past the absolute expiry")
+	})).ServeHTTP(rec, launchRequest(
+		t, "user-a", expiringSessionCookie(t, "user-a", now.Add(5*time.Hour), now.Add(3*time.Hour)),
+	))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected a consent redirect, got %d", rec.Code)
+	}
+	if location := rec.Header().Get("Location"); location != "/oauth/install" {
+		t.Errorf("expected the install path, got %q", location)
+	}
+}
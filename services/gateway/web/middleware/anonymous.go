@@ -0,0 +1,193 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package middleware
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/golang-jwt/jwt/v4"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/option"
+)
+
+// anonymousUserPrefix marks the generated ids anonymous viewers act under
+// in download tokens and the sessions registry.
+const anonymousUserPrefix = "anonymous-"
+
+// anonymousOrConsent is where a request without an establishable session
+// lands. A launch pointing at a file that turns out to be link-shared gets
+// the anonymous view-only editor; everything else goes through consent as
+// before.
+func (m SessionMiddleware) anonymousOrConsent(rw http.ResponseWriter, r *http.Request) {
+	state, _ := m.decodeLaunchState(r)
+	if m.serveAnonymousView(rw, r, state) {
+		return
+	}
+	http.Redirect(rw, r, "/oauth/install", http.StatusSeeOther)
+}
+
+// getPublicFile fetches a file's metadata through the key-only Drive
+// client. It succeeds exactly for files shared "anyone with the link can
+// view", which is the whole access check: Drive answers 404 for anything
+// the key alone may not see.
+func (m SessionMiddleware) getPublicFile(ctx context.Context, fileID string) (*drive.File, error) {
+	srv, err := drive.NewService(ctx, option.WithAPIKey(m.credentials.Credentials.APIKey))
+	if err != nil {
+		return nil, err
+	}
+	return srv.Files.Get(fileID).Do()
+}
+
+// serveAnonymousView renders a strictly view-only editor for a launched
+// file when the key-only client can see it, reporting whether it handled
+// the request. Every bail-out returns false so the caller falls back to
+// the consent redirect the viewer would have seen anyway.
+func (m SessionMiddleware) serveAnonymousView(rw http.ResponseWriter, r *http.Request, state request.DriveState) bool {
+	if m.credentials.Credentials.APIKey == "" || len(state.IDS) == 0 {
+		return false
+	}
+	// Only the editor launch has an anonymous shape; creating, converting
+	// and browsing all act on a Drive the viewer does not have.
+	if !strings.HasSuffix(r.URL.Path, "/editor") {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	file, err := m.fetchPublicFile(ctx, state.IDS[0])
+	if err != nil {
+		m.logger.Debugf("file %s is not publicly readable, falling back to consent: %s", state.IDS[0], err.Error())
+		return false
+	}
+
+	// Folders and trashed files have authenticated flows of their own;
+	// Google-native documents export only through OAuth, so the key-only
+	// download proxy could never feed them to the Document Server.
+	if file.MimeType == shared.GoogleFolderMime || shared.IsTrashed(file) ||
+		shared.IsGoogleNative(file.MimeType) {
+		return false
+	}
+	// The owner disabled download for readers; an anonymous viewer is the
+	// reader that restriction is about.
+	if file.CopyRequiresWriterPermission {
+		return false
+	}
+
+	fileType, err := m.fileUtility.GetFileType(file.FileExtension)
+	if err != nil {
+		return false
+	}
+	limits := m.onlyoffice.Config().Onlyoffice.Limits
+	if limits.ExceedsOpenSizeLimit(fileType, file.FileExtension, file.FileSize) {
+		return false
+	}
+
+	config, uid, err := m.anonymousConfig(file, fileType)
+	if err != nil {
+		m.logger.Errorf("could not build an anonymous config for %s: %s", file.Id, err.Error())
+		return false
+	}
+
+	// Anonymous views open no editor session; the recorded issuance is what
+	// lets the Document Server fetch the content through the download proxy.
+	if m.sessions != nil {
+		m.sessions.NoteIssued(uid, file.Id)
+	}
+
+	m.logger.Infof("serving file %s to an anonymous viewer", file.Id)
+	embeddable.EditorPage.Execute(rw, map[string]interface{}{
+		"apijs":  fmt.Sprintf("%s/web-apps/apps/api/documents/api.js", m.onlyoffice.Config().Onlyoffice.Builder.DocumentServerURL),
+		"config": string(config.ToJSON()),
+	})
+	return true
+}
+
+// anonymousConfig builds the view-only editor config for a link-shared
+// file: every permission including download stays denied, no callback URL
+// ever saves, and the generated viewer id keeps the document key away from
+// the authenticated co-editing session.
+func (m SessionMiddleware) anonymousConfig(file *drive.File, fileType string) (response.BuildConfigResponse, string, error) {
+	suffix := m.ids.NewID()[:8]
+	uid := anonymousUserPrefix + suffix
+
+	downloadToken, err := m.jwtManager.Sign(m.credentials.Credentials.ClientSecret, shared.DownloadToken{
+		UID:    uid,
+		FileID: file.Id,
+		Public: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(m.clock.Now()),
+			ExpiresAt: jwt.NewNumericDate(m.clock.Now().Add(4 * time.Minute)),
+		},
+	})
+	if err != nil {
+		return response.BuildConfigResponse{}, "", err
+	}
+
+	keySource := md5.Sum([]byte(file.Id + file.ModifiedDate + ":" + uid))
+	config := response.BuildConfigResponse{
+		Document: response.Document{
+			FileType: file.FileExtension,
+			Key:      hex.EncodeToString(keySource[:]),
+			Title:    file.Title,
+			URL: fmt.Sprintf(
+				"%s/api/download?token=%s",
+				m.onlyoffice.Config().Onlyoffice.Builder.GatewayURL, url.QueryEscape(downloadToken),
+			),
+			Permissions: response.Permissions{},
+		},
+		DocumentType: fileType,
+		EditorConfig: response.EditorConfig{
+			User: response.User{
+				ID:   uid,
+				Name: fmt.Sprintf("Anonymous (%s)", suffix[:4]),
+			},
+			Lang: "en",
+			Mode: "view",
+		},
+		Type:      "embedded",
+		ServerURL: m.onlyoffice.Config().Onlyoffice.Builder.DocumentServerURL,
+	}
+
+	if secret := m.onlyoffice.Config().Onlyoffice.Builder.DocumentServerSecret; secret != "" {
+		var claims jwt.MapClaims
+		if err := json.Unmarshal(config.ToJSON(), &claims); err != nil {
+			return response.BuildConfigResponse{}, "", err
+		}
+		token, err := m.jwtManager.Sign(secret, claims)
+		if err != nil {
+			return response.BuildConfigResponse{}, "", err
+		}
+		config.Token = token
+	}
+
+	return config, uid, nil
+}
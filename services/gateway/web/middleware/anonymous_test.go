@@ -0,0 +1,198 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	drive "google.golang.org/api/drive/v2"
+)
+
+// newAnonymousTestMiddleware wires a middleware whose key-only metadata
+// lookups answer from the given stub instead of Google.
+func newAnonymousTestMiddleware(
+	t *testing.T, fetch func(ctx context.Context, fileID string) (*drive.File, error),
+) SessionMiddleware {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := &shared.CredentialsConfig{}
+	credentials.Credentials.ClientSecret = "client-secret"
+	credentials.Credentials.APIKey = "api-key"
+
+	middleware := SessionMiddleware{
+		jwtManager:  sessionJwtManager{},
+		fileUtility: files.NewOnlyofficeFileUtility(),
+		credentials: credentials,
+		onlyoffice:  source,
+		sessions:    sessions.NewRegistry(time.Minute),
+		states:      NewStateStore(time.Minute),
+		logger:      accountTestLogger{},
+		clock:       shared.FixedClock{Instant: time.Unix(1700000000, 0)},
+		ids:         &shared.SequenceIDs{},
+	}
+	middleware.fetchPublicFile = fetch
+	return middleware
+}
+
+func anonymousLaunchRequest(t *testing.T, fileID string) *http.Request {
+	t.Helper()
+	state := url.QueryEscape(fmt.Sprintf(`{"ids":[%q],"action":"open"}`, fileID))
+	return httptest.NewRequest(http.MethodGet, "/api/editor?state="+state, nil)
+}
+
+// A launch without any session pointing at a link-shared file must render
+// the editor page instead of bouncing the viewer through consent.
+func TestAnonymousViewServesPublicFiles(t *testing.T) {
+	middleware := newAnonymousTestMiddleware(t, func(ctx context.Context, fileID string) (*drive.File, error) {
+		return &drive.File{
+			Id:            fileID,
+			Title:         "notes.docx",
+			FileExtension: "docx",
+			MimeType:      "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		}, nil
+	})
+
+	rec := httptest.NewRecorder()
+	middleware.Protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		t.Error("the protected handler must not run for an anonymous viewer")
+	})).ServeHTTP(rec, anonymousLaunchRequest(t, "file-id-0123456789"))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the editor page, got %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "DocsAPI") {
+		t.Errorf("expected a rendered editor page, got %q", body)
+	}
+}
+
+// Anything the key-only client cannot serve — a private file, an owner
+// restriction, a Google-native document — falls back to the consent
+// redirect the viewer would have seen before.
+func TestAnonymousViewFallsBackToConsent(t *testing.T) {
+	for name, fetch := range map[string]func(ctx context.Context, fileID string) (*drive.File, error){
+		"not shared": func(ctx context.Context, fileID string) (*drive.File, error) {
+			return nil, errors.New("googleapi: Error 404: File not found")
+		},
+		"download restricted": func(ctx context.Context, fileID string) (*drive.File, error) {
+			return &drive.File{
+				Id:                           fileID,
+				FileExtension:                "docx",
+				CopyRequiresWriterPermission: true,
+			}, nil
+		},
+		"google native": func(ctx context.Context, fileID string) (*drive.File, error) {
+			return &drive.File{
+				Id:       fileID,
+				MimeType: "application/vnd.google-apps.document",
+			}, nil
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			middleware := newAnonymousTestMiddleware(t, fetch)
+
+			rec := httptest.NewRecorder()
+			middleware.Protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				t.Error("the protected handler must not run without a session")
+			})).ServeHTTP(rec, anonymousLaunchRequest(t, "file-id-0123456789"))
+
+			if rec.Code != http.StatusSeeOther {
+				t.Fatalf("expected a consent redirect, got %d", rec.Code)
+			}
+			if location := rec.Header().Get("Location"); location != "/oauth/install" {
+				t.Fatalf("expected the install path, got %q", location)
+			}
+		})
+	}
+}
+
+// The anonymous config must never grow beyond viewing: no permissions, no
+// callback URL to save through, and a generated identity whose public
+// download token the proxy can tell apart from an authenticated one.
+func TestAnonymousConfigIsStrictlyViewOnly(t *testing.T) {
+	middleware := newAnonymousTestMiddleware(t, nil)
+
+	config, uid, err := middleware.anonymousConfig(&drive.File{
+		Id:            "file-id-0123456789",
+		Title:         "notes.docx",
+		FileExtension: "docx",
+		ModifiedDate:  "2023-01-01T00:00:00.000Z",
+	}, "word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.EditorConfig.Mode != "view" || config.Type != "embedded" {
+		t.Errorf("expected an embedded view config, got mode %q type %q", config.EditorConfig.Mode, config.Type)
+	}
+	if config.Document.Permissions.Download || config.Document.Permissions.Edit {
+		t.Error("expected every permission including download to stay denied")
+	}
+	if config.EditorConfig.CallbackURL != "" {
+		t.Errorf("an anonymous view must not carry a callback url, got %q", config.EditorConfig.CallbackURL)
+	}
+	if !strings.HasPrefix(uid, anonymousUserPrefix) {
+		t.Errorf("expected a generated anonymous id, got %q", uid)
+	}
+	if !strings.HasPrefix(config.EditorConfig.User.Name, "Anonymous") {
+		t.Errorf("expected a generated display name, got %q", config.EditorConfig.User.Name)
+	}
+
+	rawToken := strings.TrimPrefix(
+		config.Document.URL, "https://gateway.example.com/api/download?token=",
+	)
+	unescaped, err := url.QueryUnescape(rawToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var token shared.DownloadToken
+	if err := (sessionJwtManager{}).Verify("client-secret", unescaped, &token); err != nil {
+		t.Fatalf("could not verify the embedded download token: %s", err.Error())
+	}
+	if !token.Public || token.UID != uid {
+		t.Errorf("expected a public token for %s, got %+v", uid, token)
+	}
+}
@@ -0,0 +1,112 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"google.golang.org/api/googleapi"
+)
+
+// sessionErrorKind classifies why the session fan-out failed so each class
+// gets a page the user can actually act on instead of one generic error.
+type sessionErrorKind int
+
+const (
+	sessionErrorNone sessionErrorKind = iota
+	// sessionErrorNotFound: the launched file no longer exists or was never
+	// shared with this account.
+	sessionErrorNotFound
+	// sessionErrorPermission: the token was revoked or lacks scopes; the fix
+	// is to run the consent flow again.
+	sessionErrorPermission
+	// sessionErrorTimeout: a phase ran past its deadline; the page says so
+	// instead of hiding the slowness behind a generic failure.
+	sessionErrorTimeout
+	// sessionErrorTransient: network trouble or a Google outage; a reload is
+	// the best advice.
+	sessionErrorTransient
+)
+
+// classifySessionError maps the fan-out results onto a single error class.
+// Permission problems win over a missing file: a revoked token makes Drive
+// report files as not found, and re-consent fixes both. A missing file in
+// turn wins over a deadline on the other call, since it already determines
+// what the user has to do.
+func classifySessionError(uinfoErr, fileErr error) sessionErrorKind {
+	if uinfoErr == nil && fileErr == nil {
+		return sessionErrorNone
+	}
+
+	kind := sessionErrorTransient
+	for _, err := range []error{fileErr, uinfoErr} {
+		var gerr *googleapi.Error
+		if !errors.As(err, &gerr) {
+			if errors.Is(err, context.DeadlineExceeded) && kind == sessionErrorTransient {
+				kind = sessionErrorTimeout
+			}
+			continue
+		}
+		switch gerr.Code {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return sessionErrorPermission
+		case http.StatusNotFound:
+			kind = sessionErrorNotFound
+		}
+	}
+
+	return kind
+}
+
+// renderSessionError writes the page matching the error class. Permission
+// failures restart the consent flow; the Drive launch state survives the
+// round trip through the install handler's redirect back.
+func (m SessionMiddleware) renderSessionError(rw http.ResponseWriter, r *http.Request, kind sessionErrorKind) {
+	switch kind {
+	case sessionErrorNotFound:
+		embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+			"errorMain":     "File not found",
+			"errorSubtext":  "The file may have been deleted or you may have lost access to it",
+			"errorLink":     "https://drive.google.com/",
+			"errorLinkText": "Open Google Drive",
+		})
+	case sessionErrorPermission:
+		http.Redirect(rw, r, "/oauth/install", http.StatusSeeOther)
+	case sessionErrorTimeout:
+		// Rendered as a 200 like the transient page, with the same
+		// dependency annotation for the error counter.
+		shared.ClassifyError(rw, shared.ErrorCategoryDependency)
+		embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+			"errorMain":    "Loading took too long",
+			"errorSubtext": "Google did not answer in time. Please reload the page",
+		})
+	default:
+		// The transient page renders as a 200; the annotation keeps Google
+		// outages visible to the error counter anyway.
+		shared.ClassifyError(rw, shared.ErrorCategoryDependency)
+		embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+			"errorMain":    "Something went wrong",
+			"errorSubtext": "Please reload the page",
+		})
+	}
+}
@@ -0,0 +1,144 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestClassifySessionError(t *testing.T) {
+	for name, tc := range map[string]struct {
+		uinfoErr error
+		fileErr  error
+		kind     sessionErrorKind
+	}{
+		"no errors": {
+			kind: sessionErrorNone,
+		},
+		"deleted file": {
+			fileErr: &googleapi.Error{Code: http.StatusNotFound},
+			kind:    sessionErrorNotFound,
+		},
+		"revoked token": {
+			uinfoErr: &googleapi.Error{Code: http.StatusUnauthorized},
+			kind:     sessionErrorPermission,
+		},
+		"missing scope": {
+			fileErr: &googleapi.Error{Code: http.StatusForbidden},
+			kind:    sessionErrorPermission,
+		},
+		"network failure": {
+			uinfoErr: errors.New("dial tcp: i/o timeout"),
+			kind:     sessionErrorTransient,
+		},
+		// Both goroutines failing must not drop either error: the revoked
+		// token explains the phantom 404, so re-consent wins.
+		"revoked token masquerading as a missing file": {
+			uinfoErr: &googleapi.Error{Code: http.StatusUnauthorized},
+			fileErr:  &googleapi.Error{Code: http.StatusNotFound},
+			kind:     sessionErrorPermission,
+		},
+		"missing file next to a network hiccup": {
+			uinfoErr: errors.New("dial tcp: i/o timeout"),
+			fileErr:  &googleapi.Error{Code: http.StatusNotFound},
+			kind:     sessionErrorNotFound,
+		},
+		"slow userinfo": {
+			uinfoErr: fmt.Errorf("Get \"https://www.googleapis.com/oauth2/v2/userinfo\": %w", context.DeadlineExceeded),
+			kind:     sessionErrorTimeout,
+		},
+		"slow metadata next to a network hiccup": {
+			uinfoErr: errors.New("dial tcp: connection refused"),
+			fileErr:  context.DeadlineExceeded,
+			kind:     sessionErrorTimeout,
+		},
+		// A definitive answer on one call outranks a deadline on the other:
+		// the user's next step is the same however slow the second call was.
+		"missing file next to a slow userinfo": {
+			uinfoErr: context.DeadlineExceeded,
+			fileErr:  &googleapi.Error{Code: http.StatusNotFound},
+			kind:     sessionErrorNotFound,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if kind := classifySessionError(tc.uinfoErr, tc.fileErr); kind != tc.kind {
+				t.Errorf("expected kind %d, got %d", tc.kind, kind)
+			}
+		})
+	}
+}
+
+func TestRenderSessionError(t *testing.T) {
+	var m SessionMiddleware
+
+	t.Run("not found links to Drive", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		m.renderSessionError(rec, httptest.NewRequest("GET", "/api/editor", nil), sessionErrorNotFound)
+		body := rec.Body.String()
+		if !strings.Contains(body, "File not found") {
+			t.Errorf("expected the not-found page, got %q", body)
+		}
+		if !strings.Contains(body, "https://drive.google.com/") {
+			t.Errorf("expected a Drive link, got %q", body)
+		}
+	})
+
+	t.Run("permission restarts the consent flow", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		m.renderSessionError(rec, httptest.NewRequest("GET", "/api/editor", nil), sessionErrorPermission)
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected a redirect, got %d", rec.Code)
+		}
+		if location := rec.Header().Get("Location"); location != "/oauth/install" {
+			t.Errorf("expected the install redirect, got %q", location)
+		}
+	})
+
+	t.Run("timeout names the slowness", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		m.renderSessionError(rec, httptest.NewRequest("GET", "/api/editor", nil), sessionErrorTimeout)
+		body := rec.Body.String()
+		if !strings.Contains(body, "Loading took too long") {
+			t.Errorf("expected the timeout page, got %q", body)
+		}
+		if !strings.Contains(body, "Please reload the page") {
+			t.Errorf("expected a reload suggestion, got %q", body)
+		}
+	})
+
+	t.Run("transient suggests a reload", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		m.renderSessionError(rec, httptest.NewRequest("GET", "/api/editor", nil), sessionErrorTransient)
+		body := rec.Body.String()
+		if !strings.Contains(body, "Please reload the page") {
+			t.Errorf("expected the retry page, got %q", body)
+		}
+		if strings.Contains(body, "drive.google.com") {
+			t.Errorf("expected no Drive link on the retry page, got %q", body)
+		}
+	})
+}
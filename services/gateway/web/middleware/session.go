@@ -0,0 +1,482 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/cache"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"go-micro.dev/v4/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v2"
+	goauth "google.golang.org/api/oauth2/v2"
+	"google.golang.org/api/option"
+)
+
+type contextKey string
+
+const (
+	// ContextUser carries the resolved auth-service user through the
+	// request context.
+	ContextUser contextKey = "user"
+	// ContextUserinfo carries the Google userinfo profile.
+	ContextUserinfo contextKey = "userinfo"
+	// ContextFile carries the Drive metadata of the first state file.
+	ContextFile contextKey = "file"
+	// ContextState carries the decoded Drive launch state.
+	ContextState contextKey = "state"
+)
+
+// SessionMiddleware resolves the auth cookie into user credentials, the
+// Google profile and the launched file's metadata before protected handlers
+// run.
+type SessionMiddleware struct {
+	jwtManager  crypto.JwtManager
+	client      client.Client
+	fileUtility files.OnlyofficeFileUtility
+	server      *config.ServerConfig
+	credentials *shared.CredentialsConfig
+	onlyoffice  *shared.OnlyofficeConfigSource
+	sessions    *sessions.Registry
+	identity    *cache.IdentityCache
+	states      *StateStore
+	codec       *sessions.CookieCodec
+	logger      log.Logger
+	// clock and ids feed re-issued session tokens and anonymous viewer
+	// identities; injected so tests can pin the resulting artifacts.
+	clock shared.Clock
+	ids   shared.IDGenerator
+
+	// lookupUser resolves stored credentials via the auth service; injected
+	// so tests can exercise the account-switch flow without an RPC client.
+	lookupUser func(ctx context.Context, uid string) (response.UserResponse, error)
+	// fetchPublicFile resolves a file's metadata through the key-only Drive
+	// client; injected so tests can exercise the anonymous view path without
+	// Google behind it.
+	fetchPublicFile func(ctx context.Context, fileID string) (*drive.File, error)
+}
+
+func NewSessionMiddleware(
+	jwtManager crypto.JwtManager, client client.Client,
+	fileUtility files.OnlyofficeFileUtility,
+	server *config.ServerConfig, credentials *shared.CredentialsConfig,
+	onlyoffice *shared.OnlyofficeConfigSource, registry *sessions.Registry,
+	identity *cache.IdentityCache, states *StateStore,
+	codec *sessions.CookieCodec, logger log.Logger,
+) SessionMiddleware {
+	middleware := SessionMiddleware{
+		jwtManager:  jwtManager,
+		client:      client,
+		fileUtility: fileUtility,
+		server:      server,
+		credentials: credentials,
+		onlyoffice:  onlyoffice,
+		sessions:    registry,
+		identity:    identity,
+		states:      states,
+		codec:       codec,
+		logger:      logger,
+		clock:       shared.SystemClock{},
+		ids:         shared.UUIDGenerator{},
+	}
+	middleware.lookupUser = middleware.getUser
+	middleware.fetchPublicFile = middleware.getPublicFile
+	return middleware
+}
+
+func (m SessionMiddleware) getUser(ctx context.Context, uid string) (response.UserResponse, error) {
+	var ures response.UserResponse
+	err := m.client.Call(ctx, m.client.NewRequest(
+		fmt.Sprintf("%s:auth", m.server.Namespace), "UserSelectHandler.GetUser",
+		uid,
+	), &ures)
+	return ures, err
+}
+
+// folderCreateURL builds the create-page URL opened when a launch state
+// points at a folder rather than a file. The folder's resource key, when
+// present, travels along so creation inside link-shared folders works.
+func folderCreateURL(states *StateStore, folderID, resourceKey, userID string) string {
+	state := request.DriveState{
+		Action:            "create",
+		UserID:            userID,
+		FolderID:          folderID,
+		FolderResourceKey: resourceKey,
+	}
+	return states.StateURL("/api/create", state)
+}
+
+// normalizeState maps the launch shapes Drive actually sends onto one
+// canonical form: the Drive UI sends action "create" with a folder and no
+// ids, while our own pages historically used "new" for the same flow.
+func normalizeState(state request.DriveState) request.DriveState {
+	if state.Action == "new" {
+		state.Action = "create"
+	}
+	return state
+}
+
+// decodeLaunchState resolves the request's Drive launch state, whether it
+// traveled inline or as a server-side handle. Oversized states travel as a
+// handle; resolving one consumes it, so a truncated or replayed sid simply
+// yields an empty state.
+func (m SessionMiddleware) decodeLaunchState(r *http.Request) (request.DriveState, string) {
+	var state request.DriveState
+	raw := r.URL.Query().Get("state")
+	if sid := r.URL.Query().Get("sid"); raw == "" && sid != "" {
+		if stored, ok := m.states.Take(sid); ok {
+			raw = stored
+		} else {
+			m.logger.Debugf("state handle %s is unknown or expired", sid)
+		}
+	}
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &state); err != nil {
+			m.logger.Debugf("could not unmarshal drive state: %s", err.Error())
+		}
+	}
+	return normalizeState(state), raw
+}
+
+// HasSession reports whether the request carries a verifiable session
+// cookie. It checks only the cookie itself — no auth-service lookup — so
+// cheap call sites like the not-found handler can tell a signed-in browser
+// from a stranger without an RPC.
+func (m SessionMiddleware) HasSession(r *http.Request) bool {
+	cookie, err := r.Cookie(shared.SessionCookie)
+	if err != nil {
+		return false
+	}
+	token, ok := m.codec.Decode(cookie.Value)
+	if !ok {
+		return false
+	}
+	var session shared.SessionToken
+	return m.jwtManager.Verify(m.credentials.Credentials.ClientSecret, token, &session) == nil
+}
+
+// Protect verifies the session cookie, resolves user credentials via the
+// auth service and fans out to load the Google userinfo profile and, when a
+// state is present, the first file's Drive metadata.
+func (m SessionMiddleware) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(shared.SessionCookie)
+		if err != nil {
+			m.anonymousOrConsent(rw, r)
+			return
+		}
+
+		// A server-side session fails to decode when its payload expired or
+		// the gateway restarted; re-consent is the only way back in.
+		token, ok := m.codec.Decode(cookie.Value)
+		if !ok {
+			m.logger.Debugf("session cookie references an unknown or expired server-side payload")
+			m.anonymousOrConsent(rw, r)
+			return
+		}
+
+		var session shared.SessionToken
+		if err := m.jwtManager.Verify(m.credentials.Credentials.ClientSecret, token, &session); err != nil {
+			m.logger.Debugf("could not verify session cookie: %s", err.Error())
+			m.anonymousOrConsent(rw, r)
+			return
+		}
+
+		// The exp claim slides forward on renewal; the absolute expiry does
+		// not, so a session past the configured total age is turned away
+		// here even though its token still verifies.
+		if session.AbsoluteExpiresAt != nil && !m.clock.Now().Before(session.AbsoluteExpiresAt.Time) {
+			m.logger.Debugf("session of user %s passed its absolute expiry", session.UID)
+			m.anonymousOrConsent(rw, r)
+			return
+		}
+
+		state, raw := m.decodeLaunchState(r)
+
+		// Drive reports which of the browser's signed-in accounts launched
+		// the app. When it is not the account behind the cookie, the request
+		// runs as the launching account: its stored tokens, when present, are
+		// all a new session needs, so multi-account users are not bounced
+		// through consent just for switching accounts.
+		uid := session.UID
+		switched := state.UserID != "" && state.UserID != session.UID
+		if switched {
+			// The launch state is unsigned, caller-controlled JSON: a bare
+			// account id in it must never become a session. The switch is
+			// honored only when the browser holds the account's keyed session
+			// cookie — proof it completed consent as that account before;
+			// anyone else goes through the account chooser.
+			if !m.accountProven(r, state.UserID) {
+				m.logger.Debugf(
+					"state user %s is not proven by a keyed session cookie; redirecting through consent",
+					state.UserID,
+				)
+				http.Redirect(rw, r, m.accountSwitchURL(r, state, raw), http.StatusSeeOther)
+				return
+			}
+			uid = state.UserID
+		}
+
+		// One budget covers everything this middleware does before the
+		// handler runs; the auth RPC gets its own smaller slice so a stuck
+		// auth service cannot eat the whole budget before the Google calls
+		// even start.
+		gateway := m.onlyoffice.Config().Onlyoffice.Gateway
+		pctx, pcancel := context.WithTimeout(r.Context(), gateway.PageBudgetDuration())
+		defer pcancel()
+
+		actx, acancel := context.WithTimeout(pctx, gateway.AuthTimeoutDuration())
+		ures, err := m.lookupUser(actx, uid)
+		acancel()
+		if err != nil {
+			if switched {
+				m.logger.Debugf(
+					"user %s does not match state user %s and no tokens are stored for the latter: %s",
+					session.UID, state.UserID, err.Error(),
+				)
+				http.Redirect(rw, r, m.accountSwitchURL(r, state, raw), http.StatusSeeOther)
+				return
+			}
+			// A slow auth service is not a missing user; re-consent would not
+			// help, so the timeout page asks for a reload instead.
+			if errors.Is(err, context.DeadlineExceeded) {
+				m.logger.Errorf("auth service did not answer for user %s within %s", uid, gateway.AuthTimeoutDuration())
+				m.renderSessionError(rw, r, sessionErrorTimeout)
+				return
+			}
+			m.logger.Errorf("could not get user %s access info: %s", uid, err.Error())
+			http.Redirect(rw, r, "/oauth/install", http.StatusSeeOther)
+			return
+		}
+
+		if switched {
+			if err := m.switchSession(rw, r, uid); err != nil {
+				m.logger.Errorf("could not mint a session for state user %s: %s", uid, err.Error())
+				http.Redirect(rw, r, "/oauth/install", http.StatusSeeOther)
+				return
+			}
+			m.logger.Debugf("switched the session from user %s to state user %s", session.UID, uid)
+		} else {
+			// A session close to its expiry slides forward; a switch just
+			// minted a fresh cookie and needs no renewal.
+			m.renewSession(rw, session)
+		}
+
+		// Drive's "New → ONLYOFFICE" entry point carries a folder and no
+		// file ids. Route it to the create page no matter which protected
+		// endpoint received the launch instead of failing on the missing id.
+		if state.Action == "create" && len(state.IDS) == 0 &&
+			state.FolderID != "" && !strings.HasSuffix(r.URL.Path, "/create") {
+			http.Redirect(rw, r, folderCreateURL(
+				m.states, state.FolderID, state.FolderResourceKey, state.UserID,
+			), http.StatusSeeOther)
+			return
+		}
+
+		gclient := (&oauth2.Config{
+			ClientID:     m.credentials.Credentials.ClientID,
+			ClientSecret: m.credentials.Credentials.ClientSecret,
+			Endpoint:     google.Endpoint,
+		}).Client(pctx, &oauth2.Token{
+			AccessToken:  ures.AccessToken,
+			RefreshToken: ures.RefreshToken,
+			TokenType:    ures.TokenType,
+		})
+
+		// Each goroutine writes only its own result pair, so both failures
+		// survive until after wg.Wait and can be classified; nothing is
+		// read from a channel that might never be written.
+		var (
+			wg       sync.WaitGroup
+			uinfo    *goauth.Userinfo
+			uinfoErr error
+			file     *drive.File
+			fileErr  error
+		)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			uctx, ucancel := context.WithTimeout(pctx, gateway.UserinfoTimeoutDuration())
+			defer ucancel()
+			// Every protected page needs the profile; the identity cache
+			// keeps that to one Userinfo call per user per TTL across all
+			// handlers.
+			value, err := m.identity.Get(
+				fmt.Sprintf("userinfo:%s", ures.ID),
+				func() (interface{}, error) {
+					usvc, err := goauth.NewService(uctx, option.WithHTTPClient(gclient))
+					if err != nil {
+						return nil, err
+					}
+					return usvc.Userinfo.Get().Context(uctx).Do()
+				},
+			)
+			if err != nil {
+				uinfoErr = err
+				return
+			}
+			uinfo = value.(*goauth.Userinfo)
+		}()
+
+		if len(state.IDS) > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fctx, fcancel := context.WithTimeout(pctx, gateway.MetadataTimeoutDuration())
+				defer fcancel()
+				fsvc, err := drive.NewService(fctx, option.WithHTTPClient(gclient))
+				if err != nil {
+					fileErr = err
+					return
+				}
+				file, fileErr = fsvc.Files.Get(state.IDS[0]).Context(fctx).Do()
+				if fileErr != nil {
+					return
+				}
+				// A launched shortcut carries no content of its own; the
+				// handlers must operate on its target instead.
+				file, fileErr = shared.ResolveShortcut(file, func(targetID, resourceKey string) (*drive.File, error) {
+					call := fsvc.Files.Get(targetID).Context(fctx)
+					if resourceKey != "" {
+						call.Header().Set(
+							shared.ResourceKeysHeader,
+							fmt.Sprintf("%s/%s", targetID, resourceKey),
+						)
+					}
+					return call.Do()
+				})
+			}()
+		}
+
+		wg.Wait()
+
+		if kind := classifySessionError(uinfoErr, fileErr); kind != sessionErrorNone {
+			m.logger.Errorf(
+				"could not populate session context: userinfo=%v file=%v",
+				uinfoErr, fileErr,
+			)
+			m.renderSessionError(rw, r, kind)
+			return
+		}
+
+		// A domain restriction added after a user signed in must also end
+		// their existing sessions, so the allowlist is re-checked on every
+		// protected request, not only at consent time.
+		allowed := m.onlyoffice.Config().Onlyoffice.Auth.AllowedDomains
+		if !shared.DomainAllowed(allowed, uinfo.Hd, uinfo.Email) {
+			m.logger.Warnf("user %s is outside the allowed domains", ures.ID)
+			main, subtext := embeddable.RestrictedDomainMessage(
+				uinfo.Locale, strings.Join(allowed, ", "),
+			)
+			rw.WriteHeader(http.StatusForbidden)
+			embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+				"errorMain":    main,
+				"errorSubtext": subtext,
+			})
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ContextUser, ures)
+		ctx = context.WithValue(ctx, ContextUserinfo, uinfo)
+		ctx = context.WithValue(ctx, ContextState, state)
+		if len(state.IDS) > 0 {
+			// API-created open-with states can point at a folder. Send the
+			// user to the create page with that folder preselected instead
+			// of failing a download later.
+			if file.MimeType == shared.GoogleFolderMime {
+				m.logger.Debugf("user %s launched the app with folder %s", ures.ID, file.Id)
+				http.Redirect(rw, r, folderCreateURL(
+					m.states, file.Id, state.FolderResourceKey, state.UserID,
+				), http.StatusSeeOther)
+				return
+			}
+
+			// A trashed document must not be silently edited; the page
+			// points at the Drive trash where the user can restore it.
+			if shared.IsTrashed(file) {
+				m.logger.Debugf("user %s launched the app with trashed file %s", ures.ID, file.Id)
+				main, subtext, linkText := embeddable.TrashedFileMessage(uinfo.Locale)
+				embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+					"errorMain":     main,
+					"errorSubtext":  subtext,
+					"errorLink":     "https://drive.google.com/drive/trash",
+					"errorLinkText": linkText,
+				})
+				return
+			}
+
+			// Oversized documents tie up the gateway and the Document
+			// Server, so they are rejected before a config is ever built.
+			// Google-native files report no size and always pass.
+			ext, mismatch := shared.EffectiveExtension(file.FileExtension, file.MimeType)
+			if mismatch {
+				m.logger.Warnf(
+					"file %s reports extension .%s but is Google-native (%s); treating it as .%s",
+					file.Id, file.FileExtension, file.MimeType, ext,
+				)
+			}
+			fileType, _ := m.fileUtility.GetFileType(ext)
+			limits := m.onlyoffice.Config().Onlyoffice.Limits
+			if limits.ExceedsOpenSizeLimit(fileType, ext, file.FileSize) {
+				embeddable.ErrorPage.Execute(rw, map[string]interface{}{
+					"errorMain": "File is too large to open",
+					"errorSubtext": fmt.Sprintf(
+						"Files of this type can be opened up to %s",
+						shared.HumanReadableSize(limits.OpenSizeLimit(fileType, ext)),
+					),
+				})
+				return
+			}
+
+			// Seat control: opening yet another document past the per-user
+			// cap renders the open-sessions page instead of the editor.
+			// Reopening a tracked document always passes.
+			limit := m.onlyoffice.Config().Onlyoffice.Sessions.MaxOpenDocuments
+			if limit > 0 && strings.HasSuffix(r.URL.Path, "/editor") &&
+				!m.sessions.CanOpen(ures.ID, file.Id, limit) {
+				embeddable.SessionsPage.Execute(rw, map[string]interface{}{
+					"limit":    limit,
+					"sessions": m.sessions.Active(ures.ID),
+				})
+				return
+			}
+
+			ctx = context.WithValue(ctx, ContextFile, file)
+		}
+
+		next.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
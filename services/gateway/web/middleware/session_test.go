@@ -0,0 +1,109 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package middleware
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+)
+
+func TestFolderCreateURL(t *testing.T) {
+	redirect := folderCreateURL(nil, "folder-id-0123456789", "0-abcDEF", "1000000000")
+	if !strings.HasPrefix(redirect, "/api/create?state=") {
+		t.Fatalf("unexpected redirect target: %s", redirect)
+	}
+
+	raw, err := url.QueryUnescape(strings.TrimPrefix(redirect, "/api/create?state="))
+	if err != nil {
+		t.Fatalf("could not unescape state: %s", err.Error())
+	}
+
+	var state request.DriveState
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		t.Fatalf("could not decode state: %s", err.Error())
+	}
+
+	if state.Action != "create" {
+		t.Errorf("expected a create action, got %q", state.Action)
+	}
+	if state.FolderID != "folder-id-0123456789" {
+		t.Errorf("expected the folder to be preselected, got %q", state.FolderID)
+	}
+	if state.FolderResourceKey != "0-abcDEF" {
+		t.Errorf("expected the resource key to travel along, got %q", state.FolderResourceKey)
+	}
+	if len(state.IDS) != 0 {
+		t.Errorf("the folder must not be passed as a file id, got %v", state.IDS)
+	}
+}
+
+// Drive launches the integration with three state shapes: opening a file,
+// "New → ONLYOFFICE" in a (possibly link-shared) folder, and the create
+// flow from our own pages. All three must decode and normalize cleanly.
+func TestNormalizeDriveStateShapes(t *testing.T) {
+	for name, tc := range map[string]struct {
+		raw         string
+		action      string
+		ids         int
+		folder      string
+		resourceKey string
+	}{
+		"open": {
+			raw:    `{"ids":["file-id-0123456789"],"action":"open","userId":"1000000000"}`,
+			action: "open",
+			ids:    1,
+		},
+		"drive create": {
+			raw:         `{"action":"create","folderId":"folder-id-0123456789","folderResourceKey":"0-abcDEF","userId":"1000000000"}`,
+			action:      "create",
+			folder:      "folder-id-0123456789",
+			resourceKey: "0-abcDEF",
+		},
+		"own ui new": {
+			raw:    `{"ids":[],"action":"new","folderId":"folder-id-0123456789","userId":"1000000000"}`,
+			action: "create",
+			folder: "folder-id-0123456789",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var state request.DriveState
+			if err := json.Unmarshal([]byte(tc.raw), &state); err != nil {
+				t.Fatalf("could not decode state: %s", err.Error())
+			}
+			state = normalizeState(state)
+
+			if state.Action != tc.action {
+				t.Errorf("expected action %q, got %q", tc.action, state.Action)
+			}
+			if len(state.IDS) != tc.ids {
+				t.Errorf("expected %d ids, got %d", tc.ids, len(state.IDS))
+			}
+			if state.FolderID != tc.folder {
+				t.Errorf("expected folder %q, got %q", tc.folder, state.FolderID)
+			}
+			if state.FolderResourceKey != tc.resourceKey {
+				t.Errorf("expected resource key %q, got %q", tc.resourceKey, state.FolderResourceKey)
+			}
+		})
+	}
+}
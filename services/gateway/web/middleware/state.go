@@ -0,0 +1,102 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/google/uuid"
+)
+
+// stateURLThreshold is the longest escaped state a redirect URL may carry
+// inline. Multi-select launches with dozens of ids exceed what proxies
+// reliably pass through, so bigger states travel as a server-side handle.
+const stateURLThreshold = 1536
+
+type stateEntry struct {
+	state   string
+	expires time.Time
+}
+
+// StateStore keeps oversized launch states server side under random
+// single-use handles. Handles are short-lived: they only need to survive one
+// redirect hop.
+type StateStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]stateEntry
+}
+
+func NewStateStore(ttl time.Duration) *StateStore {
+	return &StateStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]stateEntry),
+	}
+}
+
+// Put stores the serialized state and returns its handle. Expired entries
+// are pruned on the way in so abandoned redirects do not accumulate.
+func (s *StateStore) Put(state string) string {
+	id := uuid.NewString()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.entries[id] = stateEntry{state: state, expires: now.Add(s.ttl)}
+	return id
+}
+
+// Take resolves a handle back to its state exactly once; the entry is
+// removed whether it was still fresh or not.
+func (s *StateStore) Take(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	if !ok {
+		return "", false
+	}
+	delete(s.entries, id)
+	if s.now().After(entry.expires) {
+		return "", false
+	}
+	return entry.state, true
+}
+
+// StateURL builds a redirect URL for the state, deciding automatically
+// between carrying it inline and parking it behind a handle. All redirect
+// constructors go through here so the threshold lives in one place. A nil
+// store always inlines.
+func (s *StateStore) StateURL(path string, state request.DriveState) string {
+	escaped := url.QueryEscape(string(state.ToJSON()))
+	if s == nil || len(escaped) <= stateURLThreshold {
+		return fmt.Sprintf("%s?state=%s", path, escaped)
+	}
+	return fmt.Sprintf("%s?sid=%s", path, s.Put(string(state.ToJSON())))
+}
@@ -0,0 +1,123 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+)
+
+func newTestStateStore(ttl time.Duration, now *time.Time) *StateStore {
+	store := NewStateStore(ttl)
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+// manyIDs builds a state big enough to exceed the inline threshold.
+func manyIDs(count int) []string {
+	ids := make([]string, count)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("file-id-%04d-abcdefghijklmnopqrstuvwxyz0123456789", i)
+	}
+	return ids
+}
+
+func TestStateURLThreshold(t *testing.T) {
+	now := time.Now()
+	store := newTestStateStore(time.Minute, &now)
+
+	small := request.DriveState{Action: "open", IDS: []string{"file-id-0123456789"}}
+	if redirect := store.StateURL("/api/editor", small); !strings.HasPrefix(redirect, "/api/editor?state=") {
+		t.Fatalf("expected an inline state, got %s", redirect)
+	}
+
+	large := request.DriveState{Action: "open", IDS: manyIDs(50)}
+	redirect := store.StateURL("/api/editor", large)
+	if !strings.HasPrefix(redirect, "/api/editor?sid=") {
+		t.Fatalf("expected a state handle, got %.80s", redirect)
+	}
+
+	sid := strings.TrimPrefix(redirect, "/api/editor?sid=")
+	raw, ok := store.Take(sid)
+	if !ok {
+		t.Fatal("expected the handle to resolve")
+	}
+	var resolved request.DriveState
+	if err := json.Unmarshal([]byte(raw), &resolved); err != nil {
+		t.Fatal(err)
+	}
+	if len(resolved.IDS) != 50 || resolved.Action != "open" {
+		t.Errorf("resolved state does not match: %d ids, action %q", len(resolved.IDS), resolved.Action)
+	}
+}
+
+// A nil store always inlines; handlers constructed without one in tests
+// still build working URLs.
+func TestStateURLNilStoreInlines(t *testing.T) {
+	var store *StateStore
+	large := request.DriveState{Action: "open", IDS: manyIDs(50)}
+	redirect := store.StateURL("/api/editor", large)
+	if !strings.HasPrefix(redirect, "/api/editor?state=") {
+		t.Fatalf("expected an inline state, got %.80s", redirect)
+	}
+	if _, err := url.QueryUnescape(strings.TrimPrefix(redirect, "/api/editor?state=")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStateStoreSingleUse(t *testing.T) {
+	now := time.Now()
+	store := newTestStateStore(time.Minute, &now)
+
+	sid := store.Put(`{"action":"open"}`)
+	if _, ok := store.Take(sid); !ok {
+		t.Fatal("expected the first take to succeed")
+	}
+	if _, ok := store.Take(sid); ok {
+		t.Fatal("a handle must resolve exactly once")
+	}
+}
+
+func TestStateStoreExpiry(t *testing.T) {
+	now := time.Now()
+	store := newTestStateStore(time.Minute, &now)
+
+	sid := store.Put(`{"action":"open"}`)
+	now = now.Add(2 * time.Minute)
+	if _, ok := store.Take(sid); ok {
+		t.Fatal("an expired handle must not resolve")
+	}
+
+	// Expired entries are pruned when new states come in.
+	stale := store.Put(`{"action":"open"}`)
+	now = now.Add(2 * time.Minute)
+	store.Put(`{"action":"create"}`)
+	store.mu.Lock()
+	_, ok := store.entries[stale]
+	store.mu.Unlock()
+	if ok {
+		t.Error("expected the stale entry to be pruned on put")
+	}
+}
@@ -0,0 +1,65 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// buildNotFound classifies unknown paths instead of treating every one the
+// same: browser noise (favicon, robots) gets a static answer, API misuse
+// gets a JSON 404, and only an actual person navigating somewhere wrong is
+// redirected — to the create page when their cookie says they are signed
+// in, to consent otherwise. Scanners probing random paths see a bare 404
+// and never generate an OAuth round trip.
+func (s DriveHTTPService) buildNotFound() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/favicon.ico":
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		case "/robots.txt":
+			rw.Header().Set("Content-Type", "text/plain")
+			rw.Write([]byte("User-agent: *\nDisallow: /\n"))
+			return
+		}
+
+		accept := r.Header.Get("Accept")
+		if strings.HasPrefix(r.URL.Path, "/api/") ||
+			strings.HasPrefix(r.URL.Path, "/internal/") ||
+			(strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")) {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte(`{"error":"not found"}`))
+			return
+		}
+
+		if strings.Contains(accept, "text/html") {
+			if s.sessionMiddleware.HasSession(r) {
+				http.Redirect(rw, r, "/api/create", http.StatusSeeOther)
+				return
+			}
+			http.Redirect(rw, r, "/oauth/install", http.StatusSeeOther)
+			return
+		}
+
+		rw.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,160 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/controller"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// newNotFoundTestService wires a service whose session middleware can
+// verify real cookies, plus one valid session cookie to present with.
+func newNotFoundTestService(t *testing.T) (DriveHTTPService, *http.Cookie) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := &shared.CredentialsConfig{}
+	credentials.Credentials.ClientSecret = "client-secret"
+
+	jwtManager := crypto.NewOnlyofficeJwtManager()
+	codec := sessions.NewCookieCodec(nil, func(string, ...interface{}) {})
+	sessionMiddleware := middleware.NewSessionMiddleware(
+		jwtManager, nil, nil, &config.ServerConfig{}, credentials, source,
+		nil, nil, nil, codec, testLogger{},
+	)
+
+	token, err := jwtManager.Sign("client-secret", shared.SessionToken{
+		UID: "user-a",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewServer(
+		controller.AuthController{}, controller.EditorController{},
+		controller.ConvertController{}, controller.CreateController{},
+		controller.APIController{}, controller.PickerController{},
+		controller.SessionsController{}, controller.SettingsController{},
+		controller.AdminController{}, sessionMiddleware,
+		source, false, testLogger{},
+	)
+	return service, &http.Cookie{Name: shared.SessionCookie, Value: token}
+}
+
+// Unknown paths answer by what is actually asking: noise gets static
+// responses, API misuse gets a JSON 404, and only browsers are redirected.
+func TestNotFoundClassification(t *testing.T) {
+	service, cookie := newNotFoundTestService(t)
+	handler := service.NewHandler()
+
+	for name, tc := range map[string]struct {
+		path       string
+		accept     string
+		withCookie bool
+		code       int
+		location   string
+		jsonBody   bool
+	}{
+		"favicon noise": {
+			path: "/favicon.ico",
+			code: http.StatusNoContent,
+		},
+		"robots noise": {
+			path: "/robots.txt",
+			code: http.StatusOK,
+		},
+		"typoed api call": {
+			path: "/api/downlaod",
+			code: http.StatusNotFound, jsonBody: true,
+		},
+		"json client off the api mount": {
+			path: "/callback", accept: "application/json",
+			code: http.StatusNotFound, jsonBody: true,
+		},
+		"browser without a session": {
+			path: "/open", accept: "text/html,application/xhtml+xml",
+			code: http.StatusSeeOther, location: "/oauth/install",
+		},
+		"browser with a session": {
+			path: "/open", accept: "text/html,application/xhtml+xml", withCookie: true,
+			code: http.StatusSeeOther, location: "/api/create",
+		},
+		"scanner without accept": {
+			path: "/wp-admin/setup.php",
+			code: http.StatusNotFound,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			if tc.withCookie {
+				req.AddCookie(cookie)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.code {
+				t.Fatalf("expected status %d, got %d", tc.code, rec.Code)
+			}
+			if location := rec.Header().Get("Location"); location != tc.location {
+				t.Errorf("expected location %q, got %q", tc.location, location)
+			}
+			if tc.jsonBody && rec.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("expected a json 404 body, got %q", rec.Header().Get("Content-Type"))
+			}
+			if tc.path == "/robots.txt" && !strings.Contains(rec.Body.String(), "Disallow") {
+				t.Errorf("expected robots to disallow crawling, got %q", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,501 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/settings"
+)
+
+// The gateway's OpenAPI document is hand-maintained here rather than
+// generated from annotations, but the schemas themselves are reflected from
+// the structs in services/shared/request and response, so the Go types stay
+// the single source of truth.
+
+type openapiSchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Properties           map[string]*openapiSchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *openapiSchema            `json:"items,omitempty"`
+	AdditionalProperties *openapiSchema            `json:"additionalProperties,omitempty"`
+}
+
+type openapiMedia struct {
+	Schema *openapiSchema `json:"schema"`
+}
+
+type openapiBody struct {
+	Required bool                    `json:"required"`
+	Content  map[string]openapiMedia `json:"content"`
+}
+
+type openapiResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]openapiMedia `json:"content,omitempty"`
+}
+
+type openapiParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openapiSchema `json:"schema"`
+}
+
+type openapiOperation struct {
+	Summary     string                     `json:"summary"`
+	Parameters  []openapiParameter         `json:"parameters,omitempty"`
+	RequestBody *openapiBody               `json:"requestBody,omitempty"`
+	Responses   map[string]openapiResponse `json:"responses"`
+}
+
+type openapiInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openapiComponents struct {
+	Schemas map[string]*openapiSchema `json:"schemas"`
+}
+
+type openapiDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openapiInfo                            `json:"info"`
+	Paths      map[string]map[string]openapiOperation `json:"paths"`
+	Components openapiComponents                      `json:"components"`
+}
+
+// openapiTypes are the shared structs exposed through the spec, keyed by
+// their component schema name.
+var openapiTypes = map[string]interface{}{
+	"DriveState":            request.DriveState{},
+	"CreateRequest":         request.CreateRequest{},
+	"SessionRefreshRequest": request.SessionRefreshRequest{},
+	"RestoreRequest":        request.RestoreRequest{},
+	"PingResponse":          response.PingResponse{},
+	"ConvertJobResponse":    response.ConvertJobResponse{},
+	"ConvertProgress":       response.ConvertProgressResponse{},
+	"FolderListResponse":    response.FolderListResponse{},
+	"CapabilitiesResponse":  response.CapabilitiesResponse{},
+	"UserSettings":          settings.UserSettings{},
+}
+
+// schemaOf reflects a Go type into an OpenAPI schema, following the same
+// json tags encoding/json uses, so the spec cannot drift from what the
+// handlers actually marshal.
+func schemaOf(t reflect.Type) *openapiSchema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return &openapiSchema{Type: "string"}
+	case reflect.Bool:
+		return &openapiSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openapiSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openapiSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t == reflect.TypeOf(json.RawMessage{}) {
+			return &openapiSchema{Type: "object"}
+		}
+		return &openapiSchema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openapiSchema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case reflect.Struct:
+		schema := &openapiSchema{Type: "object", Properties: map[string]*openapiSchema{}}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name := strings.Split(tag, ",")[0]
+			if name == "" {
+				name = field.Name
+			}
+
+			schema.Properties[name] = schemaOf(field.Type)
+			if !strings.Contains(tag, "omitempty") {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		return schema
+	default:
+		return &openapiSchema{Type: "string"}
+	}
+}
+
+func schemaRef(name string) *openapiSchema {
+	return &openapiSchema{Ref: fmt.Sprintf("#/components/schemas/%s", name)}
+}
+
+func jsonBody(name string) *openapiBody {
+	return &openapiBody{
+		Required: true,
+		Content:  map[string]openapiMedia{"application/json": {Schema: schemaRef(name)}},
+	}
+}
+
+func jsonResponse(description, name string) openapiResponse {
+	return openapiResponse{
+		Description: description,
+		Content:     map[string]openapiMedia{"application/json": {Schema: schemaRef(name)}},
+	}
+}
+
+func htmlResponse(description string) openapiResponse {
+	return openapiResponse{
+		Description: description,
+		Content: map[string]openapiMedia{
+			"text/html": {Schema: &openapiSchema{Type: "string"}},
+		},
+	}
+}
+
+func plainResponse(description string) openapiResponse {
+	return openapiResponse{Description: description}
+}
+
+func queryParameter(name, description string, required bool) openapiParameter {
+	return openapiParameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Required:    required,
+		Schema:      &openapiSchema{Type: "string"},
+	}
+}
+
+// buildOpenAPIDocument assembles the gateway API description. Every
+// /api route registered in NewHandler must appear here; the spec test
+// cross-checks the two.
+func buildOpenAPIDocument() openapiDocument {
+	schemas := map[string]*openapiSchema{}
+	for name, value := range openapiTypes {
+		schemas[name] = schemaOf(reflect.TypeOf(value))
+	}
+
+	stateParameter := queryParameter(
+		"state", "Drive launch state, a serialized DriveState", true,
+	)
+
+	return openapiDocument{
+		OpenAPI: "3.0.3",
+		Info: openapiInfo{
+			Title:       "ONLYOFFICE Google Drive gateway",
+			Description: "HTTP API of the gateway service integrators embed.",
+			Version:     buildinfo.Version,
+		},
+		Components: openapiComponents{Schemas: schemas},
+		Paths: map[string]map[string]openapiOperation{
+			"/api/ping": {
+				"get": {
+					Summary: "Liveness probe spanning the gateway's dependencies",
+					Responses: map[string]openapiResponse{
+						"200": jsonResponse("Service health per dependency", "PingResponse"),
+					},
+				},
+			},
+			"/api/download": {
+				"get": {
+					Summary: "Stream a Drive file's content to a download token holder",
+					Parameters: []openapiParameter{
+						queryParameter("token", "Signed download token", true),
+					},
+					Responses: map[string]openapiResponse{
+						"200": plainResponse("The file content"),
+						"403": plainResponse("Invalid token or restricted file"),
+					},
+				},
+			},
+			"/api/editor": {
+				"get": {
+					Summary:    "Render the editor page for the launch state",
+					Parameters: []openapiParameter{stateParameter},
+					Responses: map[string]openapiResponse{
+						"200": htmlResponse("The editor page"),
+					},
+				},
+			},
+			"/api/editor/beacon": {
+				"post": {
+					Summary: "Record a browser-reported api.js load failure",
+					Responses: map[string]openapiResponse{
+						"204": plainResponse("Report recorded"),
+					},
+				},
+			},
+			"/api/convert": {
+				"get": {
+					Summary:    "Render the conversion suggestion page",
+					Parameters: []openapiParameter{stateParameter},
+					Responses: map[string]openapiResponse{
+						"200": htmlResponse("The conversion page"),
+					},
+				},
+				"post": {
+					Summary:     "Convert a legacy file or force the editor open",
+					RequestBody: jsonBody("DriveState"),
+					Responses: map[string]openapiResponse{
+						"200": jsonResponse("The state to relaunch the editor with", "DriveState"),
+						"202": jsonResponse("The job tracking a started conversion", "ConvertJobResponse"),
+						"400": plainResponse("Invalid payload"),
+						"409": plainResponse("Spent or expired page nonce"),
+					},
+				},
+			},
+			"/api/convert/progress": {
+				"get": {
+					Summary: "Report a conversion job's phase and percent",
+					Parameters: []openapiParameter{
+						queryParameter("job", "The job id the convert POST returned", true),
+					},
+					Responses: map[string]openapiResponse{
+						"200": jsonResponse("The job status", "ConvertProgress"),
+						"404": plainResponse("Unknown or expired job"),
+					},
+				},
+			},
+			"/api/convert/cancel": {
+				"post": {
+					Summary: "Cancel a running conversion job",
+					Parameters: []openapiParameter{
+						queryParameter("job", "The job id the convert POST returned", true),
+					},
+					Responses: map[string]openapiResponse{
+						"204": plainResponse("The cancellation was accepted"),
+					},
+				},
+			},
+			"/api/folders": {
+				"get": {
+					Summary: "List destination folders for converted copies",
+					Parameters: []openapiParameter{
+						queryParameter("parent", "Parent folder id, the Drive root when omitted", false),
+						queryParameter("page", "Drive page token", false),
+					},
+					Responses: map[string]openapiResponse{
+						"200": jsonResponse("One page of subfolders", "FolderListResponse"),
+					},
+				},
+			},
+			"/api/create": {
+				"get": {
+					Summary:    "Render the new-document form",
+					Parameters: []openapiParameter{stateParameter},
+					Responses: map[string]openapiResponse{
+						"200": htmlResponse("The new-document page"),
+					},
+				},
+				"post": {
+					Summary:     "Create a Drive file from an embedded template",
+					RequestBody: jsonBody("CreateRequest"),
+					Responses: map[string]openapiResponse{
+						"200": jsonResponse("The state opening the created file", "DriveState"),
+						"400": plainResponse("Invalid payload"),
+						"409": plainResponse("Spent or expired page nonce"),
+					},
+				},
+			},
+			"/api/capabilities": {
+				"get": {
+					Summary: "Report whether a Drive file would open in the editor",
+					Parameters: []openapiParameter{
+						queryParameter("fileId", "The Drive file id to pre-validate", true),
+					},
+					Responses: map[string]openapiResponse{
+						"200": jsonResponse("The file's capabilities", "CapabilitiesResponse"),
+						"400": plainResponse("Missing file id"),
+					},
+				},
+			},
+			"/api/restore": {
+				"post": {
+					Summary:     "Write an older revision back as the file's new head",
+					RequestBody: jsonBody("RestoreRequest"),
+					Responses: map[string]openapiResponse{
+						"200": plainResponse("The revision was restored"),
+						"400": plainResponse("Invalid payload or missing confirmation"),
+						"403": plainResponse("The caller can only view the file"),
+						"404": plainResponse("No such file"),
+					},
+				},
+			},
+			"/api/picker": {
+				"get": {
+					Summary:    "Render the shared-file picker page",
+					Parameters: []openapiParameter{stateParameter},
+					Responses: map[string]openapiResponse{
+						"200": htmlResponse("The picker page"),
+					},
+				},
+			},
+			"/api/picker/select": {
+				"post": {
+					Summary: "Exchange a picked file id for an embed URL",
+					RequestBody: &openapiBody{
+						Required: true,
+						Content: map[string]openapiMedia{
+							"application/json": {Schema: &openapiSchema{
+								Type: "object",
+								Properties: map[string]*openapiSchema{
+									"id": {Type: "string"},
+								},
+								Required: []string{"id"},
+							}},
+						},
+					},
+					Responses: map[string]openapiResponse{
+						"200": plainResponse("The embed URL"),
+						"400": plainResponse("Missing file id"),
+					},
+				},
+			},
+			"/api/picker/embed": {
+				"get": {
+					Summary: "Render the embedded view-only editor for a picked file",
+					Parameters: []openapiParameter{
+						queryParameter("token", "Signed picker token", true),
+					},
+					Responses: map[string]openapiResponse{
+						"200": htmlResponse("The embedded editor page"),
+					},
+				},
+			},
+			"/api/sessions/drop": {
+				"post": {
+					Summary: "Force-close one of the caller's open documents",
+					Parameters: []openapiParameter{
+						queryParameter("file_id", "The open document to close", true),
+					},
+					Responses: map[string]openapiResponse{
+						"303": plainResponse("Redirect back to the referring page"),
+						"404": plainResponse("No such open document"),
+					},
+				},
+			},
+			"/api/settings": {
+				"get": {
+					Summary: "Read the caller's stored preferences",
+					Responses: map[string]openapiResponse{
+						"200": jsonResponse("The stored preferences", "UserSettings"),
+					},
+				},
+				"post": {
+					Summary:     "Store the caller's preferences",
+					RequestBody: jsonBody("UserSettings"),
+					Responses: map[string]openapiResponse{
+						"200": plainResponse("Preferences stored"),
+						"400": plainResponse("Invalid payload"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// validateOpenAPIDocument structurally checks the assembled spec: version
+// and info are present, every path carries at least one operation, every
+// operation answers something and every $ref resolves to a component. It
+// backs both the startup-free spec test and integrators' trust that the
+// served document is well-formed.
+func validateOpenAPIDocument(doc openapiDocument) error {
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return fmt.Errorf("openapi version %q is not a v3 version", doc.OpenAPI)
+	}
+	if doc.Info.Title == "" || doc.Info.Version == "" {
+		return fmt.Errorf("info.title and info.version are required")
+	}
+	if len(doc.Paths) == 0 {
+		return fmt.Errorf("the spec describes no paths")
+	}
+
+	for path, operations := range doc.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path %q does not start with a slash", path)
+		}
+		if len(operations) == 0 {
+			return fmt.Errorf("path %s has no operations", path)
+		}
+		for method, operation := range operations {
+			if len(operation.Responses) == 0 {
+				return fmt.Errorf("%s %s declares no responses", method, path)
+			}
+			for _, answer := range operation.Responses {
+				if answer.Description == "" {
+					return fmt.Errorf("%s %s has a response without a description", method, path)
+				}
+				if err := validateRefs(doc, answer.Content); err != nil {
+					return fmt.Errorf("%s %s: %w", method, path, err)
+				}
+			}
+			if operation.RequestBody != nil {
+				if err := validateRefs(doc, operation.RequestBody.Content); err != nil {
+					return fmt.Errorf("%s %s: %w", method, path, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateRefs(doc openapiDocument, content map[string]openapiMedia) error {
+	for _, media := range content {
+		if media.Schema == nil || media.Schema.Ref == "" {
+			continue
+		}
+		name := strings.TrimPrefix(media.Schema.Ref, "#/components/schemas/")
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			return fmt.Errorf("unresolved schema reference %s", media.Schema.Ref)
+		}
+	}
+	return nil
+}
+
+// buildOpenAPISpec serves the assembled document. The endpoint is gated by
+// the gateway openapi config flag, checked per request so a config reload
+// can expose or hide it without a restart.
+func (s DriveHTTPService) buildOpenAPISpec() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if !s.onlyoffice.Config().Onlyoffice.Gateway.OpenAPI {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(buildOpenAPIDocument())
+	}
+}
@@ -0,0 +1,144 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/controller"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/go-chi/chi/v5"
+)
+
+func newOpenAPITestService(t *testing.T, enabled bool) DriveHTTPService {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := fmt.Sprintf("onlyoffice:\n  gateway:\n    openapi: %t\n", enabled)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := shared.NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewServer(
+		controller.AuthController{}, controller.EditorController{},
+		controller.ConvertController{}, controller.CreateController{},
+		controller.APIController{}, controller.PickerController{},
+		controller.SessionsController{}, controller.SettingsController{},
+		controller.AdminController{}, middleware.SessionMiddleware{},
+		source, false, testLogger{},
+	)
+}
+
+type testLogger struct{}
+
+func (testLogger) Debugf(format string, args ...interface{}) {}
+func (testLogger) Infof(format string, args ...interface{})  {}
+func (testLogger) Warnf(format string, args ...interface{})  {}
+func (testLogger) Errorf(format string, args ...interface{}) {}
+func (testLogger) Fatalf(format string, args ...interface{}) {}
+
+func TestOpenAPIDocumentValidates(t *testing.T) {
+	if err := validateOpenAPIDocument(buildOpenAPIDocument()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAPIValidationCatchesDanglingRefs(t *testing.T) {
+	doc := buildOpenAPIDocument()
+	delete(doc.Components.Schemas, "DriveState")
+
+	if err := validateOpenAPIDocument(doc); err == nil {
+		t.Fatal("expected a dangling reference to fail validation")
+	}
+}
+
+// Every /api route registered on the router must be described in the spec;
+// a new endpoint without documentation fails here.
+func TestOpenAPICoversRegisteredRoutes(t *testing.T) {
+	service := newOpenAPITestService(t, true)
+	service.NewHandler()
+
+	doc := buildOpenAPIDocument()
+	if err := chi.Walk(service.mux, func(
+		method, route string, handler http.Handler,
+		middlewares ...func(http.Handler) http.Handler,
+	) error {
+		if !strings.HasPrefix(route, "/api/") || route == "/api/openapi.json" {
+			return nil
+		}
+
+		operations, ok := doc.Paths[route]
+		if !ok {
+			t.Errorf("route %s is not described in the openapi document", route)
+			return nil
+		}
+		if _, ok := operations[strings.ToLower(method)]; !ok {
+			t.Errorf("route %s is missing the %s operation", route, method)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAPISpecIsServedBehindTheFlag(t *testing.T) {
+	for name, tc := range map[string]struct {
+		enabled bool
+		status  int
+	}{
+		"enabled serves the document": {enabled: true, status: http.StatusOK},
+		"disabled answers not found":  {enabled: false, status: http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := newOpenAPITestService(t, tc.enabled).NewHandler()
+
+			req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			if !tc.enabled {
+				return
+			}
+
+			var doc openapiDocument
+			if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+				t.Fatal(err)
+			}
+			if err := validateOpenAPIDocument(doc); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
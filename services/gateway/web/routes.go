@@ -0,0 +1,163 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// routeProtection names the guard a route must run behind. Routes declare
+// their requirement in the table below and NewHandler applies the matching
+// middleware itself, so a handler that needs a session can never be
+// registered without it by forgetting a wrapper.
+type routeProtection string
+
+const (
+	// protectionNone marks endpoints that are public by design, like the
+	// health probe and the consent entry points.
+	protectionNone routeProtection = "none"
+	// protectionToken marks endpoints authenticated by a signed token
+	// inside the request itself — the download proxy's download token and
+	// the Document Server JWT — rather than by a browser session.
+	protectionToken routeProtection = "token"
+	// protectionSession marks endpoints that run behind
+	// SessionMiddleware.Protect and expect user credentials in the context.
+	protectionSession routeProtection = "session"
+	// protectionInternal marks service-to-service endpoints behind
+	// shared.InternalAuthMiddleware's request signature check.
+	protectionInternal routeProtection = "internal"
+)
+
+// route declares one gateway endpoint: where it is mounted, the protection
+// it requires and the handler serving it. Debug-only routes register only
+// when the gateway runs in debug mode.
+type route struct {
+	method     string
+	pattern    string
+	protection routeProtection
+	handler    http.HandlerFunc
+	debugOnly  bool
+}
+
+// publicAPIRoutes lists the /api endpoints that are deliberately reachable
+// without a session, each for a reviewed reason. verifyRouteProtections
+// rejects any other unprotected /api registration, so adding a public
+// endpoint is an explicit decision here rather than an omission in the
+// table.
+var publicAPIRoutes = map[string]routeProtection{
+	// The health probe other services compare gateway URLs against.
+	"GET /api/ping": protectionNone,
+	// The Document Server fetches file content with a signed download
+	// token; it has no browser session.
+	"GET /api/download": protectionToken,
+	// The embeddable picker is served cross-origin to partner pages.
+	"GET /api/picker/embed": protectionNone,
+	// The API description documents the endpoints, it exposes no data.
+	"GET /api/openapi.json": protectionNone,
+}
+
+// routes is the gateway's route table. Everything under /api is session
+// protected unless listed in publicAPIRoutes; everything under /internal
+// carries the internal request signature requirement.
+func (s DriveHTTPService) routes() []route {
+	return []route{
+		{http.MethodGet, "/oauth/install", protectionNone, s.authController.BuildGetAuth(), false},
+		{http.MethodGet, "/oauth/redirect", protectionNone, s.authController.BuildGetRedirect(), false},
+		// Exposes the computed consent URL for comparison against the
+		// Google console; diagnostic only, so debug builds only.
+		{http.MethodGet, "/oauth/diagnostics", protectionNone, s.authController.BuildGetDiagnostics(), true},
+
+		{http.MethodGet, "/api/ping", protectionNone, s.apiController.BuildPing(), false},
+		{http.MethodGet, "/api/download", protectionToken, s.apiController.BuildDownloadFile(), false},
+		{http.MethodGet, "/api/picker/embed", protectionNone, s.pickerController.BuildPickerEmbed(), false},
+		{http.MethodGet, "/api/openapi.json", protectionNone, s.buildOpenAPISpec(), false},
+
+		{http.MethodGet, "/api/editor", protectionSession, s.editorController.BuildEditorPage(), false},
+		{http.MethodPost, "/api/editor/beacon", protectionSession, s.editorController.BuildEditorBeacon(), false},
+		{http.MethodGet, "/api/convert", protectionSession, s.convertController.BuildConvertPage(), false},
+		{http.MethodPost, "/api/convert", protectionSession, s.convertController.BuildConvertFile(), false},
+		{http.MethodGet, "/api/convert/progress", protectionSession, s.convertController.BuildConvertProgress(), false},
+		{http.MethodPost, "/api/convert/cancel", protectionSession, s.convertController.BuildConvertCancel(), false},
+		{http.MethodGet, "/api/folders", protectionSession, s.convertController.BuildListFolders(), false},
+		{http.MethodGet, "/api/create", protectionSession, s.createController.BuildCreatePage(), false},
+		{http.MethodPost, "/api/create", protectionSession, s.createController.BuildCreateFile(), false},
+		{http.MethodGet, "/api/picker", protectionSession, s.pickerController.BuildPickerPage(), false},
+		{http.MethodPost, "/api/picker/select", protectionSession, s.pickerController.BuildPickerSelect(), false},
+		{http.MethodPost, "/api/sessions/drop", protectionSession, s.sessionsController.BuildDropSession(), false},
+		{http.MethodGet, "/api/email/attachments", protectionSession, s.emailController.BuildEmailAttachments(), false},
+		{http.MethodGet, "/api/email/attachment", protectionSession, s.emailController.BuildEmailAttachment(), false},
+		{http.MethodGet, "/api/capabilities", protectionSession, s.apiController.BuildCapabilities(), false},
+		{http.MethodPost, "/api/restore", protectionSession, s.apiController.BuildRestoreRevision(), false},
+		{http.MethodGet, "/api/settings", protectionSession, s.settingsController.BuildGetSettings(), false},
+		{http.MethodPost, "/api/settings", protectionSession, s.settingsController.BuildPostSettings(), false},
+		// The effective configuration with secrets masked; diagnostic only,
+		// so debug builds only.
+		{http.MethodGet, "/api/config", protectionSession, s.buildEffectiveConfig(), true},
+
+		{http.MethodPost, "/internal/reload", protectionInternal, s.buildReload(), false},
+		{http.MethodPost, "/internal/sessions/refresh", protectionInternal, s.sessionsController.BuildRefreshSession(), false},
+		{http.MethodPost, "/internal/configs/invalidate", protectionInternal, s.editorController.BuildInvalidateConfigs(), false},
+		{http.MethodPost, "/internal/users/invalidate", protectionInternal, s.adminController.BuildInvalidateUser(), false},
+	}
+}
+
+// verifyRouteProtections is the startup assertion over the route table: a
+// duplicate registration, an /api route that is neither session protected
+// nor on the public allowlist, or an /internal route without the signature
+// requirement all fail the gateway before it ever serves a request.
+func verifyRouteProtections(routes []route) error {
+	seen := map[string]struct{}{}
+	for _, rt := range routes {
+		key := fmt.Sprintf("%s %s", rt.method, rt.pattern)
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("route %s is registered twice", key)
+		}
+		seen[key] = struct{}{}
+
+		switch {
+		case strings.HasPrefix(rt.pattern, "/api/"):
+			if rt.protection == protectionSession {
+				continue
+			}
+			if expected, ok := publicAPIRoutes[key]; !ok || expected != rt.protection {
+				return fmt.Errorf(
+					"route %s requires a session or an entry in publicAPIRoutes, got %q",
+					key, rt.protection,
+				)
+			}
+		case strings.HasPrefix(rt.pattern, "/internal/"):
+			if rt.protection != protectionInternal {
+				return fmt.Errorf(
+					"route %s must carry the internal signature requirement, got %q",
+					key, rt.protection,
+				)
+			}
+		default:
+			if rt.protection != protectionNone {
+				return fmt.Errorf(
+					"route %s declares %q outside the mount that enforces it",
+					key, rt.protection,
+				)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// TestRouteProtections enumerates the gateway's routes and the protection
+// each must declare; a new route missing here, or an existing one changing
+// its guard, fails loudly so the change is a reviewed decision.
+func TestRouteProtections(t *testing.T) {
+	expected := map[string]routeProtection{
+		"GET /oauth/install":                protectionNone,
+		"GET /oauth/redirect":               protectionNone,
+		"GET /oauth/diagnostics":            protectionNone,
+		"GET /api/ping":                     protectionNone,
+		"GET /api/download":                 protectionToken,
+		"GET /api/picker/embed":             protectionNone,
+		"GET /api/openapi.json":             protectionNone,
+		"GET /api/editor":                   protectionSession,
+		"POST /api/editor/beacon":           protectionSession,
+		"GET /api/convert":                  protectionSession,
+		"POST /api/convert":                 protectionSession,
+		"GET /api/convert/progress":         protectionSession,
+		"POST /api/convert/cancel":          protectionSession,
+		"GET /api/folders":                  protectionSession,
+		"GET /api/create":                   protectionSession,
+		"POST /api/create":                  protectionSession,
+		"GET /api/picker":                   protectionSession,
+		"POST /api/picker/select":           protectionSession,
+		"POST /api/sessions/drop":           protectionSession,
+		"GET /api/email/attachments":        protectionSession,
+		"GET /api/email/attachment":         protectionSession,
+		"GET /api/capabilities":             protectionSession,
+		"POST /api/restore":                 protectionSession,
+		"GET /api/settings":                 protectionSession,
+		"POST /api/settings":                protectionSession,
+		"GET /api/config":                   protectionSession,
+		"POST /internal/reload":             protectionInternal,
+		"POST /internal/sessions/refresh":   protectionInternal,
+		"POST /internal/configs/invalidate": protectionInternal,
+		"POST /internal/users/invalidate":   protectionInternal,
+	}
+
+	routes := DriveHTTPService{}.routes()
+	if len(routes) != len(expected) {
+		t.Errorf("expected %d routes, got %d", len(expected), len(routes))
+	}
+	for _, rt := range routes {
+		key := fmt.Sprintf("%s %s", rt.method, rt.pattern)
+		protection, ok := expected[key]
+		if !ok {
+			t.Errorf("route %s is not enumerated in this test", key)
+			continue
+		}
+		if rt.protection != protection {
+			t.Errorf("route %s declares %q, expected %q", key, rt.protection, protection)
+		}
+	}
+
+	if err := verifyRouteProtections(routes); err != nil {
+		t.Fatalf("the route table must pass its own assertions: %s", err)
+	}
+}
+
+func TestVerifyRouteProtectionsRejectsUnprotectedAPIRoute(t *testing.T) {
+	routes := append(DriveHTTPService{}.routes(), route{
+		method:     http.MethodGet,
+		pattern:    "/api/forgotten",
+		protection: protectionNone,
+	})
+
+	if err := verifyRouteProtections(routes); err == nil {
+		t.Fatal("expected an unprotected /api route to fail the assertion")
+	}
+}
+
+func TestVerifyRouteProtectionsRejectsUnsignedInternalRoute(t *testing.T) {
+	routes := append(DriveHTTPService{}.routes(), route{
+		method:     http.MethodPost,
+		pattern:    "/internal/forgotten",
+		protection: protectionNone,
+	})
+
+	if err := verifyRouteProtections(routes); err == nil {
+		t.Fatal("expected an unsigned /internal route to fail the assertion")
+	}
+}
+
+func TestVerifyRouteProtectionsRejectsDuplicates(t *testing.T) {
+	routes := DriveHTTPService{}.routes()
+	routes = append(routes, routes[0])
+
+	if err := verifyRouteProtections(routes); err == nil {
+		t.Fatal("expected a duplicate registration to fail the assertion")
+	}
+}
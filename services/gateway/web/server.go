@@ -0,0 +1,145 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/controller"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/embeddable"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DriveHTTPService wires the gateway controllers into a chi router.
+type DriveHTTPService struct {
+	mux                *chi.Mux
+	authController     controller.AuthController
+	editorController   controller.EditorController
+	convertController  controller.ConvertController
+	createController   controller.CreateController
+	apiController      controller.APIController
+	pickerController   controller.PickerController
+	sessionsController controller.SessionsController
+	settingsController controller.SettingsController
+	adminController    controller.AdminController
+	emailController    controller.EmailController
+	sessionMiddleware  middleware.SessionMiddleware
+	onlyoffice         *shared.OnlyofficeConfigSource
+	server             *config.ServerConfig
+	debug              bool
+	logger             log.Logger
+}
+
+func NewServer(
+	authController controller.AuthController,
+	editorController controller.EditorController,
+	convertController controller.ConvertController,
+	createController controller.CreateController,
+	apiController controller.APIController,
+	pickerController controller.PickerController,
+	sessionsController controller.SessionsController,
+	settingsController controller.SettingsController,
+	adminController controller.AdminController,
+	emailController controller.EmailController,
+	sessionMiddleware middleware.SessionMiddleware,
+	onlyoffice *shared.OnlyofficeConfigSource,
+	server *config.ServerConfig,
+	debug bool, logger log.Logger,
+) DriveHTTPService {
+	return DriveHTTPService{
+		mux:                chi.NewRouter(),
+		authController:     authController,
+		editorController:   editorController,
+		convertController:  convertController,
+		createController:   createController,
+		apiController:      apiController,
+		pickerController:   pickerController,
+		sessionsController: sessionsController,
+		settingsController: settingsController,
+		adminController:    adminController,
+		emailController:    emailController,
+		sessionMiddleware:  sessionMiddleware,
+		onlyoffice:         onlyoffice,
+		server:             server,
+		debug:              debug,
+		logger:             logger,
+	}
+}
+
+// buildReload re-reads the shared configuration on demand; internal only.
+func (s DriveHTTPService) buildReload() http.HandlerFunc {
+	return func(rw http.ResponseWriter, _ *http.Request) {
+		if err := s.onlyoffice.Reload(); err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}
+}
+
+// NewHandler builds the gateway http handler from the route table. It
+// panics when the table fails its protection assertions — a misregistered
+// route must stop the gateway at startup, not surface as an open endpoint.
+func (s DriveHTTPService) NewHandler() http.Handler {
+	s.mux.Use(chimiddleware.RealIP)
+	s.mux.Use(chimiddleware.RequestID)
+	s.mux.Use(chimiddleware.Recoverer)
+	s.mux.Use(shared.TraceMiddleware(s.onlyoffice, s.debug, s.logger))
+
+	s.mux.Handle("/static/*", http.FileServer(http.FS(embeddable.Static)))
+	s.mux.Handle("/metrics", promhttp.Handler())
+	s.mux.Get("/version", buildinfo.Handler())
+
+	routes := s.routes()
+	if err := verifyRouteProtections(routes); err != nil {
+		panic(fmt.Sprintf("gateway route table: %s", err))
+	}
+
+	internal := shared.InternalAuthMiddleware(s.onlyoffice)
+	for _, rt := range routes {
+		if rt.debugOnly && !s.debug {
+			continue
+		}
+
+		var handler http.Handler = rt.handler
+		switch rt.protection {
+		case protectionSession:
+			handler = s.sessionMiddleware.Protect(handler)
+		case protectionInternal:
+			handler = internal(handler)
+		}
+		// Classification wraps the whole chain so middleware rejections
+		// count too; only registered routes are wrapped, which keeps bots
+		// probing random paths out of the error counter.
+		handler = shared.ClassifyErrors(rt.pattern)(handler)
+		s.mux.Method(rt.method, rt.pattern, handler)
+	}
+
+	s.mux.NotFound(s.buildNotFound())
+
+	return s.mux
+}
@@ -0,0 +1,20 @@
+module github.com/ONLYOFFICE/onlyoffice-gdrive/services
+
+go 1.25.3
+
+require (
+	github.com/ONLYOFFICE/onlyoffice-integration-adapters v0.1.52
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/go-chi/chi/v5 v5.2.3
+	github.com/go-redis/redis/v8 v8.11.5
+	github.com/golang-jwt/jwt/v4 v4.5.2
+	github.com/google/uuid v1.6.0
+	github.com/prometheus/client_golang v1.24.1
+	github.com/sethvargo/go-envconfig v1.3.0
+	go-micro.dev/v4 v4.11.0
+	go.mongodb.org/mongo-driver v1.17.9
+	golang.org/x/oauth2 v0.33.0
+	golang.org/x/sync v0.18.0
+	google.golang.org/api v0.255.0
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -0,0 +1,58 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package buildinfo carries version metadata injected at build time:
+//
+//	go build -ldflags "\
+//	  -X .../services/shared/buildinfo.Version=1.2.0 \
+//	  -X .../services/shared/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X .../services/shared/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// Version is the release tag of this build.
+	Version = "develop"
+	// Commit is the short git revision of this build.
+	Commit = "unknown"
+	// Date is the UTC timestamp of this build.
+	Date = "unknown"
+)
+
+// UserAgent identifies outgoing Drive and Document Server requests in
+// partner logs.
+func UserAgent() string {
+	return fmt.Sprintf("onlyoffice-gdrive/%s (%s)", Version, Commit)
+}
+
+// Handler answers /version with the build metadata.
+func Handler() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]string{
+			"version": Version,
+			"commit":  Commit,
+			"date":    Date,
+		})
+	}
+}
@@ -0,0 +1,54 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerPayloadShape(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Handler()(recorder, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if ct := recorder.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	var payload map[string]string
+	if err := json.Unmarshal(recorder.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("payload is not valid json: %s", err.Error())
+	}
+
+	for _, key := range []string{"version", "commit", "date"} {
+		if payload[key] == "" {
+			t.Errorf("payload is missing %q", key)
+		}
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	ua := UserAgent()
+	if !strings.HasPrefix(ua, "onlyoffice-gdrive/") || !strings.Contains(ua, Version) {
+		t.Fatalf("unexpected user agent %q", ua)
+	}
+}
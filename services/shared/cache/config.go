@@ -0,0 +1,136 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// editorStartupWindow is how much signed-token lifetime a cached config must
+// still have to be served. The browser spends real time loading api.js and
+// opening the document; a token that expires inside that window would hand
+// the user an editor that rejects its own config.
+const editorStartupWindow = time.Minute
+
+// ConfigCache keeps recently built editor configs so a quick page reload
+// skips the builder RPC, the Userinfo call and the token signing behind it.
+// An entry only answers the exact launch it was built for — the same user,
+// file, revision, force flags and device — and a save callback drops every
+// entry for the document key it reports, so the cache never outlives the
+// config's inputs.
+type ConfigCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]configEntry
+}
+
+type configEntry struct {
+	launch request.ConfigRequest
+	config response.BuildConfigResponse
+	// tokenExpiry is the signed token's expiry, zero when the config
+	// carries no token.
+	tokenExpiry time.Time
+	expires     time.Time
+}
+
+func NewConfigCache(ttl time.Duration) *ConfigCache {
+	return &ConfigCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]configEntry),
+	}
+}
+
+func configCacheKey(launch request.ConfigRequest) string {
+	return launch.UID + "\x00" + launch.FileID
+}
+
+// Get returns the cached config for an identical launch. A launch differing
+// in any dimension — a new revision, a toggled force-edit, another device —
+// drops the stale entry and misses, as does an entry whose signed token
+// would expire within the editor's startup window.
+func (c *ConfigCache) Get(launch request.ConfigRequest) (response.BuildConfigResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[configCacheKey(launch)]
+	if !ok {
+		return response.BuildConfigResponse{}, false
+	}
+
+	now := c.now()
+	if entry.launch != launch || now.After(entry.expires) ||
+		(!entry.tokenExpiry.IsZero() && !now.Add(editorStartupWindow).Before(entry.tokenExpiry)) {
+		delete(c.entries, configCacheKey(launch))
+		return response.BuildConfigResponse{}, false
+	}
+
+	return entry.config, true
+}
+
+// Put records a freshly built config for the launch that produced it.
+func (c *ConfigCache) Put(launch request.ConfigRequest, config response.BuildConfigResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[configCacheKey(launch)] = configEntry{
+		launch:      launch,
+		config:      config,
+		tokenExpiry: tokenExpiry(config.Token),
+		expires:     c.now().Add(c.ttl),
+	}
+}
+
+// InvalidateKey drops every cached config built around the document key,
+// called when a save callback reports the document behind the key changed.
+func (c *ConfigCache) InvalidateKey(key string) {
+	if key == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, entry := range c.entries {
+		if entry.config.Document.Key == key {
+			delete(c.entries, id)
+		}
+	}
+}
+
+// tokenExpiry reads the expiry claim out of the builder-signed token. The
+// cache holds what our own builder just produced, so the claim is read
+// without verification; a token that does not parse or carries no expiry
+// reports zero and skips the startup-window check.
+func tokenExpiry(token string) time.Time {
+	if token == "" {
+		return time.Time{}
+	}
+
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil || claims.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return claims.ExpiresAt.Time
+}
@@ -0,0 +1,123 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func editorLaunch() request.ConfigRequest {
+	return request.ConfigRequest{
+		UID:       "user-id",
+		FileID:    "file-id",
+		UserAgent: "desktop-agent",
+	}
+}
+
+func editorConfig(t *testing.T, key string, tokenLifetime time.Duration) response.BuildConfigResponse {
+	t.Helper()
+
+	config := response.BuildConfigResponse{}
+	config.Document.Key = key
+	config.Document.Title = "document.docx"
+
+	if tokenLifetime > 0 {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Unix(1700000000, 0).Add(tokenLifetime)),
+		}).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Token = token
+	}
+	return config
+}
+
+// A reload of the same launch within the TTL is the cache's whole point;
+// any changed input — here the pinned revision — must rebuild instead.
+func TestConfigCacheServesOnlyIdenticalLaunches(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := NewConfigCache(30 * time.Second)
+	cache.now = func() time.Time { return now }
+
+	cache.Put(editorLaunch(), editorConfig(t, "doc-key", time.Hour))
+	config, ok := cache.Get(editorLaunch())
+	if !ok || config.Document.Key != "doc-key" {
+		t.Fatal("expected an identical reload to hit the cache")
+	}
+
+	revised := editorLaunch()
+	revised.RevisionID = "2"
+	if _, ok := cache.Get(revised); ok {
+		t.Fatal("a revision change must not serve the cached config")
+	}
+	// The mismatch dropped the stale entry entirely.
+	if _, ok := cache.Get(editorLaunch()); ok {
+		t.Fatal("expected the stale entry to be dropped on mismatch")
+	}
+
+	cache.Put(editorLaunch(), editorConfig(t, "doc-key", time.Hour))
+	now = now.Add(31 * time.Second)
+	if _, ok := cache.Get(editorLaunch()); ok {
+		t.Fatal("expected the entry to expire with the TTL")
+	}
+}
+
+// A config whose signed token runs out within the editor's startup window
+// would be rejected by the Document Server mid-load; it must miss.
+func TestConfigCacheBypassesNearTokenExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := NewConfigCache(time.Hour)
+	cache.now = func() time.Time { return now }
+
+	cache.Put(editorLaunch(), editorConfig(t, "doc-key", 30*time.Second))
+	if _, ok := cache.Get(editorLaunch()); ok {
+		t.Fatal("a token expiring within the startup window must bypass the cache")
+	}
+
+	cache.Put(editorLaunch(), editorConfig(t, "doc-key", 5*time.Minute))
+	if _, ok := cache.Get(editorLaunch()); !ok {
+		t.Fatal("a token with lifetime beyond the startup window should be served")
+	}
+}
+
+// A save callback reports the document key it replaced; every launch cached
+// around that key is stale, launches for other documents are not.
+func TestConfigCacheInvalidatesByDocumentKey(t *testing.T) {
+	cache := NewConfigCache(time.Hour)
+	cache.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	other := editorLaunch()
+	other.FileID = "other-file-id"
+	cache.Put(editorLaunch(), editorConfig(t, "doc-key", time.Hour))
+	cache.Put(other, editorConfig(t, "other-key", time.Hour))
+
+	cache.InvalidateKey("doc-key")
+	if _, ok := cache.Get(editorLaunch()); ok {
+		t.Fatal("expected the saved document's configs to be dropped")
+	}
+	if _, ok := cache.Get(other); !ok {
+		t.Fatal("other documents' configs must survive the invalidation")
+	}
+}
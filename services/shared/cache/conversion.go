@@ -0,0 +1,89 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// ConversionCache remembers which converted copy belongs to which source
+// revision so reopening an unchanged legacy file skips the conversion
+// pipeline. Entries keyed by a stale revision or older than the TTL are
+// treated as misses and dropped.
+type ConversionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]conversionEntry
+}
+
+type conversionEntry struct {
+	revision string
+	targetID string
+	expires  time.Time
+}
+
+func NewConversionCache(ttl time.Duration) *ConversionCache {
+	return &ConversionCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]conversionEntry),
+	}
+}
+
+// Get returns the converted copy id recorded for the source file at the
+// given revision. A revision mismatch means the source changed; the stale
+// entry is dropped.
+func (c *ConversionCache) Get(fileID, revision string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[fileID]
+	if !ok {
+		return "", false
+	}
+
+	if entry.revision != revision || c.now().After(entry.expires) {
+		delete(c.entries, fileID)
+		return "", false
+	}
+
+	return entry.targetID, true
+}
+
+// Put records the converted copy for the source file revision.
+func (c *ConversionCache) Put(fileID, revision, targetID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[fileID] = conversionEntry{
+		revision: revision,
+		targetID: targetID,
+		expires:  c.now().Add(c.ttl),
+	}
+}
+
+// Invalidate drops the entry for a source file, e.g. when the recorded
+// converted copy no longer exists in Drive.
+func (c *ConversionCache) Invalidate(fileID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, fileID)
+}
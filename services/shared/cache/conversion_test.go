@@ -0,0 +1,76 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConversionCacheHit(t *testing.T) {
+	cache := NewConversionCache(time.Hour)
+	cache.Put("source", "rev-1", "copy")
+
+	target, ok := cache.Get("source", "rev-1")
+	if !ok || target != "copy" {
+		t.Fatalf("expected a hit returning copy, got %q, %t", target, ok)
+	}
+}
+
+func TestConversionCacheStaleSource(t *testing.T) {
+	cache := NewConversionCache(time.Hour)
+	cache.Put("source", "rev-1", "copy")
+
+	if _, ok := cache.Get("source", "rev-2"); ok {
+		t.Fatal("expected a miss for a changed source revision")
+	}
+
+	// The stale entry must not come back once the old revision is asked
+	// for again.
+	if _, ok := cache.Get("source", "rev-1"); ok {
+		t.Fatal("expected the stale entry to be dropped")
+	}
+}
+
+func TestConversionCacheDeletedCopy(t *testing.T) {
+	cache := NewConversionCache(time.Hour)
+	cache.Put("source", "rev-1", "copy")
+	cache.Invalidate("source")
+
+	if _, ok := cache.Get("source", "rev-1"); ok {
+		t.Fatal("expected a miss after invalidation")
+	}
+}
+
+func TestConversionCacheExpiry(t *testing.T) {
+	now := time.Now()
+	cache := NewConversionCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	cache.Put("source", "rev-1", "copy")
+
+	now = now.Add(30 * time.Second)
+	if _, ok := cache.Get("source", "rev-1"); !ok {
+		t.Fatal("expected a hit before the ttl elapses")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.Get("source", "rev-1"); ok {
+		t.Fatal("expected a miss after the ttl elapses")
+	}
+}
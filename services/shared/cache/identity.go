@@ -0,0 +1,133 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/singleflight"
+)
+
+// IdentityCache deduplicates and caches the small per-user "identity-ish"
+// Google lookups (Userinfo, About) every handler needs. Within the TTL a
+// user costs one upstream call no matter how many handlers ask; after the
+// TTL the stale value is served immediately while a single background
+// refresh runs, so pages never block on these lookups.
+type IdentityCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]identityEntry
+	group   singleflight.Group
+
+	hits   prometheus.Counter
+	misses prometheus.Counter
+}
+
+type identityEntry struct {
+	value   interface{}
+	fetched time.Time
+}
+
+func NewIdentityCache(ttl time.Duration) *IdentityCache {
+	return &IdentityCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]identityEntry),
+		hits: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "onlyoffice_identity_cache_hits_total",
+			Help: "Identity lookups answered from the cache.",
+		}),
+		misses: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "onlyoffice_identity_cache_misses_total",
+			Help: "Identity lookups that hit the Google API.",
+		}),
+	}
+}
+
+// Get returns the value cached under key, calling fetch on a cold miss.
+// Concurrent callers of the same key share one fetch; a stale entry is
+// returned right away and refreshed once in the background.
+func (c *IdentityCache) Get(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	stale := ok && c.now().Sub(entry.fetched) >= c.ttl
+	c.mu.Unlock()
+
+	if ok && !stale {
+		if c.hits != nil {
+			c.hits.Inc()
+		}
+		return entry.value, nil
+	}
+
+	if stale {
+		if c.hits != nil {
+			c.hits.Inc()
+		}
+		// Stale-while-revalidate: hand out the old value and refresh once
+		// off the request path; a failed refresh keeps the stale entry.
+		go c.group.Do(key, func() (interface{}, error) {
+			value, err := fetch()
+			if err == nil {
+				c.store(key, value)
+			}
+			return value, err
+		})
+		return entry.value, nil
+	}
+
+	if c.misses != nil {
+		c.misses.Inc()
+	}
+	value, err, _ := c.group.Do(key, func() (interface{}, error) {
+		// A parallel flight may have landed while this caller queued up.
+		c.mu.Lock()
+		current, ok := c.entries[key]
+		fresh := ok && c.now().Sub(current.fetched) < c.ttl
+		c.mu.Unlock()
+		if fresh {
+			return current.value, nil
+		}
+
+		value, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		c.store(key, value)
+		return value, nil
+	})
+	return value, err
+}
+
+// Invalidate drops the entry so the next Get fetches anew.
+func (c *IdentityCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+func (c *IdentityCache) store(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = identityEntry{value: value, fetched: c.now()}
+}
@@ -0,0 +1,131 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestIdentityCache pins the clock and skips metric registration so
+// parallel tests do not trip the prometheus registry.
+func newTestIdentityCache(ttl time.Duration, now *time.Time) *IdentityCache {
+	return &IdentityCache{
+		ttl:     ttl,
+		now:     func() time.Time { return *now },
+		entries: make(map[string]identityEntry),
+	}
+}
+
+func TestIdentityCacheDeduplicatesConcurrentFetches(t *testing.T) {
+	now := time.Unix(10000, 0)
+	cache := newTestIdentityCache(time.Minute, &now)
+
+	var calls int32
+	fetch := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return "someone@example.com", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := cache.Get("userinfo:someone", fetch)
+			if err != nil {
+				t.Errorf("unexpected fetch error: %s", err.Error())
+			}
+			if value != "someone@example.com" {
+				t.Errorf("unexpected value: %v", value)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected concurrent handlers to share one call, got %d", got)
+	}
+}
+
+func TestIdentityCacheServesWithinTTL(t *testing.T) {
+	now := time.Unix(10000, 0)
+	cache := newTestIdentityCache(time.Minute, &now)
+
+	var calls int32
+	fetch := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "value", nil
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := cache.Get("userinfo:someone", fetch); err != nil {
+			t.Fatalf("unexpected fetch error: %s", err.Error())
+		}
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected one call within the ttl, got %d", got)
+	}
+}
+
+func TestIdentityCacheStaleWhileRevalidate(t *testing.T) {
+	now := time.Unix(10000, 0)
+	cache := newTestIdentityCache(time.Minute, &now)
+
+	var calls int32
+	fetched := make(chan struct{}, 16)
+	fetch := func() (interface{}, error) {
+		count := atomic.AddInt32(&calls, 1)
+		fetched <- struct{}{}
+		if count > 1 {
+			return "fresh", nil
+		}
+		return "stale", nil
+	}
+
+	if _, err := cache.Get("userinfo:someone", fetch); err != nil {
+		t.Fatalf("unexpected fetch error: %s", err.Error())
+	}
+	<-fetched
+
+	// Past the ttl the stale value is served without blocking.
+	now = now.Add(2 * time.Minute)
+	value, err := cache.Get("userinfo:someone", fetch)
+	if err != nil {
+		t.Fatalf("unexpected fetch error: %s", err.Error())
+	}
+	if value != "stale" {
+		t.Fatalf("expected the stale value to be served, got %v", value)
+	}
+
+	// The background refresh lands and the next read is fresh.
+	<-fetched
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if value, _ := cache.Get("userinfo:someone", fetch); value == "fresh" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the background refresh to replace the stale value")
+}
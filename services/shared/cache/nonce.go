@@ -0,0 +1,95 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// NonceStore issues one-time nonces that bind a rendered page to the POST
+// it submits. A page embeds a nonce at render time; the handler consumes it
+// exactly once, so a captured request body cannot be replayed against the
+// user's Drive while their session cookie is still valid. Entries expire
+// with the TTL, which also bounds how long an abandoned page keeps a slot.
+type NonceStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]nonceEntry
+}
+
+type nonceEntry struct {
+	session string
+	expires time.Time
+}
+
+func NewNonceStore(ttl time.Duration) *NonceStore {
+	return &NonceStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]nonceEntry),
+	}
+}
+
+// Issue creates a nonce bound to the session and remembers it for the TTL.
+func (s *NonceStore) Issue(session string) string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	nonce := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	s.entries[nonce] = nonceEntry{
+		session: session,
+		expires: s.now().Add(s.ttl),
+	}
+	return nonce
+}
+
+// Consume spends the nonce. It succeeds at most once per issued nonce, and
+// only for the session the nonce was issued to; expired, unknown and
+// already-spent nonces all fail the same way.
+func (s *NonceStore) Consume(session, nonce string) bool {
+	if nonce == "" {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	entry, ok := s.entries[nonce]
+	if !ok || entry.session != session {
+		return false
+	}
+	delete(s.entries, nonce)
+	return true
+}
+
+func (s *NonceStore) prune() {
+	now := s.now()
+	for nonce, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, nonce)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNonceConsumesOnce(t *testing.T) {
+	store := NewNonceStore(time.Minute)
+	nonce := store.Issue("session-1")
+
+	if store.Consume("session-2", nonce) {
+		t.Fatal("expected a nonce to be bound to its session")
+	}
+	if !store.Consume("session-1", nonce) {
+		t.Fatal("expected the first consume to succeed")
+	}
+	if store.Consume("session-1", nonce) {
+		t.Fatal("expected a spent nonce to be rejected")
+	}
+	if store.Consume("session-1", "") {
+		t.Fatal("expected an empty nonce to be rejected")
+	}
+}
+
+func TestNonceExpires(t *testing.T) {
+	store := NewNonceStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	nonce := store.Issue("session-1")
+	now = now.Add(time.Minute + time.Second)
+	if store.Consume("session-1", nonce) {
+		t.Fatal("expected an expired nonce to be rejected")
+	}
+
+	// A fresh nonce issued after the expiry works normally.
+	if !store.Consume("session-1", store.Issue("session-1")) {
+		t.Fatal("expected a fresh nonce to be consumable")
+	}
+}
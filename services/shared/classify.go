@@ -0,0 +1,145 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Error categories for the gateway error counter. Dashboards and alerts key
+// on these strings, so they are append-only: renaming one silently empties
+// the panels built on it.
+const (
+	// ErrorCategoryClient: the request itself was bad — malformed state,
+	// missing parameters, rejected tokens. Expected background noise; alert
+	// only on sudden rate changes.
+	ErrorCategoryClient = "client"
+	// ErrorCategoryConfig: this deployment is misconfigured — wrong
+	// credentials, unreachable URLs. Pages on first occurrence; these never
+	// fix themselves.
+	ErrorCategoryConfig = "config"
+	// ErrorCategoryDependency: an upstream failed — Document Server down,
+	// Drive or the auth service erroring. Alert on sustained rate; usually
+	// somebody else's outage.
+	ErrorCategoryDependency = "dependency"
+	// ErrorCategoryInternal: everything else, including panics. Always a
+	// bug; alert on any sustained occurrence.
+	ErrorCategoryInternal = "internal"
+)
+
+// errorCategoryHeader carries a handler's category annotation to the
+// classification middleware; it is stripped before the response leaves the
+// gateway.
+const errorCategoryHeader = "X-Error-Category"
+
+var gatewayErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "onlyoffice_gateway_errors_total",
+	Help: "Gateway error responses by category and route.",
+}, []string{"category", "route"})
+
+// ClassifyError annotates the response with an error category before its
+// status is written, overriding the status-based default. Handlers use it
+// where the status alone would mislead: a 403 caused by Drive rejecting our
+// token is a dependency problem, not a client one.
+func ClassifyError(rw http.ResponseWriter, category string) {
+	rw.Header().Set(errorCategoryHeader, category)
+}
+
+// classifyStatus maps a response status onto the default category. Anything
+// below 400 — including the consent redirects the session middleware
+// answers with — is not an error at all.
+func classifyStatus(status int) string {
+	switch {
+	case status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout:
+		return ErrorCategoryDependency
+	case status >= http.StatusInternalServerError:
+		return ErrorCategoryInternal
+	case status >= http.StatusBadRequest:
+		return ErrorCategoryClient
+	}
+	return ""
+}
+
+// ClassifyErrors counts the route's error responses into the gateway error
+// counter by category. It wraps registered routes only: the catch-all 404s
+// bots generate never reach it, so they cannot drown the user-impacting
+// signal. A panic is counted as internal and re-raised for the recoverer.
+func ClassifyErrors(route string) func(http.Handler) http.Handler {
+	return classifyErrors(route, func(category string) {
+		gatewayErrors.WithLabelValues(category, route).Inc()
+	})
+}
+
+func classifyErrors(route string, record func(category string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			recorder := &classifyRecorder{ResponseWriter: rw, status: http.StatusOK}
+			defer func() {
+				if rec := recover(); rec != nil {
+					record(ErrorCategoryInternal)
+					panic(rec)
+				}
+				category := recorder.category
+				if category == "" {
+					category = classifyStatus(recorder.status)
+				}
+				if category != "" {
+					record(category)
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// classifyRecorder captures the response status and consumes the category
+// annotation so it never reaches the client.
+type classifyRecorder struct {
+	http.ResponseWriter
+	status      int
+	category    string
+	wroteHeader bool
+}
+
+func (c *classifyRecorder) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	c.status = status
+	if category := c.Header().Get(errorCategoryHeader); category != "" {
+		c.category = category
+		c.Header().Del(errorCategoryHeader)
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// Write mirrors net/http's implicit 200 so an annotation set by a handler
+// that never calls WriteHeader is still consumed.
+func (c *classifyRecorder) Write(content []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(content)
+}
@@ -0,0 +1,155 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// classifyRequest runs the handler under the classification middleware and
+// returns the recorded categories alongside the response.
+func classifyRequest(handler http.HandlerFunc) ([]string, *httptest.ResponseRecorder) {
+	var recorded []string
+	rec := httptest.NewRecorder()
+	classifyErrors("/api/test", func(category string) {
+		recorded = append(recorded, category)
+	})(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/test", nil))
+	return recorded, rec
+}
+
+func TestClassifyRepresentativeFailures(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		handler  http.HandlerFunc
+		category string
+	}{
+		{
+			// A malformed launch state is the caller's fault.
+			name: "bad state",
+			handler: func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(http.StatusBadRequest)
+			},
+			category: ErrorCategoryClient,
+		},
+		{
+			// The DS-down page renders as a 200 and counts only because the
+			// handler annotated it.
+			name: "document server down",
+			handler: func(rw http.ResponseWriter, r *http.Request) {
+				ClassifyError(rw, ErrorCategoryDependency)
+				rw.Write([]byte("error page"))
+			},
+			category: ErrorCategoryDependency,
+		},
+		{
+			// Drive rejecting our token surfaces as a 403 to the caller but
+			// is an upstream failure; the annotation overrides the default.
+			name: "drive 403",
+			handler: func(rw http.ResponseWriter, r *http.Request) {
+				ClassifyError(rw, ErrorCategoryDependency)
+				rw.WriteHeader(http.StatusForbidden)
+			},
+			category: ErrorCategoryDependency,
+		},
+		{
+			name: "unannotated 500",
+			handler: func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(http.StatusInternalServerError)
+			},
+			category: ErrorCategoryInternal,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorded, rec := classifyRequest(tc.handler)
+			if len(recorded) != 1 || recorded[0] != tc.category {
+				t.Errorf("expected one %q error, got %v", tc.category, recorded)
+			}
+			if rec.Header().Get(errorCategoryHeader) != "" {
+				t.Error("expected the annotation header to be stripped from the response")
+			}
+		})
+	}
+}
+
+func TestClassifyIgnoresSuccessesAndRedirects(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "plain success",
+			handler: func(rw http.ResponseWriter, r *http.Request) {
+				rw.Write([]byte("ok"))
+			},
+		},
+		{
+			// The missing-cookie consent redirect is routine, not an error.
+			name: "auth redirect",
+			handler: func(rw http.ResponseWriter, r *http.Request) {
+				http.Redirect(rw, r, "/oauth/install", http.StatusSeeOther)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if recorded, _ := classifyRequest(tc.handler); len(recorded) != 0 {
+				t.Errorf("expected nothing recorded, got %v", recorded)
+			}
+		})
+	}
+}
+
+func TestClassifyCountsPanicsAsInternal(t *testing.T) {
+	var recorded []string
+	handler := classifyErrors("/api/test", func(category string) {
+		recorded = append(recorded, category)
+	})(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	defer func() {
+		// The panic must keep traveling so the router's recoverer still
+		// answers the request.
+		if recover() == nil {
+			t.Error("expected the panic to be re-raised")
+		}
+		if len(recorded) != 1 || recorded[0] != ErrorCategoryInternal {
+			t.Errorf("expected one internal error, got %v", recorded)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/test", nil))
+}
+
+func TestClassifyStatusDefaults(t *testing.T) {
+	for status, category := range map[int]string{
+		http.StatusOK:                  "",
+		http.StatusSeeOther:            "",
+		http.StatusBadRequest:          ErrorCategoryClient,
+		http.StatusForbidden:           ErrorCategoryClient,
+		http.StatusInternalServerError: ErrorCategoryInternal,
+		http.StatusBadGateway:          ErrorCategoryDependency,
+		http.StatusServiceUnavailable:  ErrorCategoryDependency,
+		http.StatusGatewayTimeout:      ErrorCategoryDependency,
+	} {
+		if got := classifyStatus(status); got != category {
+			t.Errorf("expected %d to classify as %q, got %q", status, category, got)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clock supplies the current time to code whose outputs outlive the
+// request: signed tokens, document keys, Drive properties. Constructors
+// default to SystemClock; tests swap in FixedClock so those artifacts come
+// out deterministic instead of carrying a fresh time.Now on every run.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the production Clock, backed by time.Now.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// FixedClock always reports the same instant. It lives outside the test
+// files because tests of several services inject it.
+type FixedClock struct {
+	Instant time.Time
+}
+
+func (c FixedClock) Now() time.Time { return c.Instant }
+
+// IDGenerator supplies unique ids for persisted artifacts such as
+// conversion request keys and job handles.
+type IDGenerator interface {
+	NewID() string
+}
+
+// UUIDGenerator is the production IDGenerator, backed by random UUIDs.
+type UUIDGenerator struct{}
+
+func (UUIDGenerator) NewID() string { return uuid.NewString() }
+
+// SequenceIDs hands out uuid-shaped ids in a predictable sequence, so
+// tests can assert on artifacts that embed an id. Callers slice generated
+// ids, so the shape must stay uuid-length.
+type SequenceIDs struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (s *SequenceIDs) NewID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", s.next)
+}
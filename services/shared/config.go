@@ -0,0 +1,761 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+	"gopkg.in/yaml.v2"
+)
+
+// OnlyofficeConfig is the integration-wide configuration shared by the
+// gateway, builder and callback services.
+type OnlyofficeConfig struct {
+	Onlyoffice struct {
+		Auth       OnlyofficeAuthConfig       `yaml:"auth"`
+		Builder    OnlyofficeBuilderConfig    `yaml:"builder"`
+		Callback   OnlyofficeCallbackConfig   `yaml:"callback"`
+		Conversion OnlyofficeConversionConfig `yaml:"conversion"`
+		CORS       OnlyofficeCORSConfig       `yaml:"cors"`
+		Features   OnlyofficeFeaturesConfig   `yaml:"features"`
+		Gateway    OnlyofficeGatewayConfig    `yaml:"gateway"`
+		History    OnlyofficeHistoryConfig    `yaml:"history"`
+		Internal   OnlyofficeInternalConfig   `yaml:"internal"`
+		Limits     OnlyofficeSizeLimitsConfig `yaml:"limits"`
+		Quota      OnlyofficeQuotaConfig      `yaml:"quota"`
+		Repl       OnlyofficeReplConfig       `yaml:"repl"`
+		Sessions   OnlyofficeSessionsConfig   `yaml:"sessions"`
+		Trace      OnlyofficeTraceConfig      `yaml:"trace"`
+	} `yaml:"onlyoffice"`
+}
+
+// OnlyofficeAuthConfig tunes the gateway's OAuth flow. Warmup
+// pre-establishes Drive connections in the background right after a
+// successful consent exchange, so the first document open does not pay for
+// TLS handshakes; WarmupTimeout (seconds) caps how long that may run.
+type OnlyofficeAuthConfig struct {
+	Warmup        bool `yaml:"warmup" env:"ONLYOFFICE_AUTH_WARMUP,overwrite"`
+	WarmupTimeout int  `yaml:"warmup_timeout" env:"ONLYOFFICE_AUTH_WARMUP_TIMEOUT,overwrite"`
+	// Encryption selects how the auth service encrypts stored tokens:
+	// "aes-gcm" (the default) binds each ciphertext to its user id,
+	// "legacy" keeps the adapters' encryptor for rollback.
+	Encryption string `yaml:"encryption" env:"ONLYOFFICE_AUTH_ENCRYPTION,overwrite"`
+	// AllowedDomains restricts sign-ins to the listed Workspace domains;
+	// an empty list keeps the integration open to any Google account.
+	AllowedDomains []string `yaml:"allowed_domains" env:"ONLYOFFICE_AUTH_ALLOWED_DOMAINS,overwrite"`
+	// CacheTTL (seconds) keeps selected user records in memory in front of
+	// the persistence backend; zero leaves every select on the backend.
+	CacheTTL int `yaml:"cache_ttl" env:"ONLYOFFICE_AUTH_CACHE_TTL,overwrite"`
+	// SessionLifetime (seconds) bounds each issued session cookie; a
+	// request arriving with less than SessionRenewalThreshold (seconds)
+	// of it remaining gets a renewed cookie, so active users stay signed
+	// in without the gateway re-minting on every request. SessionMaxAge
+	// (seconds) is the absolute cap: renewals never push a session past
+	// its first sign-in plus this, after which re-consent is forced. A
+	// zero threshold disables renewal; a zero max age leaves sliding
+	// sessions uncapped.
+	SessionLifetime         int `yaml:"session_lifetime" env:"ONLYOFFICE_AUTH_SESSION_LIFETIME,overwrite"`
+	SessionRenewalThreshold int `yaml:"session_renewal_threshold" env:"ONLYOFFICE_AUTH_SESSION_RENEWAL_THRESHOLD,overwrite"`
+	SessionMaxAge           int `yaml:"session_max_age" env:"ONLYOFFICE_AUTH_SESSION_MAX_AGE,overwrite"`
+}
+
+// SessionDuration returns the configured session lifetime, falling back to
+// the historical seven days when unset.
+func (c OnlyofficeAuthConfig) SessionDuration() time.Duration {
+	if c.SessionLifetime <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(c.SessionLifetime) * time.Second
+}
+
+// ValidateSession rejects session lifetime combinations under which the
+// gateway could never issue a usable cookie.
+func (c OnlyofficeAuthConfig) ValidateSession() error {
+	if c.SessionRenewalThreshold > 0 && c.SessionLifetime > 0 &&
+		c.SessionRenewalThreshold >= c.SessionLifetime {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Session renewal threshold",
+			Reason:    "Should be shorter than the session lifetime",
+		}
+	}
+	if c.SessionMaxAge > 0 && c.SessionMaxAge < c.SessionLifetime {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Session max age",
+			Reason:    "Should not be shorter than the session lifetime",
+		}
+	}
+	return nil
+}
+
+// Token encryption implementations the auth service can be configured with.
+const (
+	EncryptionAesGcm = "aes-gcm"
+	EncryptionLegacy = "legacy"
+)
+
+// ValidEncryption reports whether mode names a known token encryption
+// implementation; empty selects the default.
+func ValidEncryption(mode string) bool {
+	return mode == "" || mode == EncryptionAesGcm || mode == EncryptionLegacy
+}
+
+// OnlyofficeBuilderConfig carries Document Server connectivity settings and
+// the public URLs the builder embeds into editor configs.
+type OnlyofficeBuilderConfig struct {
+	DocumentServerURL    string `yaml:"document_server_url" env:"ONLYOFFICE_DS_URL,overwrite"`
+	DocumentServerSecret string `yaml:"document_server_secret" env:"ONLYOFFICE_DS_SECRET,overwrite"`
+	GatewayURL           string `yaml:"gateway_url" env:"ONLYOFFICE_GATEWAY_URL,overwrite"`
+	CallbackURL          string `yaml:"callback_url" env:"ONLYOFFICE_CALLBACK_URL,overwrite"`
+	AllowedDownloads     int    `yaml:"allowed_downloads" env:"ONLYOFFICE_ALLOWED_DOWNLOADS,overwrite"`
+	StrictGatewayCheck   bool   `yaml:"strict_gateway_check" env:"ONLYOFFICE_STRICT_GATEWAY_CHECK,overwrite"`
+	// EmailPreview lets .eml files open view-only by serving their
+	// extracted body HTML to the Document Server; off by default because
+	// the preview rewrites the bytes the editor receives.
+	EmailPreview bool `yaml:"email_preview" env:"ONLYOFFICE_EMAIL_PREVIEW,overwrite"`
+	// TemplateEdit opens the OOXML template formats (dotx, xltx, potx)
+	// directly in the editor instead of the conversion page; off by default
+	// because editing them in place needs a Document Server new enough to
+	// list templates among its editable formats.
+	TemplateEdit bool `yaml:"template_edit" env:"ONLYOFFICE_TEMPLATE_EDIT,overwrite"`
+	// Strict Document Server configurations stamp issuer/audience claims
+	// into their tokens, and some installs run with a few seconds of clock
+	// skew. All three settings are permissive when unset.
+	DocumentServerAudience string   `yaml:"document_server_audience" env:"ONLYOFFICE_DS_AUDIENCE,overwrite"`
+	DocumentServerIssuers  []string `yaml:"document_server_issuers" env:"ONLYOFFICE_DS_ISSUERS,overwrite"`
+	DocumentServerLeeway   int      `yaml:"document_server_leeway" env:"ONLYOFFICE_DS_LEEWAY,overwrite"`
+	// DisableGoback removes the editor's back-to-Drive button entirely;
+	// GobackURL replaces the computed folder URL with a template whose
+	// {folder_id} placeholder receives the parent folder id.
+	DisableGoback bool                        `yaml:"disable_goback" env:"ONLYOFFICE_DISABLE_GOBACK,overwrite"`
+	GobackURL     string                      `yaml:"goback_url" env:"ONLYOFFICE_GOBACK_URL,overwrite"`
+	Permissions   OnlyofficePermissionsConfig `yaml:"permissions"`
+	Watermark     OnlyofficeWatermarkConfig   `yaml:"watermark"`
+	CoEditing     OnlyofficeCoEditingConfig   `yaml:"coediting"`
+	// MobileEditable overrides, per format family ("word", "cell", "slide",
+	// "form"), whether the Document Server's mobile editor may edit the
+	// family. Unlisted families keep the defaults from the Document Server
+	// documentation, under which forms stay view-only on mobile.
+	MobileEditable map[string]bool `yaml:"mobile_editable"`
+	// DocumentServers names alternate Document Server instances; Routing
+	// sends files carrying a matching private Drive property to one of
+	// them, e.g. documents tagged "confidential" to an on-prem install.
+	// Files without a matching property stay on the default instance.
+	DocumentServers map[string]OnlyofficeDocumentServerConfig `yaml:"document_servers"`
+	Routing         []OnlyofficeRoutingRule                   `yaml:"routing"`
+}
+
+// OnlyofficeDocumentServerConfig describes one named alternate Document
+// Server instance. An empty secret inherits the default instance's secret.
+type OnlyofficeDocumentServerConfig struct {
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret"`
+}
+
+// OnlyofficeRoutingRule sends files whose private Drive property matches
+// to a named Document Server instance. An empty value matches any value
+// of the property.
+type OnlyofficeRoutingRule struct {
+	Property string `yaml:"property"`
+	Value    string `yaml:"value"`
+	Server   string `yaml:"server"`
+}
+
+// ValidateRouting rejects routing configuration that could never apply: an
+// instance without a URL, a rule without a property, or a rule referencing
+// an instance that is not in the table. A rule that silently never routes
+// is worse than a startup failure for the documents it was meant to fence.
+func (b OnlyofficeBuilderConfig) ValidateRouting() error {
+	for name, server := range b.DocumentServers {
+		if server.URL == "" {
+			return &InvalidConfigurationParameterError{
+				Parameter: "Document servers",
+				Reason:    fmt.Sprintf("Instance %q should carry a url", name),
+			}
+		}
+	}
+
+	for _, rule := range b.Routing {
+		if rule.Property == "" {
+			return &InvalidConfigurationParameterError{
+				Parameter: "Routing",
+				Reason:    "Rules should name a file property",
+			}
+		}
+		if _, ok := b.DocumentServers[rule.Server]; !ok {
+			return &InvalidConfigurationParameterError{
+				Parameter: "Routing",
+				Reason:    fmt.Sprintf("Rule for property %q references unknown document server %q", rule.Property, rule.Server),
+			}
+		}
+	}
+	return nil
+}
+
+// RouteDocumentServer resolves the Document Server for a file from its
+// private Drive properties; the first matching rule wins. Files without a
+// matching property use the default instance, so a fetch that came back
+// without properties degrades to the default rather than failing the open.
+func (b OnlyofficeBuilderConfig) RouteDocumentServer(properties map[string]string) (string, string) {
+	for _, rule := range b.Routing {
+		value, ok := properties[rule.Property]
+		if !ok || (rule.Value != "" && value != rule.Value) {
+			continue
+		}
+
+		server := b.DocumentServers[rule.Server]
+		secret := server.Secret
+		if secret == "" {
+			secret = b.DocumentServerSecret
+		}
+		return server.URL, secret
+	}
+	return b.DocumentServerURL, b.DocumentServerSecret
+}
+
+// Format families a mobile capability may be configured for: the three
+// Document Server documentTypes plus forms, which are a family of their own
+// because their editing UI differs from plain word documents.
+var mobileFormatFamilies = map[string]bool{
+	"word":  true,
+	"cell":  true,
+	"slide": true,
+	"form":  true,
+}
+
+// defaultMobileEditable is the capability table from the Document Server
+// documentation: the mobile editor edits documents, spreadsheets and
+// presentations, but offers no form-filling UI.
+var defaultMobileEditable = map[string]bool{
+	"word":  true,
+	"cell":  true,
+	"slide": true,
+	"form":  false,
+}
+
+// ValidateMobileEditable rejects capability overrides for families that do
+// not exist, so a typo in the table fails at startup rather than silently
+// keeping the default.
+func (b OnlyofficeBuilderConfig) ValidateMobileEditable() error {
+	for family := range b.MobileEditable {
+		if !mobileFormatFamilies[family] {
+			return &InvalidConfigurationParameterError{
+				Parameter: "Mobile editable",
+				Reason:    fmt.Sprintf("Unknown format family %q, expected word, cell, slide or form", family),
+			}
+		}
+	}
+	return nil
+}
+
+// MobileEditableFamily reports whether the Document Server's mobile editor
+// may edit the format family, honoring a configured override and falling
+// back to the documented capabilities.
+func (b OnlyofficeBuilderConfig) MobileEditableFamily(family string) bool {
+	if editable, ok := b.MobileEditable[family]; ok {
+		return editable
+	}
+	return defaultMobileEditable[family]
+}
+
+// Co-editing modes the Document Server supports. Fast merges changes in
+// real time; strict shows other users' changes only after they save. The
+// empty string leaves the Document Server default (fast) in place.
+const (
+	CoEditingFast   = "fast"
+	CoEditingStrict = "strict"
+)
+
+// OnlyofficeCoEditingConfig pins the co-editing mode per deployment, e.g.
+// strict for regulated documents. Change lets users switch the mode from
+// inside the editor; it is off by default so a pinned mode stays pinned.
+type OnlyofficeCoEditingConfig struct {
+	Mode   string `yaml:"mode" env:"ONLYOFFICE_COEDITING_MODE,overwrite"`
+	Change bool   `yaml:"change" env:"ONLYOFFICE_COEDITING_CHANGE,overwrite"`
+}
+
+// ValidCoEditingMode reports whether mode is a co-editing mode the Document
+// Server understands; the empty string means "no preference".
+func ValidCoEditingMode(mode string) bool {
+	return mode == "" || mode == CoEditingFast || mode == CoEditingStrict
+}
+
+// OnlyofficeWatermarkConfig draws an on-screen watermark over documents
+// opened view-only. The template may reference {user_name}, {user_email}
+// and {date}; Transparent is the 0-1 opacity of the drawn text.
+type OnlyofficeWatermarkConfig struct {
+	Enabled     bool    `yaml:"enabled" env:"ONLYOFFICE_WATERMARK_ENABLED,overwrite"`
+	Template    string  `yaml:"template" env:"ONLYOFFICE_WATERMARK_TEMPLATE,overwrite"`
+	FontFamily  string  `yaml:"font_family" env:"ONLYOFFICE_WATERMARK_FONT_FAMILY,overwrite"`
+	FontSize    int     `yaml:"font_size" env:"ONLYOFFICE_WATERMARK_FONT_SIZE,overwrite"`
+	Rotate      int     `yaml:"rotate" env:"ONLYOFFICE_WATERMARK_ROTATE,overwrite"`
+	Transparent float64 `yaml:"transparent" env:"ONLYOFFICE_WATERMARK_TRANSPARENT,overwrite"`
+}
+
+// Permission policies a deployment may pin per editor capability. The empty
+// string is equivalent to PermissionDrive.
+const (
+	PermissionDrive = "drive"
+	PermissionAllow = "allow"
+	PermissionDeny  = "deny"
+)
+
+// OnlyofficePermissionsConfig pins editor permissions per deployment
+// instead of deriving them from Drive capabilities alone. Each field takes
+// "allow", "deny" or "drive" (the default): deny always wins, allow wins
+// over the derived default but never over a restriction Drive itself
+// imposes, and drive keeps the capability-derived value.
+type OnlyofficePermissionsConfig struct {
+	Comment  string `yaml:"comment" env:"ONLYOFFICE_PERMISSIONS_COMMENT,overwrite"`
+	Copy     string `yaml:"copy" env:"ONLYOFFICE_PERMISSIONS_COPY,overwrite"`
+	Download string `yaml:"download" env:"ONLYOFFICE_PERMISSIONS_DOWNLOAD,overwrite"`
+	Edit     string `yaml:"edit" env:"ONLYOFFICE_PERMISSIONS_EDIT,overwrite"`
+	Print    string `yaml:"print" env:"ONLYOFFICE_PERMISSIONS_PRINT,overwrite"`
+	Review   string `yaml:"review" env:"ONLYOFFICE_PERMISSIONS_REVIEW,overwrite"`
+}
+
+// Validate rejects unknown policy values and combinations that contradict
+// each other, so a misconfigured deployment fails at startup rather than
+// handing out surprising editor configs.
+func (c OnlyofficePermissionsConfig) Validate() error {
+	for name, policy := range map[string]string{
+		"comment":  c.Comment,
+		"copy":     c.Copy,
+		"download": c.Download,
+		"edit":     c.Edit,
+		"print":    c.Print,
+		"review":   c.Review,
+	} {
+		switch policy {
+		case "", PermissionDrive, PermissionAllow, PermissionDeny:
+		default:
+			return &InvalidConfigurationParameterError{
+				Parameter: fmt.Sprintf("Permissions %s", name),
+				Reason:    fmt.Sprintf("Unknown policy %q, expected drive, allow or deny", policy),
+			}
+		}
+	}
+
+	if c.Review == PermissionAllow && c.Edit == PermissionDeny {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Permissions review",
+			Reason:    "Cannot be allowed while editing is denied",
+		}
+	}
+
+	if c.Print == PermissionAllow && c.Download == PermissionDeny {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Permissions print",
+			Reason:    "Cannot be allowed while downloads are denied",
+		}
+	}
+
+	return nil
+}
+
+// OnlyofficeConversionConfig bounds the gateway conversion pipeline.
+// CacheTTL is how long (in seconds) a converted copy is remembered for an
+// unchanged source revision.
+type OnlyofficeConversionConfig struct {
+	CacheTTL int `yaml:"cache_ttl" env:"ONLYOFFICE_CONVERSION_CACHE_TTL,overwrite"`
+	// Parallelism bounds how many files of one multi-select batch convert
+	// concurrently; non-positive values fall back to the built-in default.
+	Parallelism int `yaml:"parallelism" env:"ONLYOFFICE_CONVERSION_PARALLELISM,overwrite"`
+	// Fallbacks maps a source extension to the alternate output format one
+	// retry converts to when the Document Server rejects the primary
+	// conversion with one of FallbackErrorCodes, e.g. mht: pdf.
+	Fallbacks map[string]string `yaml:"fallbacks"`
+	// FallbackErrorCodes lists the Document Server conversion error codes
+	// that trigger the fallback retry; empty disables it entirely.
+	FallbackErrorCodes []int `yaml:"fallback_error_codes" env:"ONLYOFFICE_CONVERSION_FALLBACK_ERROR_CODES,overwrite"`
+}
+
+// conversionFallbackOutputs are the output formats a fallback may target:
+// the OOXML formats the pipeline produces anyway, plus pdf for sources
+// that survive no editable route.
+var conversionFallbackOutputs = map[string]bool{
+	"docx": true,
+	"xlsx": true,
+	"pptx": true,
+	"pdf":  true,
+}
+
+// Validate rejects fallback strategies the pipeline could not act on, so a
+// typo in the table fails at startup rather than on the first rejected
+// conversion.
+func (c OnlyofficeConversionConfig) Validate() error {
+	for source, output := range c.Fallbacks {
+		if source == "" {
+			return &InvalidConfigurationParameterError{
+				Parameter: "Conversion fallbacks",
+				Reason:    "Source extension should not be empty",
+			}
+		}
+		if !conversionFallbackOutputs[output] {
+			return &InvalidConfigurationParameterError{
+				Parameter: fmt.Sprintf("Conversion fallback for %s", source),
+				Reason:    fmt.Sprintf("Unknown output format %q, expected docx, xlsx, pptx or pdf", output),
+			}
+		}
+	}
+	return nil
+}
+
+// FallbackOutput returns the alternate output format configured for the
+// source extension when the Document Server error code is one of the
+// fallback triggers.
+func (c OnlyofficeConversionConfig) FallbackOutput(ext string, code int) (string, bool) {
+	triggered := false
+	for _, candidate := range c.FallbackErrorCodes {
+		if candidate == code {
+			triggered = true
+			break
+		}
+	}
+	if !triggered {
+		return "", false
+	}
+	output, ok := c.Fallbacks[ext]
+	return output, ok
+}
+
+// OnlyofficeCORSConfig lists partner origins allowed to call the picker
+// endpoints and iframe the embedded viewer.
+type OnlyofficeCORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins" env:"ONLYOFFICE_CORS_ORIGINS,overwrite"`
+}
+
+// OnlyofficeGatewayConfig carries gateway-only toggles. OpenAPI serves the
+// machine-readable API description at /api/openapi.json; it is off by
+// default because the document enumerates every endpoint of the deployment.
+// DrainDelay (seconds) is how long a stopping pod keeps serving after
+// deregistering, so requests the load balancer already routed still land;
+// ShutdownTimeout (seconds) caps the graceful stop.
+type OnlyofficeGatewayConfig struct {
+	OpenAPI         bool `yaml:"openapi" env:"ONLYOFFICE_GATEWAY_OPENAPI,overwrite"`
+	DrainDelay      int  `yaml:"drain_delay" env:"ONLYOFFICE_GATEWAY_DRAIN_DELAY,overwrite"`
+	ShutdownTimeout int  `yaml:"shutdown_timeout" env:"ONLYOFFICE_GATEWAY_SHUTDOWN_TIMEOUT,overwrite"`
+	// DisableDownloadSessionCheck turns off the requirement that a download
+	// token belongs to an active editing flow; a debugging escape hatch, not
+	// for production use.
+	DisableDownloadSessionCheck bool `yaml:"disable_download_session_check" env:"ONLYOFFICE_GATEWAY_DISABLE_DOWNLOAD_SESSION_CHECK,overwrite"`
+	// ServerSessions moves session payloads server side, leaving only an
+	// opaque handle in the browser cookie. Off by default: the handles do
+	// not survive a gateway restart, which costs every user a re-login.
+	ServerSessions bool `yaml:"server_sessions" env:"ONLYOFFICE_GATEWAY_SERVER_SESSIONS,overwrite"`
+	// PageBudget (seconds) bounds everything a protected page does before
+	// its handler runs: the auth RPC, the Drive metadata fetch and the
+	// Userinfo call. The per-phase timeouts below default to slices of it
+	// and only need setting when one phase deserves a different bound.
+	PageBudget      int `yaml:"page_budget" env:"ONLYOFFICE_GATEWAY_PAGE_BUDGET,overwrite"`
+	AuthTimeout     int `yaml:"auth_timeout" env:"ONLYOFFICE_GATEWAY_AUTH_TIMEOUT,overwrite"`
+	MetadataTimeout int `yaml:"metadata_timeout" env:"ONLYOFFICE_GATEWAY_METADATA_TIMEOUT,overwrite"`
+	UserinfoTimeout int `yaml:"userinfo_timeout" env:"ONLYOFFICE_GATEWAY_USERINFO_TIMEOUT,overwrite"`
+}
+
+// PageBudgetDuration is the overall session-resolution deadline, fifteen
+// seconds unless configured.
+func (c OnlyofficeGatewayConfig) PageBudgetDuration() time.Duration {
+	if c.PageBudget > 0 {
+		return time.Duration(c.PageBudget) * time.Second
+	}
+	return 15 * time.Second
+}
+
+// AuthTimeoutDuration bounds the auth-service RPC; by default a third of
+// the page budget, leaving the fan-out to Google the larger share.
+func (c OnlyofficeGatewayConfig) AuthTimeoutDuration() time.Duration {
+	if c.AuthTimeout > 0 {
+		return time.Duration(c.AuthTimeout) * time.Second
+	}
+	return c.PageBudgetDuration() / 3
+}
+
+// MetadataTimeoutDuration bounds the Drive metadata fetch; by default the
+// page budget itself, since the fan-out already runs inside it.
+func (c OnlyofficeGatewayConfig) MetadataTimeoutDuration() time.Duration {
+	if c.MetadataTimeout > 0 {
+		return time.Duration(c.MetadataTimeout) * time.Second
+	}
+	return c.PageBudgetDuration()
+}
+
+// UserinfoTimeoutDuration bounds the Userinfo call, defaulting like the
+// metadata fetch.
+func (c OnlyofficeGatewayConfig) UserinfoTimeoutDuration() time.Duration {
+	if c.UserinfoTimeout > 0 {
+		return time.Duration(c.UserinfoTimeout) * time.Second
+	}
+	return c.PageBudgetDuration()
+}
+
+// OnlyofficeHistoryConfig controls whether save callbacks' changes data is
+// kept as sidecar JSON in the user's appDataFolder and how many records
+// are retained per file.
+type OnlyofficeHistoryConfig struct {
+	Enabled   bool `yaml:"enabled" env:"ONLYOFFICE_HISTORY_ENABLED,overwrite"`
+	Retention int  `yaml:"retention" env:"ONLYOFFICE_HISTORY_RETENTION,overwrite"`
+}
+
+// OnlyofficeInternalConfig carries the shared secret service-to-service
+// requests are signed with. SecondarySecret is also accepted during
+// verification so the secret can be rotated without restarting all
+// services at once. ReplayWindow is the accepted timestamp skew in seconds.
+type OnlyofficeInternalConfig struct {
+	Secret          string `yaml:"secret" env:"ONLYOFFICE_INTERNAL_SECRET,overwrite"`
+	SecondarySecret string `yaml:"secondary_secret" env:"ONLYOFFICE_INTERNAL_SECONDARY_SECRET,overwrite"`
+	ReplayWindow    int    `yaml:"replay_window" env:"ONLYOFFICE_INTERNAL_REPLAY_WINDOW,overwrite"`
+}
+
+// Secrets lists the non-empty accepted secrets, primary first.
+func (c OnlyofficeInternalConfig) Secrets() []string {
+	var secrets []string
+	for _, secret := range []string{c.Secret, c.SecondarySecret} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// OnlyofficeQuotaConfig bounds Google API consumption. Non-interactive
+// calls are delayed once the per-minute call count passes
+// threshold*limit_per_minute. A zero limit disables throttling.
+type OnlyofficeQuotaConfig struct {
+	LimitPerMinute int     `yaml:"limit_per_minute" env:"ONLYOFFICE_DRIVE_QUOTA_LIMIT,overwrite"`
+	Threshold      float64 `yaml:"threshold" env:"ONLYOFFICE_DRIVE_QUOTA_THRESHOLD,overwrite"`
+}
+
+// OnlyofficeReplConfig governs the operational listener next to each
+// service's rpc traffic. Pprof stays off unless explicitly enabled here —
+// a debug build alone does not turn it on — and a configured token puts
+// every debug endpoint behind bearer authentication. Address moves the
+// listener to its own interface, e.g. a loopback or a maintenance network,
+// while metrics scraping can keep the default.
+type OnlyofficeReplConfig struct {
+	Address   string `yaml:"address" env:"ONLYOFFICE_REPL_ADDRESS,overwrite"`
+	Pprof     bool   `yaml:"pprof" env:"ONLYOFFICE_REPL_PPROF,overwrite"`
+	AuthToken string `yaml:"auth_token" env:"ONLYOFFICE_REPL_AUTH_TOKEN,overwrite"`
+}
+
+// OnlyofficeSessionsConfig caps concurrent editing per user.
+// MaxOpenDocuments of zero disables the cap; TTL is how long (in seconds)
+// an untouched session keeps counting against it.
+type OnlyofficeSessionsConfig struct {
+	MaxOpenDocuments int `yaml:"max_open_documents" env:"ONLYOFFICE_SESSIONS_MAX_OPEN,overwrite"`
+	TTL              int `yaml:"ttl" env:"ONLYOFFICE_SESSIONS_TTL,overwrite"`
+}
+
+// OnlyofficeCallbackConfig bounds the callback service upload pipeline.
+// Notifications posts a Drive comment with a signed retry link when a
+// background upload is finally dropped; it is disabled by default because it
+// writes user-visible content.
+type OnlyofficeCallbackConfig struct {
+	MaxSize       int64 `yaml:"max_size" env:"ONLYOFFICE_CALLBACK_MAX_SIZE,overwrite"`
+	UploadTimeout int   `yaml:"upload_timeout" env:"ONLYOFFICE_CALLBACK_UPLOAD_TIMEOUT,overwrite"`
+	Notifications bool  `yaml:"notifications" env:"ONLYOFFICE_CALLBACK_NOTIFICATIONS,overwrite"`
+	// MaxRetries caps how many failed upload attempts one document key may
+	// accumulate within RetryWindow (seconds) before further save callbacks
+	// for it are short-circuited; zero disables the cap.
+	MaxRetries  int `yaml:"max_retries" env:"ONLYOFFICE_CALLBACK_MAX_RETRIES,overwrite"`
+	RetryWindow int `yaml:"retry_window" env:"ONLYOFFICE_CALLBACK_RETRY_WINDOW,overwrite"`
+}
+
+func (oc *OnlyofficeConfig) Validate() error {
+	if oc.Onlyoffice.Builder.DocumentServerURL == "" {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Document server url",
+			Reason:    "Should not be empty",
+		}
+	}
+
+	if _, err := url.Parse(oc.Onlyoffice.Builder.DocumentServerURL); err != nil {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Document server url",
+			Reason:    err.Error(),
+		}
+	}
+
+	if oc.Onlyoffice.Builder.GatewayURL == "" {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Gateway url",
+			Reason:    "Should not be empty",
+		}
+	}
+
+	if oc.Onlyoffice.Builder.CallbackURL == "" {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Callback url",
+			Reason:    "Should not be empty",
+		}
+	}
+
+	if !ValidCoEditingMode(oc.Onlyoffice.Builder.CoEditing.Mode) {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Coediting mode",
+			Reason:    "Should be fast, strict or empty",
+		}
+	}
+
+	if !ValidEncryption(oc.Onlyoffice.Auth.Encryption) {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Auth encryption",
+			Reason:    "Should be aes-gcm, legacy or empty",
+		}
+	}
+
+	if err := oc.Onlyoffice.Auth.ValidateSession(); err != nil {
+		return err
+	}
+
+	if err := oc.Onlyoffice.Builder.ValidateRouting(); err != nil {
+		return err
+	}
+
+	if err := oc.Onlyoffice.Builder.ValidateMobileEditable(); err != nil {
+		return err
+	}
+
+	if err := oc.Onlyoffice.Conversion.Validate(); err != nil {
+		return err
+	}
+
+	return oc.Onlyoffice.Builder.Permissions.Validate()
+}
+
+// CredentialsConfig carries the Google OAuth application credentials shared
+// by the gateway and auth services.
+type CredentialsConfig struct {
+	Credentials struct {
+		ClientID     string `yaml:"client_id" env:"GOOGLE_CLIENT_ID,overwrite"`
+		ClientSecret string `yaml:"client_secret" env:"GOOGLE_CLIENT_SECRET,overwrite"`
+		RedirectURI  string `yaml:"redirect_uri" env:"GOOGLE_REDIRECT_URI,overwrite"`
+		// RedirectURIs lists the redirect endpoints of every deployment
+		// sharing this Google client; ResolveRedirectURI picks the one
+		// matching the gateway at startup.
+		RedirectURIs []string `yaml:"redirect_uris" env:"GOOGLE_REDIRECT_URIS,overwrite"`
+		// RedirectURIIndex pins the pick by position instead of host
+		// matching; negative (the default) leaves the choice to the match.
+		RedirectURIIndex int `yaml:"redirect_uri_index" env:"GOOGLE_REDIRECT_URI_INDEX,overwrite"`
+		// APIKey authorizes key-only Drive access to files shared "anyone
+		// with the link can view"; empty disables the anonymous view path.
+		APIKey string `yaml:"api_key" env:"GOOGLE_API_KEY,overwrite"`
+	} `yaml:"credentials"`
+}
+
+func (cc *CredentialsConfig) Validate() error {
+	if cc.Credentials.ClientID == "" {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Client id",
+			Reason:    "Should not be empty",
+		}
+	}
+
+	if cc.Credentials.ClientSecret == "" {
+		return &InvalidConfigurationParameterError{
+			Parameter: "Client secret",
+			Reason:    "Should not be empty",
+		}
+	}
+
+	return nil
+}
+
+// BuildNewCredentialsConfig reads the yaml configuration at path, overlays
+// environment variables and validates the result.
+func BuildNewCredentialsConfig(path string) func() (*CredentialsConfig, error) {
+	return func() (*CredentialsConfig, error) {
+		var config CredentialsConfig
+		// Only an explicit index pins the redirect pick; zero must not
+		// silently mean "the first entry".
+		config.Credentials.RedirectURIIndex = -1
+		if path != "" {
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close()
+			decoder := yaml.NewDecoder(file)
+			if err := decoder.Decode(&config); err != nil {
+				return nil, err
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+		defer cancel()
+		if err := envconfig.Process(ctx, &config); err != nil {
+			return nil, err
+		}
+
+		return &config, config.Validate()
+	}
+}
+
+// BuildNewOnlyofficeConfig reads the yaml configuration at path, overlays
+// environment variables and validates the result. The path may be empty, in
+// which case only environment variables are consulted.
+func BuildNewOnlyofficeConfig(path string) func() (*OnlyofficeConfig, error) {
+	return func() (*OnlyofficeConfig, error) {
+		var config OnlyofficeConfig
+		config.Onlyoffice.Auth.SessionLifetime = 604800
+		config.Onlyoffice.Auth.SessionRenewalThreshold = 86400
+		config.Onlyoffice.Auth.SessionMaxAge = 2592000
+		config.Onlyoffice.Builder.Watermark.Template = "{user_email} {date}"
+		config.Onlyoffice.Builder.Watermark.FontFamily = "Arial"
+		config.Onlyoffice.Builder.Watermark.FontSize = 24
+		config.Onlyoffice.Builder.Watermark.Rotate = -45
+		config.Onlyoffice.Builder.Watermark.Transparent = 0.3
+		config.Onlyoffice.Callback.MaxSize = 210000000
+		config.Onlyoffice.Callback.UploadTimeout = 120
+		config.Onlyoffice.Callback.MaxRetries = 5
+		config.Onlyoffice.Callback.RetryWindow = 600
+		config.Onlyoffice.Conversion.CacheTTL = 86400
+		config.Onlyoffice.History.Retention = 10
+		config.Onlyoffice.Internal.ReplayWindow = 30
+		config.Onlyoffice.Limits.Word = 110000000
+		config.Onlyoffice.Limits.Cell = 30000000
+		config.Onlyoffice.Limits.Slide = 110000000
+		config.Onlyoffice.Limits.PDF = 210000000
+		config.Onlyoffice.Quota.Threshold = 0.8
+		config.Onlyoffice.Sessions.TTL = 1800
+		if path != "" {
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close()
+			decoder := yaml.NewDecoder(file)
+			if err := decoder.Decode(&config); err != nil {
+				return nil, err
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+		defer cancel()
+		if err := envconfig.Process(ctx, &config); err != nil {
+			return nil, err
+		}
+
+		return &config, config.Validate()
+	}
+}
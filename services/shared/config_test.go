@@ -0,0 +1,282 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import "testing"
+
+func TestPermissionsConfigValidate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config OnlyofficePermissionsConfig
+		valid  bool
+	}{
+		"empty defaults": {
+			config: OnlyofficePermissionsConfig{},
+			valid:  true,
+		},
+		"explicit policies": {
+			config: OnlyofficePermissionsConfig{
+				Comment: PermissionAllow,
+				Print:   PermissionDeny,
+				Review:  PermissionDrive,
+			},
+			valid: true,
+		},
+		"unknown policy": {
+			config: OnlyofficePermissionsConfig{Edit: "maybe"},
+			valid:  false,
+		},
+		"review allowed while edit denied": {
+			config: OnlyofficePermissionsConfig{
+				Edit:   PermissionDeny,
+				Review: PermissionAllow,
+			},
+			valid: false,
+		},
+		"print allowed while download denied": {
+			config: OnlyofficePermissionsConfig{
+				Download: PermissionDeny,
+				Print:    PermissionAllow,
+			},
+			valid: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.valid && err != nil {
+				t.Fatalf("expected the configuration to validate: %s", err.Error())
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected a validation error")
+			}
+		})
+	}
+}
+
+func TestConversionConfigValidate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config OnlyofficeConversionConfig
+		valid  bool
+	}{
+		"empty defaults": {
+			config: OnlyofficeConversionConfig{},
+			valid:  true,
+		},
+		"known output formats": {
+			config: OnlyofficeConversionConfig{
+				Fallbacks:          map[string]string{"mht": "pdf", "doc": "docx"},
+				FallbackErrorCodes: []int{-3},
+			},
+			valid: true,
+		},
+		"unknown output format": {
+			config: OnlyofficeConversionConfig{
+				Fallbacks: map[string]string{"mht": "html"},
+			},
+			valid: false,
+		},
+		"empty source extension": {
+			config: OnlyofficeConversionConfig{
+				Fallbacks: map[string]string{"": "pdf"},
+			},
+			valid: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.valid && err != nil {
+				t.Fatalf("expected the configuration to validate: %s", err.Error())
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected a validation error")
+			}
+		})
+	}
+}
+
+func TestConversionFallbackOutput(t *testing.T) {
+	config := OnlyofficeConversionConfig{
+		Fallbacks:          map[string]string{"mht": "pdf"},
+		FallbackErrorCodes: []int{-3},
+	}
+
+	if output, ok := config.FallbackOutput("mht", -3); !ok || output != "pdf" {
+		t.Errorf("expected the configured fallback, got %q, %t", output, ok)
+	}
+	if _, ok := config.FallbackOutput("mht", -2); ok {
+		t.Error("expected an unlisted error code to trigger nothing")
+	}
+	if _, ok := config.FallbackOutput("xls", -3); ok {
+		t.Error("expected an unmapped extension to trigger nothing")
+	}
+}
+
+func TestRoutingValidation(t *testing.T) {
+	servers := map[string]OnlyofficeDocumentServerConfig{
+		"onprem": {URL: "https://ds.internal.example.com", Secret: "onprem-secret"},
+	}
+
+	for name, tc := range map[string]struct {
+		config OnlyofficeBuilderConfig
+		valid  bool
+	}{
+		"no routing": {
+			config: OnlyofficeBuilderConfig{},
+			valid:  true,
+		},
+		"rule with a known instance": {
+			config: OnlyofficeBuilderConfig{
+				DocumentServers: servers,
+				Routing: []OnlyofficeRoutingRule{
+					{Property: "classification", Value: "confidential", Server: "onprem"},
+				},
+			},
+			valid: true,
+		},
+		"rule referencing an unknown instance": {
+			config: OnlyofficeBuilderConfig{
+				DocumentServers: servers,
+				Routing: []OnlyofficeRoutingRule{
+					{Property: "classification", Server: "onperm"},
+				},
+			},
+			valid: false,
+		},
+		"rule without a property": {
+			config: OnlyofficeBuilderConfig{
+				DocumentServers: servers,
+				Routing:         []OnlyofficeRoutingRule{{Server: "onprem"}},
+			},
+			valid: false,
+		},
+		"instance without a url": {
+			config: OnlyofficeBuilderConfig{
+				DocumentServers: map[string]OnlyofficeDocumentServerConfig{"onprem": {}},
+			},
+			valid: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.config.ValidateRouting()
+			if tc.valid && err != nil {
+				t.Fatalf("expected the routing to validate: %s", err.Error())
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected a validation error")
+			}
+		})
+	}
+}
+
+func TestMobileEditable(t *testing.T) {
+	valid := OnlyofficeBuilderConfig{
+		MobileEditable: map[string]bool{"form": true, "slide": false},
+	}
+	if err := valid.ValidateMobileEditable(); err != nil {
+		t.Fatalf("expected the overrides to validate: %s", err.Error())
+	}
+
+	invalid := OnlyofficeBuilderConfig{MobileEditable: map[string]bool{"forms": true}}
+	if err := invalid.ValidateMobileEditable(); err == nil {
+		t.Fatal("expected an unknown family to be rejected")
+	}
+
+	// Overrides win where present; the documented defaults cover the rest.
+	if !valid.MobileEditableFamily("form") || valid.MobileEditableFamily("slide") {
+		t.Error("expected the overrides to decide form and slide")
+	}
+	if !valid.MobileEditableFamily("word") || valid.MobileEditableFamily("unknown") {
+		t.Error("expected the defaults to decide the unlisted families")
+	}
+}
+
+func TestSessionConfigValidate(t *testing.T) {
+	valid := OnlyofficeAuthConfig{
+		SessionLifetime:         3600,
+		SessionRenewalThreshold: 600,
+		SessionMaxAge:           86400,
+	}
+	if err := valid.ValidateSession(); err != nil {
+		t.Fatalf("expected the session settings to validate: %s", err.Error())
+	}
+
+	// A threshold at or past the lifetime would renew on every request.
+	overlapping := OnlyofficeAuthConfig{SessionLifetime: 600, SessionRenewalThreshold: 600}
+	if err := overlapping.ValidateSession(); err == nil {
+		t.Fatal("expected a threshold matching the lifetime to be rejected")
+	}
+
+	// An absolute cap shorter than one lifetime could never be honored.
+	capped := OnlyofficeAuthConfig{SessionLifetime: 3600, SessionMaxAge: 600}
+	if err := capped.ValidateSession(); err == nil {
+		t.Fatal("expected a max age below the lifetime to be rejected")
+	}
+
+	// Zeroes disable renewal and the cap rather than failing startup.
+	if err := (OnlyofficeAuthConfig{}).ValidateSession(); err != nil {
+		t.Fatalf("expected the zero config to validate: %s", err.Error())
+	}
+}
+
+func TestRouteDocumentServer(t *testing.T) {
+	config := OnlyofficeBuilderConfig{
+		DocumentServerURL:    "https://ds.example.com",
+		DocumentServerSecret: "default-secret",
+		DocumentServers: map[string]OnlyofficeDocumentServerConfig{
+			"onprem": {URL: "https://ds.internal.example.com", Secret: "onprem-secret"},
+			"mirror": {URL: "https://ds-mirror.example.com"},
+		},
+		Routing: []OnlyofficeRoutingRule{
+			{Property: "classification", Value: "confidential", Server: "onprem"},
+			{Property: "pinned_ds", Server: "mirror"},
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		properties map[string]string
+		url        string
+		secret     string
+	}{
+		"matching property and value": {
+			properties: map[string]string{"classification": "confidential"},
+			url:        "https://ds.internal.example.com",
+			secret:     "onprem-secret",
+		},
+		"matching property, wrong value": {
+			properties: map[string]string{"classification": "internal"},
+			url:        "https://ds.example.com",
+			secret:     "default-secret",
+		},
+		"any-value rule inherits the default secret": {
+			properties: map[string]string{"pinned_ds": "whatever"},
+			url:        "https://ds-mirror.example.com",
+			secret:     "default-secret",
+		},
+		"no properties": {
+			url:    "https://ds.example.com",
+			secret: "default-secret",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			url, secret := config.RouteDocumentServer(tc.properties)
+			if url != tc.url || secret != tc.secret {
+				t.Errorf("expected %s with secret %s, got %s with %s", tc.url, tc.secret, url, secret)
+			}
+		})
+	}
+}
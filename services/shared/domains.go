@@ -0,0 +1,49 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import "strings"
+
+// DomainAllowed reports whether a user may use a deployment restricted to
+// the allowed Workspace domains. The user's domain is the hd claim Google
+// sets for Workspace accounts; consumer accounts carry no hd, so the email
+// domain is the fallback — which correctly leaves gmail.com users outside
+// any restricted deployment. An empty list keeps the integration open.
+func DomainAllowed(allowed []string, hd, email string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	domain := strings.ToLower(hd)
+	if domain == "" {
+		if at := strings.LastIndex(email, "@"); at >= 0 {
+			domain = strings.ToLower(email[at+1:])
+		}
+	}
+	if domain == "" {
+		return false
+	}
+
+	for _, candidate := range allowed {
+		if strings.EqualFold(candidate, domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import "testing"
+
+func TestDomainAllowedOpenByDefault(t *testing.T) {
+	if !DomainAllowed(nil, "", "anyone@gmail.com") {
+		t.Fatal("expected an empty list to allow everyone")
+	}
+}
+
+func TestDomainAllowedRejectsConsumerAccounts(t *testing.T) {
+	allowed := []string{"example.org"}
+
+	// Consumer accounts carry no hd claim; the email domain must not slip
+	// past a restricted deployment.
+	if DomainAllowed(allowed, "", "user@gmail.com") {
+		t.Fatal("expected a gmail.com user to be rejected")
+	}
+	if DomainAllowed(allowed, "", "") {
+		t.Fatal("expected a user without hd and email to be rejected")
+	}
+}
+
+func TestDomainAllowedPrefersHostedDomain(t *testing.T) {
+	allowed := []string{"example.org"}
+
+	if !DomainAllowed(allowed, "example.org", "user@alias.example.com") {
+		t.Fatal("expected the hd claim to win over the email domain")
+	}
+	// A matching email must not rescue an account whose hosted domain is
+	// not on the list; hd is the authoritative claim when present.
+	if DomainAllowed(allowed, "other.org", "user@example.org") {
+		t.Fatal("expected a foreign hd claim to be rejected despite the email")
+	}
+}
+
+func TestDomainAllowedEmailFallback(t *testing.T) {
+	allowed := []string{"example.org"}
+
+	// Deployments predating the hd claim in userinfo responses still see
+	// accounts through their email domain.
+	if !DomainAllowed(allowed, "", "user@example.org") {
+		t.Fatal("expected the email domain fallback to match")
+	}
+}
+
+func TestDomainAllowedCaseInsensitive(t *testing.T) {
+	if !DomainAllowed([]string{"Example.ORG"}, "example.org", "") {
+		t.Fatal("expected domain comparison to ignore case")
+	}
+	if !DomainAllowed([]string{"example.org"}, "", "User@EXAMPLE.ORG") {
+		t.Fatal("expected email domain comparison to ignore case")
+	}
+}
@@ -0,0 +1,109 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+)
+
+// dsProbeTimeout caps one reachability probe; the Document Server health
+// endpoint answers in milliseconds when it is up at all.
+const dsProbeTimeout = 2 * time.Second
+
+// DSHealth caches whether the Document Server answers its healthcheck
+// endpoint, so the editor page can fail fast with a real error instead of
+// rendering a frame whose api.js never loads. The result is probed lazily
+// and cached for a TTL; a browser-reported load failure invalidates the
+// cache so the next open re-probes instead of trusting a stale "healthy".
+type DSHealth struct {
+	mu     sync.Mutex
+	source *OnlyofficeConfigSource
+	ttl    time.Duration
+	now    func() time.Time
+	// probe reports whether the Document Server at url is reachable;
+	// injected so tests run without a server.
+	probe func(ctx context.Context, url string) bool
+
+	checked time.Time
+	healthy bool
+}
+
+func NewDSHealth(source *OnlyofficeConfigSource, ttl time.Duration) *DSHealth {
+	return &DSHealth{
+		source: source,
+		ttl:    ttl,
+		now:    time.Now,
+		probe:  probeDocumentServer,
+	}
+}
+
+// Healthy reports whether the Document Server answered its healthcheck
+// within the last TTL, probing it when the cached result has expired. The
+// probe runs under the lock: one slow probe is better than a stampede of
+// them when the server just went down.
+func (h *DSHealth) Healthy(ctx context.Context) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.checked.IsZero() && h.now().Sub(h.checked) < h.ttl {
+		return h.healthy
+	}
+
+	h.healthy = h.probe(ctx, h.source.Config().Onlyoffice.Builder.DocumentServerURL)
+	h.checked = h.now()
+	return h.healthy
+}
+
+// Invalidate drops the cached result so the next Healthy call probes again;
+// called when a browser reports that api.js failed to load even though the
+// last probe looked fine.
+func (h *DSHealth) Invalidate() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checked = time.Time{}
+}
+
+// probeDocumentServer asks the Document Server's healthcheck endpoint
+// whether it is up; any answer but 200 within the timeout counts as down.
+func probeDocumentServer(ctx context.Context, url string) bool {
+	pctx, cancel := context.WithTimeout(ctx, dsProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(
+		pctx, http.MethodGet, fmt.Sprintf("%s/healthcheck", url), nil,
+	)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", buildinfo.UserAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
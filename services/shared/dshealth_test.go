@@ -0,0 +1,110 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newDSHealthTestSource(t *testing.T) *OnlyofficeConfigSource {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return source
+}
+
+func TestDSHealthCachesProbeResult(t *testing.T) {
+	health := NewDSHealth(newDSHealthTestSource(t), time.Minute)
+	probes := 0
+	health.probe = func(context.Context, string) bool {
+		probes++
+		return true
+	}
+
+	for i := 0; i < 3; i++ {
+		if !health.Healthy(context.Background()) {
+			t.Fatal("expected the probed server to read as healthy")
+		}
+	}
+	if probes != 1 {
+		t.Errorf("expected one probe within the ttl, got %d", probes)
+	}
+}
+
+func TestDSHealthReprobesAfterTTL(t *testing.T) {
+	now := time.Now()
+	health := NewDSHealth(newDSHealthTestSource(t), time.Minute)
+	health.now = func() time.Time { return now }
+	up := false
+	health.probe = func(context.Context, string) bool { return up }
+
+	if health.Healthy(context.Background()) {
+		t.Fatal("expected the down server to read as unhealthy")
+	}
+
+	// The cached failure holds within the ttl even after the server
+	// recovers, then the next probe picks the recovery up.
+	up = true
+	if health.Healthy(context.Background()) {
+		t.Fatal("expected the cached failure to hold within the ttl")
+	}
+	now = now.Add(2 * time.Minute)
+	if !health.Healthy(context.Background()) {
+		t.Fatal("expected a re-probe after the ttl to see the recovery")
+	}
+}
+
+func TestDSHealthInvalidateForcesReprobe(t *testing.T) {
+	health := NewDSHealth(newDSHealthTestSource(t), time.Hour)
+	up := true
+	probes := 0
+	health.probe = func(context.Context, string) bool {
+		probes++
+		return up
+	}
+
+	if !health.Healthy(context.Background()) {
+		t.Fatal("expected the probed server to read as healthy")
+	}
+
+	// A browser-reported load failure drops the cached "healthy" so the
+	// next open re-checks instead of waiting out the ttl.
+	up = false
+	health.Invalidate()
+	if health.Healthy(context.Background()) {
+		t.Fatal("expected the re-probe after invalidation to see the outage")
+	}
+	if probes != 2 {
+		t.Errorf("expected two probes, got %d", probes)
+	}
+}
@@ -0,0 +1,88 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// VerifyDocumentServerToken verifies a token issued by the Document Server
+// against the builder configuration: the shared secret always, and issuer,
+// audience and clock-skew leeway when the install configures them. With the
+// optional settings unset the behavior matches plain secret verification,
+// so existing installs keep working unchanged.
+func VerifyDocumentServerToken(config OnlyofficeBuilderConfig, token string, body interface{}) error {
+	return verifyDocumentServerToken(config, token, body, time.Now())
+}
+
+func verifyDocumentServerToken(
+	config OnlyofficeBuilderConfig, token string, body interface{}, now time.Time,
+) error {
+	claims := jwt.MapClaims{}
+	// Claims are validated below so the configured leeway can soften the
+	// time checks; the signature and algorithm are still enforced here.
+	if _, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(config.DocumentServerSecret), nil
+	}, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+		jwt.WithoutClaimsValidation()); err != nil {
+		return err
+	}
+
+	leeway := time.Duration(config.DocumentServerLeeway) * time.Second
+	if !claims.VerifyExpiresAt(now.Add(-leeway).Unix(), false) {
+		return fmt.Errorf("document server token is expired")
+	}
+	if !claims.VerifyIssuedAt(now.Add(leeway).Unix(), false) {
+		return fmt.Errorf("document server token is used before issued")
+	}
+	if !claims.VerifyNotBefore(now.Add(leeway).Unix(), false) {
+		return fmt.Errorf("document server token is not valid yet")
+	}
+
+	if config.DocumentServerAudience != "" &&
+		!claims.VerifyAudience(config.DocumentServerAudience, true) {
+		return fmt.Errorf("document server token audience mismatch")
+	}
+
+	if len(config.DocumentServerIssuers) > 0 {
+		issuer, _ := claims["iss"].(string)
+		allowed := false
+		for _, candidate := range config.DocumentServerIssuers {
+			if issuer == candidate {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("document server token issuer %q is not allowed", issuer)
+		}
+	}
+
+	// The adapters manager decodes claims the same way: through a JSON
+	// round trip into the caller's shape.
+	buf, err := json.Marshal(claims)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, body)
+}
@@ -0,0 +1,153 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const dsTestSecret = "document-server-secret"
+
+func signDSToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(dsTestSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerifyDocumentServerToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	for name, tc := range map[string]struct {
+		claims jwt.MapClaims
+		config OnlyofficeBuilderConfig
+		valid  bool
+	}{
+		"plain token with no options": {
+			claims: jwt.MapClaims{"key": "document-key"},
+			config: OnlyofficeBuilderConfig{},
+			valid:  true,
+		},
+		"expired within leeway": {
+			claims: jwt.MapClaims{"exp": now.Add(-5 * time.Second).Unix()},
+			config: OnlyofficeBuilderConfig{DocumentServerLeeway: 10},
+			valid:  true,
+		},
+		"expired outside leeway": {
+			claims: jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()},
+			config: OnlyofficeBuilderConfig{DocumentServerLeeway: 10},
+			valid:  false,
+		},
+		"expired without leeway": {
+			claims: jwt.MapClaims{"exp": now.Add(-5 * time.Second).Unix()},
+			config: OnlyofficeBuilderConfig{},
+			valid:  false,
+		},
+		"issued ahead within leeway": {
+			claims: jwt.MapClaims{"iat": now.Add(5 * time.Second).Unix()},
+			config: OnlyofficeBuilderConfig{DocumentServerLeeway: 10},
+			valid:  true,
+		},
+		"issued ahead outside leeway": {
+			claims: jwt.MapClaims{"iat": now.Add(30 * time.Second).Unix()},
+			config: OnlyofficeBuilderConfig{DocumentServerLeeway: 10},
+			valid:  false,
+		},
+		"audience match": {
+			claims: jwt.MapClaims{"aud": "onlyoffice-gdrive"},
+			config: OnlyofficeBuilderConfig{DocumentServerAudience: "onlyoffice-gdrive"},
+			valid:  true,
+		},
+		"audience mismatch": {
+			claims: jwt.MapClaims{"aud": "another-integration"},
+			config: OnlyofficeBuilderConfig{DocumentServerAudience: "onlyoffice-gdrive"},
+			valid:  false,
+		},
+		"audience required but absent": {
+			claims: jwt.MapClaims{"key": "document-key"},
+			config: OnlyofficeBuilderConfig{DocumentServerAudience: "onlyoffice-gdrive"},
+			valid:  false,
+		},
+		"issuer allowed": {
+			claims: jwt.MapClaims{"iss": "ds-1"},
+			config: OnlyofficeBuilderConfig{DocumentServerIssuers: []string{"ds-1", "ds-2"}},
+			valid:  true,
+		},
+		"issuer not allowed": {
+			claims: jwt.MapClaims{"iss": "rogue"},
+			config: OnlyofficeBuilderConfig{DocumentServerIssuers: []string{"ds-1", "ds-2"}},
+			valid:  false,
+		},
+		// Unset options keep the permissive behavior installs rely on.
+		"foreign issuer and audience with no options": {
+			claims: jwt.MapClaims{"iss": "rogue", "aud": "another-integration"},
+			config: OnlyofficeBuilderConfig{},
+			valid:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc.config.DocumentServerSecret = dsTestSecret
+			var body map[string]interface{}
+			err := verifyDocumentServerToken(
+				tc.config, signDSToken(t, tc.claims), &body, now,
+			)
+			if tc.valid && err != nil {
+				t.Errorf("expected the token to verify, got %s", err.Error())
+			}
+			if !tc.valid && err == nil {
+				t.Error("expected a verification error")
+			}
+		})
+	}
+}
+
+func TestVerifyDocumentServerTokenRejectsWrongSecret(t *testing.T) {
+	token, err := jwt.NewWithClaims(
+		jwt.SigningMethodHS256, jwt.MapClaims{"key": "document-key"},
+	).SignedString([]byte("another-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body map[string]interface{}
+	config := OnlyofficeBuilderConfig{DocumentServerSecret: dsTestSecret}
+	if err := verifyDocumentServerToken(config, token, &body, time.Now()); err == nil {
+		t.Error("expected a signature error")
+	}
+}
+
+func TestVerifyDocumentServerTokenDecodesBody(t *testing.T) {
+	var body struct {
+		Key    string `json:"key"`
+		Status int    `json:"status"`
+	}
+	config := OnlyofficeBuilderConfig{DocumentServerSecret: dsTestSecret}
+	token := signDSToken(t, jwt.MapClaims{"key": "document-key", "status": 2})
+	if err := verifyDocumentServerToken(config, token, &body, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if body.Key != "document-key" || body.Status != 2 {
+		t.Errorf("claims were not decoded: %+v", body)
+	}
+}
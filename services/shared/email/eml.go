@@ -0,0 +1,283 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package email extracts the displayable parts of .eml files so the
+// integration can show mail exported into Drive through the Document
+// Server, which has no rfc822 format of its own. Outlook's .msg files are
+// an OLE container, not MIME, and have no parser here.
+package email
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// maxPartBytes caps how much of any single body part is kept in memory.
+// Mail exported into Drive is small; a part running past this is cut off
+// rather than failed, so an oversized inline image cannot block the text.
+const maxPartBytes = 25 << 20
+
+// maxPartDepth bounds multipart nesting; real mail rarely nests past
+// three levels and a deeper message is more likely crafted than genuine.
+const maxPartDepth = 8
+
+// Attachment is one file carried inside a parsed message, content
+// included — the caller decides whether to list it or serve it.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is the displayable content of a parsed .eml file. HTML and Text
+// hold the first body part of each kind; multipart/alternative messages
+// carry both and HTML wins when rendering.
+type Message struct {
+	Subject     string
+	From        string
+	HTML        string
+	Text        string
+	Attachments []Attachment
+}
+
+// ParseEML reads an RFC 5322 message and extracts its displayable body and
+// attachments, decoding transfer encodings and encoded-word headers along
+// the way.
+func ParseEML(r io.Reader) (*Message, error) {
+	parsed, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &Message{
+		Subject: decodeHeader(parsed.Header.Get("Subject")),
+		From:    decodeHeader(parsed.Header.Get("From")),
+	}
+	if err := message.addPart(parsed.Header, parsed.Body, 0); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// header is the part of mail.Header and textproto.MIMEHeader the walk
+// needs; it lets one function descend through both.
+type header interface {
+	Get(key string) string
+}
+
+// addPart sorts one MIME part into the message: containers recurse, parts
+// carrying a filename or an attachment disposition join the attachment
+// list, and the first text part of each kind becomes the body.
+func (m *Message) addPart(h header, body io.Reader, depth int) error {
+	if depth > maxPartDepth {
+		return fmt.Errorf("message nests deeper than %d multipart levels", maxPartDepth)
+	}
+
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := m.addPart(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	disposition := strings.ToLower(h.Get("Content-Disposition"))
+	filename := partFilename(h, params)
+	if filename != "" || strings.HasPrefix(disposition, "attachment") {
+		data, err := readPart(h, body)
+		if err != nil {
+			return err
+		}
+		if filename == "" {
+			filename = "attachment"
+		}
+		m.Attachments = append(m.Attachments, Attachment{
+			Filename:    filename,
+			ContentType: mediaType,
+			Data:        data,
+		})
+		return nil
+	}
+
+	switch mediaType {
+	case "text/html":
+		if m.HTML == "" {
+			data, err := readPart(h, body)
+			if err != nil {
+				return err
+			}
+			m.HTML = string(data)
+		}
+	case "text/plain":
+		if m.Text == "" {
+			data, err := readPart(h, body)
+			if err != nil {
+				return err
+			}
+			m.Text = string(data)
+		}
+	}
+	return nil
+}
+
+// readPart decodes one part's transfer encoding and reads it up to the
+// size cap. multipart already decodes quoted-printable parts itself and
+// removes the header, so only what is still declared needs handling here.
+func readPart(h header, body io.Reader) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	return io.ReadAll(io.LimitReader(body, maxPartBytes))
+}
+
+// partFilename resolves a part's filename from its disposition, falling
+// back to the content type's name parameter older mailers use.
+func partFilename(h header, typeParams map[string]string) string {
+	if disposition := h.Get("Content-Disposition"); disposition != "" {
+		if _, params, err := mime.ParseMediaType(disposition); err == nil {
+			if filename := params["filename"]; filename != "" {
+				return decodeHeader(filename)
+			}
+		}
+	}
+	return decodeHeader(typeParams["name"])
+}
+
+// decodeHeader resolves RFC 2047 encoded words, keeping the raw value when
+// the encoding is broken — a readable mojibake subject beats an empty one.
+func decodeHeader(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// RenderHTML produces the HTML document handed to the Document Server: the
+// body, preceded by the subject and sender for plain-text mail, followed
+// by the attachment list. openLink maps an attachment index to the URL
+// opening it, or "" for attachments the integration cannot open; the list
+// names those without linking them.
+func (m *Message) RenderHTML(openLink func(index int) string) string {
+	extra := m.attachmentsHTML(openLink)
+
+	if m.HTML != "" {
+		// The body is whatever the sender wrote; it never reaches the
+		// output with its active content intact.
+		return injectBeforeBodyEnd(sanitizeHTML(m.HTML), extra)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8"></head><body>`)
+	if m.Subject != "" {
+		fmt.Fprintf(&b, "<h1>%s</h1>", html.EscapeString(m.Subject))
+	}
+	if m.From != "" {
+		fmt.Fprintf(&b, "<p>From: %s</p>", html.EscapeString(m.From))
+	}
+	fmt.Fprintf(&b, "<pre>%s</pre>", html.EscapeString(m.Text))
+	b.WriteString(extra)
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+// attachmentsHTML lists the attachments by name and type so the reader
+// knows the message carries more than its body.
+func (m *Message) attachmentsHTML(openLink func(index int) string) string {
+	if len(m.Attachments) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<hr><h2>Attachments</h2><ul>")
+	for i, attachment := range m.Attachments {
+		label := html.EscapeString(attachment.Filename)
+		if openLink != nil {
+			if link := openLink(i); link != "" {
+				label = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(link), label)
+			}
+		}
+		fmt.Fprintf(&b, "<li>%s (%s)</li>", label, html.EscapeString(attachment.ContentType))
+	}
+	b.WriteString("</ul>")
+	return b.String()
+}
+
+// Mail HTML is attacker-supplied and the preview is served from the
+// gateway's own origin, so everything that can run script is stripped
+// before rendering: script elements with their content, the element kinds
+// that load or submit on their own, inline event handlers, and script
+// URLs in link and source attributes. The gateway additionally serves the
+// preview under a sandbox content security policy, so markup these
+// patterns miss still cannot execute or reach the origin's cookies.
+var (
+	scriptBlockPattern = regexp.MustCompile(`(?is)<script\b.*?(?:</script\s*>|\z)`)
+	activeTagPattern   = regexp.MustCompile(`(?i)</?(?:iframe|frame|frameset|object|embed|applet|base|form|link|meta)\b[^>]*>`)
+	eventAttrPattern   = regexp.MustCompile(`(?i)\s+on[a-z]+\s*=\s*(?:"[^"]*"|'[^']*'|[^\s>]+)`)
+	scriptURLPattern   = regexp.MustCompile(`(?i)\s(href|src|action|formaction)\s*=\s*(?:"\s*(?:javascript|vbscript):[^"]*"|'\s*(?:javascript|vbscript):[^']*'|(?:javascript|vbscript):[^\s>]*)`)
+)
+
+// sanitizeHTML strips the parts of a mail body that could execute in the
+// reader's browser, keeping the inert markup that shapes the message.
+func sanitizeHTML(doc string) string {
+	doc = scriptBlockPattern.ReplaceAllString(doc, "")
+	doc = activeTagPattern.ReplaceAllString(doc, "")
+	doc = eventAttrPattern.ReplaceAllString(doc, "")
+	return scriptURLPattern.ReplaceAllString(doc, ` $1="blocked:"`)
+}
+
+// injectBeforeBodyEnd places extra markup at the end of an existing HTML
+// document's body, or appends it when the document has no closing tag —
+// the Document Server's HTML import forgives the latter.
+func injectBeforeBodyEnd(doc, extra string) string {
+	if extra == "" {
+		return doc
+	}
+	if i := strings.LastIndex(strings.ToLower(doc), "</body>"); i >= 0 {
+		return doc[:i] + extra + doc[i:]
+	}
+	return doc + extra
+}
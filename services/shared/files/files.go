@@ -0,0 +1,265 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package files
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// OnlyofficeFileUtility answers format questions about the files the
+// integration is asked to open.
+type OnlyofficeFileUtility interface {
+	ValidateFileSize(size int64) bool
+	GetFileType(ext string) (string, error)
+	SupportedExtensions() []string
+	IsExtensionSupported(ext string) bool
+	IsExtensionEditable(ext string) bool
+	IsExtensionViewOnly(ext string) bool
+	IsExtensionLossEditable(ext string) bool
+	IsExtensionEmail(ext string) bool
+	IsExtensionTemplate(ext string) bool
+	TemplateOutputExtension(ext string) (string, bool)
+	EscapeFilename(filename string) string
+	SanitizeTitle(title string) string
+	TruncateTitle(title string, limit int) string
+	SniffFormat(reader io.Reader) (string, io.Reader)
+	FormatMatchesExtension(ext, format string) bool
+	CorrectedExtension(ext, format string) (string, bool)
+}
+
+type fileUtility struct{}
+
+func NewOnlyofficeFileUtility() OnlyofficeFileUtility {
+	return fileUtility{}
+}
+
+// MaxFileSize bounds documents the editor is allowed to open.
+const MaxFileSize int64 = 210000000
+
+var wordExtensions = map[string]bool{
+	"doc": true, "docm": true, "docx": true, "docxf": true, "dot": true,
+	"dotm": true, "dotx": true, "eml": true, "epub": true, "fb2": true,
+	"fodt": true, "html": true, "mht": true, "odt": true, "ott": true,
+	"oxps": true, "pdf": true, "rtf": true, "txt": true, "xml": true,
+	"xps": true,
+}
+
+var cellExtensions = map[string]bool{
+	"csv": true, "fods": true, "ods": true, "ots": true, "xls": true,
+	"xlsb": true, "xlsm": true, "xlsx": true, "xlt": true, "xltm": true,
+	"xltx": true,
+}
+
+var slideExtensions = map[string]bool{
+	"fodp": true, "odp": true, "otp": true, "pot": true, "potm": true,
+	"potx": true, "pps": true, "ppsm": true, "ppsx": true, "ppt": true,
+	"pptm": true, "pptx": true,
+}
+
+var editableExtensions = map[string]bool{
+	"docx": true, "xlsx": true, "pptx": true, "docxf": true,
+}
+
+var lossEditableExtensions = map[string]bool{
+	"epub": true, "fb2": true, "html": true, "odp": true, "ods": true,
+	"odt": true, "rtf": true, "txt": true,
+}
+
+// emailExtensions are view-only formats the Document Server cannot open
+// directly; the download proxy serves their extracted body HTML instead.
+// Outlook's .msg is an OLE container with no parser here, so it stays
+// unregistered.
+var emailExtensions = map[string]bool{
+	"eml": true,
+}
+
+// templateOutputExtensions maps every template format onto the OOXML
+// template extension of its family. The family's plain output (docx, xlsx,
+// pptx) silently demotes a template to an ordinary document, so the
+// conversion pipeline takes its target from this table when the user asked
+// for the template nature to be kept. The OOXML template formats map onto
+// themselves, which is also what marks them as editable in place on
+// Document Servers that support template editing.
+var templateOutputExtensions = map[string]string{
+	"dot": "dotx", "dotm": "dotx", "dotx": "dotx", "ott": "dotx",
+	"xlt": "xltx", "xltm": "xltx", "xltx": "xltx", "ots": "xltx",
+	"pot": "potx", "potm": "potx", "potx": "potx", "otp": "potx",
+}
+
+type UnsupportedExtensionError struct {
+	Extension string
+}
+
+func (e *UnsupportedExtensionError) Error() string {
+	return "unsupported file extension " + e.Extension
+}
+
+func (u fileUtility) ValidateFileSize(size int64) bool {
+	return size >= 0 && size <= MaxFileSize
+}
+
+// GetFileType maps a file extension to the Document Server documentType.
+func (u fileUtility) GetFileType(ext string) (string, error) {
+	ext = strings.ToLower(ext)
+	switch {
+	case wordExtensions[ext]:
+		return "word", nil
+	case cellExtensions[ext]:
+		return "cell", nil
+	case slideExtensions[ext]:
+		return "slide", nil
+	default:
+		return "", &UnsupportedExtensionError{Extension: ext}
+	}
+}
+
+// SupportedExtensions returns every extension the editor can open, sorted
+// alphabetically.
+func (u fileUtility) SupportedExtensions() []string {
+	exts := make([]string, 0, len(wordExtensions)+len(cellExtensions)+len(slideExtensions))
+	for _, m := range []map[string]bool{wordExtensions, cellExtensions, slideExtensions} {
+		for ext := range m {
+			exts = append(exts, ext)
+		}
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+func (u fileUtility) IsExtensionSupported(ext string) bool {
+	_, err := u.GetFileType(ext)
+	return err == nil
+}
+
+func (u fileUtility) IsExtensionEditable(ext string) bool {
+	return editableExtensions[strings.ToLower(ext)]
+}
+
+func (u fileUtility) IsExtensionViewOnly(ext string) bool {
+	ext = strings.ToLower(ext)
+	return u.IsExtensionSupported(ext) && !u.IsExtensionEditable(ext) &&
+		!u.IsExtensionLossEditable(ext)
+}
+
+func (u fileUtility) IsExtensionLossEditable(ext string) bool {
+	return lossEditableExtensions[strings.ToLower(ext)]
+}
+
+func (u fileUtility) IsExtensionEmail(ext string) bool {
+	return emailExtensions[strings.ToLower(ext)]
+}
+
+// IsExtensionTemplate reports whether ext is a document, spreadsheet or
+// presentation template format.
+func (u fileUtility) IsExtensionTemplate(ext string) bool {
+	_, ok := templateOutputExtensions[strings.ToLower(ext)]
+	return ok
+}
+
+// TemplateOutputExtension returns the template-preserving conversion target
+// for ext — the OOXML template extension of its family — and reports
+// whether ext is a template format at all.
+func (u fileUtility) TemplateOutputExtension(ext string) (string, bool) {
+	output, ok := templateOutputExtensions[strings.ToLower(ext)]
+	return output, ok
+}
+
+// EscapeFilename strips characters that break Drive titles and generated
+// download URLs.
+func (u fileUtility) EscapeFilename(filename string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\\', '/', ':', '*', '?', '"', '<', '>', '|':
+			return -1
+		default:
+			return r
+		}
+	}, filename)
+}
+
+// maxTitleRunes caps generated titles so the name plus extension stays
+// within common filesystem component limits.
+const maxTitleRunes = 200
+
+// SanitizeTitle makes a generated title safe as a filename on every client
+// OS: the characters <>:"/\|?* and control characters are dropped, Unicode
+// letters are kept as-is and the result is trimmed and capped at
+// maxTitleRunes runes. Titles of files that are merely updated in place are
+// never sanitized; this only applies to names the integration creates.
+func (u fileUtility) SanitizeTitle(title string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r == '<' || r == '>' || r == ':' || r == '"' || r == '/' ||
+			r == '\\' || r == '|' || r == '?' || r == '*':
+			return -1
+		case r < 0x20 || r == 0x7f:
+			return -1
+		default:
+			return r
+		}
+	}, title)
+
+	if runes := []rune(sanitized); len(runes) > maxTitleRunes {
+		sanitized = string(runes[:maxTitleRunes])
+	}
+
+	// Windows rejects names ending with a dot or a space.
+	sanitized = strings.Trim(sanitized, " .")
+	if sanitized == "" {
+		return "Untitled"
+	}
+	return sanitized
+}
+
+// TruncateTitle caps a generated filename at limit runes while keeping the
+// extension intact: Drive accepts titles up to 32k characters, but names
+// that long break Content-Disposition headers and Document Server
+// requests. Overlong bases are cut in the middle with an ellipsis so both
+// the distinguishing start and any trailing numbering survive. Counting in
+// runes keeps a multibyte character from being split at either cut point.
+// A non-positive limit falls back to maxTitleRunes; titles of files merely
+// updated in place are never truncated.
+func (u fileUtility) TruncateTitle(title string, limit int) string {
+	if limit <= 0 {
+		limit = maxTitleRunes
+	}
+	runes := []rune(title)
+	if len(runes) <= limit {
+		return title
+	}
+
+	var ext []rune
+	if dot := strings.LastIndex(title, "."); dot > 0 {
+		ext = []rune(title[dot:])
+	}
+	// One rune of the budget goes to the ellipsis.
+	keep := limit - len(ext) - 1
+	if keep < 2 {
+		// A pathological extension leaves no room for a readable base;
+		// keep the head of the whole name instead.
+		return string(runes[:limit])
+	}
+
+	base := runes[:len(runes)-len(ext)]
+	head := base[:(keep+1)/2]
+	tail := base[len(base)-keep/2:]
+	return string(head) + "…" + string(tail) + string(ext)
+}
@@ -0,0 +1,153 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package files
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeTitle(t *testing.T) {
+	utility := NewOnlyofficeFileUtility()
+
+	for _, tc := range []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{"plain", "Report", "Report"},
+		{"reserved characters", `Q1: <results>?`, "Q1 results"},
+		{"cyrillic preserved", "Q1: результаты?", "Q1 результаты"},
+		{"mixed scripts", "契約書/final|v2", "契約書finalv2"},
+		{"control characters", "bad\x00name\x1ftitle", "badnametitle"},
+		{"path separators", `..\..\etc\passwd`, "etcpasswd"},
+		{"trailing dots and spaces", "draft... ", "draft"},
+		{"only reserved characters", `<>:"/\|?*`, "Untitled"},
+		{"empty", "", "Untitled"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := utility.SanitizeTitle(tc.title); got != tc.want {
+				t.Errorf("SanitizeTitle(%q) = %q, want %q", tc.title, got, tc.want)
+			}
+		})
+	}
+
+	t.Run("maximum length", func(t *testing.T) {
+		long := strings.Repeat("я", maxTitleRunes+50)
+		got := utility.SanitizeTitle(long)
+		if runes := []rune(got); len(runes) != maxTitleRunes {
+			t.Errorf("expected %d runes, got %d", maxTitleRunes, len(runes))
+		}
+	})
+}
+
+func TestTemplateExtensions(t *testing.T) {
+	utility := NewOnlyofficeFileUtility()
+
+	for _, tc := range []struct {
+		ext    string
+		output string
+	}{
+		{"dot", "dotx"}, {"dotm", "dotx"}, {"dotx", "dotx"}, {"ott", "dotx"},
+		{"xlt", "xltx"}, {"xltm", "xltx"}, {"xltx", "xltx"}, {"ots", "xltx"},
+		{"pot", "potx"}, {"potm", "potx"}, {"potx", "potx"}, {"otp", "potx"},
+		{"DOTX", "dotx"},
+	} {
+		t.Run(tc.ext, func(t *testing.T) {
+			if !utility.IsExtensionTemplate(tc.ext) {
+				t.Errorf("expected .%s to be a template format", tc.ext)
+			}
+			output, ok := utility.TemplateOutputExtension(tc.ext)
+			if !ok || output != tc.output {
+				t.Errorf("TemplateOutputExtension(%q) = %q, %t, want %q", tc.ext, output, ok, tc.output)
+			}
+			if !utility.IsExtensionSupported(tc.ext) {
+				t.Errorf("expected the template format .%s to be registered as supported", tc.ext)
+			}
+		})
+	}
+
+	t.Run("plain documents are not templates", func(t *testing.T) {
+		for _, ext := range []string{"docx", "xlsx", "pptx", "csv", "pdf"} {
+			if utility.IsExtensionTemplate(ext) {
+				t.Errorf("expected .%s not to be a template format", ext)
+			}
+			if output, ok := utility.TemplateOutputExtension(ext); ok {
+				t.Errorf("expected no template target for .%s, got %q", ext, output)
+			}
+		}
+	})
+
+	// Every target must be an identity-mapped template of the same family,
+	// or an "edit a template copy" result could not reopen as a template.
+	t.Run("targets map onto themselves", func(t *testing.T) {
+		for ext, output := range templateOutputExtensions {
+			if target, ok := templateOutputExtensions[output]; !ok || target != output {
+				t.Errorf("target %q of .%s is not an identity-mapped template", output, ext)
+			}
+			extType, _ := utility.GetFileType(ext)
+			outputType, _ := utility.GetFileType(output)
+			if extType != outputType {
+				t.Errorf(".%s (%s) maps across families to .%s (%s)", ext, extType, output, outputType)
+			}
+		}
+	})
+}
+
+func TestTruncateTitle(t *testing.T) {
+	utility := NewOnlyofficeFileUtility()
+
+	for _, tc := range []struct {
+		name  string
+		title string
+		limit int
+		want  string
+	}{
+		{"under the limit", "Report.docx", 20, "Report.docx"},
+		{"exactly at the limit", strings.Repeat("a", 16) + ".docx", 21, strings.Repeat("a", 16) + ".docx"},
+		{"one over the limit", strings.Repeat("a", 17) + ".docx", 21, strings.Repeat("a", 8) + "…" + strings.Repeat("a", 7) + ".docx"},
+		{"keeps head and tail", "Quarterly report 2024 final v2.xlsx", 20, "Quarter…inal v2.xlsx"},
+		{"no extension", strings.Repeat("b", 30), 11, "bbbbb…bbbbb"},
+		{"extension eats the whole budget", "name.averylongextension", 8, "name.ave"},
+		{"non-positive limit uses the default", strings.Repeat("c", maxTitleRunes+50), 0, strings.Repeat("c", 100) + "…" + strings.Repeat("c", 99)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := utility.TruncateTitle(tc.title, tc.limit)
+			if got != tc.want {
+				t.Errorf("TruncateTitle(%q, %d) = %q, want %q", tc.title, tc.limit, got, tc.want)
+			}
+		})
+	}
+
+	// Multibyte characters straddling either cut point must never be
+	// split: the result has to stay valid UTF-8 at exactly the limit.
+	t.Run("multibyte at the cut points", func(t *testing.T) {
+		title := strings.Repeat("日本語テキスト", 100) + ".docx"
+		got := utility.TruncateTitle(title, 40)
+		if runes := []rune(got); len(runes) != 40 {
+			t.Errorf("expected 40 runes, got %d", len(runes))
+		}
+		if !strings.HasSuffix(got, ".docx") {
+			t.Errorf("expected the extension to survive, got %q", got)
+		}
+		if strings.ContainsRune(got, '�') || !strings.Contains(got, "…") {
+			t.Errorf("expected a cleanly cut name, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package files
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// Format families SniffFormat can tell apart. The families are deliberately
+// coarse: the point is catching a renamed .xls posing as .xlsx, not a full
+// content-type detector.
+const (
+	// FormatOLE2: the legacy binary Office container (doc, xls, ppt).
+	FormatOLE2 = "ole2"
+	// FormatZip: a ZIP archive, which covers OOXML and OpenDocument files.
+	FormatZip = "zip"
+	// FormatPDF: a PDF document.
+	FormatPDF = "pdf"
+	// FormatText: printable text — txt, csv, html, rtf and the flat XML
+	// formats all land here.
+	FormatText = "text"
+	// FormatUnknown: none of the known signatures matched; callers must
+	// treat it as inconclusive, never as a mismatch.
+	FormatUnknown = ""
+)
+
+// sniffLimit is how much of the stream SniffFormat inspects. The magic
+// numbers need 8 bytes; the rest only feeds the text heuristic.
+const sniffLimit = 512
+
+var ole2Magic = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}
+
+var zipMagics = [][]byte{
+	[]byte("PK\x03\x04"),
+	// An empty archive and a spanned archive still identify the family.
+	[]byte("PK\x05\x06"),
+	[]byte("PK\x07\x08"),
+}
+
+// SniffFormat inspects the head of the stream and reports its format
+// family. The returned reader replays the inspected bytes before the rest
+// of the stream, so callers hand it on in place of the original and lose
+// nothing.
+func (u fileUtility) SniffFormat(reader io.Reader) (string, io.Reader) {
+	head := make([]byte, sniffLimit)
+	n, _ := io.ReadFull(reader, head)
+	head = head[:n]
+	return detectFormat(head), io.MultiReader(bytes.NewReader(head), reader)
+}
+
+func detectFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, ole2Magic):
+		return FormatOLE2
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return FormatPDF
+	}
+	for _, magic := range zipMagics {
+		if bytes.HasPrefix(head, magic) {
+			return FormatZip
+		}
+	}
+	if looksLikeText(head) {
+		return FormatText
+	}
+	return FormatUnknown
+}
+
+// looksLikeText accepts byte runs without control characters other than
+// whitespace. It deliberately stays byte-level: the sniff window can cut a
+// UTF-8 sequence in half, so full validation would misfire.
+func looksLikeText(head []byte) bool {
+	if len(head) == 0 {
+		return false
+	}
+	// A UTF-8 byte order mark is as textual as it gets.
+	head = bytes.TrimPrefix(head, []byte{0xef, 0xbb, 0xbf})
+	for _, b := range head {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != 0x0c {
+			return false
+		}
+	}
+	return true
+}
+
+// expectedFormats maps an extension to the format families its content may
+// legitimately have. Extensions absent from the map are never flagged.
+var expectedFormats = map[string]string{
+	"doc": FormatOLE2, "dot": FormatOLE2, "xls": FormatOLE2,
+	"xlt": FormatOLE2, "ppt": FormatOLE2, "pps": FormatOLE2,
+	"pot": FormatOLE2,
+
+	"docx": FormatZip, "docm": FormatZip, "dotx": FormatZip,
+	"dotm": FormatZip, "docxf": FormatZip, "xlsx": FormatZip,
+	"xlsm": FormatZip, "xlsb": FormatZip, "xltx": FormatZip,
+	"xltm": FormatZip, "pptx": FormatZip, "pptm": FormatZip,
+	"potx": FormatZip, "potm": FormatZip, "ppsx": FormatZip,
+	"ppsm": FormatZip, "odt": FormatZip, "ott": FormatZip,
+	"ods": FormatZip, "ots": FormatZip, "odp": FormatZip,
+	"otp": FormatZip, "epub": FormatZip, "xps": FormatZip,
+	"oxps": FormatZip,
+
+	"pdf": FormatPDF,
+
+	"txt": FormatText, "csv": FormatText, "html": FormatText,
+	"xml": FormatText, "mht": FormatText, "fb2": FormatText,
+	"rtf": FormatText, "fodt": FormatText, "fods": FormatText,
+	"fodp": FormatText,
+}
+
+// FormatMatchesExtension reports whether sniffed content is plausible for
+// the extension. Unknown formats and unmapped extensions pass: only a
+// positive contradiction is worth acting on.
+func (u fileUtility) FormatMatchesExtension(ext, format string) bool {
+	if format == FormatUnknown {
+		return true
+	}
+	expected, ok := expectedFormats[strings.ToLower(ext)]
+	if !ok {
+		return true
+	}
+	return expected == format
+}
+
+// CorrectedExtension suggests the extension matching the sniffed content
+// within the file's document family, so a renamed .xls declared as .xlsx is
+// converted as the spreadsheet it actually is. No plausible correction
+// means ok is false and the caller keeps the declared extension.
+func (u fileUtility) CorrectedExtension(ext, format string) (string, bool) {
+	fileType, err := u.GetFileType(ext)
+	if err != nil {
+		return "", false
+	}
+	if format == FormatPDF {
+		return "pdf", true
+	}
+
+	corrected, ok := map[string]map[string]string{
+		"word":  {FormatOLE2: "doc", FormatZip: "docx", FormatText: "txt"},
+		"cell":  {FormatOLE2: "xls", FormatZip: "xlsx", FormatText: "csv"},
+		"slide": {FormatOLE2: "ppt", FormatZip: "pptx"},
+	}[fileType][format]
+	return corrected, ok
+}
@@ -0,0 +1,156 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package files
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSniffFormatFamilies(t *testing.T) {
+	utility := NewOnlyofficeFileUtility()
+
+	for _, tc := range []struct {
+		name   string
+		head   []byte
+		format string
+	}{
+		{
+			name:   "legacy binary office",
+			head:   []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1, 0x00, 0x00},
+			format: FormatOLE2,
+		},
+		{
+			name:   "ooxml zip",
+			head:   []byte("PK\x03\x04\x14\x00\x06\x00[Content_Types].xml"),
+			format: FormatZip,
+		},
+		{
+			name:   "empty zip archive",
+			head:   []byte("PK\x05\x06\x00\x00\x00\x00"),
+			format: FormatZip,
+		},
+		{
+			name:   "pdf",
+			head:   []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3"),
+			format: FormatPDF,
+		},
+		{
+			name:   "plain text csv",
+			head:   []byte("name,amount\nfirst,1\nsecond,2\n"),
+			format: FormatText,
+		},
+		{
+			name:   "utf-8 text with byte order mark",
+			head:   append([]byte{0xef, 0xbb, 0xbf}, []byte("Überschrift")...),
+			format: FormatText,
+		},
+		{
+			name:   "unrecognized binary",
+			head:   []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+			format: FormatUnknown,
+		},
+		{
+			name:   "empty stream",
+			head:   nil,
+			format: FormatUnknown,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			format, _ := utility.SniffFormat(bytes.NewReader(tc.head))
+			if format != tc.format {
+				t.Errorf("expected %q, got %q", tc.format, format)
+			}
+		})
+	}
+}
+
+func TestSniffFormatReplaysTheStream(t *testing.T) {
+	utility := NewOnlyofficeFileUtility()
+	content := "%PDF-1.7\n" + strings.Repeat("stream content ", 200)
+
+	format, replay := utility.SniffFormat(strings.NewReader(content))
+	if format != FormatPDF {
+		t.Fatalf("expected the pdf family, got %q", format)
+	}
+
+	replayed, err := io.ReadAll(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(replayed) != content {
+		t.Errorf("expected the sniffed bytes back in the stream, got %d of %d bytes", len(replayed), len(content))
+	}
+}
+
+func TestFormatMatchesExtension(t *testing.T) {
+	utility := NewOnlyofficeFileUtility()
+
+	for _, tc := range []struct {
+		ext     string
+		format  string
+		matches bool
+	}{
+		{ext: "xlsx", format: FormatZip, matches: true},
+		{ext: "xlsx", format: FormatOLE2, matches: false},
+		{ext: "xls", format: FormatOLE2, matches: true},
+		{ext: "doc", format: FormatZip, matches: false},
+		{ext: "pdf", format: FormatPDF, matches: true},
+		{ext: "csv", format: FormatText, matches: true},
+		{ext: "csv", format: FormatZip, matches: false},
+		// Inconclusive sniffs and unmapped extensions never flag.
+		{ext: "xlsx", format: FormatUnknown, matches: true},
+		{ext: "unknownext", format: FormatZip, matches: true},
+	} {
+		if got := utility.FormatMatchesExtension(tc.ext, tc.format); got != tc.matches {
+			t.Errorf("expected .%s with %q content to match=%t", tc.ext, tc.format, tc.matches)
+		}
+	}
+}
+
+func TestCorrectedExtension(t *testing.T) {
+	utility := NewOnlyofficeFileUtility()
+
+	for _, tc := range []struct {
+		ext       string
+		format    string
+		corrected string
+		ok        bool
+	}{
+		// The headline case: an .xls renamed to .xlsx.
+		{ext: "xlsx", format: FormatOLE2, corrected: "xls", ok: true},
+		{ext: "xls", format: FormatZip, corrected: "xlsx", ok: true},
+		{ext: "doc", format: FormatZip, corrected: "docx", ok: true},
+		{ext: "pptx", format: FormatOLE2, corrected: "ppt", ok: true},
+		{ext: "csv", format: FormatZip, corrected: "xlsx", ok: true},
+		{ext: "docx", format: FormatPDF, corrected: "pdf", ok: true},
+		{ext: "pptx", format: FormatText, ok: false},
+		{ext: "unknownext", format: FormatZip, ok: false},
+	} {
+		corrected, ok := utility.CorrectedExtension(tc.ext, tc.format)
+		if ok != tc.ok || corrected != tc.corrected {
+			t.Errorf(
+				"expected .%s with %q content to correct to %q/%t, got %q/%t",
+				tc.ext, tc.format, tc.corrected, tc.ok, corrected, ok,
+			)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// Feature flag names consulted across the services.
+const (
+	// FlagQueuedUploads defers Document Server saves to the background
+	// queue; disabling it makes the callback upload synchronously.
+	FlagQueuedUploads = "queued_uploads"
+	// FlagConflictCopies stores a saved revision as a copy instead of
+	// overwriting a file that changed in Drive while the editor was open.
+	FlagConflictCopies = "conflict_copies"
+)
+
+// FeatureFlagConfig describes one rollout-controlled behavior. A matching
+// domain override wins, then a configured percentage rollout hashed by user
+// id, then the default.
+type FeatureFlagConfig struct {
+	Default bool            `yaml:"default"`
+	Domains map[string]bool `yaml:"domains"`
+	Rollout int             `yaml:"rollout"`
+}
+
+// OnlyofficeFeaturesConfig maps flag names to their rollout rules. It lives
+// in the watched configuration file, so flags flip without a redeploy.
+type OnlyofficeFeaturesConfig map[string]FeatureFlagConfig
+
+// Flags resolves feature flags per user. The fallback applies only when the
+// configuration does not mention the flag at all, so code-side defaults
+// survive an empty features block.
+type Flags interface {
+	Enabled(name, uid string, fallback bool) bool
+}
+
+type configFlags struct {
+	onlyoffice *OnlyofficeConfigSource
+}
+
+// NewConfigFlags resolves flags against the hot-reloadable config source.
+func NewConfigFlags(onlyoffice *OnlyofficeConfigSource) Flags {
+	return configFlags{onlyoffice: onlyoffice}
+}
+
+func (f configFlags) Enabled(name, uid string, fallback bool) bool {
+	flag, ok := f.onlyoffice.Config().Onlyoffice.Features[name]
+	if !ok {
+		return fallback
+	}
+	return flagEnabled(flag, name, uid)
+}
+
+func flagEnabled(flag FeatureFlagConfig, name, uid string) bool {
+	// Email-shaped ids carry a domain; an override for it wins outright.
+	if at := strings.LastIndex(uid, "@"); at >= 0 {
+		if enabled, ok := flag.Domains[strings.ToLower(uid[at+1:])]; ok {
+			return enabled
+		}
+	}
+
+	if flag.Rollout > 0 {
+		return rolloutBucket(name, uid) < flag.Rollout
+	}
+
+	return flag.Default
+}
+
+// rolloutBucket maps a user to a stable 0-99 bucket. The flag name salts
+// the hash, so separate flags roll out to independent user slices.
+func rolloutBucket(name, uid string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(uid))
+	return int(h.Sum32() % 100)
+}
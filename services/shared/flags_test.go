@@ -0,0 +1,129 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFlagEnabled(t *testing.T) {
+	for name, tc := range map[string]struct {
+		flag     FeatureFlagConfig
+		uid      string
+		expected bool
+	}{
+		"default off": {
+			flag:     FeatureFlagConfig{},
+			uid:      "1000000000",
+			expected: false,
+		},
+		"default on": {
+			flag:     FeatureFlagConfig{Default: true},
+			uid:      "1000000000",
+			expected: true,
+		},
+		"domain override wins over default": {
+			flag:     FeatureFlagConfig{Default: false, Domains: map[string]bool{"example.com": true}},
+			uid:      "jane@example.com",
+			expected: true,
+		},
+		"domain override disables": {
+			flag:     FeatureFlagConfig{Default: true, Domains: map[string]bool{"example.com": false}},
+			uid:      "jane@Example.COM",
+			expected: false,
+		},
+		"full rollout": {
+			flag:     FeatureFlagConfig{Rollout: 100},
+			uid:      "1000000000",
+			expected: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := flagEnabled(tc.flag, "some_flag", tc.uid); got != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+// Rollout bucketing must be deterministic: the same user lands in the same
+// bucket on every resolution, and different flags slice users differently.
+func TestRolloutBucketIsDeterministic(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		uid := fmt.Sprintf("user-%d", i)
+		first := rolloutBucket("queued_uploads", uid)
+		for run := 0; run < 10; run++ {
+			if got := rolloutBucket("queued_uploads", uid); got != first {
+				t.Fatalf("bucket for %s changed from %d to %d", uid, first, got)
+			}
+		}
+		if first < 0 || first > 99 {
+			t.Fatalf("bucket for %s out of range: %d", uid, first)
+		}
+	}
+}
+
+func TestRolloutBucketIsSaltedByFlagName(t *testing.T) {
+	same := 0
+	for i := 0; i < 100; i++ {
+		uid := fmt.Sprintf("user-%d", i)
+		if rolloutBucket("queued_uploads", uid) == rolloutBucket("conflict_copies", uid) {
+			same++
+		}
+	}
+	// Identical bucketing across flags would mean the salt is ignored; a
+	// few collisions are expected from a 100-bucket space.
+	if same > 50 {
+		t.Errorf("flags share buckets for %d of 100 users", same)
+	}
+}
+
+func TestConfigFlagsFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+  features:
+    conflict_copies:
+      default: true
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := NewConfigFlags(source)
+	if !flags.Enabled("conflict_copies", "1000000000", false) {
+		t.Error("a configured flag must resolve from the config")
+	}
+	if !flags.Enabled("queued_uploads", "1000000000", true) {
+		t.Error("an absent flag must resolve to the fallback")
+	}
+	if flags.Enabled("queued_uploads", "1000000000", false) {
+		t.Error("an absent flag must resolve to the fallback")
+	}
+}
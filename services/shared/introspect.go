@@ -0,0 +1,117 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"gopkg.in/yaml.v2"
+)
+
+// configMask replaces secret values in sanitized configuration output.
+// Unlike Redact it keeps nothing of the value: the output is served over
+// HTTP and pasted into support tickets, so even a partial reveal is too
+// much. Empty secrets stay empty — whether a secret is configured at all
+// is exactly what operators come here to check.
+const configMask = "****"
+
+// secretFieldPattern matches configuration field names that hold secret
+// material by their name alone.
+var secretFieldPattern = regexp.MustCompile(`(?i)secret|password|token`)
+
+// explicitSecretFields lists fields whose names do not say they are
+// secrets; the pattern above cannot catch them.
+var explicitSecretFields = map[string]struct{}{
+	"api_key": {},
+}
+
+// SecretConfigField reports whether a configuration field name holds a
+// secret and must be masked before the value leaves the process.
+func SecretConfigField(name string) bool {
+	if _, ok := explicitSecretFields[name]; ok {
+		return true
+	}
+	return secretFieldPattern.MatchString(name)
+}
+
+// SanitizeConfig renders a configuration struct into a generic tree with
+// every secret-bearing field masked, following the same yaml tags the
+// loader reads so the output names match the configuration file. The
+// result marshals cleanly to JSON.
+func SanitizeConfig(cfg interface{}) (interface{}, error) {
+	buf, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree interface{}
+	if err := yaml.Unmarshal(buf, &tree); err != nil {
+		return nil, err
+	}
+	return maskTree(tree, false), nil
+}
+
+// maskTree walks the decoded configuration; once a field name marks its
+// subtree secret, every string underneath is masked, so a secret-named
+// block cannot leak through its children.
+func maskTree(node interface{}, secret bool) interface{} {
+	switch value := node.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			name := fmt.Sprint(key)
+			out[name] = maskTree(item, secret || SecretConfigField(name))
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			out[i] = maskTree(item, secret)
+		}
+		return out
+	case string:
+		if secret && value != "" {
+			return configMask
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// LogEffectiveConfig writes the sanitized effective configuration once at
+// info level, so the log of every install starts with what it actually
+// runs with rather than what its configuration file says.
+func LogEffectiveConfig(logger log.Logger, cfg *OnlyofficeConfig) {
+	sanitized, err := SanitizeConfig(cfg.Onlyoffice)
+	if err != nil {
+		logger.Warnf("could not render the effective configuration: %s", err.Error())
+		return
+	}
+
+	buf, err := json.Marshal(sanitized)
+	if err != nil {
+		logger.Warnf("could not render the effective configuration: %s", err.Error())
+		return
+	}
+	logger.Infof("effective configuration: %s", buf)
+}
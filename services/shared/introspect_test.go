@@ -0,0 +1,122 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// A fully populated configuration run through the sanitizer must leak none
+// of its secret values, while everything an operator needs for debugging —
+// URLs, toggles, feature flags — survives verbatim.
+func TestSanitizeConfigMasksEverySecret(t *testing.T) {
+	var cfg OnlyofficeConfig
+	cfg.Onlyoffice.Builder.DocumentServerURL = "https://ds.example.com"
+	cfg.Onlyoffice.Builder.DocumentServerSecret = "default-ds-secret-value"
+	cfg.Onlyoffice.Builder.GatewayURL = "https://gateway.example.com"
+	cfg.Onlyoffice.Builder.CallbackURL = "https://callback.example.com"
+	cfg.Onlyoffice.Builder.DocumentServers = map[string]OnlyofficeDocumentServerConfig{
+		"onprem": {URL: "https://onprem.example.com", Secret: "onprem-ds-secret-value"},
+	}
+	cfg.Onlyoffice.Internal.Secret = "internal-secret-value"
+	cfg.Onlyoffice.Internal.SecondarySecret = "rotated-secret-value"
+	cfg.Onlyoffice.Features = OnlyofficeFeaturesConfig{
+		"queued_uploads": {Default: true},
+	}
+	cfg.Onlyoffice.Callback.MaxSize = 1024
+
+	sanitized, err := SanitizeConfig(cfg.Onlyoffice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := json.Marshal(sanitized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := string(buf)
+	for _, secret := range []string{
+		"default-ds-secret-value",
+		"onprem-ds-secret-value",
+		"internal-secret-value",
+		"rotated-secret-value",
+	} {
+		if strings.Contains(output, secret) {
+			t.Errorf("expected %q to be masked, got %s", secret, output)
+		}
+	}
+	for _, visible := range []string{
+		"https://ds.example.com",
+		"https://onprem.example.com",
+		"queued_uploads",
+	} {
+		if !strings.Contains(output, visible) {
+			t.Errorf("expected %q to survive sanitizing, got %s", visible, output)
+		}
+	}
+}
+
+// The api_key field does not match the name patterns and is masked through
+// the explicit list; the client id next to it is what operators compare
+// against the Google console and must stay readable.
+func TestSanitizeConfigMasksExplicitFields(t *testing.T) {
+	var cfg CredentialsConfig
+	cfg.Credentials.ClientID = "client-id-value"
+	cfg.Credentials.ClientSecret = "client-secret-value"
+	cfg.Credentials.APIKey = "api-key-value"
+
+	sanitized, err := SanitizeConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := json.Marshal(sanitized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := string(buf)
+	if strings.Contains(output, "client-secret-value") || strings.Contains(output, "api-key-value") {
+		t.Errorf("expected the credentials to be masked, got %s", output)
+	}
+	if !strings.Contains(output, "client-id-value") {
+		t.Errorf("expected the client id to stay readable, got %s", output)
+	}
+}
+
+// An unset secret must stay visibly empty: whether a secret is configured
+// at all is the question operators come here with.
+func TestSanitizeConfigKeepsEmptySecretsEmpty(t *testing.T) {
+	var cfg OnlyofficeConfig
+	cfg.Onlyoffice.Builder.DocumentServerURL = "https://ds.example.com"
+
+	sanitized, err := SanitizeConfig(cfg.Onlyoffice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := json.Marshal(sanitized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(buf), `"document_server_secret":""`) {
+		t.Errorf("expected an unset secret to stay empty, got %s", buf)
+	}
+}
@@ -0,0 +1,99 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"context"
+	"time"
+)
+
+// defaultStopTimeout bounds the graceful HTTP stop when no timeout is
+// configured.
+const defaultStopTimeout = 30 * time.Second
+
+// Lifecycle shuts a service down in load-balancer-safe order: deregister
+// from the registry first so no new traffic is routed here, wait a drain
+// delay for requests already routed to arrive, stop the HTTP server
+// gracefully with a deadline, and only then release backend connections.
+// Stopping the server before deregistering would answer those late-routed
+// requests with connection refused.
+type Lifecycle struct {
+	drainDelay  time.Duration
+	stopTimeout time.Duration
+	deregister  func() error
+	stop        func(ctx context.Context) error
+	cleanups    []func() error
+	warn        func(format string, args ...interface{})
+	sleep       func(time.Duration)
+}
+
+// NewLifecycle builds a shutdown sequence. A zero stopTimeout falls back to
+// a 30 second deadline; a zero drainDelay skips the drain wait.
+func NewLifecycle(
+	drainDelay, stopTimeout time.Duration,
+	deregister func() error, stop func(ctx context.Context) error,
+	warn func(format string, args ...interface{}),
+) *Lifecycle {
+	if stopTimeout <= 0 {
+		stopTimeout = defaultStopTimeout
+	}
+
+	return &Lifecycle{
+		drainDelay:  drainDelay,
+		stopTimeout: stopTimeout,
+		deregister:  deregister,
+		stop:        stop,
+		warn:        warn,
+		sleep:       time.Sleep,
+	}
+}
+
+// OnCleanup appends a connection-releasing step (broker, cache, watcher)
+// executed after the HTTP server has stopped, in registration order.
+func (l *Lifecycle) OnCleanup(cleanup func() error) {
+	l.cleanups = append(l.cleanups, cleanup)
+}
+
+// Shutdown runs the sequence. Failures of individual steps are logged and
+// do not halt the rest of the shutdown; the server-stop error is returned
+// because it is the one that loses in-flight requests.
+func (l *Lifecycle) Shutdown(ctx context.Context) error {
+	if err := l.deregister(); err != nil {
+		l.warn("could not deregister from the registry: %s", err.Error())
+	}
+
+	if l.drainDelay > 0 {
+		l.sleep(l.drainDelay)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, l.stopTimeout)
+	defer cancel()
+	stopErr := l.stop(tctx)
+	if stopErr != nil {
+		l.warn("could not stop the http server gracefully: %s", stopErr.Error())
+	}
+
+	for _, cleanup := range l.cleanups {
+		if err := cleanup(); err != nil {
+			l.warn("shutdown cleanup failed: %s", err.Error())
+		}
+	}
+
+	return stopErr
+}
@@ -0,0 +1,121 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLifecycleShutdownOrdering(t *testing.T) {
+	var order []string
+
+	lifecycle := NewLifecycle(
+		2*time.Second, 10*time.Second,
+		func() error {
+			order = append(order, "deregister")
+			return nil
+		},
+		func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("expected the server stop to carry a deadline")
+			}
+			order = append(order, "stop")
+			return nil
+		},
+		func(format string, args ...interface{}) {},
+	)
+	lifecycle.sleep = func(d time.Duration) {
+		if d != 2*time.Second {
+			t.Errorf("expected a 2s drain delay, got %s", d)
+		}
+		order = append(order, "drain")
+	}
+	lifecycle.OnCleanup(func() error {
+		order = append(order, "broker")
+		return nil
+	})
+	lifecycle.OnCleanup(func() error {
+		order = append(order, "cache")
+		return nil
+	})
+
+	if err := lifecycle.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"deregister", "drain", "stop", "broker", "cache"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected shutdown order %v, got %v", expected, order)
+	}
+}
+
+// A failing registry must not keep the rest of the shutdown from running;
+// the pod is stopping either way.
+func TestLifecycleShutdownContinuesPastFailures(t *testing.T) {
+	var order []string
+	var warnings int
+
+	lifecycle := NewLifecycle(
+		0, time.Second,
+		func() error { return errors.New("registry unreachable") },
+		func(ctx context.Context) error {
+			order = append(order, "stop")
+			return nil
+		},
+		func(format string, args ...interface{}) { warnings++ },
+	)
+	lifecycle.sleep = func(time.Duration) {
+		order = append(order, "drain")
+	}
+	lifecycle.OnCleanup(func() error {
+		order = append(order, "cleanup")
+		return errors.New("already closed")
+	})
+
+	if err := lifecycle.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A zero drain delay skips the wait entirely.
+	expected := []string{"stop", "cleanup"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected shutdown order %v, got %v", expected, order)
+	}
+	if warnings != 2 {
+		t.Errorf("expected 2 warnings, got %d", warnings)
+	}
+}
+
+func TestLifecycleReturnsTheStopError(t *testing.T) {
+	stopErr := errors.New("deadline exceeded")
+	lifecycle := NewLifecycle(
+		0, time.Second,
+		func() error { return nil },
+		func(ctx context.Context) error { return stopErr },
+		func(format string, args ...interface{}) {},
+	)
+
+	if err := lifecycle.Shutdown(context.Background()); !errors.Is(err, stopErr) {
+		t.Fatalf("expected the stop error, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OnlyofficeSizeLimitsConfig caps how large a document may be to be opened
+// in the editor, in bytes per editor family. PDF gets its own limit because
+// the viewer handles it differently from other word documents. A zero
+// limit disables the check for that family.
+type OnlyofficeSizeLimitsConfig struct {
+	Word  int64 `yaml:"word" env:"ONLYOFFICE_LIMIT_WORD,overwrite"`
+	Cell  int64 `yaml:"cell" env:"ONLYOFFICE_LIMIT_CELL,overwrite"`
+	Slide int64 `yaml:"slide" env:"ONLYOFFICE_LIMIT_SLIDE,overwrite"`
+	PDF   int64 `yaml:"pdf" env:"ONLYOFFICE_LIMIT_PDF,overwrite"`
+	// MaxTitleLength caps generated filenames in runes; Drive accepts
+	// titles far longer than headers and the Document Server tolerate.
+	// Zero keeps the built-in default.
+	MaxTitleLength int `yaml:"max_title_length" env:"ONLYOFFICE_LIMIT_MAX_TITLE_LENGTH,overwrite"`
+}
+
+// OpenSizeLimit returns the byte limit applying to a file of the given
+// Document Server type ("word"/"cell"/"slide") and extension. Zero means
+// unlimited.
+func (l OnlyofficeSizeLimitsConfig) OpenSizeLimit(fileType, ext string) int64 {
+	if strings.EqualFold(ext, "pdf") {
+		return l.PDF
+	}
+	switch fileType {
+	case "word":
+		return l.Word
+	case "cell":
+		return l.Cell
+	case "slide":
+		return l.Slide
+	default:
+		return 0
+	}
+}
+
+// ExceedsOpenSizeLimit reports whether a file of the given size may not be
+// opened. Unknown sizes (zero, e.g. Google-native files without an export
+// estimate) always pass.
+func (l OnlyofficeSizeLimitsConfig) ExceedsOpenSizeLimit(fileType, ext string, size int64) bool {
+	limit := l.OpenSizeLimit(fileType, ext)
+	return limit > 0 && size > limit
+}
+
+// HumanReadableSize formats a byte limit for user-facing messages.
+func HumanReadableSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(size)/float64(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/float64(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/float64(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
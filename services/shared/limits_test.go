@@ -0,0 +1,79 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import "testing"
+
+func TestExceedsOpenSizeLimit(t *testing.T) {
+	limits := OnlyofficeSizeLimitsConfig{
+		Word:  100,
+		Cell:  50,
+		Slide: 80,
+		PDF:   200,
+	}
+
+	for _, tc := range []struct {
+		name     string
+		fileType string
+		ext      string
+		size     int64
+		exceeds  bool
+	}{
+		{"word under", "word", "docx", 99, false},
+		{"word at limit", "word", "docx", 100, false},
+		{"word over", "word", "docx", 101, true},
+		{"cell under", "cell", "xlsx", 49, false},
+		{"cell at limit", "cell", "xlsx", 50, false},
+		{"cell over", "cell", "xlsx", 51, true},
+		{"slide over", "slide", "pptx", 81, true},
+		// PDF belongs to the word family but has its own limit.
+		{"pdf between word and pdf limits", "word", "pdf", 150, false},
+		{"pdf over", "word", "pdf", 201, true},
+		// Google-native files report no size and always pass.
+		{"unknown size", "word", "docx", 0, false},
+		{"unknown family", "", "", 1 << 40, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := limits.ExceedsOpenSizeLimit(tc.fileType, tc.ext, tc.size); got != tc.exceeds {
+				t.Errorf(
+					"ExceedsOpenSizeLimit(%q, %q, %d) = %t, want %t",
+					tc.fileType, tc.ext, tc.size, got, tc.exceeds,
+				)
+			}
+		})
+	}
+
+	var unlimited OnlyofficeSizeLimitsConfig
+	if unlimited.ExceedsOpenSizeLimit("word", "docx", 1<<40) {
+		t.Error("a zero limit must disable the check")
+	}
+}
+
+func TestHumanReadableSize(t *testing.T) {
+	for size, want := range map[int64]string{
+		512:       "512 B",
+		2048:      "2.0 KB",
+		110000000: "104.9 MB",
+		1 << 31:   "2.0 GB",
+	} {
+		if got := HumanReadableSize(size); got != want {
+			t.Errorf("HumanReadableSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
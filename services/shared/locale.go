@@ -0,0 +1,111 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import "strings"
+
+// languageAliases maps the deprecated ISO 639 codes Google accounts still
+// report onto their modern replacements.
+var languageAliases = map[string]string{
+	"in": "id",
+	"iw": "he",
+	"mo": "ro",
+	"no": "nb",
+}
+
+// dsLanguages lists the language codes the Document Server accepts for its
+// editorConfig.lang parameter; anything else makes it fall back to an
+// English UI even when the language is actually supported under another
+// code.
+var dsLanguages = map[string]bool{
+	"ar": true, "az": true, "be": true, "bg": true, "ca": true,
+	"cs": true, "da": true, "de": true, "el": true, "en": true,
+	"es": true, "eu": true, "fi": true, "fr": true, "gl": true,
+	"he": true, "hu": true, "hy": true, "id": true, "it": true,
+	"ja": true, "ko": true, "lo": true, "lv": true, "ms": true,
+	"nb": true, "nl": true, "pl": true, "pt": true, "ro": true,
+	"ru": true, "si": true, "sk": true, "sl": true, "sr": true,
+	"sv": true, "tr": true, "uk": true, "ur": true, "vi": true,
+	"zh": true,
+}
+
+// BaseLanguage extracts the base language from a locale string as Google
+// reports them: "pt-BR" and "pt_BR" become "pt", deprecated codes map to
+// their replacements ("no" to "nb", "iw" to "he"), and junk like "c" or
+// "POSIX" comes back empty rather than posing as a language.
+func BaseLanguage(locale string) string {
+	normalized := strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	base := strings.ToLower(strings.Split(normalized, "-")[0])
+	if alias, ok := languageAliases[base]; ok {
+		base = alias
+	}
+
+	if len(base) < 2 || len(base) > 3 {
+		return ""
+	}
+	for _, r := range base {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return base
+}
+
+// DSLanguage normalizes a locale string into a value the Document Server's
+// lang parameter accepts. Unknown and malformed locales fall back to "en"
+// explicitly, which is what the Document Server would silently do anyway —
+// minus the supported languages it would take down with them. Chinese keeps
+// its script distinction, since "zh" alone selects the simplified UI.
+func DSLanguage(locale string) string {
+	normalized := strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	base := BaseLanguage(normalized)
+	if !dsLanguages[base] {
+		return "en"
+	}
+
+	switch base {
+	case "zh":
+		if chineseTraditional(normalized) {
+			return "zh-TW"
+		}
+	case "pt":
+		for _, tag := range localeSubtags(normalized) {
+			if tag == "br" {
+				return "pt-BR"
+			}
+		}
+	}
+	return base
+}
+
+// chineseTraditional reports whether the locale's script or region subtags
+// select the traditional Chinese UI, e.g. "zh-Hant-TW" or "zh-HK".
+func chineseTraditional(locale string) bool {
+	for _, tag := range localeSubtags(locale) {
+		switch tag {
+		case "hant", "tw", "hk", "mo":
+			return true
+		}
+	}
+	return false
+}
+
+func localeSubtags(locale string) []string {
+	return strings.Split(strings.ToLower(locale), "-")[1:]
+}
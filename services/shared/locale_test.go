@@ -0,0 +1,61 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import "testing"
+
+func TestDSLanguage(t *testing.T) {
+	tests := []struct {
+		locale string
+		base   string
+		ds     string
+	}{
+		{"en", "en", "en"},
+		{"en-US", "en", "en"},
+		{"pt_BR", "pt", "pt-BR"},
+		{"pt-PT", "pt", "pt"},
+		{"zh-CN", "zh", "zh"},
+		{"zh-Hant-TW", "zh", "zh-TW"},
+		{"zh-HK", "zh", "zh-TW"},
+		// Deprecated codes Google accounts still report.
+		{"no", "nb", "nb"},
+		{"no-NO", "nb", "nb"},
+		{"iw", "he", "he"},
+		{"in-ID", "id", "id"},
+		// Supported language with an unexpected region.
+		{"de-LI", "de", "de"},
+		// Real language the Document Server does not ship a UI for.
+		{"sw-KE", "sw", "en"},
+		// Junk that must not reach the lang parameter.
+		{"c", "", "en"},
+		{"POSIX", "", "en"},
+		{"", "", "en"},
+		{"123", "", "en"},
+		{"TW", "tw", "en"},
+	}
+
+	for _, test := range tests {
+		if got := BaseLanguage(test.locale); got != test.base {
+			t.Errorf("BaseLanguage(%q) = %q, expected %q", test.locale, got, test.base)
+		}
+		if got := DSLanguage(test.locale); got != test.ds {
+			t.Errorf("DSLanguage(%q) = %q, expected %q", test.locale, got, test.ds)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import "strings"
+
+// Mime constants for Google Workspace native documents.
+const (
+	GoogleDocumentMime     = "application/vnd.google-apps.document"
+	GoogleSpreadsheetMime  = "application/vnd.google-apps.spreadsheet"
+	GooglePresentationMime = "application/vnd.google-apps.presentation"
+	GoogleFolderMime       = "application/vnd.google-apps.folder"
+	GoogleShortcutMime     = "application/vnd.google-apps.shortcut"
+)
+
+// mimeEntry ties a file extension to its mime type. The table is ordered:
+// when several extensions share a mime type (doc/dot, ppt/pot/pps), the
+// first entry is the canonical extension MimeToExtension reports.
+type mimeEntry struct {
+	extension string
+	mime      string
+}
+
+// mimeTable is the single source of truth for extension/mime mapping. It
+// deliberately avoids mime.TypeByExtension, whose answers depend on the
+// host OS mime database.
+var mimeTable = []mimeEntry{
+	{"docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	{"docxf", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	{"doc", "application/msword"},
+	{"dot", "application/msword"},
+	{"docm", "application/vnd.ms-word.document.macroEnabled.12"},
+	{"dotm", "application/vnd.ms-word.template.macroEnabled.12"},
+	{"dotx", "application/vnd.openxmlformats-officedocument.wordprocessingml.template"},
+	{"epub", "application/epub+zip"},
+	{"fb2", "application/x-fictionbook+xml"},
+	{"fodt", "application/vnd.oasis.opendocument.text-flat-xml"},
+	{"html", "text/html"},
+	{"mht", "message/rfc822"},
+	{"eml", "message/rfc822"},
+	{"odt", "application/vnd.oasis.opendocument.text"},
+	{"ott", "application/vnd.oasis.opendocument.text-template"},
+	{"oxps", "application/oxps"},
+	{"pdf", "application/pdf"},
+	{"rtf", "application/rtf"},
+	{"txt", "text/plain"},
+	{"xml", "application/xml"},
+	{"xps", "application/vnd.ms-xpsdocument"},
+
+	{"xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	{"xls", "application/vnd.ms-excel"},
+	{"xlt", "application/vnd.ms-excel"},
+	{"xlsb", "application/vnd.ms-excel.sheet.binary.macroEnabled.12"},
+	{"xlsm", "application/vnd.ms-excel.sheet.macroEnabled.12"},
+	{"xltm", "application/vnd.ms-excel.template.macroEnabled.12"},
+	{"xltx", "application/vnd.openxmlformats-officedocument.spreadsheetml.template"},
+	{"csv", "text/csv"},
+	{"fods", "application/vnd.oasis.opendocument.spreadsheet-flat-xml"},
+	{"ods", "application/vnd.oasis.opendocument.spreadsheet"},
+	{"ots", "application/vnd.oasis.opendocument.spreadsheet-template"},
+
+	{"pptx", "application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+	{"ppt", "application/vnd.ms-powerpoint"},
+	{"pot", "application/vnd.ms-powerpoint"},
+	{"pps", "application/vnd.ms-powerpoint"},
+	{"potm", "application/vnd.ms-powerpoint.template.macroEnabled.12"},
+	{"potx", "application/vnd.openxmlformats-officedocument.presentationml.template"},
+	{"ppsm", "application/vnd.ms-powerpoint.slideshow.macroEnabled.12"},
+	{"ppsx", "application/vnd.openxmlformats-officedocument.presentationml.slideshow"},
+	{"pptm", "application/vnd.ms-powerpoint.presentation.macroEnabled.12"},
+	{"fodp", "application/vnd.oasis.opendocument.presentation-flat-xml"},
+	{"odp", "application/vnd.oasis.opendocument.presentation"},
+	{"otp", "application/vnd.oasis.opendocument.presentation-template"},
+}
+
+// googleExports maps Google Workspace native mime types to the OOXML mime
+// types the integration exports them as.
+var googleExports = map[string]string{
+	GoogleDocumentMime:     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	GoogleSpreadsheetMime:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	GooglePresentationMime: "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+var extensionMimes = func() map[string]string {
+	m := make(map[string]string, len(mimeTable))
+	for _, entry := range mimeTable {
+		m[entry.extension] = entry.mime
+	}
+	return m
+}()
+
+var mimeExtensions = func() map[string]string {
+	m := make(map[string]string, len(mimeTable))
+	for _, entry := range mimeTable {
+		if _, ok := m[entry.mime]; !ok {
+			m[entry.mime] = entry.extension
+		}
+	}
+	return m
+}()
+
+// ExtensionToMime returns the mime type for a supported file extension or
+// an empty string for unknown extensions.
+func ExtensionToMime(ext string) string {
+	return extensionMimes[strings.ToLower(strings.TrimPrefix(ext, "."))]
+}
+
+// MimeToExtension returns the canonical extension for a mime type or an
+// empty string for unknown types. Google Workspace native types resolve to
+// the extension of their OOXML export.
+func MimeToExtension(mime string) string {
+	if export, ok := googleExports[mime]; ok {
+		mime = export
+	}
+	return mimeExtensions[mime]
+}
+
+// GoogleExportMime returns the OOXML mime type a Google-native document is
+// exported as, and whether such an export exists. Native objects without an
+// OOXML counterpart (forms, maps, drawings) report false.
+func GoogleExportMime(mime string) (string, bool) {
+	export, ok := googleExports[mime]
+	return export, ok
+}
+
+// IsGoogleNative reports whether the mime type denotes a Google Workspace
+// native object rather than a regular Drive file.
+func IsGoogleNative(mime string) bool {
+	return strings.HasPrefix(mime, "application/vnd.google-apps.")
+}
+
+// EffectiveExtension decides which extension a file's content should be
+// treated as, consuming both metadata signals. The mime type wins: certain
+// imports leave a Google-native file with a misleading FileExtension, and
+// its content is only ever served through the export link, so the export's
+// extension is the truthful one. For regular files the declared extension
+// stands, with the mime table as fallback when it is empty. The second
+// return reports that the two signals disagreed, so every branch point can
+// log the same conflict instead of silently resolving it differently.
+func EffectiveExtension(fileExtension, mimeType string) (string, bool) {
+	if IsGoogleNative(mimeType) {
+		native := ""
+		if exportMime, ok := GoogleExportMime(mimeType); ok {
+			native = MimeToExtension(exportMime)
+		}
+		return native, fileExtension != "" && fileExtension != native
+	}
+
+	if fileExtension != "" {
+		return fileExtension, false
+	}
+	return MimeToExtension(mimeType), false
+}
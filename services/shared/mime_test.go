@@ -0,0 +1,176 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+)
+
+// Every extension the file utility accepts must resolve to a mime type, and
+// every table entry must be an extension the editor can actually open.
+func TestMimeTableCoversSupportedExtensions(t *testing.T) {
+	utility := files.NewOnlyofficeFileUtility()
+
+	for _, ext := range utility.SupportedExtensions() {
+		if ExtensionToMime(ext) == "" {
+			t.Errorf("supported extension %q has no mime entry", ext)
+		}
+	}
+
+	for _, entry := range mimeTable {
+		if !utility.IsExtensionSupported(entry.extension) {
+			t.Errorf("mime table extension %q is not supported by the file utility", entry.extension)
+		}
+	}
+}
+
+func TestExtensionToMime(t *testing.T) {
+	for _, tc := range []struct {
+		ext  string
+		mime string
+	}{
+		{"docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"DOCX", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{".csv", "text/csv"},
+		{"pptx", "application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+		{"exe", ""},
+		{"", ""},
+	} {
+		if got := ExtensionToMime(tc.ext); got != tc.mime {
+			t.Errorf("ExtensionToMime(%q) = %q, want %q", tc.ext, got, tc.mime)
+		}
+	}
+}
+
+func TestMimeToExtension(t *testing.T) {
+	for _, tc := range []struct {
+		mime string
+		ext  string
+	}{
+		{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"},
+		// Ambiguous mime types resolve to their canonical extension.
+		{"application/msword", "doc"},
+		{"application/vnd.ms-powerpoint", "ppt"},
+		// Google Workspace natives resolve through their OOXML export.
+		{GoogleDocumentMime, "docx"},
+		{GoogleSpreadsheetMime, "xlsx"},
+		{GooglePresentationMime, "pptx"},
+		{GoogleFolderMime, ""},
+		{"application/octet-stream", ""},
+	} {
+		if got := MimeToExtension(tc.mime); got != tc.ext {
+			t.Errorf("MimeToExtension(%q) = %q, want %q", tc.mime, got, tc.ext)
+		}
+	}
+}
+
+func TestMimeRoundTrip(t *testing.T) {
+	for _, entry := range mimeTable {
+		ext := MimeToExtension(entry.mime)
+		if ext == "" {
+			t.Errorf("mime %q has no reverse extension", entry.mime)
+			continue
+		}
+		if ExtensionToMime(ext) != entry.mime {
+			t.Errorf("round trip for %q yielded %q with mime %q", entry.extension, ext, ExtensionToMime(ext))
+		}
+	}
+}
+
+func TestIsGoogleNative(t *testing.T) {
+	for mime, native := range map[string]bool{
+		GoogleDocumentMime: true,
+		GoogleFolderMime:   true,
+		GoogleShortcutMime: true,
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": false,
+		"text/plain": false,
+	} {
+		if IsGoogleNative(mime) != native {
+			t.Errorf("IsGoogleNative(%q) = %t, want %t", mime, !native, native)
+		}
+	}
+}
+
+// EffectiveExtension is the single decision point for the native-vs-binary
+// branch; the table enumerates the ways the two metadata signals can
+// disagree and pins one answer for all of them.
+func TestEffectiveExtension(t *testing.T) {
+	for name, tc := range map[string]struct {
+		fileExtension string
+		mimeType      string
+		want          string
+		mismatch      bool
+	}{
+		"regular file": {
+			fileExtension: "xlsx",
+			mimeType:      "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			want:          "xlsx",
+		},
+		"native without extension": {
+			fileExtension: "",
+			mimeType:      GoogleSpreadsheetMime,
+			want:          "xlsx",
+		},
+		"native with misleading extension": {
+			fileExtension: "xlsx",
+			mimeType:      GoogleSpreadsheetMime,
+			want:          "xlsx",
+			mismatch:      true,
+		},
+		"native document named like a binary": {
+			fileExtension: "doc",
+			mimeType:      GoogleDocumentMime,
+			want:          "docx",
+			mismatch:      true,
+		},
+		"native without an export": {
+			fileExtension: "form",
+			mimeType:      "application/vnd.google-apps.form",
+			want:          "",
+			mismatch:      true,
+		},
+		"no extension, known mime": {
+			fileExtension: "",
+			mimeType:      "application/pdf",
+			want:          "pdf",
+		},
+		"no extension, unknown mime": {
+			fileExtension: "",
+			mimeType:      "application/octet-stream",
+			want:          "",
+		},
+		"declared extension wins for regular files": {
+			fileExtension: "xls",
+			mimeType:      "application/vnd.ms-excel",
+			want:          "xls",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, mismatch := EffectiveExtension(tc.fileExtension, tc.mimeType)
+			if got != tc.want || mismatch != tc.mismatch {
+				t.Errorf(
+					"EffectiveExtension(%q, %q) = %q, %t, want %q, %t",
+					tc.fileExtension, tc.mimeType, got, mismatch, tc.want, tc.mismatch,
+				)
+			}
+		})
+	}
+}
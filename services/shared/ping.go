@@ -0,0 +1,78 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+)
+
+// GatewayURLHash digests a gateway public URL so services can compare
+// their configured URLs without shipping them over the wire. Trailing
+// slashes are ignored.
+func GatewayURLHash(gatewayURL string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSuffix(gatewayURL, "/")))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckGatewayURL calls the gateway ping endpoint at the configured
+// gateway URL and reports an error when the gateway is unreachable or was
+// configured with a different public URL. A copy-paste mismatch between
+// the builder's and gateway's ONLYOFFICE_GATEWAY_URL otherwise only shows
+// up as Document Server download failures much later.
+func CheckGatewayURL(ctx context.Context, gatewayURL string) error {
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet,
+		fmt.Sprintf("%s/api/ping", strings.TrimSuffix(gatewayURL, "/")), nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("gateway at %s is unreachable: %w", gatewayURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gateway at %s answered ping with status %d", gatewayURL, resp.StatusCode)
+	}
+
+	var ping response.PingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ping); err != nil {
+		return fmt.Errorf("gateway at %s answered ping with an invalid body: %w", gatewayURL, err)
+	}
+
+	if ping.URLHash != GatewayURLHash(gatewayURL) {
+		return fmt.Errorf(
+			"gateway at %s reports a different configured public url (version %s); check ONLYOFFICE_GATEWAY_URL on both services",
+			gatewayURL, ping.Version,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+)
+
+func newPingServer(t *testing.T, configuredURL func(self string) string) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/ping" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(response.PingResponse{
+			Version: Version,
+			URLHash: GatewayURLHash(configuredURL(server.URL)),
+		}.ToJSON())
+	}))
+	return server
+}
+
+func TestCheckGatewayURLMatch(t *testing.T) {
+	server := newPingServer(t, func(self string) string { return self })
+	defer server.Close()
+
+	if err := CheckGatewayURL(context.Background(), server.URL); err != nil {
+		t.Fatalf("expected matching urls to pass, got %s", err.Error())
+	}
+
+	// Trailing slashes are cosmetic and must not trip the check.
+	if err := CheckGatewayURL(context.Background(), server.URL+"/"); err != nil {
+		t.Fatalf("expected a trailing slash to pass, got %s", err.Error())
+	}
+}
+
+func TestCheckGatewayURLMismatch(t *testing.T) {
+	server := newPingServer(t, func(string) string { return "https://drive.other.example.com" })
+	defer server.Close()
+
+	err := CheckGatewayURL(context.Background(), server.URL)
+	if err == nil {
+		t.Fatal("expected a mismatch error")
+	}
+	if !strings.Contains(err.Error(), "different configured public url") {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestCheckGatewayURLUnreachable(t *testing.T) {
+	server := newPingServer(t, func(self string) string { return self })
+	server.Close()
+
+	if err := CheckGatewayURL(context.Background(), server.URL); err == nil {
+		t.Fatal("expected an error for an unreachable gateway")
+	}
+}
@@ -0,0 +1,200 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package quota tracks Google API consumption per Drive method and slows
+// down non-interactive calls before the per-user and per-project limits are
+// exhausted.
+package quota
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/api/googleapi"
+)
+
+// ScopeUser and ScopeProject classify quota-exceeded errors by the limit
+// they hit.
+const (
+	ScopeUser    = "user"
+	ScopeProject = "project"
+)
+
+// window is a one-minute sliding window counter with per-second buckets.
+type window struct {
+	mu      sync.Mutex
+	size    time.Duration
+	buckets map[int64]int
+}
+
+func newWindow(size time.Duration) *window {
+	return &window{
+		size:    size,
+		buckets: make(map[int64]int),
+	}
+}
+
+func (w *window) observe(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buckets[now.Unix()]++
+	w.evict(now)
+}
+
+func (w *window) count(now time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.evict(now)
+	total := 0
+	for _, count := range w.buckets {
+		total += count
+	}
+
+	return total
+}
+
+func (w *window) evict(now time.Time) {
+	edge := now.Add(-w.size).Unix()
+	for second := range w.buckets {
+		if second <= edge {
+			delete(w.buckets, second)
+		}
+	}
+}
+
+// Monitor counts Drive API calls per method and decides when to start
+// delaying non-interactive traffic.
+type Monitor struct {
+	mu        sync.Mutex
+	windows   map[string]*window
+	limit     int
+	threshold float64
+	now       func() time.Time
+
+	calls       *prometheus.GaugeVec
+	quotaErrors *prometheus.CounterVec
+}
+
+// NewMonitor builds a Monitor delaying non-interactive calls once the
+// per-minute call count passes threshold*limit. A limit of zero disables
+// throttling but keeps the telemetry.
+func NewMonitor(limit int, threshold float64) *Monitor {
+	return &Monitor{
+		windows:   make(map[string]*window),
+		limit:     limit,
+		threshold: threshold,
+		now:       time.Now,
+		calls: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "onlyoffice_drive_api_calls_per_minute",
+			Help: "Sliding-window Google Drive API calls per minute.",
+		}, []string{"method"}),
+		quotaErrors: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "onlyoffice_drive_api_quota_errors_total",
+			Help: "Google API quota-exceeded errors by limit scope.",
+		}, []string{"method", "scope"}),
+	}
+}
+
+// Observe records a Drive API call about to be made with the given method
+// name, e.g. "files.get".
+func (m *Monitor) Observe(method string) {
+	now := m.now()
+
+	m.mu.Lock()
+	w, ok := m.windows[method]
+	if !ok {
+		w = newWindow(time.Minute)
+		m.windows[method] = w
+	}
+	m.mu.Unlock()
+
+	w.observe(now)
+	m.calls.WithLabelValues(method).Set(float64(w.count(now)))
+}
+
+// ObserveError inspects a failed call and counts quota-exceeded errors by
+// scope.
+func (m *Monitor) ObserveError(method string, err error) {
+	if scope := QuotaScope(err); scope != "" {
+		m.quotaErrors.WithLabelValues(method, scope).Inc()
+	}
+}
+
+// rate returns the total calls across all methods within the window.
+func (m *Monitor) rate() int {
+	now := m.now()
+	total := 0
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.windows {
+		total += w.count(now)
+	}
+
+	return total
+}
+
+// ShouldThrottle reports whether non-interactive calls should be delayed.
+func (m *Monitor) ShouldThrottle() bool {
+	if m.limit <= 0 {
+		return false
+	}
+
+	return float64(m.rate()) >= m.threshold*float64(m.limit)
+}
+
+// Throttle sleeps a jittered delay before a non-interactive call when the
+// consumption is nearing the limit. Interactive calls are never delayed.
+func (m *Monitor) Throttle(ctx context.Context, interactive bool) {
+	if interactive || !m.ShouldThrottle() {
+		return
+	}
+
+	delay := time.Duration(500+rand.Intn(1500)) * time.Millisecond
+	select {
+	case <-ctx.Done():
+	case <-time.After(delay):
+	}
+}
+
+// QuotaScope classifies a googleapi error as a user or project quota limit,
+// returning an empty string for everything else.
+func QuotaScope(err error) string {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) || gerr.Code != 403 {
+		return ""
+	}
+
+	for _, item := range gerr.Errors {
+		switch item.Reason {
+		case "userRateLimitExceeded":
+			return ScopeUser
+		case "rateLimitExceeded", "dailyLimitExceeded", "quotaExceeded":
+			return ScopeProject
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,138 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package quota
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestWindowCounter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	w := newWindow(time.Minute)
+
+	for i := 0; i < 5; i++ {
+		w.observe(now)
+	}
+	if got := w.count(now); got != 5 {
+		t.Fatalf("expected 5 calls in window, got %d", got)
+	}
+
+	w.observe(now.Add(30 * time.Second))
+	if got := w.count(now.Add(30 * time.Second)); got != 6 {
+		t.Fatalf("expected 6 calls in window, got %d", got)
+	}
+
+	// The first five observations fall off the sliding edge.
+	if got := w.count(now.Add(61 * time.Second)); got != 1 {
+		t.Fatalf("expected 1 call after expiry, got %d", got)
+	}
+
+	if got := w.count(now.Add(5 * time.Minute)); got != 0 {
+		t.Fatalf("expected empty window, got %d", got)
+	}
+}
+
+func TestShouldThrottle(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		threshold float64
+		calls     int
+		expected  bool
+	}{
+		{name: "disabled monitor never throttles", limit: 0, threshold: 0.8, calls: 1000, expected: false},
+		{name: "idle monitor", limit: 100, threshold: 0.8, calls: 0, expected: false},
+		{name: "below threshold", limit: 100, threshold: 0.8, calls: 79, expected: false},
+		{name: "at threshold", limit: 100, threshold: 0.8, calls: 80, expected: true},
+		{name: "above threshold", limit: 100, threshold: 0.8, calls: 200, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Monitor{
+				windows:   map[string]*window{},
+				limit:     tt.limit,
+				threshold: tt.threshold,
+				now:       func() time.Time { return time.Unix(2000, 0) },
+			}
+
+			w := newWindow(time.Minute)
+			for i := 0; i < tt.calls; i++ {
+				w.observe(time.Unix(2000, 0))
+			}
+			m.windows["files.get"] = w
+
+			if got := m.ShouldThrottle(); got != tt.expected {
+				t.Fatalf("expected throttle=%v with %d/%d calls", tt.expected, tt.calls, tt.limit)
+			}
+		})
+	}
+}
+
+func TestQuotaScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name: "user rate limit",
+			err: &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{
+				{Reason: "userRateLimitExceeded"},
+			}},
+			expected: ScopeUser,
+		},
+		{
+			name: "project rate limit",
+			err: &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{
+				{Reason: "rateLimitExceeded"},
+			}},
+			expected: ScopeProject,
+		},
+		{
+			name: "daily limit",
+			err: &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{
+				{Reason: "dailyLimitExceeded"},
+			}},
+			expected: ScopeProject,
+		},
+		{
+			name:     "permission denied",
+			err:      &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "forbidden"}}},
+			expected: "",
+		},
+		{
+			name:     "not a googleapi error",
+			err:      errors.New("boom"),
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuotaScope(tt.err); got != tt.expected {
+				t.Fatalf("expected scope %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
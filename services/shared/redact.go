@@ -0,0 +1,54 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Redact masks a secret for logging, keeping only the first and last four
+// characters. Secrets too short to partially reveal are fully masked.
+// Access tokens, refresh tokens and JWTs must never reach log output
+// unredacted.
+func Redact(secret string) string {
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return secret[:4] + "****" + secret[len(secret)-4:]
+}
+
+// RedactURL masks token-bearing query parameters of a URL for logging.
+// Unparseable input is fully masked rather than leaked as-is.
+func RedactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "****"
+	}
+
+	query := parsed.Query()
+	for key := range query {
+		if strings.Contains(strings.ToLower(key), "token") {
+			query.Set(key, Redact(query.Get(key)))
+		}
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
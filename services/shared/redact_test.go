@@ -0,0 +1,101 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	for _, tc := range []struct {
+		secret string
+		want   string
+	}{
+		{"ya29.a0AfB_byDEADBEEFdeadbeef", "ya29****beef"},
+		{"short", "****"},
+		{"", "****"},
+	} {
+		if got := Redact(tc.secret); got != tc.want {
+			t.Errorf("Redact(%q) = %q, want %q", tc.secret, got, tc.want)
+		}
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	redacted := RedactURL("https://gateway.example.com/api/download?token=eyJhbGciOiJIUzI1NiJ9.payload.signature&id=5")
+	if strings.Contains(redacted, "eyJhbGciOiJIUzI1NiJ9.payload.signature") {
+		t.Fatalf("token survived redaction: %s", redacted)
+	}
+	if !strings.Contains(redacted, "id=5") {
+		t.Fatalf("non-sensitive query parameters must survive: %s", redacted)
+	}
+
+	if got := RedactURL("://not-a-url"); got != "****" {
+		t.Fatalf("unparseable input must be fully masked, got %q", got)
+	}
+}
+
+// Raw credential fields must never be interpolated into log calls. The
+// test greps the audited services for logger calls that reference token
+// fields without going through Redact/RedactURL.
+func TestNoRawTokenLogging(t *testing.T) {
+	logCall := regexp.MustCompile(`\.(Debugf|Infof|Warnf|Errorf|Fatalf)\(`)
+	tokenField := regexp.MustCompile(`\.(AccessToken|RefreshToken|Token)\b`)
+
+	for _, root := range []string{"../auth", "../gateway", "../callback"} {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(path, ".go") ||
+				strings.HasSuffix(path, "_test.go") {
+				return err
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			scanner := bufio.NewScanner(file)
+			line := 0
+			inCall := false
+			for scanner.Scan() {
+				line++
+				text := scanner.Text()
+				if logCall.MatchString(text) {
+					inCall = true
+				}
+				if inCall && tokenField.MatchString(text) && !strings.Contains(text, "Redact") {
+					t.Errorf("%s:%d passes a raw token field to the logger", path, line)
+				}
+				if inCall && strings.Count(text, ")") >= strings.Count(text, "(") {
+					inCall = false
+				}
+			}
+			return scanner.Err()
+		})
+		if err != nil {
+			t.Fatalf("could not audit %s: %s", root, err)
+		}
+	}
+}
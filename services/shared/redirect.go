@@ -0,0 +1,73 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ResolveRedirectURI picks the effective redirect URI for this deployment
+// when the credentials list several — staging and production sharing one
+// Google client each register their own redirect endpoint. The candidate
+// whose host and path match the gateway's own /oauth/redirect endpoint
+// wins; a non-negative RedirectURIIndex pins the choice by position
+// instead. An empty list keeps the single RedirectURI untouched, and no
+// match at all is a configuration error rather than a silent first-entry
+// fallback.
+func (cc *CredentialsConfig) ResolveRedirectURI(gatewayURL string) error {
+	uris := cc.Credentials.RedirectURIs
+	if len(uris) == 0 {
+		return nil
+	}
+
+	if index := cc.Credentials.RedirectURIIndex; index >= 0 {
+		if index >= len(uris) {
+			return fmt.Errorf(
+				"redirect uri index %d is out of range, %d redirect uris are configured",
+				index, len(uris),
+			)
+		}
+		cc.Credentials.RedirectURI = uris[index]
+		return nil
+	}
+
+	expected, err := url.Parse(strings.TrimSuffix(gatewayURL, "/") + "/oauth/redirect")
+	if err != nil {
+		return fmt.Errorf("could not parse the gateway url %q: %w", gatewayURL, err)
+	}
+
+	for _, candidate := range uris {
+		parsed, err := url.Parse(candidate)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(parsed.Host, expected.Host) &&
+			strings.TrimSuffix(parsed.Path, "/") == strings.TrimSuffix(expected.Path, "/") {
+			cc.Credentials.RedirectURI = candidate
+			return nil
+		}
+	}
+
+	return fmt.Errorf(
+		"none of the %d configured redirect uris points at the gateway %q",
+		len(uris), gatewayURL,
+	)
+}
@@ -0,0 +1,99 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import "testing"
+
+func newRedirectCredentials(uris []string, index int) *CredentialsConfig {
+	credentials := &CredentialsConfig{}
+	credentials.Credentials.RedirectURI = "https://configured.example.com/oauth/redirect"
+	credentials.Credentials.RedirectURIs = uris
+	credentials.Credentials.RedirectURIIndex = index
+	return credentials
+}
+
+func TestResolveRedirectURIPicksTheGatewayMatch(t *testing.T) {
+	credentials := newRedirectCredentials([]string{
+		"https://staging.example.com/oauth/redirect",
+		"https://drive.example.com/oauth/redirect",
+	}, -1)
+
+	if err := credentials.ResolveRedirectURI("https://drive.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := credentials.Credentials.RedirectURI; got != "https://drive.example.com/oauth/redirect" {
+		t.Errorf("expected the production entry, got %q", got)
+	}
+}
+
+func TestResolveRedirectURIMatchesSubPathDeployments(t *testing.T) {
+	credentials := newRedirectCredentials([]string{
+		"https://apps.example.com/other/oauth/redirect",
+		"https://apps.example.com/drive/oauth/redirect",
+	}, -1)
+
+	if err := credentials.ResolveRedirectURI("https://apps.example.com/drive/"); err != nil {
+		t.Fatal(err)
+	}
+	if got := credentials.Credentials.RedirectURI; got != "https://apps.example.com/drive/oauth/redirect" {
+		t.Errorf("expected the sub-path entry, got %q", got)
+	}
+}
+
+func TestResolveRedirectURIErrorsWithoutMatch(t *testing.T) {
+	credentials := newRedirectCredentials([]string{
+		"https://staging.example.com/oauth/redirect",
+	}, -1)
+
+	if err := credentials.ResolveRedirectURI("https://drive.example.com"); err == nil {
+		t.Error("expected no matching entry to be a configuration error")
+	}
+}
+
+func TestResolveRedirectURIHonorsIndexOverride(t *testing.T) {
+	credentials := newRedirectCredentials([]string{
+		"https://staging.example.com/oauth/redirect",
+		"https://drive.example.com/oauth/redirect",
+	}, 0)
+
+	// The pinned entry wins even though the second one matches the gateway.
+	if err := credentials.ResolveRedirectURI("https://drive.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := credentials.Credentials.RedirectURI; got != "https://staging.example.com/oauth/redirect" {
+		t.Errorf("expected the pinned entry, got %q", got)
+	}
+
+	if err := newRedirectCredentials([]string{
+		"https://staging.example.com/oauth/redirect",
+	}, 5).ResolveRedirectURI("https://drive.example.com"); err == nil {
+		t.Error("expected an out-of-range index to be rejected")
+	}
+}
+
+func TestResolveRedirectURIKeepsSingleEntryConfigs(t *testing.T) {
+	credentials := newRedirectCredentials(nil, -1)
+
+	if err := credentials.ResolveRedirectURI("https://drive.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := credentials.Credentials.RedirectURI; got != "https://configured.example.com/oauth/redirect" {
+		t.Errorf("expected the single redirect uri untouched, got %q", got)
+	}
+}
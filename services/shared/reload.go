@@ -0,0 +1,153 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// OnlyofficeConfigSource holds the current OnlyofficeConfig behind an
+// atomic pointer so non-critical settings can be hot-reloaded. Handlers
+// must read the config through Config() on every request instead of
+// capturing the struct pointer at construction time.
+//
+// Credentials, secrets and service addresses are restart-only: Reload keeps
+// the previously loaded values for them and reports changes through the
+// warn callback.
+type OnlyofficeConfigSource struct {
+	path    string
+	current atomic.Pointer[OnlyofficeConfig]
+	sem     *ResizableSemaphore
+	warn    func(format string, args ...interface{})
+}
+
+// NewOnlyofficeConfigSource loads the initial configuration from path. The
+// warn callback receives messages about ignored restart-only changes and
+// failed reloads; it must not be nil.
+func NewOnlyofficeConfigSource(path string, warn func(format string, args ...interface{})) (*OnlyofficeConfigSource, error) {
+	config, err := BuildNewOnlyofficeConfig(path)()
+	if err != nil {
+		return nil, err
+	}
+
+	source := &OnlyofficeConfigSource{
+		path: path,
+		sem:  NewResizableSemaphore(config.Onlyoffice.Builder.AllowedDownloads),
+		warn: warn,
+	}
+	source.current.Store(config)
+	return source, nil
+}
+
+// Config returns the currently active configuration. The returned pointer
+// must not be retained across requests.
+func (s *OnlyofficeConfigSource) Config() *OnlyofficeConfig {
+	return s.current.Load()
+}
+
+// DownloadSemaphore returns the shared download slot semaphore, resized
+// whenever allowed_downloads is hot-reloaded.
+func (s *OnlyofficeConfigSource) DownloadSemaphore() *ResizableSemaphore {
+	return s.sem
+}
+
+// Reload re-reads the configuration file and swaps the active pointer.
+// Restart-only parameters keep their previously loaded values.
+func (s *OnlyofficeConfigSource) Reload() error {
+	next, err := BuildNewOnlyofficeConfig(s.path)()
+	if err != nil {
+		return err
+	}
+
+	previous := s.current.Load()
+	restartOnly := []struct {
+		name     string
+		previous string
+		next     string
+	}{
+		{"document_server_url", previous.Onlyoffice.Builder.DocumentServerURL, next.Onlyoffice.Builder.DocumentServerURL},
+		{"document_server_secret", previous.Onlyoffice.Builder.DocumentServerSecret, next.Onlyoffice.Builder.DocumentServerSecret},
+		{"gateway_url", previous.Onlyoffice.Builder.GatewayURL, next.Onlyoffice.Builder.GatewayURL},
+		{"callback_url", previous.Onlyoffice.Builder.CallbackURL, next.Onlyoffice.Builder.CallbackURL},
+	}
+	for _, param := range restartOnly {
+		if param.previous != param.next {
+			s.warn("configuration parameter %s changed on disk but is restart-only, keeping the active value", param.name)
+		}
+	}
+
+	next.Onlyoffice.Builder.DocumentServerURL = previous.Onlyoffice.Builder.DocumentServerURL
+	next.Onlyoffice.Builder.DocumentServerSecret = previous.Onlyoffice.Builder.DocumentServerSecret
+	next.Onlyoffice.Builder.GatewayURL = previous.Onlyoffice.Builder.GatewayURL
+	next.Onlyoffice.Builder.CallbackURL = previous.Onlyoffice.Builder.CallbackURL
+
+	s.current.Store(next)
+	s.sem.Resize(next.Onlyoffice.Builder.AllowedDownloads)
+	return nil
+}
+
+// Watch reloads the configuration on yaml file changes and on SIGHUP until
+// the context is cancelled.
+func (s *OnlyofficeConfigSource) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(s.path); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	go func() {
+		defer watcher.Close()
+		defer signal.Stop(sighup)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				if err := s.Reload(); err != nil {
+					s.warn("could not hot-reload configuration: %s", err.Error())
+				}
+			case <-sighup:
+				if err := s.Reload(); err != nil {
+					s.warn("could not hot-reload configuration: %s", err.Error())
+				}
+			case err := <-watcher.Errors:
+				s.warn("configuration watcher error: %s", err.Error())
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,101 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, path string, maxSize int64, allowed int, dsURL string) {
+	t.Helper()
+	content := fmt.Sprintf(`onlyoffice:
+  builder:
+    document_server_url: %q
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+    allowed_downloads: %d
+  callback:
+    max_size: %d
+`, dsURL, allowed, maxSize)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnlyofficeConfigSourceReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeTestConfig(t, path, 1000, 5, "https://ds.example.com")
+
+	var warnings []string
+	source, err := NewOnlyofficeConfigSource(path, func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := source.Config().Onlyoffice.Callback.MaxSize; got != 1000 {
+		t.Fatalf("expected initial max_size 1000, got %d", got)
+	}
+
+	writeTestConfig(t, path, 2000, 10, "https://ds.example.com")
+	if err := source.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := source.Config().Onlyoffice.Callback.MaxSize; got != 2000 {
+		t.Fatalf("expected reloaded max_size 2000, got %d", got)
+	}
+	if got := source.Config().Onlyoffice.Builder.AllowedDownloads; got != 10 {
+		t.Fatalf("expected reloaded allowed_downloads 10, got %d", got)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %v", warnings)
+	}
+}
+
+func TestOnlyofficeConfigSourceRestartOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeTestConfig(t, path, 1000, 5, "https://ds.example.com")
+
+	var warnings []string
+	source, err := NewOnlyofficeConfigSource(path, func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestConfig(t, path, 1000, 5, "https://other-ds.example.com")
+	if err := source.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := source.Config().Onlyoffice.Builder.DocumentServerURL; got != "https://ds.example.com" {
+		t.Fatalf("expected restart-only url to keep its value, got %s", got)
+	}
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "document_server_url") {
+		t.Fatalf("expected a restart-only warning, got %v", warnings)
+	}
+}
@@ -0,0 +1,71 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewReplMux builds the operational mux a service hangs next to its rpc
+// traffic: /metrics always, the pprof endpoints only when the repl config
+// enables them. Pprof profiles expose memory contents and must never ship
+// reachable by accident, so enabling them is an explicit decision and a
+// configured token additionally gates every debug route — the metrics
+// endpoint stays open for scrapers either way.
+func NewReplMux(config OnlyofficeReplConfig) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	if !config.Pprof {
+		return mux
+	}
+
+	// The handlers are mounted explicitly instead of importing net/http/pprof
+	// for its side effect, so nothing ever lands on the default mux.
+	debug := http.NewServeMux()
+	debug.HandleFunc("/debug/pprof/", pprof.Index)
+	debug.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	debug.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	debug.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	debug.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/pprof/", ReplAuthMiddleware(config.AuthToken, debug))
+	return mux
+}
+
+// ReplAuthMiddleware requires the configured bearer token on every request
+// it wraps; an empty token leaves the wrapped handler open, for deployments
+// that bind the repl listener to an interface that is itself restricted.
+func ReplAuthMiddleware(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				rw.Header().Set("WWW-Authenticate", "Bearer")
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,77 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func replGet(mux *http.ServeMux, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// Pprof must not be mounted by default — debug builds included — and even a
+// valid token opens nothing that is not there.
+func TestReplMuxLeavesPprofOffByDefault(t *testing.T) {
+	mux := NewReplMux(OnlyofficeReplConfig{AuthToken: "repl-token"})
+
+	if rec := replGet(mux, "/debug/pprof/", "repl-token"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for pprof while disabled, got %d", rec.Code)
+	}
+	if rec := replGet(mux, "/metrics", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected metrics to be served, got %d", rec.Code)
+	}
+}
+
+// With pprof enabled and a token configured, the debug endpoints answer the
+// bearer and nobody else; metrics scraping stays tokenless.
+func TestReplMuxGuardsPprofWithTheBearerToken(t *testing.T) {
+	mux := NewReplMux(OnlyofficeReplConfig{Pprof: true, AuthToken: "repl-token"})
+
+	if rec := replGet(mux, "/debug/pprof/", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", rec.Code)
+	}
+	if rec := replGet(mux, "/debug/pprof/", "wrong-token"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong token, got %d", rec.Code)
+	}
+	if rec := replGet(mux, "/debug/pprof/", "repl-token"); rec.Code != http.StatusOK {
+		t.Errorf("expected the pprof index for the configured token, got %d", rec.Code)
+	}
+	if rec := replGet(mux, "/metrics", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected metrics to stay open for scrapers, got %d", rec.Code)
+	}
+}
+
+// An empty token deliberately leaves enabled pprof open: the deployment is
+// expected to have restricted the bind address instead.
+func TestReplMuxServesPprofWithoutTokenWhenUnset(t *testing.T) {
+	mux := NewReplMux(OnlyofficeReplConfig{Pprof: true})
+
+	if rec := replGet(mux, "/debug/pprof/", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected open pprof without a configured token, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,86 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package request
+
+import "encoding/json"
+
+// CallbackAction describes a single user action inside a Document Server
+// callback.
+type CallbackAction struct {
+	Type   int    `json:"type"`
+	UserID string `json:"userid"`
+}
+
+// CallbackRequest is the Document Server save callback body. Changesurl
+// and History describe the revision delta and are kept so stored versions
+// can later be restored with their changes.
+type CallbackRequest struct {
+	Actions    []CallbackAction `json:"actions"`
+	Key        string           `json:"key"`
+	Status     int              `json:"status"`
+	Users      []string         `json:"users"`
+	URL        string           `json:"url"`
+	Changesurl string           `json:"changesurl,omitempty"`
+	History    json.RawMessage  `json:"history,omitempty"`
+	Token      string           `json:"token,omitempty"`
+
+	// FileID is extracted from the callback URL query, not the body.
+	FileID string `json:"-"`
+}
+
+// Validate checks the fields this integration depends on. The callback
+// contract belongs to the Document Server, so unknown fields are tolerated,
+// but the required ones are validated explicitly and reported with their
+// field path.
+func (c CallbackRequest) Validate() error {
+	var errors ValidationErrors
+
+	if c.Key == "" {
+		errors = append(errors, ValidationError{
+			Field:   "key",
+			Message: "must not be empty",
+		})
+	}
+
+	if c.Status < 1 || c.Status > 7 {
+		errors = append(errors, ValidationError{
+			Field:   "status",
+			Message: "must be between 1 and 7",
+		})
+	}
+
+	// A save callback without a document URL cannot be uploaded at all.
+	if c.Status == 2 && c.URL == "" {
+		errors = append(errors, ValidationError{
+			Field:   "url",
+			Message: "must not be empty for save callbacks",
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	return nil
+}
+
+func (c CallbackRequest) ToJSON() []byte {
+	buf, _ := json.Marshal(c)
+	return buf
+}
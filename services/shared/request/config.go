@@ -0,0 +1,78 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package request
+
+import "encoding/json"
+
+// ConfigRequest is the builder RPC request asking for a Document Server
+// editor config for a given user and file. Embedded requests a view-only
+// embedded config regardless of the user's Drive capabilities.
+type ConfigRequest struct {
+	UID       string `json:"uid"`
+	FileID    string `json:"file_id"`
+	UserAgent string `json:"user_agent"`
+	ForceEdit bool   `json:"force_edit"`
+	Embedded  bool   `json:"embedded"`
+	// ForceType overrides editor type detection for debugging. Valid values
+	// are "mobile" and "desktop"; anything else is ignored.
+	ForceType string `json:"force_type,omitempty"`
+	// RevisionID builds a view-only config for an older revision.
+	RevisionID string `json:"revision_id,omitempty"`
+	// CoEditing overrides the configured co-editing mode; "fast" or
+	// "strict", empty keeps the deployment default.
+	CoEditing string `json:"co_editing,omitempty"`
+}
+
+func (r ConfigRequest) ToJSON() []byte {
+	buf, _ := json.Marshal(r)
+	return buf
+}
+
+// ConfigInvalidateRequest is the internal payload the callback service
+// posts to the gateway when a save transition makes the editor configs
+// cached for a document key stale.
+type ConfigInvalidateRequest struct {
+	FileID string `json:"file_id"`
+	Key    string `json:"key"`
+}
+
+func (r ConfigInvalidateRequest) ToJSON() []byte {
+	buf, _ := json.Marshal(r)
+	return buf
+}
+
+// ConvertAPIRequest is the payload the integration sends to the Document
+// Server conversion endpoint.
+type ConvertAPIRequest struct {
+	Async      bool   `json:"async"`
+	Filetype   string `json:"filetype"`
+	Key        string `json:"key"`
+	Outputtype string `json:"outputtype"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	// Password unlocks a protected source file; it is sent to the Document
+	// Server and nowhere else.
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+func (r ConvertAPIRequest) ToJSON() []byte {
+	buf, _ := json.Marshal(r)
+	return buf
+}
@@ -0,0 +1,71 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DecodeStrict decodes a JSON payload whose contract this integration owns:
+// unknown fields and type mismatches are errors instead of silent zero
+// values, so a misspelled field in a client fails loudly. The returned
+// ValidationErrors names the offending field, ready for a 400 body.
+//
+// Payloads with an external contract (the Document Server callback) must
+// not use this; new fields on their side are expected and tolerated.
+func DecodeStrict(r io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return decodeErrors(err)
+	}
+
+	return nil
+}
+
+// decodeErrors maps encoding/json failures onto ValidationErrors with the
+// field path the client has to fix.
+func decodeErrors(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return ValidationErrors{{
+			Field:   field,
+			Message: fmt.Sprintf("must be a %s", typeErr.Type),
+		}}
+	}
+
+	// encoding/json reports unknown fields only as a formatted string.
+	if message := err.Error(); strings.HasPrefix(message, "json: unknown field ") {
+		return ValidationErrors{{
+			Field:   strings.Trim(strings.TrimPrefix(message, "json: unknown field "), "\""),
+			Message: "is not a known field",
+		}}
+	}
+
+	return ValidationErrors{{Field: "body", Message: "is not valid json"}}
+}
@@ -0,0 +1,150 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package request
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodeStrictDriveState(t *testing.T) {
+	for name, tc := range map[string]struct {
+		payload string
+		field   string
+	}{
+		"valid payload": {
+			payload: `{"ids":["abc"],"action":"edit"}`,
+		},
+		"unknown field is rejected": {
+			payload: `{"ids":["abc"],"action":"edit","forceedit":true}`,
+			field:   "forceedit",
+		},
+		"wrongly typed field is rejected": {
+			payload: `{"ids":"abc","action":"edit"}`,
+			field:   "ids",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var state DriveState
+			err := DecodeStrict(strings.NewReader(tc.payload), &state)
+			assertDecodeError(t, err, tc.field)
+		})
+	}
+}
+
+func TestDecodeStrictCreateRequest(t *testing.T) {
+	for name, tc := range map[string]struct {
+		payload string
+		field   string
+	}{
+		"valid payload": {
+			payload: `{"name":"Report","type":"docx"}`,
+		},
+		"unknown field is rejected": {
+			payload: `{"name":"Report","type":"docx","folder":"root"}`,
+			field:   "folder",
+		},
+		"wrongly typed field is rejected": {
+			payload: `{"name":"Report","type":1}`,
+			field:   "type",
+		},
+		"not json at all": {
+			payload: `name=Report`,
+			field:   "body",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var body CreateRequest
+			err := DecodeStrict(strings.NewReader(tc.payload), &body)
+			assertDecodeError(t, err, tc.field)
+		})
+	}
+}
+
+func assertDecodeError(t *testing.T, err error, field string) {
+	t.Helper()
+
+	if field == "" {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	verrs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	if len(verrs) != 1 || verrs[0].Field != field {
+		t.Errorf("expected an error on field %q, got %v", field, verrs)
+	}
+}
+
+// The callback body contract belongs to the Document Server: unknown fields
+// must decode fine, while the fields this integration depends on are
+// validated explicitly.
+func TestCallbackRequestValidation(t *testing.T) {
+	for name, tc := range map[string]struct {
+		payload string
+		field   string
+	}{
+		"valid save callback": {
+			payload: `{"key":"k","status":2,"url":"https://ds/cache/file.docx"}`,
+		},
+		"unknown fields are tolerated": {
+			payload: `{"key":"k","status":4,"forcesavetype":1,"userdata":"x"}`,
+		},
+		"missing key": {
+			payload: `{"status":2,"url":"https://ds/cache/file.docx"}`,
+			field:   "key",
+		},
+		"status out of range": {
+			payload: `{"key":"k","status":9}`,
+			field:   "status",
+		},
+		"save callback without url": {
+			payload: `{"key":"k","status":2}`,
+			field:   "url",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var body CallbackRequest
+			if err := json.Unmarshal([]byte(tc.payload), &body); err != nil {
+				t.Fatal(err)
+			}
+
+			err := body.Validate()
+			if tc.field == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			verrs, ok := err.(ValidationErrors)
+			if !ok {
+				t.Fatalf("expected ValidationErrors, got %v", err)
+			}
+			if len(verrs) != 1 || verrs[0].Field != tc.field {
+				t.Errorf("expected an error on field %q, got %v", tc.field, verrs)
+			}
+		})
+	}
+}
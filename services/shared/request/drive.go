@@ -0,0 +1,110 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package request
+
+import "encoding/json"
+
+// DriveState mirrors the state payload Google Drive passes to the
+// integration when it is launched from the Drive UI. The same structure is
+// reused for the gateway /api/convert POST body, where Action selects what
+// to do with the file.
+type DriveState struct {
+	IDS               []string `json:"ids"`
+	Action            string   `json:"action"`
+	UserID            string   `json:"userId"`
+	FolderID          string   `json:"folderId,omitempty"`
+	FolderResourceKey string   `json:"folderResourceKey,omitempty"`
+	UserAgent         string   `json:"user_agent,omitempty"`
+	ForceEdit         bool     `json:"force_edit,omitempty"`
+	// DestinationID is the folder a converted copy should be placed in;
+	// empty keeps the copy next to the source file.
+	DestinationID string `json:"destinationId,omitempty"`
+	// Notice carries a non-fatal message back to the conversion page, e.g.
+	// when the chosen destination was not writable anymore.
+	Notice string `json:"notice,omitempty"`
+	// Degraded marks a copy produced by the conversion fallback rather than
+	// the primary output format, so the page can warn that fidelity may be
+	// reduced.
+	Degraded bool `json:"degraded,omitempty"`
+	// RevisionID pins the editor to an older revision, opened read-only;
+	// empty opens the file's current content.
+	RevisionID string `json:"revisionId,omitempty"`
+	// CoEditing overrides the deployment's co-editing mode for this session;
+	// "fast" or "strict", empty keeps the configured default.
+	CoEditing string `json:"coEditing,omitempty"`
+	// Extension names the format the conversion page was shown for, so the
+	// chosen action can be remembered per extension; DontAsk additionally
+	// skips the page the next time a file of that format is opened.
+	Extension string `json:"extension,omitempty"`
+	DontAsk   bool   `json:"dontAsk,omitempty"`
+	// AsTemplate asks a create action to keep the source's template nature:
+	// the copy comes out in the family's OOXML template format (dotx, xltx,
+	// potx) instead of the plain document one.
+	AsTemplate bool `json:"asTemplate,omitempty"`
+}
+
+func (s DriveState) ToJSON() []byte {
+	buf, _ := json.Marshal(s)
+	return buf
+}
+
+// ConvertRequest is the /api/convert POST body: the Drive state plus the
+// optional password of a protected source file. The password lives only in
+// the in-flight request — it is never echoed back into the state, carried
+// in a token or written to a log.
+type ConvertRequest struct {
+	DriveState
+	Password string `json:"password,omitempty"`
+	// Nonce is the one-time value embedded in the rendered convert page;
+	// the handler consumes it so a captured body cannot be replayed.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// CreateRequest is the gateway /api/create POST body used to create a new
+// document from one of the embedded templates.
+type CreateRequest struct {
+	Name              string `json:"name"`
+	Type              string `json:"type"`
+	FolderID          string `json:"folder_id,omitempty"`
+	FolderResourceKey string `json:"folder_resource_key,omitempty"`
+	// Nonce is the one-time value embedded in the rendered create page;
+	// the handler consumes it so a captured body cannot be replayed.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+func (r CreateRequest) ToJSON() []byte {
+	buf, _ := json.Marshal(r)
+	return buf
+}
+
+// RestoreRequest is the /api/restore POST body asking the gateway to write
+// an older revision's content back as the file's new head.
+type RestoreRequest struct {
+	FileID     string `json:"file_id"`
+	RevisionID string `json:"revision_id"`
+	// Confirm states the user went through a confirmation step: restoring
+	// overwrites the current version, so the flag must be sent explicitly
+	// rather than defaulted by a client.
+	Confirm bool `json:"confirm"`
+}
+
+func (r RestoreRequest) ToJSON() []byte {
+	buf, _ := json.Marshal(r)
+	return buf
+}
@@ -0,0 +1,35 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package request
+
+import "encoding/json"
+
+// SessionRefreshRequest is the internal payload the callback service posts
+// to the gateway when the Document Server reports editing activity, keeping
+// the per-user session registry warm between editor opens.
+type SessionRefreshRequest struct {
+	UID    string `json:"uid"`
+	FileID string `json:"file_id"`
+	Key    string `json:"key"`
+}
+
+func (r SessionRefreshRequest) ToJSON() []byte {
+	buf, _ := json.Marshal(r)
+	return buf
+}
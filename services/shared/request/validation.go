@@ -0,0 +1,233 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxFilenameLength is the longest file name the gateway accepts in
+	// create requests. Drive itself tolerates longer names, but names past
+	// this point break exported document titles.
+	MaxFilenameLength = 250
+)
+
+var (
+	// driveIDRegexp matches Google Drive file and folder identifiers.
+	driveIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{10,128}$`)
+	// extensionRegexp matches a bare lowercase file extension, the form the
+	// conversion page reports back and settings key preferences on.
+	extensionRegexp = regexp.MustCompile(`^[a-z0-9]{1,10}$`)
+	// filenameForbidden lists characters Drive rejects or that break the
+	// download URLs the integration builds.
+	filenameForbidden = "\\/:*?\"<>|"
+)
+
+// convertActions enumerates the actions the /api/convert endpoint accepts.
+var convertActions = map[string]bool{
+	"edit":   true,
+	"view":   true,
+	"create": true,
+}
+
+// coEditingModes enumerates the Document Server co-editing modes; the empty
+// string keeps the deployment default.
+var coEditingModes = map[string]bool{
+	"":       true,
+	"fast":   true,
+	"strict": true,
+}
+
+// ValidationError describes a single invalid field in a request payload.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("field %s is invalid: %s", e.Field, e.Message)
+}
+
+// ValidationErrors aggregates per-field errors so handlers can return all of
+// them in a single 400 response.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e ValidationErrors) ToJSON() []byte {
+	buf, _ := json.Marshal(map[string]ValidationErrors{"errors": e})
+	return buf
+}
+
+// ValidDriveID reports whether id looks like a Google Drive file or folder
+// identifier.
+func ValidDriveID(id string) bool {
+	return driveIDRegexp.MatchString(id)
+}
+
+// ValidExtension reports whether ext looks like a bare lowercase file
+// extension without its dot.
+func ValidExtension(ext string) bool {
+	return extensionRegexp.MatchString(ext)
+}
+
+// ValidFilename reports whether name is acceptable as a Drive file title.
+func ValidFilename(name string) bool {
+	if name == "" || !utf8.ValidString(name) {
+		return false
+	}
+	if utf8.RuneCountInString(name) > MaxFilenameLength {
+		return false
+	}
+	return !strings.ContainsAny(name, filenameForbidden)
+}
+
+// Validate checks a /api/convert payload and returns a ValidationErrors
+// listing every invalid field, or nil if the payload is well-formed.
+func (s DriveState) Validate() error {
+	var errors ValidationErrors
+
+	if !convertActions[s.Action] {
+		errors = append(errors, ValidationError{
+			Field:   "action",
+			Message: "must be one of edit, view, create",
+		})
+	}
+
+	if len(s.IDS) < 1 {
+		errors = append(errors, ValidationError{
+			Field:   "ids",
+			Message: "must contain at least one file id",
+		})
+	} else {
+		for _, id := range s.IDS {
+			if !ValidDriveID(id) {
+				errors = append(errors, ValidationError{
+					Field:   "ids",
+					Message: fmt.Sprintf("%q is not a valid drive file id", id),
+				})
+				break
+			}
+		}
+	}
+
+	if s.UserID != "" && !regexp.MustCompile(`^[0-9]{1,64}$`).MatchString(s.UserID) {
+		errors = append(errors, ValidationError{
+			Field:   "userId",
+			Message: "must be a numeric google user id",
+		})
+	}
+
+	if !coEditingModes[s.CoEditing] {
+		errors = append(errors, ValidationError{
+			Field:   "coEditing",
+			Message: "must be fast or strict",
+		})
+	}
+
+	if s.Extension != "" && !ValidExtension(s.Extension) {
+		errors = append(errors, ValidationError{
+			Field:   "extension",
+			Message: "must be a lowercase file extension",
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	return nil
+}
+
+// Validate checks a /api/restore payload and returns a ValidationErrors
+// listing every invalid field, or nil if the payload is well-formed.
+func (r RestoreRequest) Validate() error {
+	var errors ValidationErrors
+
+	if !ValidDriveID(r.FileID) {
+		errors = append(errors, ValidationError{
+			Field:   "file_id",
+			Message: "is not a valid drive file id",
+		})
+	}
+
+	if r.RevisionID == "" {
+		errors = append(errors, ValidationError{
+			Field:   "revision_id",
+			Message: "must not be empty",
+		})
+	}
+
+	if !r.Confirm {
+		errors = append(errors, ValidationError{
+			Field:   "confirm",
+			Message: "restoring overwrites the current version and must be confirmed",
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	return nil
+}
+
+// Validate checks a /api/create payload and returns a ValidationErrors
+// listing every invalid field, or nil if the payload is well-formed.
+func (r CreateRequest) Validate() error {
+	var errors ValidationErrors
+
+	if !ValidFilename(r.Name) {
+		errors = append(errors, ValidationError{
+			Field:   "name",
+			Message: fmt.Sprintf("must be a non-empty utf-8 name without %s of at most %d characters", filenameForbidden, MaxFilenameLength),
+		})
+	}
+
+	if _, ok := map[string]bool{"docx": true, "xlsx": true, "pptx": true}[r.Type]; !ok {
+		errors = append(errors, ValidationError{
+			Field:   "type",
+			Message: "must be one of docx, xlsx, pptx",
+		})
+	}
+
+	if r.FolderID != "" && !ValidDriveID(r.FolderID) {
+		errors = append(errors, ValidationError{
+			Field:   "folder_id",
+			Message: "is not a valid drive folder id",
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	return nil
+}
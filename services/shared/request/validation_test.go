@@ -0,0 +1,176 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package request
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDriveStateValidate(t *testing.T) {
+	validID := "1aBcDeFgHiJkLmNoPqRsTuVwXyZ"
+
+	tests := []struct {
+		name   string
+		state  DriveState
+		fields []string
+	}{
+		{
+			name:  "valid edit",
+			state: DriveState{IDS: []string{validID}, Action: "edit", UserID: "1234567890"},
+		},
+		{
+			name:  "valid view without user",
+			state: DriveState{IDS: []string{validID}, Action: "view"},
+		},
+		{
+			name:  "valid create",
+			state: DriveState{IDS: []string{validID}, Action: "create"},
+		},
+		{
+			name:   "unknown action",
+			state:  DriveState{IDS: []string{validID}, Action: "explode"},
+			fields: []string{"action"},
+		},
+		{
+			name:   "empty action",
+			state:  DriveState{IDS: []string{validID}},
+			fields: []string{"action"},
+		},
+		{
+			name:   "no ids",
+			state:  DriveState{Action: "edit"},
+			fields: []string{"ids"},
+		},
+		{
+			name:   "malformed id",
+			state:  DriveState{IDS: []string{"../../etc/passwd"}, Action: "edit"},
+			fields: []string{"ids"},
+		},
+		{
+			name:   "short id",
+			state:  DriveState{IDS: []string{"abc"}, Action: "edit"},
+			fields: []string{"ids"},
+		},
+		{
+			name:   "non numeric user id",
+			state:  DriveState{IDS: []string{validID}, Action: "edit", UserID: "robert'); DROP"},
+			fields: []string{"userId"},
+		},
+		{
+			name:   "everything wrong",
+			state:  DriveState{Action: "nope", UserID: "xx"},
+			fields: []string{"action", "ids", "userId"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.state.Validate()
+			assertValidation(t, err, tt.fields)
+		})
+	}
+}
+
+func TestCreateRequestValidate(t *testing.T) {
+	validFolder := "0B7z5aBcDeFgHiJkLmNoPqRsT"
+
+	tests := []struct {
+		name    string
+		request CreateRequest
+		fields  []string
+	}{
+		{
+			name:    "valid document",
+			request: CreateRequest{Name: "Report", Type: "docx", FolderID: validFolder},
+		},
+		{
+			name:    "valid without folder",
+			request: CreateRequest{Name: "Бюджет 2023", Type: "xlsx"},
+		},
+		{
+			name:    "empty name",
+			request: CreateRequest{Type: "docx"},
+			fields:  []string{"name"},
+		},
+		{
+			name:    "oversized name",
+			request: CreateRequest{Name: strings.Repeat("я", MaxFilenameLength+1), Type: "pptx"},
+			fields:  []string{"name"},
+		},
+		{
+			name:    "forbidden characters",
+			request: CreateRequest{Name: "a/b", Type: "docx"},
+			fields:  []string{"name"},
+		},
+		{
+			name:    "invalid utf8",
+			request: CreateRequest{Name: string([]byte{0xff, 0xfe}), Type: "docx"},
+			fields:  []string{"name"},
+		},
+		{
+			name:    "unknown type",
+			request: CreateRequest{Name: "notes", Type: "exe"},
+			fields:  []string{"type"},
+		},
+		{
+			name:    "bad folder id",
+			request: CreateRequest{Name: "notes", Type: "docx", FolderID: "nope"},
+			fields:  []string{"folder_id"},
+		},
+		{
+			name:    "everything wrong",
+			request: CreateRequest{Name: "", Type: "", FolderID: "!"},
+			fields:  []string{"name", "type", "folder_id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.request.Validate()
+			assertValidation(t, err, tt.fields)
+		})
+	}
+}
+
+func assertValidation(t *testing.T, err error, fields []string) {
+	t.Helper()
+
+	if len(fields) == 0 {
+		if err != nil {
+			t.Fatalf("expected no validation errors, got %v", err)
+		}
+		return
+	}
+
+	verrs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %T (%v)", err, err)
+	}
+
+	if len(verrs) != len(fields) {
+		t.Fatalf("expected %d errors (%v), got %d (%v)", len(fields), fields, len(verrs), verrs)
+	}
+
+	for i, field := range fields {
+		if verrs[i].Field != field {
+			t.Errorf("expected error %d to target field %s, got %s", i, field, verrs[i].Field)
+		}
+	}
+}
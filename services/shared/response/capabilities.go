@@ -0,0 +1,46 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package response
+
+import "encoding/json"
+
+// CapabilityReason explains one refusal: a stable code for machine
+// handling plus the message key a launcher resolves to its own localized
+// copy.
+type CapabilityReason struct {
+	Code       string `json:"code"`
+	MessageKey string `json:"message_key"`
+}
+
+// CapabilitiesResponse answers the pre-validation endpoint third-party
+// launchers call before embedding the editor: whether the file would open,
+// the mode it would open in, and the reasons when it would not.
+type CapabilitiesResponse struct {
+	Supported bool `json:"supported"`
+	// Mode is how the editor would open the file: "edit" directly,
+	// "view" read-only, or "convert-required" when faithful editing goes
+	// through a converted copy. Empty when the file is not supported.
+	Mode    string             `json:"mode,omitempty"`
+	Reasons []CapabilityReason `json:"reasons,omitempty"`
+}
+
+func (c CapabilitiesResponse) ToJSON() []byte {
+	buf, _ := json.Marshal(c)
+	return buf
+}
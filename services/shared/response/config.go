@@ -0,0 +1,113 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package response
+
+import "encoding/json"
+
+// Permissions mirrors the Document Server document.permissions block. The
+// capabilities this integration does not manage are omitted when false so
+// the Document Server applies its own defaults instead of an accidental
+// explicit deny.
+type Permissions struct {
+	Comment                 bool `json:"comment"`
+	Copy                    bool `json:"copy"`
+	DeleteCommentAuthorOnly bool `json:"deleteCommentAuthorOnly,omitempty"`
+	Download                bool `json:"download"`
+	Edit                    bool `json:"edit"`
+	EditCommentAuthorOnly   bool `json:"editCommentAuthorOnly,omitempty"`
+	FillForms               bool `json:"fillForms,omitempty"`
+	ModifyContentControl    bool `json:"modifyContentControl,omitempty"`
+	ModifyFilter            bool `json:"modifyFilter,omitempty"`
+	Print                   bool `json:"print"`
+	Review                  bool `json:"review"`
+}
+
+// DocumentOptions mirrors the Document Server config.document.options
+// block. WatermarkOnDraw carries the pre-rendered watermark JSON.
+type DocumentOptions struct {
+	WatermarkOnDraw json.RawMessage `json:"watermark_on_draw,omitempty"`
+}
+
+// Document mirrors the Document Server config.document block.
+type Document struct {
+	FileType    string           `json:"fileType"`
+	Key         string           `json:"key"`
+	Title       string           `json:"title"`
+	URL         string           `json:"url"`
+	Permissions Permissions      `json:"permissions"`
+	Options     *DocumentOptions `json:"options,omitempty"`
+}
+
+// User identifies the editing user inside the editor config.
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Goback mirrors the Document Server customization.goback block; it backs
+// the editor's "open file location" button.
+type Goback struct {
+	RequestClose bool   `json:"requestClose"`
+	Text         string `json:"text,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+// Customization mirrors the Document Server editorConfig.customization block.
+type Customization struct {
+	Goback        Goback `json:"goback"`
+	Plugins       bool   `json:"plugins"`
+	HideRightMenu bool   `json:"hideRightMenu"`
+}
+
+// CoEditing mirrors the Document Server editorConfig.coEditing block. Mode
+// is "fast" or "strict"; Change lets users switch the mode in the editor.
+type CoEditing struct {
+	Mode   string `json:"mode"`
+	Change bool   `json:"change"`
+}
+
+// EditorConfig mirrors the Document Server config.editorConfig block.
+type EditorConfig struct {
+	User          User          `json:"user"`
+	CallbackURL   string        `json:"callbackUrl"`
+	CoEditing     *CoEditing    `json:"coEditing,omitempty"`
+	Customization Customization `json:"customization"`
+	Lang          string        `json:"lang,omitempty"`
+	Mode          string        `json:"mode,omitempty"`
+}
+
+// BuildConfigResponse is the builder RPC reply rendered into the editor
+// page and handed to DocsAPI.DocEditor.
+type BuildConfigResponse struct {
+	Document     Document     `json:"document"`
+	DocumentType string       `json:"documentType"`
+	EditorConfig EditorConfig `json:"editorConfig"`
+	Type         string       `json:"type,omitempty"`
+	Token        string       `json:"token,omitempty"`
+	ServerURL    string       `json:"server_url"`
+	// MobileViewOnly marks a config the builder downgraded to view because
+	// the mobile editor cannot edit the file's format family; the editor
+	// page renders a notice explaining the downgrade.
+	MobileViewOnly bool `json:"mobile_view_only,omitempty"`
+}
+
+func (r BuildConfigResponse) ToJSON() []byte {
+	buf, _ := json.Marshal(r)
+	return buf
+}
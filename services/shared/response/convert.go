@@ -0,0 +1,92 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package response
+
+import "encoding/json"
+
+// ConvertResponse is the Document Server conversion API reply.
+type ConvertResponse struct {
+	Error      int    `json:"error,omitempty"`
+	EndConvert bool   `json:"endConvert"`
+	FileURL    string `json:"fileUrl"`
+	FileType   string `json:"fileType"`
+	Percent    int    `json:"percent"`
+}
+
+func (c ConvertResponse) ToJSON() []byte {
+	buf, _ := json.Marshal(c)
+	return buf
+}
+
+// ConvertJobResponse acknowledges a started conversion: the pipeline keeps
+// running after the POST returns and the page follows the job through the
+// progress endpoint.
+type ConvertJobResponse struct {
+	Job string `json:"job"`
+	// Nonce is a fresh page nonce: starting a job spends the one embedded at
+	// render time, so the page stores this one for any follow-up POST.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+func (c ConvertJobResponse) ToJSON() []byte {
+	buf, _ := json.Marshal(c)
+	return buf
+}
+
+// ConvertProgressError mirrors the validation error shape the conversion
+// page already renders, so a failed job plugs into the same error list.
+type ConvertProgressError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ConvertFileProgress is one file's standing within a batch conversion:
+// pending until a worker picks it up, then running, and finally done or
+// failed with the reason.
+type ConvertFileProgress struct {
+	FileID string `json:"file_id"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ConvertProgressResponse reports where a conversion job stands: its
+// pipeline phase and percent while running, the editor relaunch state once
+// done, or the failure — including the optional escape-hatch link used for
+// files over the Drive export cap. Batch jobs carry one entry per selected
+// file, in selection order.
+type ConvertProgressResponse struct {
+	Phase    string                 `json:"phase"`
+	Percent  int                    `json:"percent"`
+	Result   json.RawMessage        `json:"result,omitempty"`
+	Files    []ConvertFileProgress  `json:"files,omitempty"`
+	Errors   []ConvertProgressError `json:"errors,omitempty"`
+	Link     string                 `json:"link,omitempty"`
+	LinkText string                 `json:"link_text,omitempty"`
+	// PasswordRequired marks a failure the user can fix by supplying the
+	// document password; the page shows a prompt and retries the POST.
+	PasswordRequired bool `json:"password_required,omitempty"`
+	// Nonce is a fresh page nonce for responses the page retries from, such
+	// as a password prompt; the retry POST must carry it.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+func (c ConvertProgressResponse) ToJSON() []byte {
+	buf, _ := json.Marshal(c)
+	return buf
+}
@@ -0,0 +1,42 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package response
+
+import "encoding/json"
+
+// FolderItem is one entry of a folder listing.
+type FolderItem struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// FolderListResponse is one page of the /api/folders destination browser:
+// the subfolders of the requested parent, the parent's own metadata for
+// breadcrumbs and the token for the next page when there is one.
+type FolderListResponse struct {
+	Current       FolderItem   `json:"current"`
+	ParentID      string       `json:"parent_id,omitempty"`
+	Folders       []FolderItem `json:"folders"`
+	NextPageToken string       `json:"next_page_token,omitempty"`
+}
+
+func (r FolderListResponse) ToJSON() []byte {
+	buf, _ := json.Marshal(r)
+	return buf
+}
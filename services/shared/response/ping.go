@@ -0,0 +1,35 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package response
+
+import "encoding/json"
+
+// PingResponse is returned by the gateway's unauthenticated /api/ping
+// endpoint. URLHash is a digest of the gateway's configured public URL so
+// other services can detect configuration drift without the URL itself
+// being exposed.
+type PingResponse struct {
+	Version string `json:"version"`
+	URLHash string `json:"url_hash"`
+}
+
+func (r PingResponse) ToJSON() []byte {
+	buf, _ := json.Marshal(r)
+	return buf
+}
@@ -0,0 +1,63 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package response
+
+import "encoding/json"
+
+// UserResponse is the auth service RPC reply carrying a user's decrypted
+// Google OAuth credentials.
+type UserResponse struct {
+	ID           string `json:"id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	Scope        string `json:"scope"`
+	Expiry       int64  `json:"expiry"`
+}
+
+func (u UserResponse) ToJSON() []byte {
+	buf, _ := json.Marshal(u)
+	return buf
+}
+
+// Per-entry statuses of a GetUsers batch reply.
+const (
+	UserBatchOK       = "ok"
+	UserBatchNotFound = "not_found"
+	UserBatchError    = "error"
+)
+
+// UserBatchEntry is one requested user's outcome in a GetUsers batch; User
+// is only present when Status is ok.
+type UserBatchEntry struct {
+	ID     string        `json:"id"`
+	Status string        `json:"status"`
+	User   *UserResponse `json:"user,omitempty"`
+}
+
+// UsersResponse is the auth service GetUsers RPC reply, with entry i
+// answering the i-th requested id.
+type UsersResponse struct {
+	Users []UserBatchEntry `json:"users"`
+}
+
+func (u UsersResponse) ToJSON() []byte {
+	buf, _ := json.Marshal(u)
+	return buf
+}
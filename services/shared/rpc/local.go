@@ -0,0 +1,188 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/codec"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// LocalRouter maps rpc endpoints onto handlers registered in this process.
+// It accepts the same handler structs the distributed binaries hand to
+// micro.RegisterHandler: every exported method of the shape
+// func(context.Context, Req, *Rsp) error becomes callable under
+// "Handler.Method" for the registered service name.
+type LocalRouter struct {
+	handlers map[string]reflect.Value
+}
+
+func NewLocalRouter() *LocalRouter {
+	return &LocalRouter{handlers: map[string]reflect.Value{}}
+}
+
+// Register exposes a handler's rpc methods under the given service name,
+// e.g. "namespace:auth". Registering a handler without a single method of
+// the rpc shape is a wiring mistake and fails loudly.
+func (r *LocalRouter) Register(service string, handler interface{}) error {
+	value := reflect.ValueOf(handler)
+	name := reflect.Indirect(value).Type().Name()
+
+	registered := 0
+	for i := 0; i < value.NumMethod(); i++ {
+		fn := value.Method(i)
+		ft := fn.Type()
+		if ft.NumIn() != 3 || ft.NumOut() != 1 ||
+			ft.In(0) != contextType || ft.In(2).Kind() != reflect.Ptr ||
+			ft.Out(0) != errorType {
+			continue
+		}
+		r.handlers[fmt.Sprintf("%s/%s.%s", service, name, value.Type().Method(i).Name)] = fn
+		registered++
+	}
+	if registered == 0 {
+		return fmt.Errorf("handler %s exposes no rpc methods", name)
+	}
+	return nil
+}
+
+func (r *LocalRouter) lookup(service, endpoint string) (reflect.Value, bool) {
+	fn, ok := r.handlers[fmt.Sprintf("%s/%s", service, endpoint)]
+	return fn, ok
+}
+
+// localRequest carries what a dispatch needs; the codec accessors exist
+// only to satisfy the client.Request interface.
+type localRequest struct {
+	service  string
+	endpoint string
+	body     interface{}
+}
+
+func (r localRequest) Service() string     { return r.service }
+func (r localRequest) Method() string      { return r.endpoint }
+func (r localRequest) Endpoint() string    { return r.endpoint }
+func (r localRequest) ContentType() string { return "application/json" }
+func (r localRequest) Body() interface{}   { return r.body }
+func (r localRequest) Codec() codec.Writer { return nil }
+func (r localRequest) Stream() bool        { return false }
+
+type localMessage struct {
+	topic   string
+	payload interface{}
+}
+
+func (m localMessage) Topic() string        { return m.topic }
+func (m localMessage) Payload() interface{} { return m.payload }
+func (m localMessage) ContentType() string  { return "application/json" }
+
+// LocalClient is a client.Client that dispatches calls to handlers
+// registered in the same process instead of over the network. It is what
+// lets the standalone binary run gateway, builder, callback and auth as one
+// process without changing a single call site: the controllers keep calling
+// "namespace:auth" and friends and the router resolves them locally.
+type LocalClient struct {
+	router *LocalRouter
+	opts   client.Options
+}
+
+func NewLocalClient(router *LocalRouter) client.Client {
+	return &LocalClient{router: router, opts: client.NewOptions()}
+}
+
+func (c *LocalClient) Init(opts ...client.Option) error {
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	return nil
+}
+
+func (c *LocalClient) Options() client.Options { return c.opts }
+
+func (c *LocalClient) NewMessage(topic string, msg interface{}, opts ...client.MessageOption) client.Message {
+	return localMessage{topic: topic, payload: msg}
+}
+
+func (c *LocalClient) NewRequest(service, endpoint string, req interface{}, opts ...client.RequestOption) client.Request {
+	return localRequest{service: service, endpoint: endpoint, body: req}
+}
+
+// Call dispatches to the registered handler. The request body round-trips
+// through json so the handler sees exactly what the wire codec would have
+// delivered — callers pass plain strings and maps where handlers take
+// pointers and structs, and the wire format is what reconciles the two.
+func (c *LocalClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	fn, ok := c.router.lookup(req.Service(), req.Endpoint())
+	if !ok {
+		return fmt.Errorf("no in-process handler for %s %s", req.Service(), req.Endpoint())
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(req.Body())
+	if err != nil {
+		return err
+	}
+	ft := fn.Type()
+	arg := reflect.New(ft.In(1))
+	if ft.In(1).Kind() == reflect.Ptr {
+		arg = reflect.New(ft.In(1).Elem())
+	}
+	if err := json.Unmarshal(buf, arg.Interface()); err != nil {
+		return err
+	}
+	if ft.In(1).Kind() != reflect.Ptr {
+		arg = arg.Elem()
+	}
+
+	out := reflect.New(ft.In(2).Elem())
+	results := fn.Call([]reflect.Value{reflect.ValueOf(ctx), arg, out})
+	if err, _ := results[0].Interface().(error); err != nil {
+		return err
+	}
+
+	if rsp == nil {
+		return nil
+	}
+	buf, err = json.Marshal(out.Interface())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, rsp)
+}
+
+func (c *LocalClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return nil, fmt.Errorf("the in-process client does not support streaming")
+}
+
+func (c *LocalClient) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	return fmt.Errorf("the in-process client does not support publishing")
+}
+
+func (c *LocalClient) String() string { return "local" }
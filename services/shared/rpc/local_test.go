@@ -0,0 +1,188 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/request"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/response"
+	"go-micro.dev/v4/client"
+)
+
+// UserSelectHandler mirrors the auth service's handler of the same name —
+// the endpoint key is derived from the type name, so the stub carries it
+// too — including the pointer request parameter the wire codec fills from a
+// plain string body.
+type UserSelectHandler struct {
+	asked []string
+	err   error
+}
+
+func (h *UserSelectHandler) GetUser(ctx context.Context, uid *string, res *response.UserResponse) error {
+	h.asked = append(h.asked, *uid)
+	if h.err != nil {
+		return h.err
+	}
+	*res = response.UserResponse{ID: *uid, AccessToken: "access-" + *uid}
+	return nil
+}
+
+// ConfigHandler mirrors the builder's: it resolves the user through the
+// same client it is called over, exactly like the real handler does in both
+// distributed and standalone mode.
+type ConfigHandler struct {
+	client client.Client
+}
+
+func (h ConfigHandler) BuildConfig(ctx context.Context, payload request.ConfigRequest, res *response.BuildConfigResponse) error {
+	var ures response.UserResponse
+	if err := h.client.Call(ctx, h.client.NewRequest(
+		"standalone:auth", "UserSelectHandler.GetUser", payload.UID,
+	), &ures); err != nil {
+		return err
+	}
+	*res = response.BuildConfigResponse{
+		Document: response.Document{
+			Key:   payload.FileID + ":" + ures.ID,
+			Title: "document.docx",
+		},
+	}
+	return nil
+}
+
+func newChainClient(t *testing.T, auth *UserSelectHandler) client.Client {
+	t.Helper()
+	router := NewLocalRouter()
+	if err := router.Register("standalone:auth", auth); err != nil {
+		t.Fatal(err)
+	}
+	local := NewLocalClient(router)
+	if err := router.Register("standalone:builder", ConfigHandler{client: local}); err != nil {
+		t.Fatal(err)
+	}
+	return local
+}
+
+// The open→save round trip of a standalone deployment: the gateway asks the
+// builder for a config, the builder resolves the user through auth, and the
+// save path later resolves the same user again — all through one in-process
+// client, with the callers passing the same wire shapes they put on the
+// network in distributed mode.
+func TestLocalClientCarriesAnOpenToSaveChain(t *testing.T) {
+	auth := &UserSelectHandler{}
+	local := newChainClient(t, auth)
+
+	var config response.BuildConfigResponse
+	if err := local.Call(context.Background(), local.NewRequest(
+		"standalone:builder", "ConfigHandler.BuildConfig",
+		request.ConfigRequest{UID: "user-a", FileID: "file-1"},
+	), &config); err != nil {
+		t.Fatalf("the open call must succeed, got %s", err.Error())
+	}
+	if config.Document.Key != "file-1:user-a" {
+		t.Errorf("expected the builder to see the resolved user, got key %q", config.Document.Key)
+	}
+
+	// The save path is the callback worker's lookup, shaped as uploader.go
+	// issues it: a plain string body against the pointer-taking handler.
+	var ures response.UserResponse
+	if err := local.Call(context.Background(), local.NewRequest(
+		"standalone:auth", "UserSelectHandler.GetUser", "user-a",
+	), &ures); err != nil {
+		t.Fatalf("the save-side lookup must succeed, got %s", err.Error())
+	}
+	if ures.AccessToken != "access-user-a" {
+		t.Errorf("expected the stored credentials back, got %q", ures.AccessToken)
+	}
+
+	if len(auth.asked) != 2 || auth.asked[0] != "user-a" || auth.asked[1] != "user-a" {
+		t.Errorf("expected two auth lookups for user-a, got %v", auth.asked)
+	}
+}
+
+func TestLocalClientPropagatesHandlerErrors(t *testing.T) {
+	auth := &UserSelectHandler{err: errors.New("user not found")}
+	local := newChainClient(t, auth)
+
+	var config response.BuildConfigResponse
+	err := local.Call(context.Background(), local.NewRequest(
+		"standalone:builder", "ConfigHandler.BuildConfig",
+		request.ConfigRequest{UID: "user-x", FileID: "file-1"},
+	), &config)
+	if err == nil || !strings.Contains(err.Error(), "user not found") {
+		t.Fatalf("expected the auth failure to surface through the chain, got %v", err)
+	}
+}
+
+func TestLocalClientRejectsUnknownEndpoints(t *testing.T) {
+	local := newChainClient(t, &UserSelectHandler{})
+
+	err := local.Call(context.Background(), local.NewRequest(
+		"standalone:settings", "SettingsHandler.GetSettings", "user-a",
+	), nil)
+	if err == nil {
+		t.Fatal("expected an unknown endpoint to fail")
+	}
+	for _, fragment := range []string{"standalone:settings", "SettingsHandler.GetSettings"} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Errorf("expected the error to name %q, got %q", fragment, err.Error())
+		}
+	}
+}
+
+func TestLocalRouterRejectsHandlersWithoutRPCMethods(t *testing.T) {
+	if err := NewLocalRouter().Register("standalone:auth", struct{}{}); err == nil {
+		t.Fatal("expected a handler without rpc methods to be rejected")
+	}
+}
+
+// UserInsertHandler mirrors the auth insert handler: the map body the
+// gateway's auth controller sends must land in the handler's struct
+// parameter the way the json codec would deliver it.
+type UserInsertHandler struct {
+	inserted map[string]interface{}
+}
+
+func (h *UserInsertHandler) InsertUser(ctx context.Context, user *map[string]interface{}, res *interface{}) error {
+	h.inserted = *user
+	return nil
+}
+
+func TestLocalClientRoundTripsMapBodies(t *testing.T) {
+	router := NewLocalRouter()
+	insert := &UserInsertHandler{}
+	if err := router.Register("standalone:auth", insert); err != nil {
+		t.Fatal(err)
+	}
+	local := NewLocalClient(router)
+
+	if err := local.Call(context.Background(), local.NewRequest(
+		"standalone:auth", "UserInsertHandler.InsertUser",
+		map[string]interface{}{"id": "user-a"},
+	), nil); err != nil {
+		t.Fatalf("expected the insert to dispatch, got %s", err.Error())
+	}
+	if got, _ := insert.inserted["id"].(string); got != "user-a" {
+		t.Errorf("expected the map body to reach the handler, got %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import "strings"
+
+// The OAuth scopes the application requests at consent. They live here as
+// the single source for the consent request, the scope string stored with
+// the tokens and the diff that detects a stale grant — the generated API
+// clients each re-export them per API version, and mixing versions is how
+// the requested set and the stored set drift apart.
+const (
+	// ScopeDriveFile grants access to files the user opened or created
+	// through the application; the application never sees the whole Drive.
+	ScopeDriveFile = "https://www.googleapis.com/auth/drive.file"
+	// ScopeDriveInstall marks the application as installed so Drive offers
+	// it in "Open with" menus.
+	ScopeDriveInstall = "https://www.googleapis.com/auth/drive.install"
+	// ScopeDriveAppdata backs the settings document in the hidden
+	// application data folder; users who consented before it was requested
+	// keep working without it.
+	ScopeDriveAppdata = "https://www.googleapis.com/auth/drive.appdata"
+	// ScopeUserinfoProfile and ScopeUserinfoEmail identify the account the
+	// tokens belong to.
+	ScopeUserinfoProfile = "https://www.googleapis.com/auth/userinfo.profile"
+	ScopeUserinfoEmail   = "https://www.googleapis.com/auth/userinfo.email"
+)
+
+// RequestedScopes returns the scope set the consent screen asks for, in a
+// stable order.
+func RequestedScopes() []string {
+	return []string{
+		ScopeDriveFile,
+		ScopeDriveInstall,
+		ScopeDriveAppdata,
+		ScopeUserinfoProfile,
+		ScopeUserinfoEmail,
+	}
+}
+
+// RequestedScopeString is the requested set in the space-separated wire
+// format Google reports grants in; it is what gets stored alongside the
+// tokens so MissingScopes can compare like with like.
+func RequestedScopeString() string {
+	return strings.Join(RequestedScopes(), " ")
+}
+
+// MissingScopes diffs a stored space-separated grant against the requested
+// set and returns the scopes the grant lacks; a non-empty answer means the
+// user has to go through consent again. An empty grant predates scope
+// tracking and reports nothing — absence of the record is not evidence of
+// a revocation.
+func MissingScopes(granted string) []string {
+	if granted == "" {
+		return nil
+	}
+
+	held := map[string]struct{}{}
+	for _, scope := range strings.Fields(granted) {
+		held[scope] = struct{}{}
+	}
+
+	var missing []string
+	for _, scope := range RequestedScopes() {
+		if _, ok := held[scope]; !ok {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,58 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The stored scope string and the requested scope set must stay the same
+// thing, or re-consent detection compares the grant against a set that was
+// never asked for.
+func TestRequestedScopeStringMatchesTheRequestedSet(t *testing.T) {
+	if got := strings.Fields(RequestedScopeString()); !reflect.DeepEqual(got, RequestedScopes()) {
+		t.Fatalf("stored scope string %v diverged from the requested set %v", got, RequestedScopes())
+	}
+
+	seen := map[string]struct{}{}
+	for _, scope := range RequestedScopes() {
+		if _, ok := seen[scope]; ok {
+			t.Fatalf("scope %s is requested twice", scope)
+		}
+		seen[scope] = struct{}{}
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	if missing := MissingScopes(RequestedScopeString()); missing != nil {
+		t.Fatalf("a full grant should miss nothing, got %v", missing)
+	}
+
+	granted := strings.ReplaceAll(RequestedScopeString(), ScopeDriveAppdata, "")
+	if missing := MissingScopes(granted); !reflect.DeepEqual(missing, []string{ScopeDriveAppdata}) {
+		t.Fatalf("expected the appdata scope to be reported missing, got %v", missing)
+	}
+
+	// An empty record predates scope tracking and is not a revocation.
+	if missing := MissingScopes(""); missing != nil {
+		t.Fatalf("an absent record should report nothing, got %v", missing)
+	}
+}
@@ -0,0 +1,95 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"context"
+	"sync"
+)
+
+// ResizableSemaphore is a counting semaphore whose capacity can be changed
+// at runtime, used for limits that follow hot-reloaded configuration.
+// Shrinking does not interrupt holders; the new limit applies as slots are
+// released.
+type ResizableSemaphore struct {
+	mu    sync.Mutex
+	limit int
+	used  int
+	wake  chan struct{}
+}
+
+func NewResizableSemaphore(limit int) *ResizableSemaphore {
+	return &ResizableSemaphore{
+		limit: limit,
+		wake:  make(chan struct{}),
+	}
+}
+
+// Acquire blocks until a slot is free or the context is done.
+func (s *ResizableSemaphore) Acquire(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		if s.used < s.limit {
+			s.used++
+			s.mu.Unlock()
+			return nil
+		}
+		wake := s.wake
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+// Release frees a slot and wakes waiters.
+func (s *ResizableSemaphore) Release() {
+	s.mu.Lock()
+	if s.used > 0 {
+		s.used--
+	}
+	s.broadcast()
+	s.mu.Unlock()
+}
+
+// With runs fn while holding a slot. The slot is released on return or
+// panic, so callers cannot leak permits through early returns.
+func (s *ResizableSemaphore) With(ctx context.Context, fn func() error) error {
+	if err := s.Acquire(ctx); err != nil {
+		return err
+	}
+	defer s.Release()
+	return fn()
+}
+
+// Resize changes the capacity and wakes waiters so they can re-check it.
+func (s *ResizableSemaphore) Resize(limit int) {
+	s.mu.Lock()
+	s.limit = limit
+	s.broadcast()
+	s.mu.Unlock()
+}
+
+func (s *ResizableSemaphore) broadcast() {
+	close(s.wake)
+	s.wake = make(chan struct{})
+}
@@ -0,0 +1,104 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Concurrent downloads above the limit must queue: against a slow fake
+// Drive, the number of in-flight calls never exceeds the configured limit
+// and the limit is actually reached.
+func TestSemaphoreBoundsConcurrentDownloads(t *testing.T) {
+	const limit = 3
+	sem := NewResizableSemaphore(limit)
+
+	var inFlight, maxInFlight int32
+	slowDriveCall := func() error {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < limit+2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sem.With(context.Background(), slowDriveCall); err != nil {
+				t.Errorf("download failed: %s", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&maxInFlight); got != limit {
+		t.Fatalf("expected max in-flight drive calls to equal %d, observed %d", limit, got)
+	}
+}
+
+// A panic inside the bracketed section must not leak the permit.
+func TestSemaphoreWithReleasesOnPanic(t *testing.T) {
+	sem := NewResizableSemaphore(1)
+
+	func() {
+		defer func() { recover() }()
+		sem.With(context.Background(), func() error {
+			panic("handler blew up")
+		})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sem.Acquire(ctx); err != nil {
+		t.Fatalf("permit leaked after panic: %s", err.Error())
+	}
+	sem.Release()
+}
+
+// A caller whose context expires while waiting gives up without consuming
+// a slot.
+func TestSemaphoreAcquireContextCancel(t *testing.T) {
+	sem := NewResizableSemaphore(1)
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("first acquire failed: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := sem.Acquire(ctx); err == nil {
+		t.Fatal("expected a context error while waiting on a full semaphore")
+	}
+
+	sem.Release()
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("slot was not returned: %s", err.Error())
+	}
+}
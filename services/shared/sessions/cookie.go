@@ -0,0 +1,133 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package sessions
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CookieSizeBudget is the largest session payload a cookie may carry before
+// the gateway complains. Browsers cap a cookie around 4 KB including its
+// name and attributes and truncate silently past that, so the budget stays
+// comfortably below the cap.
+const CookieSizeBudget = 3800
+
+// payloadPrefix marks a cookie value that is a server-side payload handle
+// rather than the session token itself.
+const payloadPrefix = "sid."
+
+type payloadEntry struct {
+	payload string
+	expires time.Time
+}
+
+// PayloadStore keeps session payloads server side under opaque handles so
+// the browser cookie stays a few dozen bytes no matter how much session
+// state accumulates. Entries live as long as the token they carry would.
+type PayloadStore struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries map[string]payloadEntry
+}
+
+func NewPayloadStore() *PayloadStore {
+	return &PayloadStore{
+		now:     time.Now,
+		entries: make(map[string]payloadEntry),
+	}
+}
+
+// Put stores the payload and returns its handle. Expired entries are pruned
+// on the way in so abandoned sessions do not accumulate.
+func (s *PayloadStore) Put(payload string, ttl time.Duration) string {
+	id := uuid.NewString()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.entries[id] = payloadEntry{payload: payload, expires: now.Add(ttl)}
+	return id
+}
+
+// Get resolves a handle back to its payload. Unlike state handles a session
+// handle is reused across requests, so the entry stays until it expires.
+func (s *PayloadStore) Get(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	if !ok || s.now().After(entry.expires) {
+		return "", false
+	}
+	return entry.payload, true
+}
+
+// CookieCodec translates between session tokens and the values stored in
+// the browser cookie. With a store the cookie carries only an opaque handle
+// and the token lives server side; without one (or on a nil codec, as the
+// zero-value middlewares tests build have) it degrades to the historical
+// cookie-only mode where the cookie carries the token itself.
+type CookieCodec struct {
+	store *PayloadStore
+	warnf func(format string, args ...interface{})
+}
+
+func NewCookieCodec(store *PayloadStore, warnf func(string, ...interface{})) *CookieCodec {
+	return &CookieCodec{store: store, warnf: warnf}
+}
+
+// Encode turns a session token into the cookie value. The ttl bounds the
+// server-side copy and must match the token's own expiry. In cookie-only
+// mode an over-budget token is still returned — truncating it would only
+// corrupt the session — but the overrun is logged so it is caught before
+// browsers start dropping bytes.
+func (c *CookieCodec) Encode(token string, ttl time.Duration) string {
+	if c == nil || c.store == nil {
+		if c != nil && c.warnf != nil && len(token) > CookieSizeBudget {
+			c.warnf(
+				"session cookie payload is %d bytes, past the %d byte budget; enable server side sessions before browsers truncate it",
+				len(token), CookieSizeBudget,
+			)
+		}
+		return token
+	}
+	return payloadPrefix + c.store.Put(token, ttl)
+}
+
+// Decode turns a cookie value back into the session token. Handle values
+// fail once their server-side payload expired or the process restarted; a
+// raw token — a pre-migration cookie or cookie-only mode — passes through
+// for the JWT verification to judge.
+func (c *CookieCodec) Decode(value string) (string, bool) {
+	if !strings.HasPrefix(value, payloadPrefix) {
+		return value, true
+	}
+	if c == nil || c.store == nil {
+		return "", false
+	}
+	return c.store.Get(strings.TrimPrefix(value, payloadPrefix))
+}
@@ -0,0 +1,128 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package sessions
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestPayloadStore(now *time.Time) *PayloadStore {
+	store := NewPayloadStore()
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+func TestCookieCodecRoundTripThroughStore(t *testing.T) {
+	now := time.Now()
+	codec := NewCookieCodec(newTestPayloadStore(&now), nil)
+
+	value := codec.Encode("session-token", time.Hour)
+	if value == "session-token" {
+		t.Fatal("expected the cookie to carry a handle, not the token")
+	}
+	if !strings.HasPrefix(value, payloadPrefix) {
+		t.Fatalf("expected a prefixed handle, got %q", value)
+	}
+
+	token, ok := codec.Decode(value)
+	if !ok || token != "session-token" {
+		t.Errorf("expected the stored token back, got %q, %t", token, ok)
+	}
+	// Session cookies travel on every request; the handle must survive
+	// repeated decodes.
+	if _, ok := codec.Decode(value); !ok {
+		t.Error("expected the handle to stay resolvable")
+	}
+}
+
+func TestCookieCodecExpiry(t *testing.T) {
+	now := time.Now()
+	codec := NewCookieCodec(newTestPayloadStore(&now), nil)
+
+	value := codec.Encode("session-token", time.Hour)
+	now = now.Add(time.Hour + time.Second)
+
+	if _, ok := codec.Decode(value); ok {
+		t.Error("expected the payload to expire with its token")
+	}
+	if _, ok := codec.Decode(payloadPrefix + "unknown"); ok {
+		t.Error("expected an unknown handle to be rejected")
+	}
+}
+
+func TestCookieCodecFallsBackToCookieOnlyMode(t *testing.T) {
+	warnings := 0
+	codec := NewCookieCodec(nil, func(string, ...interface{}) { warnings++ })
+
+	if value := codec.Encode("session-token", time.Hour); value != "session-token" {
+		t.Errorf("expected the token itself without a store, got %q", value)
+	}
+	if warnings != 0 {
+		t.Errorf("expected no warning for a token within budget, got %d", warnings)
+	}
+	if token, ok := codec.Decode("session-token"); !ok || token != "session-token" {
+		t.Errorf("expected a raw token to pass through, got %q, %t", token, ok)
+	}
+
+	// Zero-value middlewares carry no codec at all; both directions must
+	// behave like cookie-only mode.
+	var nilCodec *CookieCodec
+	if value := nilCodec.Encode("session-token", time.Hour); value != "session-token" {
+		t.Errorf("expected a nil codec to pass the token through, got %q", value)
+	}
+	if token, ok := nilCodec.Decode("session-token"); !ok || token != "session-token" {
+		t.Errorf("expected a nil codec to decode raw tokens, got %q, %t", token, ok)
+	}
+}
+
+func TestCookieCodecWarnsPastTheSizeBudget(t *testing.T) {
+	warnings := 0
+	codec := NewCookieCodec(nil, func(string, ...interface{}) { warnings++ })
+
+	oversized := strings.Repeat("a", CookieSizeBudget+1)
+	if value := codec.Encode(oversized, time.Hour); value != oversized {
+		t.Error("expected the oversized token untouched; truncation would corrupt it")
+	}
+	if warnings != 1 {
+		t.Errorf("expected one budget warning, got %d", warnings)
+	}
+
+	// With a store the cookie is a handle and the budget cannot be hit.
+	now := time.Now()
+	stored := NewCookieCodec(newTestPayloadStore(&now), func(string, ...interface{}) { warnings++ })
+	if value := stored.Encode(oversized, time.Hour); len(value) > CookieSizeBudget {
+		t.Errorf("expected a small handle, got %d bytes", len(value))
+	}
+	if warnings != 1 {
+		t.Errorf("expected no further warnings in store mode, got %d", warnings)
+	}
+}
+
+func TestCookieCodecAcceptsPreMigrationCookies(t *testing.T) {
+	now := time.Now()
+	codec := NewCookieCodec(newTestPayloadStore(&now), nil)
+
+	// Cookies minted before server side sessions were enabled carry the
+	// token itself and must keep working after the switch.
+	if token, ok := codec.Decode("legacy-token"); !ok || token != "legacy-token" {
+		t.Errorf("expected the legacy cookie to pass through, got %q, %t", token, ok)
+	}
+}
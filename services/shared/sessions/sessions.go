@@ -0,0 +1,197 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package sessions tracks which documents each user currently has open
+// through the integration so installs can cap concurrent editor seats.
+// Entries are refreshed on editor opens and on Document Server activity
+// callbacks and silently expire after the configured TTL, so a crashed
+// editor never pins a seat forever.
+package sessions
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Session is one open document tracked for a user.
+type Session struct {
+	FileID string `json:"file_id"`
+	Title  string `json:"title"`
+	Key    string `json:"key"`
+}
+
+type entry struct {
+	session Session
+	expires time.Time
+}
+
+// issuanceWindow is how long a recorded source-URL issuance keeps
+// authorizing downloads without a tracked session; it matches the lifetime
+// of the download tokens themselves.
+const issuanceWindow = 4 * time.Minute
+
+// Registry is an in-memory per-user session table.
+type Registry struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	now   func() time.Time
+	users map[string]map[string]entry
+	// issued remembers recent download-URL issuances for flows that never
+	// open an editor seat, such as conversions and embedded views.
+	issued map[string]time.Time
+
+	perUser prometheus.Histogram
+}
+
+// NewRegistry builds a Registry expiring untouched sessions after ttl.
+func NewRegistry(ttl time.Duration) *Registry {
+	return &Registry{
+		ttl:    ttl,
+		now:    time.Now,
+		users:  map[string]map[string]entry{},
+		issued: map[string]time.Time{},
+		perUser: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "onlyoffice_active_sessions_per_user",
+			Help:    "Distribution of concurrently open documents per user.",
+			Buckets: []float64{1, 2, 3, 5, 8, 13, 21},
+		}),
+	}
+}
+
+// Refresh records activity on a session, creating it when needed. Empty
+// title or key fields keep the previously recorded values, because
+// callback-reported activity does not carry the document title.
+func (r *Registry) Refresh(uid string, session Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune(uid)
+	if r.users[uid] == nil {
+		r.users[uid] = map[string]entry{}
+	}
+
+	previous := r.users[uid][session.FileID].session
+	if session.Title == "" {
+		session.Title = previous.Title
+	}
+	if session.Key == "" {
+		session.Key = previous.Key
+	}
+
+	r.users[uid][session.FileID] = entry{
+		session: session,
+		expires: r.now().Add(r.ttl),
+	}
+	if r.perUser != nil {
+		r.perUser.Observe(float64(len(r.users[uid])))
+	}
+}
+
+// Drop forgets one session, freeing the seat immediately.
+func (r *Registry) Drop(uid, fileID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.users[uid], fileID)
+}
+
+// Get returns the tracked session for a file, when one is still active.
+func (r *Registry) Get(uid, fileID string) (Session, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune(uid)
+	e, ok := r.users[uid][fileID]
+	return e.session, ok
+}
+
+// NoteIssued records that a download URL for the file was just handed out
+// on the user's behalf, so the download proxy accepts it even when no
+// editor session is tracked.
+func (r *Registry) NoteIssued(uid, fileID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for key, issued := range r.issued {
+		if now.Sub(issued) > issuanceWindow {
+			delete(r.issued, key)
+		}
+	}
+	r.issued[uid+"\x00"+fileID] = now
+}
+
+// RecentlyIssued reports whether a download URL for the file was handed out
+// within the issuance window.
+func (r *Registry) RecentlyIssued(uid, fileID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	issued, ok := r.issued[uid+"\x00"+fileID]
+	return ok && r.now().Sub(issued) <= issuanceWindow
+}
+
+// Active lists the user's live sessions sorted by title.
+func (r *Registry) Active(uid string) []Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune(uid)
+	active := make([]Session, 0, len(r.users[uid]))
+	for _, e := range r.users[uid] {
+		active = append(active, e.session)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].Title < active[j].Title
+	})
+	return active
+}
+
+// CanOpen reports whether opening fileID keeps the user within limit.
+// Reopening an already tracked document never counts as a new seat and a
+// non-positive limit disables the check.
+func (r *Registry) CanOpen(uid, fileID string, limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune(uid)
+	if _, open := r.users[uid][fileID]; open {
+		return true
+	}
+	return len(r.users[uid]) < limit
+}
+
+// prune drops the user's expired sessions; callers hold the lock.
+func (r *Registry) prune(uid string) {
+	now := r.now()
+	for fileID, e := range r.users[uid] {
+		if !e.expires.After(now) {
+			delete(r.users[uid], fileID)
+		}
+	}
+	if len(r.users[uid]) == 0 {
+		delete(r.users, uid)
+	}
+}
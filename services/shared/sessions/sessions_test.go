@@ -0,0 +1,122 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package sessions
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestRegistry builds a registry with a pinned clock and no metrics.
+func newTestRegistry(ttl time.Duration) (*Registry, *time.Time) {
+	now := time.Unix(1000, 0)
+	registry := &Registry{
+		ttl:    ttl,
+		now:    func() time.Time { return now },
+		users:  map[string]map[string]entry{},
+		issued: map[string]time.Time{},
+	}
+	return registry, &now
+}
+
+func TestRegistryLimitEnforcement(t *testing.T) {
+	registry, _ := newTestRegistry(time.Hour)
+
+	registry.Refresh("someone", Session{FileID: "one", Title: "One.docx", Key: "k1"})
+	registry.Refresh("someone", Session{FileID: "two", Title: "Two.xlsx", Key: "k2"})
+
+	if !registry.CanOpen("someone", "three", 3) {
+		t.Error("expected a third document to fit under a limit of 3")
+	}
+	if registry.CanOpen("someone", "three", 2) {
+		t.Error("expected a third document to be rejected under a limit of 2")
+	}
+	if !registry.CanOpen("someone", "one", 2) {
+		t.Error("expected reopening a tracked document to always pass")
+	}
+	if !registry.CanOpen("someone else", "three", 2) {
+		t.Error("expected limits to be tracked per user")
+	}
+	if !registry.CanOpen("someone", "three", 0) {
+		t.Error("expected a zero limit to disable the check")
+	}
+
+	registry.Drop("someone", "one")
+	if !registry.CanOpen("someone", "three", 2) {
+		t.Error("expected a dropped session to free its seat")
+	}
+}
+
+func TestRegistryExpiry(t *testing.T) {
+	registry, now := newTestRegistry(30 * time.Minute)
+
+	registry.Refresh("someone", Session{FileID: "one", Title: "One.docx"})
+	*now = now.Add(20 * time.Minute)
+	registry.Refresh("someone", Session{FileID: "two", Title: "Two.xlsx"})
+
+	// The first session was refreshed 20 minutes ago and is still live.
+	if got := len(registry.Active("someone")); got != 2 {
+		t.Fatalf("expected 2 active sessions, got %d", got)
+	}
+
+	// Another 20 minutes expires the first session but not the second.
+	*now = now.Add(20 * time.Minute)
+	active := registry.Active("someone")
+	if len(active) != 1 || active[0].FileID != "two" {
+		t.Fatalf("expected only the second session to survive, got %v", active)
+	}
+
+	*now = now.Add(time.Hour)
+	if got := len(registry.Active("someone")); got != 0 {
+		t.Fatalf("expected all sessions to expire, got %d", got)
+	}
+}
+
+func TestRegistryIssuanceWindow(t *testing.T) {
+	registry, now := newTestRegistry(time.Hour)
+
+	registry.NoteIssued("someone", "one")
+	if !registry.RecentlyIssued("someone", "one") {
+		t.Error("expected a fresh issuance to be recognized")
+	}
+	if registry.RecentlyIssued("someone", "two") {
+		t.Error("expected an unknown file to have no issuance")
+	}
+	if registry.RecentlyIssued("someone else", "one") {
+		t.Error("expected issuances to be tracked per user")
+	}
+
+	*now = now.Add(issuanceWindow + time.Second)
+	if registry.RecentlyIssued("someone", "one") {
+		t.Error("expected the issuance to expire after the window")
+	}
+}
+
+func TestRegistryRefreshKeepsDetails(t *testing.T) {
+	registry, _ := newTestRegistry(time.Hour)
+
+	registry.Refresh("someone", Session{FileID: "one", Title: "One.docx", Key: "k1"})
+	// Callback-driven refreshes carry no title.
+	registry.Refresh("someone", Session{FileID: "one", Key: "k1"})
+
+	session, ok := registry.Get("someone", "one")
+	if !ok || session.Title != "One.docx" || session.Key != "k1" {
+		t.Fatalf("expected the title to survive a bare refresh, got %+v", session)
+	}
+}
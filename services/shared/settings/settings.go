@@ -0,0 +1,260 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package settings persists per-user integration settings as a small JSON
+// document in the user's Drive appDataFolder, so preferences survive
+// cookie resets and follow the user across devices.
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/googleapi"
+)
+
+// settingsTitle names the settings document inside the appDataFolder.
+const settingsTitle = "onlyoffice-settings.json"
+
+// settingsQuery locates the settings document. Drive may hold several after
+// concurrent first writes; the first match wins.
+const settingsQuery = "'appDataFolder' in parents and title = '" +
+	settingsTitle + "' and trashed = false"
+
+// UserSettings is the per-user preferences document. Every field is
+// optional; the zero value means "no preference" and keeps the current
+// behavior.
+type UserSettings struct {
+	// Locale overrides the template locale derived from the Google account.
+	Locale string `json:"locale,omitempty"`
+	// NewFileType preselects the document type on the new-file page.
+	NewFileType string `json:"new_file_type,omitempty"`
+	// ConversionAction remembers the user's last choice on the conversion
+	// page ("convert" or "view").
+	ConversionAction string `json:"conversion_action,omitempty"`
+	// OpenAs remembers the last conversion-page choice per extension
+	// ("view", "edit" or "create"); extensions in OpenAsSkip apply that
+	// choice without showing the page again. Posting settings without
+	// these fields clears them, which is how preferences are reset.
+	OpenAs     map[string]string `json:"open_as,omitempty"`
+	OpenAsSkip map[string]bool   `json:"open_as_skip,omitempty"`
+}
+
+// OpenAsPreference returns the remembered conversion-page choice for ext
+// and whether it should apply without showing the page.
+func (s UserSettings) OpenAsPreference(ext string) (string, bool) {
+	action := s.OpenAs[ext]
+	return action, action != "" && s.OpenAsSkip[ext]
+}
+
+// RememberOpenAs records action as the choice for ext; skip additionally
+// marks the extension to bypass the conversion page, and unmarking it
+// here is how unchecking the box on the page takes effect.
+func (s *UserSettings) RememberOpenAs(ext, action string, skip bool) {
+	if s.OpenAs == nil {
+		s.OpenAs = map[string]string{}
+	}
+	s.OpenAs[ext] = action
+	if skip {
+		if s.OpenAsSkip == nil {
+			s.OpenAsSkip = map[string]bool{}
+		}
+		s.OpenAsSkip[ext] = true
+		return
+	}
+	delete(s.OpenAsSkip, ext)
+}
+
+func (s UserSettings) ToJSON() []byte {
+	buf, _ := json.Marshal(s)
+	return buf
+}
+
+// SettingsDrive is the subset of Drive calls the store needs. It is an
+// interface so tests can run against a fake client.
+type SettingsDrive interface {
+	List(query string) ([]*drive.File, error)
+	Download(id string) (io.ReadCloser, error)
+	Insert(file *drive.File, content io.Reader) (*drive.File, error)
+	Update(id string, content io.Reader) (*drive.File, error)
+}
+
+// driveSettingsClient adapts *drive.Service to SettingsDrive.
+type driveSettingsClient struct {
+	srv *drive.Service
+}
+
+// NewDriveClient wraps a drive service for use with the store.
+func NewDriveClient(srv *drive.Service) SettingsDrive {
+	return driveSettingsClient{srv: srv}
+}
+
+func (c driveSettingsClient) List(query string) ([]*drive.File, error) {
+	list, err := c.srv.Files.List().Q(query).Do()
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (c driveSettingsClient) Download(id string) (io.ReadCloser, error) {
+	res, err := c.srv.Files.Get(id).Download()
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (c driveSettingsClient) Insert(file *drive.File, content io.Reader) (*drive.File, error) {
+	return c.srv.Files.Insert(file).Media(content).Do()
+}
+
+func (c driveSettingsClient) Update(id string, content io.Reader) (*drive.File, error) {
+	return c.srv.Files.Update(id, &drive.File{Title: settingsTitle}).Media(content).Do()
+}
+
+// cached is one user's settings with their Drive file id (empty until the
+// document exists) and the time they were read.
+type cached struct {
+	settings UserSettings
+	fileID   string
+	readAt   time.Time
+}
+
+// Store reads and writes user settings with a short per-user cache, so a
+// session costs at most one appDataFolder round trip.
+type Store struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	now   func() time.Time
+	users map[string]cached
+}
+
+func NewStore(ttl time.Duration) *Store {
+	return &Store{
+		ttl:   ttl,
+		now:   time.Now,
+		users: map[string]cached{},
+	}
+}
+
+// Get returns the user's stored settings. A user without a settings
+// document — or without the appdata scope, which existing users consented
+// before the scope was requested — gets the zero settings without an
+// error.
+func (s *Store) Get(client SettingsDrive, uid string) (UserSettings, error) {
+	s.mu.Lock()
+	entry, ok := s.users[uid]
+	s.mu.Unlock()
+	if ok && s.now().Sub(entry.readAt) < s.ttl {
+		return entry.settings, nil
+	}
+
+	files, err := client.List(settingsQuery)
+	if err != nil {
+		if isScopeDenied(err) {
+			// The appdata scope is optional for users who consented before
+			// it was requested; they simply keep the defaults.
+			s.remember(uid, UserSettings{}, "")
+			return UserSettings{}, nil
+		}
+		return UserSettings{}, err
+	}
+
+	if len(files) == 0 {
+		s.remember(uid, UserSettings{}, "")
+		return UserSettings{}, nil
+	}
+
+	body, err := client.Download(files[0].Id)
+	if err != nil {
+		return UserSettings{}, err
+	}
+	defer body.Close()
+
+	var settings UserSettings
+	if err := json.NewDecoder(body).Decode(&settings); err != nil {
+		return UserSettings{}, err
+	}
+
+	s.remember(uid, settings, files[0].Id)
+	return settings, nil
+}
+
+// Save writes the settings document, creating it on first use.
+func (s *Store) Save(client SettingsDrive, uid string, settings UserSettings) error {
+	s.mu.Lock()
+	entry, ok := s.users[uid]
+	s.mu.Unlock()
+
+	fileID := entry.fileID
+	if !ok || fileID == "" {
+		files, err := client.List(settingsQuery)
+		if err != nil && !isScopeDenied(err) {
+			return err
+		}
+		if len(files) > 0 {
+			fileID = files[0].Id
+		}
+	}
+
+	if fileID == "" {
+		file, err := client.Insert(&drive.File{
+			Title:    settingsTitle,
+			MimeType: "application/json",
+			Parents:  []*drive.ParentReference{{Id: "appDataFolder"}},
+		}, bytes.NewReader(settings.ToJSON()))
+		if err != nil {
+			return err
+		}
+		s.remember(uid, settings, file.Id)
+		return nil
+	}
+
+	if _, err := client.Update(fileID, bytes.NewReader(settings.ToJSON())); err != nil {
+		return err
+	}
+	s.remember(uid, settings, fileID)
+	return nil
+}
+
+// Invalidate drops the cached entry, forcing the next Get to re-read.
+func (s *Store) Invalidate(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, uid)
+}
+
+func (s *Store) remember(uid string, settings UserSettings, fileID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[uid] = cached{settings: settings, fileID: fileID, readAt: s.now()}
+}
+
+// isScopeDenied reports whether Drive rejected the call because the token
+// lacks the appdata scope.
+func isScopeDenied(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusForbidden
+}
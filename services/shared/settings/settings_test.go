@@ -0,0 +1,205 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package settings
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/googleapi"
+)
+
+// fakeSettingsDrive keeps the settings document in memory and counts calls
+// so tests can assert on caching.
+type fakeSettingsDrive struct {
+	content  []byte
+	fileID   string
+	listErr  error
+	lists    int
+	inserts  int
+	updates  int
+	download int
+}
+
+func (d *fakeSettingsDrive) List(query string) ([]*drive.File, error) {
+	d.lists++
+	if d.listErr != nil {
+		return nil, d.listErr
+	}
+	if d.fileID == "" {
+		return nil, nil
+	}
+	return []*drive.File{{Id: d.fileID, Title: settingsTitle}}, nil
+}
+
+func (d *fakeSettingsDrive) Download(id string) (io.ReadCloser, error) {
+	d.download++
+	return io.NopCloser(bytes.NewReader(d.content)), nil
+}
+
+func (d *fakeSettingsDrive) Insert(file *drive.File, content io.Reader) (*drive.File, error) {
+	d.inserts++
+	d.fileID = "settings-file"
+	d.content, _ = io.ReadAll(content)
+	return &drive.File{Id: d.fileID}, nil
+}
+
+func (d *fakeSettingsDrive) Update(id string, content io.Reader) (*drive.File, error) {
+	d.updates++
+	d.content, _ = io.ReadAll(content)
+	return &drive.File{Id: id}, nil
+}
+
+func newTestStore() *Store {
+	return NewStore(time.Minute)
+}
+
+func TestStoreFirstRunCreatesDocument(t *testing.T) {
+	store := newTestStore()
+	client := &fakeSettingsDrive{}
+
+	settings, err := store.Get(client, "someone")
+	if err != nil {
+		t.Fatalf("expected a first run to succeed: %s", err.Error())
+	}
+	if !reflect.DeepEqual(settings, UserSettings{}) {
+		t.Fatalf("expected zero settings on first run, got %+v", settings)
+	}
+
+	if err := store.Save(client, "someone", UserSettings{Locale: "de-DE"}); err != nil {
+		t.Fatalf("expected the first save to succeed: %s", err.Error())
+	}
+	if client.inserts != 1 || client.updates != 0 {
+		t.Fatalf("expected one insert and no updates, got %d/%d", client.inserts, client.updates)
+	}
+}
+
+func TestStoreReadIsCached(t *testing.T) {
+	store := newTestStore()
+	client := &fakeSettingsDrive{
+		fileID:  "settings-file",
+		content: UserSettings{Locale: "fr-FR", NewFileType: "spreadsheet"}.ToJSON(),
+	}
+
+	for i := 0; i < 3; i++ {
+		settings, err := store.Get(client, "someone")
+		if err != nil {
+			t.Fatalf("expected the read to succeed: %s", err.Error())
+		}
+		if settings.Locale != "fr-FR" || settings.NewFileType != "spreadsheet" {
+			t.Fatalf("unexpected settings: %+v", settings)
+		}
+	}
+
+	if client.lists != 1 || client.download != 1 {
+		t.Fatalf("expected one drive round trip, got %d lists and %d downloads", client.lists, client.download)
+	}
+}
+
+func TestStoreUpdateExistingDocument(t *testing.T) {
+	store := newTestStore()
+	client := &fakeSettingsDrive{
+		fileID:  "settings-file",
+		content: UserSettings{Locale: "fr-FR"}.ToJSON(),
+	}
+
+	if _, err := store.Get(client, "someone"); err != nil {
+		t.Fatalf("expected the read to succeed: %s", err.Error())
+	}
+	if err := store.Save(client, "someone", UserSettings{Locale: "es-ES"}); err != nil {
+		t.Fatalf("expected the update to succeed: %s", err.Error())
+	}
+
+	if client.inserts != 0 || client.updates != 1 {
+		t.Fatalf("expected one update and no inserts, got %d/%d", client.updates, client.inserts)
+	}
+
+	settings, err := store.Get(client, "someone")
+	if err != nil {
+		t.Fatalf("expected the cached read to succeed: %s", err.Error())
+	}
+	if settings.Locale != "es-ES" {
+		t.Fatalf("expected the cache to carry the update, got %+v", settings)
+	}
+}
+
+// Users who consented before the appdata scope was requested keep working
+// with the defaults instead of seeing errors.
+func TestStoreMissingScopeFallsBackToDefaults(t *testing.T) {
+	store := newTestStore()
+	client := &fakeSettingsDrive{
+		listErr: &googleapi.Error{Code: http.StatusForbidden, Message: "insufficient scopes"},
+	}
+
+	settings, err := store.Get(client, "someone")
+	if err != nil {
+		t.Fatalf("expected a missing scope to fall back to defaults: %s", err.Error())
+	}
+	if !reflect.DeepEqual(settings, UserSettings{}) {
+		t.Fatalf("expected zero settings, got %+v", settings)
+	}
+
+	// The denial is remembered, so the scope is not probed per request.
+	if _, err := store.Get(client, "someone"); err != nil {
+		t.Fatalf("expected the cached fallback to succeed: %s", err.Error())
+	}
+	if client.lists != 1 {
+		t.Fatalf("expected a single probe, got %d", client.lists)
+	}
+}
+
+// Open-as preferences survive a round trip through the store, and
+// unmarking "don't ask again" clears the skip without losing the choice.
+func TestOpenAsPreferencesPersist(t *testing.T) {
+	store := newTestStore()
+	client := &fakeSettingsDrive{}
+
+	stored, _ := store.Get(client, "someone")
+	stored.RememberOpenAs("csv", "edit", true)
+	if err := store.Save(client, "someone", stored); err != nil {
+		t.Fatalf("expected the save to succeed: %s", err.Error())
+	}
+
+	store.Invalidate("someone")
+	settings, err := store.Get(client, "someone")
+	if err != nil {
+		t.Fatalf("expected the re-read to succeed: %s", err.Error())
+	}
+	if action, skip := settings.OpenAsPreference("csv"); action != "edit" || !skip {
+		t.Fatalf("expected a skipping edit preference, got %q/%t", action, skip)
+	}
+	if action, skip := settings.OpenAsPreference("txt"); action != "" || skip {
+		t.Fatalf("expected no preference for .txt, got %q/%t", action, skip)
+	}
+
+	settings.RememberOpenAs("csv", "edit", false)
+	if err := store.Save(client, "someone", settings); err != nil {
+		t.Fatalf("expected the save to succeed: %s", err.Error())
+	}
+	store.Invalidate("someone")
+	settings, _ = store.Get(client, "someone")
+	if action, skip := settings.OpenAsPreference("csv"); action != "edit" || skip {
+		t.Fatalf("expected the choice without the skip, got %q/%t", action, skip)
+	}
+}
@@ -0,0 +1,57 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"fmt"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+// ResourceKeysHeader scopes a Drive request to a link-shared file's resource
+// key; without it Files.Get on such a file answers 404.
+const ResourceKeysHeader = "X-Goog-Drive-Resource-Keys"
+
+// ShortcutResolver fetches a shortcut target's metadata. A non-empty
+// resourceKey must scope the request via ResourceKeysHeader, because
+// shortcut targets in link-shared folders are otherwise invisible.
+type ShortcutResolver func(targetID, resourceKey string) (*drive.File, error)
+
+// ResolveShortcut follows a Drive shortcut to its target file so callers
+// always operate on the object that actually carries content. Non-shortcut
+// files pass through unchanged; a shortcut whose target details are missing
+// is reported as an error instead of producing a contentless file later.
+func ResolveShortcut(file *drive.File, resolve ShortcutResolver) (*drive.File, error) {
+	if file == nil || file.MimeType != GoogleShortcutMime {
+		return file, nil
+	}
+	if file.ShortcutDetails == nil || file.ShortcutDetails.TargetId == "" {
+		return nil, fmt.Errorf("shortcut %s carries no target", file.Id)
+	}
+	return resolve(file.ShortcutDetails.TargetId, file.ShortcutDetails.TargetResourceKey)
+}
+
+// IsTrashed reports whether Drive marks the file as trashed, either
+// explicitly by the user or through a trashed parent folder.
+func IsTrashed(file *drive.File) bool {
+	if file == nil {
+		return false
+	}
+	return file.ExplicitlyTrashed || (file.Labels != nil && file.Labels.Trashed)
+}
@@ -0,0 +1,89 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"testing"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestResolveShortcut(t *testing.T) {
+	target := &drive.File{Id: "target-id", Title: "Target.docx"}
+
+	t.Run("regular files pass through", func(t *testing.T) {
+		file := &drive.File{Id: "file-id", MimeType: "application/pdf"}
+		resolved, err := ResolveShortcut(file, func(string, string) (*drive.File, error) {
+			t.Fatal("a regular file must not be resolved")
+			return nil, nil
+		})
+		if err != nil || resolved != file {
+			t.Errorf("expected the file unchanged, got %v (%v)", resolved, err)
+		}
+	})
+
+	t.Run("shortcuts resolve to their target", func(t *testing.T) {
+		resolved, err := ResolveShortcut(&drive.File{
+			Id:       "shortcut-id",
+			MimeType: GoogleShortcutMime,
+			ShortcutDetails: &drive.FileShortcutDetails{
+				TargetId:          "target-id",
+				TargetResourceKey: "0-abcDEF",
+			},
+		}, func(targetID, resourceKey string) (*drive.File, error) {
+			if targetID != "target-id" || resourceKey != "0-abcDEF" {
+				t.Errorf("unexpected resolver arguments: %s %s", targetID, resourceKey)
+			}
+			return target, nil
+		})
+		if err != nil || resolved != target {
+			t.Errorf("expected the target file, got %v (%v)", resolved, err)
+		}
+	})
+
+	t.Run("shortcuts without a target error out", func(t *testing.T) {
+		if _, err := ResolveShortcut(&drive.File{
+			Id:       "shortcut-id",
+			MimeType: GoogleShortcutMime,
+		}, func(string, string) (*drive.File, error) {
+			return target, nil
+		}); err == nil {
+			t.Error("expected a shortcut without target details to be rejected")
+		}
+	})
+}
+
+func TestIsTrashed(t *testing.T) {
+	for name, tc := range map[string]struct {
+		file *drive.File
+		want bool
+	}{
+		"live file":          {&drive.File{Labels: &drive.FileLabels{}}, false},
+		"no labels":          {&drive.File{}, false},
+		"explicitly trashed": {&drive.File{ExplicitlyTrashed: true}, true},
+		"trashed via parent": {&drive.File{Labels: &drive.FileLabels{Trashed: true}}, true},
+		"nil file":           {nil, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := IsTrashed(tc.file); got != tc.want {
+				t.Errorf("IsTrashed = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Internal service-to-service requests carry an HMAC signature instead of a
+// user session. The signature covers the method, path, a body digest and a
+// timestamp, so a captured request cannot be replayed against another
+// endpoint, with another payload or outside the replay window.
+const (
+	InternalSignatureHeader = "X-Onlyoffice-Signature"
+	InternalTimestampHeader = "X-Onlyoffice-Timestamp"
+)
+
+// ErrInternalSignature is returned for any verification failure. Callers
+// get no detail about which check failed.
+var ErrInternalSignature = errors.New("invalid internal request signature")
+
+func internalSignature(secret []byte, method, path, timestamp string, body []byte) string {
+	bodySum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, path, hex.EncodeToString(bodySum[:]), timestamp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignInternalRequest stamps req with the signature headers. The body must
+// be passed explicitly because req.Body is not rewindable.
+func SignInternalRequest(secret string, req *http.Request, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(InternalTimestampHeader, timestamp)
+	req.Header.Set(InternalSignatureHeader, internalSignature(
+		[]byte(secret), req.Method, req.URL.Path, timestamp, body,
+	))
+}
+
+// VerifyInternalRequest checks the signature headers against every accepted
+// secret, so a primary/secondary pair lets installs rotate the secret
+// without a simultaneous restart of all services.
+func VerifyInternalRequest(
+	secrets []string, req *http.Request, body []byte,
+	window time.Duration, now time.Time,
+) error {
+	timestamp := req.Header.Get(InternalTimestampHeader)
+	signature := req.Header.Get(InternalSignatureHeader)
+	if timestamp == "" || signature == "" {
+		return ErrInternalSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInternalSignature
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > window || skew < -window {
+		return ErrInternalSignature
+	}
+
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		expected := internalSignature([]byte(secret), req.Method, req.URL.Path, timestamp, body)
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			return nil
+		}
+	}
+
+	return ErrInternalSignature
+}
+
+// InternalAuthMiddleware guards internal/admin endpoints with the signature
+// check. Endpoints answer 404 when no internal secret is configured, so an
+// unconfigured install does not advertise their existence.
+func InternalAuthMiddleware(source *OnlyofficeConfigSource) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			internal := source.Config().Onlyoffice.Internal
+			secrets := internal.Secrets()
+			if len(secrets) == 0 {
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err != nil {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			window := time.Duration(internal.ReplayWindow) * time.Second
+			if err := VerifyInternalRequest(secrets, r, body, window, time.Now()); err != nil {
+				rw.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// InternalClient is an http client wrapper that signs every outgoing
+// request with the primary internal secret.
+type InternalClient struct {
+	secret string
+	client *http.Client
+}
+
+// NewInternalClient wraps client with internal request signing. A nil
+// client falls back to http.DefaultClient.
+func NewInternalClient(secret string, client *http.Client) InternalClient {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return InternalClient{secret: secret, client: client}
+}
+
+// Do signs and sends the request. The body, when present, is buffered to
+// compute its digest.
+func (c InternalClient) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	SignInternalRequest(c.secret, req, body)
+	return c.client.Do(req)
+}
@@ -0,0 +1,144 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedTestRequest(t *testing.T, secret, method, path string, body []byte) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	SignInternalRequest(secret, req, body)
+	return req
+}
+
+func TestVerifyInternalRequest(t *testing.T) {
+	secrets := []string{"primary", "secondary"}
+	body := []byte(`{"uid":"someone"}`)
+	now := time.Now()
+	window := 30 * time.Second
+
+	t.Run("primary secret", func(t *testing.T) {
+		req := signedTestRequest(t, "primary", http.MethodPost, "/internal/reload", body)
+		if err := VerifyInternalRequest(secrets, req, body, window, now); err != nil {
+			t.Fatalf("expected the primary secret to verify: %s", err.Error())
+		}
+	})
+
+	t.Run("rotated secret", func(t *testing.T) {
+		req := signedTestRequest(t, "secondary", http.MethodPost, "/internal/reload", body)
+		if err := VerifyInternalRequest(secrets, req, body, window, now); err != nil {
+			t.Fatalf("expected the secondary secret to verify: %s", err.Error())
+		}
+	})
+
+	t.Run("unknown secret", func(t *testing.T) {
+		req := signedTestRequest(t, "forged", http.MethodPost, "/internal/reload", body)
+		if err := VerifyInternalRequest(secrets, req, body, window, now); err == nil {
+			t.Fatal("expected an unknown secret to be rejected")
+		}
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		req := signedTestRequest(t, "primary", http.MethodPost, "/internal/reload", body)
+		if err := VerifyInternalRequest(secrets, req, []byte("{}"), window, now); err == nil {
+			t.Fatal("expected a tampered body to be rejected")
+		}
+	})
+
+	t.Run("tampered path", func(t *testing.T) {
+		req := signedTestRequest(t, "primary", http.MethodPost, "/internal/reload", body)
+		req.URL.Path = "/internal/sessions"
+		if err := VerifyInternalRequest(secrets, req, body, window, now); err == nil {
+			t.Fatal("expected a replay against another path to be rejected")
+		}
+	})
+
+	t.Run("replay outside window", func(t *testing.T) {
+		req := signedTestRequest(t, "primary", http.MethodPost, "/internal/reload", body)
+		if err := VerifyInternalRequest(secrets, req, body, window, now.Add(time.Minute)); err == nil {
+			t.Fatal("expected a stale timestamp to be rejected")
+		}
+	})
+
+	t.Run("missing headers", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/internal/reload", bytes.NewReader(body))
+		if err := VerifyInternalRequest(secrets, req, body, window, now); err == nil {
+			t.Fatal("expected an unsigned request to be rejected")
+		}
+	})
+}
+
+func TestInternalAuthMiddleware(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `onlyoffice:
+  builder:
+    document_server_url: "https://ds.example.com"
+    gateway_url: "https://gateway.example.com"
+    callback_url: "https://callback.example.com"
+  internal:
+    secret: "primary"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(InternalAuthMiddleware(source)(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		}),
+	))
+	defer server.Close()
+
+	client := NewInternalClient("primary", nil)
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/internal/reload", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a signed request to pass, got %d", resp.StatusCode)
+	}
+
+	unsigned, err := http.Post(server.URL+"/internal/reload", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned.Body.Close()
+	if unsigned.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected an unsigned request to be rejected, got %d", unsigned.StatusCode)
+	}
+}
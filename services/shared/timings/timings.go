@@ -0,0 +1,176 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package timings records how long conversions and saves take relative to
+// document size, the numbers Document Server capacity planning runs on.
+package timings
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Operations whose durations are recorded.
+const (
+	OperationConvert = "convert"
+	OperationSave    = "save"
+)
+
+const mib = 1 << 20
+
+// SizeBucket maps a streamed byte count onto the label bucket the duration
+// is recorded under. The edges follow the sizes that matter operationally:
+// small files convert in seconds, files past 100MB approach the configured
+// open limits.
+func SizeBucket(bytes int64) string {
+	switch {
+	case bytes < mib:
+		return "<1MB"
+	case bytes < 10*mib:
+		return "1-10MB"
+	case bytes < 100*mib:
+		return "10-100MB"
+	default:
+		return ">=100MB"
+	}
+}
+
+// Recorder aggregates conversion and save durations per document family
+// and size bucket. Prometheus carries the histograms; a small in-process
+// aggregate backs the periodic info-level summary, because the default
+// registry cannot be read back cheaply.
+type Recorder struct {
+	mu     sync.Mutex
+	totals map[string]*aggregate
+
+	durations *prometheus.HistogramVec
+}
+
+// aggregate is one summary cell: how many operations landed in it and how
+// much wall time they took since the last summary was logged.
+type aggregate struct {
+	count   int
+	seconds float64
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		totals: make(map[string]*aggregate),
+		durations: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "onlyoffice_document_operation_duration_seconds",
+			Help:    "Conversion and save durations by document family and size bucket.",
+			Buckets: []float64{1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+		}, []string{"operation", "family", "size_bucket"}),
+	}
+}
+
+// Observe records one finished operation: its kind, the document family
+// (word/cell/slide), the byte count observed while streaming the content
+// and the wall time it took.
+func (r *Recorder) Observe(operation, family string, bytes int64, elapsed time.Duration) {
+	bucket := SizeBucket(bytes)
+	if r.durations != nil {
+		r.durations.WithLabelValues(operation, family, bucket).Observe(elapsed.Seconds())
+	}
+
+	key := fmt.Sprintf("%s %s %s", operation, family, bucket)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cell, ok := r.totals[key]
+	if !ok {
+		cell = &aggregate{}
+		r.totals[key] = cell
+	}
+	cell.count++
+	cell.seconds += elapsed.Seconds()
+}
+
+// summaryLines drains the aggregates into sorted, human-readable lines,
+// one per cell observed since the previous drain.
+func (r *Recorder) summaryLines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lines := make([]string, 0, len(r.totals))
+	for key, cell := range r.totals {
+		lines = append(lines, fmt.Sprintf(
+			"%s: %d in %.1fs (avg %.1fs)",
+			key, cell.count, cell.seconds, cell.seconds/float64(cell.count),
+		))
+	}
+	r.totals = make(map[string]*aggregate)
+
+	sort.Strings(lines)
+	return lines
+}
+
+// StartSummaries logs one info line per observed cell every interval until
+// the context ends; quiet intervals log nothing.
+func (r *Recorder) StartSummaries(
+	ctx context.Context, interval time.Duration,
+	infof func(format string, args ...interface{}),
+) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, line := range r.summaryLines() {
+					infof("timings: %s", line)
+				}
+			}
+		}
+	}()
+}
+
+// CountingReader counts the bytes streamed through it, so pipelines can
+// record the size actually transferred without buffering the content.
+type CountingReader struct {
+	mu    sync.Mutex
+	inner io.Reader
+	bytes int64
+}
+
+func NewCountingReader(inner io.Reader) *CountingReader {
+	return &CountingReader{inner: inner}
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.inner.Read(p)
+	c.mu.Lock()
+	c.bytes += int64(n)
+	c.mu.Unlock()
+	return n, err
+}
+
+// Bytes reports how many bytes have been read so far.
+func (c *CountingReader) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
@@ -0,0 +1,95 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package timings
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRecorder skips metric registration so parallel tests do not trip
+// the prometheus registry; the summary aggregates carry the assertions.
+func newTestRecorder() *Recorder {
+	return &Recorder{totals: make(map[string]*aggregate)}
+}
+
+func TestSizeBucket(t *testing.T) {
+	for name, tc := range map[string]struct {
+		bytes int64
+		want  string
+	}{
+		"empty file":       {0, "<1MB"},
+		"just under 1MB":   {1<<20 - 1, "<1MB"},
+		"exactly 1MB":      {1 << 20, "1-10MB"},
+		"middle of range":  {5 << 20, "1-10MB"},
+		"exactly 10MB":     {10 << 20, "10-100MB"},
+		"just under 100MB": {100<<20 - 1, "10-100MB"},
+		"exactly 100MB":    {100 << 20, ">=100MB"},
+		"gigabyte":         {1 << 30, ">=100MB"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := SizeBucket(tc.bytes); got != tc.want {
+				t.Errorf("SizeBucket(%d) = %q, want %q", tc.bytes, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRecorderAggregatesPerBucket(t *testing.T) {
+	recorder := newTestRecorder()
+
+	recorder.Observe(OperationConvert, "word", 5<<20, 2*time.Second)
+	recorder.Observe(OperationConvert, "word", 8<<20, 4*time.Second)
+	recorder.Observe(OperationSave, "cell", 200<<20, 30*time.Second)
+
+	lines := recorder.summaryLines()
+	if len(lines) != 2 {
+		t.Fatalf("expected two summary cells, got %d: %v", len(lines), lines)
+	}
+	if !strings.HasPrefix(lines[0], "convert word 1-10MB: 2 in 6.0s (avg 3.0s)") {
+		t.Errorf("unexpected convert cell: %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "save cell >=100MB: 1 in 30.0s (avg 30.0s)") {
+		t.Errorf("unexpected save cell: %s", lines[1])
+	}
+
+	// The drain resets the aggregates; a quiet interval logs nothing.
+	if rest := recorder.summaryLines(); len(rest) != 0 {
+		t.Errorf("expected the drain to reset the aggregates, got %v", rest)
+	}
+}
+
+func TestCountingReader(t *testing.T) {
+	counted := NewCountingReader(strings.NewReader("streamed content"))
+
+	buf := make([]byte, 8)
+	total := 0
+	for {
+		n, err := counted.Read(buf)
+		total += n
+		if err != nil {
+			break
+		}
+	}
+
+	if counted.Bytes() != int64(total) || counted.Bytes() != 16 {
+		t.Errorf("expected 16 counted bytes, got %d", counted.Bytes())
+	}
+}
@@ -0,0 +1,85 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import "github.com/golang-jwt/jwt/v4"
+
+// SessionCookie is the cookie carrying the active account's session token.
+const SessionCookie = "onlyoffice_auth"
+
+// AccountSessionCookie returns the per-account cookie name a signed-in
+// account's session is mirrored under. Drive users are often signed into
+// several Google accounts at once; the keyed copies let the gateway switch
+// the active session to whichever account launched the app without another
+// consent round trip.
+func AccountSessionCookie(uid string) string {
+	return SessionCookie + "_" + uid
+}
+
+// DownloadToken authorizes a single-file download through the gateway
+// /api/download endpoint. It is issued by the builder when it embeds a
+// document URL into the editor config and by the gateway when it hands a
+// source URL to the Document Server conversion API.
+type DownloadToken struct {
+	jwt.RegisteredClaims
+	UID    string `json:"uid"`
+	FileID string `json:"file_id"`
+	// RevisionID pins the download to an older revision's content.
+	RevisionID string `json:"revision_id,omitempty"`
+	// Public marks a token minted for an anonymous viewer of a link-shared
+	// file; the download proxy serves it through the key-only Drive client
+	// instead of stored user credentials.
+	Public bool `json:"public,omitempty"`
+}
+
+// SessionToken is the gateway auth cookie payload identifying a signed-in
+// Google user.
+type SessionToken struct {
+	jwt.RegisteredClaims
+	UID string `json:"uid"`
+	// Email is remembered so a later account switch can point the consent
+	// screen at the right account via login_hint.
+	Email string `json:"email,omitempty"`
+	// AbsoluteExpiresAt caps the total age of a sliding session: renewals
+	// extend ExpiresAt but never past this instant, after which re-consent
+	// is the only way back in.
+	AbsoluteExpiresAt *jwt.NumericDate `json:"abs_exp,omitempty"`
+}
+
+// UploadRetryToken authorizes re-enqueueing a dropped background upload
+// through the callback /callback/retry endpoint. It carries the whole job,
+// so a retry needs no stored state beyond the Document Server still serving
+// the saved revision URL.
+type UploadRetryToken struct {
+	jwt.RegisteredClaims
+	UID        string `json:"uid"`
+	FileID     string `json:"file_id"`
+	URL        string `json:"url"`
+	Key        string `json:"key,omitempty"`
+	ChangesURL string `json:"changes_url,omitempty"`
+}
+
+// PickerToken authorizes opening a picked Drive file through the embedded
+// view-only editor at /api/picker/embed. The expiry is the only thing that
+// bounds its use, so issuers must keep it short.
+type PickerToken struct {
+	jwt.RegisteredClaims
+	UID    string `json:"uid"`
+	FileID string `json:"file_id"`
+}
@@ -0,0 +1,164 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// defaultTraceBodyLimit caps how much of a request or response body one
+// trace line may carry.
+const defaultTraceBodyLimit = 4 << 10
+
+// OnlyofficeTraceConfig selects which routes the debug trace middleware
+// captures. Routes are path prefixes ("/callback", "/api/download");
+// MaxBody (bytes) caps the logged body size, 4 KiB when unset. The
+// middleware itself only runs when the server is in debug mode.
+type OnlyofficeTraceConfig struct {
+	Routes  []string `yaml:"routes" env:"ONLYOFFICE_TRACE_ROUTES,overwrite"`
+	MaxBody int      `yaml:"max_body" env:"ONLYOFFICE_TRACE_MAX_BODY,overwrite"`
+}
+
+// tokenValues matches JSON string fields whose key contains "token", so
+// signed payloads inside traced bodies never hit the log verbatim.
+var tokenValues = regexp.MustCompile(`("[^"]*token[^"]*"\s*:\s*")([^"]*)(")`)
+
+// TraceMiddleware logs request headers and bodies and the response status
+// and body for the configured routes, tagged with the chi request id. It
+// exists for diagnosing Document Server interop problems without printf
+// patches. Outside debug mode the handler chain is returned untouched, so
+// production requests pay nothing for it.
+func TraceMiddleware(source *OnlyofficeConfigSource, debug bool, logger log.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !debug {
+			return next
+		}
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			config := source.Config().Onlyoffice.Trace
+			if !traceMatches(config.Routes, r.URL.Path) {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			limit := config.MaxBody
+			if limit <= 0 {
+				limit = defaultTraceBodyLimit
+			}
+
+			id := chimiddleware.GetReqID(r.Context())
+			body := captureBody(r, limit)
+			logger.Debugf(
+				"trace[%s] > %s %s headers: %s body: %s",
+				id, r.Method, RedactURL(r.URL.String()),
+				traceHeaders(r.Header), redactTracedBody(body),
+			)
+
+			recorder := &traceRecorder{ResponseWriter: rw, status: http.StatusOK, limit: limit}
+			next.ServeHTTP(recorder, r)
+
+			logger.Debugf(
+				"trace[%s] < %d body: %s",
+				id, recorder.status, redactTracedBody(recorder.body.String()),
+			)
+		})
+	}
+}
+
+func traceMatches(routes []string, path string) bool {
+	for _, route := range routes {
+		if route != "" && strings.HasPrefix(path, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// captureBody reads up to limit bytes of the request body for the trace and
+// splices them back so the handler still sees the full stream.
+func captureBody(r *http.Request, limit int) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	captured, _ := io.ReadAll(io.LimitReader(r.Body, int64(limit)))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(captured), r.Body), r.Body}
+
+	return string(captured)
+}
+
+// traceHeaders renders the request headers with credential-bearing values
+// redacted.
+func traceHeaders(headers http.Header) string {
+	var rendered []string
+	for name, values := range headers {
+		value := strings.Join(values, ", ")
+		lowered := strings.ToLower(name)
+		if lowered == "authorization" || lowered == "cookie" ||
+			strings.Contains(lowered, "token") {
+			value = Redact(value)
+		}
+		rendered = append(rendered, fmt.Sprintf("%s=%s", name, value))
+	}
+	return strings.Join(rendered, " ")
+}
+
+// redactTracedBody masks token-valued JSON fields inside a traced body.
+func redactTracedBody(body string) string {
+	return tokenValues.ReplaceAllStringFunc(body, func(match string) string {
+		parts := tokenValues.FindStringSubmatch(match)
+		return parts[1] + Redact(parts[2]) + parts[3]
+	})
+}
+
+// traceRecorder tees the response into a size-capped buffer while passing
+// everything through to the real writer.
+type traceRecorder struct {
+	http.ResponseWriter
+	status int
+	limit  int
+	body   bytes.Buffer
+}
+
+func (t *traceRecorder) WriteHeader(status int) {
+	t.status = status
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *traceRecorder) Write(content []byte) (int, error) {
+	if remaining := t.limit - t.body.Len(); remaining > 0 {
+		if len(content) > remaining {
+			t.body.Write(content[:remaining])
+		} else {
+			t.body.Write(content)
+		}
+	}
+	return t.ResponseWriter.Write(content)
+}
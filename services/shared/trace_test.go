@@ -0,0 +1,140 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package shared
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// collectingLogger records Debugf lines so tests can inspect trace output.
+type collectingLogger struct {
+	lines []string
+}
+
+func (l *collectingLogger) Debugf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+func (l *collectingLogger) Infof(format string, args ...interface{})  {}
+func (l *collectingLogger) Warnf(format string, args ...interface{})  {}
+func (l *collectingLogger) Errorf(format string, args ...interface{}) {}
+func (l *collectingLogger) Fatalf(format string, args ...interface{}) {}
+
+func newTraceTestSource(t *testing.T, maxBody int) *OnlyofficeConfigSource {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := fmt.Sprintf(
+		"onlyoffice:\n  trace:\n    routes:\n      - /callback\n    max_body: %d\n",
+		maxBody,
+	)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewOnlyofficeConfigSource(path, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return source
+}
+
+func TestTraceRedactsTokens(t *testing.T) {
+	logger := &collectingLogger{}
+	handler := TraceMiddleware(newTraceTestSource(t, 0), true, logger)(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			rw.Write([]byte(`{"error":0}`))
+		}),
+	)
+
+	const secret = "secret-jwt-payload-value"
+	req := httptest.NewRequest(
+		http.MethodPost, "/callback?id=file-1",
+		strings.NewReader(fmt.Sprintf(`{"key":"k","status":2,"token":"%s"}`, secret)),
+	)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(logger.lines) != 2 {
+		t.Fatalf("expected a request and a response line, got %d", len(logger.lines))
+	}
+	for _, line := range logger.lines {
+		if strings.Contains(line, secret) {
+			t.Errorf("trace leaked a secret: %s", line)
+		}
+	}
+	if !strings.Contains(logger.lines[0], `"token":"secr****alue"`) {
+		t.Errorf("expected a redacted token in %s", logger.lines[0])
+	}
+	if !strings.Contains(logger.lines[1], `{"error":0}`) {
+		t.Errorf("expected the response body in %s", logger.lines[1])
+	}
+}
+
+func TestTraceCapsBodySize(t *testing.T) {
+	logger := &collectingLogger{}
+	var received int64
+	handler := TraceMiddleware(newTraceTestSource(t, 64), true, logger)(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			received, _ = io.Copy(io.Discard, r.Body)
+			rw.Write([]byte(strings.Repeat("b", 1024)))
+		}),
+	)
+
+	body := strings.Repeat("a", 1024)
+	req := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// The handler still sees the whole stream and the client the whole
+	// response; only the logged copies are capped.
+	if received != int64(len(body)) {
+		t.Errorf("expected the handler to read %d bytes, got %d", len(body), received)
+	}
+	if rec.Body.Len() != 1024 {
+		t.Errorf("expected a 1024 byte response, got %d", rec.Body.Len())
+	}
+	for _, line := range logger.lines {
+		if len(line) > 512 {
+			t.Errorf("expected capped trace lines, got %d bytes: %.80s", len(line), line)
+		}
+	}
+}
+
+func TestTraceSkipsUnlistedRoutesAndDisabledDebug(t *testing.T) {
+	logger := &collectingLogger{}
+	source := newTraceTestSource(t, 0)
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+	TraceMiddleware(source, true, logger)(next).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/editor", nil))
+	TraceMiddleware(source, false, logger)(next).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/callback", nil))
+
+	if len(logger.lines) != 0 {
+		t.Errorf("expected no trace output, got %v", logger.lines)
+	}
+}
@@ -0,0 +1,211 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// memoryQueueSize bounds how many jobs can wait in the in-process queue.
+const memoryQueueSize = 1024
+
+// ErrQueueFull is returned by Enqueue when the in-process queue is at
+// capacity.
+var ErrQueueFull = errors.New("memory worker queue is full")
+
+// ErrWorkerStopped is returned by Enqueue after the worker began draining.
+var ErrWorkerStopped = errors.New("memory worker is stopped")
+
+type memoryTask struct {
+	pattern string
+	payload []byte
+	attempt int
+}
+
+// MemoryWorker is an in-process replacement for the redis-backed
+// background worker so single-node installs can run without Redis. Jobs
+// live in a bounded channel and are lost on restart; behavior otherwise
+// matches the queued path: registered handlers, bounded concurrency and
+// retries with backoff.
+type MemoryWorker struct {
+	mu          sync.Mutex
+	handlers    map[string]func(ctx context.Context, payload []byte) error
+	dropped     map[string]func(payload []byte, err error)
+	queue       chan memoryTask
+	concurrency int
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+	warn        func(format string, args ...interface{})
+	stopped     bool
+	draining    chan struct{}
+	wg          sync.WaitGroup
+}
+
+// NewMemoryWorker builds an in-process worker running handlers on
+// concurrency goroutines and retrying failed jobs up to maxAttempts times.
+// The warn callback receives dropped-job notices.
+func NewMemoryWorker(
+	concurrency, maxAttempts int,
+	warn func(format string, args ...interface{}),
+) *MemoryWorker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &MemoryWorker{
+		handlers:    make(map[string]func(ctx context.Context, payload []byte) error),
+		dropped:     make(map[string]func(payload []byte, err error)),
+		queue:       make(chan memoryTask, memoryQueueSize),
+		concurrency: concurrency,
+		maxAttempts: maxAttempts,
+		backoff: func(attempt int) time.Duration {
+			return time.Duration(attempt*attempt) * time.Second
+		},
+		warn:     warn,
+		draining: make(chan struct{}),
+	}
+}
+
+// Register attaches a handler to a job pattern. Registration must happen
+// before Run.
+func (w *MemoryWorker) Register(pattern string, handler func(ctx context.Context, payload []byte) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[pattern] = handler
+}
+
+// RegisterDropped attaches a hook invoked when a job for the pattern is
+// finally dropped, after its last attempt failed or its retry could not be
+// queued. Registration must happen before Run.
+func (w *MemoryWorker) RegisterDropped(pattern string, handler func(payload []byte, err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dropped[pattern] = handler
+}
+
+// Enqueue places a job on the queue. It never blocks; a full queue surfaces
+// as ErrQueueFull so callers can report the callback as failed.
+func (w *MemoryWorker) Enqueue(pattern string, payload []byte) error {
+	w.mu.Lock()
+	stopped := w.stopped
+	w.mu.Unlock()
+	if stopped {
+		return ErrWorkerStopped
+	}
+
+	select {
+	case w.queue <- memoryTask{pattern: pattern, payload: payload, attempt: 1}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run processes jobs until Shutdown is called. It blocks, mirroring the
+// redis-backed worker, and is meant to be started on its own goroutine.
+func (w *MemoryWorker) Run() {
+	for i := 0; i < w.concurrency; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for {
+				select {
+				case task := <-w.queue:
+					w.process(task)
+				case <-w.draining:
+					// Drain what is already queued, then exit.
+					select {
+					case task := <-w.queue:
+						w.process(task)
+					default:
+						return
+					}
+				}
+			}
+		}()
+	}
+	w.wg.Wait()
+}
+
+// Shutdown stops accepting jobs and waits for queued ones to drain or the
+// context to expire.
+func (w *MemoryWorker) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.draining)
+	}
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *MemoryWorker) process(task memoryTask) {
+	w.mu.Lock()
+	handler, ok := w.handlers[task.pattern]
+	w.mu.Unlock()
+	if !ok {
+		w.warn("no handler registered for pattern %s", task.pattern)
+		return
+	}
+
+	if err := handler(context.Background(), task.payload); err != nil {
+		if task.attempt >= w.maxAttempts {
+			w.warn("dropping job %s after %d attempts: %s", task.pattern, task.attempt, err.Error())
+			w.drop(task, err)
+			return
+		}
+
+		task.attempt++
+		wait := w.backoff(task.attempt - 1)
+		time.AfterFunc(wait, func() {
+			select {
+			case w.queue <- task:
+			default:
+				w.warn("dropping job %s: retry queue is full", task.pattern)
+				w.drop(task, err)
+			}
+		})
+	}
+}
+
+func (w *MemoryWorker) drop(task memoryTask, err error) {
+	w.mu.Lock()
+	handler, ok := w.dropped[task.pattern]
+	w.mu.Unlock()
+	if ok {
+		handler(task.payload, err)
+	}
+}
@@ -0,0 +1,143 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func discardWarn(string, ...interface{}) {}
+
+func TestMemoryWorkerConcurrencyBound(t *testing.T) {
+	const concurrency = 3
+	const jobs = 12
+
+	worker := NewMemoryWorker(concurrency, 1, discardWarn)
+
+	var inFlight, maxInFlight int32
+	var done sync.WaitGroup
+	done.Add(jobs)
+	worker.Register("job", func(ctx context.Context, payload []byte) error {
+		defer done.Done()
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return nil
+	})
+
+	for i := 0; i < jobs; i++ {
+		if err := worker.Enqueue("job", nil); err != nil {
+			t.Fatalf("enqueue failed: %s", err.Error())
+		}
+	}
+	go worker.Run()
+	done.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := worker.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %s", err.Error())
+	}
+
+	if got := atomic.LoadInt32(&maxInFlight); got > concurrency || got == 0 {
+		t.Fatalf("expected between 1 and %d jobs in flight, observed %d", concurrency, got)
+	}
+}
+
+func TestMemoryWorkerRetriesWithBackoff(t *testing.T) {
+	worker := NewMemoryWorker(1, 3, discardWarn)
+	worker.backoff = func(int) time.Duration { return time.Millisecond }
+
+	var attempts int32
+	succeeded := make(chan struct{})
+	worker.Register("flaky", func(ctx context.Context, payload []byte) error {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return errors.New("transient")
+		}
+		close(succeeded)
+		return nil
+	})
+
+	if err := worker.Enqueue("flaky", nil); err != nil {
+		t.Fatalf("enqueue failed: %s", err.Error())
+	}
+	go worker.Run()
+
+	select {
+	case <-succeeded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job was not retried to success")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	worker.Shutdown(ctx)
+}
+
+func TestMemoryWorkerDropsAfterMaxAttempts(t *testing.T) {
+	worker := NewMemoryWorker(1, 2, discardWarn)
+	worker.backoff = func(int) time.Duration { return time.Millisecond }
+
+	var attempts int32
+	worker.Register("failing", func(ctx context.Context, payload []byte) error {
+		atomic.AddInt32(&attempts, 1)
+		return errors.New("permanent")
+	})
+
+	if err := worker.Enqueue("failing", nil); err != nil {
+		t.Fatalf("enqueue failed: %s", err.Error())
+	}
+	go worker.Run()
+	time.Sleep(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	worker.Shutdown(ctx)
+
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Fatalf("expected the job to stop after 2 attempts, got %d", got)
+	}
+}
+
+func TestMemoryWorkerEnqueueAfterShutdown(t *testing.T) {
+	worker := NewMemoryWorker(1, 1, discardWarn)
+	go worker.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	worker.Shutdown(ctx)
+
+	if err := worker.Enqueue("job", nil); !errors.Is(err, ErrWorkerStopped) {
+		t.Fatalf("expected ErrWorkerStopped, got %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+/**
+ *
+ * (c) Copyright Ascensio System SIA 2023
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// The standalone binary runs gateway, builder, callback and auth as one
+// process for small self-hosted installs: one binary, one configuration
+// file, no broker between the services. The rpc calls the distributed
+// binaries put on the network are dispatched in-process through
+// rpc.LocalClient; the distributed binaries themselves stay the default
+// deployment and are untouched by this mode.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	authadapter "github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/adapter"
+	authcrypto "github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/crypto"
+	authport "github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/port"
+	authservice "github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/core/service"
+	authhandler "github.com/ONLYOFFICE/onlyoffice-gdrive/services/auth/web/handler"
+	builderhandler "github.com/ONLYOFFICE/onlyoffice-gdrive/services/builder/web/handler"
+	callbackcontroller "github.com/ONLYOFFICE/onlyoffice-gdrive/services/callback/web/controller"
+	callbackworker "github.com/ONLYOFFICE/onlyoffice-gdrive/services/callback/web/worker"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/controller"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/gateway/web/middleware"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/buildinfo"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/cache"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/files"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/quota"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/rpc"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/sessions"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/settings"
+	"github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/timings"
+	sworker "github.com/ONLYOFFICE/onlyoffice-gdrive/services/shared/worker"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/config"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/crypto"
+	"github.com/ONLYOFFICE/onlyoffice-integration-adapters/log"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func main() {
+	configPath := flag.String("config_path", "./config/config.yml", "server configuration path")
+	// The gateway answers on the server address from the configuration; the
+	// Document Server's callbacks need their own listener, and the shared
+	// file's builder.callback_url must point at it.
+	callbackAddress := flag.String("callback_address", ":5044", "address of the callback listener")
+	flag.Parse()
+
+	serverConfig, err := config.BuildNewServerConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	credentialsConfig, err := shared.BuildNewCredentialsConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	persistenceConfig, err := config.BuildNewPersistenceConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	workerConfig, err := config.BuildNewWorkerConfig(*configPath)()
+	if err != nil {
+		panic(err)
+	}
+
+	logger := log.NewDefaultLogger(serverConfig)
+
+	onlyofficeConfig, err := shared.NewOnlyofficeConfigSource(*configPath, logger.Warnf)
+	if err != nil {
+		logger.Fatalf("could not load the onlyoffice configuration: %s", err.Error())
+	}
+	if err := onlyofficeConfig.Watch(context.Background()); err != nil {
+		logger.Warnf("could not start the configuration watcher: %s", err.Error())
+	}
+	shared.LogEffectiveConfig(logger, onlyofficeConfig.Config())
+	if err := credentialsConfig.ResolveRedirectURI(
+		onlyofficeConfig.Config().Onlyoffice.Builder.GatewayURL,
+	); err != nil {
+		logger.Fatalf("could not resolve the oauth redirect uri: %s", err.Error())
+	}
+	if gatewayURL := onlyofficeConfig.Config().Onlyoffice.Builder.GatewayURL; !controller.RedirectURIMatches(
+		credentialsConfig.Credentials.RedirectURI, gatewayURL,
+	) {
+		logger.Warnf(
+			"the configured redirect uri %q does not match this gateway's %q; Google will reject the consent round trip",
+			credentialsConfig.Credentials.RedirectURI,
+			controller.ExpectedRedirectURI(gatewayURL),
+		)
+	}
+
+	// Every cross-service call in this process goes through the local
+	// router; the services keep addressing each other by name, exactly as
+	// they do over the network in distributed mode.
+	router := rpc.NewLocalRouter()
+	localClient := rpc.NewLocalClient(router)
+
+	jwtManager := crypto.NewOnlyofficeJwtManager()
+	fileUtility := files.NewOnlyofficeFileUtility()
+	// One monitor and one timings recorder for the whole process: gateway
+	// and callback normally each own theirs, but the prometheus metrics they
+	// register exist once per process.
+	monitor := quota.NewMonitor(
+		onlyofficeConfig.Config().Onlyoffice.Quota.LimitPerMinute,
+		onlyofficeConfig.Config().Onlyoffice.Quota.Threshold,
+	)
+	timingsRecorder := timings.NewRecorder()
+	timingsRecorder.StartSummaries(context.Background(), 5*time.Minute, logger.Infof)
+
+	// Auth: the same wiring as its own binary, minus the rpc listener. The
+	// persistence backend is still required — standalone merges processes,
+	// not storage. With one process the select handler's local refresh
+	// deduplication is all the locking token refreshes need.
+	userAdapter, err := authadapter.NewMongoUserAdapter(persistenceConfig.Persistence.URL)
+	if err != nil {
+		logger.Fatalf("could not connect to the persistence backend: %s", err.Error())
+	}
+	var cacheInvalidator authport.UserCacheInvalidator
+	if ttl := onlyofficeConfig.Config().Onlyoffice.Auth.CacheTTL; ttl > 0 {
+		cached := authadapter.NewCachingUserAdapter(userAdapter, time.Duration(ttl)*time.Second)
+		userAdapter = cached
+		cacheInvalidator = cached
+	}
+	encryptor := authcrypto.NewAesGcmEncryptor()
+	if onlyofficeConfig.Config().Onlyoffice.Auth.Encryption == shared.EncryptionLegacy {
+		encryptor = authcrypto.NewLegacyEncryptor()
+	}
+	userService := authservice.NewUserAccessService(
+		userAdapter, encryptor,
+		[]byte(credentialsConfig.Credentials.ClientSecret), logger,
+	)
+	selectHandler := authhandler.NewUserSelectHandler(
+		userService, credentialsConfig, authhandler.NewRefreshMetrics(), nil, logger,
+	)
+	insertHandler := authhandler.NewUserInsertHandler(userService, logger)
+	deleteHandler := authhandler.NewUserDeleteHandler(userService, logger)
+	cacheHandler := authhandler.NewUserCacheHandler(cacheInvalidator, logger)
+	authName := fmt.Sprintf("%s:auth", serverConfig.Namespace)
+	for _, h := range []interface{}{&selectHandler, &insertHandler, &deleteHandler, &cacheHandler} {
+		if err := router.Register(authName, h); err != nil {
+			logger.Fatalf("could not register an rpc handler: %s", err.Error())
+		}
+	}
+
+	// Builder: one handler, no listener. The gateway URL reachability check
+	// its binary runs at startup is skipped — the gateway it would probe is
+	// this process and is not listening yet.
+	configHandler := builderhandler.NewConfigHandler(
+		localClient, jwtManager, fileUtility, serverConfig,
+		credentialsConfig, onlyofficeConfig, logger,
+	)
+	if err := router.Register(
+		fmt.Sprintf("%s:builder", serverConfig.Namespace), &configHandler,
+	); err != nil {
+		logger.Fatalf("could not register the config handler: %s", err.Error())
+	}
+
+	// Callback: its own listener, as in distributed mode, since the Document
+	// Server addresses it by URL rather than by service name.
+	flags := shared.NewConfigFlags(onlyofficeConfig)
+	uploadWorker := callbackworker.NewCallbackWorker(
+		localClient, jwtManager, fileUtility, serverConfig, credentialsConfig,
+		onlyofficeConfig, flags, monitor, timingsRecorder, logger,
+	)
+	uploadRetries := callbackcontroller.NewUploadRetries(onlyofficeConfig)
+	trackedUpload := uploadRetries.Track(uploadWorker.UploadFile)
+	// Standalone is the single-node case the in-process queue exists for;
+	// installs that want the durable broker should run the distributed
+	// binaries instead.
+	memoryWorker := sworker.NewMemoryWorker(workerConfig.MaxConcurrency, 3, logger.Warnf)
+	memoryWorker.Register(callbackcontroller.UploadPattern, trackedUpload)
+	memoryWorker.RegisterDropped(callbackcontroller.UploadPattern, uploadWorker.NotifyUploadFailed)
+	go memoryWorker.Run()
+	callbackController := callbackcontroller.NewCallbackController(
+		memoryWorker, trackedUpload, uploadRetries, jwtManager,
+		credentialsConfig, onlyofficeConfig, flags, logger,
+	)
+	callbackMux := chi.NewRouter()
+	callbackMux.Use(chimiddleware.RequestID)
+	callbackMux.Use(shared.TraceMiddleware(onlyofficeConfig, serverConfig.Debug, logger))
+	callbackMux.Post("/callback", callbackController.BuildPostHandleCallback())
+	callbackMux.Get("/callback/retry", callbackController.BuildGetRetryUpload())
+	callbackMux.Handle("/metrics", promhttp.Handler())
+	callbackMux.Get("/version", buildinfo.Handler())
+	callbackMux.Route("/internal", func(r chi.Router) {
+		r.Use(shared.InternalAuthMiddleware(onlyofficeConfig))
+		r.Post("/reload", func(rw http.ResponseWriter, _ *http.Request) {
+			if err := onlyofficeConfig.Reload(); err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			rw.WriteHeader(http.StatusOK)
+		})
+	})
+
+	// Gateway: the same wiring as its own binary with the local client in
+	// place of the network one.
+	sourceService := controller.NewSourceURLService(
+		jwtManager, credentialsConfig, onlyofficeConfig,
+	)
+	sessionRegistry := sessions.NewRegistry(
+		time.Duration(onlyofficeConfig.Config().Onlyoffice.Sessions.TTL) * time.Second,
+	)
+	identityCache := cache.NewIdentityCache(time.Minute)
+	stateStore := middleware.NewStateStore(2 * time.Minute)
+	var sessionPayloads *sessions.PayloadStore
+	if onlyofficeConfig.Config().Onlyoffice.Gateway.ServerSessions {
+		sessionPayloads = sessions.NewPayloadStore()
+	}
+	sessionCodec := sessions.NewCookieCodec(sessionPayloads, logger.Warnf)
+	sessionMiddleware := middleware.NewSessionMiddleware(
+		jwtManager, localClient, fileUtility, serverConfig,
+		credentialsConfig, onlyofficeConfig, sessionRegistry, identityCache,
+		stateStore, sessionCodec, logger,
+	)
+	authController := controller.NewAuthController(
+		localClient, jwtManager, serverConfig, credentialsConfig,
+		onlyofficeConfig, sessionCodec, logger,
+	)
+	dsHealth := shared.NewDSHealth(onlyofficeConfig, 30*time.Second)
+	configCache := cache.NewConfigCache(30 * time.Second)
+	editorController := controller.NewEditorController(
+		localClient, serverConfig, onlyofficeConfig, sessionRegistry,
+		configCache, dsHealth, logger,
+	)
+	conversionCache := cache.NewConversionCache(
+		time.Duration(onlyofficeConfig.Config().Onlyoffice.Conversion.CacheTTL) * time.Second,
+	)
+	settingsStore := settings.NewStore(5 * time.Minute)
+	nonceStore := cache.NewNonceStore(time.Hour)
+	convertController := controller.NewConvertController(
+		localClient, jwtManager, fileUtility, serverConfig,
+		credentialsConfig, onlyofficeConfig, sourceService, monitor,
+		conversionCache, stateStore, settingsStore, nonceStore,
+		sessionRegistry, timingsRecorder, logger,
+	)
+	createController := controller.NewCreateController(
+		localClient, fileUtility, serverConfig, credentialsConfig,
+		onlyofficeConfig, settingsStore, nonceStore, logger,
+	)
+	apiController := controller.NewAPIController(
+		localClient, jwtManager, fileUtility, serverConfig,
+		credentialsConfig, onlyofficeConfig, monitor, sessionRegistry, logger,
+	)
+	pickerController := controller.NewPickerController(
+		localClient, jwtManager, fileUtility, serverConfig,
+		credentialsConfig, onlyofficeConfig, monitor, sessionRegistry, logger,
+	)
+	sessionsController := controller.NewSessionsController(
+		sessionRegistry, jwtManager, onlyofficeConfig, logger,
+	)
+	settingsController := controller.NewSettingsController(
+		settingsStore, credentialsConfig, logger,
+	)
+	adminController := controller.NewAdminController(
+		localClient, serverConfig, identityCache, settingsStore, logger,
+	)
+	emailController := controller.NewEmailController(
+		fileUtility, credentialsConfig, onlyofficeConfig, monitor, logger,
+	)
+
+	server := web.NewServer(
+		authController, editorController, convertController,
+		createController, apiController, pickerController, sessionsController,
+		settingsController, adminController, emailController,
+		sessionMiddleware, onlyofficeConfig,
+		serverConfig, serverConfig.Debug, logger,
+	)
+
+	gatewayServer := &http.Server{
+		Addr:    serverConfig.Address,
+		Handler: server.NewHandler(),
+	}
+	callbackServer := &http.Server{
+		Addr:    *callbackAddress,
+		Handler: callbackMux,
+	}
+
+	// One lifecycle stops both listeners; there is no registry to leave, so
+	// the deregister step is a no-op.
+	gatewayConfig := onlyofficeConfig.Config().Onlyoffice.Gateway
+	lifecycle := shared.NewLifecycle(
+		time.Duration(gatewayConfig.DrainDelay)*time.Second,
+		time.Duration(gatewayConfig.ShutdownTimeout)*time.Second,
+		func() error { return nil },
+		func(ctx context.Context) error {
+			err := gatewayServer.Shutdown(ctx)
+			if cerr := callbackServer.Shutdown(ctx); err == nil {
+				err = cerr
+			}
+			return err
+		},
+		logger.Warnf,
+	)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	drained := make(chan struct{})
+	go func() {
+		<-stop
+		lifecycle.Shutdown(context.Background())
+		close(drained)
+	}()
+
+	go func() {
+		logger.Infof("starting standalone callback on %s", *callbackAddress)
+		if err := callbackServer.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Fatalf("callback stopped: %s", err.Error())
+		}
+	}()
+
+	logger.Infof("starting standalone gateway on %s", serverConfig.Address)
+	if err := gatewayServer.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Fatalf("gateway stopped: %s", err.Error())
+	}
+	<-drained
+	logger.Infof("standalone gateway drained and stopped")
+}